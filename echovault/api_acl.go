@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/tidwall/resp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ACLLoadOptions modifies the behaviour of the ACLLoad function.
@@ -31,6 +33,50 @@ type ACLLoadOptions struct {
 	Replace bool
 }
 
+// ACLLogOptions modifies the behaviour of the ACLLog function.
+// Count is the maximum number of entries to return. When it's 0, up to 10 entries are returned.
+// If Reset is true, the log is cleared and no entries are returned.
+type ACLLogOptions struct {
+	Count uint
+	Reset bool
+}
+
+// ACLLogEntry is an entry of the ACL log returned by the ACLLog function.
+// It records a command that was denied or a connection that failed to authenticate.
+// Repeated events from the same client are recorded in a single entry.
+//
+// EntryID - int - the ID of the entry. Newer entries have higher IDs.
+//
+// Count - int - the number of times the event was repeated.
+//
+// Reason - string - "auth", "command", "key" or "channel".
+//
+// Object - string - the command, key or channel that was denied. Empty for failed authentications.
+//
+// Command - string - the command that was denied, or that failed to authenticate the connection.
+//
+// Username - string - the user of the connection, or the user the connection failed to authenticate as.
+//
+// ClientAddr - string - the remote address of the connection.
+//
+// AgeSeconds - float64 - the number of seconds since the first event.
+//
+// CreatedAt - time.Time - the time of the first event.
+//
+// UpdatedAt - time.Time - the time of the latest event.
+type ACLLogEntry struct {
+	EntryID    int
+	Count      int
+	Reason     string
+	Object     string
+	Command    string
+	Username   string
+	ClientAddr string
+	AgeSeconds float64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
 // User is the user object passed to the ACLSetUser function to update an existing user or create a new user.
 //
 // Username - string - the user's username.
@@ -52,10 +98,13 @@ type ACLLoadOptions struct {
 // AddPlainPasswords - []string - the list of plaintext passwords to add to the user's passwords.
 //
 // RemovePlainPasswords - []string - the list of plaintext passwords to remove from the user's passwords.
+// Any hashed password that matches the plaintext is removed as well.
 //
-// AddHashPasswords - []string - the list of SHA256 password hashes to add to the user's passwords.
+// AddHashPasswords - []string - the list of password hashes to add to the user's passwords. A hash can be a hex
+// encoded SHA256 digest, a bcrypt hash (e.g. "$2a$10$...") or an argon2id hash in the PHC string format
+// (e.g. "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>").
 //
-// RemoveHashPasswords - []string - the list of SHA256 password hashes to add to the user's passwords.
+// RemoveHashPasswords - []string - the list of password hashes to remove from the user's passwords.
 //
 // IncludeCategories - []string - the list of ACL command categories to allow this user to access, default is all.
 //
@@ -375,6 +424,7 @@ func (server *EchoVault) ACLLoad(options ACLLoadOptions) (bool, error) {
 }
 
 // ACLSave saves the current ACL configuration to the configured ACL file.
+// Plaintext passwords are hashed before they're saved, using the hash in the acl-password-hash config.
 //
 // Returns: true if the save is successful.
 func (server *EchoVault) ACLSave() (bool, error) {
@@ -385,3 +435,67 @@ func (server *EchoVault) ACLSave() (bool, error) {
 	s, err := internal.ParseStringResponse(b)
 	return strings.EqualFold(s, "ok"), err
 }
+
+// ACLLog lists the latest ACL security events, starting with the most recent. These are the commands that were denied
+// and the connections that failed to authenticate.
+//
+// Parameters:
+//
+// `options` - ACLLogOptions - the number of entries to list, or whether to clear the log.
+//
+// Returns: the entries of the log. The slice is empty when the log is reset.
+func (server *EchoVault) ACLLog(options ACLLogOptions) ([]ACLLogEntry, error) {
+	cmd := []string{"ACL", "LOG"}
+	switch {
+	case options.Reset:
+		cmd = append(cmd, "RESET")
+	case options.Count > 0:
+		cmd = append(cmd, strconv.Itoa(int(options.Count)))
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	if options.Reset {
+		return []ACLLogEntry{}, nil
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ACLLogEntry, len(v.Array()))
+	for i, e := range v.Array() {
+		fields := e.Array()
+		for j := 0; j+1 < len(fields); j += 2 {
+			value := fields[j+1]
+			switch fields[j].String() {
+			case "entry-id":
+				entries[i].EntryID = value.Integer()
+			case "count":
+				entries[i].Count = value.Integer()
+			case "reason":
+				entries[i].Reason = value.String()
+			case "object":
+				entries[i].Object = value.String()
+			case "command":
+				entries[i].Command = value.String()
+			case "username":
+				entries[i].Username = value.String()
+			case "client-addr":
+				entries[i].ClientAddr = value.String()
+			case "age-seconds":
+				entries[i].AgeSeconds = value.Float()
+			case "timestamp-created":
+				entries[i].CreatedAt = time.UnixMilli(int64(value.Integer()))
+			case "timestamp-last-updated":
+				entries[i].UpdatedAt = time.UnixMilli(int64(value.Integer()))
+			}
+		}
+	}
+
+	return entries, nil
+}
@@ -124,6 +124,20 @@ func generateSHA256Password(plain string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// maskPasswordHashes replaces the bcrypt password hashes in the ACL LIST rules of a user,
+// as the hashes are different each time a password is hashed.
+func maskPasswordHashes(rules []string) []string {
+	masked := make([]string, len(rules))
+	for i, rule := range rules {
+		if strings.HasPrefix(rule, "#$2") {
+			masked[i] = "#<bcrypt>"
+			continue
+		}
+		masked[i] = rule
+	}
+	return masked
+}
+
 func TestEchoVault_ACLCat(t *testing.T) {
 	server := createEchoVault()
 
@@ -156,7 +170,9 @@ func TestEchoVault_ACLCat(t *testing.T) {
 				constants.AdminCategory, constants.ConnectionCategory, constants.DangerousCategory,
 				constants.HashCategory, constants.FastCategory, constants.KeyspaceCategory, constants.ListCategory,
 				constants.PubSubCategory, constants.ReadCategory, constants.WriteCategory, constants.SetCategory,
-				constants.SortedSetCategory, constants.SlowCategory, constants.StringCategory,
+				constants.SortedSetCategory, constants.SlowCategory, constants.StringCategory, constants.TransactionCategory,
+				constants.StreamCategory, constants.BlockingCategory, constants.BitmapCategory, constants.HyperLogLogCategory,
+				constants.GeoCategory, constants.ScriptingCategory,
 			},
 			wantErr: false,
 		},
@@ -386,10 +402,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				path: path.Join(baseDir, "json_test.json"),
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 				},
 			},
 			{
@@ -397,10 +413,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				path: path.Join(baseDir, "yaml_test.yaml"),
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 				},
 			},
 			{
@@ -408,10 +424,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				path: path.Join(baseDir, "yml_test.yml"),
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 				},
 			},
 		}
@@ -454,7 +470,7 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				// Check if ACL LIST returns the expected list of users.
 				var resStr []string
 				for i := 0; i < len(list); i++ {
-					resStr = strings.Split(list[i], " ")
+					resStr = maskPasswordHashes(strings.Split(list[i], " "))
 					if !slices.ContainsFunc(test.want, func(s string) bool {
 						expectedUserSlice := strings.Split(s, " ")
 						return compareSlices(resStr, expectedUserSlice) == nil
@@ -491,10 +507,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 on +@all +all %RW~* +&*",
 				},
 			},
@@ -509,10 +525,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 on +@all +all %RW~* +&*",
 				},
 			},
@@ -527,10 +543,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 on +@all +all %RW~* +&*",
 				},
 			},
@@ -557,10 +573,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf(`with_password_user on >password2 >password3 >password4 #%s +@all +all %s~key1 %s~key2 %s~key5 %s~key6 %s~key3 %s~key4 +&channel[12] -&channel[34]`,
+					fmt.Sprintf(`with_password_user on #<bcrypt> #<bcrypt> #<bcrypt> #%s +@all +all %s~key1 %s~key2 %s~key5 %s~key6 %s~key3 %s~key4 +&channel[12] -&channel[34]`,
 						generateSHA256Password("password3"), "%RW", "%RW", "%R", "%R", "%W", "%W"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 off +@all +all %RW~* +&*",
 				},
 			},
@@ -587,10 +603,10 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 off +@all +all %RW~* +&*",
 				},
 			},
@@ -649,7 +665,8 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 				// Check if ACL LIST returns the expected list of users.
 				var resStr []string
 				for i := 0; i < len(list); i++ {
-					resStr = strings.Split(list[i], " ")
+					// The plaintext passwords are hashed when the users are saved before they're loaded.
+					resStr = maskPasswordHashes(strings.Split(list[i], " "))
 					if !slices.ContainsFunc(test.want, func(s string) bool {
 						expectedUserSlice := strings.Split(s, " ")
 						return compareSlices(resStr, expectedUserSlice) == nil
@@ -662,3 +679,59 @@ func TestEchoVault_ACLConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestEchoVault_ACLLog(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.ACLSetUser(User{
+		Username:          "log_user",
+		Enabled:           true,
+		AddPlainPasswords: []string{"password1"},
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Repeated failed authentications are recorded in a single entry.
+	for i := 0; i < 3; i++ {
+		if _, err := server.ExecuteCommand("AUTH", "log_user", "wrong_password"); err == nil {
+			t.Error("expected AUTH with the wrong password to fail")
+		}
+	}
+	if _, err := server.ExecuteCommand("AUTH", "missing_user", "password1"); err == nil {
+		t.Error("expected AUTH with a missing user to fail")
+	}
+
+	entries, err := server.ACLLog(ACLLogOptions{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []ACLLogEntry{
+		{EntryID: 1, Count: 1, Reason: "auth", Command: "auth", Username: "missing_user"},
+		{EntryID: 0, Count: 3, Reason: "auth", Command: "auth", Username: "log_user"},
+	}
+	if len(entries) != len(want) {
+		t.Errorf("expected %d entries, got %d", len(want), len(entries))
+		return
+	}
+	for i, entry := range entries {
+		if entry.EntryID != want[i].EntryID || entry.Count != want[i].Count || entry.Reason != want[i].Reason ||
+			entry.Command != want[i].Command || entry.Username != want[i].Username {
+			t.Errorf("expected entry %d to be %+v, got %+v", i, want[i], entry)
+		}
+		if !entry.CreatedAt.Equal(server.clock.Now().Truncate(time.Millisecond)) {
+			t.Errorf("expected entry %d to be created at %v, got %v", i, server.clock.Now(), entry.CreatedAt)
+		}
+	}
+
+	if entries, err = server.ACLLog(ACLLogOptions{Count: 1}); err != nil || len(entries) != 1 {
+		t.Errorf("expected 1 entry, got %d (%v)", len(entries), err)
+	}
+	if _, err = server.ACLLog(ACLLogOptions{Reset: true}); err != nil {
+		t.Error(err)
+	}
+	if entries, err = server.ACLLog(ACLLogOptions{}); err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries after reset, got %d (%v)", len(entries), err)
+	}
+}
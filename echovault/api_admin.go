@@ -203,6 +203,46 @@ func (server *EchoVault) RewriteAOF() (string, error) {
 	return internal.ParseStringResponse(b)
 }
 
+// MemoryUsage returns the estimated number of bytes used to store the key and its value.
+// Collections are estimated from a sample of 5 of their elements.
+//
+// Returns: the estimated number of bytes, or 0 if the key does not exist.
+func (server *EchoVault) MemoryUsage(key string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"MEMORY", "USAGE", key}), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// MemoryStats returns the estimated memory usage of the dataset and the memory allocated by the server.
+//
+// Returns: a map of the stat names (e.g. "dataset.bytes", "keys.count") to their values.
+func (server *EchoVault) MemoryStats() (map[string]string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"MEMORY", "STATS"}), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := internal.ParseStringArrayResponse(b)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]string, len(arr)/2)
+	for i := 0; i+1 < len(arr); i += 2 {
+		stats[arr[i]] = arr[i+1]
+	}
+	return stats, nil
+}
+
+// MemoryDoctor returns a report of the memory problems detected by the server.
+func (server *EchoVault) MemoryDoctor() (string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"MEMORY", "DOCTOR"}), nil, false, true)
+	if err != nil {
+		return "", err
+	}
+	return internal.ParseStringResponse(b)
+}
+
 // AddCommand adds a new command to EchoVault. The added command can be executed using the ExecuteCommand method.
 //
 // Parameters:
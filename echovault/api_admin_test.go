@@ -425,7 +425,7 @@ func TestEchoVault_CommandList(t *testing.T) {
 			want: func() []string {
 				var commands []string
 				for _, command := range server.commands {
-					if strings.EqualFold(command.Module, constants.SortedSetModule) {
+					if strings.EqualFold(command.Module, constants.SortedSetModule) && strings.HasPrefix(command.Command, "z") {
 						commands = append(commands, strings.ToLower(command.Command))
 					}
 				}
@@ -567,3 +567,134 @@ func TestEchoVault_LastSave(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_Memory(t *testing.T) {
+	t.Run("Test_MemoryUsage", func(t *testing.T) {
+		server := createEchoVault()
+
+		if _, _, err := server.Set("key1", "value1", SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		small, err := server.MemoryUsage("key1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if small <= len("key1")+len("value1") {
+			t.Errorf("expected usage of key1 to be more than the length of the key and value, got %d", small)
+		}
+
+		if _, _, err = server.Set("key2", strings.Repeat("a", 1000), SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		large, err := server.MemoryUsage("key2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if large-small < 1000-len("value1") {
+			t.Errorf("expected usage of key2 to grow with the value, got %d and %d", small, large)
+		}
+
+		if usage, err := server.MemoryUsage("key3"); err != nil || usage != 0 {
+			t.Errorf("expected usage of a missing key to be 0, got %d (%v)", usage, err)
+		}
+
+		// The tracked dataset size is the sum of the key sizes, and it shrinks when keys are deleted.
+		stats, err := server.MemoryStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats["dataset.bytes"] != strconv.Itoa(small+large) {
+			t.Errorf("expected dataset.bytes to be %d, got %s", small+large, stats["dataset.bytes"])
+		}
+		if stats["keys.count"] != "2" {
+			t.Errorf("expected keys.count to be 2, got %s", stats["keys.count"])
+		}
+		if _, err = server.Del("key2"); err != nil {
+			t.Fatal(err)
+		}
+		if stats, err = server.MemoryStats(); err != nil {
+			t.Fatal(err)
+		}
+		if stats["dataset.bytes"] != strconv.Itoa(small) {
+			t.Errorf("expected dataset.bytes to be %d, got %s", small, stats["dataset.bytes"])
+		}
+		if stats["dataset.peak.bytes"] != strconv.Itoa(small+large) {
+			t.Errorf("expected dataset.peak.bytes to be %d, got %s", small+large, stats["dataset.peak.bytes"])
+		}
+
+		// Values modified in place are re-estimated after the command.
+		if _, err = server.SAdd("set", "a"); err != nil {
+			t.Fatal(err)
+		}
+		before, _ := server.MemoryUsage("set")
+		if _, err = server.SAdd("set", "b", "c", "d"); err != nil {
+			t.Fatal(err)
+		}
+		if after, _ := server.MemoryUsage("set"); after <= before {
+			t.Errorf("expected set usage to grow after SADD, got %d and %d", before, after)
+		}
+		if stats, err = server.MemoryStats(); err != nil {
+			t.Fatal(err)
+		}
+		if after, _ := server.MemoryUsage("set"); stats["dataset.bytes"] != strconv.Itoa(small+after) {
+			t.Errorf("expected dataset.bytes to be %d, got %s", small+after, stats["dataset.bytes"])
+		}
+	})
+
+	t.Run("Test_MaxMemoryNoEviction", func(t *testing.T) {
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.MaxMemory = 1000
+		conf.EvictionPolicy = constants.NoEviction
+		server := createEchoVaultWithConfig(conf)
+
+		// Writes are accepted until the tracked dataset size reaches max-memory.
+		if _, _, err := server.Set("key1", strings.Repeat("a", 1000), SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := server.Set("key2", "value2", SetOptions{}); err == nil {
+			t.Error("expected write to be rejected once max-memory is reached")
+		}
+
+		report, err := server.MemoryDoctor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(report, "writes are rejected") {
+			t.Errorf("expected doctor report to mention rejected writes, got %q", report)
+		}
+	})
+
+	t.Run("Test_MaxMemoryEviction", func(t *testing.T) {
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.MaxMemory = 2000
+		conf.EvictionPolicy = constants.AllKeysLRU
+		server := createEchoVaultWithConfig(conf)
+
+		for i := 0; i < 20; i++ {
+			if _, _, err := server.Set(fmt.Sprintf("key%d", i), strings.Repeat("a", 200), SetOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Keys are evicted in the background after they're written.
+		var stats map[string]string
+		for i := 0; i < 100; i++ {
+			var err error
+			if stats, err = server.MemoryStats(); err != nil {
+				t.Fatal(err)
+			}
+			if size, _ := strconv.Atoi(stats["dataset.bytes"]); uint64(size) < conf.MaxMemory {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if size, _ := strconv.Atoi(stats["dataset.bytes"]); uint64(size) >= conf.MaxMemory {
+			t.Errorf("expected dataset.bytes to be below max-memory, got %d", size)
+		}
+		if stats["evicted.keys"] == "0" {
+			t.Error("expected keys to have been evicted")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"github.com/echovault/echovault/internal"
+	"strconv"
+	"strings"
+)
+
+// ClusterNode is a member of the raft group of the EchoVault instance, as returned by ClusterNodes.
+//
+// Role is "leader", "follower" or "learner".
+//
+// Myself is true for the instance the nodes were listed from.
+//
+// LastContact is the number of milliseconds since the node last heard from the leader. It's 0 on the leader.
+//
+// LastContact, CommitIndex and AppliedIndex are -1 when they're unknown, for instance when the node could not be
+// reached. MemberlistAddr is empty when the node is not a known member of the memberlist cluster.
+type ClusterNode struct {
+	ID             string
+	RaftAddr       string
+	MemberlistAddr string
+	Role           string
+	Myself         bool
+	LastContact    int64
+	CommitIndex    int64
+	AppliedIndex   int64
+}
+
+// ClusterInfo returns the state of the instance's raft group and the raft stats of the instance.
+//
+// Returns: a map of the field names (e.g. "cluster_state", "leader_id", "commit_index") to their values.
+// Returns an error when the instance is not in a cluster.
+func (server *EchoVault) ClusterInfo() (map[string]string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"CLUSTER", "INFO"}), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	if err != nil {
+		return nil, err
+	}
+	info := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(res), "\r\n") {
+		if field, value, ok := strings.Cut(line, ":"); ok {
+			info[field] = value
+		}
+	}
+	return info, nil
+}
+
+// ClusterNodes returns the members of the instance's raft group.
+// Returns an error when the instance is not in a cluster.
+func (server *EchoVault) ClusterNodes() ([]ClusterNode, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"CLUSTER", "NODES"}), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []ClusterNode
+	for _, line := range strings.Split(strings.TrimSpace(res), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 7 {
+			continue
+		}
+		node := ClusterNode{
+			ID:             fields[0],
+			RaftAddr:       fields[1],
+			MemberlistAddr: fields[2],
+			Role:           strings.TrimPrefix(fields[3], "myself,"),
+			Myself:         strings.HasPrefix(fields[3], "myself,"),
+		}
+		if node.MemberlistAddr == "-" {
+			node.MemberlistAddr = ""
+		}
+		for i, n := range []*int64{&node.LastContact, &node.CommitIndex, &node.AppliedIndex} {
+			if *n, err = strconv.ParseInt(fields[4+i], 10, 64); err != nil {
+				*n = -1
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// ClusterLeave removes the instance from its raft group and leaves the memberlist cluster.
+// The instance keeps running, but can no longer serve the cluster's data and should be shut down.
+//
+// Returns: true if the instance left the cluster.
+func (server *EchoVault) ClusterLeave() (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"CLUSTER", "LEAVE"}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(res, "ok"), err
+}
+
+// ClusterRemove removes the node with the server ID from the raft group. The instance must be the leader.
+//
+// Returns: true if the node was removed.
+func (server *EchoVault) ClusterRemove(id string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"CLUSTER", "REMOVE", id}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(res, "ok"), err
+}
+
+// ClusterPromote makes the learner with the server ID a voter of the raft group. The instance must be the leader.
+//
+// Returns: true if the learner was promoted.
+func (server *EchoVault) ClusterPromote(id string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"CLUSTER", "PROMOTE", id}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(res, "ok"), err
+}
+
+// ClusterTransferLeader transfers the leadership of the raft group. The instance must be the leader.
+//
+// Parameters:
+//
+// `id` - string - the server ID of the new leader. When empty, the most up-to-date voter is picked.
+//
+// Returns: true if the leadership was transferred.
+func (server *EchoVault) ClusterTransferLeader(id string) (bool, error) {
+	cmd := []string{"CLUSTER", "TRANSFER-LEADER"}
+	if id != "" {
+		cmd = append(cmd, id)
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(res, "ok"), err
+}
+
+// ClusterSnapshot takes a raft snapshot of the instance and waits for it to complete.
+//
+// Returns: true if the snapshot was taken.
+func (server *EchoVault) ClusterSnapshot() (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"CLUSTER", "SNAPSHOT"}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	res, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(res, "ok"), err
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"testing"
+)
+
+func TestEchoVault_ClusterStandalone(t *testing.T) {
+	server := createEchoVault()
+	t.Cleanup(func() {
+		server.ShutDown()
+	})
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "1. ClusterInfo", call: func() error { _, err := server.ClusterInfo(); return err }},
+		{name: "2. ClusterNodes", call: func() error { _, err := server.ClusterNodes(); return err }},
+		{name: "3. ClusterLeave", call: func() error { _, err := server.ClusterLeave(); return err }},
+		{name: "4. ClusterRemove", call: func() error { _, err := server.ClusterRemove("SERVER-1"); return err }},
+		{name: "5. ClusterTransferLeader", call: func() error { _, err := server.ClusterTransferLeader(""); return err }},
+		{name: "6. ClusterSnapshot", call: func() error { _, err := server.ClusterSnapshot(); return err }},
+		{name: "7. ClusterPromote", call: func() error { _, err := server.ClusterPromote("SERVER-1"); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err == nil || err.Error() != "cluster support disabled" {
+				t.Errorf("expected error \"cluster support disabled\", got %v", err)
+			}
+		})
+	}
+}
@@ -13,3 +13,93 @@
 // limitations under the License.
 
 package echovault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReadConsistencyOptions sets the consistency of read commands in cluster mode.
+// In standalone mode, reads are always served from the local store.
+//
+// Mode - The read consistency mode. The options are:
+//
+// "leader" - The leader confirms it's still the leader with a quorum of the cluster before serving each read.
+// Reads on followers are rejected.
+//
+// "lease" - The leader serves reads without contacting the cluster while its leadership lease is valid.
+// Reads on followers are rejected.
+//
+// "stale" - Any node serves reads from its local store.
+//
+// MaxLag - For stale reads, the max time since a follower last heard from the leader.
+// Reads on followers that exceed it are rejected. 0 means there's no limit.
+type ReadConsistencyOptions struct {
+	Mode   string
+	MaxLag time.Duration
+}
+
+func parseReadConsistency(options ReadConsistencyOptions) (internal.ReadConsistency, error) {
+	mode := strings.ToLower(options.Mode)
+	if !slices.Contains([]string{
+		constants.ReadConsistencyLeader, constants.ReadConsistencyLease, constants.ReadConsistencyStale,
+	}, mode) {
+		return internal.ReadConsistency{}, fmt.Errorf("read consistency %s must be leader, lease or stale", options.Mode)
+	}
+	if options.MaxLag < 0 {
+		return internal.ReadConsistency{}, errors.New("max lag must be a non-negative duration")
+	}
+	return internal.ReadConsistency{Mode: mode, MaxLag: options.MaxLag}, nil
+}
+
+// SetReadConsistency sets the consistency of the read commands called through the embedded API.
+// The read consistency from the config is used until it's set.
+//
+// Parameters:
+//
+// `options` - ReadConsistencyOptions.
+//
+// Errors:
+//
+// "read consistency <mode> must be leader, lease or stale" - when the mode is not valid.
+func (server *EchoVault) SetReadConsistency(options ReadConsistencyOptions) error {
+	consistency, err := parseReadConsistency(options)
+	if err != nil {
+		return err
+	}
+	server.setReadConsistency(nil, consistency)
+	return nil
+}
+
+// ExecuteReadCommand works like ExecuteCommand, but a read command is served with the given consistency instead of
+// the one set with SetReadConsistency.
+//
+// Parameters:
+//
+// `options` - ReadConsistencyOptions.
+//
+// `command` - ...string.
+//
+// Returns: []byte - Raw RESP response returned by the command handler.
+//
+// Errors:
+//
+// "read consistency <mode> must be leader, lease or stale" - when the mode is not valid.
+//
+// "cannot serve read with <mode> consistency: ..." - when the node can't serve the read with the consistency.
+//
+// All the errors returned by ExecuteCommand.
+func (server *EchoVault) ExecuteReadCommand(options ReadConsistencyOptions, command ...string) ([]byte, error) {
+	consistency, err := parseReadConsistency(options)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(server.context, internal.ContextReadConsistency("ReadConsistency"), consistency)
+	return server.handleCommand(ctx, internal.EncodeCommand(command), nil, false, true)
+}
@@ -13,3 +13,75 @@
 // limitations under the License.
 
 package echovault
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEchoVault_ReadConsistency(t *testing.T) {
+	server := createEchoVault()
+
+	if _, _, err := server.Set("ReadConsistencyKey1", "value1", SetOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name    string
+		options ReadConsistencyOptions
+		wantErr string
+	}{
+		{
+			name:    "1. Leader read consistency",
+			options: ReadConsistencyOptions{Mode: "leader"},
+		},
+		{
+			name:    "2. Lease read consistency is case insensitive",
+			options: ReadConsistencyOptions{Mode: "LEASE"},
+		},
+		{
+			name:    "3. Stale read consistency with a max lag",
+			options: ReadConsistencyOptions{Mode: "stale", MaxLag: 100 * time.Millisecond},
+		},
+		{
+			name:    "4. Unknown read consistency",
+			options: ReadConsistencyOptions{Mode: "eventual"},
+			wantErr: "read consistency eventual must be leader, lease or stale",
+		},
+		{
+			name:    "5. Negative max lag",
+			options: ReadConsistencyOptions{Mode: "stale", MaxLag: -1},
+			wantErr: "max lag must be a non-negative duration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := server.SetReadConsistency(tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("SetReadConsistency() error = %v, wantErr %s", err, tt.wantErr)
+				}
+				if _, err = server.ExecuteReadCommand(tt.options, "GET", "ReadConsistencyKey1"); err == nil {
+					t.Errorf("ExecuteReadCommand() expected error %s", tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("SetReadConsistency() error = %v", err)
+				return
+			}
+			// In standalone mode, reads are served regardless of the read consistency.
+			got, err := server.Get("ReadConsistencyKey1")
+			if err != nil || got != "value1" {
+				t.Errorf("Get() = %s, %v, want value1", got, err)
+			}
+			res, err := server.ExecuteReadCommand(tt.options, "GET", "ReadConsistencyKey1")
+			if err != nil || string(res) != "+value1\r\n" {
+				t.Errorf("ExecuteReadCommand() = %q, %v, want value1", res, err)
+			}
+		})
+	}
+}
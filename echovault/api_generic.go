@@ -15,10 +15,13 @@
 package echovault
 
 import (
+	"bytes"
+	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
 )
 
 // SetOptions modifies the behaviour for the Set command
@@ -69,6 +72,39 @@ type PExpireOptions ExpireOptions
 type ExpireAtOptions ExpireOptions
 type PExpireAtOptions ExpireOptions
 
+// ScanOptions modifies the behaviour of the Scan, HScan, SScan and ZScan iterators.
+//
+// Match - Only return the elements that match the glob pattern.
+//
+// Count - The number of elements fetched from the server in each batch. Defaults to 10.
+//
+// Type - Only return the keys holding values of the given type (string, list, set, zset, hash or stream).
+// This is only used by Scan.
+type ScanOptions struct {
+	Match string
+	Count uint
+	Type  string
+}
+
+// ScanIterator is returned by Scan, HScan, SScan and ZScan. Seq is the type of the iterator returned by All.
+// The elements are fetched from the server in batches as the iteration progresses. If a batch can't be fetched,
+// the iteration stops early and Err returns the error.
+type ScanIterator[Seq any] struct {
+	all Seq
+	err error
+}
+
+// All returns the iterator over the elements. It can be used with a range statement.
+func (it *ScanIterator[Seq]) All() Seq {
+	return it.all
+}
+
+// Err returns the error that stopped the latest iteration early.
+// It's nil if the iteration completed, or was stopped by the caller.
+func (it *ScanIterator[Seq]) Err() error {
+	return it.err
+}
+
 // Set creates or modifies the value at the given key.
 //
 // Parameters:
@@ -206,6 +242,164 @@ func (server *EchoVault) Del(keys ...string) (int, error) {
 	return internal.ParseIntegerResponse(b)
 }
 
+// Unlink is an alias of Del. The memory held by the values of deleted keys is reclaimed by the garbage collector,
+// which runs concurrently, so neither command waits for large values to be released.
+//
+// Parameters:
+//
+// `keys` - []string - the keys to remove from the store.
+//
+// Returns: The number of keys that were removed.
+func (server *EchoVault) Unlink(keys ...string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"UNLINK"}, keys...)), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// Keys returns all the keys that match the glob pattern.
+//
+// Parameters:
+//
+// `pattern` - string - the glob pattern to match the keys against.
+//
+// Returns: A string slice of the matching keys.
+//
+// Errors:
+//
+// "invalid pattern <pattern>" - when the pattern is not a valid glob pattern.
+func (server *EchoVault) Keys(pattern string) ([]string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"KEYS", pattern}), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseStringArrayResponse(b)
+}
+
+// Type returns the type of the value stored at the key.
+//
+// Parameters:
+//
+// `key` - string - the key to check.
+//
+// Returns: One of "string", "list", "set", "zset", "hash" or "stream". Returns "none" if the key does not exist.
+func (server *EchoVault) Type(key string) (string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"TYPE", key}), nil, false, true)
+	if err != nil {
+		return "", err
+	}
+	return internal.ParseStringResponse(b)
+}
+
+// Exists returns the number of the given keys that exist. A key that is provided multiple times is counted each time.
+//
+// Parameters:
+//
+// `keys` - []string - the keys to check.
+//
+// Returns: The number of keys that exist.
+func (server *EchoVault) Exists(keys ...string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"EXISTS"}, keys...)), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// Rename renames the key to newKey, along with its expiry time. If newKey already exists, it is overwritten.
+//
+// Parameters:
+//
+// `key` - string - the key to rename.
+//
+// `newKey` - string - the new name of the key.
+//
+// Returns: true if the key was renamed.
+//
+// Errors:
+//
+// "no such key" - when the key does not exist.
+func (server *EchoVault) Rename(key, newKey string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"RENAME", key, newKey}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	s, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(s, "ok"), err
+}
+
+// RenameNX renames the key to newKey, along with its expiry time, only if newKey does not exist.
+//
+// Parameters:
+//
+// `key` - string - the key to rename.
+//
+// `newKey` - string - the new name of the key.
+//
+// Returns: true if the key was renamed, false if newKey already exists.
+//
+// Errors:
+//
+// "no such key" - when the key does not exist.
+func (server *EchoVault) RenameNX(key, newKey string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"RENAMENX", key, newKey}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	return internal.ParseBooleanResponse(b)
+}
+
+// Copy copies the value and the expiry time of the source key to the destination key.
+// Collections are deep copied, so modifying the copy does not modify the source.
+//
+// Parameters:
+//
+// `source` - string - the key to copy.
+//
+// `destination` - string - the key to copy to.
+//
+// `replace` - bool - overwrite the destination if it already exists.
+//
+// Returns: true if the key was copied, false if the source does not exist or the destination exists and replace is false.
+func (server *EchoVault) Copy(source, destination string, replace bool) (bool, error) {
+	cmd := []string{"COPY", source, destination}
+	if replace {
+		cmd = append(cmd, "REPLACE")
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	return internal.ParseBooleanResponse(b)
+}
+
+// RandomKey returns a random key from the store.
+//
+// Returns: A random key, or an empty string if the store is empty.
+func (server *EchoVault) RandomKey() (string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"RANDOMKEY"}), nil, false, true)
+	if err != nil {
+		return "", err
+	}
+	return internal.ParseStringResponse(b)
+}
+
+// Touch updates the last access time of the given keys.
+//
+// Parameters:
+//
+// `keys` - []string - the keys to touch.
+//
+// Returns: The number of keys that exist.
+func (server *EchoVault) Touch(keys ...string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"TOUCH"}, keys...)), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
 // Persist removes the expiry associated with a key and makes it permanent.
 // Has no effect on a key that is already persistent.
 //
@@ -461,3 +655,107 @@ func (server *EchoVault) Decr(key string) (int, error) {
 	// Parse the integer response
 	return internal.ParseIntegerResponse(b)
 }
+
+// Scan returns an iterator over the keys in the keyspace. The keys are fetched in batches as the iteration
+// progresses, so the store is never locked for the whole iteration.
+// A key that exists for the whole iteration is returned exactly once, even when keys are added or removed.
+// The iterator can be used with a range statement:
+//
+//	keys, err := server.Scan(echovault.ScanOptions{Match: "user:*"})
+//	for key := range keys.All() { ... }
+//	if err := keys.Err(); err != nil { ... }
+//
+// Parameters:
+//
+// `options` - ScanOptions.
+//
+// Returns: A ScanIterator over the keys. The first batch is fetched before Scan returns.
+// If a later batch can't be fetched, the iteration stops and the iterator's Err returns the error.
+//
+// Errors:
+//
+// "invalid pattern <pattern>" - when the Match pattern is not a valid glob pattern.
+func (server *EchoVault) Scan(options ScanOptions) (*ScanIterator[func(yield func(key string) bool)], error) {
+	it := &ScanIterator[func(yield func(key string) bool)]{}
+	batches, err := server.scanBatches(func(cursor uint64) []string {
+		cmd := append([]string{"SCAN", strconv.FormatUint(cursor, 10)}, buildScanArgs(options)...)
+		if options.Type != "" {
+			cmd = append(cmd, "TYPE", options.Type)
+		}
+		return cmd
+	}, &it.err)
+	if err != nil {
+		return nil, err
+	}
+	it.all = func(yield func(key string) bool) {
+		batches(func(keys []string) bool {
+			for _, key := range keys {
+				if !yield(key) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return it, nil
+}
+
+func buildScanArgs(options ScanOptions) []string {
+	var args []string
+	if options.Match != "" {
+		args = append(args, "MATCH", options.Match)
+	}
+	if options.Count > 0 {
+		args = append(args, "COUNT", strconv.FormatUint(uint64(options.Count), 10))
+	}
+	return args
+}
+
+// scanBatches returns an iterator over the batches of elements returned by a scan command.
+// The command function returns the command for the given cursor. The first batch is fetched straight away,
+// so that errors in the command are returned to the caller. The error that stops an iteration early
+// is stored in iterErr, which is reset when an iteration starts.
+func (server *EchoVault) scanBatches(command func(cursor uint64) []string, iterErr *error) (func(yield func(batch []string) bool), error) {
+	cursor, batch, err := server.scanBatch(command(0))
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(batch []string) bool) {
+		*iterErr = nil
+		cursor, batch := cursor, batch
+		for {
+			if !yield(batch) || cursor == 0 {
+				return
+			}
+			var err error
+			if cursor, batch, err = server.scanBatch(command(cursor)); err != nil {
+				*iterErr = err
+				return
+			}
+		}
+	}, nil
+}
+
+func (server *EchoVault) scanBatch(cmd []string) (uint64, []string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, nil, err
+	}
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(v.Array()) != 2 {
+		return 0, nil, fmt.Errorf("unexpected response to %s: %q", cmd[0], b)
+	}
+	cursor, err := strconv.ParseUint(v.Array()[0].String(), 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	batch := make([]string, len(v.Array()[1].Array()))
+	for i, e := range v.Array()[1].Array() {
+		batch[i] = e.String()
+	}
+	return cursor, batch, nil
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -1047,3 +1048,161 @@ func TestEchoVault_DECR(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_SCAN(t *testing.T) {
+	server := createEchoVault()
+
+	var expected []string
+	for i := 0; i < 30; i++ {
+		key := "scan_key" + strconv.Itoa(i)
+		if _, _, err := server.Set(key, "value", SetOptions{}); err != nil {
+			t.Error(err)
+			return
+		}
+		expected = append(expected, key)
+	}
+	if _, err := server.LPush("scan_list", "value"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	keys, err := server.Scan(ScanOptions{Match: "scan_key*", Count: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var got []string
+	keys.All()(func(key string) bool {
+		got = append(got, key)
+		return true
+	})
+	slices.Sort(got)
+	slices.Sort(expected)
+	if !slices.Equal(got, expected) {
+		t.Errorf("Scan() got = %v, want %v", got, expected)
+	}
+
+	// The iteration stops when yield returns false.
+	count := 0
+	keys.All()(func(key string) bool {
+		count += 1
+		return count < 5
+	})
+	if count != 5 {
+		t.Errorf("Scan() yielded %d keys after the iteration was stopped, want 5", count)
+	}
+
+	lists, err := server.Scan(ScanOptions{Match: "scan_*", Type: "list"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	got = []string{}
+	lists.All()(func(key string) bool {
+		got = append(got, key)
+		return true
+	})
+	if !reflect.DeepEqual(got, []string{"scan_list"}) {
+		t.Errorf("Scan() got = %v, want [scan_list]", got)
+	}
+
+	if _, err = server.Scan(ScanOptions{Match: "scan_[key"}); err == nil {
+		t.Error("Scan() expected an error for the invalid pattern")
+	}
+}
+
+func TestEchoVault_KeyspaceCommands(t *testing.T) {
+	server := createEchoVault()
+
+	if _, _, err := server.Set("keyspace_key1", "value1", SetOptions{EX: 100}); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := server.SAdd("keyspace_set1", "a", "b"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Run("Keys", func(t *testing.T) {
+		keys, err := server.Keys("keyspace_*")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, []string{"keyspace_key1", "keyspace_set1"}) {
+			t.Errorf("Keys() got = %v, want [keyspace_key1 keyspace_set1]", keys)
+		}
+	})
+
+	t.Run("Type", func(t *testing.T) {
+		for key, want := range map[string]string{"keyspace_key1": "string", "keyspace_set1": "set", "keyspace_none": "none"} {
+			got, err := server.Type(key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got != want {
+				t.Errorf("Type(%s) got = %s, want %s", key, got, want)
+			}
+		}
+	})
+
+	t.Run("Exists and Touch", func(t *testing.T) {
+		if got, err := server.Exists("keyspace_key1", "keyspace_set1", "keyspace_none"); err != nil || got != 2 {
+			t.Errorf("Exists() got = %d, %v, want 2", got, err)
+		}
+		if got, err := server.Touch("keyspace_key1", "keyspace_none"); err != nil || got != 1 {
+			t.Errorf("Touch() got = %d, %v, want 1", got, err)
+		}
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		if ok, err := server.Copy("keyspace_set1", "keyspace_set2", false); err != nil || !ok {
+			t.Errorf("Copy() got = %v, %v, want true", ok, err)
+			return
+		}
+		if _, err := server.SAdd("keyspace_set2", "c"); err != nil {
+			t.Error(err)
+			return
+		}
+		if got, _ := server.SCard("keyspace_set1"); got != 2 {
+			t.Errorf("Copy() modifying the copy changed the source, got %d members", got)
+		}
+		if ok, err := server.Copy("keyspace_set1", "keyspace_set2", false); err != nil || ok {
+			t.Errorf("Copy() got = %v, %v, want false when the destination exists", ok, err)
+		}
+		if ok, err := server.Copy("keyspace_set1", "keyspace_set2", true); err != nil || !ok {
+			t.Errorf("Copy() got = %v, %v, want true with replace", ok, err)
+		}
+	})
+
+	t.Run("Rename and RenameNX", func(t *testing.T) {
+		if ok, err := server.Rename("keyspace_key1", "keyspace_key2"); err != nil || !ok {
+			t.Errorf("Rename() got = %v, %v, want true", ok, err)
+			return
+		}
+		if ttl, _ := server.TTL("keyspace_key2"); ttl != 100 {
+			t.Errorf("Rename() expected the expiry time to be kept, got TTL %d", ttl)
+		}
+		if ok, err := server.RenameNX("keyspace_key2", "keyspace_set1"); err != nil || ok {
+			t.Errorf("RenameNX() got = %v, %v, want false when the destination exists", ok, err)
+		}
+		if _, err := server.Rename("keyspace_none", "keyspace_key3"); err == nil {
+			t.Error("Rename() expected an error when the key does not exist")
+		}
+	})
+
+	t.Run("RandomKey and Unlink", func(t *testing.T) {
+		key, err := server.RandomKey()
+		if err != nil || !strings.HasPrefix(key, "keyspace_") {
+			t.Errorf("RandomKey() got = %s, %v", key, err)
+		}
+		if got, err := server.Unlink("keyspace_key2", "keyspace_set1", "keyspace_set2"); err != nil || got != 3 {
+			t.Errorf("Unlink() got = %d, %v, want 3", got, err)
+		}
+		if key, err = server.RandomKey(); err != nil || key != "" {
+			t.Errorf("RandomKey() got = %s, %v, want an empty string", key, err)
+		}
+	})
+}
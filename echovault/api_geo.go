@@ -0,0 +1,350 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"bytes"
+	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
+	"strconv"
+)
+
+// GeoLocation is a member of a geo index and its coordinates.
+type GeoLocation struct {
+	Member    string
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoPosition is the longitude and latitude of a member of a geo index.
+type GeoPosition struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoAddOptions allows you to modify the effects of the GeoAdd command.
+//
+// NX only adds new members. NX takes higher priority than XX.
+//
+// XX only updates the coordinates of members that already exist.
+//
+// CH modifies the result to return the number of members changed + added, instead of only new members added.
+type GeoAddOptions struct {
+	NX bool
+	XX bool
+	CH bool
+}
+
+// GeoSearchOptions describes the area searched by the GeoSearch and GeoSearchStore commands.
+//
+// FromMember is the member at the centre of the search. When it's empty, the centre is Longitude and Latitude.
+//
+// ByBox searches within a box of Width and Height centred on the centre. Otherwise, the search is within the Radius.
+//
+// Unit is the unit of Radius, Width, Height and the returned distances. It's one of "m", "km", "ft" or "mi".
+// The default is "m".
+//
+// Sort orders the results by their distance from the centre. It's either "ASC" or "DESC".
+//
+// Count limits the number of results. Unless Any is true, the closest matches are returned.
+//
+// Any returns as soon as Count matches are found.
+type GeoSearchOptions struct {
+	FromMember string
+	Longitude  float64
+	Latitude   float64
+	ByBox      bool
+	Radius     float64
+	Width      float64
+	Height     float64
+	Unit       string
+	Sort       string
+	Count      uint
+	Any        bool
+}
+
+// GeoSearchStoreOptions allows you to modify the effects of the GeoSearchStore command.
+//
+// StoreDist stores the distances from the centre in the given unit as the scores of the members.
+// Otherwise, the geohashes are stored so that the result is a geo index.
+type GeoSearchStoreOptions struct {
+	GeoSearchOptions
+	StoreDist bool
+}
+
+// GeoSearchResult is a member found by the GeoSearch command.
+//
+// Distance is the distance from the centre of the search in the unit of the search.
+//
+// Hash is the geohash score of the member in the sorted set.
+type GeoSearchResult struct {
+	Member    string
+	Distance  float64
+	Hash      int64
+	Longitude float64
+	Latitude  float64
+}
+
+func buildGeoSearchArgs(options GeoSearchOptions) []string {
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	var cmd []string
+	if options.FromMember != "" {
+		cmd = append(cmd, "FROMMEMBER", options.FromMember)
+	} else {
+		cmd = append(cmd, "FROMLONLAT", formatFloat(options.Longitude), formatFloat(options.Latitude))
+	}
+
+	unit := options.Unit
+	if unit == "" {
+		unit = "m"
+	}
+	if options.ByBox {
+		cmd = append(cmd, "BYBOX", formatFloat(options.Width), formatFloat(options.Height), unit)
+	} else {
+		cmd = append(cmd, "BYRADIUS", formatFloat(options.Radius), unit)
+	}
+
+	if options.Sort != "" {
+		cmd = append(cmd, options.Sort)
+	}
+	if options.Count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(options.Count), 10))
+		if options.Any {
+			cmd = append(cmd, "ANY")
+		}
+	}
+	return cmd
+}
+
+// GeoAdd adds the locations to the geo index at the key. The geo index is a sorted set where the score of each member
+// is the geohash of its coordinates. If the key does not exist, a new sorted set is created.
+//
+// Parameters:
+//
+// `key` - string - the key to the geo index.
+//
+// `locations` - []GeoLocation - the members to add and their coordinates.
+//
+// `options` - GeoAddOptions.
+//
+// Returns: The number of members added, or the number of members added and updated if the "CH" flag is true.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the provided key exists but is not a sorted set.
+//
+// "invalid longitude,latitude pair <longitude>,<latitude>" - when the coordinates are out of range.
+func (server *EchoVault) GeoAdd(key string, locations []GeoLocation, options GeoAddOptions) (int, error) {
+	cmd := []string{"GEOADD", key}
+	switch {
+	case options.NX:
+		cmd = append(cmd, "NX")
+	case options.XX:
+		cmd = append(cmd, "XX")
+	}
+	if options.CH {
+		cmd = append(cmd, "CH")
+	}
+	for _, location := range locations {
+		cmd = append(cmd,
+			strconv.FormatFloat(location.Longitude, 'f', -1, 64),
+			strconv.FormatFloat(location.Latitude, 'f', -1, 64),
+			location.Member,
+		)
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// GeoPos returns the coordinates of the members of the geo index.
+//
+// Parameters:
+//
+// `key` - string - the key to the geo index.
+//
+// `members` - ...string - the members whose coordinates to return.
+//
+// Returns: A slice of positions in the order of the members. The position is nil for members that do not exist.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the provided key exists but is not a sorted set.
+func (server *EchoVault) GeoPos(key string, members ...string) ([]*GeoPosition, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"GEOPOS", key}, members...)), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]*GeoPosition, len(v.Array()))
+	for i, position := range v.Array() {
+		if position.IsNull() {
+			continue
+		}
+		positions[i] = &GeoPosition{
+			Longitude: position.Array()[0].Float(),
+			Latitude:  position.Array()[1].Float(),
+		}
+	}
+	return positions, nil
+}
+
+// GeoDist returns the distance between two members of the geo index.
+//
+// Parameters:
+//
+// `key` - string - the key to the geo index.
+//
+// `member1`, `member2` - string - the members to measure the distance between.
+//
+// `unit` - string - the unit of the result. It's one of "m", "km", "ft" or "mi". The default is "m".
+//
+// Returns: The distance as a float64, or nil if either of the members does not exist.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the provided key exists but is not a sorted set.
+//
+// "unsupported unit provided. please use M, KM, FT, MI" - when the unit is not supported.
+func (server *EchoVault) GeoDist(key, member1, member2, unit string) (interface{}, error) {
+	cmd := []string{"GEODIST", key, member1, member2}
+	if unit != "" {
+		cmd = append(cmd, unit)
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	isNil, err := internal.ParseNilResponse(b)
+	if err != nil {
+		return nil, err
+	}
+	if isNil {
+		return nil, nil
+	}
+	return internal.ParseFloatResponse(b)
+}
+
+// GeoHash returns the standard 11 character geohash of the members of the geo index.
+//
+// Parameters:
+//
+// `key` - string - the key to the geo index.
+//
+// `members` - ...string - the members whose geohashes to return.
+//
+// Returns: A string slice of geohashes in the order of the members. The geohash is an empty string for members
+// that do not exist.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the provided key exists but is not a sorted set.
+func (server *EchoVault) GeoHash(key string, members ...string) ([]string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"GEOHASH", key}, members...)), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseStringArrayResponse(b)
+}
+
+// GeoSearch returns the members of the geo index within the area described by the options.
+//
+// Parameters:
+//
+// `key` - string - the key to the geo index.
+//
+// `options` - GeoSearchOptions.
+//
+// Returns: A slice of results with the distance, hash and coordinates of each member found.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the provided key exists but is not a sorted set.
+//
+// "could not decode requested zset member" - when FromMember is not a member of the geo index.
+func (server *EchoVault) GeoSearch(key string, options GeoSearchOptions) ([]GeoSearchResult, error) {
+	cmd := append([]string{"GEOSEARCH", key}, buildGeoSearchArgs(options)...)
+	cmd = append(cmd, "WITHDIST", "WITHHASH", "WITHCOORD")
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]GeoSearchResult, len(v.Array()))
+	for i, entry := range v.Array() {
+		arr := entry.Array()
+		coordinates := arr[3].Array()
+		results[i] = GeoSearchResult{
+			Member:    arr[0].String(),
+			Distance:  arr[1].Float(),
+			Hash:      int64(arr[2].Integer()),
+			Longitude: coordinates[0].Float(),
+			Latitude:  coordinates[1].Float(),
+		}
+	}
+	return results, nil
+}
+
+// GeoSearchStore works like GeoSearch but stores the members found as a sorted set at the destination.
+// If no members are found, the destination is deleted.
+//
+// Parameters:
+//
+// `destination` - string - the key to store the result.
+//
+// `source` - string - the key to the geo index.
+//
+// `options` - GeoSearchStoreOptions.
+//
+// Returns: The number of members stored at the destination.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the source exists but is not a sorted set.
+//
+// "could not decode requested zset member" - when FromMember is not a member of the geo index.
+func (server *EchoVault) GeoSearchStore(destination, source string, options GeoSearchStoreOptions) (int, error) {
+	cmd := append([]string{"GEOSEARCHSTORE", destination, source}, buildGeoSearchArgs(options.GeoSearchOptions)...)
+	if options.StoreDist {
+		cmd = append(cmd, "STOREDIST")
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
@@ -0,0 +1,294 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var geoTestLocations = []GeoLocation{
+	{Member: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+	{Member: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+}
+
+func TestEchoVault_GEOADD(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name        string
+		presetValue interface{}
+		key         string
+		locations   []GeoLocation
+		options     GeoAddOptions
+		want        int
+		wantErr     bool
+	}{
+		{
+			name:      "1. Create a new geo index",
+			key:       "GeoAddKey1",
+			locations: geoTestLocations,
+			want:      2,
+			wantErr:   false,
+		},
+		{
+			name:      "2. XX does not add new members",
+			key:       "GeoAddKey2",
+			locations: geoTestLocations,
+			options:   GeoAddOptions{XX: true},
+			want:      0,
+			wantErr:   false,
+		},
+		{
+			name:      "3. Return an error when the coordinates are out of range",
+			key:       "GeoAddKey3",
+			locations: []GeoLocation{{Member: "Invalid", Longitude: 181, Latitude: 0}},
+			want:      0,
+			wantErr:   true,
+		},
+		{
+			name:        "4. Return an error when the key is not a sorted set",
+			presetValue: "Default value",
+			key:         "GeoAddKey4",
+			locations:   geoTestLocations,
+			want:        0,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.presetValue != nil {
+				if err := presetValue(server, context.Background(), tt.key, tt.presetValue); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			got, err := server.GeoAdd(tt.key, tt.locations, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GEOADD() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GEOADD() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_GEOPOS(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.GeoAdd("GeoPosKey1", geoTestLocations, GeoAddOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := server.GeoPos("GeoPosKey1", "Palermo", "NonExistent")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(got) != 2 || got[0] == nil || got[1] != nil {
+		t.Errorf("GEOPOS() got = %v, want a position for Palermo only", got)
+		return
+	}
+	if math.Abs(got[0].Longitude-13.361389) > 0.00001 || math.Abs(got[0].Latitude-38.115556) > 0.00001 {
+		t.Errorf("GEOPOS() got = %v, want close to {13.361389 38.115556}", *got[0])
+	}
+}
+
+func TestEchoVault_GEODIST(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.GeoAdd("GeoDistKey1", geoTestLocations, GeoAddOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name    string
+		member1 string
+		member2 string
+		unit    string
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:    "1. Return the distance in meters",
+			member1: "Palermo",
+			member2: "Catania",
+			want:    166274.1516,
+			wantErr: false,
+		},
+		{
+			name:    "2. Return the distance in kilometers",
+			member1: "Palermo",
+			member2: "Catania",
+			unit:    "km",
+			want:    166.2742,
+			wantErr: false,
+		},
+		{
+			name:    "3. Return nil when a member does not exist",
+			member1: "Palermo",
+			member2: "NonExistent",
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "4. Return an error when the unit is not supported",
+			member1: "Palermo",
+			member2: "Catania",
+			unit:    "parsec",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.GeoDist("GeoDistKey1", tt.member1, tt.member2, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GEODIST() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("GEODIST() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_GEOHASH(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.GeoAdd("GeoHashKey1", geoTestLocations, GeoAddOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := server.GeoHash("GeoHashKey1", "Palermo", "Catania", "NonExistent")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []string{"sqc8b49rny0", "sqdtr74hyu0", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GEOHASH() got = %v, want %v", got, want)
+	}
+}
+
+func TestEchoVault_GEOSEARCH(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.GeoAdd("GeoSearchKey1", geoTestLocations, GeoAddOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name        string
+		options     GeoSearchOptions
+		wantMembers []string
+		wantErr     bool
+	}{
+		{
+			name:        "1. Search by radius from coordinates",
+			options:     GeoSearchOptions{Longitude: 15, Latitude: 37, Radius: 200, Unit: "km", Sort: "ASC"},
+			wantMembers: []string{"Catania", "Palermo"},
+			wantErr:     false,
+		},
+		{
+			name:        "2. Search by radius in descending order with count",
+			options:     GeoSearchOptions{Longitude: 15, Latitude: 37, Radius: 200, Unit: "km", Sort: "DESC", Count: 1},
+			wantMembers: []string{"Palermo"},
+			wantErr:     false,
+		},
+		{
+			name:        "3. Search by box from a member",
+			options:     GeoSearchOptions{FromMember: "Catania", ByBox: true, Width: 100, Height: 100, Unit: "km"},
+			wantMembers: []string{"Catania"},
+			wantErr:     false,
+		},
+		{
+			name:        "4. Return an error when the member does not exist",
+			options:     GeoSearchOptions{FromMember: "NonExistent", Radius: 100},
+			wantMembers: nil,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.GeoSearch("GeoSearchKey1", tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GEOSEARCH() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var members []string
+			for _, result := range got {
+				members = append(members, result.Member)
+			}
+			if !reflect.DeepEqual(members, tt.wantMembers) {
+				t.Errorf("GEOSEARCH() got = %v, want %v", members, tt.wantMembers)
+			}
+		})
+	}
+
+	t.Run("5. Return the distance, hash and coordinates", func(t *testing.T) {
+		got, err := server.GeoSearch("GeoSearchKey1", GeoSearchOptions{Longitude: 15, Latitude: 37, Radius: 100, Unit: "km"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(got) != 1 {
+			t.Errorf("GEOSEARCH() got = %v, want 1 result", got)
+			return
+		}
+		if got[0].Distance != 56.4413 || got[0].Hash != 3479447370796909 || math.Abs(got[0].Longitude-15.087269) > 0.00001 {
+			t.Errorf("GEOSEARCH() got = %v, want Catania at 56.4413 km", got[0])
+		}
+	})
+}
+
+func TestEchoVault_GEOSEARCHSTORE(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.GeoAdd("GeoSearchStoreKey1", geoTestLocations, GeoAddOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := server.GeoSearchStore("GeoSearchStoreDestination1", "GeoSearchStoreKey1", GeoSearchStoreOptions{
+		GeoSearchOptions: GeoSearchOptions{Longitude: 15, Latitude: 37, Radius: 200, Unit: "km"},
+		StoreDist:        true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got != 2 {
+		t.Errorf("GEOSEARCHSTORE() got = %v, want 2", got)
+	}
+
+	score, err := server.ZScore("GeoSearchStoreDestination1", "Catania")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if s, ok := score.(float64); !ok || math.Abs(s-56.4413) > 0.0001 {
+		t.Errorf("GEOSEARCHSTORE() stored score = %v, want close to 56.4413", score)
+	}
+}
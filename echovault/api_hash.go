@@ -326,3 +326,38 @@ func (server *EchoVault) HDel(key string, fields ...string) (int, error) {
 	}
 	return internal.ParseIntegerResponse(b)
 }
+
+// HScan returns an iterator over the field-value pairs of the hash. The fields are fetched in batches
+// as the iteration progresses. A field that exists for the whole iteration is returned exactly once.
+//
+// Parameters:
+//
+// `key` - string - the key to the hash.
+//
+// `options` - ScanOptions. The Type option is ignored.
+//
+// Returns: A ScanIterator over the fields and their values. The iteration is empty if the key does not exist.
+//
+// Errors:
+//
+// "value at <key> is not a hash" - when the provided key exists but is not a hash.
+func (server *EchoVault) HScan(key string, options ScanOptions) (*ScanIterator[func(yield func(field, value string) bool)], error) {
+	it := &ScanIterator[func(yield func(field, value string) bool)]{}
+	batches, err := server.scanBatches(func(cursor uint64) []string {
+		return append([]string{"HSCAN", key, strconv.FormatUint(cursor, 10)}, buildScanArgs(options)...)
+	}, &it.err)
+	if err != nil {
+		return nil, err
+	}
+	it.all = func(yield func(field, value string) bool) {
+		batches(func(batch []string) bool {
+			for i := 0; i+1 < len(batch); i += 2 {
+				if !yield(batch[i], batch[i+1]) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return it, nil
+}
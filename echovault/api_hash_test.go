@@ -18,6 +18,8 @@ import (
 	"context"
 	"reflect"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -826,3 +828,56 @@ func TestEchoVault_HGet(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_HSCAN(t *testing.T) {
+	server := createEchoVault()
+
+	expected := map[string]string{}
+	for i := 0; i < 25; i++ {
+		expected["field"+strconv.Itoa(i)] = "value" + strconv.Itoa(i)
+	}
+	if _, err := server.HSet("hscan_key1", expected); err != nil {
+		t.Error(err)
+		return
+	}
+
+	fields, err := server.HScan("hscan_key1", ScanOptions{Count: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	got := map[string]string{}
+	fields.All()(func(field, value string) bool {
+		got[field] = value
+		return true
+	})
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("HScan() got = %v, want %v", got, expected)
+	}
+	if err = fields.Err(); err != nil {
+		t.Errorf("HScan() unexpected iteration error: %v", err)
+	}
+
+	// The error of a batch fetched during the iteration is returned by Err.
+	count := 0
+	fields.All()(func(field, value string) bool {
+		count += 1
+		if count == 1 {
+			if err := presetValue(server, context.Background(), "hscan_key1", "value"); err != nil {
+				t.Error(err)
+			}
+		}
+		return true
+	})
+	if err = fields.Err(); err == nil || !strings.Contains(err.Error(), "not a hash") {
+		t.Errorf("HScan() expected the iteration to stop with a type error, got %v", err)
+	}
+
+	if err = presetValue(server, context.Background(), "hscan_key2", "value"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = server.HScan("hscan_key2", ScanOptions{}); err == nil {
+		t.Error("HScan() expected an error for a key that is not a hash")
+	}
+}
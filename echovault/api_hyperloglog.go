@@ -0,0 +1,87 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"github.com/echovault/echovault/internal"
+	"strings"
+)
+
+// PFAdd adds the elements to the HyperLogLog at the key. If the key does not exist, an empty HyperLogLog is created.
+//
+// Parameters:
+//
+// `key` - string - the key to the HyperLogLog.
+//
+// `elements` - ...string - the elements to add.
+//
+// Returns: true if the HyperLogLog was created or at least one of its registers was altered, otherwise false.
+//
+// Errors:
+//
+// "value at key <key> is not a hyperloglog" - when the provided key exists but is not a HyperLogLog.
+func (server *EchoVault) PFAdd(key string, elements ...string) (bool, error) {
+	cmd := append([]string{"PFADD", key}, elements...)
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	return internal.ParseBooleanResponse(b)
+}
+
+// PFCount returns the estimated cardinality of the HyperLogLog at the key. When more than one key is provided,
+// the estimated cardinality of the union of the HyperLogLogs is returned.
+//
+// Parameters:
+//
+// `keys` - ...string - the keys to the HyperLogLogs. Keys that do not exist are treated as empty HyperLogLogs.
+//
+// Returns: The estimated cardinality as an integer.
+//
+// Errors:
+//
+// "value at key <key> is not a hyperloglog" - when one of the provided keys exists but is not a HyperLogLog.
+func (server *EchoVault) PFCount(keys ...string) (int, error) {
+	cmd := append([]string{"PFCOUNT"}, keys...)
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// PFMerge merges the source HyperLogLogs into the destination HyperLogLog. If the destination exists,
+// its registers are included in the merge. Otherwise, it's created.
+//
+// Parameters:
+//
+// `destination` - string - the key to store the merged HyperLogLog.
+//
+// `sources` - ...string - the keys to the HyperLogLogs to merge. Keys that do not exist are skipped.
+//
+// Returns: true if the merge is successful.
+//
+// Errors:
+//
+// "value at key <key> is not a hyperloglog" - when the destination or one of the sources exists but is not a HyperLogLog.
+func (server *EchoVault) PFMerge(destination string, sources ...string) (bool, error) {
+	cmd := append([]string{"PFMERGE", destination}, sources...)
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	s, err := internal.ParseStringResponse(b)
+	return strings.EqualFold(s, "ok"), err
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestEchoVault_PFADD(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name        string
+		presetValue interface{}
+		key         string
+		elements    []string
+		want        bool
+		wantCount   int
+		wantErr     bool
+	}{
+		{
+			name:      "1. Create a new HyperLogLog",
+			key:       "PFAddKey1",
+			elements:  []string{"a", "b", "c"},
+			want:      true,
+			wantCount: 3,
+			wantErr:   false,
+		},
+		{
+			name:        "2. Return an error when the key is not a HyperLogLog",
+			presetValue: "Default value",
+			key:         "PFAddKey2",
+			elements:    []string{"a"},
+			want:        false,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.presetValue != nil {
+				if err := presetValue(server, context.Background(), tt.key, tt.presetValue); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			got, err := server.PFAdd(tt.key, tt.elements...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PFADD() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("PFADD() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			count, err := server.PFCount(tt.key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if count != tt.wantCount {
+				t.Errorf("PFADD() count = %v, want %v", count, tt.wantCount)
+			}
+		})
+	}
+
+	t.Run("3. Return false when no register is altered", func(t *testing.T) {
+		got, err := server.PFAdd("PFAddKey1", "a", "b")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if got {
+			t.Errorf("PFADD() got = %v, want false", got)
+		}
+	})
+}
+
+func TestEchoVault_PFCOUNT(t *testing.T) {
+	server := createEchoVault()
+
+	for i := 0; i < 20000; i++ {
+		if _, err := server.PFAdd("PFCountKey1", fmt.Sprintf("element-%d", i)); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := server.PFAdd("PFCountKey2", fmt.Sprintf("element-%d", i+10000)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "1. Estimate the cardinality of a single HyperLogLog",
+			keys:    []string{"PFCountKey1"},
+			want:    20000,
+			wantErr: false,
+		},
+		{
+			name:    "2. Estimate the cardinality of the union of HyperLogLogs",
+			keys:    []string{"PFCountKey1", "PFCountKey2", "PFCountKey3"},
+			want:    30000,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.PFCount(tt.keys...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PFCOUNT() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if math.Abs(float64(got-tt.want))/float64(tt.want) > 0.0243 {
+				t.Errorf("PFCOUNT() got = %v, want close to %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_PFMERGE(t *testing.T) {
+	server := createEchoVault()
+
+	if _, err := server.PFAdd("PFMergeKey1", "a", "b", "c"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := server.PFAdd("PFMergeKey2", "c", "d"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	ok, err := server.PFMerge("PFMergeDestination", "PFMergeKey1", "PFMergeKey2")
+	if err != nil || !ok {
+		t.Errorf("PFMERGE() got = %v, error = %v", ok, err)
+		return
+	}
+	count, err := server.PFCount("PFMergeDestination")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if count != 4 {
+		t.Errorf("PFMERGE() count = %v, want 4", count)
+	}
+
+	if err = presetValue(server, context.Background(), "PFMergeKey3", "Default value"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = server.PFMerge("PFMergeDestination", "PFMergeKey3"); err == nil {
+		t.Error("expected PFMERGE with a source that is not a HyperLogLog to return an error")
+	}
+}
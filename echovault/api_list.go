@@ -15,9 +15,13 @@
 package echovault
 
 import (
+	"bytes"
+	"context"
 	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // LLen returns the length of the list.
@@ -315,3 +319,191 @@ func (server *EchoVault) RPushX(key string, values ...string) (int, error) {
 	}
 	return internal.ParseIntegerResponse(b)
 }
+
+// LMPop pops up to count elements from the first non-empty list of the provided keys.
+//
+// Parameters:
+//
+// `keys` - []string - the keys to the lists, checked in the given order.
+//
+// `whereFrom` - string - either "LEFT" or "RIGHT". The end of the list the elements are popped from.
+//
+// `count` - uint - the maximum number of elements to pop. Defaults to 1 when 0 is passed.
+//
+// Returns: The key of the list the elements were popped from and the popped elements, in the order they were popped.
+// Returns an empty key and a nil slice if all the lists are empty.
+//
+// Errors:
+//
+// "value at key <key> is not a list" - when one of the provided keys exists but is not a list.
+//
+// "wherefrom and whereto arguments must be either LEFT or RIGHT" - if whereFrom is not either "LEFT" or "RIGHT".
+func (server *EchoVault) LMPop(keys []string, whereFrom string, count uint) (string, []string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"LMPOP"}, buildMPopArgs(keys, whereFrom, count)...)), nil, false, true)
+	if err != nil {
+		return "", nil, err
+	}
+	return parseMPopResponse(b)
+}
+
+// BLPop is the blocking version of LPop. It pops an element from the start of the first non-empty list of the
+// provided keys. If all the lists are empty, it waits until an element is pushed to one of them.
+// Callers blocked on the same key are served in the order they started waiting.
+//
+// Parameters:
+//
+// `ctx` - context.Context - cancelling the context stops the wait.
+//
+// `timeout` - time.Duration - the maximum time to wait for. A timeout of 0 waits until the context is cancelled.
+//
+// `keys` - ...string - the keys to the lists, checked in the given order.
+//
+// Returns: The key of the list the element was popped from and the popped element.
+// Returns empty strings if the timeout is reached.
+//
+// Errors:
+//
+// "value at key <key> is not a list" - when one of the provided keys exists but is not a list.
+//
+// The context's error if it's cancelled before an element is popped.
+func (server *EchoVault) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return server.bpop(ctx, "BLPOP", timeout, keys)
+}
+
+// BRPop is the blocking version of RPop. It behaves like BLPop but pops the element from the end of the list.
+func (server *EchoVault) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return server.bpop(ctx, "BRPOP", timeout, keys)
+}
+
+func (server *EchoVault) bpop(ctx context.Context, command string, timeout time.Duration, keys []string) (string, string, error) {
+	cmd := append(append([]string{command}, keys...), formatBlockTimeout(timeout))
+	b, err := server.handleBlockingCommand(ctx, cmd)
+	if err != nil {
+		return "", "", err
+	}
+	res, err := internal.ParseStringArrayResponse(b)
+	if err != nil || len(res) != 2 {
+		return "", "", err
+	}
+	return res[0], res[1], nil
+}
+
+// BLMove is the blocking version of LMove. If the source list is empty, it waits until an element is pushed to it.
+//
+// Parameters:
+//
+// `ctx` - context.Context - cancelling the context stops the wait.
+//
+// `source` - string - the key to the list from which the element is removed.
+//
+// `destination` - string - the key to the list to which the element is added. The list is created if it doesn't exist.
+//
+// `whereFrom` - string - either "LEFT" or "RIGHT". The end of the source list the element is removed from.
+//
+// `whereTo` - string - either "LEFT" or "RIGHT". The end of the destination list the element is added to.
+//
+// `timeout` - time.Duration - the maximum time to wait for. A timeout of 0 waits until the context is cancelled.
+//
+// Returns: The element that was moved, and true if an element was moved before the timeout was reached.
+//
+// Errors:
+//
+// "value at key <key> is not a list" - when either source or destination exist but are not lists.
+//
+// "wherefrom and whereto arguments must be either LEFT or RIGHT" - if whereFrom or whereTo are not either "LEFT" or "RIGHT".
+//
+// The context's error if it's cancelled before an element is moved.
+func (server *EchoVault) BLMove(ctx context.Context, source, destination, whereFrom, whereTo string, timeout time.Duration) (string, bool, error) {
+	b, err := server.handleBlockingCommand(ctx, []string{"BLMOVE", source, destination, whereFrom, whereTo, formatBlockTimeout(timeout)})
+	if err != nil {
+		return "", false, err
+	}
+	isNil, err := internal.ParseNilResponse(b)
+	if err != nil || isNil {
+		return "", false, err
+	}
+	element, err := internal.ParseStringResponse(b)
+	return element, err == nil, err
+}
+
+// BLMPop is the blocking version of LMPop. If all the lists are empty, it waits until an element is pushed to one of them.
+//
+// Parameters:
+//
+// `ctx` - context.Context - cancelling the context stops the wait.
+//
+// `timeout` - time.Duration - the maximum time to wait for. A timeout of 0 waits until the context is cancelled.
+//
+// `keys` - []string - the keys to the lists, checked in the given order.
+//
+// `whereFrom` - string - either "LEFT" or "RIGHT". The end of the list the elements are popped from.
+//
+// `count` - uint - the maximum number of elements to pop. Defaults to 1 when 0 is passed.
+//
+// Returns: The key of the list the elements were popped from and the popped elements, in the order they were popped.
+// Returns an empty key and a nil slice if the timeout is reached.
+//
+// Errors:
+//
+// "value at key <key> is not a list" - when one of the provided keys exists but is not a list.
+//
+// "wherefrom and whereto arguments must be either LEFT or RIGHT" - if whereFrom is not either "LEFT" or "RIGHT".
+//
+// The context's error if it's cancelled before any elements are popped.
+func (server *EchoVault) BLMPop(ctx context.Context, timeout time.Duration, keys []string, whereFrom string, count uint) (string, []string, error) {
+	cmd := append([]string{"BLMPOP", formatBlockTimeout(timeout)}, buildMPopArgs(keys, whereFrom, count)...)
+	b, err := server.handleBlockingCommand(ctx, cmd)
+	if err != nil {
+		return "", nil, err
+	}
+	return parseMPopResponse(b)
+}
+
+// handleBlockingCommand runs the blocking command until it returns, or until the context is cancelled.
+func (server *EchoVault) handleBlockingCommand(ctx context.Context, cmd []string) ([]byte, error) {
+	// The command runs with the server's context so that it has access to the server's values.
+	// It's cancelled along with the caller's context.
+	blockingCtx, cancel := context.WithCancel(server.context)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	b, err := server.handleCommand(blockingCtx, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		// The command was unblocked by the cancellation of the context.
+		if isNil, _ := internal.ParseNilResponse(b); isNil {
+			return nil, ctx.Err()
+		}
+	}
+	return b, nil
+}
+
+func formatBlockTimeout(timeout time.Duration) string {
+	return strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)
+}
+
+// buildMPopArgs returns the numkeys, keys, direction and count arguments shared by LMPOP and BLMPOP.
+func buildMPopArgs(keys []string, whereFrom string, count uint) []string {
+	cmd := append([]string{strconv.Itoa(len(keys))}, keys...)
+	cmd = append(cmd, whereFrom)
+	if count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(count), 10))
+	}
+	return cmd
+}
+
+func parseMPopResponse(b []byte) (string, []string, error) {
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil || v.IsNull() || len(v.Array()) != 2 {
+		return "", nil, err
+	}
+	elements := make([]string, len(v.Array()[1].Array()))
+	for i, element := range v.Array()[1].Array() {
+		elements[i] = element.String()
+	}
+	return v.Array()[0].String(), elements, nil
+}
@@ -16,8 +16,10 @@ package echovault
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestEchoVault_LLEN(t *testing.T) {
@@ -931,3 +933,184 @@ func TestEchoVault_LTRIM(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_LMPOP(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name         string
+		preset       map[string]interface{}
+		keys         []string
+		whereFrom    string
+		count        uint
+		wantKey      string
+		wantElements []string
+		wantErr      bool
+	}{
+		{
+			name:         "Pop from the left of the first non-empty list",
+			preset:       map[string]interface{}{"lmpop_key2": []interface{}{"value1", "value2", "value3"}},
+			keys:         []string{"lmpop_key1", "lmpop_key2"},
+			whereFrom:    "LEFT",
+			count:        2,
+			wantKey:      "lmpop_key2",
+			wantElements: []string{"value1", "value2"},
+		},
+		{
+			name:         "Pop from the right of the list",
+			preset:       map[string]interface{}{"lmpop_key3": []interface{}{"value1", "value2", "value3"}},
+			keys:         []string{"lmpop_key3"},
+			whereFrom:    "RIGHT",
+			wantKey:      "lmpop_key3",
+			wantElements: []string{"value3"},
+		},
+		{
+			name:      "Return empty key when all the lists are empty",
+			keys:      []string{"lmpop_key4", "lmpop_key5"},
+			whereFrom: "LEFT",
+		},
+		{
+			name:      "Return error when the key is not a list",
+			preset:    map[string]interface{}{"lmpop_key6": "Default value"},
+			keys:      []string{"lmpop_key6"},
+			whereFrom: "LEFT",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.preset {
+				if err := presetValue(server, context.Background(), k, v); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			key, elements, err := server.LMPop(tt.keys, tt.whereFrom, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LMPOP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if key != tt.wantKey || !reflect.DeepEqual(elements, tt.wantElements) {
+				t.Errorf("LMPOP() got = %v %v, want %v %v", key, elements, tt.wantKey, tt.wantElements)
+			}
+		})
+	}
+}
+
+func TestEchoVault_BLPOP(t *testing.T) {
+	server := createEchoVault()
+
+	t.Run("Pop without blocking when the list has elements", func(t *testing.T) {
+		if err := presetValue(server, context.Background(), "blpop_key1", []interface{}{"value1", "value2"}); err != nil {
+			t.Error(err)
+			return
+		}
+		key, element, err := server.BRPop(context.Background(), time.Second, "blpop_key0", "blpop_key1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if key != "blpop_key1" || element != "value2" {
+			t.Errorf("BRPOP() got = %s %s, want blpop_key1 value2", key, element)
+		}
+	})
+
+	t.Run("Return empty strings when the timeout is reached", func(t *testing.T) {
+		key, element, err := server.BLPop(context.Background(), 50*time.Millisecond, "blpop_key2")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if key != "" || element != "" {
+			t.Errorf("BLPOP() got = %s %s, want empty strings", key, element)
+		}
+	})
+
+	t.Run("Wake up when an element is pushed to the list", func(t *testing.T) {
+		done := make(chan []string, 1)
+		go func() {
+			key, element, err := server.BLPop(context.Background(), 0, "blpop_key3")
+			if err != nil {
+				t.Error(err)
+			}
+			done <- []string{key, element}
+		}()
+		time.Sleep(50 * time.Millisecond)
+		if _, err := server.RPush("blpop_key3", "value1"); err != nil {
+			t.Error(err)
+			return
+		}
+		select {
+		case got := <-done:
+			if !reflect.DeepEqual(got, []string{"blpop_key3", "value1"}) {
+				t.Errorf("BLPOP() got = %v, want [blpop_key3 value1]", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("BLPOP() was not woken up")
+		}
+	})
+
+	t.Run("Return the context error when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, _, err := server.BLPop(ctx, 0, "blpop_key4")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("BLPOP() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestEchoVault_BLMOVE(t *testing.T) {
+	server := createEchoVault()
+
+	if err := presetValue(server, context.Background(), "blmove_source1", []interface{}{"value1", "value2"}); err != nil {
+		t.Error(err)
+		return
+	}
+	element, moved, err := server.BLMove(context.Background(), "blmove_source1", "blmove_destination1", "LEFT", "RIGHT", time.Second)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !moved || element != "value1" {
+		t.Errorf("BLMOVE() got = %s %v, want value1 true", element, moved)
+	}
+	if got, _ := server.LRange("blmove_destination1", 0, -1); !reflect.DeepEqual(got, []string{"value1"}) {
+		t.Errorf("BLMOVE() destination = %v, want [value1]", got)
+	}
+
+	element, moved, err = server.BLMove(context.Background(), "blmove_source2", "blmove_destination2", "LEFT", "RIGHT", 50*time.Millisecond)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if moved || element != "" {
+		t.Errorf("BLMOVE() got = %s %v, want empty string and false", element, moved)
+	}
+}
+
+func TestEchoVault_BLMPOP(t *testing.T) {
+	server := createEchoVault()
+
+	done := make(chan []string, 1)
+	go func() {
+		key, elements, err := server.BLMPop(context.Background(), time.Second, []string{"blmpop_key1", "blmpop_key2"}, "RIGHT", 2)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- append([]string{key}, elements...)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := server.RPush("blmpop_key2", "value1", "value2", "value3"); err != nil {
+		t.Error(err)
+		return
+	}
+	select {
+	case got := <-done:
+		if !reflect.DeepEqual(got, []string{"blmpop_key2", "value3", "value2"}) {
+			t.Errorf("BLMPOP() got = %v, want [blmpop_key2 value3 value2]", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("BLMPOP() was not woken up")
+	}
+}
@@ -34,9 +34,11 @@ var connections sync.Map
 // ReadPubSubMessage is returned by the Subscribe and PSubscribe functions.
 //
 // This function is lazy, therefore it needs to be invoked in order to read the next message.
-// When the message is read, the function returns a string slice with 3 elements.
+// When a message published to a subscribed channel is read, the function returns a string slice with 3 elements.
 // Index 0 holds the event type which in this case will be "message". Index 1 holds the channel name.
 // Index 2 holds the actual message.
+// Messages received through a pattern subscription have 4 elements. Index 0 holds "pmessage", index 1 holds the
+// pattern, index 2 holds the name of the channel the message was published to, and index 3 holds the message.
 type ReadPubSubMessage func() []string
 
 func establishConnections(tag string) (*net.Conn, *net.Conn, error) {
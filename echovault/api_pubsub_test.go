@@ -110,15 +110,18 @@ func TestEchoVault_PSubscribe(t *testing.T) {
 	for i := 0; i < len(patterns)*2; i++ {
 		message := readMessage()
 		// Check that we've received the messages.
-		if message[0] != "message" {
-			t.Errorf("SUBSCRIBE() expected index 0 for message at %d to be \"message\", got %s", i, message[0])
+		if len(message) != 4 {
+			t.Errorf("PSUBSCRIBE() expected message %d to have 4 elements, got %v", i, message)
+			continue
+		}
+		if message[0] != "pmessage" {
+			t.Errorf("PSUBSCRIBE() expected index 0 for message at %d to be \"pmessage\", got %s", i, message[0])
 		}
 		if !slices.Contains(patterns, message[1]) {
-			t.Errorf("SUBSCRIBE() unexpected string \"%s\" at index 1 for message %d", message[1], i)
+			t.Errorf("PSUBSCRIBE() unexpected string \"%s\" at index 1 for message %d", message[1], i)
 		}
-		if !slices.Contains([]string{
-			"message for channel1", "message for channel2", "message for pattern1", "message for pattern2"}, message[2]) {
-			t.Errorf("SUBSCRIBE() unexpected string \"%s\" at index 1 for message %d", message[2], i)
+		if message[3] != fmt.Sprintf("message for %s", message[2]) {
+			t.Errorf("PSUBSCRIBE() unexpected message \"%s\" for channel \"%s\" at %d", message[3], message[2], i)
 		}
 	}
 
@@ -0,0 +1,145 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"bytes"
+	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
+	"strconv"
+)
+
+// Eval runs the Lua script atomically. The script is added to the script cache.
+//
+// Parameters:
+//
+// `script` - string - the Lua script. It can call commands with redis.call and redis.pcall.
+//
+// `keys` - []string - the keys that the script accesses. They're available to the script in the KEYS table.
+//
+// `args` - []string - the arguments of the script. They're available to the script in the ARGV table.
+//
+// Returns: The value returned by the script. It's either a string, an int, a []interface{} or nil.
+// Elements of a []interface{} can also be an error if the script returned an error reply in a table.
+//
+// Errors:
+//
+// "error compiling script: <error>" - when the script is not valid Lua.
+//
+// "error running script: <error>" - when the script raises a Lua error.
+//
+// Any error raised by redis.call or returned by the script with redis.error_reply.
+func (server *EchoVault) Eval(script string, keys []string, args []string) (interface{}, error) {
+	return server.evalCommand("EVAL", script, keys, args)
+}
+
+// EvalSHA runs the script with the given SHA1 digest from the script cache. It works the same as Eval otherwise.
+//
+// Parameters:
+//
+// `sha` - string - the SHA1 digest of the script, as returned by ScriptLoad.
+//
+// `keys` - []string - the keys that the script accesses. They're available to the script in the KEYS table.
+//
+// `args` - []string - the arguments of the script. They're available to the script in the ARGV table.
+//
+// Returns: The value returned by the script, as described in Eval.
+//
+// Errors:
+//
+// "NOSCRIPT No matching script. Please use EVAL." - when the script is not in the script cache.
+//
+// Any of the errors returned by Eval.
+func (server *EchoVault) EvalSHA(sha string, keys []string, args []string) (interface{}, error) {
+	return server.evalCommand("EVALSHA", sha, keys, args)
+}
+
+func (server *EchoVault) evalCommand(command string, script string, keys []string, args []string) (interface{}, error) {
+	cmd := append([]string{command, script, strconv.Itoa(len(keys))}, keys...)
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append(cmd, args...)), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := resp.NewReader(bytes.NewReader(b)).ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	return parseTransactionResult(v), nil
+}
+
+// ScriptLoad compiles the script and adds it to the script cache without running it.
+//
+// Parameters:
+//
+// `script` - string - the Lua script.
+//
+// Returns: The SHA1 digest of the script, which can be passed to EvalSHA.
+//
+// Errors:
+//
+// "error compiling script: <error>" - when the script is not valid Lua.
+func (server *EchoVault) ScriptLoad(script string) (string, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"SCRIPT", "LOAD", script}), nil, false, true)
+	if err != nil {
+		return "", err
+	}
+	return internal.ParseStringResponse(b)
+}
+
+// ScriptExists checks whether the scripts with the given SHA1 digests are in the script cache.
+//
+// Parameters:
+//
+// `shas` - ...string - the SHA1 digests of the scripts.
+//
+// Returns: A slice with true for each script that is in the script cache and false for each one that is not.
+func (server *EchoVault) ScriptExists(shas ...string) ([]bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"SCRIPT", "EXISTS"}, shas...)), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseBooleanArrayResponse(b)
+}
+
+// ScriptFlush removes all the scripts from the script cache.
+//
+// Returns: true when the script cache is flushed.
+func (server *EchoVault) ScriptFlush() (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"SCRIPT", "FLUSH"}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	s, err := internal.ParseStringResponse(b)
+	return s == "OK", err
+}
+
+// ScriptKill stops the script that's currently running. Scripts that have already written to the store
+// can't be stopped, and neither can scripts in cluster mode.
+//
+// Returns: true when the script is stopped.
+//
+// Errors:
+//
+// "NOTBUSY No scripts in execution right now." - when no script is running.
+//
+// "UNKILLABLE ..." - when the script can't be stopped.
+func (server *EchoVault) ScriptKill() (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"SCRIPT", "KILL"}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	s, err := internal.ParseStringResponse(b)
+	return s == "OK", err
+}
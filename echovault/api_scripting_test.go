@@ -0,0 +1,182 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/constants"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEchoVault_EVAL(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name    string
+		script  string
+		keys    []string
+		args    []string
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:    "1. Return a string",
+			script:  "return ARGV[1]",
+			args:    []string{"hello"},
+			want:    "hello",
+			wantErr: false,
+		},
+		{
+			name:    "2. Write and read a key",
+			script:  "redis.call('SET', KEYS[1], ARGV[1]) return redis.call('INCR', KEYS[1])",
+			keys:    []string{"EvalKey1"},
+			args:    []string{"41"},
+			want:    42,
+			wantErr: false,
+		},
+		{
+			name:    "3. Return an array",
+			script:  "return {1, 'two', false, redis.error_reply('nested')}",
+			want:    []interface{}{1, "two", nil, "nested"},
+			wantErr: false,
+		},
+		{
+			name:    "4. Return nil",
+			script:  "return nil",
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "5. Return an error reply as an error",
+			script:  "return redis.error_reply('my error')",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.Eval(tt.script, tt.keys, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EVAL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if arr, ok := got.([]interface{}); ok {
+				// Nested errors are returned as error values, so compare their messages.
+				for i, v := range arr {
+					if e, ok := v.(error); ok {
+						arr[i] = e.Error()
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EVAL() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_EVALSHA(t *testing.T) {
+	server := createEchoVault()
+
+	sha, err := server.ScriptLoad("return #KEYS + #ARGV")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := server.EvalSHA(sha, []string{"key1", "key2"}, []string{"arg1"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got != 3 {
+		t.Errorf("EVALSHA() got = %v, want %v", got, 3)
+	}
+
+	exists, err := server.ScriptExists(sha, "ffffffffffffffffffffffffffffffffffffffff")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(exists, []bool{true, false}) {
+		t.Errorf("SCRIPT EXISTS got = %v, want %v", exists, []bool{true, false})
+	}
+
+	ok, err := server.ScriptFlush()
+	if err != nil || !ok {
+		t.Errorf("SCRIPT FLUSH got = %v, error = %v", ok, err)
+		return
+	}
+	if _, err = server.EvalSHA(sha, nil, nil); err == nil {
+		t.Errorf("EVALSHA() expected NOSCRIPT error after SCRIPT FLUSH")
+	}
+}
+
+func TestEchoVault_SCRIPTKILL(t *testing.T) {
+	server := createEchoVaultWithConfig(config.Config{
+		DataDir:         "",
+		EvictionPolicy:  constants.NoEviction,
+		ScriptTimeLimit: 50 * time.Millisecond,
+	})
+
+	if _, err := server.ScriptKill(); err == nil || !strings.HasPrefix(err.Error(), "NOTBUSY") {
+		t.Errorf("SCRIPT KILL expected NOTBUSY error when no script is running, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := server.Eval("while true do end", nil, nil)
+		done <- err
+	}()
+
+	// The other commands are rejected once the script has run for longer than the time limit.
+	// Commands sent before then wait for the script to complete, so the test waits for the limit to pass.
+	for deadline := time.Now().Add(5 * time.Second); server.scripts.running.Load() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the script to start")
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	<-time.After(100 * time.Millisecond)
+	if _, err := server.Get("key1"); err == nil || !strings.HasPrefix(err.Error(), "BUSY") {
+		t.Fatalf("expected commands to be rejected with a BUSY error while the script is running, got %v", err)
+	}
+
+	if ok, err := server.ScriptKill(); err != nil || !ok {
+		t.Fatalf("SCRIPT KILL got = %v, error = %v", ok, err)
+	}
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "killed") {
+			t.Errorf("EVAL expected the script to be killed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the killed script to return")
+	}
+
+	// A script that has written to the store can't be killed.
+	run := &scriptRun{cancel: func() {}}
+	if !run.write() {
+		t.Fatal("expected a running script to be allowed to write")
+	}
+	if err := run.kill(); err == nil || !strings.HasPrefix(err.Error(), "UNKILLABLE") {
+		t.Errorf("expected UNKILLABLE error for a script that wrote to the store, got %v", err)
+	}
+}
@@ -346,3 +346,38 @@ func (server *EchoVault) SUnionStore(destination string, keys ...string) (int, e
 	}
 	return internal.ParseIntegerResponse(b)
 }
+
+// SScan returns an iterator over the members of the set. The members are fetched in batches
+// as the iteration progresses. A member that exists for the whole iteration is returned exactly once.
+//
+// Parameters:
+//
+// `key` - string - the key to the set.
+//
+// `options` - ScanOptions. The Type option is ignored.
+//
+// Returns: A ScanIterator over the members. The iteration is empty if the key does not exist.
+//
+// Errors:
+//
+// "value at key <key> is not a set" - when the provided key exists but is not a set.
+func (server *EchoVault) SScan(key string, options ScanOptions) (*ScanIterator[func(yield func(member string) bool)], error) {
+	it := &ScanIterator[func(yield func(member string) bool)]{}
+	batches, err := server.scanBatches(func(cursor uint64) []string {
+		return append([]string{"SSCAN", key, strconv.FormatUint(cursor, 10)}, buildScanArgs(options)...)
+	}, &it.err)
+	if err != nil {
+		return nil, err
+	}
+	it.all = func(yield func(member string) bool) {
+		batches(func(batch []string) bool {
+			for _, member := range batch {
+				if !yield(member) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return it, nil
+}
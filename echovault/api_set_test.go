@@ -19,6 +19,7 @@ import (
 	"github.com/echovault/echovault/internal/modules/set"
 	"reflect"
 	"slices"
+	"strconv"
 	"testing"
 )
 
@@ -1209,3 +1210,41 @@ func TestEchoVault_SUNIONSTORE(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_SSCAN(t *testing.T) {
+	server := createEchoVault()
+
+	var expected []string
+	for i := 0; i < 25; i++ {
+		expected = append(expected, "member"+strconv.Itoa(i))
+	}
+	if _, err := server.SAdd("sscan_key1", expected...); err != nil {
+		t.Error(err)
+		return
+	}
+
+	members, err := server.SScan("sscan_key1", ScanOptions{Match: "member1*", Count: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var got []string
+	members.All()(func(member string) bool {
+		got = append(got, member)
+		return true
+	})
+	slices.Sort(got)
+	want := []string{"member1", "member10", "member11", "member12", "member13", "member14",
+		"member15", "member16", "member17", "member18", "member19"}
+	if !slices.Equal(got, want) {
+		t.Errorf("SScan() got = %v, want %v", got, want)
+	}
+
+	if err = presetValue(server, context.Background(), "sscan_key2", "value"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = server.SScan("sscan_key2", ScanOptions{}); err == nil {
+		t.Error("SScan() expected an error for a key that is not a set")
+	}
+}
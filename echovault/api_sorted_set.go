@@ -1019,3 +1019,43 @@ func (server *EchoVault) ZRangeStore(destination, source, start, stop string, op
 
 	return internal.ParseIntegerResponse(b)
 }
+
+// ZScan returns an iterator over the members of the sorted set and their scores. The members are fetched
+// in batches as the iteration progresses. A member that exists for the whole iteration is returned exactly once.
+//
+// Parameters:
+//
+// `key` - string - the key to the sorted set.
+//
+// `options` - ScanOptions. The Type option is ignored.
+//
+// Returns: A ScanIterator over the members and their scores. The iteration is empty if the key does not exist.
+//
+// Errors:
+//
+// "value at <key> is not a sorted set" - when the provided key exists but is not a sorted set.
+func (server *EchoVault) ZScan(key string, options ScanOptions) (*ScanIterator[func(yield func(member string, score float64) bool)], error) {
+	it := &ScanIterator[func(yield func(member string, score float64) bool)]{}
+	batches, err := server.scanBatches(func(cursor uint64) []string {
+		return append([]string{"ZSCAN", key, strconv.FormatUint(cursor, 10)}, buildScanArgs(options)...)
+	}, &it.err)
+	if err != nil {
+		return nil, err
+	}
+	it.all = func(yield func(member string, score float64) bool) {
+		batches(func(batch []string) bool {
+			for i := 0; i+1 < len(batch); i += 2 {
+				score, err := strconv.ParseFloat(batch[i+1], 64)
+				if err != nil {
+					it.err = err
+					return false
+				}
+				if !yield(batch[i], score) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return it, nil
+}
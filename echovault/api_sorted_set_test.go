@@ -3588,3 +3588,38 @@ func TestEchoVault_ZRemRangeByRank(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_ZSCAN(t *testing.T) {
+	server := createEchoVault()
+
+	expected := map[string]float64{}
+	for i := 0; i < 25; i++ {
+		expected["member"+strconv.Itoa(i)] = float64(i) + 0.5
+	}
+	if _, err := server.ZAdd("zscan_key1", expected, ZAddOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	members, err := server.ZScan("zscan_key1", ScanOptions{Count: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	got := map[string]float64{}
+	members.All()(func(member string, score float64) bool {
+		got[member] = score
+		return true
+	})
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ZScan() got = %v, want %v", got, expected)
+	}
+
+	if err = presetValue(server, context.Background(), "zscan_key2", "value"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = server.ZScan("zscan_key2", ScanOptions{}); err == nil {
+		t.Error("ZScan() expected an error for a key that is not a sorted set")
+	}
+}
@@ -0,0 +1,768 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"bytes"
+	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
+	"strconv"
+	"time"
+)
+
+// StreamEntry is an entry of a stream.
+//
+// ID is the ID of the entry in the form "<ms>-<seq>".
+//
+// Fields holds the field-value pairs of the entry. Fields is nil if the entry has been deleted from the stream
+// but is still in the pending entries list of a consumer.
+type StreamEntry struct {
+	ID     string
+	Fields map[string]string
+}
+
+// XAddOptions allows you to modify the effects of the XAdd command.
+//
+// ID is the ID of the new entry. If it's empty, the ID is generated from the current time.
+// An ID in the form "<ms>-*" only generates the sequence number.
+//
+// NoMkStream does not create the stream if it does not exist.
+//
+// MaxLen trims the stream to at most MaxLen entries after the entry is added.
+//
+// MinID trims the entries with IDs lower than MinID after the entry is added. MaxLen takes priority over MinID.
+//
+// Approximate only removes whole nodes of the stream when trimming, which is more efficient but may leave a few
+// more entries than requested.
+//
+// Limit caps the number of entries removed by an approximate trim.
+type XAddOptions struct {
+	ID          string
+	NoMkStream  bool
+	MaxLen      uint
+	MinID       string
+	Approximate bool
+	Limit       uint
+}
+
+// XTrimOptions allows you to modify the effects of the XTrim command. The fields behave the same as the
+// trimming fields of XAddOptions. One of MaxLen or MinID must be provided. If neither is set, the stream is
+// trimmed to 0 entries.
+type XTrimOptions struct {
+	MaxLen      uint
+	MinID       string
+	Approximate bool
+	Limit       uint
+}
+
+// XReadOptions allows you to modify the effects of the XRead command.
+//
+// Count limits the number of entries returned from each stream.
+//
+// Block waits for entries to be added when none are available.
+//
+// Timeout is the maximum time to block for. A Timeout of 0 blocks indefinitely.
+type XReadOptions struct {
+	Count   uint
+	Block   bool
+	Timeout time.Duration
+}
+
+// XReadGroupOptions allows you to modify the effects of the XReadGroup command.
+// Count, Block and Timeout behave the same as in XReadOptions.
+//
+// NoAck does not add the delivered entries to the pending entries list of the consumer.
+type XReadGroupOptions struct {
+	Count   uint
+	Block   bool
+	Timeout time.Duration
+	NoAck   bool
+}
+
+// XGroupCreateOptions allows you to modify the effects of the XGroupCreate command.
+//
+// MkStream creates an empty stream if the key does not exist.
+//
+// EntriesRead sets the number of entries the group has read.
+type XGroupCreateOptions struct {
+	MkStream    bool
+	EntriesRead uint
+}
+
+// XPendingSummary is the summary of the pending entries list of a consumer group.
+//
+// Count is the number of pending entries.
+//
+// MinID and MaxID are the smallest and greatest IDs in the pending entries list.
+//
+// Consumers maps each consumer with pending entries to the number of entries it has pending.
+type XPendingSummary struct {
+	Count     int
+	MinID     string
+	MaxID     string
+	Consumers map[string]int
+}
+
+// XPendingEntry is an entry of the pending entries list of a consumer group.
+type XPendingEntry struct {
+	ID            string
+	Consumer      string
+	Idle          time.Duration
+	DeliveryCount int
+}
+
+// XPendingOptions allows you to modify the results of the XPendingRange command.
+//
+// Start and End are the bounds of the range of IDs. They default to "-" and "+".
+//
+// Count is the maximum number of entries returned. It defaults to 10.
+//
+// Consumer only returns the pending entries of the consumer.
+//
+// Idle only returns the entries that have been idle for at least the given duration.
+type XPendingOptions struct {
+	Start    string
+	End      string
+	Count    uint
+	Consumer string
+	Idle     time.Duration
+}
+
+// XClaimOptions allows you to modify the effects of the XClaim command.
+//
+// Idle sets the idle time of the claimed entries. Time sets their delivery time instead. Idle takes priority over Time.
+//
+// RetryCount sets the delivery count of the claimed entries.
+//
+// Force adds the entries to the pending entries list even if they're not pending, as long as they exist.
+//
+// JustID only returns the IDs of the claimed entries and does not increment their delivery count.
+// The entries returned only have their ID set.
+//
+// LastID updates the last delivered ID of the group if it's greater than the current one.
+type XClaimOptions struct {
+	Idle       time.Duration
+	Time       time.Time
+	RetryCount uint
+	Force      bool
+	JustID     bool
+	LastID     string
+}
+
+// XAutoClaimOptions allows you to modify the effects of the XAutoClaim command.
+//
+// Count is the maximum number of entries claimed. It defaults to 100.
+//
+// JustID only returns the IDs of the claimed entries and does not increment their delivery count.
+type XAutoClaimOptions struct {
+	Count  uint
+	JustID bool
+}
+
+func parseStreamEntry(v resp.Value) StreamEntry {
+	arr := v.Array()
+	entry := StreamEntry{ID: arr[0].String()}
+	if len(arr) < 2 || arr[1].IsNull() {
+		return entry
+	}
+	fields := arr[1].Array()
+	entry.Fields = make(map[string]string, len(fields)/2)
+	for i := 0; i < len(fields)-1; i += 2 {
+		entry.Fields[fields[i].String()] = fields[i+1].String()
+	}
+	return entry
+}
+
+func parseStreamEntries(v resp.Value) []StreamEntry {
+	entries := make([]StreamEntry, len(v.Array()))
+	for i, e := range v.Array() {
+		if e.Type() != resp.Array {
+			// Entries returned with JUSTID only contain the ID.
+			entries[i] = StreamEntry{ID: e.String()}
+			continue
+		}
+		entries[i] = parseStreamEntry(e)
+	}
+	return entries
+}
+
+func parseStreamEntriesResponse(b []byte) ([]StreamEntry, error) {
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	if v.IsNull() {
+		return []StreamEntry{}, nil
+	}
+	return parseStreamEntries(v), nil
+}
+
+// parseStreamsResponse parses the reply of XREAD and XREADGROUP into a map of keys to entries.
+func parseStreamsResponse(b []byte) (map[string][]StreamEntry, error) {
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]StreamEntry)
+	if v.IsNull() {
+		return res, nil
+	}
+	for _, stream := range v.Array() {
+		arr := stream.Array()
+		res[arr[0].String()] = parseStreamEntries(arr[1])
+	}
+	return res, nil
+}
+
+func buildTrimArgs(maxLen uint, minID string, approximate bool, limit uint) []string {
+	cmd := []string{"MAXLEN"}
+	threshold := strconv.FormatUint(uint64(maxLen), 10)
+	if maxLen == 0 && minID != "" {
+		cmd = []string{"MINID"}
+		threshold = minID
+	}
+	if approximate {
+		cmd = append(cmd, "~")
+	}
+	cmd = append(cmd, threshold)
+	if approximate && limit > 0 {
+		cmd = append(cmd, "LIMIT", strconv.FormatUint(uint64(limit), 10))
+	}
+	return cmd
+}
+
+// XAdd appends an entry to the stream. If the stream does not exist, it's created unless NoMkStream is true.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `fields` - map[string]string - the field-value pairs of the entry.
+//
+// `options` - XAddOptions.
+//
+// Returns: The ID of the added entry. Returns an empty string if NoMkStream is true and the stream does not exist.
+//
+// Errors:
+//
+// "value at <key> is not a stream" - when the provided key exists but is not a stream.
+//
+// "The ID specified in XADD is equal or smaller than the target stream top item" - when the ID is not greater
+// than the last ID of the stream.
+func (server *EchoVault) XAdd(key string, fields map[string]string, options XAddOptions) (string, error) {
+	cmd := []string{"XADD", key}
+	if options.NoMkStream {
+		cmd = append(cmd, "NOMKSTREAM")
+	}
+	if options.MaxLen > 0 || options.MinID != "" {
+		cmd = append(cmd, buildTrimArgs(options.MaxLen, options.MinID, options.Approximate, options.Limit)...)
+	}
+	if options.ID == "" {
+		cmd = append(cmd, "*")
+	} else {
+		cmd = append(cmd, options.ID)
+	}
+	for field, value := range fields {
+		cmd = append(cmd, field, value)
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return "", err
+	}
+	return internal.ParseStringResponse(b)
+}
+
+// XLen returns the number of entries in the stream.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// Returns: The number of entries in the stream. Returns 0 if the key does not exist.
+//
+// Errors:
+//
+// "value at <key> is not a stream" - when the provided key exists but is not a stream.
+func (server *EchoVault) XLen(key string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"XLEN", key}), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// XRange returns the entries of the stream with IDs between start and end inclusive.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `start` - string - the start of the range. "-" is the smallest possible ID. Prefix the ID with "(" to make it exclusive.
+//
+// `end` - string - the end of the range. "+" is the greatest possible ID. Prefix the ID with "(" to make it exclusive.
+//
+// `count` - uint - the maximum number of entries to return. 0 returns all the entries in the range.
+//
+// Returns: The entries in the range. Returns an empty slice if the key does not exist.
+//
+// Errors:
+//
+// "value at <key> is not a stream" - when the provided key exists but is not a stream.
+func (server *EchoVault) XRange(key, start, end string, count uint) ([]StreamEntry, error) {
+	cmd := []string{"XRANGE", key, start, end}
+	if count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(count), 10))
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseStreamEntriesResponse(b)
+}
+
+// XRevRange is the same as XRange, but returns the entries in reverse order, starting from end.
+func (server *EchoVault) XRevRange(key, end, start string, count uint) ([]StreamEntry, error) {
+	cmd := []string{"XREVRANGE", key, end, start}
+	if count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(count), 10))
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseStreamEntriesResponse(b)
+}
+
+// XDel deletes the entries with the given IDs from the stream.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `ids` - ...string - the IDs of the entries to delete.
+//
+// Returns: The number of entries deleted.
+//
+// Errors:
+//
+// "value at <key> is not a stream" - when the provided key exists but is not a stream.
+func (server *EchoVault) XDel(key string, ids ...string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"XDEL", key}, ids...)), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// XTrim trims the stream.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `options` - XTrimOptions.
+//
+// Returns: The number of entries removed.
+//
+// Errors:
+//
+// "value at <key> is not a stream" - when the provided key exists but is not a stream.
+func (server *EchoVault) XTrim(key string, options XTrimOptions) (int, error) {
+	cmd := append([]string{"XTRIM", key}, buildTrimArgs(options.MaxLen, options.MinID, options.Approximate, options.Limit)...)
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// XRead returns the entries with IDs greater than the given ID from each of the streams.
+//
+// Parameters:
+//
+// `streams` - map[string]string - a map of stream keys to IDs. "$" only returns the entries added after
+// the call is made.
+//
+// `options` - XReadOptions.
+//
+// Returns: A map of stream keys to entries. Streams without entries are not included in the map.
+//
+// Errors:
+//
+// "value at <key> is not a stream" - when one of the provided keys exists but is not a stream.
+func (server *EchoVault) XRead(streams map[string]string, options XReadOptions) (map[string][]StreamEntry, error) {
+	cmd := []string{"XREAD"}
+	if options.Count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(options.Count), 10))
+	}
+	if options.Block {
+		cmd = append(cmd, "BLOCK", strconv.FormatInt(options.Timeout.Milliseconds(), 10))
+	}
+	cmd = append(cmd, "STREAMS")
+	keys := make([]string, 0, len(streams))
+	ids := make([]string, 0, len(streams))
+	for key, id := range streams {
+		keys = append(keys, key)
+		ids = append(ids, id)
+	}
+	cmd = append(append(cmd, keys...), ids...)
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseStreamsResponse(b)
+}
+
+// XGroupCreate creates a consumer group on the stream.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `group` - string - the name of the group.
+//
+// `id` - string - the group delivers the entries with IDs greater than id. "$" only delivers new entries.
+//
+// `options` - XGroupCreateOptions.
+//
+// Returns: true if the group was created.
+//
+// Errors:
+//
+// "BUSYGROUP Consumer Group name already exists" - when the group already exists.
+//
+// "The XGROUP subcommand requires the key to exist..." - when the key does not exist and MkStream is false.
+func (server *EchoVault) XGroupCreate(key, group, id string, options XGroupCreateOptions) (bool, error) {
+	cmd := []string{"XGROUP", "CREATE", key, group, id}
+	if options.MkStream {
+		cmd = append(cmd, "MKSTREAM")
+	}
+	if options.EntriesRead > 0 {
+		cmd = append(cmd, "ENTRIESREAD", strconv.FormatUint(uint64(options.EntriesRead), 10))
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	s, err := internal.ParseStringResponse(b)
+	return s == "OK", err
+}
+
+// XGroupSetID sets the last delivered ID of the consumer group.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `group` - string - the name of the group.
+//
+// `id` - string - the new last delivered ID. "$" is the last ID of the stream.
+//
+// Returns: true if the ID was set.
+//
+// Errors:
+//
+// "NOGROUP No such consumer group <group> for key name <key>" - when the group does not exist.
+func (server *EchoVault) XGroupSetID(key, group, id string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"XGROUP", "SETID", key, group, id}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	s, err := internal.ParseStringResponse(b)
+	return s == "OK", err
+}
+
+// XGroupDestroy destroys the consumer group along with its consumers and pending entries.
+//
+// Returns: true if the group was destroyed, false if it did not exist.
+func (server *EchoVault) XGroupDestroy(key, group string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"XGROUP", "DESTROY", key, group}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	n, err := internal.ParseIntegerResponse(b)
+	return n == 1, err
+}
+
+// XGroupCreateConsumer creates a consumer in the consumer group.
+//
+// Returns: true if the consumer was created, false if it already exists.
+func (server *EchoVault) XGroupCreateConsumer(key, group, consumer string) (bool, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"XGROUP", "CREATECONSUMER", key, group, consumer}), nil, false, true)
+	if err != nil {
+		return false, err
+	}
+	n, err := internal.ParseIntegerResponse(b)
+	return n == 1, err
+}
+
+// XGroupDelConsumer deletes the consumer from the consumer group.
+//
+// Returns: The number of pending entries the consumer had.
+func (server *EchoVault) XGroupDelConsumer(key, group, consumer string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"XGROUP", "DELCONSUMER", key, group, consumer}), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// XReadGroup reads the entries of the streams on behalf of a consumer of the consumer group.
+//
+// Parameters:
+//
+// `group` - string - the name of the group.
+//
+// `consumer` - string - the name of the consumer. The consumer is created if it does not exist.
+//
+// `streams` - map[string]string - a map of stream keys to IDs. ">" delivers the entries that have never been
+// delivered to any consumer of the group. Any other ID returns the consumer's pending entries with greater IDs.
+//
+// `options` - XReadGroupOptions.
+//
+// Returns: A map of stream keys to entries.
+//
+// Errors:
+//
+// "NOGROUP No such key <key> or consumer group <group> in XREADGROUP with GROUP option" - when one of the
+// streams or the group does not exist.
+func (server *EchoVault) XReadGroup(group, consumer string, streams map[string]string, options XReadGroupOptions) (map[string][]StreamEntry, error) {
+	cmd := []string{"XREADGROUP", "GROUP", group, consumer}
+	if options.Count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(options.Count), 10))
+	}
+	if options.Block {
+		cmd = append(cmd, "BLOCK", strconv.FormatInt(options.Timeout.Milliseconds(), 10))
+	}
+	if options.NoAck {
+		cmd = append(cmd, "NOACK")
+	}
+	cmd = append(cmd, "STREAMS")
+	keys := make([]string, 0, len(streams))
+	ids := make([]string, 0, len(streams))
+	for key, id := range streams {
+		keys = append(keys, key)
+		ids = append(ids, id)
+	}
+	cmd = append(append(cmd, keys...), ids...)
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseStreamsResponse(b)
+}
+
+// XAck removes the IDs from the pending entries list of the consumer group.
+//
+// Returns: The number of entries acknowledged.
+func (server *EchoVault) XAck(key, group string, ids ...string) (int, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(append([]string{"XACK", key, group}, ids...)), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// XPending returns the summary of the pending entries list of the consumer group.
+//
+// Errors:
+//
+// "NOGROUP No such key <key> or consumer group <group>" - when the stream or the group does not exist.
+func (server *EchoVault) XPending(key, group string) (XPendingSummary, error) {
+	b, err := server.handleCommand(server.context, internal.EncodeCommand([]string{"XPENDING", key, group}), nil, false, true)
+	if err != nil {
+		return XPendingSummary{}, err
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return XPendingSummary{}, err
+	}
+	arr := v.Array()
+	summary := XPendingSummary{
+		Count:     arr[0].Integer(),
+		MinID:     arr[1].String(),
+		MaxID:     arr[2].String(),
+		Consumers: make(map[string]int),
+	}
+	if summary.Count == 0 {
+		summary.MinID, summary.MaxID = "", ""
+	}
+	for _, c := range arr[3].Array() {
+		summary.Consumers[c.Array()[0].String()] = c.Array()[1].Integer()
+	}
+	return summary, nil
+}
+
+// XPendingRange returns the details of the pending entries of the consumer group.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `group` - string - the name of the group.
+//
+// `options` - XPendingOptions.
+//
+// Errors:
+//
+// "NOGROUP No such key <key> or consumer group <group>" - when the stream or the group does not exist.
+func (server *EchoVault) XPendingRange(key, group string, options XPendingOptions) ([]XPendingEntry, error) {
+	cmd := []string{"XPENDING", key, group}
+	if options.Idle > 0 {
+		cmd = append(cmd, "IDLE", strconv.FormatInt(options.Idle.Milliseconds(), 10))
+	}
+	start, end, count := options.Start, options.End, options.Count
+	if start == "" {
+		start = "-"
+	}
+	if end == "" {
+		end = "+"
+	}
+	if count == 0 {
+		count = 10
+	}
+	cmd = append(cmd, start, end, strconv.FormatUint(uint64(count), 10))
+	if options.Consumer != "" {
+		cmd = append(cmd, options.Consumer)
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]XPendingEntry, len(v.Array()))
+	for i, e := range v.Array() {
+		arr := e.Array()
+		entries[i] = XPendingEntry{
+			ID:            arr[0].String(),
+			Consumer:      arr[1].String(),
+			Idle:          time.Duration(arr[2].Integer()) * time.Millisecond,
+			DeliveryCount: arr[3].Integer(),
+		}
+	}
+	return entries, nil
+}
+
+// XClaim transfers the ownership of the pending entries that have been idle for at least minIdle to the consumer.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `group` - string - the name of the group.
+//
+// `consumer` - string - the consumer that claims the entries.
+//
+// `minIdle` - time.Duration - only the entries that have been idle for at least minIdle are claimed.
+//
+// `ids` - []string - the IDs of the entries to claim.
+//
+// `options` - XClaimOptions.
+//
+// Returns: The claimed entries.
+//
+// Errors:
+//
+// "NOGROUP No such key <key> or consumer group <group>" - when the stream or the group does not exist.
+func (server *EchoVault) XClaim(key, group, consumer string, minIdle time.Duration, ids []string, options XClaimOptions) ([]StreamEntry, error) {
+	cmd := append([]string{"XCLAIM", key, group, consumer, strconv.FormatInt(minIdle.Milliseconds(), 10)}, ids...)
+	switch {
+	case options.Idle > 0:
+		cmd = append(cmd, "IDLE", strconv.FormatInt(options.Idle.Milliseconds(), 10))
+	case !options.Time.IsZero():
+		cmd = append(cmd, "TIME", strconv.FormatInt(options.Time.UnixMilli(), 10))
+	}
+	if options.RetryCount > 0 {
+		cmd = append(cmd, "RETRYCOUNT", strconv.FormatUint(uint64(options.RetryCount), 10))
+	}
+	if options.Force {
+		cmd = append(cmd, "FORCE")
+	}
+	if options.JustID {
+		cmd = append(cmd, "JUSTID")
+	}
+	if options.LastID != "" {
+		cmd = append(cmd, "LASTID", options.LastID)
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return parseStreamEntriesResponse(b)
+}
+
+// XAutoClaim transfers the ownership of the pending entries that have been idle for at least minIdle,
+// starting from start, to the consumer.
+//
+// Parameters:
+//
+// `key` - string - the key of the stream.
+//
+// `group` - string - the name of the group.
+//
+// `consumer` - string - the consumer that claims the entries.
+//
+// `minIdle` - time.Duration - only the entries that have been idle for at least minIdle are claimed.
+//
+// `start` - string - the ID to start scanning the pending entries list from.
+//
+// `options` - XAutoClaimOptions.
+//
+// Returns: The ID to use as start in the next call ("0-0" when the whole pending entries list has been scanned),
+// the claimed entries and the IDs of the pending entries that no longer exist in the stream.
+//
+// Errors:
+//
+// "NOGROUP No such key <key> or consumer group <group>" - when the stream or the group does not exist.
+func (server *EchoVault) XAutoClaim(key, group, consumer string, minIdle time.Duration, start string, options XAutoClaimOptions) (string, []StreamEntry, []string, error) {
+	cmd := []string{"XAUTOCLAIM", key, group, consumer, strconv.FormatInt(minIdle.Milliseconds(), 10), start}
+	if options.Count > 0 {
+		cmd = append(cmd, "COUNT", strconv.FormatUint(uint64(options.Count), 10))
+	}
+	if options.JustID {
+		cmd = append(cmd, "JUSTID")
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	arr := v.Array()
+	deleted := make([]string, len(arr[2].Array()))
+	for i, id := range arr[2].Array() {
+		deleted[i] = id.String()
+	}
+	return arr[0].String(), parseStreamEntries(arr[1]), deleted, nil
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"github.com/echovault/echovault/internal/modules/stream"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func presetStream(entries ...stream.Entry) *stream.Stream {
+	s := stream.NewStream()
+	for _, entry := range entries {
+		_ = s.Add(entry.ID, entry.Fields)
+	}
+	return s
+}
+
+func TestEchoVault_XADD(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name        string
+		presetValue interface{}
+		key         string
+		fields      map[string]string
+		options     XAddOptions
+		want        string
+		wantLen     int
+		wantErr     bool
+	}{
+		{
+			name:    "1. Create a new stream with an explicit ID",
+			key:     "XAddKey1",
+			fields:  map[string]string{"field1": "value1"},
+			options: XAddOptions{ID: "1-1"},
+			want:    "1-1",
+			wantLen: 1,
+			wantErr: false,
+		},
+		{
+			name: "2. Trim the stream after adding the entry",
+			presetValue: presetStream(
+				stream.Entry{ID: stream.ID{Ms: 1, Seq: 1}, Fields: []string{"a", "1"}},
+				stream.Entry{ID: stream.ID{Ms: 1, Seq: 2}, Fields: []string{"b", "2"}},
+			),
+			key:     "XAddKey2",
+			fields:  map[string]string{"c": "3"},
+			options: XAddOptions{ID: "2-*", MaxLen: 2},
+			want:    "2-0",
+			wantLen: 2,
+			wantErr: false,
+		},
+		{
+			name:    "3. NoMkStream does not create the stream",
+			key:     "XAddKey3",
+			fields:  map[string]string{"field1": "value1"},
+			options: XAddOptions{NoMkStream: true},
+			want:    "",
+			wantLen: 0,
+			wantErr: false,
+		},
+		{
+			name: "4. Return an error when the ID is smaller than the last ID",
+			presetValue: presetStream(
+				stream.Entry{ID: stream.ID{Ms: 5, Seq: 0}, Fields: []string{"a", "1"}},
+			),
+			key:     "XAddKey4",
+			fields:  map[string]string{"field1": "value1"},
+			options: XAddOptions{ID: "4-0"},
+			want:    "",
+			wantLen: 1,
+			wantErr: true,
+		},
+		{
+			name:        "5. Return an error when the key is not a stream",
+			presetValue: "Default value",
+			key:         "XAddKey5",
+			fields:      map[string]string{"field1": "value1"},
+			want:        "",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.presetValue != nil {
+				if err := presetValue(server, context.Background(), tt.key, tt.presetValue); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			got, err := server.XAdd(tt.key, tt.fields, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("XADD() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("XADD() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			length, err := server.XLen(tt.key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if length != tt.wantLen {
+				t.Errorf("XADD() stream length = %v, want %v", length, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestEchoVault_XRANGE(t *testing.T) {
+	server := createEchoVault()
+
+	preset := presetStream(
+		stream.Entry{ID: stream.ID{Ms: 1, Seq: 1}, Fields: []string{"a", "1"}},
+		stream.Entry{ID: stream.ID{Ms: 1, Seq: 2}, Fields: []string{"b", "2"}},
+		stream.Entry{ID: stream.ID{Ms: 2, Seq: 1}, Fields: []string{"c", "3"}},
+	)
+	if err := presetValue(server, context.Background(), "XRangeKey1", preset); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name    string
+		rev     bool
+		start   string
+		end     string
+		count   uint
+		want    []StreamEntry
+		wantErr bool
+	}{
+		{
+			name:  "1. Return the entries in the range",
+			start: "-",
+			end:   "1",
+			want: []StreamEntry{
+				{ID: "1-1", Fields: map[string]string{"a": "1"}},
+				{ID: "1-2", Fields: map[string]string{"b": "2"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "2. Exclusive range with count",
+			start:   "(1-1",
+			end:     "+",
+			count:   1,
+			want:    []StreamEntry{{ID: "1-2", Fields: map[string]string{"b": "2"}}},
+			wantErr: false,
+		},
+		{
+			name:  "3. Reverse range",
+			rev:   true,
+			start: "1-2",
+			end:   "+",
+			want: []StreamEntry{
+				{ID: "2-1", Fields: map[string]string{"c": "3"}},
+				{ID: "1-2", Fields: map[string]string{"b": "2"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "4. Return an error when the ID is invalid",
+			start:   "invalid",
+			end:     "+",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []StreamEntry
+			var err error
+			if tt.rev {
+				got, err = server.XRevRange("XRangeKey1", tt.end, tt.start, tt.count)
+			} else {
+				got, err = server.XRange("XRangeKey1", tt.start, tt.end, tt.count)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("XRANGE() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("XRANGE() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_XREAD(t *testing.T) {
+	server := createEchoVault()
+
+	preset := presetStream(
+		stream.Entry{ID: stream.ID{Ms: 1, Seq: 1}, Fields: []string{"a", "1"}},
+	)
+	if err := presetValue(server, context.Background(), "XReadKey1", preset); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Run("1. Read the entries after the ID", func(t *testing.T) {
+		got, err := server.XRead(map[string]string{"XReadKey1": "0", "XReadKey2": "0"}, XReadOptions{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		want := map[string][]StreamEntry{
+			"XReadKey1": {{ID: "1-1", Fields: map[string]string{"a": "1"}}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("XREAD() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("2. Blocking read returns the entry added by another caller", func(t *testing.T) {
+		go func() {
+			<-time.After(50 * time.Millisecond)
+			_, _ = server.XAdd("XReadKey1", map[string]string{"b": "2"}, XAddOptions{ID: "2-1"})
+		}()
+		got, err := server.XRead(map[string]string{"XReadKey1": "$"}, XReadOptions{Block: true, Timeout: 5 * time.Second})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		want := map[string][]StreamEntry{
+			"XReadKey1": {{ID: "2-1", Fields: map[string]string{"b": "2"}}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("XREAD() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("3. Blocking read times out", func(t *testing.T) {
+		got, err := server.XRead(map[string]string{"XReadKey1": "$"}, XReadOptions{Block: true, Timeout: 50 * time.Millisecond})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(got) != 0 {
+			t.Errorf("XREAD() got = %v, want empty result", got)
+		}
+	})
+}
+
+func TestEchoVault_XREADGROUP(t *testing.T) {
+	server := createEchoVault()
+
+	preset := presetStream(
+		stream.Entry{ID: stream.ID{Ms: 1, Seq: 1}, Fields: []string{"a", "1"}},
+		stream.Entry{ID: stream.ID{Ms: 1, Seq: 2}, Fields: []string{"b", "2"}},
+	)
+	if err := presetValue(server, context.Background(), "XGroupKey1", preset); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if ok, err := server.XGroupCreate("XGroupKey1", "group1", "0", XGroupCreateOptions{}); !ok || err != nil {
+		t.Errorf("XGROUP CREATE got = %v, error = %v", ok, err)
+		return
+	}
+	if _, err := server.XGroupCreate("XGroupKey2", "group1", "$", XGroupCreateOptions{}); err == nil {
+		t.Error("expected XGROUP CREATE on a non-existent key to return an error")
+	}
+
+	got, err := server.XReadGroup("group1", "consumer1", map[string]string{"XGroupKey1": ">"}, XReadGroupOptions{Count: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := map[string][]StreamEntry{"XGroupKey1": {{ID: "1-1", Fields: map[string]string{"a": "1"}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("XREADGROUP() got = %v, want %v", got, want)
+	}
+
+	summary, err := server.XPending("XGroupKey1", "group1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	wantSummary := XPendingSummary{Count: 1, MinID: "1-1", MaxID: "1-1", Consumers: map[string]int{"consumer1": 1}}
+	if !reflect.DeepEqual(summary, wantSummary) {
+		t.Errorf("XPENDING() got = %v, want %v", summary, wantSummary)
+	}
+
+	claimed, err := server.XClaim("XGroupKey1", "group1", "consumer2", 0, []string{"1-1"}, XClaimOptions{JustID: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(claimed, []StreamEntry{{ID: "1-1"}}) {
+		t.Errorf("XCLAIM() got = %v, want [{1-1 map[]}]", claimed)
+	}
+
+	pending, err := server.XPendingRange("XGroupKey1", "group1", XPendingOptions{Consumer: "consumer2"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	wantPending := []XPendingEntry{{ID: "1-1", Consumer: "consumer2", Idle: 0, DeliveryCount: 1}}
+	if !reflect.DeepEqual(pending, wantPending) {
+		t.Errorf("XPENDING() got = %v, want %v", pending, wantPending)
+	}
+
+	acked, err := server.XAck("XGroupKey1", "group1", "1-1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if acked != 1 {
+		t.Errorf("XACK() got = %v, want 1", acked)
+	}
+
+	if _, err = server.XReadGroup("group2", "consumer1", map[string]string{"XGroupKey1": ">"}, XReadGroupOptions{}); err == nil {
+		t.Error("expected XREADGROUP with a non-existent group to return an error")
+	}
+}
@@ -15,8 +15,11 @@
 package echovault
 
 import (
+	"bytes"
 	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
 	"strconv"
+	"strings"
 )
 
 // SetRange replaces a portion of the string at the provided key starting at the offset with a new string.
@@ -76,3 +79,204 @@ func (server *EchoVault) GetRange(key string, start, end int) (string, error) {
 	}
 	return internal.ParseStringResponse(b)
 }
+
+// BitCountOptions allows you to limit the BitCount command to a range of the string.
+//
+// Start and End specify the inclusive range. Negative indices count from the end of the string.
+//
+// Bit specifies the range in bits instead of bytes.
+type BitCountOptions struct {
+	Start int
+	End   int
+	Bit   bool
+}
+
+// BitPosOptions allows you to limit the BitPos command to a range of the string.
+// The fields behave the same as in BitCountOptions.
+type BitPosOptions BitCountOptions
+
+// BitFieldOperation is a single operation of the BitField command.
+//
+// Operation is one of "GET", "SET", "INCRBY" or "OVERFLOW".
+//
+// Encoding is the integer type, "i" or "u" for signed and unsigned integers followed by the width in bits (e.g. "i8", "u16").
+//
+// Offset is the bit offset of the integer. An offset prefixed with "#" is multiplied by the width of the encoding.
+//
+// Value is the value to set for "SET" and the increment for "INCRBY".
+//
+// Overflow is the overflow behaviour of the subsequent "SET" and "INCRBY" operations for the "OVERFLOW" operation.
+// It's one of "WRAP", "SAT" or "FAIL".
+type BitFieldOperation struct {
+	Operation string
+	Encoding  string
+	Offset    string
+	Value     int64
+	Overflow  string
+}
+
+// SetBit sets or clears the bit at the offset in the string at the provided key.
+// If the string does not exist, a new string is created. The string is grown if the offset is past its end.
+//
+// Parameters:
+//
+// `key` - string - the key of the string.
+//
+// `offset` - uint - the bit offset.
+//
+// `value` - int - the bit to set, 0 or 1.
+//
+// Returns: The original bit at the offset.
+//
+// Errors:
+//
+// - "value at key <key> is not a string" - when the key provided does not hold a string.
+//
+// - "bit is not an integer or out of range" - when the value is not 0 or 1.
+func (server *EchoVault) SetBit(key string, offset uint, value int) (int, error) {
+	cmd := []string{"SETBIT", key, strconv.FormatUint(uint64(offset), 10), strconv.Itoa(value)}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// GetBit returns the bit at the offset in the string at the provided key.
+//
+// Returns: The bit at the offset. Returns 0 if the offset is past the end of the string or the key does not exist.
+//
+// Errors:
+//
+// - "value at key <key> is not a string" - when the key provided does not hold a string.
+func (server *EchoVault) GetBit(key string, offset uint) (int, error) {
+	cmd := []string{"GETBIT", key, strconv.FormatUint(uint64(offset), 10)}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// BitCount returns the number of set bits in the string at the provided key.
+// If options are provided, only the bits in the range are counted.
+//
+// Returns: The number of set bits. Returns 0 if the key does not exist.
+//
+// Errors:
+//
+// - "value at key <key> is not a string" - when the key provided does not hold a string.
+func (server *EchoVault) BitCount(key string, options ...BitCountOptions) (int, error) {
+	cmd := []string{"BITCOUNT", key}
+	if len(options) > 0 {
+		cmd = append(cmd, strconv.Itoa(options[0].Start), strconv.Itoa(options[0].End))
+		if options[0].Bit {
+			cmd = append(cmd, "BIT")
+		}
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// BitPos returns the position of the first bit set to the provided bit in the string at the provided key.
+// If options are provided, only the bits in the range are searched.
+//
+// Returns: The position of the bit, or -1 if it's not found. When searching for a clear bit without a range,
+// a string of set bits returns the position right after the end of the string.
+//
+// Errors:
+//
+// - "value at key <key> is not a string" - when the key provided does not hold a string.
+//
+// - "the bit argument must be 1 or 0" - when the bit is not 0 or 1.
+func (server *EchoVault) BitPos(key string, bit int, options ...BitPosOptions) (int, error) {
+	cmd := []string{"BITPOS", key, strconv.Itoa(bit)}
+	if len(options) > 0 {
+		cmd = append(cmd, strconv.Itoa(options[0].Start), strconv.Itoa(options[0].End))
+		if options[0].Bit {
+			cmd = append(cmd, "BIT")
+		}
+	}
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// BitOp performs a bitwise operation between the strings at the keys and stores the result at the destination.
+//
+// Parameters:
+//
+// `operation` - string - one of "AND", "OR", "XOR" or "NOT". "NOT" only accepts a single key.
+//
+// `destination` - string - the key to store the result at.
+//
+// `keys` - ...string - the keys of the source strings.
+//
+// Returns: The length of the string stored at the destination.
+//
+// Errors:
+//
+// - "value at key <key> is not a string" - when one of the keys provided does not hold a string.
+//
+// - "BITOP NOT must be called with a single source key" - when more than one key is provided with "NOT".
+func (server *EchoVault) BitOp(operation, destination string, keys ...string) (int, error) {
+	cmd := append([]string{"BITOP", operation, destination}, keys...)
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ParseIntegerResponse(b)
+}
+
+// BitField performs the operations on the integers stored in the string at the provided key.
+//
+// Parameters:
+//
+// `key` - string - the key of the string.
+//
+// `operations` - ...BitFieldOperation - the operations to perform, in order.
+//
+// Returns: The result of each "GET", "SET" and "INCRBY" operation. "SET" returns the old value and "INCRBY"
+// returns the new value. The result is nil if the operation was skipped because of the "FAIL" overflow behaviour.
+//
+// Errors:
+//
+// - "value at key <key> is not a string" - when the key provided does not hold a string.
+func (server *EchoVault) BitField(key string, operations ...BitFieldOperation) ([]*int64, error) {
+	cmd := []string{"BITFIELD", key}
+	for _, op := range operations {
+		switch strings.ToUpper(op.Operation) {
+		case "OVERFLOW":
+			cmd = append(cmd, "OVERFLOW", op.Overflow)
+		case "GET":
+			cmd = append(cmd, "GET", op.Encoding, op.Offset)
+		default:
+			cmd = append(cmd, op.Operation, op.Encoding, op.Offset, strconv.FormatInt(op.Value, 10))
+		}
+	}
+
+	b, err := server.handleCommand(server.context, internal.EncodeCommand(cmd), nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resp.NewReader(bytes.NewReader(b))
+	v, _, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*int64, len(v.Array()))
+	for i, e := range v.Array() {
+		if e.IsNull() {
+			continue
+		}
+		n := int64(e.Integer())
+		res[i] = &n
+	}
+	return res, nil
+}
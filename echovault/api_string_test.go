@@ -311,3 +311,250 @@ func TestEchoVault_STRLEN(t *testing.T) {
 		})
 	}
 }
+
+func TestEchoVault_SETBIT(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name        string
+		presetValue interface{}
+		key         string
+		offset      uint
+		value       int
+		want        int
+		wantBit     int
+		wantErr     bool
+	}{
+		{
+			name:    "1. Set a bit on a non-existent key",
+			key:     "SetBitKey1",
+			offset:  10,
+			value:   1,
+			want:    0,
+			wantBit: 1,
+			wantErr: false,
+		},
+		{
+			name:        "2. Clear a set bit on an existing string",
+			presetValue: "a",
+			key:         "SetBitKey2",
+			offset:      1,
+			value:       0,
+			want:        1,
+			wantBit:     0,
+			wantErr:     false,
+		},
+		{
+			name:        "3. Return an error when the value is not a string",
+			presetValue: []interface{}{"value"},
+			key:         "SetBitKey3",
+			offset:      1,
+			value:       1,
+			want:        0,
+			wantErr:     true,
+		},
+		{
+			name:    "4. Return an error when the bit is not 0 or 1",
+			key:     "SetBitKey4",
+			offset:  1,
+			value:   2,
+			want:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.presetValue != nil {
+				if err := presetValue(server, context.Background(), tt.key, tt.presetValue); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			got, err := server.SetBit(tt.key, tt.offset, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SETBIT() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("SETBIT() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			bit, err := server.GetBit(tt.key, tt.offset)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if bit != tt.wantBit {
+				t.Errorf("GETBIT() got = %v, want %v", bit, tt.wantBit)
+			}
+		})
+	}
+}
+
+func TestEchoVault_BITCOUNT(t *testing.T) {
+	server := createEchoVault()
+
+	if err := presetValue(server, context.Background(), "BitCountKey1", "foobar"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		options []BitCountOptions
+		want    int
+	}{
+		{name: "1. Count the whole string", key: "BitCountKey1", want: 26},
+		{name: "2. Count a byte range", key: "BitCountKey1", options: []BitCountOptions{{Start: 1, End: 1}}, want: 6},
+		{name: "3. Count a bit range", key: "BitCountKey1", options: []BitCountOptions{{Start: 5, End: 30, Bit: true}}, want: 17},
+		{name: "4. Count a non-existent key", key: "BitCountKey2", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.BitCount(tt.key, tt.options...)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BITCOUNT() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_BITPOS(t *testing.T) {
+	server := createEchoVault()
+
+	if err := presetValue(server, context.Background(), "BitPosKey1", "\xff\xf0\x00"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		bit     int
+		options []BitPosOptions
+		want    int
+	}{
+		{name: "1. First clear bit", key: "BitPosKey1", bit: 0, want: 12},
+		{name: "2. First set bit in a byte range", key: "BitPosKey1", bit: 1, options: []BitPosOptions{{Start: 1, End: -1}}, want: 8},
+		{name: "3. Bit not found in the range", key: "BitPosKey1", bit: 1, options: []BitPosOptions{{Start: 2, End: -1}}, want: -1},
+		{name: "4. Set bit in a non-existent key", key: "BitPosKey2", bit: 1, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.BitPos(tt.key, tt.bit, tt.options...)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BITPOS() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoVault_BITOP(t *testing.T) {
+	server := createEchoVault()
+
+	for key, value := range map[string]string{"BitOpKey1": "foobar", "BitOpKey2": "abcdef"} {
+		if err := presetValue(server, context.Background(), key, value); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	tests := []struct {
+		name      string
+		operation string
+		keys      []string
+		want      int
+		wantValue interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "1. AND",
+			operation: "AND",
+			keys:      []string{"BitOpKey1", "BitOpKey2"},
+			want:      6,
+			wantValue: "`bc`ab",
+		},
+		{
+			name:      "2. XOR of a string with itself",
+			operation: "XOR",
+			keys:      []string{"BitOpKey1", "BitOpKey1"},
+			want:      6,
+			wantValue: "\x00\x00\x00\x00\x00\x00",
+		},
+		{
+			name:      "3. Empty source keys delete the destination",
+			operation: "OR",
+			keys:      []string{"BitOpKey4", "BitOpKey5"},
+			want:      0,
+			wantValue: nil,
+		},
+		{
+			name:      "4. NOT with multiple keys returns an error",
+			operation: "NOT",
+			keys:      []string{"BitOpKey1", "BitOpKey2"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.BitOp(tt.operation, "BitOpKey3", tt.keys...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BITOP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BITOP() got = %v, want %v", got, tt.want)
+			}
+			if value := server.getValues(context.Background(), []string{"BitOpKey3"})["BitOpKey3"]; value != tt.wantValue {
+				t.Errorf("BITOP() value = %q, want %q", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestEchoVault_BITFIELD(t *testing.T) {
+	server := createEchoVault()
+
+	got, err := server.BitField("BitFieldKey1",
+		BitFieldOperation{Operation: "SET", Encoding: "u8", Offset: "0", Value: 255},
+		BitFieldOperation{Operation: "INCRBY", Encoding: "u8", Offset: "0", Value: 1},
+		BitFieldOperation{Operation: "OVERFLOW", Overflow: "FAIL"},
+		BitFieldOperation{Operation: "INCRBY", Encoding: "u8", Offset: "0", Value: 256},
+		BitFieldOperation{Operation: "GET", Encoding: "i4", Offset: "#1"},
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []interface{}{int64(0), int64(0), nil, int64(0)}
+	if len(got) != len(want) {
+		t.Errorf("BITFIELD() got %d results, want %d", len(got), len(want))
+		return
+	}
+	for i := range got {
+		if want[i] == nil {
+			if got[i] != nil {
+				t.Errorf("BITFIELD() got[%d] = %v, want nil", i, *got[i])
+			}
+			continue
+		}
+		if got[i] == nil || *got[i] != want[i] {
+			t.Errorf("BITFIELD() got[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/echovault/echovault/internal"
+	"github.com/tidwall/resp"
+)
+
+// Tx is the handle passed to the function provided to the Transaction method.
+// It's used to watch keys and queue the commands that make up the transaction.
+type Tx struct {
+	server *EchoVault
+	state  *transactionState
+}
+
+// Watch marks the keys to be watched for the duration of the transaction.
+// If any of the watched keys are modified before the transaction is committed,
+// the transaction is aborted and none of the queued commands are executed.
+//
+// Watch should be called before reading the values that the queued commands depend on.
+func (tx *Tx) Watch(keys ...string) {
+	tx.server.watchKeys(tx.state, keys)
+}
+
+// Queue adds a command to the transaction. The command is not executed until the transaction is committed.
+//
+// Parameters:
+//
+// `command` - ...string - The command to queue, e.g. Queue("SET", "key", "value").
+//
+// Errors:
+//
+// "command <command> not supported" - when the command does not exist.
+//
+// Any error returned by the command's key extraction function when the command is malformed.
+func (tx *Tx) Queue(command ...string) error {
+	if len(command) == 0 {
+		return errors.New("empty command")
+	}
+	c, err := tx.server.getCommand(command[0])
+	if err != nil {
+		return err
+	}
+	sc, err := internal.GetSubCommand(c, command)
+	if err != nil {
+		return err
+	}
+	subCommand, _ := sc.(internal.SubCommand)
+	keyExtractionFunc := c.KeyExtractionFunc
+	if subCommand.KeyExtractionFunc != nil {
+		keyExtractionFunc = subCommand.KeyExtractionFunc
+	}
+	if _, err = keyExtractionFunc(command); err != nil {
+		return err
+	}
+	tx.state.commands = append(tx.state.commands, command)
+	return nil
+}
+
+// Transaction executes a group of commands as a single atomic unit.
+// The provided function is used to watch keys and queue commands on the Tx handle.
+// When the function returns nil, all the queued commands are executed without any other command
+// being able to read or modify the keyspace in between. If the function returns an error, the transaction
+// is discarded. In cluster mode, the transaction is replicated as a single raft log entry.
+//
+// Parameters:
+//
+// `fn` - func(tx *Tx) error - The function that builds the transaction.
+//
+// Returns: A slice containing the result of each queued command, in the order they were queued.
+// Each result is either a string, an int, a []interface{}, nil, or an error if that particular command failed.
+//
+// Errors:
+//
+// "transaction aborted, watched keys were modified" - when any of the watched keys were modified before commit.
+//
+// Any error returned by fn.
+func (server *EchoVault) Transaction(fn func(tx *Tx) error) ([]interface{}, error) {
+	tx := &Tx{
+		server: server,
+		state:  newTransactionState(),
+	}
+	defer server.unwatchKeys(tx.state)
+
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+
+	b, err := server.commitTransaction(server.context, nil, tx.state.commands, tx.state.watched)
+	if err != nil {
+		return nil, err
+	}
+
+	v, _, err := resp.NewReader(bytes.NewReader(b)).ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	if v.IsNull() {
+		return nil, errors.New("transaction aborted, watched keys were modified")
+	}
+
+	results := make([]interface{}, len(v.Array()))
+	for i, r := range v.Array() {
+		results[i] = parseTransactionResult(r)
+	}
+	return results, nil
+}
+
+func parseTransactionResult(v resp.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch v.Type() {
+	case resp.Error:
+		return errors.New(strings.TrimPrefix(v.String(), "Error "))
+	case resp.Integer:
+		return v.Integer()
+	case resp.Array:
+		arr := make([]interface{}, len(v.Array()))
+		for i, e := range v.Array() {
+			arr[i] = parseTransactionResult(e)
+		}
+		return arr
+	default:
+		return v.String()
+	}
+}
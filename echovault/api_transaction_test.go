@@ -0,0 +1,150 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/echovault/echovault/internal"
+)
+
+func TestEchoVault_Transaction(t *testing.T) {
+	server := createEchoVault()
+
+	tests := []struct {
+		name         string
+		presetValues map[string]internal.KeyData
+		fn           func(tx *Tx) error
+		modify       map[string]interface{} // Values written after the transaction function runs, before commit.
+		want         []interface{}
+		wantValues   map[string]interface{}
+		wantErr      bool
+	}{
+		{
+			name: "1. Execute queued commands atomically",
+			presetValues: map[string]internal.KeyData{
+				"TxKey1": {Value: 10, ExpireAt: time.Time{}},
+			},
+			fn: func(tx *Tx) error {
+				if err := tx.Queue("INCR", "TxKey1"); err != nil {
+					return err
+				}
+				if err := tx.Queue("SET", "TxKey2", "value2"); err != nil {
+					return err
+				}
+				return tx.Queue("GET", "TxKey2")
+			},
+			want:       []interface{}{11, "OK", "value2"},
+			wantValues: map[string]interface{}{"TxKey1": "11", "TxKey2": "value2"},
+			wantErr:    false,
+		},
+		{
+			name: "2. Error returned from the transaction function discards the transaction",
+			fn: func(tx *Tx) error {
+				if err := tx.Queue("SET", "TxKey3", "value3"); err != nil {
+					return err
+				}
+				return errors.New("discard")
+			},
+			want:       nil,
+			wantValues: map[string]interface{}{"TxKey3": nil},
+			wantErr:    true,
+		},
+		{
+			name: "3. Queueing an unsupported command returns an error",
+			fn: func(tx *Tx) error {
+				return tx.Queue("NOTACOMMAND", "TxKey4")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "4. Modifying a watched key aborts the transaction",
+			presetValues: map[string]internal.KeyData{
+				"TxKey5": {Value: "value5", ExpireAt: time.Time{}},
+			},
+			fn: func(tx *Tx) error {
+				tx.Watch("TxKey5")
+				return tx.Queue("SET", "TxKey5", "transaction")
+			},
+			modify:     map[string]interface{}{"TxKey5": "modified"},
+			want:       nil,
+			wantValues: map[string]interface{}{"TxKey5": "modified"},
+			wantErr:    true,
+		},
+		{
+			name: "5. Command errors are returned in place without aborting the transaction",
+			presetValues: map[string]internal.KeyData{
+				"TxKey6": {Value: "value6", ExpireAt: time.Time{}},
+			},
+			fn: func(tx *Tx) error {
+				if err := tx.Queue("INCR", "TxKey6"); err != nil {
+					return err
+				}
+				return tx.Queue("SET", "TxKey7", "value7")
+			},
+			want:       []interface{}{errors.New("value is not an integer or out of range"), "OK"},
+			wantValues: map[string]interface{}{"TxKey6": "value6", "TxKey7": "value7"},
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, d := range tt.presetValues {
+				presetKeyData(server, context.Background(), k, d)
+			}
+			got, err := server.Transaction(func(tx *Tx) error {
+				if err := tt.fn(tx); err != nil {
+					return err
+				}
+				for k, v := range tt.modify {
+					if err := presetValue(server, context.Background(), k, v); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Transaction() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Transaction() got = %v, want %v", got, tt.want)
+				return
+			}
+			for i := range got {
+				if wantErr, ok := tt.want[i].(error); ok {
+					gotErr, ok := got[i].(error)
+					if !ok || gotErr.Error() != wantErr.Error() {
+						t.Errorf("Transaction() got[%d] = %v, want %v", i, got[i], tt.want[i])
+					}
+					continue
+				}
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("Transaction() got[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+			for k, v := range tt.wantValues {
+				if value := server.getValues(context.Background(), []string{k})[k]; !reflect.DeepEqual(value, v) {
+					t.Errorf("Transaction() value at key %s = %v, want %v", k, value, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"github.com/echovault/echovault/internal"
+	"net"
+	"slices"
+	"time"
+)
+
+// keyWaiter is registered by a blocked command on each of the keys it's waiting on.
+type keyWaiter struct {
+	// ch receives a value when the waiter is woken. It's buffered so that a wake up
+	// that happens while the command is checking the keys is not missed.
+	ch chan struct{}
+	// exclusive waiters are woken one at a time, in the order they were registered on the key,
+	// as they consume the data they were waiting for. Other waiters are all woken at once.
+	exclusive bool
+}
+
+func (w *keyWaiter) signal() {
+	select {
+	case w.ch <- struct{}{}:
+	default:
+	}
+}
+
+// notifyOnKeys returns a channel that receives a value when any of the keys is modified, and a function that
+// deregisters the channel. The deregister function must be called once the channel is no longer needed.
+//
+// Blocking commands should call notifyOnKeys before checking the keys, so that a modification
+// made between the check and the wait is not missed.
+func (server *EchoVault) notifyOnKeys(keys []string) (<-chan struct{}, func()) {
+	return server.registerKeyWaiter(keys, false)
+}
+
+// queueOnKeys works like notifyOnKeys, but the commands queued on a key are woken one at a time in the
+// order they were queued. When the deregister function is called, the next command queued on each of the keys
+// is woken, so that it can consume any data left on the keys.
+func (server *EchoVault) queueOnKeys(keys []string) (<-chan struct{}, func()) {
+	return server.registerKeyWaiter(keys, true)
+}
+
+func (server *EchoVault) registerKeyWaiter(keys []string, exclusive bool) (<-chan struct{}, func()) {
+	w := &keyWaiter{ch: make(chan struct{}, 1), exclusive: exclusive}
+
+	server.keyWaiters.mutex.Lock()
+	defer server.keyWaiters.mutex.Unlock()
+	for _, key := range keys {
+		server.keyWaiters.waiters[key] = append(server.keyWaiters.waiters[key], w)
+	}
+
+	return w.ch, func() {
+		server.keyWaiters.mutex.Lock()
+		defer server.keyWaiters.mutex.Unlock()
+		for _, key := range keys {
+			server.keyWaiters.waiters[key] = slices.DeleteFunc(server.keyWaiters.waiters[key], func(waiter *keyWaiter) bool {
+				return waiter == w
+			})
+			if len(server.keyWaiters.waiters[key]) == 0 {
+				delete(server.keyWaiters.waiters, key)
+				continue
+			}
+			if exclusive {
+				// Pass the turn on to the next exclusive waiter, in case there's data left on the key.
+				server.signalExclusiveWaiter(key)
+			}
+		}
+	}
+}
+
+// signalKeys wakes up the commands blocked on any of the keys.
+// All the non-exclusive waiters on each key are woken, along with the first exclusive waiter.
+func (server *EchoVault) signalKeys(keys []string) {
+	server.keyWaiters.mutex.Lock()
+	defer server.keyWaiters.mutex.Unlock()
+	for _, key := range keys {
+		for _, w := range server.keyWaiters.waiters[key] {
+			if !w.exclusive {
+				w.signal()
+			}
+		}
+		server.signalExclusiveWaiter(key)
+	}
+}
+
+// signalExclusiveWaiter wakes the first exclusive waiter on the key. The caller must hold the keyWaiters mutex.
+func (server *EchoVault) signalExclusiveWaiter(key string) {
+	for _, w := range server.keyWaiters.waiters[key] {
+		if w.exclusive {
+			w.signal()
+			return
+		}
+	}
+}
+
+// waitOnKeys returns the Wait function of the handler params of a command run with the context.
+func waitOnKeys(ctx context.Context) func(notify <-chan struct{}, deadline <-chan time.Time) bool {
+	return func(notify <-chan struct{}, deadline <-chan time.Time) bool {
+		select {
+		case <-notify:
+			return true
+		case <-deadline:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// getBlockingHandlerFuncParams returns the handler params for a blocking write command.
+// The command runs in the write section like other write commands, so each attempt to read and modify its keys
+// is complete before a state view can be opened. As these commands can wait indefinitely, they leave the write
+// section while they wait, so that state views are not held up.
+func (server *EchoVault) getBlockingHandlerFuncParams(ctx context.Context, cmd []string, conn *net.Conn) internal.HandlerFuncParams {
+	params := server.getWriteHandlerFuncParams(ctx, cmd, conn)
+	wait := params.Wait
+	params.Wait = func(notify <-chan struct{}, deadline <-chan time.Time) bool {
+		server.stateViews.writes.RUnlock()
+		defer server.stateViews.writes.RLock()
+		return wait(notify, deadline)
+	}
+	return params
+}
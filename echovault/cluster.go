@@ -17,16 +17,39 @@ package echovault
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/forward"
+	"github.com/echovault/echovault/internal/memberlist"
+	"github.com/echovault/echovault/internal/raft"
+	"github.com/sethvargo/go-retry"
+	"net"
+	"strconv"
+	"sync"
 	"time"
 )
 
+const (
+	readIndexTimeout  = 5 * time.Second  // The maximum time to wait for the log to be applied before a leader read.
+	forwardTimeout    = 5 * time.Second  // The maximum time to wait for the leader's response to a forwarded command.
+	forwardRetryLimit = 10 * time.Second // The maximum time to retry a forwarded command while the leader is changing.
+	statsTimeout      = time.Second      // The maximum time to wait for the raft stats of another node.
+)
+
 func (server *EchoVault) isInCluster() bool {
 	return server.config.BootstrapCluster || server.config.JoinAddr != ""
 }
 
-func (server *EchoVault) raftApplyDeleteKey(ctx context.Context, key string) error {
+// forwardEnabled returns whether the node runs the forward channel, which needs the cluster secret.
+func (server *EchoVault) forwardEnabled() bool {
+	return server.config.ClusterSecret != ""
+}
+
+// raftApplyDeleteKey deletes the key from every node in the cluster. The event is the keyspace event that is
+// published when the key is deleted: del, expired or evicted.
+func (server *EchoVault) raftApplyDeleteKey(ctx context.Context, key string, event string) error {
 	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
 
 	deleteKeyRequest := internal.ApplyRequest{
@@ -34,6 +57,7 @@ func (server *EchoVault) raftApplyDeleteKey(ctx context.Context, key string) err
 		ServerID:     serverId,
 		ConnectionID: "nil",
 		Key:          key,
+		Event:        event,
 	}
 
 	b, err := json.Marshal(deleteKeyRequest)
@@ -63,12 +87,18 @@ func (server *EchoVault) raftApplyDeleteKey(ctx context.Context, key string) err
 func (server *EchoVault) raftApplyCommand(ctx context.Context, cmd []string) ([]byte, error) {
 	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
 	connectionId, _ := ctx.Value(internal.ContextConnID("ConnectionID")).(string)
+	protocol, ok := ctx.Value(internal.ContextProtocol("Protocol")).(int)
+	if !ok {
+		protocol = constants.RESP2Protocol
+	}
 
 	applyRequest := internal.ApplyRequest{
 		Type:         "command",
 		ServerID:     serverId,
 		ConnectionID: connectionId,
 		CMD:          cmd,
+		Protocol:     protocol,
+		Timestamp:    server.clock.Now().UnixNano(),
 	}
 
 	b, err := json.Marshal(applyRequest)
@@ -94,3 +124,525 @@ func (server *EchoVault) raftApplyCommand(ctx context.Context, cmd []string) ([]
 
 	return r.Response, nil
 }
+
+// raftApplyDeleteKeyIf deletes the key from every node in the cluster if its version is still the given version
+// when the request is applied. It returns false if the key was modified and therefore not deleted.
+func (server *EchoVault) raftApplyDeleteKeyIf(ctx context.Context, key string, version uint64) (bool, error) {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+
+	deleteKeyRequest := internal.ApplyRequest{
+		Type:         "delete-key-if",
+		ServerID:     serverId,
+		ConnectionID: "nil",
+		Key:          key,
+		Version:      version,
+	}
+
+	b, err := json.Marshal(deleteKeyRequest)
+	if err != nil {
+		return false, fmt.Errorf("could not parse delete key request for key: %s", key)
+	}
+
+	applyFuture := server.raft.Apply(b, 500*time.Millisecond)
+
+	if err = applyFuture.Error(); err != nil {
+		return false, err
+	}
+
+	r, ok := applyFuture.Response().(internal.ApplyResponse)
+
+	if !ok {
+		return false, fmt.Errorf("unprocessable entity %v", r)
+	}
+
+	if r.Error != nil {
+		return false, r.Error
+	}
+
+	return r.Response != nil, nil
+}
+
+// raftApplyTransaction replicates the transaction. The response is nil if any of the watched keys were modified
+// when the transaction was applied.
+func (server *EchoVault) raftApplyTransaction(ctx context.Context, commands [][]string, watched map[string]uint64) ([]byte, error) {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+	connectionId, _ := ctx.Value(internal.ContextConnID("ConnectionID")).(string)
+
+	applyRequest := internal.ApplyRequest{
+		Type:         "transaction",
+		ServerID:     serverId,
+		ConnectionID: connectionId,
+		Transaction:  commands,
+		Watched:      watched,
+		Timestamp:    server.clock.Now().UnixNano(),
+	}
+
+	b, err := json.Marshal(applyRequest)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse transaction request for commands: %+v", commands)
+	}
+
+	applyFuture := server.raft.Apply(b, 500*time.Millisecond)
+
+	if err = applyFuture.Error(); err != nil {
+		return nil, err
+	}
+
+	r, ok := applyFuture.Response().(internal.ApplyResponse)
+
+	if !ok {
+		return nil, fmt.Errorf("unprocessable entity %v", r)
+	}
+
+	if r.Error != nil {
+		return nil, r.Error
+	}
+
+	return r.Response, nil
+}
+
+func (server *EchoVault) raftApplyScript(ctx context.Context, cmd []string) ([]byte, error) {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+	connectionId, _ := ctx.Value(internal.ContextConnID("ConnectionID")).(string)
+	protocol, ok := ctx.Value(internal.ContextProtocol("Protocol")).(int)
+	if !ok {
+		protocol = constants.RESP2Protocol
+	}
+
+	applyRequest := internal.ApplyRequest{
+		Type:         "script",
+		ServerID:     serverId,
+		ConnectionID: connectionId,
+		CMD:          cmd,
+		Protocol:     protocol,
+		Timestamp:    server.clock.Now().UnixNano(),
+	}
+
+	b, err := json.Marshal(applyRequest)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse script request for command: %+v", cmd)
+	}
+
+	applyFuture := server.raft.Apply(b, 500*time.Millisecond)
+
+	if err = applyFuture.Error(); err != nil {
+		return nil, err
+	}
+
+	r, ok := applyFuture.Response().(internal.ApplyResponse)
+
+	if !ok {
+		return nil, fmt.Errorf("unprocessable entity %v", r)
+	}
+
+	if r.Error != nil {
+		return nil, r.Error
+	}
+
+	return r.Response, nil
+}
+
+// newForwardRequest returns a forward request of the given type for the client connection in the context.
+func newForwardRequest(ctx context.Context, requestType string) forward.Request {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+	connectionId, _ := ctx.Value(internal.ContextConnID("ConnectionID")).(string)
+	protocol, ok := ctx.Value(internal.ContextProtocol("Protocol")).(int)
+	if !ok {
+		protocol = constants.RESP2Protocol
+	}
+	user, _ := ctx.Value(internal.ContextUser("User")).(string)
+
+	return forward.Request{
+		Type:         requestType,
+		ServerID:     serverId,
+		ConnectionID: connectionId,
+		Protocol:     protocol,
+		User:         user,
+	}
+}
+
+// forwardCommand forwards the write command to the leader and returns the leader's response.
+func (server *EchoVault) forwardCommand(ctx context.Context, cmd []string) ([]byte, error) {
+	request := newForwardRequest(ctx, forward.TypeCommand)
+	request.CMD = cmd
+	return server.forwardToLeader(ctx, request)
+}
+
+// forwardTransaction forwards the transaction to the leader and returns the leader's response.
+// The response is nil if any of the watched keys were modified when the leader applied the transaction.
+func (server *EchoVault) forwardTransaction(ctx context.Context, commands [][]string, watched map[string]uint64) ([]byte, error) {
+	request := newForwardRequest(ctx, forward.TypeTransaction)
+	request.Transaction = commands
+	request.Watched = watched
+	return server.forwardToLeader(ctx, request)
+}
+
+// forwardScript forwards the EVAL command of a script to the leader and returns the leader's response.
+func (server *EchoVault) forwardScript(ctx context.Context, cmd []string) ([]byte, error) {
+	request := newForwardRequest(ctx, forward.TypeScript)
+	request.CMD = cmd
+	return server.forwardToLeader(ctx, request)
+}
+
+// forwardToLeader sends the request to the leader and returns the leader's response.
+// The request is retried while there's no leader or the leader is changing, as long as it was not delivered
+// to a leader. A request that reached the leader is never retried, so that it's not applied twice.
+func (server *EchoVault) forwardToLeader(ctx context.Context, request forward.Request) ([]byte, error) {
+	if !server.forwardEnabled() {
+		return nil, errors.New("the forward channel is disabled because no cluster secret is set")
+	}
+	var response forward.Response
+	backoffPolicy := internal.RetryBackoff(retry.NewFibonacci(50*time.Millisecond), 0, 0, time.Second, forwardRetryLimit)
+	err := retry.Do(ctx, backoffPolicy, func(ctx context.Context) error {
+		addr, err := server.leaderForwardAddr()
+		if err != nil {
+			return retry.RetryableError(err)
+		}
+		response, err = server.forwardClient.Forward(ctx, addr, request)
+		if errors.Is(err, forward.ErrNotDelivered) {
+			return retry.RetryableError(err)
+		}
+		if err != nil {
+			return fmt.Errorf("forward command to leader: %v", err)
+		}
+		if response.NotLeader {
+			return retry.RetryableError(fmt.Errorf("%s is no longer the cluster leader", addr))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	return response.Response, nil
+}
+
+// leaderForwardAddr returns the address of the leader's forward listener.
+func (server *EchoVault) leaderForwardAddr() (string, error) {
+	_, leaderId := server.raft.Leader()
+	if leaderId == "" {
+		return "", errors.New("no cluster leader")
+	}
+	meta, err := server.memberList.GetNodeMeta(leaderId)
+	if err != nil {
+		return "", err
+	}
+	if meta.ForwardAddr == "" {
+		return "", fmt.Errorf("cluster leader %s has no forward address", leaderId)
+	}
+	return meta.ForwardAddr, nil
+}
+
+// handleForwardedCommand applies a command, transaction or script forwarded by a follower, or a key migrated from
+// another shard, and returns its response. It also serves the leave requests of followers and the stats requests of
+// other nodes.
+func (server *EchoVault) handleForwardedCommand(ctx context.Context, request forward.Request) ([]byte, error) {
+	ctx = context.WithValue(ctx, internal.ContextServerID("ServerID"), request.ServerID)
+	ctx = context.WithValue(ctx, internal.ContextConnID("ConnectionID"), request.ConnectionID)
+	ctx = context.WithValue(ctx, internal.ContextProtocol("Protocol"), request.Protocol)
+
+	var res []byte
+	var err error
+	switch request.Type {
+	case forward.TypeTransaction:
+		if err = server.authorizeForwardedTransaction(request.User, request.Transaction); err == nil {
+			res, err = server.raftApplyTransaction(ctx, request.Transaction, request.Watched)
+		}
+	case forward.TypeScript:
+		if err = server.authorizeForwarded(request.User, request.CMD); err == nil {
+			res, err = server.raftApplyScript(ctx, request.CMD)
+		}
+	case forward.TypeRestoreKey:
+		res, err = server.handleRestoreKey(ctx, request)
+	case forward.TypeLeave:
+		if err = server.authorizeLeave(request); err == nil {
+			err = server.removeClusterNode(request.ServerID)
+		}
+	case forward.TypeStats:
+		res, err = json.Marshal(server.raft.Stats())
+	case forward.TypeSlots:
+		res, err = json.Marshal(server.getSlotState())
+	default:
+		if err = server.authorizeForwarded(request.User, request.CMD); err == nil {
+			res, err = server.raftApplyCommand(ctx, request.CMD)
+		}
+	}
+	if errors.Is(err, raft.ErrNotLeader) {
+		// The node lost the leadership before the command was applied, so the follower can retry it.
+		return nil, forward.ErrNotLeader
+	}
+	return res, err
+}
+
+// authorizeForwarded checks the ACL permissions of the user that ran the forwarded command on another node.
+// Commands run with the embedded API have no user and are not authorized, like on the node that forwarded them.
+func (server *EchoVault) authorizeForwarded(user string, cmd []string) error {
+	if user == "" || server.acl == nil || len(cmd) == 0 {
+		return nil
+	}
+	command, err := server.getCommand(cmd[0])
+	if err != nil {
+		return err
+	}
+	sc, err := internal.GetSubCommand(command, cmd)
+	if err != nil {
+		return err
+	}
+	subCommand, _ := sc.(internal.SubCommand)
+	return server.acl.AuthorizeUser(user, cmd, command, subCommand)
+}
+
+// authorizeForwardedTransaction checks the ACL permissions of the user for each of the transaction's commands.
+func (server *EchoVault) authorizeForwardedTransaction(user string, commands [][]string) error {
+	for _, cmd := range commands {
+		if err := server.authorizeForwarded(user, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeLeave checks that the leave request was sent from the host of the node that leaves,
+// or on behalf of a user that is allowed to remove nodes with CLUSTER REMOVE.
+func (server *EchoVault) authorizeLeave(request forward.Request) error {
+	servers, err := server.raft.Servers()
+	if err != nil {
+		return err
+	}
+	var nodeAddr string
+	for _, s := range servers {
+		if string(s.ID) == request.ServerID {
+			nodeAddr = string(s.Address)
+		}
+	}
+	if nodeAddr == "" {
+		return fmt.Errorf("node %s is not a member of the cluster", request.ServerID)
+	}
+
+	nodeHost, _, _ := net.SplitHostPort(nodeAddr)
+	remoteHost, _, _ := net.SplitHostPort(request.RemoteAddr)
+	if nodeHost != "" && nodeHost == remoteHost {
+		return nil
+	}
+	if request.User != "" {
+		return server.authorizeForwarded(request.User, []string{"CLUSTER", "REMOVE", request.ServerID})
+	}
+	return fmt.Errorf("leave request for node %s was not sent by the node", request.ServerID)
+}
+
+// verifyReadConsistency returns an error if this node can't serve a read with the consistency.
+func (server *EchoVault) verifyReadConsistency(ctx context.Context, consistency internal.ReadConsistency) error {
+	switch consistency.Mode {
+	case constants.ReadConsistencyLeader:
+		ctx, cancel := context.WithTimeout(ctx, readIndexTimeout)
+		defer cancel()
+		if err := server.raft.VerifyLeader(ctx); err != nil {
+			return fmt.Errorf("cannot serve read with %s consistency: %v", consistency.Mode, err)
+		}
+	case constants.ReadConsistencyLease:
+		ctx, cancel := context.WithTimeout(ctx, readIndexTimeout)
+		defer cancel()
+		if err := server.raft.VerifyLease(ctx); err != nil {
+			return fmt.Errorf("cannot serve read with %s consistency: %v", consistency.Mode, err)
+		}
+	default:
+		if consistency.MaxLag <= 0 {
+			return nil
+		}
+		lag, ok := server.raft.ReplicationLag()
+		if !ok {
+			return errors.New("cannot serve stale read: no contact with the cluster leader")
+		}
+		if lag > consistency.MaxLag {
+			return fmt.Errorf("cannot serve stale read: replication lag of %s exceeds the max lag of %s",
+				lag.Round(time.Millisecond), consistency.MaxLag)
+		}
+	}
+	return nil
+}
+
+// getClusterInfo returns the state of the node's raft group, followed by the raft stats of the node.
+func (server *EchoVault) getClusterInfo() (map[string]string, error) {
+	if !server.isInCluster() {
+		return nil, errors.New("cluster support disabled")
+	}
+
+	info := server.raft.Stats()
+	leaderAddr, leaderId := server.raft.Leader()
+	info["cluster_state"] = "ok"
+	if leaderId == "" {
+		info["cluster_state"] = "fail"
+	}
+	info["server_id"] = server.config.ServerID
+	info["shard_id"] = server.config.ShardID
+	info["leader_id"] = string(leaderId)
+	info["leader_addr"] = string(leaderAddr)
+	return info, nil
+}
+
+// getClusterNodes returns the members of the node's raft group. The last contact and the indexes of the other
+// members are collected from each of them, so they're reported as unknown for the members that can't be reached.
+func (server *EchoVault) getClusterNodes(ctx context.Context) ([]internal.RaftNode, error) {
+	if !server.isInCluster() {
+		return nil, errors.New("cluster support disabled")
+	}
+
+	servers, err := server.raft.Servers()
+	if err != nil {
+		return nil, err
+	}
+	_, leaderId := server.raft.Leader()
+	members := make(map[string]memberlist.NodeMeta)
+	for _, meta := range server.memberList.Members() {
+		members[string(meta.ServerID)] = meta
+	}
+
+	nodes := make([]internal.RaftNode, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		nodes[i] = internal.RaftNode{
+			ID:           string(s.ID),
+			RaftAddr:     string(s.Address),
+			Role:         "follower",
+			Myself:       string(s.ID) == server.config.ServerID,
+			LastContact:  -1,
+			CommitIndex:  -1,
+			AppliedIndex: -1,
+		}
+		switch {
+		case s.Suffrage != raft.Voter:
+			nodes[i].Role = "learner"
+		case s.ID == leaderId:
+			nodes[i].Role = "leader"
+		}
+
+		meta, ok := members[nodes[i].ID]
+		if ok {
+			nodes[i].MemberlistAddr = meta.MemberlistAddr
+		}
+		if nodes[i].Myself {
+			setRaftNodeStats(&nodes[i], server.raft.Stats())
+			continue
+		}
+		if !ok || meta.ForwardAddr == "" || !server.forwardEnabled() {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node *internal.RaftNode, addr string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, statsTimeout)
+			defer cancel()
+			response, err := server.forwardClient.Forward(ctx, addr, forward.Request{Type: forward.TypeStats})
+			if err != nil || response.Error != "" {
+				return
+			}
+			var stats map[string]string
+			if err = json.Unmarshal(response.Response, &stats); err == nil {
+				setRaftNodeStats(node, stats)
+			}
+		}(&nodes[i], meta.ForwardAddr)
+	}
+	wg.Wait()
+
+	return nodes, nil
+}
+
+// setRaftNodeStats sets the last contact and the indexes of the node from its raft stats.
+func setRaftNodeStats(node *internal.RaftNode, stats map[string]string) {
+	switch lastContact := stats["last_contact"]; lastContact {
+	case "0":
+		node.LastContact = 0
+	case "never", "":
+		node.LastContact = -1
+	default:
+		if d, err := time.ParseDuration(lastContact); err == nil {
+			node.LastContact = d.Milliseconds()
+		}
+	}
+	if index, err := strconv.ParseInt(stats["commit_index"], 10, 64); err == nil {
+		node.CommitIndex = index
+	}
+	if index, err := strconv.ParseInt(stats["applied_index"], 10, 64); err == nil {
+		node.AppliedIndex = index
+	}
+}
+
+// leaveCluster removes the node from its raft group, then leaves the memberlist cluster.
+// A follower asks the leader to remove it. The node keeps running, but can no longer serve the cluster's data.
+func (server *EchoVault) leaveCluster(ctx context.Context) error {
+	if !server.isInCluster() {
+		return errors.New("cluster support disabled")
+	}
+
+	var err error
+	if server.raft.IsRaftLeader() {
+		// Raft shuts down on a leader once its own removal is committed.
+		err = server.removeClusterNode(server.config.ServerID)
+	} else {
+		user, _ := ctx.Value(internal.ContextUser("User")).(string)
+		_, err = server.forwardToLeader(ctx, forward.Request{
+			Type:     forward.TypeLeave,
+			ServerID: server.config.ServerID,
+			User:     user,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return server.memberList.Leave()
+}
+
+// removeClusterNode removes the node with the server ID from the raft group. Must be called on the leader.
+func (server *EchoVault) removeClusterNode(id string) error {
+	if !server.isInCluster() {
+		return errors.New("cluster support disabled")
+	}
+	if !server.raft.IsRaftLeader() {
+		return raft.ErrNotLeader
+	}
+
+	servers, err := server.raft.Servers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		if string(s.ID) == id {
+			return server.raft.RemoveServer(memberlist.NodeMeta{ServerID: s.ID, ShardID: server.config.ShardID})
+		}
+	}
+	return fmt.Errorf("node %s is not a member of the cluster", id)
+}
+
+// promoteClusterNode makes the learner with the server ID a voter. Must be called on the leader.
+func (server *EchoVault) promoteClusterNode(id string) error {
+	if !server.isInCluster() {
+		return errors.New("cluster support disabled")
+	}
+	return server.raft.Promote(id)
+}
+
+// transferLeadership transfers the leadership of the raft group to the node with the server ID,
+// or to the most up-to-date voter when the ID is empty.
+func (server *EchoVault) transferLeadership(id string) error {
+	if !server.isInCluster() {
+		return errors.New("cluster support disabled")
+	}
+	return server.raft.TransferLeadership(id)
+}
+
+// takeRaftSnapshot takes a raft snapshot and waits for it to complete. Unlike SAVE, the error of the snapshot
+// is returned to the caller.
+func (server *EchoVault) takeRaftSnapshot() error {
+	if !server.isInCluster() {
+		return errors.New("cluster support disabled")
+	}
+	if server.snapshotInProgress.Load() {
+		return errors.New("snapshot already in progress")
+	}
+	return server.raft.TakeSnapshot()
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"net"
+)
+
+// registerConnection stores the details of a newly accepted TCP connection.
+// All connections start out speaking RESP2 until they negotiate a different version with HELLO.
+func (server *EchoVault) registerConnection(conn *net.Conn, id uint64) {
+	server.connInfo.mutex.Lock()
+	defer server.connInfo.mutex.Unlock()
+	server.connInfo.clients[conn] = internal.ConnectionInfo{
+		Id:              id,
+		Name:            "",
+		Protocol:        constants.RESP2Protocol,
+		ReadConsistency: server.defaultReadConsistency(),
+	}
+}
+
+func (server *EchoVault) unregisterConnection(conn *net.Conn) {
+	server.connInfo.mutex.Lock()
+	defer server.connInfo.mutex.Unlock()
+	delete(server.connInfo.clients, conn)
+}
+
+// getConnectionInfo returns the details of the connection.
+// Connections that are not registered (e.g. embedded calls) always use RESP2.
+func (server *EchoVault) getConnectionInfo(conn *net.Conn) internal.ConnectionInfo {
+	server.connInfo.mutex.RLock()
+	defer server.connInfo.mutex.RUnlock()
+	if info, ok := server.connInfo.clients[conn]; ok {
+		return info
+	}
+	return internal.ConnectionInfo{Protocol: constants.RESP2Protocol}
+}
+
+func (server *EchoVault) setConnectionInfo(conn *net.Conn, clientname string, protocol int) {
+	server.connInfo.mutex.Lock()
+	defer server.connInfo.mutex.Unlock()
+	info, ok := server.connInfo.clients[conn]
+	if !ok {
+		return
+	}
+	info.Name = clientname
+	info.Protocol = protocol
+	server.connInfo.clients[conn] = info
+}
+
+// setReadConsistency sets the read consistency of the connection, or of embedded calls if the connection is nil.
+func (server *EchoVault) setReadConsistency(conn *net.Conn, consistency internal.ReadConsistency) {
+	server.connInfo.mutex.Lock()
+	defer server.connInfo.mutex.Unlock()
+	if conn == nil {
+		server.connInfo.embeddedReadConsistency = &consistency
+		return
+	}
+	info, ok := server.connInfo.clients[conn]
+	if !ok {
+		return
+	}
+	info.ReadConsistency = consistency
+	server.connInfo.clients[conn] = info
+}
+
+// getReadConsistency returns the read consistency of the command. The consistency passed with the context
+// takes precedence over the consistency of the connection.
+func (server *EchoVault) getReadConsistency(ctx context.Context, conn *net.Conn) internal.ReadConsistency {
+	if consistency, ok := ctx.Value(internal.ContextReadConsistency("ReadConsistency")).(internal.ReadConsistency); ok {
+		return consistency
+	}
+	server.connInfo.mutex.RLock()
+	defer server.connInfo.mutex.RUnlock()
+	if info, ok := server.connInfo.clients[conn]; ok {
+		return info.ReadConsistency
+	}
+	if conn == nil && server.connInfo.embeddedReadConsistency != nil {
+		return *server.connInfo.embeddedReadConsistency
+	}
+	return server.defaultReadConsistency()
+}
+
+// asking flags the connection's next command as allowed to access a slot that is being imported.
+func (server *EchoVault) asking(conn *net.Conn) error {
+	if conn == nil {
+		return errors.New("ASKING requires a client connection")
+	}
+	server.connInfo.mutex.Lock()
+	defer server.connInfo.mutex.Unlock()
+	info, ok := server.connInfo.clients[conn]
+	if !ok {
+		return errors.New("ASKING requires a client connection")
+	}
+	info.Asking = true
+	server.connInfo.clients[conn] = info
+	return nil
+}
+
+// takeAsking returns true if ASKING was called before the connection's current command, and clears the flag.
+func (server *EchoVault) takeAsking(conn *net.Conn) bool {
+	if conn == nil {
+		return false
+	}
+	server.connInfo.mutex.Lock()
+	defer server.connInfo.mutex.Unlock()
+	info, ok := server.connInfo.clients[conn]
+	if !ok || !info.Asking {
+		return false
+	}
+	info.Asking = false
+	server.connInfo.clients[conn] = info
+	return true
+}
+
+// defaultReadConsistency returns the read consistency from the config.
+func (server *EchoVault) defaultReadConsistency() internal.ReadConsistency {
+	return internal.ReadConsistency{
+		Mode:   server.config.ReadConsistency,
+		MaxLag: server.config.MaxReadLag,
+	}
+}
+
+func (server *EchoVault) getServerInfo() internal.ServerInfo {
+	mode, role := "standalone", "master"
+	if server.isInCluster() {
+		mode = "cluster"
+		if !server.raft.IsRaftLeader() {
+			role = "replica"
+		}
+	}
+	return internal.ServerInfo{
+		Server:  "echovault",
+		Version: constants.Version,
+		Id:      server.config.ServerID,
+		Mode:    mode,
+		Role:    role,
+		Modules: server.ListModules(),
+	}
+}
+
+// toRESP3Null replaces a RESP2 null bulk string or null array response with the RESP3 null type.
+func toRESP3Null(res []byte) []byte {
+	if bytes.Equal(res, []byte("$-1\r\n")) || bytes.Equal(res, []byte("*-1\r\n")) {
+		return []byte(constants.NullResponse)
+	}
+	return res
+}
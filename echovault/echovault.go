@@ -25,19 +25,25 @@ import (
 	"github.com/echovault/echovault/internal/clock"
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/constants"
-	"github.com/echovault/echovault/internal/eviction"
+	"github.com/echovault/echovault/internal/forward"
 	"github.com/echovault/echovault/internal/memberlist"
 	"github.com/echovault/echovault/internal/modules/acl"
 	"github.com/echovault/echovault/internal/modules/admin"
+	"github.com/echovault/echovault/internal/modules/cluster"
 	"github.com/echovault/echovault/internal/modules/connection"
 	"github.com/echovault/echovault/internal/modules/generic"
 	"github.com/echovault/echovault/internal/modules/hash"
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
 	"github.com/echovault/echovault/internal/modules/list"
 	"github.com/echovault/echovault/internal/modules/pubsub"
+	"github.com/echovault/echovault/internal/modules/scripting"
 	"github.com/echovault/echovault/internal/modules/set"
 	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
 	str "github.com/echovault/echovault/internal/modules/string"
+	"github.com/echovault/echovault/internal/modules/transaction"
 	"github.com/echovault/echovault/internal/raft"
+	"github.com/echovault/echovault/internal/slots"
 	"github.com/echovault/echovault/internal/snapshot"
 	"io"
 	"log"
@@ -48,6 +54,9 @@ import (
 	"time"
 )
 
+// tlsHandshakeTimeout is the maximum time a client has to complete the TLS handshake once it has connected.
+var tlsHandshakeTimeout = 10 * time.Second
+
 type EchoVault struct {
 	// clock is an implementation of a time interface that allows mocking of time functions during testing.
 	clock clock.Clock
@@ -60,23 +69,66 @@ type EchoVault struct {
 	// the new number is the new connection's ID.
 	connId atomic.Uint64
 
-	storeLock *sync.RWMutex               // Global read-write mutex for entire store.
-	store     map[string]internal.KeyData // Data store to hold the keys and their associated data, expiry time, etc.
+	// The keyspace is partitioned into shards, each with its own lock, so that commands on keys in different
+	// shards don't contend with each other.
+	shards [shardCount]*shard
+
+	// Tracks the estimated memory used by the keys in the store. Max-memory decisions are based on the total.
+	memoryUsage struct {
+		total       atomic.Int64  // The estimated size of all the keys.
+		peak        atomic.Int64  // The highest total since the instance started.
+		evictedKeys atomic.Uint64 // The number of keys evicted to stay under max-memory.
+	}
 
-	// Holds all the keys that are currently associated with an expiry.
-	keysWithExpiry struct {
-		rwMutex sync.RWMutex // Mutex as only one process should be able to update this list at a time.
-		keys    []string     // string slice of the volatile keys
+	// Tracks the deletion of keys whose expiry time has passed.
+	expiryStats struct {
+		expiredKeys       atomic.Uint64 // The number of expired keys deleted, either when accessed or by the active expiry cycle.
+		activeExpiredKeys atomic.Uint64 // The number of expired keys deleted by the active expiry cycle.
+		cycles            atomic.Uint64 // The number of active expiry cycles that have run.
+		cyclesTimeLimit   atomic.Uint64 // The number of active expiry cycles that stopped because they ran out of time.
+		nextShard         atomic.Int64  // The shard that the next active expiry cycle starts from.
+	}
+
+	// Holds the transaction state of each connection that has called MULTI or WATCH.
+	transactions struct {
+		mutex       sync.Mutex                      // Mutex as only one goroutine can edit the map at a time.
+		connections map[*net.Conn]*transactionState // Map of connections to their transaction state.
+	}
+	// Holds the details of each TCP client connection, such as the negotiated RESP protocol version.
+	connInfo struct {
+		mutex   sync.RWMutex                          // RWMutex for concurrency control when accessing the connection details.
+		clients map[*net.Conn]internal.ConnectionInfo // Map of connections to their details.
+		// The read consistency of embedded calls set with SetReadConsistency. The config's is used when it's nil.
+		embeddedReadConsistency *internal.ReadConsistency
+	}
+	// Holds the commands that are blocked waiting for keys to be modified (e.g. XREAD BLOCK).
+	keyWaiters struct {
+		mutex   sync.Mutex              // Mutex as only one goroutine can edit the map at a time.
+		waiters map[string][]*keyWaiter // Map of keys to the waiters blocked on the key, in the order they blocked.
 	}
-	// LFU cache used when eviction policy is allkeys-lfu or volatile-lfu.
-	lfuCache struct {
-		mutex sync.Mutex        // Mutex as only one goroutine can edit the LFU cache at a time.
-		cache eviction.CacheLFU // LFU cache represented by a min head.
+	// Holds the compiled Lua scripts that have been loaded with EVAL or SCRIPT LOAD.
+	scripts struct {
+		mutex sync.RWMutex       // RWMutex as scripts are looked up far more often than they're loaded.
+		cache map[string]*script // Map of the SHA1 digest of each script to the compiled script.
+		// The script that's currently running, if any. Scripts hold the locks of all the shards, so only one
+		// runs at a time.
+		running atomic.Pointer[scriptRun]
 	}
-	// LRU cache used when eviction policy is allkeys-lru or volatile-lru.
-	lruCache struct {
-		mutex sync.Mutex        // Mutex as only one goroutine can edit the LRU at a time.
-		cache eviction.CacheLRU // LRU cache represented by a max head.
+	// Holds the versions of the keys that are currently watched by at least one transaction.
+	keyVersions struct {
+		mutex    sync.Mutex             // Mutex as the versions are updated whenever a key is modified.
+		versions map[string]*keyVersion // Map of the watched keys to their versions.
+	}
+	// The index of the raft log entry being applied. Keys modified in cluster mode are stamped with it.
+	appliedIndex atomic.Uint64
+
+	// Holds the copy-on-write views of the keyspace that are open for snapshots and AOF rewrites.
+	stateViews struct {
+		// Write commands hold the read lock while they run. Opening a view acquires the write lock,
+		// so that a view is never opened in the middle of a write command.
+		writes sync.RWMutex
+		mutex  sync.Mutex                   // Mutex as only one goroutine can add or remove a view at a time.
+		open   atomic.Pointer[[]*stateView] // The open views. The slice is replaced when a view is added or removed.
 	}
 
 	// Holds the list of all commands supported by the echovault.
@@ -86,22 +138,40 @@ type EchoVault struct {
 	raft       *raft.Raft             // The raft replication layer for the echovault.
 	memberList *memberlist.MemberList // The memberlist layer for the echovault.
 
+	// Holds the slot state of the node's shard in cluster mode.
+	slotState struct {
+		mutex   sync.RWMutex
+		state   slots.State
+		changed chan struct{} // Signals that the state changed and must be advertised to the other shards.
+		stop    chan struct{} // Signals the goroutine that advertises the node's metadata to stop.
+	}
+
+	// Caches the slot states of the other shards in cluster mode, by shard ID. They are fetched from the nodes
+	// of each shard, which only advertise the epoch and digest of their slot state.
+	remoteSlots struct {
+		mutex  sync.RWMutex
+		states map[string]slots.State
+	}
+
+	forwardServer *forward.Server // Serves the commands forwarded by followers while this node is the leader.
+	forwardClient *forward.Client // Forwards the write commands received by this node to the leader.
+
 	context context.Context
 
 	acl    *acl.ACL
 	pubSub *pubsub.PubSub
 
+	keyspaceEvents internal.KeyspaceEvents // The keyspace notifications enabled by the notify-keyspace-events config.
+
 	snapshotInProgress         atomic.Bool      // Atomic boolean that's true when actively taking a snapshot.
 	rewriteAOFInProgress       atomic.Bool      // Atomic boolean that's true when actively rewriting AOF file is in progress.
-	stateCopyInProgress        atomic.Bool      // Atomic boolean that's true when actively copying state for snapshotting or preamble generation.
-	stateMutationInProgress    atomic.Bool      // Atomic boolean that is set to true when state mutation is in progress.
 	latestSnapshotMilliseconds atomic.Int64     // Unix epoch in milliseconds.
 	snapshotEngine             *snapshot.Engine // Snapshot engine for standalone mode.
 	aofEngine                  *aof.Engine      // AOF engine for standalone mode.
 
 	listener atomic.Value  // Holds the TCP listener.
 	quit     chan struct{} // Channel that signals the closing of all client connections.
-	stopTTL  chan struct{} // Channel that signals the TTL sampling goroutine to stop execution.
+	stopTTL  chan struct{} // Channel that signals the active expiry goroutine to stop execution.
 }
 
 // WithContext is an options that for the NewEchoVault function that allows you to
@@ -129,27 +199,39 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 		clock:         clock.NewClock(),
 		context:       context.Background(),
 		config:        config.DefaultConfig(),
-		storeLock:     &sync.RWMutex{},
-		store:         make(map[string]internal.KeyData),
 		commandsRWMut: sync.RWMutex{},
 		commands: func() []internal.Command {
 			var commands []internal.Command
 			commands = append(commands, acl.Commands()...)
 			commands = append(commands, admin.Commands()...)
+			commands = append(commands, cluster.Commands()...)
 			commands = append(commands, connection.Commands()...)
 			commands = append(commands, generic.Commands()...)
 			commands = append(commands, hash.Commands()...)
+			commands = append(commands, hyperloglog.Commands()...)
 			commands = append(commands, list.Commands()...)
 			commands = append(commands, pubsub.Commands()...)
+			commands = append(commands, scripting.Commands()...)
 			commands = append(commands, set.Commands()...)
 			commands = append(commands, sorted_set.Commands()...)
+			commands = append(commands, stream.Commands()...)
 			commands = append(commands, str.Commands()...)
+			commands = append(commands, transaction.Commands()...)
 			return commands
 		}(),
 		quit:    make(chan struct{}),
 		stopTTL: make(chan struct{}),
 	}
 
+	echovault.transactions.connections = make(map[*net.Conn]*transactionState)
+	echovault.connInfo.clients = make(map[*net.Conn]internal.ConnectionInfo)
+	echovault.keyWaiters.waiters = make(map[string][]*keyWaiter)
+	echovault.scripts.cache = make(map[string]*script)
+	echovault.keyVersions.versions = make(map[string]*keyVersion)
+	for i := range echovault.shards {
+		echovault.shards[i] = newShard()
+	}
+
 	for _, option := range options {
 		option(echovault)
 	}
@@ -174,6 +256,37 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 	// Set up Pub/Sub module
 	echovault.pubSub = pubsub.NewPubSub()
 
+	keyspaceEvents, err := internal.ParseKeyspaceEvents(echovault.config.NotifyKeyspaceEvents)
+	if err != nil {
+		return nil, err
+	}
+	echovault.keyspaceEvents = keyspaceEvents
+
+	// A learner is never elected, so it can't bootstrap a cluster.
+	if echovault.config.RaftLearner && echovault.config.BootstrapCluster {
+		return nil, errors.New("a raft learner cannot bootstrap the cluster")
+	}
+
+	// The nodes authenticate each other with the cluster secret before they send requests over the forward channel.
+	// Clusters created before the secret was introduced keep working without it, as long as they don't use the
+	// features that need the channel.
+	if echovault.isInCluster() && !echovault.forwardEnabled() {
+		if echovault.config.ForwardCommand || echovault.config.ShardID != "" {
+			return nil, errors.New("cluster secret is required to forward commands or to run a sharded cluster")
+		}
+		log.Println("WARNING: no cluster secret is set, so the forward channel is disabled. " +
+			"Followers can't leave the cluster with CLUSTER LEAVE, and CLUSTER NODES only reports the raft stats " +
+			"of this node. Set the same cluster secret on every node to enable them.")
+	}
+
+	// The slot state from the config is replaced by the one in the raft log once it has been changed.
+	if echovault.slotState.state, err = initialSlotState(echovault.config.ClusterSlots); err != nil {
+		return nil, err
+	}
+	echovault.slotState.changed = make(chan struct{}, 1)
+	echovault.slotState.stop = make(chan struct{})
+	echovault.remoteSlots.states = make(map[string]slots.State)
+
 	if echovault.isInCluster() {
 		echovault.raft = raft.NewRaft(raft.Opts{
 			Config:                echovault.config,
@@ -183,31 +296,43 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 			StartSnapshot:         echovault.startSnapshot,
 			FinishSnapshot:        echovault.finishSnapshot,
 			SetLatestSnapshotTime: echovault.setLatestSnapshot,
-			GetHandlerFuncParams:  echovault.getHandlerFuncParams,
-			DeleteKey: func(key string) error {
-				echovault.storeLock.Lock()
-				defer echovault.storeLock.Unlock()
-				return echovault.deleteKey(key)
-			},
-			GetState: func() map[string]internal.KeyData {
-				state := make(map[string]internal.KeyData)
-				for k, v := range echovault.getState() {
-					if data, ok := v.(internal.KeyData); ok {
-						state[k] = data
-					}
-				}
-				return state
+			GetHandlerFuncParams:  echovault.getWriteHandlerFuncParams,
+			ExecTransaction:       echovault.execReplicatedTransaction,
+			ExecScript:            echovault.execReplicatedScript,
+			KeysModified:          echovault.keysModified,
+			GetSlotState:          echovault.getSlotState,
+			SetSlotState:          echovault.setSlotState,
+			RestoreKey:            echovault.restoreKey,
+			SetAppliedIndex:       echovault.appliedIndex.Store,
+			DeleteKey: func(key string, event string) error {
+				unlock := echovault.lockShards([]string{key})
+				defer unlock()
+				return echovault.deleteKey(key, event)
 			},
+			DeleteKeyIf: echovault.deleteKeyIfVersion,
+			GetState:    echovault.getState,
 		})
 		echovault.memberList = memberlist.NewMemberList(memberlist.Opts{
 			Config:           echovault.config,
 			HasJoinedCluster: echovault.raft.HasJoinedCluster,
 			AddVoter:         echovault.raft.AddVoter,
+			AddNonvoter:      echovault.raft.AddNonvoter,
 			RemoveRaftServer: echovault.raft.RemoveServer,
 			IsRaftLeader:     echovault.raft.IsRaftLeader,
-			ApplyMutate:      echovault.raftApplyCommand,
-			ApplyDeleteKey:   echovault.raftApplyDeleteKey,
+			ApplyDeleteKey: func(ctx context.Context, key string) error {
+				// Followers only forward the deletion of keys they found expired.
+				return echovault.raftApplyDeleteKey(ctx, key, expiredEvent)
+			},
+			GetSlotState: echovault.getSlotState,
 		})
+		if echovault.forwardEnabled() {
+			echovault.forwardServer = forward.NewServer(forward.ServerOpts{
+				IsLeader: echovault.raft.IsRaftLeader,
+				Handle:   echovault.handleForwardedCommand,
+				Secret:   echovault.config.ClusterSecret,
+			})
+			echovault.forwardClient = forward.NewClient(forwardTimeout, echovault.config.ClusterSecret)
+		}
 	} else {
 		// Set up standalone snapshot engine
 		echovault.snapshotEngine = snapshot.NewSnapshotEngine(
@@ -219,15 +344,7 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 			snapshot.WithFinishSnapshotFunc(echovault.finishSnapshot),
 			snapshot.WithSetLatestSnapshotTimeFunc(echovault.setLatestSnapshot),
 			snapshot.WithGetLatestSnapshotTimeFunc(echovault.getLatestSnapshotTime),
-			snapshot.WithGetStateFunc(func() map[string]internal.KeyData {
-				state := make(map[string]internal.KeyData)
-				for k, v := range echovault.getState() {
-					if data, ok := v.(internal.KeyData); ok {
-						state[k] = data
-					}
-				}
-				return state
-			}),
+			snapshot.WithGetStateFunc(echovault.getState),
 			snapshot.WithSetKeyDataFunc(func(key string, data internal.KeyData) {
 				ctx := context.Background()
 				if err := echovault.setValues(ctx, map[string]interface{}{key: data.Value}); err != nil {
@@ -243,15 +360,7 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 			aof.WithStrategy(echovault.config.AOFSyncStrategy),
 			aof.WithStartRewriteFunc(echovault.startRewriteAOF),
 			aof.WithFinishRewriteFunc(echovault.finishRewriteAOF),
-			aof.WithGetStateFunc(func() map[string]internal.KeyData {
-				state := make(map[string]internal.KeyData)
-				for k, v := range echovault.getState() {
-					if data, ok := v.(internal.KeyData); ok {
-						state[k] = data
-					}
-				}
-				return state
-			}),
+			aof.WithGetStateFunc(echovault.getState),
 			aof.WithSetKeyDataFunc(func(key string, value internal.KeyData) {
 				ctx := context.Background()
 				if err := echovault.setValues(ctx, map[string]interface{}{key: value.Value}); err != nil {
@@ -272,34 +381,22 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 		echovault.aofEngine = aofEngine
 	}
 
-	// If eviction policy is not noeviction, start a goroutine to evict keys every 100 milliseconds.
-	if echovault.config.EvictionPolicy != constants.NoEviction {
-		go func() {
-			ticker := time.NewTicker(echovault.config.EvictionInterval)
-			defer func() {
-				ticker.Stop()
-			}()
-			for {
-				select {
-				case <-ticker.C:
-					if err := echovault.evictKeysWithExpiredTTL(context.Background()); err != nil {
-						log.Printf("evict with ttl: %v\n", err)
-					}
-				case <-echovault.stopTTL:
-					break
-				}
-			}
-		}()
-	}
-
 	if echovault.config.TLS && len(echovault.config.CertKeyPairs) <= 0 {
 		return nil, errors.New("must provide certificate and key file paths for TLS mode")
 	}
 
 	if echovault.isInCluster() {
+		// Start the forward listener before joining, so that the node can serve forwarded commands once it's elected.
+		if echovault.forwardEnabled() {
+			forwardAddr := fmt.Sprintf("%s:%d", echovault.config.RaftBindAddr, echovault.config.ForwardBindPort)
+			if err := echovault.forwardServer.Listen(echovault.context, forwardAddr); err != nil {
+				return nil, fmt.Errorf("forward listener: %v", err)
+			}
+		}
 		// Initialise raft and memberlist
 		echovault.raft.RaftInit(echovault.context)
 		echovault.memberList.MemberListInit(echovault.context)
+		go echovault.advertiseNodeMeta()
 		if echovault.raft.IsRaftLeader() {
 			echovault.initialiseCaches()
 		}
@@ -324,6 +421,27 @@ func NewEchoVault(options ...func(echovault *EchoVault)) (*EchoVault, error) {
 		}
 	}
 
+	// Start a goroutine to delete expired keys every eviction interval.
+	// It's started after raft is initialised because the cycle checks whether the node is the raft leader.
+	if echovault.config.EvictionInterval > 0 {
+		ticker := time.NewTicker(echovault.config.EvictionInterval)
+		go func() {
+			defer func() {
+				ticker.Stop()
+			}()
+			for {
+				select {
+				case <-ticker.C:
+					if err := echovault.activeExpireCycle(context.Background()); err != nil {
+						log.Printf("active expire cycle: %v\n", err)
+					}
+				case <-echovault.stopTTL:
+					return
+				}
+			}
+		}()
+	}
+
 	return echovault, nil
 }
 
@@ -415,6 +533,20 @@ func (server *EchoVault) startTCP() {
 }
 
 func (server *EchoVault) handleConnection(conn net.Conn) {
+	// The TLS handshake is completed before the connection is registered, so that the ACL module can
+	// authenticate the connection with its verified client certificate.
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		// Clients that never complete the handshake would otherwise hold the connection open forever.
+		ctx, cancel := context.WithTimeout(server.context, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("tls handshake: %v\n", err)
+			_ = conn.Close()
+			return
+		}
+	}
+
 	// If ACL module is loaded, register the connection with the ACL
 	if server.acl != nil {
 		server.acl.RegisterConnection(&conn)
@@ -423,41 +555,67 @@ func (server *EchoVault) handleConnection(conn net.Conn) {
 	w, r := io.Writer(conn), io.Reader(conn)
 
 	cid := server.connId.Add(1)
-	ctx := context.WithValue(server.context, internal.ContextConnID("ConnectionID"),
-		fmt.Sprintf("%s-%d", server.context.Value(internal.ContextServerID("ServerID")), cid))
+	// The context is cancelled when the connection is closed, which unblocks any command blocked on it.
+	ctx, cancel := context.WithCancel(context.WithValue(server.context, internal.ContextConnID("ConnectionID"),
+		fmt.Sprintf("%s-%d", server.context.Value(internal.ContextServerID("ServerID")), cid)))
+
+	server.registerConnection(&conn, cid)
 
 	defer func() {
 		log.Printf("closing connection %d...", cid)
+		cancel()
+		server.removeTransaction(&conn)
+		server.unregisterConnection(&conn)
 		if err := conn.Close(); err != nil {
 			log.Println(err)
 		}
 	}()
 
-	for {
-		message, err := internal.ReadMessage(r)
-
-		if err != nil && errors.Is(err, io.EOF) {
-			// Connection closed
-			log.Println(err)
-			break
-		}
-
-		if err != nil {
-			log.Println(err)
-			break
+	// Messages are read in a separate goroutine so that the connection being closed
+	// is detected while a blocking command is still waiting.
+	messages := make(chan []byte)
+	go func() {
+		defer cancel()
+		defer close(messages)
+		for {
+			message, err := internal.ReadMessage(r)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			// ReadMessage returns an empty message when the connection is closed by the client.
+			if len(message) == 0 {
+				return
+			}
+			select {
+			case messages <- message:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
 
+	for message := range messages {
 		res, err := server.handleCommand(ctx, message, &conn, false, false)
 		if err != nil && errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
-			if _, err = w.Write([]byte(fmt.Sprintf("-Error %s\r\n", err.Error()))); err != nil {
+			// Cluster errors are sent with their code as the prefix, so that clients can follow redirections.
+			format := "-Error %s\r\n"
+			if slotErr := (*slots.Error)(nil); errors.As(err, &slotErr) {
+				format = "-%s\r\n"
+			}
+			if _, err = w.Write([]byte(fmt.Sprintf(format, err.Error()))); err != nil {
 				log.Println(err)
 			}
 			continue
 		}
 
+		if server.getConnectionInfo(&conn).Protocol == constants.RESP3Protocol {
+			res = toRESP3Null(res)
+		}
+
 		chunkSize := 1024
 
 		// If the length of the response is 0, return nothing to the client.
@@ -571,25 +729,19 @@ func (server *EchoVault) ShutDown() {
 	}
 	if server.isInCluster() {
 		server.raft.RaftShutdown()
+		close(server.slotState.stop)
 		server.memberList.MemberListShutdown()
+		if server.forwardEnabled() {
+			if err := server.forwardServer.Close(); err != nil {
+				log.Printf("forward listener close: %v\n", err)
+			}
+			server.forwardClient.Close()
+		}
 	}
 }
 
 func (server *EchoVault) initialiseCaches() {
-	// Set up LFU cache
-	server.lfuCache = struct {
-		mutex sync.Mutex
-		cache eviction.CacheLFU
-	}{
-		mutex: sync.Mutex{},
-		cache: eviction.NewCacheLFU(),
-	}
-	// set up LRU cache
-	server.lruCache = struct {
-		mutex sync.Mutex
-		cache eviction.CacheLRU
-	}{
-		mutex: sync.Mutex{},
-		cache: eviction.NewCacheLRU(),
+	for _, s := range server.shards {
+		s.initialiseCaches(server.clock)
 	}
 }
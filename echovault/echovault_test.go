@@ -17,25 +17,34 @@ package echovault
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/clock"
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/forward"
 	"github.com/tidwall/resp"
 	"io"
 	"math"
 	"net"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
+// testClusterSecret is the secret the nodes of the test clusters authenticate each other with.
+const testClusterSecret = "cluster-secret"
+
 type ClientServerPair struct {
 	dataDir          string
 	serverId         string
@@ -89,6 +98,7 @@ func setupServer(
 	conf.ServerID = serverId
 	conf.DiscoveryPort = uint16(discoveryPort)
 	conf.BootstrapCluster = bootstrapCluster
+	conf.ClusterSecret = testClusterSecret
 	conf.EvictionPolicy = constants.NoEviction
 
 	return NewEchoVault(
@@ -210,6 +220,19 @@ func makeCluster(size int) ([]ClientServerPair, error) {
 	return pairs, nil
 }
 
+// doCommand sends the command to the node and returns the node's response.
+func doCommand(node ClientServerPair, command ...string) (resp.Value, error) {
+	cmd := make([]resp.Value, len(command))
+	for i, arg := range command {
+		cmd[i] = resp.StringValue(arg)
+	}
+	if err := node.client.WriteArray(cmd); err != nil {
+		return resp.Value{}, err
+	}
+	res, _, err := node.client.ReadValue()
+	return res, err
+}
+
 func Test_Cluster(t *testing.T) {
 	nodes, err := makeCluster(5)
 	if err != nil {
@@ -249,6 +272,11 @@ func Test_Cluster(t *testing.T) {
 			{key: "key11", value: "value11"},
 			{key: "key12", value: "value12"},
 		},
+		"transaction": {
+			{key: "key13", value: "value13"},
+			{key: "key14", value: "value14"},
+			{key: "key15", value: "value15"},
+		},
 	}
 
 	t.Run("Test_Replication", func(t *testing.T) {
@@ -455,7 +483,7 @@ func Test_Cluster(t *testing.T) {
 
 		// Delete the keys using raftApplyDelete method.
 		for _, test := range tests {
-			if err := nodes[0].server.raftApplyDeleteKey(nodes[0].server.context, test.key); err != nil {
+			if err := nodes[0].server.raftApplyDeleteKey(nodes[0].server.context, test.key, delEvent); err != nil {
 				t.Error(err)
 			}
 		}
@@ -570,187 +598,1432 @@ func Test_Cluster(t *testing.T) {
 		}
 	})
 
-	t.Run("Test_NotLeaderError", func(t *testing.T) {
-		node := nodes[len(nodes)-1]
-		err := node.client.WriteArray([]resp.Value{
-			resp.StringValue("SET"),
-			resp.StringValue("key"),
-			resp.StringValue("value"),
-		})
-		if err != nil {
+	t.Run("Test_ForwardCommandResponse", func(t *testing.T) {
+		follower := nodes[1]
+		leader := nodes[0]
+
+		commands := []struct {
+			command  []string
+			expected string
+			isError  bool
+		}{
+			{command: []string{"SET", "ForwardResponseKey1", "value1"}, expected: "OK"},
+			// The leader's response is returned, not an immediate OK.
+			{command: []string{"SET", "ForwardResponseKey1", "value2", "NX"}, isError: true},
+			{command: []string{"SET", "ForwardResponseKey1", "value3", "GET"}, expected: "value1"},
+			{command: []string{"INCR", "ForwardResponseKey1"}, isError: true},
+			{command: []string{"SET", "ForwardResponseKey2", "1"}, expected: "OK"},
+			{command: []string{"INCR", "ForwardResponseKey2"}, expected: "2"},
+		}
+
+		for i, test := range commands {
+			cmd := make([]resp.Value, len(test.command))
+			for j, arg := range test.command {
+				cmd[j] = resp.StringValue(arg)
+			}
+			if err := follower.client.WriteArray(cmd); err != nil {
+				t.Errorf("could not write command %d to follower node: %v", i, err)
+				return
+			}
+			rd, _, err := follower.client.ReadValue()
+			if err != nil {
+				t.Errorf("could not read response %d from follower node: %v", i, err)
+				return
+			}
+			if test.isError {
+				if rd.Error() == nil {
+					t.Errorf("expected command %d to return an error, got %s", i, rd.String())
+				}
+				continue
+			}
+			if rd.String() != test.expected {
+				t.Errorf("expected response %d to be \"%s\", got \"%s\"", i, test.expected, rd.String())
+			}
+		}
+
+		// The forwarded command has been applied on the leader by the time the follower responds.
+		if err := leader.client.WriteArray([]resp.Value{
+			resp.StringValue("GET"), resp.StringValue("ForwardResponseKey1"),
+		}); err != nil {
 			t.Error(err)
 			return
 		}
-		res, _, err := node.client.ReadValue()
+		rd, _, err := leader.client.ReadValue()
 		if err != nil {
 			t.Error(err)
 			return
 		}
-		expected := "not cluster leader, cannot carry out command"
-		if !strings.Contains(res.Error().Error(), expected) {
-			t.Errorf("expected response to contain \"%s\", got \"%s\"", expected, res.Error().Error())
+		if rd.String() != "value3" {
+			t.Errorf("expected leader value to be \"value3\", got \"%s\"", rd.String())
 		}
 	})
-}
 
-func Test_Standalone(t *testing.T) {
-	port, err := internal.GetFreePort()
-	if err != nil {
-		t.Error(err)
-		return
-	}
+	t.Run("Test_ReadConsistency", func(t *testing.T) {
+		leader := nodes[0]
+		follower := nodes[1]
 
-	mockServer, err := NewEchoVault(
-		WithConfig(config.Config{
-			BindAddr:       "localhost",
-			Port:           uint16(port),
-			DataDir:        "",
-			EvictionPolicy: constants.NoEviction,
-		}),
-	)
-	if err != nil {
-		t.Error(err)
-		return
-	}
+		if _, _, err := leader.server.Set("ReadConsistencyKey1", "value1", SetOptions{}); err != nil {
+			t.Error(err)
+			return
+		}
+		// Wait for the key to be replicated to the follower.
+		<-time.After(200 * time.Millisecond)
 
-	go func() {
-		mockServer.Start()
-	}()
+		tests := []struct {
+			name    string
+			node    ClientServerPair
+			options []string
+			wantErr string
+		}{
+			{name: "1. Leader read on the leader", node: leader, options: []string{"LEADER"}},
+			{name: "2. Lease read on the leader", node: leader, options: []string{"LEASE"}},
+			{
+				name:    "3. Leader read on a follower is rejected",
+				node:    follower,
+				options: []string{"LEADER"},
+				wantErr: "cannot serve read with leader consistency",
+			},
+			{
+				name:    "4. Lease read on a follower is rejected",
+				node:    follower,
+				options: []string{"LEASE"},
+				wantErr: "cannot serve read with lease consistency",
+			},
+			{name: "5. Stale read on a follower", node: follower, options: []string{"STALE"}},
+			{
+				name:    "6. Stale read on a follower within the max lag",
+				node:    follower,
+				options: []string{"STALE", "MAXLAG", "10000"},
+			},
+		}
 
-	t.Cleanup(func() {
-		mockServer.ShutDown()
-	})
+		for _, test := range tests {
+			command := []resp.Value{resp.StringValue("READCONSISTENCY")}
+			for _, option := range test.options {
+				command = append(command, resp.StringValue(option))
+			}
+			if err := test.node.client.WriteArray(command); err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				return
+			}
+			if rd, _, err := test.node.client.ReadValue(); err != nil || rd.String() != "OK" {
+				t.Errorf("%s: expected READCONSISTENCY response OK, got %s, %v", test.name, rd.String(), err)
+			}
+			if err := test.node.client.WriteArray([]resp.Value{
+				resp.StringValue("GET"), resp.StringValue("ReadConsistencyKey1"),
+			}); err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				return
+			}
+			rd, _, err := test.node.client.ReadValue()
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+			if test.wantErr != "" {
+				if rd.Error() == nil || !strings.Contains(rd.Error().Error(), test.wantErr) {
+					t.Errorf("%s: expected error %s, got %s", test.name, test.wantErr, rd.String())
+				}
+				continue
+			}
+			if rd.String() != "value1" {
+				t.Errorf("%s: expected value1, got %s", test.name, rd.String())
+			}
+		}
 
-	t.Run("Test_EmptyCommand", func(t *testing.T) {
-		conn, err := internal.GetConnection("localhost", port)
+		// The reads queued in a transaction are checked before the transaction is executed.
+		for _, node := range []ClientServerPair{leader, follower} {
+			for _, command := range [][]string{{"READCONSISTENCY", "LEADER"}, {"MULTI"}, {"GET", "ReadConsistencyKey1"}} {
+				if _, err := doCommand(node, command...); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+		if rd, err := doCommand(leader, "EXEC"); err != nil || len(rd.Array()) != 1 || rd.Array()[0].String() != "value1" {
+			t.Errorf("expected EXEC on the leader to return [value1], got %s, %v", rd.String(), err)
+		}
+		rd, err := doCommand(follower, "EXEC")
 		if err != nil {
 			t.Error(err)
 			return
 		}
-		defer func() {
-			_ = conn.Close()
-		}()
-		client := resp.NewConn(conn)
+		if rd.Error() == nil || !strings.Contains(rd.Error().Error(), "cannot serve read with leader consistency") {
+			t.Errorf("expected EXEC on a follower to be rejected, got %s", rd.String())
+		}
 
-		if err := client.WriteArray([]resp.Value{}); err != nil {
-			t.Error(err)
-			return
+		// Reset the read consistency of the connections for the other tests.
+		for _, node := range []ClientServerPair{leader, follower} {
+			if err := node.client.WriteArray([]resp.Value{
+				resp.StringValue("READCONSISTENCY"), resp.StringValue("STALE"),
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+			if _, _, err := node.client.ReadValue(); err != nil {
+				t.Error(err)
+				return
+			}
 		}
-		res, _, err := client.ReadValue()
-		if err != nil {
-			t.Error(err)
+
+		// The embedded API takes the read consistency per call.
+		if _, err := follower.server.ExecuteReadCommand(
+			ReadConsistencyOptions{Mode: "leader"}, "GET", "ReadConsistencyKey1"); err == nil {
+			t.Error("expected embedded leader read on a follower to be rejected")
 		}
-		expected := "empty command"
-		if !strings.Contains(res.Error().Error(), expected) {
-			t.Errorf("expcted response to contain \"%s\", got \"%s\"", expected, res.Error().Error())
+		if res, err := leader.server.ExecuteReadCommand(
+			ReadConsistencyOptions{Mode: "leader"}, "GET", "ReadConsistencyKey1"); err != nil || string(res) != "+value1\r\n" {
+			t.Errorf("expected embedded leader read on the leader to return value1, got %q, %v", res, err)
 		}
 	})
 
-	t.Run("Test_TLS", func(t *testing.T) {
-		t.Parallel()
+	t.Run("Test_Transaction", func(t *testing.T) {
+		tests := tests["transaction"]
+		node := nodes[0]
 
-		port, err := internal.GetFreePort()
-		if err != nil {
-			t.Error(err)
-			return
+		// Queue all the writes in a single transaction on the leader.
+		commands := [][]resp.Value{{resp.StringValue("MULTI")}}
+		for _, test := range tests {
+			commands = append(commands, []resp.Value{
+				resp.StringValue("SET"), resp.StringValue(test.key), resp.StringValue(test.value),
+			})
 		}
-
-		conf := DefaultConfig()
-		conf.DataDir = ""
-		conf.BindAddr = "localhost"
-		conf.Port = uint16(port)
-		conf.TLS = true
-		conf.CertKeyPairs = [][]string{
-			{
-				path.Join("..", "openssl", "server", "server1.crt"),
-				path.Join("..", "openssl", "server", "server1.key"),
-			},
-			{
-				path.Join("..", "openssl", "server", "server2.crt"),
-				path.Join("..", "openssl", "server", "server2.key"),
-			},
+		for i, command := range commands {
+			if err := node.client.WriteArray(command); err != nil {
+				t.Errorf("could not write command %d to leader node: %v", i, err)
+				return
+			}
+			rd, _, err := node.client.ReadValue()
+			if err != nil {
+				t.Errorf("could not read response %d from leader node: %v", i, err)
+				return
+			}
+			expected := "QUEUED"
+			if i == 0 {
+				expected = "OK"
+			}
+			if rd.String() != expected {
+				t.Errorf("expected response %d to be \"%s\", got \"%s\"", i, expected, rd.String())
+			}
 		}
 
-		server, err := NewEchoVault(WithConfig(conf))
+		// Execute the transaction and make sure each command in the transaction succeeded.
+		if err := node.client.WriteArray([]resp.Value{resp.StringValue("EXEC")}); err != nil {
+			t.Error(err)
+			return
+		}
+		rd, _, err := node.client.ReadValue()
 		if err != nil {
 			t.Error(err)
 			return
 		}
+		if len(rd.Array()) != len(tests) {
+			t.Errorf("expected EXEC response of length %d, got %d", len(tests), len(rd.Array()))
+			return
+		}
+		for i, res := range rd.Array() {
+			if !strings.EqualFold(res.String(), "ok") {
+				t.Errorf("expected response for test %d to be \"OK\", got %s", i, res.String())
+			}
+		}
 
-		wg := sync.WaitGroup{}
-		wg.Add(1)
-		go func() {
-			wg.Done()
-			server.Start()
+		// Yield
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer func() {
+			ticker.Stop()
 		}()
-		wg.Wait()
+		<-ticker.C
 
-		// Dial with ServerCAs
-		serverCAs := x509.NewCertPool()
-		f, err := os.Open(path.Join("..", "openssl", "server", "rootCA.crt"))
-		if err != nil {
-			t.Error(err)
+		// Check if the data has been replicated on a quorum (majority of the cluster).
+		quorum := int(math.Ceil(float64(len(nodes)/2)) + 1)
+		for i, test := range tests {
+			count := 0
+			for j := 0; j < len(nodes); j++ {
+				if err := nodes[j].client.WriteArray([]resp.Value{
+					resp.StringValue("GET"),
+					resp.StringValue(test.key),
+				}); err != nil {
+					t.Errorf("could not write data to follower node %d (test %d): %v", j, i, err)
+				}
+				rd, _, err := nodes[j].client.ReadValue()
+				if err != nil {
+					t.Errorf("could not read data from follower node %d (test %d): %v", j, i, err)
+				}
+				if rd.String() == test.value {
+					count += 1 // If the expected value is found, increment the count.
+				}
+			}
+			// Fail if count is less than quorum.
+			if count < quorum {
+				t.Errorf("could not find value %s at key %s in cluster quorum", test.value, test.key)
+			}
 		}
-		cert, err := io.ReadAll(bufio.NewReader(f))
-		if err != nil {
+	})
+
+	t.Run("Test_TransactionWatchedKeyModifiedOnFollower", func(t *testing.T) {
+		leader, follower := nodes[0], nodes[1]
+		key := "WatchedKey1"
+
+		if _, err := doCommand(leader, "SET", key, "value1"); err != nil {
 			t.Error(err)
+			return
 		}
-		ok := serverCAs.AppendCertsFromPEM(cert)
-		if !ok {
-			t.Error("could not load server CA")
-		}
-
-		conn, err := internal.GetTLSConnection("localhost", port, &tls.Config{
-			RootCAs: serverCAs,
-		})
-		if err != nil {
+		if _, err := doCommand(leader, "WATCH", key); err != nil {
 			t.Error(err)
 			return
 		}
-		defer func() {
-			_ = conn.Close()
-			server.ShutDown()
-		}()
-		client := resp.NewConn(conn)
 
-		// Test that we can set and get a value from the server.
-		key := "key1"
-		value := "value1"
-		err = client.WriteArray([]resp.Value{
-			resp.StringValue("SET"), resp.StringValue(key), resp.StringValue(value),
-		})
+		// Modify the watched key through a follower, which forwards the write to the leader.
+		rd, err := doCommand(follower, "SET", key, "value2")
 		if err != nil {
 			t.Error(err)
+			return
+		}
+		if !strings.EqualFold(rd.String(), "ok") {
+			t.Errorf("expected follower SET response to be \"OK\", got %s", rd.String())
+			return
 		}
 
-		res, _, err := client.ReadValue()
+		for _, command := range [][]string{{"MULTI"}, {"SET", key, "value3"}} {
+			if _, err = doCommand(leader, command...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		rd, err = doCommand(leader, "EXEC")
 		if err != nil {
 			t.Error(err)
+			return
+		}
+		if !rd.IsNull() {
+			t.Errorf("expected EXEC to be aborted with a nil response, got %s", rd.String())
 		}
 
-		if !strings.EqualFold(res.String(), "ok") {
-			t.Errorf("expected response OK, got \"%s\"", res.String())
+		// The aborted transaction must not have been applied on any node.
+		<-time.After(200 * time.Millisecond)
+		for i, node := range nodes {
+			rd, err = doCommand(node, "GET", key)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if rd.String() != "value2" {
+				t.Errorf("expected node %d to have value \"value2\" at key %s, got \"%s\"", i, key, rd.String())
+			}
 		}
+	})
 
-		err = client.WriteArray([]resp.Value{resp.StringValue("GET"), resp.StringValue(key)})
-		if err != nil {
+	t.Run("Test_TransactionWatchedKeyRewrittenWithSameValue", func(t *testing.T) {
+		leader, follower := nodes[0], nodes[1]
+		key := "WatchedKey2"
+
+		if _, err := doCommand(leader, "SET", key, "value1"); err != nil {
 			t.Error(err)
+			return
 		}
-
-		res, _, err = client.ReadValue()
-		if err != nil {
+		if _, err := doCommand(leader, "WATCH", key); err != nil {
 			t.Error(err)
+			return
 		}
 
-		if res.String() != value {
-			t.Errorf("expected response at key \"%s\" to be \"%s\", got \"%s\"", key, value, res.String())
+		// Writing the same value still modifies the key.
+		if _, err := doCommand(follower, "SET", key, "value1"); err != nil {
+			t.Error(err)
+			return
 		}
-	})
-
-	t.Run("Test_MTLS", func(t *testing.T) {
-		t.Parallel()
 
-		port, err := internal.GetFreePort()
+		for _, command := range [][]string{{"MULTI"}, {"SET", key, "value2"}} {
+			if _, err := doCommand(leader, command...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		rd, err := doCommand(leader, "EXEC")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !rd.IsNull() {
+			t.Errorf("expected EXEC to be aborted with a nil response, got %s", rd.String())
+		}
+	})
+
+	t.Run("Test_ExpiredKeyReadWhileApplied", func(t *testing.T) {
+		node := nodes[0]
+		key := "ExpiredKey1"
+
+		for _, command := range [][]string{{"SET", key, "10"}, {"EXPIREAT", key, "1"}} {
+			if _, err := doCommand(node, command...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+
+		// The write command finds the expired key while it's applied, and deletes it on every node.
+		rd, err := doCommand(node, "INCR", key)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.Integer() != 1 {
+			t.Errorf("expected INCR response to be 1, got %s", rd.String())
+			return
+		}
+
+		<-time.After(200 * time.Millisecond)
+		for i, node := range nodes {
+			rd, err = doCommand(node, "GET", key)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if rd.String() != "1" {
+				t.Errorf("expected node %d to have value \"1\" at key %s, got \"%s\"", i, key, rd.String())
+			}
+		}
+	})
+
+	t.Run("Test_TransactionOnFollower", func(t *testing.T) {
+		leader, follower := nodes[0], nodes[1]
+		key1, key2 := "FollowerTransactionKey1", "FollowerTransactionKey2"
+
+		// The whole transaction is forwarded to the leader and applied as a single raft log entry.
+		if _, err := doCommand(follower, "WATCH", key1); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, command := range [][]string{{"MULTI"}, {"SET", key1, "value1"}, {"INCR", key2}, {"GET", key1}} {
+			if _, err := doCommand(follower, command...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		rd, err := doCommand(follower, "EXEC")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []string{"OK", "1", "value1"}
+		if len(rd.Array()) != len(expected) {
+			t.Errorf("expected EXEC response of length %d, got %s", len(expected), rd.String())
+			return
+		}
+		for i, res := range rd.Array() {
+			if res.String() != expected[i] {
+				t.Errorf("expected EXEC response %d to be \"%s\", got \"%s\"", i, expected[i], res.String())
+			}
+		}
+		rd, err = doCommand(leader, "GET", key1)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.String() != "value1" {
+			t.Errorf("expected leader value at key %s to be \"value1\", got \"%s\"", key1, rd.String())
+		}
+
+		// The transaction is aborted by the leader if a watched key was modified after WATCH on the follower.
+		if _, err = doCommand(follower, "WATCH", key1); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err = doCommand(leader, "SET", key1, "value2"); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, command := range [][]string{{"MULTI"}, {"SET", key1, "value3"}} {
+			if _, err = doCommand(follower, command...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		rd, err = doCommand(follower, "EXEC")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !rd.IsNull() {
+			t.Errorf("expected EXEC to be aborted with a nil response, got %s", rd.String())
+		}
+
+		// A follower that does not forward commands can't execute the transaction.
+		node := nodes[len(nodes)-1]
+		for _, command := range [][]string{{"MULTI"}, {"SET", key1, "value4"}} {
+			if _, err = doCommand(node, command...); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		rd, err = doCommand(node, "EXEC")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.Error() == nil || !strings.Contains(rd.Error().Error(), "not cluster leader") {
+			t.Errorf("expected not cluster leader error, got %s", rd.String())
+		}
+	})
+
+	t.Run("Test_ScriptOnFollower", func(t *testing.T) {
+		leader, follower := nodes[0], nodes[1]
+		key := "FollowerScriptKey1"
+		script := "redis.call('SET', KEYS[1], ARGV[1]) return redis.call('INCR', KEYS[1])"
+
+		rd, err := doCommand(follower, "EVAL", script, "1", key, "1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.String() != "2" {
+			t.Errorf("expected EVAL response to be \"2\", got \"%s\"", rd.String())
+		}
+
+		// SCRIPT LOAD is replicated, so wait for the follower to cache the script before running it with EVALSHA.
+		sha, err := doCommand(follower, "SCRIPT", "LOAD", "return redis.call('INCR', KEYS[1])")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		<-time.After(200 * time.Millisecond)
+		rd, err = doCommand(follower, "EVALSHA", sha.String(), "1", key)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.String() != "3" {
+			t.Errorf("expected EVALSHA response to be \"3\", got \"%s\"", rd.String())
+		}
+		rd, err = doCommand(leader, "GET", key)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.String() != "3" {
+			t.Errorf("expected leader value at key %s to be \"3\", got \"%s\"", key, rd.String())
+		}
+
+		// A follower that does not forward commands can't run the script.
+		rd, err = doCommand(nodes[len(nodes)-1], "EVAL", script, "1", key, "1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if rd.Error() == nil || !strings.Contains(rd.Error().Error(), "not cluster leader") {
+			t.Errorf("expected not cluster leader error, got %s", rd.String())
+		}
+	})
+
+	t.Run("Test_BlockingCommand", func(t *testing.T) {
+		leader, follower := nodes[0], nodes[1]
+
+		// The blocked commands wait on the node that received them until an element is pushed through the leader.
+		for i, node := range []ClientServerPair{leader, follower} {
+			key := fmt.Sprintf("BlockingListKey%d", i+1)
+			popped := make(chan string, 1)
+			go func() {
+				_, element, err := node.server.BLPop(context.Background(), 0, key)
+				if err != nil {
+					t.Error(err)
+				}
+				popped <- element
+			}()
+
+			select {
+			case element := <-popped:
+				t.Errorf("expected BLPOP on node %d to block, got \"%s\"", i, element)
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			if _, err := doCommand(leader, "LPUSH", key, "value1"); err != nil {
+				t.Error(err)
+				return
+			}
+			select {
+			case element := <-popped:
+				if element != "value1" {
+					t.Errorf("expected BLPOP on node %d to pop \"value1\", got \"%s\"", i, element)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("timed out waiting for BLPOP on node %d to be woken by LPUSH", i)
+				return
+			}
+
+			// The pop is replicated, so the list is empty on every node.
+			<-time.After(200 * time.Millisecond)
+			for j, n := range nodes {
+				rd, err := doCommand(n, "LLEN", key)
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if rd.Integer() != 0 {
+					t.Errorf("expected list %s to be empty on node %d, got length %d", key, j, rd.Integer())
+				}
+			}
+		}
+	})
+
+	t.Run("Test_ForwardAuthentication", func(t *testing.T) {
+		leader := nodes[0]
+		addr := net.JoinHostPort(leader.server.config.RaftBindAddr, fmt.Sprint(leader.server.config.ForwardBindPort))
+		key := "ForwardAuthKey1"
+		ctx := context.Background()
+
+		// A client that does not know the cluster secret is rejected.
+		client := forward.NewClient(time.Second, "wrong-secret")
+		defer client.Close()
+		if _, err := client.Forward(ctx, addr, forward.Request{CMD: []string{"SET", key, "value1"}}); !errors.Is(err, forward.ErrUnauthenticated) {
+			t.Errorf("expected forward with the wrong secret to fail with %v, got %v", forward.ErrUnauthenticated, err)
+		}
+
+		// A request sent without the handshake is not served.
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := json.Marshal(forward.Request{CMD: []string{"SET", key, "value2"}})
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(b)))
+		if _, err = conn.Write(append(frame, b...)); err != nil {
+			t.Error(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _ = io.Copy(io.Discard, conn)
+		_ = conn.Close()
+
+		res, err := leader.server.Get(key)
+		if err != nil {
+			t.Error(err)
+		}
+		if res != "" {
+			t.Errorf("expected key %s to not be set by unauthenticated requests, got \"%s\"", key, res)
+		}
+
+		// A client with the cluster secret is served.
+		client = forward.NewClient(time.Second, testClusterSecret)
+		defer client.Close()
+		response, err := client.Forward(ctx, addr, forward.Request{CMD: []string{"SET", key, "value3"}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(response.Response) != "+OK\r\n" {
+			t.Errorf("expected forwarded SET response to be \"+OK\\r\\n\", got %q (error %q)", response.Response, response.Error)
+		}
+	})
+
+	t.Run("Test_NotLeaderError", func(t *testing.T) {
+		node := nodes[len(nodes)-1]
+		err := node.client.WriteArray([]resp.Value{
+			resp.StringValue("SET"),
+			resp.StringValue("key"),
+			resp.StringValue("value"),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res, _, err := node.client.ReadValue()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := "not cluster leader, cannot carry out command"
+		if !strings.Contains(res.Error().Error(), expected) {
+			t.Errorf("expected response to contain \"%s\", got \"%s\"", expected, res.Error().Error())
+		}
+	})
+
+	t.Run("Test_ClusterInfo", func(t *testing.T) {
+		if err := nodes[1].client.WriteArray([]resp.Value{resp.StringValue("CLUSTER"), resp.StringValue("INFO")}); err != nil {
+			t.Error(err)
+			return
+		}
+		res, _, err := nodes[1].client.ReadValue()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, want := range []string{"cluster_state:ok", "leader_id:SERVER-0", "server_id:SERVER-1", "state:Follower"} {
+			if !strings.Contains(res.String(), want+"\r\n") {
+				t.Errorf("expected cluster info to contain \"%s\", got \"%s\"", want, res.String())
+			}
+		}
+
+		info, err := nodes[0].server.ClusterInfo()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if info["state"] != "Leader" || info["server_id"] != "SERVER-0" {
+			t.Errorf("expected the info of the leader SERVER-0, got %v", info)
+		}
+	})
+
+	t.Run("Test_ClusterNodes", func(t *testing.T) {
+		clusterNodes, err := nodes[1].server.ClusterNodes()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(clusterNodes) != len(nodes) {
+			t.Errorf("expected %d nodes, got %d", len(nodes), len(clusterNodes))
+		}
+		for _, node := range clusterNodes {
+			wantRole := "follower"
+			if node.ID == "SERVER-0" {
+				wantRole = "leader"
+			}
+			if node.Role != wantRole {
+				t.Errorf("expected node %s to have role %s, got %s", node.ID, wantRole, node.Role)
+			}
+			if node.Myself != (node.ID == "SERVER-1") {
+				t.Errorf("expected only SERVER-1 to be myself, got %+v", node)
+			}
+			if node.MemberlistAddr == "" || node.CommitIndex <= 0 || node.AppliedIndex <= 0 {
+				t.Errorf("expected the memberlist address and the indexes of node %s, got %+v", node.ID, node)
+			}
+			if node.ID == "SERVER-0" && node.LastContact != 0 {
+				t.Errorf("expected the leader's last contact to be 0, got %d", node.LastContact)
+			}
+		}
+	})
+
+	t.Run("Test_ClusterSnapshot", func(t *testing.T) {
+		if ok, err := nodes[0].server.ClusterSnapshot(); err != nil || !ok {
+			t.Errorf("expected the snapshot to be taken, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Test_ClusterRemove", func(t *testing.T) {
+		if _, err := nodes[1].server.ClusterRemove("SERVER-4"); err == nil {
+			t.Error("expected an error when removing a node from a follower")
+		}
+		if _, err := nodes[0].server.ClusterRemove("SERVER-9"); err == nil ||
+			err.Error() != "node SERVER-9 is not a member of the cluster" {
+			t.Errorf("expected an error when removing an unknown node, got %v", err)
+		}
+
+		if err := nodes[0].client.WriteArray([]resp.Value{
+			resp.StringValue("CLUSTER"), resp.StringValue("REMOVE"), resp.StringValue("SERVER-4"),
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		res, _, err := nodes[0].client.ReadValue()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if res.String() != "OK" {
+			t.Errorf("expected response OK, got %s", res.String())
+		}
+
+		servers, err := nodes[0].server.raft.Servers()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, s := range servers {
+			if s.ID == "SERVER-4" {
+				t.Error("expected SERVER-4 to be removed from the raft configuration")
+			}
+		}
+	})
+
+	t.Run("Test_ClusterLeave", func(t *testing.T) {
+		// The follower asks the leader to remove it.
+		if ok, err := nodes[3].server.ClusterLeave(); err != nil || !ok {
+			t.Errorf("expected SERVER-3 to leave the cluster, got %v, %v", ok, err)
+			return
+		}
+		servers, err := nodes[0].server.raft.Servers()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(servers) != 3 {
+			t.Errorf("expected 3 servers in the raft configuration, got %+v", servers)
+		}
+		for _, s := range servers {
+			if s.ID == "SERVER-3" {
+				t.Error("expected SERVER-3 to be removed from the raft configuration")
+			}
+		}
+	})
+
+	t.Run("Test_ClusterTransferLeader", func(t *testing.T) {
+		if _, err := nodes[2].server.ClusterTransferLeader(""); err == nil {
+			t.Error("expected an error when transferring the leadership from a follower")
+		}
+		if ok, err := nodes[0].server.ClusterTransferLeader("SERVER-1"); err != nil || !ok {
+			t.Errorf("expected the leadership to be transferred, got %v, %v", ok, err)
+			return
+		}
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		timeout := time.After(5 * time.Second)
+		for !nodes[1].server.raft.IsRaftLeader() {
+			select {
+			case <-timeout:
+				t.Error("timed out waiting for SERVER-1 to become the leader")
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func Test_ClusterLearner(t *testing.T) {
+	nodes, err := makeCluster(3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	discoveryPort, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf := DefaultConfig()
+	conf.DataDir = ""
+	conf.ServerID = "LEARNER"
+	conf.BindAddr = getBindAddr().String()
+	conf.Port = uint16(port)
+	conf.DiscoveryPort = uint16(discoveryPort)
+	conf.JoinAddr = fmt.Sprintf("%s/%s:%d", nodes[0].serverId, nodes[0].bindAddr, nodes[0].discoveryPort)
+	conf.ForwardCommand = true
+	conf.RaftLearner = true
+	conf.ClusterSecret = testClusterSecret
+	conf.EvictionPolicy = constants.NoEviction
+
+	learner, err := NewEchoVault(WithConfig(conf))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		learner.ShutDown()
+		for i := len(nodes) - 1; i > -1; i-- {
+			_ = nodes[i].raw.Close()
+			nodes[i].server.ShutDown()
+		}
+	})
+
+	// waitFor polls the condition until it's true, and fails the test if it's still false after 5 seconds.
+	waitFor := func(t *testing.T, description string, condition func() bool) bool {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		timeout := time.After(5 * time.Second)
+		for !condition() {
+			select {
+			case <-timeout:
+				t.Errorf("timed out waiting for %s", description)
+				return false
+			case <-ticker.C:
+			}
+		}
+		return true
+	}
+
+	suffrage := func(id string) string {
+		servers, err := nodes[0].server.raft.Servers()
+		if err != nil {
+			return ""
+		}
+		for _, s := range servers {
+			if string(s.ID) == id {
+				return s.Suffrage.String()
+			}
+		}
+		return ""
+	}
+
+	if !waitFor(t, "the learner to join the cluster", learner.raft.HasJoinedCluster) {
+		return
+	}
+
+	t.Run("Test_LearnerJoin", func(t *testing.T) {
+		if got := suffrage("LEARNER"); got != "Nonvoter" {
+			t.Errorf("expected the learner to join as a Nonvoter, got %s", got)
+		}
+		clusterNodes, err := learner.ClusterNodes()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, node := range clusterNodes {
+			if node.ID == "LEARNER" && (node.Role != "learner" || !node.Myself) {
+				t.Errorf("expected the learner to be reported as myself,learner, got %+v", node)
+			}
+		}
+
+		if _, err = NewEchoVault(WithConfig(config.Config{BootstrapCluster: true, RaftLearner: true})); err == nil ||
+			err.Error() != "a raft learner cannot bootstrap the cluster" {
+			t.Errorf("expected an error when a learner bootstraps the cluster, got %v", err)
+		}
+	})
+
+	t.Run("Test_LearnerReadsAndWrites", func(t *testing.T) {
+		if _, _, err := nodes[0].server.Set("leader-key", "value1", SetOptions{}); err != nil {
+			t.Error(err)
+			return
+		}
+		waitFor(t, "the learner to replicate the key", func() bool {
+			value, err := learner.Get("leader-key")
+			return err == nil && value == "value1"
+		})
+
+		// The learner forwards writes to the leader.
+		if _, _, err := learner.Set("learner-key", "value2", SetOptions{}); err != nil {
+			t.Error(err)
+			return
+		}
+		if value, err := nodes[0].server.Get("learner-key"); err != nil || value != "value2" {
+			t.Errorf("expected the leader to hold the value written on the learner, got %s, %v", value, err)
+		}
+	})
+
+	t.Run("Test_ClusterPromote", func(t *testing.T) {
+		if _, err := nodes[1].server.ClusterPromote("LEARNER"); err == nil {
+			t.Error("expected an error when promoting from a follower")
+		}
+
+		if err := nodes[0].client.WriteArray([]resp.Value{
+			resp.StringValue("CLUSTER"), resp.StringValue("PROMOTE"), resp.StringValue("LEARNER"),
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		res, _, err := nodes[0].client.ReadValue()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if res.String() != "OK" {
+			t.Errorf("expected response OK, got %s", res.String())
+		}
+		if got := suffrage("LEARNER"); got != "Voter" {
+			t.Errorf("expected the learner to be promoted to a Voter, got %s", got)
+		}
+
+		if _, err = nodes[0].server.ClusterPromote("LEARNER"); err == nil || err.Error() != "node LEARNER is already a voter" {
+			t.Errorf("expected an error when promoting a voter, got %v", err)
+		}
+	})
+}
+
+func Test_ShardedCluster(t *testing.T) {
+	// Two shards with one node each. Slot 5061 ("bar") belongs to shard-1 and slot 12182 ("foo") to shard-2.
+	shards := []struct {
+		id    string
+		slots string
+	}{
+		{id: "shard-1", slots: "0-8191"},
+		{id: "shard-2", slots: "8192-16383"},
+	}
+	nodes := make([]ClientServerPair, len(shards))
+	for i, shard := range shards {
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		discoveryPort, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		nodes[i] = ClientServerPair{
+			serverId:      fmt.Sprintf("SHARDED-SERVER-%d", i),
+			bindAddr:      getBindAddr().String(),
+			port:          port,
+			discoveryPort: discoveryPort,
+		}
+
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.BindAddr = nodes[i].bindAddr
+		conf.Port = uint16(port)
+		conf.ServerID = nodes[i].serverId
+		conf.DiscoveryPort = uint16(discoveryPort)
+		conf.ClusterSecret = testClusterSecret
+		conf.EvictionPolicy = constants.NoEviction
+		// Each shard bootstraps its own raft group. The nodes join the same memberlist cluster.
+		conf.BootstrapCluster = true
+		conf.ShardID = shard.id
+		conf.ClusterSlots = shard.slots
+		if i > 0 {
+			conf.JoinAddr = fmt.Sprintf("%s/%s:%d", nodes[0].serverId, nodes[0].bindAddr, nodes[0].discoveryPort)
+		}
+
+		server, err := NewEchoVault(WithContext(context.Background()), WithConfig(conf))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		go server.Start()
+		for !server.raft.IsRaftLeader() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		conn, err := internal.GetConnection(nodes[i].bindAddr, port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		nodes[i].raw = conn
+		nodes[i].client = resp.NewConn(conn)
+		nodes[i].server = server
+	}
+
+	t.Cleanup(func() {
+		for i := len(nodes) - 1; i > -1; i-- {
+			if nodes[i].server == nil {
+				continue
+			}
+			_ = nodes[i].raw.Close()
+			nodes[i].server.ShutDown()
+		}
+	})
+
+	addr := func(node ClientServerPair) string {
+		return fmt.Sprintf("%s:%d", node.bindAddr, node.port)
+	}
+	// waitFor polls the command on the node until it returns the expected response or error.
+	waitFor := func(node ClientServerPair, expected string, command ...string) error {
+		var got string
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+			res, err := doCommand(node, command...)
+			if err != nil {
+				return err
+			}
+			got = res.String()
+			if res.Error() != nil {
+				got = res.Error().Error()
+			}
+			if got == expected {
+				return nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return fmt.Errorf("expected %v to return \"%s\", got \"%s\"", command, expected, got)
+	}
+
+	// Wait until each node knows the slots of the other shard.
+	if err := waitFor(nodes[0], fmt.Sprintf("MOVED 12182 %s", addr(nodes[1])), "GET", "foo"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := waitFor(nodes[1], fmt.Sprintf("MOVED 5061 %s", addr(nodes[0])), "GET", "bar"); err != nil {
+		t.Error(err)
+		return
+	}
+	// Wait until the leaders of the shards know each other, so that the keys can be migrated.
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		_, err0 := nodes[0].server.shardLeader(shards[1].id)
+		_, err1 := nodes[1].server.shardLeader(shards[0].id)
+		if err0 == nil && err1 == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("shard leaders are not known: %v, %v", err0, err1)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Run("Test_Redirection", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			node     ClientServerPair
+			command  []string
+			expected string
+			isError  bool
+		}{
+			{name: "owned slot", node: nodes[0], command: []string{"SET", "bar", "value1"}, expected: "OK"},
+			{name: "owned hashtag", node: nodes[0], command: []string{"SET", "{bar}2", "value2"}, expected: "OK"},
+			{
+				name:     "moved to owner",
+				node:     nodes[1],
+				command:  []string{"GET", "bar"},
+				expected: fmt.Sprintf("MOVED 5061 %s", addr(nodes[0])),
+				isError:  true,
+			},
+			{
+				name:     "cross slot",
+				node:     nodes[0],
+				command:  []string{"MSET", "foo", "1", "bar", "2"},
+				expected: "CROSSSLOT Keys in request don't hash to the same slot",
+				isError:  true,
+			},
+			// Keys in different slots can be accessed together while the shard owns all of them.
+			{name: "owned slots", node: nodes[0], command: []string{"EXISTS", "bar", "baz"}, expected: "1"},
+			{name: "keyslot", node: nodes[1], command: []string{"CLUSTER", "KEYSLOT", "{bar}2"}, expected: "5061"},
+			{name: "count keys", node: nodes[0], command: []string{"CLUSTER", "COUNTKEYSINSLOT", "5061"}, expected: "2"},
+		}
+		for _, test := range tests {
+			res, err := doCommand(test.node, test.command...)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if test.isError {
+				if res.Error() == nil || res.Error().Error() != test.expected {
+					t.Errorf("%s: expected error \"%s\", got %v", test.name, test.expected, res)
+				}
+				continue
+			}
+			if res.Error() != nil || res.String() != test.expected {
+				t.Errorf("%s: expected response \"%s\", got %v", test.name, test.expected, res)
+			}
+		}
+
+		res, err := doCommand(nodes[0], "CLUSTER", "SLOTS")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		slotRanges := res.Array()
+		if len(slotRanges) != 2 {
+			t.Errorf("expected 2 slot ranges, got %v", res)
+			return
+		}
+		if slotRanges[0].Array()[1].Integer() != 8191 || slotRanges[1].Array()[2].Array()[2].String() != nodes[1].serverId {
+			t.Errorf("expected slots 0-8191 followed by the slots of %s, got %v", nodes[1].serverId, res)
+		}
+	})
+
+	t.Run("Test_SlotMigration", func(t *testing.T) {
+		source, target := nodes[0], nodes[1]
+		steps := []struct {
+			name     string
+			node     ClientServerPair
+			command  []string
+			expected string
+		}{
+			{name: "import", node: target, command: []string{"CLUSTER", "SETSLOT", "5061", "IMPORTING", "shard-1"}, expected: "OK"},
+			{name: "migrate", node: source, command: []string{"CLUSTER", "SETSLOT", "5061", "MIGRATING", "shard-2"}, expected: "OK"},
+			// Keys that are missing on the source are served by the target, only to clients that ask.
+			{
+				name:     "ask for missing key",
+				node:     source,
+				command:  []string{"GET", "{bar}missing"},
+				expected: fmt.Sprintf("ASK 5061 %s", addr(target)),
+			},
+			{
+				name:     "moved without asking",
+				node:     target,
+				command:  []string{"GET", "{bar}missing"},
+				expected: fmt.Sprintf("MOVED 5061 %s", addr(source)),
+			},
+			{name: "asking", node: target, command: []string{"ASKING"}, expected: "OK"},
+			{name: "after asking", node: target, command: []string{"GET", "{bar}missing"}, expected: ""},
+			{name: "existing key", node: source, command: []string{"GET", "bar"}, expected: "value1"},
+			{name: "unassigned slot has keys", node: source, command: []string{"CLUSTER", "SETSLOT", "5061", "NODE", "shard-2"},
+				expected: "Error slot 5061 still has keys, move them with CLUSTER MIGRATESLOT first"},
+			{name: "move keys", node: source, command: []string{"CLUSTER", "MIGRATESLOT", "5061", "10"}, expected: "2"},
+			{name: "ask for moved key", node: source, command: []string{"GET", "bar"}, expected: fmt.Sprintf("ASK 5061 %s", addr(target))},
+			{name: "assign on target", node: target, command: []string{"CLUSTER", "SETSLOT", "5061", "NODE", "shard-2"}, expected: "OK"},
+			{name: "assign on source", node: source, command: []string{"CLUSTER", "SETSLOT", "5061", "NODE", "shard-2"}, expected: "OK"},
+			{name: "served by target", node: target, command: []string{"GET", "bar"}, expected: "value1"},
+			{name: "count keys on target", node: target, command: []string{"CLUSTER", "COUNTKEYSINSLOT", "5061"}, expected: "2"},
+		}
+		for _, step := range steps {
+			res, err := doCommand(step.node, step.command...)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			got := res.String()
+			if res.Error() != nil {
+				got = res.Error().Error()
+			}
+			if got != step.expected {
+				t.Errorf("%s: expected \"%s\", got \"%s\"", step.name, step.expected, got)
+				return
+			}
+		}
+
+		// The source redirects to the target once the target's claim on the slot has reached it.
+		if err := waitFor(source, fmt.Sprintf("MOVED 5061 %s", addr(target)), "GET", "bar"); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func Test_ClusterWithoutSecret(t *testing.T) {
+	newConfig := func(t *testing.T) config.Config {
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Fatal(err)
+		}
+		discoveryPort, err := internal.GetFreePort()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.ServerID = "NO_SECRET"
+		conf.BindAddr = getBindAddr().String()
+		conf.Port = uint16(port)
+		conf.DiscoveryPort = uint16(discoveryPort)
+		conf.BootstrapCluster = true
+		conf.EvictionPolicy = constants.NoEviction
+		return conf
+	}
+
+	t.Run("Test_SecretRequiredToForward", func(t *testing.T) {
+		conf := newConfig(t)
+		conf.ForwardCommand = true
+		if _, err := NewEchoVault(WithConfig(conf)); err == nil {
+			t.Error("expected an error when forwarding commands without a cluster secret")
+		}
+	})
+
+	t.Run("Test_ForwardChannelDisabled", func(t *testing.T) {
+		// A cluster created before the secret was introduced starts without the forward channel.
+		server, err := NewEchoVault(WithConfig(newConfig(t)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.ShutDown()
+		for !server.raft.IsRaftLeader() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if _, _, err = server.Set("key", "value", SetOptions{}); err != nil {
+			t.Error(err)
+		}
+		if value, err := server.Get("key"); err != nil || value != "value" {
+			t.Errorf("expected value \"value\", got %q, %v", value, err)
+		}
+		if _, err = server.forwardCommand(context.Background(), []string{"SET", "key", "value"}); err == nil {
+			t.Error("expected forwarding to fail without a cluster secret")
+		}
+	})
+}
+
+func Test_Standalone(t *testing.T) {
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mockServer, err := NewEchoVault(
+		WithConfig(config.Config{
+			BindAddr:       "localhost",
+			Port:           uint16(port),
+			DataDir:        "",
+			EvictionPolicy: constants.NoEviction,
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		mockServer.Start()
+	}()
+
+	t.Cleanup(func() {
+		mockServer.ShutDown()
+	})
+
+	t.Run("Test_EmptyCommand", func(t *testing.T) {
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		if err := client.WriteArray([]resp.Value{}); err != nil {
+			t.Error(err)
+			return
+		}
+		res, _, err := client.ReadValue()
+		if err != nil {
+			t.Error(err)
+		}
+		expected := "empty command"
+		if !strings.Contains(res.Error().Error(), expected) {
+			t.Errorf("expcted response to contain \"%s\", got \"%s\"", expected, res.Error().Error())
+		}
+	})
+
+	t.Run("Test_TLS", func(t *testing.T) {
+		t.Parallel()
+
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.BindAddr = "localhost"
+		conf.Port = uint16(port)
+		conf.TLS = true
+		conf.CertKeyPairs = [][]string{
+			{
+				path.Join("..", "openssl", "server", "server1.crt"),
+				path.Join("..", "openssl", "server", "server1.key"),
+			},
+			{
+				path.Join("..", "openssl", "server", "server2.crt"),
+				path.Join("..", "openssl", "server", "server2.key"),
+			},
+		}
+
+		server, err := NewEchoVault(WithConfig(conf))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			wg.Done()
+			server.Start()
+		}()
+		wg.Wait()
+
+		// Dial with ServerCAs
+		serverCAs := x509.NewCertPool()
+		f, err := os.Open(path.Join("..", "openssl", "server", "rootCA.crt"))
+		if err != nil {
+			t.Error(err)
+		}
+		cert, err := io.ReadAll(bufio.NewReader(f))
+		if err != nil {
+			t.Error(err)
+		}
+		ok := serverCAs.AppendCertsFromPEM(cert)
+		if !ok {
+			t.Error("could not load server CA")
+		}
+
+		conn, err := internal.GetTLSConnection("localhost", port, &tls.Config{
+			RootCAs: serverCAs,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+			server.ShutDown()
+		}()
+		client := resp.NewConn(conn)
+
+		// Test that we can set and get a value from the server.
+		key := "key1"
+		value := "value1"
+		err = client.WriteArray([]resp.Value{
+			resp.StringValue("SET"), resp.StringValue(key), resp.StringValue(value),
+		})
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, _, err := client.ReadValue()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if !strings.EqualFold(res.String(), "ok") {
+			t.Errorf("expected response OK, got \"%s\"", res.String())
+		}
+
+		err = client.WriteArray([]resp.Value{resp.StringValue("GET"), resp.StringValue(key)})
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, _, err = client.ReadValue()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if res.String() != value {
+			t.Errorf("expected response at key \"%s\" to be \"%s\", got \"%s\"", key, value, res.String())
+		}
+	})
+
+	t.Run("Test_TLSHandshakeTimeout", func(t *testing.T) {
+		// Not parallel, as the other TLS tests read the handshake timeout.
+		defaultTimeout := tlsHandshakeTimeout
+		tlsHandshakeTimeout = 100 * time.Millisecond
+		defer func() {
+			tlsHandshakeTimeout = defaultTimeout
+		}()
+
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.BindAddr = "localhost"
+		conf.Port = uint16(port)
+		conf.TLS = true
+		conf.CertKeyPairs = [][]string{
+			{
+				path.Join("..", "openssl", "server", "server1.crt"),
+				path.Join("..", "openssl", "server", "server1.key"),
+			},
+		}
+
+		server, err := NewEchoVault(WithConfig(conf))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		go server.Start()
+		defer server.ShutDown()
+
+		// Connect without starting the handshake. The server closes the connection once the timeout has passed.
+		var conn net.Conn
+		for deadline := time.Now().Add(5 * time.Second); ; {
+			if conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", port)); err == nil || time.Now().After(deadline) {
+				break
+			}
+			<-time.After(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err = conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Errorf("expected the server to close the connection, got %v", err)
+		}
+	})
+
+	t.Run("Test_MTLS", func(t *testing.T) {
+		t.Parallel()
+
+		port, err := internal.GetFreePort()
 		if err != nil {
 			t.Error(err)
 			return
@@ -872,6 +2145,152 @@ func Test_Standalone(t *testing.T) {
 		}
 	})
 
+	t.Run("Test_MTLSUsers", func(t *testing.T) {
+		t.Parallel()
+
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		client1, err := tls.LoadX509KeyPair(
+			path.Join("..", "openssl", "client", "client1.crt"),
+			path.Join("..", "openssl", "client", "client1.key"),
+		)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		client2, err := tls.LoadX509KeyPair(
+			path.Join("..", "openssl", "client", "client2.crt"),
+			path.Join("..", "openssl", "client", "client2.key"),
+		)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		fingerprint := sha256.Sum256(client1.Certificate[0])
+
+		conf := DefaultConfig()
+		conf.DataDir = ""
+		conf.BindAddr = "localhost"
+		conf.Port = uint16(port)
+		conf.TLS = true
+		conf.MTLS = true
+		conf.RequirePass = true
+		conf.Password = "password1"
+		conf.ClientCAs = []string{path.Join("..", "openssl", "client", "rootCA.crt")}
+		conf.CertKeyPairs = [][]string{
+			{path.Join("..", "openssl", "server", "server1.crt"), path.Join("..", "openssl", "server", "server1.key")},
+		}
+		// Both client certificates have the common name localhost, but only client1 matches the fingerprint.
+		conf.MTLSUsers = []string{
+			fmt.Sprintf("fingerprint:%X=fingerprint_user", fingerprint),
+			"cn:localhost=cn_user",
+		}
+
+		server, err := NewEchoVault(WithConfig(conf))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, username := range []string{"fingerprint_user", "cn_user"} {
+			if _, err = server.ACLSetUser(User{
+				Username:             username,
+				Enabled:              true,
+				IncludeCategories:    []string{"*"},
+				IncludeCommands:      []string{"*"},
+				IncludeReadWriteKeys: []string{"*"},
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		go func() {
+			server.Start()
+		}()
+		t.Cleanup(func() {
+			server.ShutDown()
+		})
+
+		serverCAs := x509.NewCertPool()
+		cert, err := os.ReadFile(path.Join("..", "openssl", "server", "rootCA.crt"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if ok := serverCAs.AppendCertsFromPEM(cert); !ok {
+			t.Error("could not load server CA")
+			return
+		}
+
+		dial := func(certificate tls.Certificate) *resp.Conn {
+			conn, err := internal.GetTLSConnection("localhost", port, &tls.Config{
+				RootCAs:      serverCAs,
+				Certificates: []tls.Certificate{certificate},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = conn.Close()
+			})
+			return resp.NewConn(conn)
+		}
+		send := func(client *resp.Conn, cmd ...string) resp.Value {
+			values := make([]resp.Value, len(cmd))
+			for i, c := range cmd {
+				values[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(values); err != nil {
+				t.Fatal(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res
+		}
+
+		tests := []struct {
+			name        string
+			certificate tls.Certificate
+			wantUser    string
+		}{
+			{
+				name:        "1. Authenticate with the certificate fingerprint",
+				certificate: client1,
+				wantUser:    "fingerprint_user",
+			},
+			{
+				name:        "2. Authenticate with the certificate common name",
+				certificate: client2,
+				wantUser:    "cn_user",
+			},
+		}
+
+		for _, test := range tests {
+			client := dial(test.certificate)
+			if res := send(client, "ACL", "WHOAMI"); res.String() != test.wantUser {
+				t.Errorf("%s: expected user %s, got %v", test.name, test.wantUser, res)
+			}
+			if res := send(client, "SET", "key1", "value1"); !strings.EqualFold(res.String(), "ok") {
+				t.Errorf("%s: expected response OK without AUTH, got %v", test.name, res)
+			}
+		}
+
+		// The connection falls back to the default user when the mapped user is disabled.
+		if _, err = server.ACLSetUser(User{Username: "cn_user", Enabled: false}); err != nil {
+			t.Error(err)
+			return
+		}
+		client := dial(client2)
+		if res := send(client, "SET", "key1", "value1"); res.Error() == nil {
+			t.Errorf("expected SET to fail without AUTH when the mapped user is disabled, got %v", res)
+		}
+	})
+
 	t.Run("Test_SnapshotRestore", func(t *testing.T) {
 		t.Parallel()
 
@@ -1086,3 +2505,467 @@ func Test_Standalone(t *testing.T) {
 		}
 	})
 }
+
+func Test_Shards(t *testing.T) {
+	mockServer := createEchoVault()
+
+	t.Run("Test_ShardIndexes", func(t *testing.T) {
+		keys := []string{"key3", "key1", "key2", "key1", "key4", "key3"}
+		indexes := shardIndexes(keys)
+		for i := 1; i < len(indexes); i++ {
+			if indexes[i] <= indexes[i-1] {
+				t.Errorf("expected shard indexes to be sorted without duplicates, got %v", indexes)
+				return
+			}
+		}
+		for _, key := range keys {
+			if !slices.Contains(indexes, shardIndex(key)) {
+				t.Errorf("expected shard indexes %v to contain the shard %d of key \"%s\"", indexes, shardIndex(key), key)
+			}
+		}
+	})
+
+	t.Run("Test_ConcurrentMultiKeyCommands", func(t *testing.T) {
+		kvPairs := make(map[string]string)
+		for i := 0; i < 100; i++ {
+			kvPairs[fmt.Sprintf("shard-key%d", i)] = fmt.Sprintf("value%d", i)
+		}
+		keys := make([]string, 0, len(kvPairs))
+		for key := range kvPairs {
+			keys = append(keys, key)
+		}
+
+		// Multi-key commands run concurrently on overlapping shards must not deadlock.
+		done := make(chan struct{})
+		go func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(3)
+				go func() {
+					defer wg.Done()
+					if _, err := mockServer.MSet(kvPairs); err != nil {
+						t.Error(err)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					if _, err := mockServer.MGet(keys...); err != nil {
+						t.Error(err)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					if _, err := mockServer.Keys("shard-key*"); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("timed out waiting for the commands to complete")
+			return
+		}
+
+		res, err := mockServer.Keys("shard-key*")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(res) != len(kvPairs) {
+			t.Errorf("expected %d keys, got %d", len(kvPairs), len(res))
+		}
+
+		// The keys are spread across the shards and the state is copied from all of them.
+		usedShards := 0
+		for _, s := range mockServer.shards {
+			if len(s.store) > 0 {
+				usedShards += 1
+			}
+		}
+		if usedShards < 2 {
+			t.Errorf("expected the keys to be spread across the shards, got %d shards in use", usedShards)
+		}
+		view := mockServer.getState()
+		defer view.Release()
+		for key, value := range kvPairs {
+			data, ok := view.State[key]
+			if !ok {
+				t.Errorf("expected key \"%s\" to be in the state", key)
+				continue
+			}
+			if data.Value != value {
+				t.Errorf("expected value at key \"%s\" to be \"%s\", got \"%v\"", key, value, data.Value)
+			}
+		}
+	})
+}
+
+func Test_StateView(t *testing.T) {
+	mockServer := createEchoVault()
+
+	if _, _, err := mockServer.Set("view-string", "value1", SetOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := mockServer.RPush("view-list", "a", "b", "c"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := mockServer.HSet("view-hash", map[string]string{"field1": "value1"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := mockServer.SAdd("view-set", "a", "b"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, _, err := mockServer.Set("view-deleted", "value1", SetOptions{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	view := mockServer.getState()
+
+	// Writes made while the view is open, including the ones that modify values in place, must not block
+	// or show up in the view.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, err := mockServer.Set("view-string", "value2", SetOptions{}); err != nil {
+			t.Error(err)
+		}
+		if _, err := mockServer.LSet("view-list", 0, "z"); err != nil {
+			t.Error(err)
+		}
+		if _, err := mockServer.HSet("view-hash", map[string]string{"field1": "value2", "field2": "value2"}); err != nil {
+			t.Error(err)
+		}
+		if _, err := mockServer.SAdd("view-set", "c"); err != nil {
+			t.Error(err)
+		}
+		if _, err := mockServer.Del("view-deleted"); err != nil {
+			t.Error(err)
+		}
+		if _, _, err := mockServer.Set("view-created", "value1", SetOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for the writes to complete while the view is open")
+		return
+	}
+
+	if got := view.State["view-string"].Value; got != "value1" {
+		t.Errorf("expected value at key \"view-string\" to be \"value1\", got \"%v\"", got)
+	}
+	if got := view.State["view-list"].Value.([]interface{})[0]; got != "a" {
+		t.Errorf("expected the first element of \"view-list\" to be \"a\", got \"%v\"", got)
+	}
+	if got := view.State["view-hash"].Value.(map[string]interface{}); len(got) != 1 || got["field1"] != "value1" {
+		t.Errorf("expected \"view-hash\" to only have field1 set to \"value1\", got %v", got)
+	}
+	if got := view.State["view-set"].Value.(interface{ Cardinality() int }).Cardinality(); got != 2 {
+		t.Errorf("expected \"view-set\" to have 2 members, got %d", got)
+	}
+	if _, ok := view.State["view-deleted"]; !ok {
+		t.Error("expected \"view-deleted\" to be in the view")
+	}
+	if _, ok := view.State["view-created"]; ok {
+		t.Error("expected \"view-created\" not to be in the view")
+	}
+	view.Release()
+
+	// A new view shows the writes.
+	view = mockServer.getState()
+	defer view.Release()
+	if got := view.State["view-string"].Value; got != "value2" {
+		t.Errorf("expected value at key \"view-string\" to be \"value2\", got \"%v\"", got)
+	}
+	if got := view.State["view-list"].Value.([]interface{})[0]; got != "z" {
+		t.Errorf("expected the first element of \"view-list\" to be \"z\", got \"%v\"", got)
+	}
+	if _, ok := view.State["view-deleted"]; ok {
+		t.Error("expected \"view-deleted\" not to be in the view")
+	}
+	if _, ok := view.State["view-created"]; !ok {
+		t.Error("expected \"view-created\" to be in the view")
+	}
+
+	// A command blocked on its keys does not hold up the views.
+	popped := make(chan string)
+	go func() {
+		_, element, err := mockServer.BLPop(context.Background(), 0, "view-blocked")
+		if err != nil {
+			t.Error(err)
+		}
+		popped <- element
+	}()
+	<-time.After(100 * time.Millisecond)
+	opened := make(chan internal.StateView)
+	go func() {
+		opened <- mockServer.getState()
+	}()
+	select {
+	case blockedView := <-opened:
+		blockedView.Release()
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for the view to open while a command is blocked")
+		return
+	}
+	if _, err := mockServer.RPush("view-blocked", "a"); err != nil {
+		t.Error(err)
+		return
+	}
+	select {
+	case element := <-popped:
+		if element != "a" {
+			t.Errorf("expected the blocked command to pop \"a\", got \"%s\"", element)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for the blocked command to pop the element")
+	}
+}
+
+func Test_ActiveExpireCycle(t *testing.T) {
+	ctx := context.Background()
+	// The interval is long enough for the cycle to only run when it's called by the test.
+	mockServer := createEchoVaultWithConfig(config.Config{
+		DataDir:          "",
+		EvictionPolicy:   constants.NoEviction,
+		EvictionSample:   2,
+		EvictionInterval: time.Hour,
+	})
+	now := mockServer.clock.Now()
+
+	var expired []string
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("expired-key%d", i)
+		presetKeyData(mockServer, ctx, key, internal.KeyData{Value: "value", ExpireAt: now.Add(-time.Duration(i+1) * time.Second)})
+		expired = append(expired, key)
+	}
+	presetKeyData(mockServer, ctx, "volatile-key", internal.KeyData{Value: "value", ExpireAt: now.Add(time.Hour)})
+	presetKeyData(mockServer, ctx, "persisted-key", internal.KeyData{Value: "value", ExpireAt: now.Add(-time.Second)})
+	mockServer.setExpiry(ctx, "persisted-key", time.Time{}, false)
+	presetValue(mockServer, ctx, "key", "value")
+
+	stats := mockServer.getMemoryStats()
+	if stats.VolatileKeysCount != len(expired)+1 {
+		t.Errorf("expected %d volatile keys, got %d", len(expired)+1, stats.VolatileKeysCount)
+	}
+
+	if err := mockServer.activeExpireCycle(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// All the expired keys are removed in a single cycle, even though at most 2 are removed at a time.
+	for _, key := range expired {
+		s := mockServer.getShard(key)
+		if _, ok := s.store[key]; ok {
+			t.Errorf("expected key \"%s\" to be deleted", key)
+		}
+		if s.expiry.Contains(key) {
+			t.Errorf("expected key \"%s\" to be removed from the expiry index", key)
+		}
+	}
+	for _, key := range []string{"volatile-key", "persisted-key", "key"} {
+		if _, ok := mockServer.getShard(key).store[key]; !ok {
+			t.Errorf("expected key \"%s\" not to be deleted", key)
+		}
+	}
+
+	stats = mockServer.getMemoryStats()
+	if stats.VolatileKeysCount != 1 {
+		t.Errorf("expected 1 volatile key, got %d", stats.VolatileKeysCount)
+	}
+	if stats.ExpiredKeys != uint64(len(expired)) || stats.ActiveExpiredKeys != uint64(len(expired)) {
+		t.Errorf("expected %d expired keys, got %d (%d by the active expiry cycle)",
+			len(expired), stats.ExpiredKeys, stats.ActiveExpiredKeys)
+	}
+	if stats.ExpireCycles != 1 || stats.ExpireCyclesTimeLimited != 0 {
+		t.Errorf("expected 1 expiry cycle that was not time limited, got %d cycles (%d time limited)",
+			stats.ExpireCycles, stats.ExpireCyclesTimeLimited)
+	}
+
+	// Expired keys found while reading them are counted, but not as removed by the active expiry cycle.
+	presetKeyData(mockServer, ctx, "read-key", internal.KeyData{Value: "value", ExpireAt: now.Add(-time.Second)})
+	if value := mockServer.getValues(ctx, []string{"read-key"})["read-key"]; value != nil {
+		t.Errorf("expected the value of an expired key to be nil, got %v", value)
+	}
+	stats = mockServer.getMemoryStats()
+	if stats.ExpiredKeys != uint64(len(expired)+1) || stats.ActiveExpiredKeys != uint64(len(expired)) {
+		t.Errorf("expected %d expired keys (%d by the active expiry cycle), got %d (%d)",
+			len(expired)+1, len(expired), stats.ExpiredKeys, stats.ActiveExpiredKeys)
+	}
+
+	// A cycle that runs out of time stops without removing the expired keys, which are left for the next cycle.
+	presetKeyData(mockServer, ctx, "expired-key", internal.KeyData{Value: "value", ExpireAt: now.Add(-time.Second)})
+	mockServer.config.EvictionInterval = 0
+	if err := mockServer.activeExpireCycle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats = mockServer.getMemoryStats(); stats.ExpireCycles != 2 || stats.ExpireCyclesTimeLimited != 1 {
+		t.Errorf("expected 2 expiry cycles with 1 time limited, got %d cycles (%d time limited)",
+			stats.ExpireCycles, stats.ExpireCyclesTimeLimited)
+	}
+	if !mockServer.getShard("expired-key").expiry.Contains("expired-key") {
+		t.Error("expected \"expired-key\" to still be in the expiry index")
+	}
+}
+
+func Test_KeyspaceNotifications(t *testing.T) {
+	ctx := context.Background()
+
+	// subscribe subscribes to the channels and returns a function that reads the notifications published on them
+	// as "<channel> <message>" strings. The notifications of different channels may arrive in any order,
+	// so they're sorted.
+	subscribe := func(server *EchoVault, tag string, channels ...string) func() []string {
+		readMessage, err := server.Subscribe(tag, channels...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages := make(chan []string)
+		go func() {
+			for {
+				messages <- readMessage()
+			}
+		}()
+		// Skip the subscription confirmations.
+		for range channels {
+			<-messages
+		}
+		return func() []string {
+			var notifications []string
+			for {
+				select {
+				case message := <-messages:
+					notifications = append(notifications, message[1]+" "+message[2])
+				case <-time.After(200 * time.Millisecond):
+					// There are no more notifications.
+					slices.Sort(notifications)
+					return notifications
+				}
+			}
+		}
+	}
+
+	t.Run("Test_AllEvents", func(t *testing.T) {
+		mockServer := createEchoVaultWithConfig(config.Config{
+			DataDir:              "",
+			EvictionPolicy:       constants.NoEviction,
+			EvictionInterval:     time.Hour,
+			NotifyKeyspaceEvents: "KEA",
+		})
+		read := subscribe(mockServer, "keyspace-notifications-all",
+			"__keyspace@0__:notify-key1", "__keyspace@0__:notify-key2", "__keyspace@0__:notify-list1",
+			"__keyevent@0__:set", "__keyevent@0__:lpush", "__keyevent@0__:expire", "__keyevent@0__:del",
+			"__keyevent@0__:expired", "__keyevent@0__:new")
+
+		if _, _, err := mockServer.Set("notify-key1", "value1", SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mockServer.LPush("notify-list1", "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mockServer.Expire("notify-list1", 10, ExpireOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mockServer.Del("notify-key1"); err != nil {
+			t.Fatal(err)
+		}
+		expired := SetOptions{PXAT: int(mockServer.clock.Now().Add(-time.Second).UnixMilli())}
+		if _, _, err := mockServer.Set("notify-key2", "value2", expired); err != nil {
+			t.Fatal(err)
+		}
+		if err := mockServer.activeExpireCycle(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"__keyevent@0__:del notify-key1",
+			"__keyevent@0__:expire notify-key2",
+			"__keyevent@0__:expire notify-list1",
+			"__keyevent@0__:expired notify-key2",
+			"__keyevent@0__:lpush notify-list1",
+			"__keyevent@0__:set notify-key1",
+			"__keyevent@0__:set notify-key2",
+			"__keyspace@0__:notify-key1 del",
+			"__keyspace@0__:notify-key1 set",
+			"__keyspace@0__:notify-key2 expire",
+			"__keyspace@0__:notify-key2 expired",
+			"__keyspace@0__:notify-key2 set",
+			"__keyspace@0__:notify-list1 expire",
+			"__keyspace@0__:notify-list1 lpush",
+		}
+		if got := read(); !slices.Equal(got, want) {
+			t.Errorf("expected notifications %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Test_EventClasses", func(t *testing.T) {
+		// Only the keyspace notifications of list commands and new keys are published.
+		mockServer := createEchoVaultWithConfig(config.Config{
+			DataDir:              "",
+			EvictionPolicy:       constants.NoEviction,
+			NotifyKeyspaceEvents: "Kln",
+		})
+		read := subscribe(mockServer, "keyspace-notifications-classes",
+			"__keyspace@0__:notify-key1", "__keyspace@0__:notify-list1", "__keyevent@0__:lpush")
+
+		if _, _, err := mockServer.Set("notify-key1", "value1", SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mockServer.LPush("notify-list1", "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mockServer.Del("notify-list1"); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"__keyspace@0__:notify-key1 new",
+			"__keyspace@0__:notify-list1 lpush",
+			"__keyspace@0__:notify-list1 new",
+		}
+		if got := read(); !slices.Equal(got, want) {
+			t.Errorf("expected notifications %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Test_PatternSubscription", func(t *testing.T) {
+		mockServer := createEchoVaultWithConfig(config.Config{
+			DataDir:              "",
+			EvictionPolicy:       constants.NoEviction,
+			NotifyKeyspaceEvents: "KA",
+		})
+		readMessage, err := mockServer.PSubscribe("keyspace-notifications-pattern", "__keyspace@0__:*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message := readMessage(); !slices.Equal(message, []string{"psubscribe", "__keyspace@0__:*", "1"}) {
+			t.Fatalf("expected the psubscribe confirmation, got %v", message)
+		}
+
+		if _, _, err = mockServer.Set("notify-key1", "value1", SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Pattern subscribers receive the pattern, the channel the key was published on, and the event.
+		want := []string{"pmessage", "__keyspace@0__:*", "__keyspace@0__:notify-key1", "set"}
+		if got := readMessage(); !slices.Equal(got, want) {
+			t.Errorf("expected notification %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Test_InvalidConfig", func(t *testing.T) {
+		_, err := NewEchoVault(WithConfig(config.Config{NotifyKeyspaceEvents: "KEy"}))
+		if err == nil || !strings.Contains(err.Error(), "invalid keyspace event flag 'y'") {
+			t.Errorf("expected an invalid flag error, got %v", err)
+		}
+	})
+}
@@ -15,39 +15,118 @@
 package echovault
 
 import (
-	"container/heap"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/constants"
 	"log"
-	"math/rand"
-	"runtime"
 	"slices"
 	"strings"
 	"time"
 )
 
 func (server *EchoVault) keysExist(keys []string) map[string]bool {
-	server.storeLock.RLock()
-	defer server.storeLock.RUnlock()
+	unlock := server.rLockShards(keys)
+	defer unlock()
+	return server.keysExistUnlocked(keys)
+}
 
+// keysExistUnlocked is the same as keysExist but assumes the caller already holds the locks of the keys' shards.
+func (server *EchoVault) keysExistUnlocked(keys []string) map[string]bool {
 	exists := make(map[string]bool, len(keys))
 
 	for _, key := range keys {
-		_, ok := server.store[key]
+		_, ok := server.getShard(key).store[key]
 		exists[key] = ok
 	}
 
 	return exists
 }
 
+// scanKeys only read-locks each shard while the batch is taken from its index, so the other shards can still be
+// written to.
+func (server *EchoVault) scanKeys(cursor uint64, count int) ([]string, uint64) {
+	return server.scanShards(cursor, count, func(s *shard) ([]string, uint64) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		return scanShardKeys(s, server.clock.Now(), cursor, count)
+	})
+}
+
+// scanKeysUnlocked is the same as scanKeys but assumes the caller already holds the locks of all the shards.
+func (server *EchoVault) scanKeysUnlocked(cursor uint64, count int) ([]string, uint64) {
+	return server.scanShards(cursor, count, func(s *shard) ([]string, uint64) {
+		return scanShardKeys(s, server.clock.Now(), cursor, count)
+	})
+}
+
+// scanShards takes a batch from every shard with scan, and merges them into the batch of the whole keyspace.
+func (server *EchoVault) scanShards(cursor uint64, count int, scan func(s *shard) ([]string, uint64)) ([]string, uint64) {
+	batches := make([][]string, len(server.shards))
+	cursors := make([]uint64, len(server.shards))
+	for i, s := range server.shards {
+		batches[i], cursors[i] = scan(s)
+	}
+	return internal.MergeScans(count, batches, cursors)
+}
+
+// scanShardKeys returns the next batch of the shard's keys from the cursor, leaving out the expired keys.
+func scanShardKeys(s *shard, now time.Time, cursor uint64, count int) ([]string, uint64) {
+	keys, next := s.keys.Scan(cursor, count)
+	return slices.DeleteFunc(keys, func(key string) bool {
+		entry := s.store[key]
+		return entry.ExpireAt != (time.Time{}) && entry.ExpireAt.Before(now)
+	}), next
+}
+
+// yieldShardKeys yields the keys of the shard that are not expired.
+// Returns false if yield returned false.
+func yieldShardKeys(s *shard, now time.Time, yield func(key string) bool) bool {
+	for key, entry := range s.store {
+		if entry.ExpireAt != (time.Time{}) && entry.ExpireAt.Before(now) {
+			continue
+		}
+		if !yield(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// getScanIndex returns the scan index of the value at the key, building it with build if the key was
+// modified since the value was last scanned.
+func (server *EchoVault) getScanIndex(key string, build func() *internal.ScanIndex) *internal.ScanIndex {
+	s := server.getShard(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return server.getScanIndexUnlocked(key, build)
+}
+
+// getScanIndexUnlocked is the same as getScanIndex but assumes the caller already holds the lock of the
+// key's shard.
+func (server *EchoVault) getScanIndexUnlocked(key string, build func() *internal.ScanIndex) *internal.ScanIndex {
+	s := server.getShard(key)
+	s.scanIndexes.mutex.Lock()
+	defer s.scanIndexes.mutex.Unlock()
+	index, ok := s.scanIndexes.indexes[key]
+	if !ok {
+		index = build()
+		s.scanIndexes.indexes[key] = index
+	}
+	return index
+}
+
 func (server *EchoVault) getExpiry(key string) time.Time {
-	server.storeLock.RLock()
-	defer server.storeLock.RUnlock()
+	s := server.getShard(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return server.getExpiryUnlocked(key)
+}
 
-	entry, ok := server.store[key]
+// getExpiryUnlocked is the same as getExpiry but assumes the caller already holds the lock of the key's shard.
+func (server *EchoVault) getExpiryUnlocked(key string) time.Time {
+	entry, ok := server.getShard(key).store[key]
 	if !ok {
 		return time.Time{}
 	}
@@ -55,38 +134,63 @@ func (server *EchoVault) getExpiry(key string) time.Time {
 	return entry.ExpireAt
 }
 
+// getValues only holds the read locks of the keys' shards while the values are read.
+// Expired keys are deleted once the read locks are released.
 func (server *EchoVault) getValues(ctx context.Context, keys []string) map[string]interface{} {
-	server.storeLock.Lock()
-	defer server.storeLock.Unlock()
+	unlock := server.rLockShards(keys)
+	values := make(map[string]interface{}, len(keys))
+	var expired []string
+	for _, key := range keys {
+		entry, ok := server.getShard(key).store[key]
+		switch {
+		case !ok:
+			values[key] = nil
+		case server.isExpired(ctx, entry):
+			expired = append(expired, key)
+			values[key] = nil
+		default:
+			values[key] = entry.Value
+		}
+	}
+	unlock()
 
+	for _, key := range expired {
+		if server.isInCluster() && !appliedThroughRaft(ctx) {
+			server.removeExpiredKey(ctx, key)
+			continue
+		}
+		s := server.getShard(key)
+		s.mutex.Lock()
+		// The key may have been updated since the read lock was released.
+		if entry, ok := s.store[key]; ok && server.isExpired(ctx, entry) {
+			server.removeExpiredKey(ctx, key)
+		}
+		s.mutex.Unlock()
+	}
+
+	// Asynchronously update the keys in the cache.
+	go func(ctx context.Context, keys []string) {
+		if err := server.updateKeysInCache(ctx, keys); err != nil {
+			log.Printf("getValues error: %+v\n", err)
+		}
+	}(ctx, keys)
+
+	return values
+}
+
+// getValuesUnlocked is the same as getValues but assumes the caller already holds the write locks of the keys' shards.
+func (server *EchoVault) getValuesUnlocked(ctx context.Context, keys []string) map[string]interface{} {
 	values := make(map[string]interface{}, len(keys))
 
 	for _, key := range keys {
-		entry, ok := server.store[key]
+		entry, ok := server.getShard(key).store[key]
 		if !ok {
 			values[key] = nil
 			continue
 		}
 
-		if entry.ExpireAt != (time.Time{}) && entry.ExpireAt.Before(server.clock.Now()) {
-			if !server.isInCluster() {
-				// If in standalone mode, delete the key directly.
-				err := server.deleteKey(key)
-				if err != nil {
-					log.Printf("keyExists: %+v\n", err)
-				}
-			} else if server.isInCluster() && server.raft.IsRaftLeader() {
-				// If we're in a raft cluster, and we're the leader, send command to delete the key in the cluster.
-				err := server.raftApplyDeleteKey(ctx, key)
-				if err != nil {
-					log.Printf("keyExists: %+v\n", err)
-				}
-			} else if server.isInCluster() && !server.raft.IsRaftLeader() {
-				// Forward message to leader to initiate key deletion.
-				// This is always called regardless of ForwardCommand config value
-				// because we always want to remove expired keys.
-				server.memberList.ForwardDeleteKey(ctx, key)
-			}
+		if server.isExpired(ctx, entry) {
+			server.removeExpiredKey(ctx, key)
 			values[key] = nil
 			continue
 		}
@@ -104,23 +208,88 @@ func (server *EchoVault) getValues(ctx context.Context, keys []string) map[strin
 	return values
 }
 
+// isExpired returns whether the entry has an expiry time that has passed.
+// Requests applied from the raft log are checked against the leader's time, so every node expires the same keys.
+func (server *EchoVault) isExpired(ctx context.Context, entry internal.KeyData) bool {
+	now := server.clock.Now()
+	if t, ok := ctx.Value(internal.ContextTimestamp("Timestamp")).(time.Time); ok {
+		now = t
+	}
+	return entry.ExpireAt != (time.Time{}) && entry.ExpireAt.Before(now)
+}
+
+// appliedThroughRaft returns whether the context belongs to a request that's being applied from the raft log.
+func appliedThroughRaft(ctx context.Context) bool {
+	_, ok := ctx.Value(internal.ContextTimestamp("Timestamp")).(time.Time)
+	return ok
+}
+
+// removeExpiredKey deletes an expired key that was found while reading it.
+// In standalone mode, and while a request is applied from the raft log, the caller must hold the write lock
+// of the key's shard.
+func (server *EchoVault) removeExpiredKey(ctx context.Context, key string) {
+	if !server.isInCluster() || appliedThroughRaft(ctx) {
+		// If in standalone mode, delete the key directly. Every node of a cluster applies the request, so they all
+		// delete the key. Applying a delete-key request from within the log would deadlock the leader.
+		err := server.deleteKey(key, expiredEvent)
+		if err != nil {
+			log.Printf("keyExists: %+v\n", err)
+			return
+		}
+		server.expiryStats.expiredKeys.Add(1)
+	} else if server.isInCluster() && server.raft.IsRaftLeader() {
+		// If we're in a raft cluster, and we're the leader, send command to delete the key in the cluster.
+		err := server.raftApplyDeleteKey(ctx, key, expiredEvent)
+		if err != nil {
+			log.Printf("keyExists: %+v\n", err)
+			return
+		}
+		server.expiryStats.expiredKeys.Add(1)
+	} else if server.isInCluster() && !server.raft.IsRaftLeader() {
+		// Forward message to leader to initiate key deletion.
+		// This is always called regardless of ForwardCommand config value
+		// because we always want to remove expired keys.
+		server.memberList.ForwardDeleteKey(ctx, key)
+	}
+}
+
 func (server *EchoVault) setValues(ctx context.Context, entries map[string]interface{}) error {
-	server.storeLock.Lock()
-	defer server.storeLock.Unlock()
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	unlock := server.lockShards(keys)
+	defer unlock()
+	return server.setValuesUnlocked(ctx, entries)
+}
 
-	if internal.IsMaxMemoryExceeded(server.config.MaxMemory) && server.config.EvictionPolicy == constants.NoEviction {
+// setValuesUnlocked is the same as setValues but assumes the caller already holds the write locks of the keys' shards.
+func (server *EchoVault) setValuesUnlocked(ctx context.Context, entries map[string]interface{}) error {
+	if server.isMaxMemoryExceeded() && server.config.EvictionPolicy == constants.NoEviction {
 		return errors.New("max memory reached, key value not set")
 	}
 
 	for key, value := range entries {
+		server.preserveKey(key)
+		s := server.getShard(key)
 		expireAt := time.Time{}
-		if _, ok := server.store[key]; ok {
-			expireAt = server.store[key].ExpireAt
+		entry, ok := s.store[key]
+		if ok {
+			expireAt = entry.ExpireAt
 		}
-		server.store[key] = internal.KeyData{
+		s.store[key] = internal.KeyData{
 			Value:    value,
 			ExpireAt: expireAt,
+			Version:  entry.Version,
 		}
+		if !ok {
+			s.keys.Add(key)
+		}
+		server.keysModifiedUnlocked([]string{key})
+		if !ok {
+			server.notifyKeyspaceEvent(internal.KeyspaceEventsNew, newEvent, key)
+		}
+		server.notifyCommandEvent(ctx, key)
 		if !server.isInCluster() {
 			server.snapshotEngine.IncrementChangeCount()
 		}
@@ -140,20 +309,31 @@ func (server *EchoVault) setValues(ctx context.Context, entries map[string]inter
 }
 
 func (server *EchoVault) setExpiry(ctx context.Context, key string, expireAt time.Time, touch bool) {
-	server.storeLock.Lock()
-	defer server.storeLock.Unlock()
+	s := server.getShard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	server.setExpiryUnlocked(ctx, key, expireAt, touch)
+}
 
-	server.store[key] = internal.KeyData{
-		Value:    server.store[key].Value,
-		ExpireAt: expireAt,
-	}
+// setExpiryUnlocked is the same as setExpiry but assumes the caller already holds the write lock of the key's shard.
+func (server *EchoVault) setExpiryUnlocked(ctx context.Context, key string, expireAt time.Time, touch bool) {
+	server.preserveKey(key)
+	s := server.getShard(key)
+	entry := s.store[key]
+	previous := entry.ExpireAt
+	entry.ExpireAt = expireAt
+	s.store[key] = entry
+	server.touchWatchedKey(key)
 
-	// If the slice of keys associated with expiry time does not contain the current key, add the key.
-	server.keysWithExpiry.rwMutex.Lock()
-	if !slices.Contains(server.keysWithExpiry.keys, key) {
-		server.keysWithExpiry.keys = append(server.keysWithExpiry.keys, key)
+	switch {
+	case !expireAt.IsZero():
+		server.notifyKeyspaceEvent(internal.KeyspaceEventsGeneric, expireEvent, key)
+	case !previous.IsZero():
+		server.notifyKeyspaceEvent(internal.KeyspaceEventsGeneric, persistEvent, key)
 	}
-	server.keysWithExpiry.rwMutex.Unlock()
+
+	// Index the key by its new expiry time. A zero expiry time removes the key from the index.
+	s.expiry.Update(key, expireAt)
 
 	// If touch is true, update the keys status in the cache.
 	if touch {
@@ -166,44 +346,60 @@ func (server *EchoVault) setExpiry(ctx context.Context, key string, expireAt tim
 	}
 }
 
-func (server *EchoVault) deleteKey(key string) error {
-	// Delete the key from keyLocks and store.
-	delete(server.store, key)
+// keysModified is called after a write command has been executed on the keys.
+// It invalidates the transactions watching the keys, updates their memory usage and wakes up the commands
+// blocked on them. Handlers often modify values in place without calling SetValues, so this is called for every
+// write command in addition to the calls made from setValues and deleteKey.
+func (server *EchoVault) keysModified(keys []string) {
+	unlock := server.lockShards(keys)
+	defer unlock()
+	server.keysModifiedUnlocked(keys)
+}
 
-	// Remove key from slice of keys associated with expiry.
-	server.keysWithExpiry.rwMutex.Lock()
-	defer server.keysWithExpiry.rwMutex.Unlock()
-	server.keysWithExpiry.keys = slices.DeleteFunc(server.keysWithExpiry.keys, func(k string) bool {
-		return k == key
-	})
+// keysModifiedUnlocked is the same as keysModified but assumes the caller already holds the write locks of the keys' shards.
+func (server *EchoVault) keysModifiedUnlocked(keys []string) {
+	for _, key := range keys {
+		server.touchWatchedKey(key)
+		s := server.getShard(key)
+		s.scanIndexes.mutex.Lock()
+		delete(s.scanIndexes.indexes, key)
+		s.scanIndexes.mutex.Unlock()
+	}
+	server.updateMemoryUsage(keys)
+	server.signalKeys(keys)
+}
+
+// deleteKey deletes the key from its shard and publishes the keyspace event, which is either del, expired or evicted.
+// The caller must hold the write lock of the key's shard.
+func (server *EchoVault) deleteKey(key string, event string) error {
+	server.preserveKey(key)
+	s := server.getShard(key)
+
+	// Delete the key from the store.
+	delete(s.store, key)
+	s.keys.Remove(key)
+	server.keysModifiedUnlocked([]string{key})
+
+	// Remove the key from the expiry index.
+	s.expiry.Delete(key)
 
 	// Remove the key from the cache.
 	switch {
 	case slices.Contains([]string{constants.AllKeysLFU, constants.VolatileLFU}, server.config.EvictionPolicy):
-		server.lfuCache.cache.Delete(key)
+		s.lfuCache.mutex.Lock()
+		s.lfuCache.cache.Delete(key)
+		s.lfuCache.mutex.Unlock()
 	case slices.Contains([]string{constants.AllKeysLRU, constants.VolatileLRU}, server.config.EvictionPolicy):
-		server.lruCache.cache.Delete(key)
+		s.lruCache.mutex.Lock()
+		s.lruCache.cache.Delete(key)
+		s.lruCache.mutex.Unlock()
 	}
 
 	log.Printf("deleted key %s\n", key)
 
-	return nil
-}
+	server.notifyKeyspaceEvent(deleteEventClass(event), event, key)
 
-func (server *EchoVault) getState() map[string]interface{} {
-	// Wait unit there's no state mutation or copy in progress before starting a new copy process.
-	for {
-		if !server.stateCopyInProgress.Load() && !server.stateMutationInProgress.Load() {
-			server.stateCopyInProgress.Store(true)
-			break
-		}
-	}
-	data := make(map[string]interface{})
-	for k, v := range server.store {
-		data[k] = v
-	}
-	server.stateCopyInProgress.Store(false)
-	return data
+	return nil
 }
 
 // updateKeysInCache updates either the key access count or the most recent access time in the cache
@@ -218,27 +414,32 @@ func (server *EchoVault) updateKeysInCache(ctx context.Context, keys []string) e
 		if server.config.MaxMemory == 0 {
 			return nil
 		}
+		s := server.getShard(key)
 		switch strings.ToLower(server.config.EvictionPolicy) {
 		case constants.AllKeysLFU:
-			server.lfuCache.mutex.Lock()
-			server.lfuCache.cache.Update(key)
-			server.lfuCache.mutex.Unlock()
+			s.lfuCache.mutex.Lock()
+			s.lfuCache.cache.Update(key)
+			s.lfuCache.mutex.Unlock()
 		case constants.AllKeysLRU:
-			server.lruCache.mutex.Lock()
-			server.lruCache.cache.Update(key)
-			server.lruCache.mutex.Unlock()
+			s.lruCache.mutex.Lock()
+			s.lruCache.cache.Update(key)
+			s.lruCache.mutex.Unlock()
 		case constants.VolatileLFU:
-			server.lfuCache.mutex.Lock()
-			if server.store[key].ExpireAt != (time.Time{}) {
-				server.lfuCache.cache.Update(key)
+			s.mutex.RLock()
+			s.lfuCache.mutex.Lock()
+			if s.store[key].ExpireAt != (time.Time{}) {
+				s.lfuCache.cache.Update(key)
 			}
-			server.lfuCache.mutex.Unlock()
+			s.lfuCache.mutex.Unlock()
+			s.mutex.RUnlock()
 		case constants.VolatileLRU:
-			server.lruCache.mutex.Lock()
-			if server.store[key].ExpireAt != (time.Time{}) {
-				server.lruCache.cache.Update(key)
+			s.mutex.RLock()
+			s.lruCache.mutex.Lock()
+			if s.store[key].ExpireAt != (time.Time{}) {
+				s.lruCache.cache.Update(key)
 			}
-			server.lruCache.mutex.Unlock()
+			s.lruCache.mutex.Unlock()
+			s.mutex.RUnlock()
 		}
 		if err := server.adjustMemoryUsage(ctx); err != nil {
 			return fmt.Errorf("updateKeysInCache: %+v", err)
@@ -247,236 +448,87 @@ func (server *EchoVault) updateKeysInCache(ctx context.Context, keys []string) e
 	return nil
 }
 
-// adjustMemoryUsage should only be called from standalone echovault or from raft cluster leader.
-func (server *EchoVault) adjustMemoryUsage(ctx context.Context) error {
-	// If max memory is 0, there's no need to adjust memory usage.
-	if server.config.MaxMemory == 0 {
-		return nil
-	}
-	// Check if memory usage is above max-memory.
-	// If it is, pop items from the cache until we get under the limit.
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	// If we're using less memory than the max-memory, there's no need to evict.
-	if memStats.HeapInuse < server.config.MaxMemory {
-		return nil
-	}
-	// Force a garbage collection first before we start evicting key.
-	runtime.GC()
-	runtime.ReadMemStats(&memStats)
-	if memStats.HeapInuse < server.config.MaxMemory {
-		return nil
-	}
-	// We've done a GC, but we're still at or above the max memory limit.
-	// Start a loop that evicts keys until either the heap is empty or
-	// we're below the max memory limit.
-	server.storeLock.Lock()
-	defer server.storeLock.Unlock()
-	switch {
-	case slices.Contains([]string{constants.AllKeysLFU, constants.VolatileLFU}, strings.ToLower(server.config.EvictionPolicy)):
-		// Remove keys from LFU cache until we're below the max memory limit or
-		// until the LFU cache is empty.
-		server.lfuCache.mutex.Lock()
-		defer server.lfuCache.mutex.Unlock()
-		for {
-			// Return if cache is empty
-			if server.lfuCache.cache.Len() == 0 {
-				return fmt.Errorf("adjsutMemoryUsage -> LFU cache empty")
-			}
-
-			key := heap.Pop(&server.lfuCache.cache).(string)
-			if !server.isInCluster() {
-				// If in standalone mode, directly delete the key
-				if err := server.deleteKey(key); err != nil {
-					return fmt.Errorf("adjustMemoryUsage -> LFU cache eviction: %+v", err)
-				}
-			} else if server.isInCluster() && server.raft.IsRaftLeader() {
-				// If in raft cluster, send command to delete key from cluster
-				if err := server.raftApplyDeleteKey(ctx, key); err != nil {
-					return fmt.Errorf("adjustMemoryUsage -> LFU cache eviction: %+v", err)
-				}
-			}
-
-			// Run garbage collection
-			runtime.GC()
-			// Return if we're below max memory
-			runtime.ReadMemStats(&memStats)
-			if memStats.HeapInuse < server.config.MaxMemory {
-				return nil
-			}
-		}
-	case slices.Contains([]string{constants.AllKeysLRU, constants.VolatileLRU}, strings.ToLower(server.config.EvictionPolicy)):
-		// Remove keys from th LRU cache until we're below the max memory limit or
-		// until the LRU cache is empty.
-		server.lruCache.mutex.Lock()
-		defer server.lruCache.mutex.Unlock()
-		for {
-			// Return if cache is empty
-			if server.lruCache.cache.Len() == 0 {
-				return fmt.Errorf("adjsutMemoryUsage -> LRU cache empty")
-			}
-
-			key := heap.Pop(&server.lruCache.cache).(string)
-			if !server.isInCluster() {
-				// If in standalone mode, directly delete the key.
-				if err := server.deleteKey(key); err != nil {
-					return fmt.Errorf("adjustMemoryUsage -> LRU cache eviction: %+v", err)
-				}
-			} else if server.isInCluster() && server.raft.IsRaftLeader() {
-				// If in cluster mode and the node is a cluster leader,
-				// send command to delete the key from the cluster.
-				if err := server.raftApplyDeleteKey(ctx, key); err != nil {
-					return fmt.Errorf("adjustMemoryUsage -> LRU cache eviction: %+v", err)
-				}
-			}
-
-			// Run garbage collection
-			runtime.GC()
-			// Return if we're below max memory
-			runtime.ReadMemStats(&memStats)
-			if memStats.HeapInuse < server.config.MaxMemory {
-				return nil
-			}
-		}
-	case slices.Contains([]string{constants.AllKeysRandom}, strings.ToLower(server.config.EvictionPolicy)):
-		// Remove random keys until we're below the max memory limit
-		// or there are no more keys remaining.
-		for {
-			server.storeLock.Lock()
-			// If there are no keys, return error
-			if len(server.store) == 0 {
-				err := errors.New("no keys to evict")
-				server.storeLock.Unlock()
-				return fmt.Errorf("adjustMemoryUsage -> all keys random: %+v", err)
-			}
-			// Get random key
-			idx := rand.Intn(len(server.store))
-			for key, _ := range server.store {
-				if idx == 0 {
-					if !server.isInCluster() {
-						// If in standalone mode, directly delete the key
-						if err := server.deleteKey(key); err != nil {
-							return fmt.Errorf("adjustMemoryUsage -> all keys random: %+v", err)
-						}
-					} else if server.isInCluster() && server.raft.IsRaftLeader() {
-						if err := server.raftApplyDeleteKey(ctx, key); err != nil {
-							return fmt.Errorf("adjustMemoryUsage -> all keys random: %+v", err)
-						}
-					}
-					// Run garbage collection
-					runtime.GC()
-					// Return if we're below max memory
-					runtime.ReadMemStats(&memStats)
-					if memStats.HeapInuse < server.config.MaxMemory {
-						return nil
-					}
-				}
-				idx--
-			}
-		}
-	case slices.Contains([]string{constants.VolatileRandom}, strings.ToLower(server.config.EvictionPolicy)):
-		// Remove random keys with an associated expiry time until we're below the max memory limit
-		// or there are no more keys with expiry time.
-		for {
-			// Get random volatile key
-			server.keysWithExpiry.rwMutex.RLock()
-			idx := rand.Intn(len(server.keysWithExpiry.keys))
-			key := server.keysWithExpiry.keys[idx]
-			server.keysWithExpiry.rwMutex.RUnlock()
-
-			if !server.isInCluster() {
-				// If in standalone mode, directly delete the key
-				if err := server.deleteKey(key); err != nil {
-					return fmt.Errorf("adjustMemoryUsage -> volatile keys random: %+v", err)
-				}
-			} else if server.isInCluster() && server.raft.IsRaftLeader() {
-				if err := server.raftApplyDeleteKey(ctx, key); err != nil {
-					return fmt.Errorf("adjustMemoryUsage -> volatile keys randome: %+v", err)
-				}
-			}
-
-			// Run garbage collection
-			runtime.GC()
-			// Return if we're below max memory
-			runtime.ReadMemStats(&memStats)
-			if memStats.HeapInuse < server.config.MaxMemory {
-				return nil
-			}
-		}
-	default:
-		return nil
-	}
-}
-
-// evictKeysWithExpiredTTL is a function that samples keys with an associated TTL
-// and evicts keys that are currently expired.
-// This function will sample 20 keys from the list of keys with an associated TTL,
-// if the key is expired, it will be evicted.
+// activeExpireCycle removes the keys whose expiry time has passed, without waiting for them to be accessed.
+// The expiry index of each shard is ordered by expiry time, so only the expired keys are visited, in the order
+// they expired. At most EvictionSample keys are removed from a shard each time its lock is acquired, and the cycle
+// stops once it has run for a quarter of the EvictionInterval, so that many keys expiring at once can't hold up
+// other commands. The next cycle starts from the shard where the previous one stopped.
 // This function is only executed in standalone mode or by the raft cluster leader.
-func (server *EchoVault) evictKeysWithExpiredTTL(ctx context.Context) error {
+func (server *EchoVault) activeExpireCycle(ctx context.Context) error {
 	// Only execute this if we're in standalone mode, or raft cluster leader.
 	if server.isInCluster() && !server.raft.IsRaftLeader() {
 		return nil
 	}
 
-	server.keysWithExpiry.rwMutex.RLock()
-
-	// Sample size should be the configured sample size, or the size of the keys with expiry,
-	// whichever one is smaller.
-	sampleSize := int(server.config.EvictionSample)
-	if len(server.keysWithExpiry.keys) < sampleSize {
-		sampleSize = len(server.keysWithExpiry.keys)
-	}
-	keys := make([]string, sampleSize)
-
+	server.expiryStats.cycles.Add(1)
+	batchSize := max(int(server.config.EvictionSample), 1)
+	deadline := time.Now().Add(server.config.EvictionInterval / 4)
+	start := int(server.expiryStats.nextShard.Load())
 	deletedCount := 0
-	thresholdPercentage := 20
+	defer func() {
+		if deletedCount > 0 {
+			log.Printf("%d expired keys deleted\n", deletedCount)
+		}
+	}()
 
-	var idx int
-	var key string
-	for i := 0; i < len(keys); i++ {
+	for i := 0; i < shardCount; i++ {
+		index := (start + i) % shardCount
 		for {
-			// Retry retrieval of a random key until we find a key that is not already in the list of sampled keys.
-			idx = rand.Intn(len(server.keysWithExpiry.keys))
-			key = server.keysWithExpiry.keys[idx]
-			if !slices.Contains(keys, key) {
-				keys[i] = key
-				break
+			if time.Now().After(deadline) {
+				server.expiryStats.cyclesTimeLimit.Add(1)
+				server.expiryStats.nextShard.Store(int64(index))
+				return nil
 			}
-		}
-	}
-	server.keysWithExpiry.rwMutex.RUnlock()
-
-	// Loop through the keys and delete them if they're expired
-	server.storeLock.Lock()
-	defer server.storeLock.Unlock()
-	for _, k := range keys {
-		// Delete the expired key
-		deletedCount += 1
-		if !server.isInCluster() {
-			if err := server.deleteKey(k); err != nil {
-				return fmt.Errorf("evictKeysWithExpiredTTL -> standalone delete: %+v", err)
+			deleted, err := server.expireShardKeys(ctx, server.shards[index], batchSize)
+			deletedCount += deleted
+			if err != nil {
+				return err
 			}
-		} else if server.isInCluster() && server.raft.IsRaftLeader() {
-			if err := server.raftApplyDeleteKey(ctx, k); err != nil {
-				return fmt.Errorf("evictKeysWithExpiredTTL -> cluster delete: %+v", err)
+			if deleted < batchSize {
+				// There are no expired keys left in the shard.
+				break
 			}
 		}
 	}
 
-	// If sampleSize is 0, there's no need to calculate deleted percentage.
-	if sampleSize == 0 {
-		return nil
+	return nil
+}
+
+// expireShardKeys removes up to limit keys of the shard whose expiry time has passed.
+// Returns the number of keys removed.
+func (server *EchoVault) expireShardKeys(ctx context.Context, s *shard, limit int) (int, error) {
+	s.mutex.Lock()
+	keys := s.expiry.PopExpired(server.clock.Now(), limit)
+
+	if !server.isInCluster() {
+		defer s.mutex.Unlock()
+		for i, key := range keys {
+			if err := server.deleteKey(key, expiredEvent); err != nil {
+				return i, fmt.Errorf("activeExpireCycle -> standalone delete: %+v", err)
+			}
+			server.expiryStats.expiredKeys.Add(1)
+			server.expiryStats.activeExpiredKeys.Add(1)
+		}
+		return len(keys), nil
 	}
 
-	log.Printf("%d keys sampled, %d keys deleted\n", sampleSize, deletedCount)
+	s.mutex.Unlock()
 
-	// If the deleted percentage is over 20% of the sample size, execute the function again immediately.
-	if (deletedCount/sampleSize)*100 >= thresholdPercentage {
-		log.Printf("deletion ratio (%d percent) reached threshold (%d percent), sampling again\n",
-			(deletedCount/sampleSize)*100, thresholdPercentage)
-		return server.evictKeysWithExpiredTTL(ctx)
+	// In cluster mode, the deletion is applied through raft, which needs the shard's lock.
+	for i, key := range keys {
+		if err := server.raftApplyDeleteKey(ctx, key, expiredEvent); err != nil {
+			// Put the keys that were not deleted back in the expiry index, so that the next cycle retries them.
+			s.mutex.Lock()
+			for _, key := range keys[i:] {
+				if entry, ok := s.store[key]; ok {
+					s.expiry.Update(key, entry.ExpireAt)
+				}
+			}
+			s.mutex.Unlock()
+			return i, fmt.Errorf("activeExpireCycle -> cluster delete: %+v", err)
+		}
+		server.expiryStats.expiredKeys.Add(1)
+		server.expiryStats.activeExpiredKeys.Add(1)
 	}
-
-	return nil
+	return len(keys), nil
 }
@@ -0,0 +1,176 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/memory"
+	"runtime"
+	"strings"
+)
+
+// updateMemoryUsage re-estimates the memory used by each of the keys and updates the totals.
+// Keys that no longer exist are removed from the totals. The caller must hold the write locks of the keys' shards.
+func (server *EchoVault) updateMemoryUsage(keys []string) {
+	for _, key := range keys {
+		s := server.getShard(key)
+		previous := s.memoryUsage.keys[key]
+		var usage int64
+		if entry, ok := s.store[key]; ok {
+			usage = memory.KeyUsage(key, entry.Value, memory.DefaultSamples)
+			s.memoryUsage.keys[key] = usage
+		} else {
+			delete(s.memoryUsage.keys, key)
+		}
+		s.memoryUsage.total.Add(usage - previous)
+		if total := server.memoryUsage.total.Add(usage - previous); total > server.memoryUsage.peak.Load() {
+			server.memoryUsage.peak.Store(total)
+		}
+	}
+}
+
+// isMaxMemoryExceeded returns whether the estimated memory used by the store is at or above max-memory.
+func (server *EchoVault) isMaxMemoryExceeded() bool {
+	return server.config.MaxMemory != 0 && uint64(server.memoryUsage.total.Load()) >= server.config.MaxMemory
+}
+
+func (server *EchoVault) getMemoryStats() internal.MemoryStats {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	keysCount, volatileKeysCount := 0, 0
+	for _, s := range server.shards {
+		s.mutex.RLock()
+		keysCount += len(s.store)
+		volatileKeysCount += s.expiry.Len()
+		s.mutex.RUnlock()
+	}
+
+	return internal.MemoryStats{
+		TotalAllocated:   memStats.HeapAlloc,
+		DatasetBytes:     server.memoryUsage.total.Load(),
+		PeakDatasetBytes: server.memoryUsage.peak.Load(),
+		KeysCount:        keysCount,
+		EvictedKeys:      server.memoryUsage.evictedKeys.Load(),
+		MaxMemory:        server.config.MaxMemory,
+		EvictionPolicy:   server.config.EvictionPolicy,
+
+		VolatileKeysCount:       volatileKeysCount,
+		ExpiredKeys:             server.expiryStats.expiredKeys.Load(),
+		ActiveExpiredKeys:       server.expiryStats.activeExpiredKeys.Load(),
+		ExpireCycles:            server.expiryStats.cycles.Load(),
+		ExpireCyclesTimeLimited: server.expiryStats.cyclesTimeLimit.Load(),
+
+		DroppedNotifications: server.pubSub.DroppedNotifications(),
+	}
+}
+
+// adjustMemoryUsage should only be called from standalone echovault or from raft cluster leader.
+// It evicts keys according to the eviction policy until the estimated memory used by the store is
+// below max-memory. Keys are evicted from the shard that uses the most memory and has a key to evict,
+// following the order of that shard's eviction cache.
+func (server *EchoVault) adjustMemoryUsage(ctx context.Context) error {
+	policy := strings.ToLower(server.config.EvictionPolicy)
+	for server.isMaxMemoryExceeded() {
+		key, err := server.evictKey(policy)
+		if err != nil {
+			return fmt.Errorf("adjustMemoryUsage -> %s: %+v", policy, err)
+		}
+		if key == "" {
+			// The policy does not evict keys.
+			return nil
+		}
+
+		if server.isInCluster() && server.raft.IsRaftLeader() {
+			// If in cluster mode and the node is a cluster leader,
+			// send command to delete the key from the cluster.
+			if err = server.raftApplyDeleteKey(ctx, key, evictedEvent); err != nil {
+				return fmt.Errorf("adjustMemoryUsage -> %s eviction: %+v", policy, err)
+			}
+		}
+		server.memoryUsage.evictedKeys.Add(1)
+	}
+
+	return nil
+}
+
+// evictKey picks the next key to evict with the eviction policy, starting with the shard that uses the most memory.
+// In standalone mode, the key is deleted before its shard is unlocked. In cluster mode, the caller has to delete it.
+// It returns an empty key if the policy does not evict keys, and an error if there are no keys left to evict.
+func (server *EchoVault) evictKey(policy string) (string, error) {
+	var lastErr error
+	for _, s := range server.shardsBySize() {
+		s.mutex.Lock()
+		key, err := server.nextKeyToEvict(s, policy)
+		if err != nil {
+			// There are no keys to evict in this shard, try the next one.
+			s.mutex.Unlock()
+			lastErr = err
+			continue
+		}
+		if key != "" && !server.isInCluster() {
+			// If in standalone mode, directly delete the key.
+			err = server.deleteKey(key, evictedEvent)
+		}
+		s.mutex.Unlock()
+		return key, err
+	}
+	return "", lastErr
+}
+
+// nextKeyToEvict returns the next key of the shard to evict with the eviction policy.
+// It returns an empty key if the policy does not evict keys, and an error if there are no keys left to evict.
+// The caller must hold the write lock of the shard.
+func (server *EchoVault) nextKeyToEvict(s *shard, policy string) (string, error) {
+	switch policy {
+	case constants.AllKeysLFU, constants.VolatileLFU:
+		s.lfuCache.mutex.Lock()
+		defer s.lfuCache.mutex.Unlock()
+		if s.lfuCache.cache.Len() == 0 {
+			return "", errors.New("LFU cache empty")
+		}
+		return heap.Pop(&s.lfuCache.cache).(string), nil
+
+	case constants.AllKeysLRU, constants.VolatileLRU:
+		s.lruCache.mutex.Lock()
+		defer s.lruCache.mutex.Unlock()
+		if s.lruCache.cache.Len() == 0 {
+			return "", errors.New("LRU cache empty")
+		}
+		return heap.Pop(&s.lruCache.cache).(string), nil
+
+	case constants.AllKeysRandom:
+		// The iteration order of a map is random, so the first key is a random key.
+		for key := range s.store {
+			return key, nil
+		}
+		return "", errors.New("no keys to evict")
+
+	case constants.VolatileRandom:
+		key, ok := s.expiry.Random()
+		if !ok {
+			return "", errors.New("no volatile keys to evict")
+		}
+		return key, nil
+
+	default:
+		return "", nil
+	}
+}
@@ -20,10 +20,10 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/clock"
-	"github.com/echovault/echovault/internal/constants"
 	"io"
 	"net"
 	"strings"
+	"time"
 )
 
 func (server *EchoVault) getCommand(cmd string) (internal.Command, error) {
@@ -39,10 +39,13 @@ func (server *EchoVault) getCommand(cmd string) (internal.Command, error) {
 
 func (server *EchoVault) getHandlerFuncParams(ctx context.Context, cmd []string, conn *net.Conn) internal.HandlerFuncParams {
 	return internal.HandlerFuncParams{
-		Context:               ctx,
+		Context:               context.WithValue(ctx, internal.ContextCommandEvent("CommandEvent"), newCommandEvent(cmd)),
 		Command:               cmd,
 		Connection:            conn,
+		Protocol:              server.getConnectionInfo(conn).Protocol,
 		KeysExist:             server.keysExist,
+		ScanKeys:              server.scanKeys,
+		GetScanIndex:          server.getScanIndex,
 		GetExpiry:             server.getExpiry,
 		GetValues:             server.getValues,
 		SetValues:             server.setValues,
@@ -56,12 +59,50 @@ func (server *EchoVault) getHandlerFuncParams(ctx context.Context, cmd []string,
 		GetPubSub:             server.getPubSub,
 		GetACL:                server.getACL,
 		GetAllCommands:        server.getCommands,
-		GetClock:              server.getClock,
+		GetClock: func() clock.Clock {
+			// Commands applied through raft use the leader's time, so every node produces the same result.
+			if t, ok := ctx.Value(internal.ContextTimestamp("Timestamp")).(time.Time); ok {
+				return clock.FixedClock{Time: t}
+			}
+			return server.getClock()
+		},
 		DeleteKey: func(key string) error {
-			server.storeLock.Lock()
-			defer server.storeLock.Unlock()
-			return server.deleteKey(key)
+			unlock := server.lockShards([]string{key})
+			defer unlock()
+			return server.deleteKey(key, delEvent)
 		},
+		Multi:   server.multi,
+		Exec:    server.exec,
+		Discard: server.discard,
+		Watch:   server.watch,
+		Unwatch: server.unwatch,
+
+		Eval:         server.eval,
+		EvalSHA:      server.evalSHA,
+		ScriptLoad:   server.scriptLoad,
+		ScriptExists: server.scriptExists,
+		ScriptFlush:  server.scriptFlush,
+		ScriptKill:   server.scriptKill,
+
+		GetConnectionInfo:  server.getConnectionInfo,
+		SetConnectionInfo:  server.setConnectionInfo,
+		SetReadConsistency: server.setReadConsistency,
+		GetClusterShards:   server.getClusterShards,
+		SetSlot:            server.setSlot,
+		MigrateSlot:        server.migrateSlot,
+		Asking:             server.asking,
+		GetClusterInfo:     server.getClusterInfo,
+		GetClusterNodes:    server.getClusterNodes,
+		LeaveCluster:       server.leaveCluster,
+		RemoveClusterNode:  server.removeClusterNode,
+		PromoteClusterNode: server.promoteClusterNode,
+		TransferLeadership: server.transferLeadership,
+		TakeRaftSnapshot:   server.takeRaftSnapshot,
+		GetServerInfo:      server.getServerInfo,
+		GetMemoryStats:     server.getMemoryStats,
+		NotifyOnKeys:       server.notifyOnKeys,
+		QueueOnKeys:        server.queueOnKeys,
+		Wait:               waitOnKeys(ctx),
 	}
 }
 
@@ -80,8 +121,14 @@ func (server *EchoVault) handleCommand(ctx context.Context, message []byte, conn
 		return nil, io.EOF
 	}
 
+	if replay {
+		// Let handlers that write to the AOF themselves know that the command is being replayed from it.
+		ctx = context.WithValue(ctx, internal.ContextReplay("Replay"), true)
+	}
+
 	command, err := server.getCommand(cmd[0])
 	if err != nil {
+		server.failTransaction(conn)
 		return nil, err
 	}
 
@@ -90,6 +137,7 @@ func (server *EchoVault) handleCommand(ctx context.Context, message []byte, conn
 
 	sc, err := internal.GetSubCommand(command, cmd)
 	if err != nil {
+		server.failTransaction(conn)
 		return nil, err
 	}
 	subCommand, ok := sc.(internal.SubCommand)
@@ -98,58 +146,124 @@ func (server *EchoVault) handleCommand(ctx context.Context, message []byte, conn
 		handler = subCommand.HandlerFunc
 	}
 
+	if !replay {
+		// Reject the command if a script has been running for too long.
+		if err = server.checkScriptBusy(command, subCommand); err != nil {
+			server.failTransaction(conn)
+			return nil, err
+		}
+	}
+
 	if conn != nil && server.acl != nil && !embedded {
 		// Authorize connection if it's provided and if ACL module is present
 		// and the embedded parameter is false.
 		if err = server.acl.AuthorizeConnection(conn, cmd, command, subCommand); err != nil {
+			server.failTransaction(conn)
 			return nil, err
 		}
+		// The leader authorizes the commands forwarded to it as the connection's user.
+		ctx = context.WithValue(ctx, internal.ContextUser("User"), server.acl.ConnectionUser(conn))
 	}
 
-	// If the command is a write command, wait for state copy to finish.
-	if internal.IsWriteCommand(command, subCommand) {
-		for {
-			if !server.stateCopyInProgress.Load() {
-				server.stateMutationInProgress.Store(true)
-				break
-			}
+	if server.isInCluster() && !replay {
+		// Redirect the client if the command's keys belong to another shard.
+		if err = server.checkSlots(conn, command, subCommand, cmd); err != nil {
+			server.failTransaction(conn)
+			return nil, err
 		}
 	}
 
+	// If the connection is in a transaction block, queue the command instead of executing it.
+	if queued, err := server.queueCommand(conn, command, subCommand, cmd); err != nil {
+		return nil, err
+	} else if queued {
+		return []byte("+QUEUED\r\n"), nil
+	}
+
+	write := internal.IsWriteCommand(command, subCommand)
+	blocking := internal.IsBlockingCommand(command, subCommand)
+
 	if !server.isInCluster() || !synchronize {
-		res, err := handler(server.getHandlerFuncParams(ctx, cmd, conn))
+		if server.isInCluster() && !replay && !write && internal.IsReadCommand(command, subCommand) {
+			// Reject the read if this node can't serve it with the required consistency.
+			if err = server.verifyReadConsistency(ctx, server.getReadConsistency(ctx, conn)); err != nil {
+				return nil, err
+			}
+		}
+		params := server.getHandlerFuncParams(ctx, cmd, conn)
+		switch {
+		case write && blocking:
+			// Blocking write commands leave the write section while they wait for their keys.
+			params = server.getBlockingHandlerFuncParams(ctx, cmd, conn)
+		case write:
+			params = server.getWriteHandlerFuncParams(ctx, cmd, conn)
+		}
+		if write {
+			// Write commands run in the write section, so that no state view is opened halfway through.
+			server.stateViews.writes.RLock()
+			defer server.stateViews.writes.RUnlock()
+		}
+		res, err := handler(params)
 		if err != nil {
 			return nil, err
 		}
 
-		if internal.IsWriteCommand(command, subCommand) && !replay {
-			go server.aofEngine.QueueCommand(message)
+		if write {
+			server.writeCommandExecuted(command, subCommand, cmd)
+			if !replay {
+				// The command is re-encoded as the handler may have resolved some of its arguments.
+				go server.aofEngine.QueueCommand(internal.EncodeCommand(cmd))
+			}
 		}
 
-		server.stateMutationInProgress.Store(false)
-
 		return res, err
 	}
 
+	if blocking {
+		// Blocking commands wait for their keys on this node, so that neither the raft log nor the forward channel
+		// is held up while they wait. Only the attempt that finds data is applied through raft.
+		if !server.raft.IsRaftLeader() && !server.config.ForwardCommand {
+			return nil, errors.New("not cluster leader, cannot carry out command")
+		}
+		params := server.getHandlerFuncParams(ctx, cmd, conn)
+		params.ApplyCommand = func(ctx context.Context, cmd []string) ([]byte, error) {
+			return server.applyClusterCommand(ctx, conn, cmd)
+		}
+		return handler(params)
+	}
+
+	return server.applyClusterCommand(ctx, conn, cmd)
+}
+
+// applyClusterCommand applies the command through raft if the node is the leader, or forwards it to the leader.
+func (server *EchoVault) applyClusterCommand(ctx context.Context, conn *net.Conn, cmd []string) ([]byte, error) {
+	// Pass the connection's protocol version along so the response is encoded for the client.
+	ctx = context.WithValue(ctx, internal.ContextProtocol("Protocol"), server.getConnectionInfo(conn).Protocol)
+
 	// Handle other commands that need to be synced across the cluster
 	if server.raft.IsRaftLeader() {
-		var res []byte
-		res, err = server.raftApplyCommand(ctx, cmd)
-		if err != nil {
-			return nil, err
-		}
-		return res, err
+		return server.raftApplyCommand(ctx, cmd)
 	}
 
-	// Forward message to leader and return immediate OK response
+	// Forward the command to the leader and return the leader's response.
 	if server.config.ForwardCommand {
-		server.memberList.ForwardDataMutation(ctx, message)
-		return []byte(constants.OkResponse), nil
+		return server.forwardCommand(ctx, cmd)
 	}
 
 	return nil, errors.New("not cluster leader, cannot carry out command")
 }
 
+// writeCommandExecuted marks the keys written to by the command as modified.
+func (server *EchoVault) writeCommandExecuted(command internal.Command, subCommand internal.SubCommand, cmd []string) {
+	keyExtractionFunc := command.KeyExtractionFunc
+	if subCommand.KeyExtractionFunc != nil {
+		keyExtractionFunc = subCommand.KeyExtractionFunc
+	}
+	if keys, err := keyExtractionFunc(cmd); err == nil {
+		server.keysModified(keys.WriteKeys)
+	}
+}
+
 func (server *EchoVault) getCommands() []internal.Command {
 	return server.commands
 }
@@ -0,0 +1,107 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"strings"
+)
+
+// The keyspace events that are not named after the command that triggered them.
+const (
+	delEvent     = "del"     // The key was deleted by a command.
+	expiredEvent = "expired" // The key was deleted because its expiry time has passed.
+	evictedEvent = "evicted" // The key was evicted to stay under max-memory.
+	expireEvent  = "expire"  // An expiry time was set on the key.
+	persistEvent = "persist" // The expiry time of the key was removed.
+	newEvent     = "new"     // The key was added to the keyspace.
+)
+
+// commandEvent is the keyspace event of a command. It's added to the context of the command's handler so that
+// the event can be published when the keys are written to. The event is only published once for each key,
+// even when the handler sets the key more than once.
+type commandEvent struct {
+	name     string              // The event is named after the command.
+	notified map[string]struct{} // The keys the event has been published on.
+}
+
+func newCommandEvent(cmd []string) *commandEvent {
+	return &commandEvent{name: strings.ToLower(cmd[0]), notified: make(map[string]struct{})}
+}
+
+// notifyKeyspaceEvent publishes the event on the __keyspace@0__:<key> channel with the event as the message, and on
+// the __keyevent@0__:<event> channel with the key as the message. Nothing is published unless the class of the event
+// and the channel are enabled by the notify-keyspace-events config.
+// It's called while the key's shard is locked, so the event is dropped rather than waiting for a subscriber
+// that fell behind.
+func (server *EchoVault) notifyKeyspaceEvent(class internal.KeyspaceEvents, event string, key string) {
+	if server.keyspaceEvents&class == 0 {
+		return
+	}
+	if server.keyspaceEvents&internal.KeyspaceEventsKeyspace != 0 {
+		server.pubSub.Notify(server.context, event, "__keyspace@0__:"+key)
+	}
+	if server.keyspaceEvents&internal.KeyspaceEventsKeyevent != 0 {
+		server.pubSub.Notify(server.context, key, "__keyevent@0__:"+event)
+	}
+}
+
+// notifyCommandEvent publishes the keyspace event of the command in the context on the key.
+// The class of the event is the type of the values the command's module works with.
+func (server *EchoVault) notifyCommandEvent(ctx context.Context, key string) {
+	if server.keyspaceEvents == 0 {
+		return
+	}
+	event, ok := ctx.Value(internal.ContextCommandEvent("CommandEvent")).(*commandEvent)
+	if !ok {
+		return
+	}
+	if _, notified := event.notified[key]; notified {
+		return
+	}
+	event.notified[key] = struct{}{}
+	class := internal.KeyspaceEventsGeneric
+	if command, err := server.getCommand(event.name); err == nil {
+		switch command.Module {
+		case constants.StringModule, constants.HyperLogLogModule:
+			class = internal.KeyspaceEventsString
+		case constants.ListModule:
+			class = internal.KeyspaceEventsList
+		case constants.SetModule:
+			class = internal.KeyspaceEventsSet
+		case constants.HashModule:
+			class = internal.KeyspaceEventsHash
+		case constants.SortedSetModule:
+			class = internal.KeyspaceEventsSortedSet
+		case constants.StreamModule:
+			class = internal.KeyspaceEventsStream
+		}
+	}
+	server.notifyKeyspaceEvent(class, event.name, key)
+}
+
+// deleteEventClass returns the class of the keyspace event published when a key is deleted.
+func deleteEventClass(event string) internal.KeyspaceEvents {
+	switch event {
+	case expiredEvent:
+		return internal.KeyspaceEventsExpired
+	case evictedEvent:
+		return internal.KeyspaceEventsEvicted
+	default:
+		return internal.KeyspaceEventsGeneric
+	}
+}
@@ -0,0 +1,617 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/tidwall/resp"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"log"
+	"math/rand"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// script is a compiled Lua script held in the script cache.
+type script struct {
+	body  string             // The source of the script.
+	proto *lua.FunctionProto // The compiled script. It's shared by every run of the script.
+}
+
+// The states of a running script. A script can only be killed before it writes to the store,
+// so that it never leaves its writes half done.
+const (
+	scriptRunning int32 = iota
+	scriptWrote
+	scriptKilled
+)
+
+// scriptRun is a script that's currently running.
+type scriptRun struct {
+	keys    []string           // The keys declared by the script. Its commands can only access these keys.
+	started time.Time          // When the script started running.
+	state   atomic.Int32       // Whether the script is running, has written to the store or has been killed.
+	cancel  context.CancelFunc // Stops the Lua state of the script.
+}
+
+// write marks the script as having written to the store. It returns false if the script has been killed.
+func (run *scriptRun) write() bool {
+	return run.state.CompareAndSwap(scriptRunning, scriptWrote) || run.state.Load() == scriptWrote
+}
+
+// kill stops the script unless it has already written to the store.
+func (run *scriptRun) kill() error {
+	if !run.state.CompareAndSwap(scriptRunning, scriptKilled) && run.state.Load() == scriptWrote {
+		return errors.New("UNKILLABLE Sorry the script already executed write commands against the dataset. " +
+			"You can either wait the script termination or kill the server in a hard way.")
+	}
+	run.cancel()
+	return nil
+}
+
+// Commands from these categories can not be called from a script, either because they need
+// a client connection or because they would run another script or transaction inside it.
+var scriptDeniedCategories = []string{
+	constants.AdminCategory,
+	constants.ConnectionCategory,
+	constants.ScriptingCategory,
+	constants.TransactionCategory,
+}
+
+func scriptSHA(body string) string {
+	sum := sha1.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// isScriptCommand returns true if the command is EVAL or EVALSHA.
+func isScriptCommand(cmd []string) bool {
+	return len(cmd) > 0 && (strings.EqualFold(cmd[0], "eval") || strings.EqualFold(cmd[0], "evalsha"))
+}
+
+// resolveScriptCommand replaces an EVALSHA command with the EVAL of the cached script body.
+// The command is returned unchanged if it's already an EVAL or if the script is not in the cache.
+func (server *EchoVault) resolveScriptCommand(cmd []string) []string {
+	if len(cmd) < 2 || !strings.EqualFold(cmd[0], "evalsha") {
+		return cmd
+	}
+	server.scripts.mutex.RLock()
+	s, ok := server.scripts.cache[strings.ToLower(cmd[1])]
+	server.scripts.mutex.RUnlock()
+	if !ok {
+		return cmd
+	}
+	return append([]string{"EVAL", s.body}, cmd[2:]...)
+}
+
+func evalCommand(body string, keys []string, args []string) []string {
+	cmd := []string{"EVAL", body, strconv.Itoa(len(keys))}
+	cmd = append(cmd, keys...)
+	return append(cmd, args...)
+}
+
+// compileScript parses and compiles the script and adds it to the script cache.
+// If the script is already cached, the cached script is returned.
+func (server *EchoVault) compileScript(body string) (string, *script, error) {
+	sha := scriptSHA(body)
+
+	server.scripts.mutex.RLock()
+	s, ok := server.scripts.cache[sha]
+	server.scripts.mutex.RUnlock()
+	if ok {
+		return sha, s, nil
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(body), "user_script")
+	if err != nil {
+		return "", nil, fmt.Errorf("error compiling script: %s", err.Error())
+	}
+	proto, err := lua.Compile(chunk, "user_script")
+	if err != nil {
+		return "", nil, fmt.Errorf("error compiling script: %s", err.Error())
+	}
+	s = &script{body: body, proto: proto}
+
+	server.scripts.mutex.Lock()
+	defer server.scripts.mutex.Unlock()
+	server.scripts.cache[sha] = s
+	return sha, s, nil
+}
+
+func (server *EchoVault) getScript(sha string) (*script, error) {
+	server.scripts.mutex.RLock()
+	defer server.scripts.mutex.RUnlock()
+	s, ok := server.scripts.cache[sha]
+	if !ok {
+		return nil, errors.New("NOSCRIPT No matching script. Please use EVAL.")
+	}
+	return s, nil
+}
+
+func (server *EchoVault) scriptLoad(body string) (string, error) {
+	sha, _, err := server.compileScript(body)
+	return sha, err
+}
+
+func (server *EchoVault) scriptExists(shas []string) []bool {
+	server.scripts.mutex.RLock()
+	defer server.scripts.mutex.RUnlock()
+	exists := make([]bool, len(shas))
+	for i, sha := range shas {
+		_, exists[i] = server.scripts.cache[sha]
+	}
+	return exists
+}
+
+func (server *EchoVault) scriptFlush() {
+	server.scripts.mutex.Lock()
+	defer server.scripts.mutex.Unlock()
+	clear(server.scripts.cache)
+}
+
+// scriptKill stops the running script. In cluster mode, scripts are applied from the raft log by every node,
+// so stopping the script on one node would leave the nodes with different data. They can't be killed.
+func (server *EchoVault) scriptKill() error {
+	run := server.scripts.running.Load()
+	if run == nil {
+		return errors.New("NOTBUSY No scripts in execution right now.")
+	}
+	if server.isInCluster() {
+		return errors.New("UNKILLABLE Scripts run by every node of the cluster can't be killed.")
+	}
+	return run.kill()
+}
+
+// checkScriptBusy returns a BUSY error if a script has been running for longer than the script time limit.
+// Only SCRIPT KILL and the connection commands can then be run until the script is complete.
+// Commands that were already waiting for the shards' locks keep waiting for the script.
+func (server *EchoVault) checkScriptBusy(command internal.Command, subCommand internal.SubCommand) error {
+	run := server.scripts.running.Load()
+	if run == nil || server.config.ScriptTimeLimit <= 0 || time.Since(run.started) < server.config.ScriptTimeLimit {
+		return nil
+	}
+	if strings.EqualFold(command.Command, "script") && strings.EqualFold(subCommand.Command, "kill") {
+		return nil
+	}
+	if slices.Contains(command.Categories, constants.ConnectionCategory) {
+		return nil
+	}
+	return errors.New("BUSY EchoVault is busy running a script. You can only call SCRIPT KILL.")
+}
+
+func (server *EchoVault) eval(ctx context.Context, conn *net.Conn, body string, keys []string, args []string) ([]byte, error) {
+	_, s, err := server.compileScript(body)
+	if err != nil {
+		return nil, err
+	}
+	return server.evalScript(ctx, conn, s, keys, args)
+}
+
+func (server *EchoVault) evalSHA(ctx context.Context, conn *net.Conn, sha string, keys []string, args []string) ([]byte, error) {
+	s, err := server.getScript(sha)
+	if err != nil {
+		return nil, err
+	}
+	return server.evalScript(ctx, conn, s, keys, args)
+}
+
+// evalScript runs the script atomically.
+// In standalone mode, the script is run locally and is appended to the AOF if it wrote to the store.
+// In cluster mode, the script is replicated as a single raft log entry, so every node runs it
+// with the leader's time. Followers forward the script to the leader if command forwarding is enabled.
+// As the leader runs the script when the log entry is applied, the script's reads are always consistent.
+func (server *EchoVault) evalScript(ctx context.Context, conn *net.Conn, s *script, keys []string, args []string) ([]byte, error) {
+	if server.isInCluster() {
+		ctx = context.WithValue(ctx, internal.ContextProtocol("Protocol"), server.getConnectionInfo(conn).Protocol)
+		switch {
+		case server.raft.IsRaftLeader():
+			return server.raftApplyScript(ctx, evalCommand(s.body, keys, args))
+		case server.config.ForwardCommand:
+			// The script body is forwarded in place of its SHA1 digest, as the leader may not have it cached.
+			return server.forwardScript(ctx, evalCommand(s.body, keys, args))
+		default:
+			return nil, errors.New("not cluster leader, cannot run script")
+		}
+	}
+
+	res, wrote, err := server.execScript(ctx, conn, s, keys, args)
+	if wrote {
+		if replay, _ := ctx.Value(internal.ContextReplay("Replay")).(bool); !replay {
+			// The script body is logged instead of its SHA1 digest, as the script cache is not persisted.
+			go server.aofEngine.QueueCommand(internal.EncodeCommand(evalCommand(s.body, keys, args)))
+		}
+	}
+	return res, err
+}
+
+// execScript runs the script while holding the locks of all the shards, so no other command can observe
+// or modify the keyspace until the script is complete.
+// Returns true if any of the commands called by the script wrote to the store.
+func (server *EchoVault) execScript(ctx context.Context, conn *net.Conn, s *script, keys []string, args []string) ([]byte, bool, error) {
+	server.stateViews.writes.RLock()
+	defer server.stateViews.writes.RUnlock()
+
+	unlock := server.lockAllShards()
+	defer unlock()
+
+	return server.runScript(ctx, conn, s, keys, args)
+}
+
+// evalUnlocked runs the script without acquiring the shards' locks. It's used for scripts queued in a transaction.
+func (server *EchoVault) evalUnlocked(ctx context.Context, conn *net.Conn, body string, keys []string, args []string) ([]byte, error) {
+	_, s, err := server.compileScript(body)
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := server.runScript(ctx, conn, s, keys, args)
+	return res, err
+}
+
+// evalSHAUnlocked works like evalUnlocked but runs the cached script with the given SHA1 digest.
+func (server *EchoVault) evalSHAUnlocked(ctx context.Context, conn *net.Conn, sha string, keys []string, args []string) ([]byte, error) {
+	s, err := server.getScript(sha)
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := server.runScript(ctx, conn, s, keys, args)
+	return res, err
+}
+
+// execReplicatedScript runs an EVAL command that was replicated through the raft log.
+func (server *EchoVault) execReplicatedScript(ctx context.Context, cmd []string) ([]byte, error) {
+	if len(cmd) < 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	numKeys, err := strconv.Atoi(cmd[2])
+	if err != nil || numKeys < 0 || numKeys > len(cmd)-3 {
+		return nil, errors.New("invalid number of keys")
+	}
+	_, s, err := server.compileScript(cmd[1])
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := server.execScript(ctx, nil, s, cmd[3:3+numKeys], cmd[3+numKeys:])
+	return res, err
+}
+
+// runScript runs the script in a new Lua state. The caller must hold the locks of all the shards.
+// Returns the RESP encoded result of the script and whether the script wrote to the store.
+func (server *EchoVault) runScript(ctx context.Context, conn *net.Conn, s *script, keys []string, args []string) ([]byte, bool, error) {
+	protocol := server.getConnectionInfo(conn).Protocol
+	if p, ok := ctx.Value(internal.ContextProtocol("Protocol")).(int); ok {
+		protocol = p
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	run := &scriptRun{keys: keys, started: time.Now(), cancel: cancel}
+	server.scripts.running.Store(run)
+	defer server.scripts.running.Store(nil)
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+	L.SetContext(ctx)
+
+	openScriptLibs(L)
+
+	L.SetGlobal("KEYS", stringsToLuaTable(L, keys))
+	L.SetGlobal("ARGV", stringsToLuaTable(L, args))
+
+	redis := L.NewTable()
+	L.SetFuncs(redis, map[string]lua.LGFunction{
+		"call":  server.luaCall(ctx, conn, run, false),
+		"pcall": server.luaCall(ctx, conn, run, true),
+		"error_reply": func(L *lua.LState) int {
+			L.Push(luaReplyTable(L, "err", L.CheckString(1)))
+			return 1
+		},
+		"status_reply": func(L *lua.LState) int {
+			L.Push(luaReplyTable(L, "ok", L.CheckString(1)))
+			return 1
+		},
+		"sha1hex": func(L *lua.LState) int {
+			L.Push(lua.LString(scriptSHA(L.CheckString(1))))
+			return 1
+		},
+		"log": func(L *lua.LState) int {
+			L.CheckInt(1)
+			parts := make([]string, 0, L.GetTop()-1)
+			for i := 2; i <= L.GetTop(); i++ {
+				parts = append(parts, L.Get(i).String())
+			}
+			log.Println(strings.Join(parts, " "))
+			return 0
+		},
+	})
+	for i, level := range []string{"LOG_DEBUG", "LOG_VERBOSE", "LOG_NOTICE", "LOG_WARNING"} {
+		redis.RawSetString(level, lua.LNumber(i))
+	}
+	L.SetGlobal("redis", redis)
+
+	L.Push(L.NewFunctionFromProto(s.proto))
+	wrote := func() bool { return run.state.Load() == scriptWrote }
+	if err := L.PCall(0, 1, nil); err != nil {
+		if run.state.Load() == scriptKilled {
+			return nil, false, errors.New("script killed by user with SCRIPT KILL")
+		}
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			if msg, ok := luaErrorReply(apiErr.Object); ok {
+				return nil, wrote(), errors.New(msg)
+			}
+			return nil, wrote(), fmt.Errorf("error running script: %s", apiErr.Object.String())
+		}
+		return nil, wrote(), err
+	}
+
+	res, err := luaToRESP(L.Get(-1), protocol, true)
+	return res, wrote(), err
+}
+
+// openScriptLibs opens the subset of the Lua standard library that's available to scripts.
+// Libraries that access the file system or the OS are not loaded, and the random number
+// generator is replaced with one that's seeded the same way on every run, so that a script
+// produces the same result on every node it's replicated to.
+func openScriptLibs(L *lua.LState) {
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	rng := rand.New(rand.NewSource(0))
+	math := L.GetGlobal(lua.MathLibName).(*lua.LTable)
+	L.SetFuncs(math, map[string]lua.LGFunction{
+		"random": func(L *lua.LState) int {
+			switch L.GetTop() {
+			case 0:
+				L.Push(lua.LNumber(rng.Float64()))
+			case 1:
+				upper := L.CheckInt(1)
+				if upper < 1 {
+					L.ArgError(1, "interval is empty")
+				}
+				L.Push(lua.LNumber(rng.Intn(upper) + 1))
+			default:
+				lower, upper := L.CheckInt(1), L.CheckInt(2)
+				if lower > upper {
+					L.ArgError(2, "interval is empty")
+				}
+				L.Push(lua.LNumber(lower + rng.Intn(upper-lower+1)))
+			}
+			return 1
+		},
+		"randomseed": func(L *lua.LState) int {
+			rng.Seed(L.CheckInt64(1))
+			return 0
+		},
+	})
+}
+
+// luaCall returns the implementation of redis.call, or of redis.pcall if protected is true.
+// The command is executed with the store functions that don't acquire the shards' locks, as they're held
+// for the duration of the script. Errors raise a Lua error in redis.call and are returned as
+// an error reply table in redis.pcall.
+func (server *EchoVault) luaCall(ctx context.Context, conn *net.Conn, run *scriptRun, protected bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		res, err := server.scriptCommand(ctx, conn, L, run)
+		if err != nil {
+			reply := luaReplyTable(L, "err", err.Error())
+			if !protected {
+				L.Error(reply, 0)
+				return 0
+			}
+			L.Push(reply)
+			return 1
+		}
+		L.Push(res)
+		return 1
+	}
+}
+
+// scriptCommand executes the command made up of the arguments on the Lua stack and returns its reply as a Lua value.
+// The command can only access the keys declared by the script.
+func (server *EchoVault) scriptCommand(ctx context.Context, conn *net.Conn, L *lua.LState, run *scriptRun) (lua.LValue, error) {
+	if L.GetTop() == 0 {
+		return nil, errors.New("please specify at least one argument for this redis lib call")
+	}
+	cmd := make([]string, L.GetTop())
+	for i := range cmd {
+		switch arg := L.Get(i + 1).(type) {
+		case lua.LString, lua.LNumber:
+			cmd[i] = arg.String()
+		default:
+			return nil, errors.New("lua redis lib command arguments must be strings or integers")
+		}
+	}
+
+	command, err := server.getCommand(cmd[0])
+	if err != nil {
+		return nil, err
+	}
+	sc, err := internal.GetSubCommand(command, cmd)
+	if err != nil {
+		return nil, err
+	}
+	subCommand, _ := sc.(internal.SubCommand)
+	keyExtractionFunc := command.KeyExtractionFunc
+	if subCommand.KeyExtractionFunc != nil {
+		keyExtractionFunc = subCommand.KeyExtractionFunc
+	}
+
+	categories := append(slices.Clone(command.Categories), subCommand.Categories...)
+	if slices.ContainsFunc(categories, func(category string) bool {
+		return slices.Contains(scriptDeniedCategories, category)
+	}) {
+		return nil, fmt.Errorf("command %s is not allowed from scripts", strings.ToUpper(cmd[0]))
+	}
+
+	if conn != nil && server.acl != nil {
+		if err = server.acl.AuthorizeConnection(conn, cmd, command, subCommand); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := keyExtractionFunc(cmd)
+	if err != nil {
+		return nil, err
+	}
+	for _, accessed := range [][]string{keys.ReadKeys, keys.WriteKeys} {
+		for _, key := range accessed {
+			if !slices.Contains(run.keys, key) {
+				return nil, fmt.Errorf("script attempted to access key %s that was not declared in KEYS", key)
+			}
+		}
+	}
+
+	if internal.IsWriteCommand(command, subCommand) && !run.write() {
+		return nil, errors.New("script killed by user with SCRIPT KILL")
+	}
+	// The command is executed without a connection so that its reply is always RESP2 encoded.
+	res, err := server.execQueuedCommand(ctx, nil, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return lua.LFalse, nil
+	}
+
+	value, _, err := resp.NewReader(bytes.NewReader(res)).ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	return respToLua(L, value), nil
+}
+
+func stringsToLuaTable(L *lua.LState, values []string) *lua.LTable {
+	table := L.CreateTable(len(values), 0)
+	for _, value := range values {
+		table.Append(lua.LString(value))
+	}
+	return table
+}
+
+// luaReplyTable returns a table with a single field, which is how scripts represent status and error replies.
+func luaReplyTable(L *lua.LState, field string, value string) *lua.LTable {
+	table := L.NewTable()
+	table.RawSetString(field, lua.LString(value))
+	return table
+}
+
+// luaErrorReply returns the message of an error reply table.
+func luaErrorReply(value lua.LValue) (string, bool) {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return "", false
+	}
+	if msg, ok := table.RawGetString("err").(lua.LString); ok {
+		return string(msg), true
+	}
+	return "", false
+}
+
+// respToLua converts a command reply to a Lua value.
+// Integers are converted to numbers, strings to strings, arrays to tables and nulls to false.
+// Simple strings are converted to strings rather than status reply tables, as many commands reply
+// with the value of a key as a simple string. Errors are converted to tables with an "err" field.
+func respToLua(L *lua.LState, value resp.Value) lua.LValue {
+	if value.IsNull() {
+		return lua.LFalse
+	}
+	switch value.Type() {
+	case resp.Integer:
+		return lua.LNumber(value.Integer())
+	case resp.Error:
+		return luaReplyTable(L, "err", strings.TrimPrefix(value.String(), "Error "))
+	case resp.Array:
+		table := L.CreateTable(len(value.Array()), 0)
+		for _, v := range value.Array() {
+			table.Append(respToLua(L, v))
+		}
+		return table
+	default:
+		return lua.LString(value.String())
+	}
+}
+
+// luaToRESP converts the value returned by a script to a RESP encoded reply.
+// Numbers are truncated to integers, tables are converted to arrays up to their first nil element
+// and true is converted to 1. False and nil are converted to null.
+// An error reply table at the top level is returned as an error.
+func luaToRESP(value lua.LValue, protocol int, top bool) ([]byte, error) {
+	null := []byte("$-1\r\n")
+	if protocol == constants.RESP3Protocol {
+		null = []byte(constants.NullResponse)
+	}
+
+	switch v := value.(type) {
+	case lua.LNumber:
+		return []byte(fmt.Sprintf(":%d\r\n", int64(v))), nil
+	case lua.LString:
+		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)), nil
+	case lua.LBool:
+		if v {
+			return []byte(":1\r\n"), nil
+		}
+		return null, nil
+	case *lua.LTable:
+		if msg, ok := luaErrorReply(v); ok {
+			if top {
+				return nil, errors.New(msg)
+			}
+			return []byte(fmt.Sprintf("-Error %s\r\n", msg)), nil
+		}
+		if status, ok := v.RawGetString("ok").(lua.LString); ok {
+			return []byte(fmt.Sprintf("+%s\r\n", status)), nil
+		}
+		var elements [][]byte
+		for i := 1; ; i++ {
+			element := v.RawGetInt(i)
+			if element == lua.LNil {
+				break
+			}
+			b, err := luaToRESP(element, protocol, false)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, b)
+		}
+		return append([]byte(fmt.Sprintf("*%d\r\n", len(elements))), bytes.Join(elements, nil)...), nil
+	default:
+		return null, nil
+	}
+}
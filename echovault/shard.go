@@ -0,0 +1,172 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/clock"
+	"github.com/echovault/echovault/internal/eviction"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// shardCount is the number of partitions of the keyspace.
+const shardCount = 32
+
+// shard is a partition of the keyspace. Each key belongs to the shard at the index returned by shardIndex.
+// The store, the expiry index and the memory usage of the shard are guarded by the shard's mutex.
+type shard struct {
+	mutex sync.RWMutex
+	store map[string]internal.KeyData // Data store to hold the keys and their associated data, expiry time, etc.
+	keys  *internal.ScanIndex         // The keys of the store in their scan order.
+
+	// The scan indexes of the values that don't keep their own, such as hashes, which are stored as plain maps.
+	// An index is built by the first scan of the value, and dropped when the key is modified.
+	// When both are needed, the shard's mutex must be acquired before the scan indexes' mutex.
+	scanIndexes struct {
+		mutex   sync.Mutex
+		indexes map[string]*internal.ScanIndex
+	}
+
+	// The keys of the shard that are currently associated with an expiry, ordered by expiry time.
+	expiry eviction.ExpiryHeap
+
+	// Tracks the estimated memory used by the keys in the shard.
+	memoryUsage struct {
+		keys  map[string]int64 // The estimated size of each key.
+		total atomic.Int64     // The estimated size of all the keys. It's read without the mutex to pick the shard to evict from.
+	}
+
+	// The eviction caches have their own mutex, as they're updated asynchronously after the keys are accessed.
+	// When both are needed, the shard's mutex must be acquired before the cache's mutex.
+	// LFU cache used when eviction policy is allkeys-lfu or volatile-lfu.
+	lfuCache struct {
+		mutex sync.Mutex        // Mutex as only one goroutine can edit the LFU cache at a time.
+		cache eviction.CacheLFU // LFU cache represented by a min head.
+	}
+	// LRU cache used when eviction policy is allkeys-lru or volatile-lru.
+	lruCache struct {
+		mutex sync.Mutex        // Mutex as only one goroutine can edit the LRU at a time.
+		cache eviction.CacheLRU // LRU cache represented by a max head.
+	}
+}
+
+func newShard() *shard {
+	s := &shard{
+		store:  make(map[string]internal.KeyData),
+		keys:   internal.NewScanIndex(),
+		expiry: eviction.NewExpiryHeap(),
+	}
+	s.scanIndexes.indexes = make(map[string]*internal.ScanIndex)
+	s.memoryUsage.keys = make(map[string]int64)
+	return s
+}
+
+// initialiseCaches resets the eviction caches of the shard.
+func (s *shard) initialiseCaches(clock clock.Clock) {
+	s.lfuCache.mutex.Lock()
+	s.lfuCache.cache = eviction.NewCacheLFU(eviction.WithClock(clock))
+	s.lfuCache.mutex.Unlock()
+
+	s.lruCache.mutex.Lock()
+	s.lruCache.cache = eviction.NewCacheLRU()
+	s.lruCache.mutex.Unlock()
+}
+
+// shardIndex returns the index of the shard the key belongs to, using the 32-bit FNV-1a hash of the key.
+func shardIndex(key string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % shardCount)
+}
+
+// getShard returns the shard that the key belongs to.
+func (server *EchoVault) getShard(key string) *shard {
+	return server.shards[shardIndex(key)]
+}
+
+// shardIndexes returns the indexes of the shards that the keys belong to, in ascending order and without duplicates.
+// Shards are always locked in this order, so that two commands locking the same shards can't deadlock.
+func shardIndexes(keys []string) []int {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, shardIndex(key))
+	}
+	slices.Sort(indexes)
+	return slices.Compact(indexes)
+}
+
+// lockShards acquires the write lock of each of the shards that the keys belong to.
+// Returns a function that releases the locks.
+func (server *EchoVault) lockShards(keys []string) func() {
+	indexes := shardIndexes(keys)
+	for _, i := range indexes {
+		server.shards[i].mutex.Lock()
+	}
+	return func() {
+		for _, i := range indexes {
+			server.shards[i].mutex.Unlock()
+		}
+	}
+}
+
+// rLockShards acquires the read lock of each of the shards that the keys belong to.
+// Returns a function that releases the locks.
+func (server *EchoVault) rLockShards(keys []string) func() {
+	indexes := shardIndexes(keys)
+	for _, i := range indexes {
+		server.shards[i].mutex.RLock()
+	}
+	return func() {
+		for _, i := range indexes {
+			server.shards[i].mutex.RUnlock()
+		}
+	}
+}
+
+// lockAllShards acquires the write lock of every shard, so that no other command can observe or modify the keyspace
+// until the returned function is called. It's used by transactions and scripts, as the keys they access are not
+// known upfront.
+func (server *EchoVault) lockAllShards() func() {
+	for _, s := range server.shards {
+		s.mutex.Lock()
+	}
+	return func() {
+		for _, s := range server.shards {
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// shardsBySize returns the shards ordered from the highest to the lowest estimated memory usage.
+func (server *EchoVault) shardsBySize() []*shard {
+	shards := slices.Clone(server.shards[:])
+	slices.SortFunc(shards, func(a, b *shard) int {
+		sizeA, sizeB := a.memoryUsage.total.Load(), b.memoryUsage.total.Load()
+		switch {
+		case sizeA > sizeB:
+			return -1
+		case sizeA < sizeB:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return shards
+}
@@ -0,0 +1,598 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/codec"
+	"github.com/echovault/echovault/internal/forward"
+	"github.com/echovault/echovault/internal/memberlist"
+	"github.com/echovault/echovault/internal/slots"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The actions of CLUSTER SETSLOT.
+const (
+	slotActionImporting = "importing"
+	slotActionMigrating = "migrating"
+	slotActionStable    = "stable"
+	slotActionNode      = "node"
+)
+
+// initialSlotState returns the slot state of the shard from the cluster-slots config.
+// The shard owns all the slots when the config is empty.
+func initialSlotState(conf string) (slots.State, error) {
+	if conf == "" {
+		return slots.NewState(slots.AllRanges()), nil
+	}
+	ranges, err := slots.ParseRanges(conf)
+	if err != nil {
+		return slots.State{}, err
+	}
+	return slots.NewState(ranges), nil
+}
+
+func (server *EchoVault) getSlotState() slots.State {
+	server.slotState.mutex.RLock()
+	defer server.slotState.mutex.RUnlock()
+	return server.slotState.state.Clone()
+}
+
+// setSlotState replaces the slot state of the shard. It's called when a slots request is applied through raft
+// and when a raft snapshot is restored. The new state is then advertised to the other shards.
+func (server *EchoVault) setSlotState(state slots.State) {
+	server.slotState.mutex.Lock()
+	server.slotState.state = state.Clone()
+	server.slotState.mutex.Unlock()
+
+	select {
+	case server.slotState.changed <- struct{}{}:
+	default:
+	}
+}
+
+// advertiseNodeMeta updates the node's memberlist metadata whenever the node gains or loses the leadership of its
+// shard, or the slot state of the shard changes, until the server shuts down.
+func (server *EchoVault) advertiseNodeMeta() {
+	leaderCh := server.raft.LeaderCh()
+	for {
+		select {
+		case <-leaderCh:
+		case <-server.slotState.changed:
+		case <-server.slotState.stop:
+			return
+		}
+		server.memberList.UpdateMeta()
+	}
+}
+
+// getClusterShards returns the shards of the cluster from the metadata advertised by their nodes.
+// The slots of another shard are taken from the node that advertises the highest epoch, preferring the leader.
+// The slots of this node's shard are always taken from its own state.
+func (server *EchoVault) getClusterShards() ([]internal.ClusterShard, error) {
+	if !server.isInCluster() {
+		return nil, errors.New("cluster support disabled")
+	}
+
+	shards := make(map[string]*internal.ClusterShard)
+	preferred := make(map[string]memberlist.NodeMeta)
+	for _, meta := range server.memberList.Members() {
+		shard, ok := shards[meta.ShardID]
+		if !ok {
+			shard = &internal.ClusterShard{ID: meta.ShardID}
+			shards[meta.ShardID] = shard
+		}
+		if p, ok := preferred[meta.ShardID]; !ok || meta.SlotEpoch > p.SlotEpoch ||
+			(meta.SlotEpoch == p.SlotEpoch && meta.Leader && !p.Leader) {
+			preferred[meta.ShardID] = meta
+		}
+		host, port, err := net.SplitHostPort(meta.ClientAddr)
+		if err != nil {
+			continue
+		}
+		p, _ := strconv.Atoi(port)
+		shard.Nodes = append(shard.Nodes, internal.ClusterNode{
+			ID:     string(meta.ServerID),
+			Host:   host,
+			Port:   p,
+			Leader: meta.Leader,
+		})
+	}
+
+	for id, shard := range shards {
+		var state slots.State
+		if id == server.config.ShardID {
+			state = server.getSlotState()
+		} else {
+			state = server.getRemoteSlotState(preferred[id])
+		}
+		shard.Epoch = state.Epoch
+		shard.Slots = state.Slots
+	}
+
+	result := make([]internal.ClusterShard, 0, len(shards))
+	for _, shard := range shards {
+		slices.SortFunc(shard.Nodes, func(a, b internal.ClusterNode) int {
+			if a.Leader != b.Leader {
+				if a.Leader {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.ID, b.ID)
+		})
+		result = append(result, *shard)
+	}
+	slices.SortFunc(result, func(a, b internal.ClusterShard) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return result, nil
+}
+
+// getRemoteSlotState returns the slot state of another shard. The state is fetched from the node with the metadata
+// when the node advertises a different state than the cached one. The cached state is returned if the fetch fails.
+func (server *EchoVault) getRemoteSlotState(meta memberlist.NodeMeta) slots.State {
+	server.remoteSlots.mutex.RLock()
+	state, ok := server.remoteSlots.states[meta.ShardID]
+	server.remoteSlots.mutex.RUnlock()
+	if ok && (state.Epoch > meta.SlotEpoch || (state.Epoch == meta.SlotEpoch && state.Digest() == meta.SlotDigest)) {
+		return state
+	}
+	if !server.forwardEnabled() {
+		return state
+	}
+
+	ctx, cancel := context.WithTimeout(server.context, statsTimeout)
+	defer cancel()
+	response, err := server.forwardClient.Forward(ctx, meta.ForwardAddr, forward.Request{Type: forward.TypeSlots})
+	if err == nil && response.Error != "" {
+		err = errors.New(response.Error)
+	}
+	var fetched slots.State
+	if err == nil {
+		err = json.Unmarshal(response.Response, &fetched)
+	}
+	if err != nil {
+		log.Printf("could not fetch the slots of shard %s from %s: %v\n", meta.ShardID, meta.ServerID, err)
+		return state
+	}
+
+	server.remoteSlots.mutex.Lock()
+	defer server.remoteSlots.mutex.Unlock()
+	if cached, ok := server.remoteSlots.states[meta.ShardID]; ok && cached.Epoch > fetched.Epoch {
+		return cached
+	}
+	server.remoteSlots.states[meta.ShardID] = fetched
+	return fetched
+}
+
+// slotOwner returns the shard that owns the slot. When several shards claim the slot, the one with the highest
+// epoch owns it. ok is false if no shard claims the slot.
+func slotOwner(shards []internal.ClusterShard, slot int) (owner internal.ClusterShard, ok bool) {
+	for _, shard := range shards {
+		if !slots.Contains(shard.Slots, slot) {
+			continue
+		}
+		if !ok || shard.Epoch > owner.Epoch {
+			owner, ok = shard, true
+		}
+	}
+	return owner, ok
+}
+
+// shardAddr returns the client address of the shard's leader, or of any of its nodes if the leader is unknown.
+func shardAddr(shard internal.ClusterShard) (string, bool) {
+	if len(shard.Nodes) == 0 {
+		return "", false
+	}
+	return net.JoinHostPort(shard.Nodes[0].Host, strconv.Itoa(shard.Nodes[0].Port)), true
+}
+
+// shardLeader returns the metadata of the leader of the shard.
+func (server *EchoVault) shardLeader(shardID string) (memberlist.NodeMeta, error) {
+	for _, meta := range server.memberList.Members() {
+		if meta.ShardID == shardID && meta.Leader {
+			return meta, nil
+		}
+	}
+	return memberlist.NodeMeta{}, fmt.Errorf("no known leader for shard %s", shardID)
+}
+
+// checkSlots returns a redirection error if the keys of the command are not served by this node's shard.
+// Commands with keys in several slots are only served when this shard owns all the slots and none of them
+// is migrating. Otherwise, the keys must belong to the same slot.
+func (server *EchoVault) checkSlots(conn *net.Conn, command internal.Command, subCommand internal.SubCommand, cmd []string) error {
+	if strings.EqualFold(command.Command, "asking") {
+		return nil
+	}
+	// The ASKING flag only applies to the command that follows it.
+	asking := server.takeAsking(conn)
+
+	keyExtractionFunc := command.KeyExtractionFunc
+	if subCommand.KeyExtractionFunc != nil {
+		keyExtractionFunc = subCommand.KeyExtractionFunc
+	}
+	if keyExtractionFunc == nil {
+		return nil
+	}
+	// Invalid commands are left to their handler to report.
+	keys, err := keyExtractionFunc(cmd)
+	if err != nil {
+		return nil
+	}
+	allKeys := append(slices.Clone(keys.ReadKeys), keys.WriteKeys...)
+	if len(allKeys) == 0 {
+		return nil
+	}
+
+	state := server.getSlotState()
+
+	slot := slots.KeySlot(allKeys[0])
+	crossSlot := false
+	for _, key := range allKeys[1:] {
+		if slots.KeySlot(key) != slot {
+			crossSlot = true
+			break
+		}
+	}
+	if crossSlot {
+		for _, key := range allKeys {
+			s := slots.KeySlot(key)
+			if _, migrating := state.Migrating[s]; !state.Owns(s) || migrating {
+				return &slots.Error{Code: "CROSSSLOT", Message: "Keys in request don't hash to the same slot"}
+			}
+		}
+		return nil
+	}
+
+	if !state.Owns(slot) {
+		if _, importing := state.Importing[slot]; importing && asking {
+			return nil
+		}
+		return server.movedError(slot)
+	}
+
+	target, migrating := state.Migrating[slot]
+	if !migrating {
+		return nil
+	}
+	// Keys that were already moved to the target shard are served there.
+	exists := server.keysExist(allKeys)
+	missing := 0
+	for _, key := range allKeys {
+		if !exists[key] {
+			missing++
+		}
+	}
+	if missing == 0 {
+		return nil
+	}
+	if missing < len(allKeys) {
+		return &slots.Error{Code: "TRYAGAIN", Message: "Multiple keys request during rehashing of slot"}
+	}
+	meta, err := server.shardLeader(target)
+	if err != nil {
+		return &slots.Error{Code: "TRYAGAIN", Message: err.Error()}
+	}
+	return &slots.Error{Code: "ASK", Message: fmt.Sprintf("%d %s", slot, meta.ClientAddr)}
+}
+
+// movedError returns the MOVED error that redirects the client to the owner of the slot.
+func (server *EchoVault) movedError(slot int) error {
+	shards, err := server.getClusterShards()
+	if err != nil {
+		return err
+	}
+	owner, ok := slotOwner(shards, slot)
+	if !ok {
+		return &slots.Error{Code: "CLUSTERDOWN", Message: fmt.Sprintf("Hash slot %d not served", slot)}
+	}
+	addr, ok := shardAddr(owner)
+	if !ok {
+		return &slots.Error{Code: "CLUSTERDOWN", Message: fmt.Sprintf("No known nodes for shard %s", owner.ID)}
+	}
+	return &slots.Error{Code: "MOVED", Message: fmt.Sprintf("%d %s", slot, addr)}
+}
+
+// keysInSlot returns up to count keys of the slot. All the keys are returned when count is 0 or less.
+func (server *EchoVault) keysInSlot(slot int, count int) []string {
+	var keys []string
+	now := server.clock.Now()
+	for _, s := range server.shards {
+		s.mutex.RLock()
+		ok := yieldShardKeys(s, now, func(key string) bool {
+			if slots.KeySlot(key) == slot {
+				keys = append(keys, key)
+			}
+			return count <= 0 || len(keys) < count
+		})
+		s.mutex.RUnlock()
+		if !ok {
+			break
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// setSlot changes the state of the slot in this node's shard, following CLUSTER SETSLOT:
+//   - importing: the shard accepts the slot's keys from the source shard, and serves them to clients that ask.
+//   - migrating: the shard redirects the clients to the target shard for the slot's keys it no longer has.
+//   - stable: clears the importing and migrating states of the slot.
+//   - node: assigns the slot to the shard. The shard that takes the slot over raises its epoch above every other
+//     shard's, so that its claim wins. The shard that gives the slot away must not have any of its keys left.
+func (server *EchoVault) setSlot(ctx context.Context, slot int, action string, shardID string) error {
+	if !server.isInCluster() {
+		return errors.New("cluster support disabled")
+	}
+	if !server.raft.IsRaftLeader() {
+		return errors.New("not cluster leader, cannot set slot")
+	}
+
+	state := server.getSlotState()
+	switch strings.ToLower(action) {
+	default:
+		return fmt.Errorf("unknown slot action %s", action)
+
+	case slotActionImporting:
+		if state.Owns(slot) {
+			return fmt.Errorf("slot %d is already owned by this shard", slot)
+		}
+		if shardID == "" || shardID == server.config.ShardID {
+			return fmt.Errorf("cannot import slot %d from shard %s", slot, shardID)
+		}
+		state.Importing[slot] = shardID
+
+	case slotActionMigrating:
+		if !state.Owns(slot) {
+			return fmt.Errorf("slot %d is not owned by this shard", slot)
+		}
+		if shardID == "" || shardID == server.config.ShardID {
+			return fmt.Errorf("cannot migrate slot %d to shard %s", slot, shardID)
+		}
+		state.Migrating[slot] = shardID
+
+	case slotActionStable:
+		delete(state.Importing, slot)
+		delete(state.Migrating, slot)
+
+	case slotActionNode:
+		if shardID == server.config.ShardID {
+			shards, err := server.getClusterShards()
+			if err != nil {
+				return err
+			}
+			epoch := state.Epoch
+			for _, shard := range shards {
+				epoch = max(epoch, shard.Epoch)
+			}
+			state.SetOwned(slot, true)
+			state.Epoch = epoch + 1
+			delete(state.Importing, slot)
+			break
+		}
+		if len(server.keysInSlot(slot, 1)) > 0 {
+			return fmt.Errorf("slot %d still has keys, move them with CLUSTER MIGRATESLOT first", slot)
+		}
+		state.SetOwned(slot, false)
+		delete(state.Importing, slot)
+		delete(state.Migrating, slot)
+	}
+
+	return server.raftApplySlots(ctx, state)
+}
+
+// migrateSlot moves up to count keys of the migrating slot to the leader of the target shard.
+// Each key is restored on the target before it's deleted here. If the key is modified while it's being moved,
+// it's moved again. Returns the number of keys moved.
+func (server *EchoVault) migrateSlot(ctx context.Context, slot int, count int) (int, error) {
+	if !server.isInCluster() {
+		return 0, errors.New("cluster support disabled")
+	}
+	if !server.raft.IsRaftLeader() {
+		return 0, errors.New("not cluster leader, cannot migrate slot")
+	}
+
+	target, ok := server.getSlotState().Migrating[slot]
+	if !ok {
+		return 0, fmt.Errorf("slot %d is not migrating", slot)
+	}
+	meta, err := server.shardLeader(target)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, key := range server.keysInSlot(slot, count) {
+		if err = server.migrateKey(ctx, meta.ForwardAddr, key); err != nil {
+			return moved, fmt.Errorf("migrate key %s: %v", key, err)
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// migrateKey copies the key to the leader of the target shard at addr and then deletes it from this shard,
+// unless it was modified in the meantime, in which case it's copied again.
+func (server *EchoVault) migrateKey(ctx context.Context, addr string, key string) error {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+
+	for {
+		// The version of the key is read along with its value so that the deletion is aborted
+		// if the key is modified after it was copied.
+		s := server.getShard(key)
+		s.mutex.RLock()
+		entry, ok := s.store[key]
+		var value []byte
+		var version uint64
+		var err error
+		if ok && !server.isExpired(ctx, entry) {
+			value, err = codec.EncodeValue(entry.Value)
+			version = server.keyVersionUnlocked(key)
+		}
+		s.mutex.RUnlock()
+		if !ok || server.isExpired(ctx, entry) || err != nil {
+			return err
+		}
+
+		var expireAt int64
+		if !entry.ExpireAt.IsZero() {
+			expireAt = entry.ExpireAt.UnixNano()
+		}
+		response, err := server.forwardClient.Forward(ctx, addr, forward.Request{
+			Type:     forward.TypeRestoreKey,
+			ServerID: serverId,
+			Key:      key,
+			Value:    value,
+			ExpireAt: expireAt,
+		})
+		if err == nil && response.NotLeader {
+			err = fmt.Errorf("%s is not the leader of the target shard", addr)
+		}
+		if err == nil && response.Error != "" {
+			err = errors.New(response.Error)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The key is copied again if it was modified after it was copied.
+		deleted, err := server.raftApplyDeleteKeyIf(ctx, key, version)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return nil
+		}
+	}
+}
+
+// deleteKeyIfVersion deletes the key if its version is still the given version.
+// It's called when a delete-key-if request is applied. It returns false if the key was modified.
+func (server *EchoVault) deleteKeyIfVersion(key string, version uint64) (bool, error) {
+	unlock := server.lockShards([]string{key})
+	defer unlock()
+	if server.keyVersionUnlocked(key) != version {
+		return false, nil
+	}
+	return true, server.deleteKey(key, delEvent)
+}
+
+// handleRestoreKey stores a key migrated from another shard, if the slot of the key is being imported.
+func (server *EchoVault) handleRestoreKey(ctx context.Context, request forward.Request) ([]byte, error) {
+	slot := slots.KeySlot(request.Key)
+	state := server.getSlotState()
+	if _, importing := state.Importing[slot]; !importing && !state.Owns(slot) {
+		return nil, fmt.Errorf("slot %d is not being imported", slot)
+	}
+	if err := server.raftApplyRestoreKey(ctx, request.Key, request.Value, request.ExpireAt); err != nil {
+		return nil, err
+	}
+	return []byte("+OK\r\n"), nil
+}
+
+// restoreKey stores a key that was migrated from another shard. It's called when a restore-key request is applied.
+func (server *EchoVault) restoreKey(key string, value []byte, expireAt int64) error {
+	v, err := codec.DecodeValue(value)
+	if err != nil {
+		return err
+	}
+	var expiry time.Time
+	if expireAt != 0 {
+		expiry = time.Unix(0, expireAt)
+	}
+
+	ctx := context.Background()
+	unlock := server.lockShards([]string{key})
+	defer unlock()
+	if err = server.setValuesUnlocked(ctx, map[string]interface{}{key: v}); err != nil {
+		return err
+	}
+	server.setExpiryUnlocked(ctx, key, expiry, false)
+	return nil
+}
+
+// raftApplySlots replicates the new slot state to the nodes of the shard.
+func (server *EchoVault) raftApplySlots(ctx context.Context, state slots.State) error {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+
+	slotsRequest := internal.ApplyRequest{
+		Type:         "slots",
+		ServerID:     serverId,
+		ConnectionID: "nil",
+		Slots:        &state,
+	}
+
+	b, err := json.Marshal(slotsRequest)
+	if err != nil {
+		return fmt.Errorf("could not parse slots request: %v", err)
+	}
+
+	applyFuture := server.raft.Apply(b, 500*time.Millisecond)
+
+	if err = applyFuture.Error(); err != nil {
+		return err
+	}
+
+	r, ok := applyFuture.Response().(internal.ApplyResponse)
+
+	if !ok {
+		return fmt.Errorf("unprocessable entity %v", r)
+	}
+
+	return r.Error
+}
+
+// raftApplyRestoreKey replicates a key migrated from another shard to the nodes of the shard.
+func (server *EchoVault) raftApplyRestoreKey(ctx context.Context, key string, value []byte, expireAt int64) error {
+	serverId, _ := ctx.Value(internal.ContextServerID("ServerID")).(string)
+
+	restoreKeyRequest := internal.ApplyRequest{
+		Type:         "restore-key",
+		ServerID:     serverId,
+		ConnectionID: "nil",
+		Key:          key,
+		Value:        value,
+		ExpireAt:     expireAt,
+	}
+
+	b, err := json.Marshal(restoreKeyRequest)
+	if err != nil {
+		return fmt.Errorf("could not parse restore key request for key: %s", key)
+	}
+
+	applyFuture := server.raft.Apply(b, 500*time.Millisecond)
+
+	if err = applyFuture.Error(); err != nil {
+		return err
+	}
+
+	r, ok := applyFuture.Response().(internal.ApplyResponse)
+
+	if !ok {
+		return fmt.Errorf("unprocessable entity %v", r)
+	}
+
+	return r.Error
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/codec"
+	"log"
+	"net"
+	"slices"
+	"sync"
+)
+
+// stateView is a copy-on-write view of the keyspace at the time it was opened.
+// While a view is open, the first write to each key preserves the key's entry as it was when the view was opened,
+// and write commands modify a copy of the value instead of the value held by the view. This way, the view can be
+// persisted without blocking writes.
+type stateView struct {
+	// The preserved entries of each shard, guarded by the shard's mutex.
+	// A nil entry means the key did not exist when the view was opened.
+	preserved [shardCount]map[string]*internal.KeyData
+}
+
+// getState opens a view of the keyspace and returns its state.
+// It only waits for the write commands in progress to finish, writes carry on while the state is being read.
+func (server *EchoVault) getState() internal.StateView {
+	view := &stateView{}
+	for i := range view.preserved {
+		view.preserved[i] = make(map[string]*internal.KeyData)
+	}
+
+	server.stateViews.writes.Lock()
+	server.stateViews.mutex.Lock()
+	views := append(slices.Clone(server.openStateViews()), view)
+	server.stateViews.open.Store(&views)
+	server.stateViews.mutex.Unlock()
+	server.stateViews.writes.Unlock()
+
+	// Each shard is only read-locked while it's being copied.
+	state := make(map[string]internal.KeyData)
+	for i, s := range server.shards {
+		s.mutex.RLock()
+		for key, entry := range s.store {
+			if _, ok := view.preserved[i][key]; !ok {
+				state[key] = entry
+			}
+		}
+		for key, entry := range view.preserved[i] {
+			if entry != nil {
+				state[key] = *entry
+			}
+		}
+		s.mutex.RUnlock()
+	}
+
+	// The state shares the values with the store, so the view stays open until it's released.
+	return internal.StateView{
+		State: state,
+		Release: sync.OnceFunc(func() {
+			server.stateViews.mutex.Lock()
+			defer server.stateViews.mutex.Unlock()
+			views := slices.DeleteFunc(slices.Clone(server.openStateViews()), func(v *stateView) bool {
+				return v == view
+			})
+			server.stateViews.open.Store(&views)
+		}),
+	}
+}
+
+// openStateViews returns the views that are currently open.
+func (server *EchoVault) openStateViews() []*stateView {
+	if views := server.stateViews.open.Load(); views != nil {
+		return *views
+	}
+	return nil
+}
+
+// preserveKey preserves the entry of the key in each of the open views that haven't preserved it yet.
+// It must be called before the key is modified. Returns true if any of the views preserved the key.
+// The caller must hold the write lock of the key's shard.
+func (server *EchoVault) preserveKey(key string) bool {
+	views := server.openStateViews()
+	if len(views) == 0 {
+		return false
+	}
+	i := shardIndex(key)
+	preserved := false
+	for _, view := range views {
+		if _, ok := view.preserved[i][key]; ok {
+			continue
+		}
+		if entry, ok := server.shards[i].store[key]; ok {
+			view.preserved[i][key] = &entry
+		} else {
+			view.preserved[i][key] = nil
+		}
+		preserved = true
+	}
+	return preserved
+}
+
+// getValuesForWrite is the same as getValues, but it's used by write commands as they can modify the values in place.
+// If a view is open, the first write to each key replaces the value in the store with a copy, which is returned
+// to the command. The view keeps the original value.
+func (server *EchoVault) getValuesForWrite(ctx context.Context, keys []string) map[string]interface{} {
+	if len(server.openStateViews()) == 0 {
+		return server.getValues(ctx, keys)
+	}
+	unlock := server.lockShards(keys)
+	defer unlock()
+	return server.getValuesForWriteUnlocked(ctx, keys)
+}
+
+// getValuesForWriteUnlocked is the same as getValuesForWrite but assumes the caller already holds the write locks
+// of the keys' shards.
+func (server *EchoVault) getValuesForWriteUnlocked(ctx context.Context, keys []string) map[string]interface{} {
+	for _, key := range keys {
+		if !server.preserveKey(key) {
+			continue
+		}
+		s := server.getShard(key)
+		entry, ok := s.store[key]
+		if !ok {
+			continue
+		}
+		value, err := codec.Clone(entry.Value)
+		if err != nil {
+			log.Printf("getValuesForWrite: %+v\n", err)
+			continue
+		}
+		s.store[key] = internal.KeyData{Value: value, ExpireAt: entry.ExpireAt, Version: entry.Version}
+	}
+	return server.getValuesUnlocked(ctx, keys)
+}
+
+// getWriteHandlerFuncParams returns the handler params for a write command.
+func (server *EchoVault) getWriteHandlerFuncParams(ctx context.Context, cmd []string, conn *net.Conn) internal.HandlerFuncParams {
+	params := server.getHandlerFuncParams(ctx, cmd, conn)
+	params.GetValues = server.getValuesForWrite
+	return params
+}
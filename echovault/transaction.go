@@ -0,0 +1,458 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echovault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"net"
+	"slices"
+	"strings"
+)
+
+// transactionState holds the transaction state of a single connection.
+// A transaction is only ever accessed from the goroutine that serves its connection,
+// so its fields do not need to be guarded.
+type transactionState struct {
+	queueing bool              // True when MULTI has been called and commands are being queued.
+	failed   bool              // True when a command could not be queued. EXEC will discard the transaction.
+	commands [][]string        // The commands queued since MULTI was called, in order.
+	watched  map[string]uint64 // The watched keys mapped to their version at the time WATCH was called.
+}
+
+// In cluster mode, the version of a watched key is the index of the raft log entry that last modified it
+// (see keyVersionUnlocked) instead of being counted locally, so that every node can check the watched keys
+// of a transaction applied through raft.
+
+// keyVersion tracks the version of a key that is being watched by at least one transaction.
+type keyVersion struct {
+	version  uint64 // Incremented each time the key is modified.
+	watchers int    // The number of transactions currently watching the key.
+}
+
+// Commands that control the transaction block are executed immediately instead of being queued.
+var transactionControlCommands = []string{"multi", "exec", "discard", "watch"}
+
+func newTransactionState() *transactionState {
+	return &transactionState{
+		queueing: false,
+		failed:   false,
+		commands: make([][]string, 0),
+		watched:  make(map[string]uint64),
+	}
+}
+
+// getTransaction returns the transaction state associated with the connection.
+// If create is true, the transaction state is created if it does not exist yet.
+func (server *EchoVault) getTransaction(conn *net.Conn, create bool) *transactionState {
+	server.transactions.mutex.Lock()
+	defer server.transactions.mutex.Unlock()
+	tx, ok := server.transactions.connections[conn]
+	if !ok && create {
+		tx = newTransactionState()
+		server.transactions.connections[conn] = tx
+	}
+	return tx
+}
+
+// removeTransaction unwatches all the keys watched by the connection and removes its transaction state.
+func (server *EchoVault) removeTransaction(conn *net.Conn) {
+	server.transactions.mutex.Lock()
+	tx, ok := server.transactions.connections[conn]
+	delete(server.transactions.connections, conn)
+	server.transactions.mutex.Unlock()
+
+	if ok {
+		server.unwatchKeys(tx)
+	}
+}
+
+// touchWatchedKey increments the version of the key if it's being watched.
+// In cluster mode, the key is stamped with the index of the raft log entry being applied instead.
+// The caller must hold the write lock of the key's shard.
+func (server *EchoVault) touchWatchedKey(key string) {
+	if server.isInCluster() {
+		s := server.getShard(key)
+		if entry, ok := s.store[key]; ok {
+			entry.Version = server.appliedIndex.Load()
+			s.store[key] = entry
+		}
+		return
+	}
+
+	server.keyVersions.mutex.Lock()
+	defer server.keyVersions.mutex.Unlock()
+	if kv, ok := server.keyVersions.versions[key]; ok {
+		kv.version += 1
+	}
+}
+
+func (server *EchoVault) watchKeys(tx *transactionState, keys []string) {
+	var versions map[string]uint64
+	if server.isInCluster() {
+		// The shards are locked before the key versions, like when a key is modified.
+		versions = make(map[string]uint64, len(keys))
+		unlock := server.rLockShards(keys)
+		for _, key := range keys {
+			versions[key] = server.keyVersionUnlocked(key)
+		}
+		unlock()
+	}
+
+	server.keyVersions.mutex.Lock()
+	defer server.keyVersions.mutex.Unlock()
+	for _, key := range keys {
+		if _, ok := tx.watched[key]; ok {
+			continue
+		}
+		kv, ok := server.keyVersions.versions[key]
+		if !ok {
+			kv = &keyVersion{version: 0, watchers: 0}
+			server.keyVersions.versions[key] = kv
+		}
+		kv.watchers += 1
+		tx.watched[key] = kv.version
+		if versions != nil {
+			tx.watched[key] = versions[key]
+		}
+	}
+}
+
+func (server *EchoVault) unwatchKeys(tx *transactionState) {
+	server.keyVersions.mutex.Lock()
+	defer server.keyVersions.mutex.Unlock()
+	for key := range tx.watched {
+		kv, ok := server.keyVersions.versions[key]
+		if !ok {
+			continue
+		}
+		kv.watchers -= 1
+		if kv.watchers <= 0 {
+			delete(server.keyVersions.versions, key)
+		}
+	}
+	clear(tx.watched)
+}
+
+// keyVersionUnlocked returns the version of the key in cluster mode, which is the index of the raft log entry
+// that last modified it, or 0 if the key does not exist. The versions are part of the raft snapshots, so the nodes
+// agree on whether a replicated transaction's watched keys were modified.
+// The caller must hold a lock of the key's shard.
+func (server *EchoVault) keyVersionUnlocked(key string) uint64 {
+	return server.getShard(key).store[key].Version
+}
+
+// watchedKeysModified returns true if any of the watched keys have been modified since they were watched.
+// In cluster mode, the caller must hold the locks of the watched keys' shards.
+func (server *EchoVault) watchedKeysModified(watched map[string]uint64) bool {
+	if server.isInCluster() {
+		for key, version := range watched {
+			if server.keyVersionUnlocked(key) != version {
+				return true
+			}
+		}
+		return false
+	}
+
+	server.keyVersions.mutex.Lock()
+	defer server.keyVersions.mutex.Unlock()
+	for key, version := range watched {
+		if kv, ok := server.keyVersions.versions[key]; !ok || kv.version != version {
+			return true
+		}
+	}
+	return false
+}
+
+// queueCommand adds the command to the connection's transaction if the connection is in a transaction block.
+// Returns true if the command was queued. The command is validated with its key extraction function
+// before it's queued. If validation fails, the transaction is marked as failed and will be discarded on EXEC.
+func (server *EchoVault) queueCommand(conn *net.Conn, command internal.Command, subCommand internal.SubCommand, cmd []string) (bool, error) {
+	if conn == nil || slices.Contains(transactionControlCommands, strings.ToLower(command.Command)) {
+		return false, nil
+	}
+	tx := server.getTransaction(conn, false)
+	if tx == nil || !tx.queueing {
+		return false, nil
+	}
+
+	keyExtractionFunc := command.KeyExtractionFunc
+	if subCommand.KeyExtractionFunc != nil {
+		keyExtractionFunc = subCommand.KeyExtractionFunc
+	}
+	if _, err := keyExtractionFunc(cmd); err != nil {
+		tx.failed = true
+		return false, err
+	}
+
+	tx.commands = append(tx.commands, cmd)
+	return true, nil
+}
+
+// failTransaction marks the connection's transaction as failed if the connection is in a transaction block.
+func (server *EchoVault) failTransaction(conn *net.Conn) {
+	if conn == nil {
+		return
+	}
+	if tx := server.getTransaction(conn, false); tx != nil && tx.queueing {
+		tx.failed = true
+	}
+}
+
+func (server *EchoVault) multi(conn *net.Conn) error {
+	if conn == nil {
+		return errors.New("MULTI requires a client connection, use the Transaction method in embedded mode")
+	}
+	tx := server.getTransaction(conn, true)
+	if tx.queueing {
+		return errors.New("MULTI calls can not be nested")
+	}
+	tx.queueing = true
+	return nil
+}
+
+func (server *EchoVault) discard(conn *net.Conn) error {
+	if conn == nil {
+		return errors.New("DISCARD without MULTI")
+	}
+	tx := server.getTransaction(conn, false)
+	if tx == nil || !tx.queueing {
+		return errors.New("DISCARD without MULTI")
+	}
+	server.removeTransaction(conn)
+	return nil
+}
+
+func (server *EchoVault) watch(conn *net.Conn, keys []string) error {
+	if conn == nil {
+		return errors.New("WATCH requires a client connection, use the Transaction method in embedded mode")
+	}
+	tx := server.getTransaction(conn, true)
+	if tx.queueing {
+		return errors.New("WATCH inside MULTI is not allowed")
+	}
+	server.watchKeys(tx, keys)
+	return nil
+}
+
+func (server *EchoVault) unwatch(conn *net.Conn) {
+	if conn == nil {
+		return
+	}
+	if tx := server.getTransaction(conn, false); tx != nil {
+		server.unwatchKeys(tx)
+	}
+}
+
+func (server *EchoVault) exec(ctx context.Context, conn *net.Conn) ([]byte, error) {
+	if conn == nil {
+		return nil, errors.New("EXEC without MULTI")
+	}
+	tx := server.getTransaction(conn, false)
+	if tx == nil || !tx.queueing {
+		return nil, errors.New("EXEC without MULTI")
+	}
+	// The transaction state is always cleared after EXEC, regardless of the outcome.
+	defer server.removeTransaction(conn)
+
+	if tx.failed {
+		return nil, errors.New("EXECABORT transaction discarded because of previous errors")
+	}
+
+	return server.commitTransaction(ctx, conn, tx.commands, tx.watched)
+}
+
+// commitTransaction executes the queued commands of a transaction.
+// In standalone mode, the commands are executed locally and the write commands are appended to the AOF.
+// In cluster mode, transactions containing commands that must be synced are replicated as a
+// single raft log entry along with the versions of the watched keys, which are checked when the entry is applied.
+// Followers forward these transactions to the leader if command forwarding is enabled.
+func (server *EchoVault) commitTransaction(ctx context.Context, conn *net.Conn, commands [][]string, watched map[string]uint64) ([]byte, error) {
+	replicate := false
+	reads := false
+	var writeCommands [][]string
+	for i, cmd := range commands {
+		if isScriptCommand(cmd) {
+			// A script may write to any of its keys, so it's always replicated and appended to the AOF.
+			// The script body is used in place of its SHA1 digest so that it can be replayed on its own.
+			commands[i] = server.resolveScriptCommand(cmd)
+			replicate = true
+			writeCommands = append(writeCommands, commands[i])
+			continue
+		}
+		command, err := server.getCommand(cmd[0])
+		if err != nil {
+			return nil, err
+		}
+		synchronize := command.Sync
+		sc, err := internal.GetSubCommand(command, cmd)
+		if err != nil {
+			return nil, err
+		}
+		subCommand, ok := sc.(internal.SubCommand)
+		if ok {
+			synchronize = subCommand.Sync
+		}
+		replicate = replicate || synchronize
+		reads = reads || internal.IsReadCommand(command, subCommand)
+		if internal.IsWriteCommand(command, subCommand) {
+			writeCommands = append(writeCommands, cmd)
+		}
+	}
+
+	if server.isInCluster() && !replicate && reads {
+		// The transaction is executed locally, so reject it if this node can't serve its reads
+		// with the required consistency. Replicated transactions are read by the leader when they're applied.
+		if err := server.verifyReadConsistency(ctx, server.getReadConsistency(ctx, conn)); err != nil {
+			return nil, err
+		}
+	}
+
+	if !server.isInCluster() || !replicate {
+		res, err := server.execTransaction(ctx, conn, commands, watched)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			// A watched key was modified, so the transaction was aborted.
+			return []byte("*-1\r\n"), nil
+		}
+		if !server.isInCluster() && len(writeCommands) > 0 {
+			go func() {
+				for _, cmd := range writeCommands {
+					server.aofEngine.QueueCommand(internal.EncodeCommand(cmd))
+				}
+			}()
+		}
+		return res, nil
+	}
+
+	var res []byte
+	var err error
+	switch {
+	case server.raft.IsRaftLeader():
+		res, err = server.raftApplyTransaction(ctx, commands, watched)
+	case server.config.ForwardCommand:
+		// The whole transaction is forwarded, so the leader applies it as a single raft log entry.
+		ctx = context.WithValue(ctx, internal.ContextProtocol("Protocol"), server.getConnectionInfo(conn).Protocol)
+		res, err = server.forwardTransaction(ctx, commands, watched)
+	default:
+		return nil, errors.New("not cluster leader, cannot carry out transaction")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		// A watched key was modified before the transaction was applied, so it was aborted.
+		return []byte("*-1\r\n"), nil
+	}
+	return res, nil
+}
+
+// execTransaction executes all the commands while holding the locks of all the shards, so no other command
+// can observe or modify the keyspace until the whole transaction is complete.
+// If any of the watched keys were modified, none of the commands are executed and nil is returned.
+// Errors from individual commands do not abort the transaction, they're returned in the
+// corresponding position of the response array instead.
+func (server *EchoVault) execTransaction(ctx context.Context, conn *net.Conn, commands [][]string, watched map[string]uint64) ([]byte, error) {
+	server.stateViews.writes.RLock()
+	defer server.stateViews.writes.RUnlock()
+
+	unlock := server.lockAllShards()
+	defer unlock()
+
+	if server.watchedKeysModified(watched) {
+		return nil, nil
+	}
+
+	res := []byte(fmt.Sprintf("*%d\r\n", len(commands)))
+	for _, cmd := range commands {
+		r, err := server.execQueuedCommand(ctx, conn, cmd)
+		if err != nil {
+			res = append(res, []byte(fmt.Sprintf("-Error %s\r\n", err.Error()))...)
+			continue
+		}
+		if len(r) == 0 {
+			r = []byte("$-1\r\n")
+		}
+		res = append(res, r...)
+	}
+	return res, nil
+}
+
+// execQueuedCommand executes a single queued command. The caller must hold the locks of all the shards.
+func (server *EchoVault) execQueuedCommand(ctx context.Context, conn *net.Conn, cmd []string) ([]byte, error) {
+	command, err := server.getCommand(cmd[0])
+	if err != nil {
+		return nil, err
+	}
+	handler := command.HandlerFunc
+	sc, err := internal.GetSubCommand(command, cmd)
+	if err != nil {
+		return nil, err
+	}
+	subCommand, ok := sc.(internal.SubCommand)
+	if ok {
+		handler = subCommand.HandlerFunc
+	}
+	params := server.getTransactionHandlerFuncParams(ctx, cmd, conn)
+	if internal.IsWriteCommand(command, subCommand) {
+		params.GetValues = server.getValuesForWriteUnlocked
+	}
+	res, err := handler(params)
+	if err != nil {
+		return nil, err
+	}
+	if internal.IsWriteCommand(command, subCommand) {
+		keyExtractionFunc := command.KeyExtractionFunc
+		if subCommand.KeyExtractionFunc != nil {
+			keyExtractionFunc = subCommand.KeyExtractionFunc
+		}
+		if keys, err := keyExtractionFunc(cmd); err == nil {
+			server.keysModifiedUnlocked(keys.WriteKeys)
+		}
+	}
+	return res, nil
+}
+
+// getTransactionHandlerFuncParams returns handler params whose keyspace functions
+// do not acquire the shards' locks, as they're already held for the duration of the transaction.
+func (server *EchoVault) getTransactionHandlerFuncParams(ctx context.Context, cmd []string, conn *net.Conn) internal.HandlerFuncParams {
+	params := server.getHandlerFuncParams(ctx, cmd, conn)
+	params.KeysExist = server.keysExistUnlocked
+	params.ScanKeys = server.scanKeysUnlocked
+	params.GetScanIndex = server.getScanIndexUnlocked
+	params.GetExpiry = server.getExpiryUnlocked
+	params.GetValues = server.getValuesUnlocked
+	params.SetValues = server.setValuesUnlocked
+	params.SetExpiry = server.setExpiryUnlocked
+	params.DeleteKey = func(key string) error {
+		return server.deleteKey(key, delEvent)
+	}
+	// Commands can not block inside a transaction as the store is locked until the transaction completes.
+	params.NotifyOnKeys = nil
+	params.QueueOnKeys = nil
+	params.Eval = server.evalUnlocked
+	params.EvalSHA = server.evalSHAUnlocked
+	return params
+}
+
+// execReplicatedTransaction executes a transaction that was replicated through the raft log.
+// It returns nil without executing the commands if any of the watched keys were modified.
+func (server *EchoVault) execReplicatedTransaction(ctx context.Context, commands [][]string, watched map[string]uint64) ([]byte, error) {
+	return server.execTransaction(ctx, nil, commands, watched)
+}
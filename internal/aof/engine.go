@@ -42,7 +42,7 @@ type Engine struct {
 
 	startRewriteFunc  func()
 	finishRewriteFunc func()
-	getStateFunc      func() map[string]internal.KeyData
+	getStateFunc      func() internal.StateView
 	setKeyDataFunc    func(key string, data internal.KeyData)
 	handleCommand     func(command []byte)
 }
@@ -77,7 +77,7 @@ func WithFinishRewriteFunc(f func()) func(engine *Engine) {
 	}
 }
 
-func WithGetStateFunc(f func() map[string]internal.KeyData) func(engine *Engine) {
+func WithGetStateFunc(f func() internal.StateView) func(engine *Engine) {
 	return func(engine *Engine) {
 		engine.getStateFunc = f
 	}
@@ -117,7 +117,7 @@ func NewAOFEngine(options ...func(engine *Engine)) (*Engine, error) {
 		logCount:          0,
 		startRewriteFunc:  func() {},
 		finishRewriteFunc: func() {},
-		getStateFunc:      func() map[string]internal.KeyData { return nil },
+		getStateFunc:      func() internal.StateView { return internal.StateView{Release: func() {}} },
 		setKeyDataFunc:    func(key string, data internal.KeyData) {},
 		handleCommand:     func(command []byte) {},
 	}
@@ -71,8 +71,8 @@ func Test_AOFEngine(t *testing.T) {
 		"key9":  {Value: "value9", ExpireAt: time.Time{}},
 		"key10": {Value: "value10", ExpireAt: time.Time{}},
 	}
-	getStateFunc := func() map[string]internal.KeyData {
-		return state
+	getStateFunc := func() internal.StateView {
+		return internal.StateView{State: state, Release: func() {}}
 	}
 	setKeyDataFunc := func(key string, data internal.KeyData) {
 		restoredState[key] = data
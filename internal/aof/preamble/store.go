@@ -15,10 +15,12 @@
 package preamble
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/clock"
+	"github.com/echovault/echovault/internal/codec"
 	"io"
 	"os"
 	"path"
@@ -38,7 +40,7 @@ type PreambleStore struct {
 	rw             PreambleReadWriter
 	mut            sync.Mutex
 	directory      string
-	getStateFunc   func() map[string]internal.KeyData
+	getStateFunc   func() internal.StateView
 	setKeyDataFunc func(key string, data internal.KeyData)
 }
 
@@ -54,7 +56,7 @@ func WithReadWriter(rw PreambleReadWriter) func(store *PreambleStore) {
 	}
 }
 
-func WithGetStateFunc(f func() map[string]internal.KeyData) func(store *PreambleStore) {
+func WithGetStateFunc(f func() internal.StateView) func(store *PreambleStore) {
 	return func(store *PreambleStore) {
 		store.getStateFunc = f
 	}
@@ -78,9 +80,9 @@ func NewPreambleStore(options ...func(store *PreambleStore)) (*PreambleStore, er
 		rw:        nil,
 		mut:       sync.Mutex{},
 		directory: "",
-		getStateFunc: func() map[string]internal.KeyData {
+		getStateFunc: func() internal.StateView {
 			// No-Op by default
-			return nil
+			return internal.StateView{Release: func() {}}
 		},
 		setKeyDataFunc: func(key string, data internal.KeyData) {},
 	}
@@ -107,30 +109,29 @@ func NewPreambleStore(options ...func(store *PreambleStore)) (*PreambleStore, er
 
 func (store *PreambleStore) CreatePreamble() error {
 	store.mut.Lock()
-	store.mut.Unlock()
+	defer store.mut.Unlock()
 
-	// Get current state.
-	state := store.filterExpiredKeys(store.getStateFunc())
-	o, err := json.Marshal(state)
-	if err != nil {
-		return err
-	}
+	// Get a view of the current state. Writes can carry on while it's being written to the preamble.
+	view := store.getStateFunc()
+	defer view.Release()
+	state := store.filterExpiredKeys(view.State)
 
 	// Truncate the preamble first
-	if err = store.rw.Truncate(0); err != nil {
+	if err := store.rw.Truncate(0); err != nil {
 		return err
 	}
 	// Seek to the beginning of the file after truncating
-	if _, err = store.rw.Seek(0, 0); err != nil {
+	if _, err := store.rw.Seek(0, 0); err != nil {
 		return err
 	}
 
-	if _, err = store.rw.Write(o); err != nil {
+	// Stream the state to the preamble
+	if err := codec.Encode(store.rw, internal.SnapshotObject{State: state}); err != nil {
 		return err
 	}
 
 	// Sync the changes
-	if err = store.rw.Sync(); err != nil {
+	if err := store.rw.Sync(); err != nil {
 		return err
 	}
 
@@ -147,19 +148,24 @@ func (store *PreambleStore) Restore() error {
 		return fmt.Errorf("restore preamble: %v", err)
 	}
 
-	b, err := io.ReadAll(store.rw)
-	if err != nil {
-		return err
-	}
-
-	if len(b) <= 0 {
+	r := bufio.NewReader(store.rw)
+	if _, err := r.Peek(1); err == io.EOF {
 		return nil
 	}
 
 	state := make(map[string]internal.KeyData)
 
-	if err = json.Unmarshal(b, &state); err != nil {
-		return err
+	if codec.IsBinary(r) {
+		object, err := codec.Decode(r)
+		if err != nil {
+			return err
+		}
+		state = object.State
+	} else {
+		// Preambles created before the binary format was introduced are JSON encoded.
+		if err := json.NewDecoder(r).Decode(&state); err != nil {
+			return err
+		}
 	}
 
 	for key, data := range store.filterExpiredKeys(state) {
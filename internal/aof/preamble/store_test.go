@@ -148,8 +148,8 @@ func Test_PreambleStore(t *testing.T) {
 		options := []func(store *preamble.PreambleStore){
 			preamble.WithClock(clock.NewClock()),
 			preamble.WithDirectory(test.directory),
-			preamble.WithGetStateFunc(func() map[string]internal.KeyData {
-				return test.state
+			preamble.WithGetStateFunc(func() internal.StateView {
+				return internal.StateView{State: test.state, Release: func() {}}
 			}),
 			preamble.WithSetKeyDataFunc(func(key string, data internal.KeyData) {
 				entry, ok := test.wantState[key]
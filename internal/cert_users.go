@@ -0,0 +1,86 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The client certificate fields that can be mapped to an ACL user.
+const (
+	CertFieldCN          = "cn"          // The common name of the certificate's subject.
+	CertFieldSAN         = "san"         // Any of the certificate's DNS names, email addresses, IP addresses or URIs.
+	CertFieldFingerprint = "fingerprint" // The hex encoded SHA256 digest of the certificate. Colons are ignored.
+)
+
+// CertUserMapping maps the verified mTLS client certificates that match it to an ACL user.
+type CertUserMapping struct {
+	Field    string // The certificate field to match: cn, san or fingerprint.
+	Value    string // The value the field must have.
+	Username string // The ACL user the connection is authenticated as.
+}
+
+// ParseCertUserMapping parses a mapping of the mtls-user config, in the format <field>:<value>=<username>
+// (e.g. "cn:client1=alice" or "fingerprint:69:39:97:...=bob").
+func ParseCertUserMapping(mapping string) (CertUserMapping, error) {
+	fieldEnd := strings.Index(mapping, ":")
+	valueEnd := strings.LastIndex(mapping, "=")
+	if fieldEnd == -1 || valueEnd < fieldEnd {
+		return CertUserMapping{}, fmt.Errorf("mtls user mapping %s must be in the format <field>:<value>=<username>", mapping)
+	}
+
+	m := CertUserMapping{
+		Field:    strings.ToLower(mapping[:fieldEnd]),
+		Value:    mapping[fieldEnd+1 : valueEnd],
+		Username: mapping[valueEnd+1:],
+	}
+	if !slices.Contains([]string{CertFieldCN, CertFieldSAN, CertFieldFingerprint}, m.Field) {
+		return CertUserMapping{}, fmt.Errorf("mtls user mapping field %s must be cn, san or fingerprint", m.Field)
+	}
+	if m.Value == "" || m.Username == "" {
+		return CertUserMapping{}, fmt.Errorf("mtls user mapping %s must have a value and a username", mapping)
+	}
+	if m.Field == CertFieldFingerprint {
+		m.Value = strings.ToLower(strings.ReplaceAll(m.Value, ":", ""))
+	}
+
+	return m, nil
+}
+
+// Matches returns true if the certificate has the field value of the mapping.
+func (m CertUserMapping) Matches(cert *x509.Certificate) bool {
+	switch m.Field {
+	case CertFieldCN:
+		return cert.Subject.CommonName == m.Value
+	case CertFieldSAN:
+		names := append(slices.Clone(cert.DNSNames), cert.EmailAddresses...)
+		for _, ip := range cert.IPAddresses {
+			names = append(names, ip.String())
+		}
+		for _, uri := range cert.URIs {
+			names = append(names, uri.String())
+		}
+		return slices.Contains(names, m.Value)
+	case CertFieldFingerprint:
+		fingerprint := sha256.Sum256(cert.Raw)
+		return hex.EncodeToString(fingerprint[:]) == m.Value
+	}
+	return false
+}
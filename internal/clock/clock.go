@@ -39,3 +39,17 @@ func (MockClock) Now() time.Time {
 func (MockClock) After(d time.Duration) <-chan time.Time {
 	return time.After(d)
 }
+
+// FixedClock always returns the same time from Now.
+// It's used when applying replicated commands so that every node uses the leader's time.
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
+
+func (FixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Clone returns a deep copy of the value, made by encoding and decoding it with the codec registered for its type.
+// Strings and numbers are immutable, so they're returned as they are. So are the values of types without a codec,
+// as decoding them from JSON would change their type.
+func Clone(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case nil, string, int, int64, float64:
+		return value, nil
+	}
+	c := codecForValue(value)
+	if c.Tag == TagJSON {
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	if err := c.Encode(writer, value); err != nil {
+		return nil, err
+	}
+	if writer.err == nil {
+		writer.err = writer.w.Flush()
+	}
+	if writer.err != nil {
+		return nil, writer.err
+	}
+
+	reader := NewReader(&buf)
+	clone, err := c.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return clone, nil
+}
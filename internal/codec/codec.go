@@ -0,0 +1,399 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package codec implements the binary format used to persist the keyspace in snapshots,
+// AOF preambles and raft snapshots.
+//
+// A snapshot starts with a header made of the magic bytes "EVSNAP", the format version and the latest
+// snapshot time in unix milliseconds. It is followed by one record for each key, holding the key, its expiry,
+// its version and its value. Each value is prefixed with the tag of the codec that encodes it (see Register), so that it
+// is restored with the type it was stored with. Raft snapshots of a sharded cluster also hold a record with the
+// JSON encoded slot state of the raft group. The snapshot ends with an end of file marker and the CRC-32C
+// checksum of everything written before the checksum.
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/slots"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// Version is the version of the format written by Encode.
+// Version 1 snapshots don't hold the versions of the keys. They're restored with a zero version.
+const Version = 2
+
+// maxLength is the maximum length of a string or a collection that is accepted when decoding,
+// so that a corrupted length can't trigger a huge allocation.
+const maxLength = 512 * 1024 * 1024
+
+const (
+	opKey   byte = 0x01 // Starts a key record.
+	opSlots byte = 0x02 // Starts the slot state record.
+	opEOF   byte = 0xFF // Marks the end of the records. It's followed by the checksum.
+)
+
+var (
+	magic     = []byte("EVSNAP")
+	crcTable  = crc32.MakeTable(crc32.Castagnoli)
+	ErrFormat = errors.New("invalid snapshot format")
+)
+
+// IsBinary reports whether the reader starts with the header of the binary format.
+// Snapshots taken before the binary format was introduced are JSON encoded, and do not.
+func IsBinary(r *bufio.Reader) bool {
+	b, err := r.Peek(len(magic))
+	return err == nil && bytes.Equal(b, magic)
+}
+
+// Encode writes the snapshot object to w in the binary format.
+// The records are streamed to w as they're encoded, in the order of their keys, so the same state
+// always produces the same output.
+func Encode(w io.Writer, object internal.SnapshotObject) error {
+	writer := NewWriter(w)
+
+	writer.write(magic)
+	writer.WriteUvarint(Version)
+	writer.WriteVarint(object.LatestSnapshotMilliseconds)
+
+	if object.Slots != nil {
+		b, err := json.Marshal(object.Slots)
+		if err != nil {
+			return fmt.Errorf("encode slots: %w", err)
+		}
+		writer.writeByte(opSlots)
+		writer.WriteString(string(b))
+	}
+
+	keys := make([]string, 0, len(object.State))
+	for key := range object.State {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		data := object.State[key]
+		writer.writeByte(opKey)
+		writer.WriteString(key)
+		if data.ExpireAt == (time.Time{}) {
+			writer.writeByte(0)
+		} else {
+			writer.writeByte(1)
+			writer.WriteVarint(data.ExpireAt.Unix())
+			writer.WriteUvarint(uint64(data.ExpireAt.Nanosecond()))
+		}
+		writer.WriteUvarint(data.Version)
+		if err := writer.WriteValue(data.Value); err != nil {
+			return fmt.Errorf("encode key %s: %w", key, err)
+		}
+	}
+
+	writer.writeByte(opEOF)
+	if writer.err != nil {
+		return writer.err
+	}
+	if err := binary.Write(writer.w, binary.BigEndian, writer.crc.Sum32()); err != nil {
+		return err
+	}
+	return writer.w.Flush()
+}
+
+// Decode reads a snapshot object written by Encode. The snapshot is only returned once its checksum
+// has been verified, so a corrupted snapshot is never partially restored.
+func Decode(r io.Reader) (internal.SnapshotObject, error) {
+	reader := NewReader(r)
+	object := internal.SnapshotObject{State: make(map[string]internal.KeyData)}
+
+	header := make([]byte, len(magic))
+	reader.read(header)
+	if reader.err != nil || !bytes.Equal(header, magic) {
+		return internal.SnapshotObject{}, ErrFormat
+	}
+	version := reader.ReadUvarint()
+	if reader.err == nil && (version < 1 || version > Version) {
+		return internal.SnapshotObject{}, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	object.LatestSnapshotMilliseconds = reader.ReadVarint()
+
+	for reader.err == nil {
+		switch op := reader.readByte(); op {
+		default:
+			reader.fail(fmt.Errorf("%w: unknown opcode %#x", ErrFormat, op))
+		case opKey:
+			key := reader.ReadString()
+			var data internal.KeyData
+			if reader.readByte() == 1 {
+				sec := reader.ReadVarint()
+				data.ExpireAt = time.Unix(sec, int64(reader.ReadUvarint()))
+			}
+			if version >= 2 {
+				data.Version = reader.ReadUvarint()
+			}
+			data.Value = reader.ReadValue()
+			object.State[key] = data
+		case opSlots:
+			b := reader.ReadString()
+			if reader.err != nil {
+				break
+			}
+			object.Slots = &slots.State{}
+			if err := json.Unmarshal([]byte(b), object.Slots); err != nil {
+				reader.fail(fmt.Errorf("%w: %v", ErrFormat, err))
+			}
+		case opEOF:
+			sum := reader.crc.Sum32()
+			var checksum uint32
+			if err := binary.Read(reader.r, binary.BigEndian, &checksum); err != nil {
+				return internal.SnapshotObject{}, fmt.Errorf("%w: %v", ErrFormat, err)
+			}
+			if checksum != sum {
+				return internal.SnapshotObject{}, fmt.Errorf("%w: checksum mismatch", ErrFormat)
+			}
+			return object, nil
+		}
+	}
+
+	if errors.Is(reader.err, io.EOF) || errors.Is(reader.err, io.ErrUnexpectedEOF) {
+		return internal.SnapshotObject{}, fmt.Errorf("%w: unexpected end of snapshot", ErrFormat)
+	}
+	return internal.SnapshotObject{}, reader.err
+}
+
+// EncodeValue encodes a single value with the codec registered for its type.
+// It's used to move keys between the raft groups of a sharded cluster.
+func EncodeValue(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	if err := writer.WriteValue(value); err != nil {
+		return nil, err
+	}
+	if err := writer.w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeValue decodes a value encoded by EncodeValue.
+func DecodeValue(b []byte) (interface{}, error) {
+	reader := NewReader(bytes.NewReader(b))
+	value := reader.ReadValue()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return value, nil
+}
+
+// Writer encodes values to the snapshot and keeps track of its checksum.
+// The first error is kept and returned by the WriteValue call that follows it, so codecs can
+// write their fields one after the other without checking each of them.
+type Writer struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), crc: crc32.New(crcTable)}
+}
+
+func (writer *Writer) write(b []byte) {
+	if writer.err != nil {
+		return
+	}
+	if _, writer.err = writer.w.Write(b); writer.err == nil {
+		_, _ = writer.crc.Write(b)
+	}
+}
+
+func (writer *Writer) writeByte(b byte) {
+	writer.buf[0] = b
+	writer.write(writer.buf[:1])
+}
+
+func (writer *Writer) WriteUvarint(n uint64) {
+	writer.write(writer.buf[:binary.PutUvarint(writer.buf[:], n)])
+}
+
+func (writer *Writer) WriteVarint(n int64) {
+	writer.write(writer.buf[:binary.PutVarint(writer.buf[:], n)])
+}
+
+// WriteFloat writes the IEEE 754 bits of the float, so that every float (including NaN and the infinities)
+// is restored exactly.
+func (writer *Writer) WriteFloat(f float64) {
+	binary.BigEndian.PutUint64(writer.buf[:8], math.Float64bits(f))
+	writer.write(writer.buf[:8])
+}
+
+func (writer *Writer) WriteString(s string) {
+	writer.WriteUvarint(uint64(len(s)))
+	if writer.err == nil {
+		_, writer.err = writer.w.WriteString(s)
+		_, _ = writer.crc.Write([]byte(s))
+	}
+}
+
+func (writer *Writer) WriteBytes(b []byte) {
+	writer.WriteUvarint(uint64(len(b)))
+	writer.write(b)
+}
+
+// WriteValue writes the tag of the codec registered for the type of the value, followed by the value
+// encoded with the codec. Values of types without a codec are JSON encoded.
+func (writer *Writer) WriteValue(value interface{}) error {
+	c := codecForValue(value)
+	writer.writeByte(c.Tag)
+	if writer.err != nil {
+		return writer.err
+	}
+	if err := c.Encode(writer, value); err != nil {
+		return err
+	}
+	return writer.err
+}
+
+// Reader decodes values from the snapshot and keeps track of its checksum.
+// Like the Writer, the first error is kept, and the reads that follow it return zero values.
+type Reader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+func NewReader(r io.Reader) *Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Reader{r: br, crc: crc32.New(crcTable)}
+}
+
+func (reader *Reader) fail(err error) {
+	if reader.err == nil {
+		reader.err = err
+	}
+}
+
+// Err returns the first error encountered by the reader.
+func (reader *Reader) Err() error {
+	return reader.err
+}
+
+// ReadByte implements io.ByteReader, so the reader can be used with binary.ReadUvarint.
+func (reader *Reader) ReadByte() (byte, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+	b, err := reader.r.ReadByte()
+	if err != nil {
+		reader.fail(err)
+		return 0, err
+	}
+	_, _ = reader.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (reader *Reader) readByte() byte {
+	b, _ := reader.ReadByte()
+	return b
+}
+
+func (reader *Reader) read(b []byte) {
+	if reader.err != nil {
+		return
+	}
+	if _, err := io.ReadFull(reader.r, b); err != nil {
+		reader.fail(err)
+		return
+	}
+	_, _ = reader.crc.Write(b)
+}
+
+func (reader *Reader) ReadUvarint() uint64 {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		reader.fail(err)
+	}
+	return n
+}
+
+func (reader *Reader) ReadVarint() int64 {
+	n, err := binary.ReadVarint(reader)
+	if err != nil {
+		reader.fail(err)
+	}
+	return n
+}
+
+func (reader *Reader) ReadFloat() float64 {
+	var b [8]byte
+	reader.read(b[:])
+	return math.Float64frombits(binary.BigEndian.Uint64(b[:]))
+}
+
+// ReadLength reads the length of a string or a collection, and fails if it exceeds the maximum length.
+func (reader *Reader) ReadLength() int {
+	n := reader.ReadUvarint()
+	if n > maxLength {
+		reader.fail(fmt.Errorf("%w: length %d exceeds the maximum length", ErrFormat, n))
+		return 0
+	}
+	return int(n)
+}
+
+func (reader *Reader) ReadBytes() []byte {
+	n := reader.ReadLength()
+	if reader.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	reader.read(b)
+	return b
+}
+
+func (reader *Reader) ReadString() string {
+	return string(reader.ReadBytes())
+}
+
+// ReadValue reads a value written by Writer.WriteValue, using the codec registered for its tag.
+func (reader *Reader) ReadValue() interface{} {
+	tag := reader.readByte()
+	if reader.err != nil {
+		return nil
+	}
+	c, ok := codecForTag(tag)
+	if !ok {
+		reader.fail(fmt.Errorf("%w: no codec registered for tag %d", ErrFormat, tag))
+		return nil
+	}
+	value, err := c.Decode(reader)
+	if err != nil {
+		reader.fail(fmt.Errorf("%w: %v", ErrFormat, err))
+	}
+	if reader.err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,261 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/codec"
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
+	"github.com/echovault/echovault/internal/modules/set"
+	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
+	"github.com/echovault/echovault/internal/slots"
+	"math"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X, Y int
+}
+
+func Test_Codec(t *testing.T) {
+	s := stream.NewStream()
+	if err := s.Add(stream.ID{Ms: 1, Seq: 1}, []string{"field1", "value1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateGroup("group1", stream.ID{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	hll := hyperloglog.NewHyperLogLog()
+	hll.Add("a", "b", "c")
+
+	expireAt := time.Date(2024, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	object := internal.SnapshotObject{
+		State: map[string]internal.KeyData{
+			"string": {Value: "value", ExpireAt: expireAt, Version: 7},
+			"int":    {Value: 42},
+			"int64":  {Value: int64(math.MinInt64)},
+			"float":  {Value: math.Inf(-1)},
+			"list":   {Value: []interface{}{"a", 1, 2.5}},
+			"hash":   {Value: map[string]interface{}{"field1": "value1", "field2": 2, "field3": 3.5}},
+			"set":    {Value: set.NewSet([]string{"a", "b", "c"})},
+			"zset": {Value: sorted_set.NewSortedSet([]sorted_set.MemberParam{
+				{Value: "a", Score: 1},
+				{Value: "b", Score: sorted_set.Score(math.Inf(1))},
+			})},
+			"stream": {Value: s},
+			"hll":    {Value: hll},
+		},
+		LatestSnapshotMilliseconds: 1704067200000,
+	}
+
+	encode := func(object internal.SnapshotObject) []byte {
+		var buf bytes.Buffer
+		if err := codec.Encode(&buf, object); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	b := encode(object)
+
+	t.Run("Test_RoundTrip", func(t *testing.T) {
+		restored, err := codec.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.LatestSnapshotMilliseconds != object.LatestSnapshotMilliseconds {
+			t.Errorf("expected latest snapshot milliseconds %d, got %d",
+				object.LatestSnapshotMilliseconds, restored.LatestSnapshotMilliseconds)
+		}
+		if len(restored.State) != len(object.State) {
+			t.Errorf("expected %d keys, got %d", len(object.State), len(restored.State))
+		}
+		for key, data := range object.State {
+			got := restored.State[key]
+			if !got.ExpireAt.Equal(data.ExpireAt) || got.ExpireAt.IsZero() != data.ExpireAt.IsZero() {
+				t.Errorf("expected expiry %v for key %s, got %v", data.ExpireAt, key, got.ExpireAt)
+			}
+			if got.Version != data.Version {
+				t.Errorf("expected version %d for key %s, got %d", data.Version, key, got.Version)
+			}
+			if reflect.TypeOf(got.Value) != reflect.TypeOf(data.Value) {
+				t.Errorf("expected type %T for key %s, got %T", data.Value, key, got.Value)
+				continue
+			}
+			switch want := data.Value.(type) {
+			default:
+				if !reflect.DeepEqual(got.Value, want) {
+					t.Errorf("expected value %v for key %s, got %v", want, key, got.Value)
+				}
+			case *set.Set:
+				wantMembers, gotMembers := want.GetAll(), got.Value.(*set.Set).GetAll()
+				slices.Sort(wantMembers)
+				slices.Sort(gotMembers)
+				if !slices.Equal(gotMembers, wantMembers) {
+					t.Errorf("expected members %v for key %s, got %v", wantMembers, key, gotMembers)
+				}
+			case *sorted_set.SortedSet:
+				for _, member := range want.GetAll() {
+					if got := got.Value.(*sorted_set.SortedSet).Get(member.Value); got.Score != member.Score {
+						t.Errorf("expected score %v for member %s, got %v", member.Score, member.Value, got.Score)
+					}
+				}
+			case *stream.Stream:
+				wantJSON, _ := want.MarshalJSON()
+				gotJSON, _ := got.Value.(*stream.Stream).MarshalJSON()
+				if !bytes.Equal(gotJSON, wantJSON) {
+					t.Errorf("expected stream %s, got %s", wantJSON, gotJSON)
+				}
+			case *hyperloglog.HyperLogLog:
+				if got := got.Value.(*hyperloglog.HyperLogLog).Count(); got != want.Count() {
+					t.Errorf("expected count %d for key %s, got %d", want.Count(), key, got)
+				}
+			}
+		}
+	})
+
+	t.Run("Test_Deterministic", func(t *testing.T) {
+		if !bytes.Equal(encode(object), b) {
+			t.Error("expected the same state to be encoded the same way")
+		}
+	})
+
+	t.Run("Test_Clone", func(t *testing.T) {
+		clones := internal.SnapshotObject{
+			State:                      make(map[string]internal.KeyData, len(object.State)),
+			LatestSnapshotMilliseconds: object.LatestSnapshotMilliseconds,
+		}
+		for key, data := range object.State {
+			clone, err := codec.Clone(data.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clones.State[key] = internal.KeyData{Value: clone, ExpireAt: data.ExpireAt, Version: data.Version}
+		}
+		if !bytes.Equal(encode(clones), b) {
+			t.Error("expected the clones to be encoded the same way as the values")
+		}
+
+		// Modifying a clone must not modify the original value.
+		clones.State["set"].Value.(*set.Set).Add([]string{"d"})
+		clones.State["list"].Value.([]interface{})[0] = "z"
+		if object.State["set"].Value.(*set.Set).Contains("d") {
+			t.Error("expected the original set not to contain the member added to the clone")
+		}
+		if object.State["list"].Value.([]interface{})[0] != "a" {
+			t.Error("expected the original list not to be modified with the clone")
+		}
+	})
+
+	t.Run("Test_Slots", func(t *testing.T) {
+		state := slots.NewState([]slots.Range{{Start: 0, End: 5460}})
+		state.Epoch = 3
+		state.Migrating[100] = "shard2"
+		restored, err := codec.Decode(bytes.NewReader(encode(internal.SnapshotObject{
+			State: map[string]internal.KeyData{"string": {Value: "value"}},
+			Slots: &state,
+		})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.Slots == nil || !reflect.DeepEqual(*restored.Slots, state) {
+			t.Errorf("expected slot state %+v, got %+v", state, restored.Slots)
+		}
+		if restored.State["string"].Value != "value" {
+			t.Errorf("expected value %s, got %v", "value", restored.State["string"].Value)
+		}
+
+		value, err := codec.EncodeValue(set.NewSet([]string{"a", "b"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := codec.DecodeValue(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := decoded.(*set.Set); !ok || !s.Contains("a") || !s.Contains("b") {
+			t.Errorf("expected a set with members a and b, got %v", decoded)
+		}
+	})
+
+	t.Run("Test_Corruption", func(t *testing.T) {
+		corrupted := slices.Clone(b)
+		corrupted[len(corrupted)/2] ^= 0xFF
+		if _, err := codec.Decode(bytes.NewReader(corrupted)); !errors.Is(err, codec.ErrFormat) {
+			t.Errorf("expected a format error for a corrupted snapshot, got %v", err)
+		}
+		if _, err := codec.Decode(bytes.NewReader(b[:len(b)-10])); !errors.Is(err, codec.ErrFormat) {
+			t.Errorf("expected a format error for a truncated snapshot, got %v", err)
+		}
+		if _, err := codec.Decode(bytes.NewReader([]byte(`{"State":{}}`))); !errors.Is(err, codec.ErrFormat) {
+			t.Errorf("expected a format error for a JSON snapshot, got %v", err)
+		}
+	})
+
+	t.Run("Test_Register", func(t *testing.T) {
+		err := codec.Register(codec.Codec{Tag: codec.TagString, Type: reflect.TypeOf(point{})})
+		if err == nil {
+			t.Error("expected an error when registering a reserved tag")
+		}
+
+		// Without a codec, the value is JSON encoded and loses its type.
+		object := internal.SnapshotObject{
+			State: map[string]internal.KeyData{"point": {Value: struct{ X, Y int }{X: 1, Y: 2}}},
+		}
+		restored, err := codec.Decode(bytes.NewReader(encode(object)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{"X": float64(1), "Y": float64(2)}
+		if !reflect.DeepEqual(restored.State["point"].Value, want) {
+			t.Errorf("expected value %v, got %v", want, restored.State["point"].Value)
+		}
+
+		if err = registerPoint(); err != nil {
+			t.Fatal(err)
+		}
+		object = internal.SnapshotObject{State: map[string]internal.KeyData{"point": {Value: point{X: 1, Y: 2}}}}
+		restored, err = codec.Decode(bytes.NewReader(encode(object)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.State["point"].Value != (point{X: 1, Y: 2}) {
+			t.Errorf("expected value %v, got %v", point{X: 1, Y: 2}, restored.State["point"].Value)
+		}
+	})
+}
+
+// registerPoint registers the codec of the point type. The registry is global, so it's only registered once
+// when the tests are run multiple times.
+var registerPoint = sync.OnceValue(func() error {
+	return codec.Register(codec.Codec{
+		Tag:  64,
+		Type: reflect.TypeOf(point{}),
+		Encode: func(w *codec.Writer, value interface{}) error {
+			w.WriteVarint(int64(value.(point).X))
+			w.WriteVarint(int64(value.(point).Y))
+			return nil
+		},
+		Decode: func(r *codec.Reader) (interface{}, error) {
+			return point{X: int(r.ReadVarint()), Y: int(r.ReadVarint())}, nil
+		},
+	})
+})
@@ -0,0 +1,325 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
+	"github.com/echovault/echovault/internal/modules/set"
+	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// The tags of the built-in codecs. Tags below 64 are reserved for the built-in codecs.
+const (
+	TagJSON        byte = 0
+	TagString      byte = 1
+	TagInt         byte = 2
+	TagInt64       byte = 3
+	TagFloat       byte = 4
+	TagList        byte = 5
+	TagHash        byte = 6
+	TagSet         byte = 7
+	TagSortedSet   byte = 8
+	TagStream      byte = 9
+	TagHyperLogLog byte = 10
+)
+
+// Codec encodes and decodes the values of one Go type.
+type Codec struct {
+	Tag    byte         // Identifies the codec in the snapshot. It must not change once snapshots are written with it.
+	Type   reflect.Type // The type of the values encoded by the codec.
+	Encode func(w *Writer, value interface{}) error
+	Decode func(r *Reader) (interface{}, error)
+}
+
+var registry = struct {
+	mutex  sync.RWMutex
+	byTag  map[byte]Codec
+	byType map[reflect.Type]Codec
+}{
+	byTag:  make(map[byte]Codec),
+	byType: make(map[reflect.Type]Codec),
+}
+
+// Register adds a codec for the values of a type that is not supported by the built-in codecs,
+// e.g. a type stored by a custom module. Values of types without a codec are JSON encoded,
+// which does not preserve their type.
+func Register(c Codec) error {
+	if c.Tag < 64 {
+		return fmt.Errorf("tag %d is reserved for the built-in codecs", c.Tag)
+	}
+	registerBuiltins()
+	return register(c)
+}
+
+func register(c Codec) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, ok := registry.byTag[c.Tag]; ok {
+		return fmt.Errorf("a codec is already registered for tag %d", c.Tag)
+	}
+	if _, ok := registry.byType[c.Type]; ok {
+		return fmt.Errorf("a codec is already registered for type %s", c.Type)
+	}
+	registry.byTag[c.Tag] = c
+	registry.byType[c.Type] = c
+	return nil
+}
+
+var builtinsOnce sync.Once
+
+// registerBuiltins registers the built-in codecs the first time a codec is looked up.
+func registerBuiltins() {
+	builtinsOnce.Do(func() {
+		for _, c := range builtinCodecs() {
+			if err := register(c); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+func codecForValue(value interface{}) Codec {
+	registerBuiltins()
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	if c, ok := registry.byType[reflect.TypeOf(value)]; ok {
+		return c
+	}
+	return jsonCodec
+}
+
+func codecForTag(tag byte) (Codec, bool) {
+	registerBuiltins()
+	if tag == TagJSON {
+		return jsonCodec, true
+	}
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	c, ok := registry.byTag[tag]
+	return c, ok
+}
+
+// jsonCodec encodes the values of types without a codec. They're decoded as generic JSON values.
+var jsonCodec = Codec{
+	Tag: TagJSON,
+	Encode: func(w *Writer, value interface{}) error {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		w.WriteBytes(b)
+		return nil
+	},
+	Decode: func(r *Reader) (interface{}, error) {
+		b := r.ReadBytes()
+		if r.Err() != nil {
+			return nil, r.Err()
+		}
+		var value interface{}
+		err := json.Unmarshal(b, &value)
+		return value, err
+	},
+}
+
+func builtinCodecs() []Codec {
+	return []Codec{
+		{
+			Tag:  TagString,
+			Type: reflect.TypeOf(""),
+			Encode: func(w *Writer, value interface{}) error {
+				w.WriteString(value.(string))
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				return r.ReadString(), nil
+			},
+		},
+		{
+			Tag:  TagInt,
+			Type: reflect.TypeOf(0),
+			Encode: func(w *Writer, value interface{}) error {
+				w.WriteVarint(int64(value.(int)))
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				return int(r.ReadVarint()), nil
+			},
+		},
+		{
+			Tag:  TagInt64,
+			Type: reflect.TypeOf(int64(0)),
+			Encode: func(w *Writer, value interface{}) error {
+				w.WriteVarint(value.(int64))
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				return r.ReadVarint(), nil
+			},
+		},
+		{
+			Tag:  TagFloat,
+			Type: reflect.TypeOf(float64(0)),
+			Encode: func(w *Writer, value interface{}) error {
+				w.WriteFloat(value.(float64))
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				return r.ReadFloat(), nil
+			},
+		},
+		{
+			Tag:  TagList,
+			Type: reflect.TypeOf([]interface{}{}),
+			Encode: func(w *Writer, value interface{}) error {
+				list := value.([]interface{})
+				w.WriteUvarint(uint64(len(list)))
+				for _, element := range list {
+					if err := w.WriteValue(element); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				list := make([]interface{}, r.ReadLength())
+				for i := 0; i < len(list) && r.Err() == nil; i++ {
+					list[i] = r.ReadValue()
+				}
+				return list, nil
+			},
+		},
+		{
+			Tag:  TagHash,
+			Type: reflect.TypeOf(map[string]interface{}{}),
+			Encode: func(w *Writer, value interface{}) error {
+				hash := value.(map[string]interface{})
+				fields := make([]string, 0, len(hash))
+				for field := range hash {
+					fields = append(fields, field)
+				}
+				slices.Sort(fields)
+				w.WriteUvarint(uint64(len(fields)))
+				for _, field := range fields {
+					w.WriteString(field)
+					if err := w.WriteValue(hash[field]); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				n := r.ReadLength()
+				hash := make(map[string]interface{}, n)
+				for i := 0; i < n && r.Err() == nil; i++ {
+					field := r.ReadString()
+					hash[field] = r.ReadValue()
+				}
+				return hash, nil
+			},
+		},
+		{
+			Tag:  TagSet,
+			Type: reflect.TypeOf(&set.Set{}),
+			Encode: func(w *Writer, value interface{}) error {
+				members := value.(*set.Set).GetAll()
+				slices.Sort(members)
+				w.WriteUvarint(uint64(len(members)))
+				for _, member := range members {
+					w.WriteString(member)
+				}
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				members := make([]string, r.ReadLength())
+				for i := 0; i < len(members) && r.Err() == nil; i++ {
+					members[i] = r.ReadString()
+				}
+				return set.NewSet(members), nil
+			},
+		},
+		{
+			Tag:  TagSortedSet,
+			Type: reflect.TypeOf(&sorted_set.SortedSet{}),
+			Encode: func(w *Writer, value interface{}) error {
+				members := value.(*sorted_set.SortedSet).GetAll()
+				slices.SortFunc(members, func(a, b sorted_set.MemberParam) int {
+					return cmp.Compare(a.Value, b.Value)
+				})
+				w.WriteUvarint(uint64(len(members)))
+				for _, member := range members {
+					w.WriteString(string(member.Value))
+					w.WriteFloat(float64(member.Score))
+				}
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				members := make([]sorted_set.MemberParam, r.ReadLength())
+				for i := 0; i < len(members) && r.Err() == nil; i++ {
+					members[i].Value = sorted_set.Value(r.ReadString())
+					members[i].Score = sorted_set.Score(r.ReadFloat())
+				}
+				return sorted_set.NewSortedSet(members), nil
+			},
+		},
+		{
+			// Streams are encoded with their JSON representation, which includes the consumer groups.
+			Tag:  TagStream,
+			Type: reflect.TypeOf(&stream.Stream{}),
+			Encode: func(w *Writer, value interface{}) error {
+				b, err := value.(*stream.Stream).MarshalJSON()
+				if err != nil {
+					return err
+				}
+				w.WriteBytes(b)
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				b := r.ReadBytes()
+				if r.Err() != nil {
+					return nil, r.Err()
+				}
+				s := stream.NewStream()
+				return s, s.UnmarshalJSON(b)
+			},
+		},
+		{
+			Tag:  TagHyperLogLog,
+			Type: reflect.TypeOf(&hyperloglog.HyperLogLog{}),
+			Encode: func(w *Writer, value interface{}) error {
+				b, err := value.(*hyperloglog.HyperLogLog).MarshalJSON()
+				if err != nil {
+					return err
+				}
+				w.WriteBytes(b)
+				return nil
+			},
+			Decode: func(r *Reader) (interface{}, error) {
+				b := r.ReadBytes()
+				if r.Err() != nil {
+					return nil, r.Err()
+				}
+				hll := hyperloglog.NewHyperLogLog()
+				return hll, hll.UnmarshalJSON(b)
+			},
+		},
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/slots"
 	"log"
 	"os"
 	"path"
@@ -32,33 +33,44 @@ import (
 )
 
 type Config struct {
-	TLS               bool          `json:"TLS" yaml:"TLS"`
-	MTLS              bool          `json:"MTLS" yaml:"MTLS"`
-	CertKeyPairs      [][]string    `json:"CertKeyPairs" yaml:"CertKeyPairs"`
-	ClientCAs         []string      `json:"ClientCAs" yaml:"ClientCAs"`
-	Port              uint16        `json:"Port" yaml:"Port"`
-	ServerID          string        `json:"ServerId" yaml:"ServerId"`
-	JoinAddr          string        `json:"JoinAddr" yaml:"JoinAddr"`
-	BindAddr          string        `json:"BindAddr" yaml:"BindAddr"`
-	DataDir           string        `json:"DataDir" yaml:"DataDir"`
-	BootstrapCluster  bool          `json:"BootstrapCluster" yaml:"BootstrapCluster"`
-	AclConfig         string        `json:"AclConfig" yaml:"AclConfig"`
-	ForwardCommand    bool          `json:"ForwardCommand" yaml:"ForwardCommand"`
-	RequirePass       bool          `json:"RequirePass" yaml:"RequirePass"`
-	Password          string        `json:"Password" yaml:"Password"`
-	SnapShotThreshold uint64        `json:"SnapshotThreshold" yaml:"SnapshotThreshold"`
-	SnapshotInterval  time.Duration `json:"SnapshotInterval" yaml:"SnapshotInterval"`
-	RestoreSnapshot   bool          `json:"RestoreSnapshot" yaml:"RestoreSnapshot"`
-	RestoreAOF        bool          `json:"RestoreAOF" yaml:"RestoreAOF"`
-	AOFSyncStrategy   string        `json:"AOFSyncStrategy" yaml:"AOFSyncStrategy"`
-	MaxMemory         uint64        `json:"MaxMemory" yaml:"MaxMemory"`
-	EvictionPolicy    string        `json:"EvictionPolicy" yaml:"EvictionPolicy"`
-	EvictionSample    uint          `json:"EvictionSample" yaml:"EvictionSample"`
-	EvictionInterval  time.Duration `json:"EvictionInterval" yaml:"EvictionInterval"`
-	Modules           []string      `json:"Plugins" yaml:"Plugins"`
-	DiscoveryPort     uint16        `json:"DiscoveryPort" yaml:"DiscoveryPort"`
-	RaftBindAddr      string
-	RaftBindPort      uint16
+	TLS                  bool          `json:"TLS" yaml:"TLS"`
+	MTLS                 bool          `json:"MTLS" yaml:"MTLS"`
+	CertKeyPairs         [][]string    `json:"CertKeyPairs" yaml:"CertKeyPairs"`
+	ClientCAs            []string      `json:"ClientCAs" yaml:"ClientCAs"`
+	MTLSUsers            []string      `json:"MTLSUsers" yaml:"MTLSUsers"`
+	Port                 uint16        `json:"Port" yaml:"Port"`
+	ServerID             string        `json:"ServerId" yaml:"ServerId"`
+	JoinAddr             string        `json:"JoinAddr" yaml:"JoinAddr"`
+	BindAddr             string        `json:"BindAddr" yaml:"BindAddr"`
+	DataDir              string        `json:"DataDir" yaml:"DataDir"`
+	BootstrapCluster     bool          `json:"BootstrapCluster" yaml:"BootstrapCluster"`
+	ShardID              string        `json:"ShardId" yaml:"ShardId"`
+	RaftLearner          bool          `json:"RaftLearner" yaml:"RaftLearner"`
+	ClusterSlots         string        `json:"ClusterSlots" yaml:"ClusterSlots"`
+	AclConfig            string        `json:"AclConfig" yaml:"AclConfig"`
+	AclPasswordHash      string        `json:"AclPasswordHash" yaml:"AclPasswordHash"`
+	ForwardCommand       bool          `json:"ForwardCommand" yaml:"ForwardCommand"`
+	ClusterSecret        string        `json:"ClusterSecret" yaml:"ClusterSecret"`
+	RequirePass          bool          `json:"RequirePass" yaml:"RequirePass"`
+	Password             string        `json:"Password" yaml:"Password"`
+	SnapShotThreshold    uint64        `json:"SnapshotThreshold" yaml:"SnapshotThreshold"`
+	SnapshotInterval     time.Duration `json:"SnapshotInterval" yaml:"SnapshotInterval"`
+	RestoreSnapshot      bool          `json:"RestoreSnapshot" yaml:"RestoreSnapshot"`
+	RestoreAOF           bool          `json:"RestoreAOF" yaml:"RestoreAOF"`
+	AOFSyncStrategy      string        `json:"AOFSyncStrategy" yaml:"AOFSyncStrategy"`
+	MaxMemory            uint64        `json:"MaxMemory" yaml:"MaxMemory"`
+	EvictionPolicy       string        `json:"EvictionPolicy" yaml:"EvictionPolicy"`
+	EvictionSample       uint          `json:"EvictionSample" yaml:"EvictionSample"`
+	EvictionInterval     time.Duration `json:"EvictionInterval" yaml:"EvictionInterval"`
+	NotifyKeyspaceEvents string        `json:"NotifyKeyspaceEvents" yaml:"NotifyKeyspaceEvents"`
+	ReadConsistency      string        `json:"ReadConsistency" yaml:"ReadConsistency"`
+	MaxReadLag           time.Duration `json:"MaxReadLag" yaml:"MaxReadLag"`
+	ScriptTimeLimit      time.Duration `json:"ScriptTimeLimit" yaml:"ScriptTimeLimit"`
+	Modules              []string      `json:"Plugins" yaml:"Plugins"`
+	DiscoveryPort        uint16        `json:"DiscoveryPort" yaml:"DiscoveryPort"`
+	RaftBindAddr         string
+	RaftBindPort         uint16
+	ForwardBindPort      uint16 // The port that followers forward write commands to when this node is the leader.
 }
 
 func GetConfig() (Config, error) {
@@ -84,6 +96,20 @@ func GetConfig() (Config, error) {
 		return nil
 	})
 
+	var mtlsUsers []string
+	flag.Func("mtls-user",
+		`Map verified mTLS client certificates to an ACL user, in the format <field>:<value>=<username>.
+The field is cn for the subject common name, san for a subject alternative name, or fingerprint for the
+SHA256 fingerprint of the certificate. Connections with a matching certificate are authenticated as the user
+without AUTH. When several mappings match, the first one is used.`,
+		func(s string) error {
+			if _, err := internal.ParseCertUserMapping(s); err != nil {
+				return err
+			}
+			mtlsUsers = append(mtlsUsers, s)
+			return nil
+		})
+
 	aofSyncStrategy := "everysec"
 	flag.Func("aof-sync-strategy", `How often to flush the file contents written to append only file.
 The options are 'always' for syncing on each command, 'everysec' to sync every second, and 'no' to leave it up to the os.`,
@@ -132,6 +158,63 @@ There is no limit by default.`, func(memory string) error {
 			return nil
 		})
 
+	aclPasswordHash := "bcrypt"
+	flag.Func("acl-password-hash",
+		`The salted hash used to store ACL passwords. The options are bcrypt and argon2id. Default is bcrypt.
+Plaintext and SHA256 passwords are replaced with this hash when they're used to authenticate
+and when the ACL config is saved.`, func(hash string) error {
+			if !slices.Contains([]string{"bcrypt", "argon2id"}, strings.ToLower(hash)) {
+				return fmt.Errorf("password hash %s is not a valid password hash", hash)
+			}
+			aclPasswordHash = strings.ToLower(hash)
+			return nil
+		})
+
+	notifyKeyspaceEvents := ""
+	flag.Func("notify-keyspace-events",
+		`The keyspace notifications to publish, as a string of flags. Notifications are disabled by default.
+K and E publish the events on the __keyspace@0__:<key> and __keyevent@0__:<event> channels respectively.
+At least one of them must be set along with the classes of events to publish:
+g - generic commands, $ - string commands, l - list commands, s - set commands, h - hash commands,
+z - sorted set commands, t - stream commands, x - expired keys, e - evicted keys, n - new keys,
+A - an alias for "g$lshztxe".`, func(flags string) error {
+			if _, err := internal.ParseKeyspaceEvents(flags); err != nil {
+				return err
+			}
+			notifyKeyspaceEvents = flags
+			return nil
+		})
+
+	readConsistency := constants.ReadConsistencyStale
+	flag.Func("read-consistency",
+		`The default consistency of read commands in cluster mode. Connections can change it with READCONSISTENCY.
+The options are:
+1) leader - The leader confirms it's still the leader with a quorum of the cluster before serving the read.
+2) lease - The leader serves the read without contacting the cluster while its leadership lease is valid.
+3) stale - Any node serves the read from its local store. This is the default.
+Reads that can't meet the consistency are rejected.`, func(mode string) error {
+			if !slices.Contains([]string{
+				constants.ReadConsistencyLeader, constants.ReadConsistencyLease, constants.ReadConsistencyStale,
+			}, strings.ToLower(mode)) {
+				return fmt.Errorf("read consistency %s is not a valid read consistency", mode)
+			}
+			readConsistency = strings.ToLower(mode)
+			return nil
+		})
+
+	clusterSlots := ""
+	flag.Func("cluster-slots",
+		`The hash slots owned by the shard of this node when the cluster is created, as comma separated slots or
+ranges of slots (e.g. 0-5460,6000). All the nodes of a shard must be started with the same slots.
+Each shard of the cluster must own a different set of slots. By default, the shard owns all the 16384 slots.`,
+		func(s string) error {
+			if _, err := slots.ParseRanges(s); err != nil {
+				return err
+			}
+			clusterSlots = s
+			return nil
+		})
+
 	var modules []string
 	flag.Func(
 		"loadmodule",
@@ -153,17 +236,35 @@ There is no limit by default.`, func(memory string) error {
 	discoveryPort := flag.Uint("discovery-port", 7946, "Port to use for memberlist cluster discovery.")
 	dataDir := flag.String("data-dir", ".", "Directory to store snapshots and logs.")
 	bootstrapCluster := flag.Bool("bootstrap-cluster", false, "Whether this instance should bootstrap a new cluster.")
+	shardId := flag.String("shard-id", "", `The ID of the shard the node belongs to in a sharded cluster. Each shard is a separate
+raft group that owns a set of hash slots. The nodes of a shard only join the raft group of the same shard.`)
+	raftLearner := flag.Bool("raft-learner", false, `Whether this instance joins the raft cluster as a non-voter (learner).
+Learners replicate the data and serve reads without growing the quorum. They're never elected leader,
+and can be promoted to voters with CLUSTER PROMOTE.`)
 	aclConfig := flag.String("acl-config", "", "ACL config file path.")
 	snapshotThreshold := flag.Uint64("snapshot-threshold", 1000, "The number of entries that trigger a snapshot. Default is 1000.")
 	snapshotInterval := flag.Duration("snapshot-interval", 5*time.Minute, "The time interval between snapshots (in seconds). Default is 5 minutes.")
 	restoreSnapshot := flag.Bool("restore-snapshot", false, "This flag prompts the echovault to restore state from snapshot when set to true. Only works in standalone mode. Higher priority than restoreAOF.")
 	restoreAOF := flag.Bool("restore-aof", false, "This flag prompts the echovault to restore state from append-only logs. Only works in standalone mode. Lower priority than restoreSnapshot.")
-	evictionSample := flag.Uint("eviction-sample", 20, "An integer specifying the number of keys to sample when checking for expired keys.")
-	evictionInterval := flag.Duration("eviction-interval", 100*time.Millisecond, "The interval between each sampling of keys to evict.")
+	evictionSample := flag.Uint("eviction-sample", 20, "The maximum number of expired keys to delete from a shard at a time during the active expiry cycle.")
+	evictionInterval := flag.Duration("eviction-interval", 100*time.Millisecond, "The interval between each active expiry cycle. Each cycle runs for at most a quarter of the interval.")
+	maxReadLag := flag.Duration("max-read-lag", 0, `The default max time since a follower last heard from the leader
+for stale reads. Stale reads on followers that exceed it are rejected. 0 means there's no limit, which is the default.`)
+	scriptTimeLimit := flag.Duration("script-time-limit", 5*time.Second, `How long a script can run before the other commands
+are rejected with a BUSY error. The script can then be stopped with SCRIPT KILL if it has not written to the store yet.
+0 means there's no limit. Default is 5 seconds.`)
 	forwardCommand := flag.Bool(
 		"forward-commands",
 		false,
-		"If the node is a follower, this flag forwards mutation command to the leader when set to true")
+		`If the node is a follower, this flag forwards mutation commands to the leader when set to true.
+The follower waits for the leader to apply the command and returns the leader's response.`)
+	clusterSecret := flag.String(
+		"cluster-secret",
+		"",
+		`The secret shared by the nodes of the cluster. Nodes prove that they know it before they forward commands
+to each other. It's required when forward-commands is set or shard-id is set. Without it, the forward channel
+is disabled: followers can't leave the cluster with CLUSTER LEAVE, and CLUSTER NODES only reports the raft stats
+of the node that serves it. To enable the channel in an existing cluster, restart each node with the same secret.`)
 	requirePass := flag.Bool(
 		"require-pass",
 		false,
@@ -192,35 +293,50 @@ It is a plain text value by default but you can provide a SHA256 hash by adding
 	if e != nil {
 		return Config{}, e
 	}
+	forwardBindPort, e := internal.GetFreePort()
+	if e != nil {
+		return Config{}, e
+	}
 
 	conf := Config{
-		CertKeyPairs:      certKeyPairs,
-		ClientCAs:         clientCAs,
-		TLS:               *tls,
-		MTLS:              *mtls,
-		Port:              uint16(*port),
-		ServerID:          *serverId,
-		JoinAddr:          *joinAddr,
-		BindAddr:          *bindAddr,
-		DataDir:           *dataDir,
-		BootstrapCluster:  *bootstrapCluster,
-		AclConfig:         *aclConfig,
-		ForwardCommand:    *forwardCommand,
-		RequirePass:       *requirePass,
-		Password:          *password,
-		SnapShotThreshold: *snapshotThreshold,
-		SnapshotInterval:  *snapshotInterval,
-		RestoreSnapshot:   *restoreSnapshot,
-		RestoreAOF:        *restoreAOF,
-		AOFSyncStrategy:   aofSyncStrategy,
-		MaxMemory:         maxMemory,
-		EvictionPolicy:    evictionPolicy,
-		EvictionSample:    *evictionSample,
-		EvictionInterval:  *evictionInterval,
-		Modules:           modules,
-		DiscoveryPort:     uint16(*discoveryPort),
-		RaftBindAddr:      raftBindAddr,
-		RaftBindPort:      uint16(raftBindPort),
+		CertKeyPairs:         certKeyPairs,
+		ClientCAs:            clientCAs,
+		MTLSUsers:            mtlsUsers,
+		TLS:                  *tls,
+		MTLS:                 *mtls,
+		Port:                 uint16(*port),
+		ServerID:             *serverId,
+		JoinAddr:             *joinAddr,
+		BindAddr:             *bindAddr,
+		DataDir:              *dataDir,
+		BootstrapCluster:     *bootstrapCluster,
+		ShardID:              *shardId,
+		RaftLearner:          *raftLearner,
+		ClusterSlots:         clusterSlots,
+		AclConfig:            *aclConfig,
+		AclPasswordHash:      aclPasswordHash,
+		ForwardCommand:       *forwardCommand,
+		ClusterSecret:        *clusterSecret,
+		RequirePass:          *requirePass,
+		Password:             *password,
+		SnapShotThreshold:    *snapshotThreshold,
+		SnapshotInterval:     *snapshotInterval,
+		RestoreSnapshot:      *restoreSnapshot,
+		RestoreAOF:           *restoreAOF,
+		AOFSyncStrategy:      aofSyncStrategy,
+		MaxMemory:            maxMemory,
+		EvictionPolicy:       evictionPolicy,
+		EvictionSample:       *evictionSample,
+		EvictionInterval:     *evictionInterval,
+		NotifyKeyspaceEvents: notifyKeyspaceEvents,
+		ReadConsistency:      readConsistency,
+		MaxReadLag:           *maxReadLag,
+		ScriptTimeLimit:      *scriptTimeLimit,
+		Modules:              modules,
+		DiscoveryPort:        uint16(*discoveryPort),
+		RaftBindAddr:         raftBindAddr,
+		RaftBindPort:         uint16(raftBindPort),
+		ForwardBindPort:      uint16(forwardBindPort),
 	}
 
 	if len(*config) > 0 {
@@ -9,34 +9,46 @@ import (
 func DefaultConfig() Config {
 	raftBindAddr, _ := internal.GetIPAddress()
 	raftBindPort, _ := internal.GetFreePort()
+	forwardBindPort, _ := internal.GetFreePort()
 
 	return Config{
-		TLS:               false,
-		MTLS:              false,
-		CertKeyPairs:      make([][]string, 0),
-		ClientCAs:         make([]string, 0),
-		Port:              7480,
-		ServerID:          "",
-		JoinAddr:          "",
-		BindAddr:          "localhost",
-		RaftBindAddr:      raftBindAddr,
-		RaftBindPort:      uint16(raftBindPort),
-		DiscoveryPort:     7946,
-		DataDir:           ".",
-		BootstrapCluster:  false,
-		AclConfig:         "",
-		ForwardCommand:    false,
-		RequirePass:       false,
-		Password:          "",
-		SnapShotThreshold: 1000,
-		SnapshotInterval:  5 * time.Minute,
-		RestoreAOF:        false,
-		RestoreSnapshot:   false,
-		AOFSyncStrategy:   "everysec",
-		MaxMemory:         0,
-		EvictionPolicy:    constants.NoEviction,
-		EvictionSample:    20,
-		EvictionInterval:  100 * time.Millisecond,
-		Modules:           make([]string, 0),
+		TLS:                  false,
+		MTLS:                 false,
+		CertKeyPairs:         make([][]string, 0),
+		ClientCAs:            make([]string, 0),
+		MTLSUsers:            make([]string, 0),
+		Port:                 7480,
+		ServerID:             "",
+		JoinAddr:             "",
+		BindAddr:             "localhost",
+		RaftBindAddr:         raftBindAddr,
+		RaftBindPort:         uint16(raftBindPort),
+		ForwardBindPort:      uint16(forwardBindPort),
+		DiscoveryPort:        7946,
+		DataDir:              ".",
+		BootstrapCluster:     false,
+		ShardID:              "",
+		RaftLearner:          false,
+		ClusterSlots:         "",
+		AclConfig:            "",
+		AclPasswordHash:      "bcrypt",
+		ForwardCommand:       false,
+		ClusterSecret:        "",
+		RequirePass:          false,
+		Password:             "",
+		SnapShotThreshold:    1000,
+		SnapshotInterval:     5 * time.Minute,
+		RestoreAOF:           false,
+		RestoreSnapshot:      false,
+		AOFSyncStrategy:      "everysec",
+		MaxMemory:            0,
+		EvictionPolicy:       constants.NoEviction,
+		EvictionSample:       20,
+		EvictionInterval:     100 * time.Millisecond,
+		NotifyKeyspaceEvents: "",
+		ReadConsistency:      constants.ReadConsistencyStale,
+		MaxReadLag:           0,
+		ScriptTimeLimit:      5 * time.Second,
+		Modules:              make([]string, 0),
 	}
 }
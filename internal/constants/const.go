@@ -15,16 +15,21 @@
 package constants
 
 const (
-	ACLModule        = "acl"
-	AdminModule      = "admin"
-	ConnectionModule = "connection"
-	GenericModule    = "generic"
-	HashModule       = "hash"
-	ListModule       = "list"
-	PubSubModule     = "pubsub"
-	SetModule        = "set"
-	SortedSetModule  = "sortedset"
-	StringModule     = "string"
+	ACLModule         = "acl"
+	AdminModule       = "admin"
+	ClusterModule     = "cluster"
+	ConnectionModule  = "connection"
+	GenericModule     = "generic"
+	HashModule        = "hash"
+	HyperLogLogModule = "hyperloglog"
+	ListModule        = "list"
+	PubSubModule      = "pubsub"
+	ScriptingModule   = "scripting"
+	SetModule         = "set"
+	SortedSetModule   = "sortedset"
+	StreamModule      = "stream"
+	StringModule      = "string"
+	TransactionModule = "transaction"
 )
 
 const (
@@ -54,6 +59,22 @@ const (
 const (
 	OkResponse        = "+OK\r\n"
 	WrongArgsResponse = "wrong number of arguments"
+	NullResponse      = "_\r\n" // RESP3 null. Only sent to connections that have negotiated RESP3.
+)
+
+const (
+	RESP2Protocol = 2
+	RESP3Protocol = 3
+)
+
+// Version is the EchoVault version reported to clients by the HELLO command.
+const Version = "0.6.0"
+
+// The read consistency modes of read commands in cluster mode.
+const (
+	ReadConsistencyLeader = "leader" // The leader confirms its leadership with a quorum before serving the read.
+	ReadConsistencyLease  = "lease"  // The leader serves the read while its leadership lease is valid.
+	ReadConsistencyStale  = "stale"  // Any node serves the read from its local store, within the max lag if one is set.
 )
 
 const (
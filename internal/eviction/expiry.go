@@ -0,0 +1,128 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eviction
+
+import (
+	"container/heap"
+	"math/rand"
+	"time"
+)
+
+type EntryExpiry struct {
+	key      string    // The key, matching the key in the store
+	expireAt time.Time // The time the key expires
+	index    int       // The index of the entry in the heap
+}
+
+// ExpiryHeap indexes the keys with an expiry time. It's a min heap ordered by expiry time,
+// so the key that expires first is always at the top.
+type ExpiryHeap struct {
+	keys    map[string]*EntryExpiry
+	entries []*EntryExpiry
+}
+
+func NewExpiryHeap() ExpiryHeap {
+	h := ExpiryHeap{
+		keys:    make(map[string]*EntryExpiry),
+		entries: make([]*EntryExpiry, 0),
+	}
+	heap.Init(&h)
+	return h
+}
+
+func (h *ExpiryHeap) Len() int {
+	return len(h.entries)
+}
+
+func (h *ExpiryHeap) Less(i, j int) bool {
+	return h.entries[i].expireAt.Before(h.entries[j].expireAt)
+}
+
+func (h *ExpiryHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *ExpiryHeap) Push(entry any) {
+	e := entry.(*EntryExpiry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+	h.keys[e.key] = e
+}
+
+func (h *ExpiryHeap) Pop() any {
+	old := h.entries
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	h.entries = old[0 : n-1]
+	delete(h.keys, entry.key)
+	return entry.key
+}
+
+// Update sets the expiry time of the key. A zero expiry time removes the key from the heap.
+func (h *ExpiryHeap) Update(key string, expireAt time.Time) {
+	if expireAt.IsZero() {
+		h.Delete(key)
+		return
+	}
+	entry, ok := h.keys[key]
+	if !ok {
+		heap.Push(h, &EntryExpiry{key: key, expireAt: expireAt})
+		return
+	}
+	entry.expireAt = expireAt
+	heap.Fix(h, entry.index)
+}
+
+func (h *ExpiryHeap) Delete(key string) {
+	if entry, ok := h.keys[key]; ok {
+		heap.Remove(h, entry.index)
+	}
+}
+
+// Contains returns whether the key has an expiry time.
+func (h *ExpiryHeap) Contains(key string) bool {
+	_, ok := h.keys[key]
+	return ok
+}
+
+// Next returns the key that expires first and its expiry time. It returns false if the heap is empty.
+func (h *ExpiryHeap) Next() (string, time.Time, bool) {
+	if len(h.entries) == 0 {
+		return "", time.Time{}, false
+	}
+	return h.entries[0].key, h.entries[0].expireAt, true
+}
+
+// PopExpired removes up to limit keys that expired before now from the heap, and returns them in the order
+// they expired.
+func (h *ExpiryHeap) PopExpired(now time.Time, limit int) []string {
+	var keys []string
+	for len(keys) < limit && len(h.entries) > 0 && h.entries[0].expireAt.Before(now) {
+		keys = append(keys, heap.Pop(h).(string))
+	}
+	return keys
+}
+
+// Random returns a random key from the heap. It returns false if the heap is empty.
+func (h *ExpiryHeap) Random() (string, bool) {
+	if len(h.entries) == 0 {
+		return "", false
+	}
+	return h.entries[rand.Intn(len(h.entries))].key, true
+}
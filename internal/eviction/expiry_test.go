@@ -0,0 +1,63 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eviction_test
+
+import (
+	"github.com/echovault/echovault/internal/eviction"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_ExpiryHeap(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	h := eviction.NewExpiryHeap()
+	h.Update("key1", now.Add(3*time.Second))
+	h.Update("key2", now.Add(-1*time.Second))
+	h.Update("key3", now.Add(-3*time.Second))
+	h.Update("key4", now.Add(-2*time.Second))
+	h.Update("key5", now.Add(1*time.Second))
+
+	// Updating the expiry time moves the key, and a zero expiry time removes it.
+	h.Update("key1", now.Add(-4*time.Second))
+	h.Update("key5", time.Time{})
+	h.Delete("key6")
+	if h.Len() != 4 {
+		t.Errorf("expected heap length to be 4, got %d", h.Len())
+	}
+	if h.Contains("key5") {
+		t.Error("expected key5 to have been removed")
+	}
+
+	if key, expireAt, ok := h.Next(); !ok || key != "key1" || !expireAt.Equal(now.Add(-4*time.Second)) {
+		t.Errorf("expected the next key to be key1 expiring at %v, got %s expiring at %v", now.Add(-4*time.Second), key, expireAt)
+	}
+
+	if keys := h.PopExpired(now, 2); !slices.Equal(keys, []string{"key1", "key3"}) {
+		t.Errorf("expected keys [key1 key3], got %v", keys)
+	}
+	h.Update("key7", now.Add(time.Second))
+	if keys := h.PopExpired(now, 10); !slices.Equal(keys, []string{"key4", "key2"}) {
+		t.Errorf("expected keys [key4 key2], got %v", keys)
+	}
+	if keys := h.PopExpired(now, 10); len(keys) != 0 {
+		t.Errorf("expected no expired keys, got %v", keys)
+	}
+
+	if key, ok := h.Random(); !ok || key != "key7" {
+		t.Errorf("expected the random key to be key7, got %s", key)
+	}
+}
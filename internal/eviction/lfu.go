@@ -16,26 +16,51 @@ package eviction
 
 import (
 	"container/heap"
-	"slices"
+	"github.com/echovault/echovault/internal/clock"
 	"time"
 )
 
+// The default period after which the access count of an idle key is decremented by one.
+// It's the same as the default lfu-decay-time in Redis.
+const defaultDecayTime = time.Minute
+
 type EntryLFU struct {
-	key       string // The key, matching the key in the store
-	count     int    // The number of times this key has been accessed
-	addedTime int64  // The time this entry was added to the cache in unix milliseconds
-	index     int    // The index of the entry in the heap
+	key        string    // The key, matching the key in the store
+	count      int       // The number of times this key has been accessed, decayed up to accessTime
+	accessTime time.Time // The time this key was last accessed
+	index      int       // The index of the entry in the heap
 }
 
 type CacheLFU struct {
-	keys    map[string]bool
-	entries []*EntryLFU
+	clock     clock.Clock
+	decayTime time.Duration
+	keys      map[string]*EntryLFU
+	entries   []*EntryLFU
+}
+
+func WithClock(clock clock.Clock) func(cache *CacheLFU) {
+	return func(cache *CacheLFU) {
+		cache.clock = clock
+	}
 }
 
-func NewCacheLFU() CacheLFU {
+// WithDecayTime sets the period after which the access count of an idle key is decremented by one,
+// so that keys that were accessed often a long time ago can still be evicted. A decay time of 0 disables decay.
+func WithDecayTime(decayTime time.Duration) func(cache *CacheLFU) {
+	return func(cache *CacheLFU) {
+		cache.decayTime = decayTime
+	}
+}
+
+func NewCacheLFU(options ...func(cache *CacheLFU)) CacheLFU {
 	cache := CacheLFU{
-		keys:    make(map[string]bool),
-		entries: make([]*EntryLFU, 0),
+		clock:     clock.NewClock(),
+		decayTime: defaultDecayTime,
+		keys:      make(map[string]*EntryLFU),
+		entries:   make([]*EntryLFU, 0),
+	}
+	for _, option := range options {
+		option(&cache)
 	}
 	heap.Init(&cache)
 	return cache
@@ -46,12 +71,23 @@ func (cache *CacheLFU) Len() int {
 }
 
 func (cache *CacheLFU) Less(i, j int) bool {
-	// If 2 entries have the same count, swap using addedTime
-	if cache.entries[i].count == cache.entries[j].count {
-		return cache.entries[i].addedTime > cache.entries[j].addedTime
+	// If 2 entries have the same score, the least recently accessed one comes first.
+	si, sj := cache.score(cache.entries[i]), cache.score(cache.entries[j])
+	if si == sj {
+		return cache.entries[i].accessTime.Before(cache.entries[j].accessTime)
 	}
-	// Otherwise, swap using count
-	return cache.entries[i].count < cache.entries[j].count
+	return si < sj
+}
+
+// score orders the entries by their decayed access count without having to update them as time passes.
+// At any time t, the decayed count of an entry is its score minus t/decayTime, so the entries compare
+// the same way with their scores as they would with their decayed counts. Entries whose decayed count
+// has dropped to 0 keep being ordered by how long they've been idle.
+func (cache *CacheLFU) score(entry *EntryLFU) float64 {
+	if cache.decayTime == 0 {
+		return float64(entry.count)
+	}
+	return float64(entry.count) + float64(entry.accessTime.UnixNano())/float64(cache.decayTime)
 }
 
 func (cache *CacheLFU) Swap(i, j int) {
@@ -61,14 +97,14 @@ func (cache *CacheLFU) Swap(i, j int) {
 }
 
 func (cache *CacheLFU) Push(key any) {
-	n := len(cache.entries)
-	cache.entries = append(cache.entries, &EntryLFU{
-		key:       key.(string),
-		count:     1,
-		addedTime: time.Now().UnixMilli(),
-		index:     n,
-	})
-	cache.keys[key.(string)] = true
+	entry := &EntryLFU{
+		key:        key.(string),
+		count:      1,
+		accessTime: cache.clock.Now(),
+		index:      len(cache.entries),
+	}
+	cache.entries = append(cache.entries, entry)
+	cache.keys[entry.key] = entry
 }
 
 func (cache *CacheLFU) Pop() any {
@@ -83,30 +119,38 @@ func (cache *CacheLFU) Pop() any {
 }
 
 func (cache *CacheLFU) Update(key string) {
+	entry, ok := cache.keys[key]
 	// If the key is not contained in the cache, push it.
-	if !cache.contains(key) {
+	if !ok {
 		heap.Push(cache, key)
 		return
 	}
-	// Get the item with key
-	entryIdx := slices.IndexFunc(cache.entries, func(e *EntryLFU) bool {
-		return e.key == key
-	})
-	entry := cache.entries[entryIdx]
-	entry.count += 1
-	heap.Fix(cache, entryIdx)
+	now := cache.clock.Now()
+	entry.count = cache.decayedCount(entry, now) + 1
+	entry.accessTime = now
+	heap.Fix(cache, entry.index)
 }
 
 func (cache *CacheLFU) Delete(key string) {
-	entryIdx := slices.IndexFunc(cache.entries, func(entry *EntryLFU) bool {
-		return entry.key == key
-	})
-	if entryIdx > -1 {
-		heap.Remove(cache, cache.entries[entryIdx].index)
+	if entry, ok := cache.keys[key]; ok {
+		heap.Remove(cache, entry.index)
 	}
 }
 
-func (cache *CacheLFU) contains(key string) bool {
-	_, ok := cache.keys[key]
-	return ok
+// Count returns the access count of the key, decayed up to now.
+func (cache *CacheLFU) Count(key string) int {
+	entry, ok := cache.keys[key]
+	if !ok {
+		return 0
+	}
+	return cache.decayedCount(entry, cache.clock.Now())
+}
+
+// decayedCount returns the access count of the entry, decremented by one for every decay period
+// that has passed since it was last accessed.
+func (cache *CacheLFU) decayedCount(entry *EntryLFU, now time.Time) int {
+	if cache.decayTime == 0 {
+		return entry.count
+	}
+	return max(entry.count-int(now.Sub(entry.accessTime)/cache.decayTime), 0)
 }
@@ -16,9 +16,11 @@ package eviction_test
 
 import (
 	"container/heap"
+	"fmt"
 	"github.com/echovault/echovault/internal/eviction"
 	"sync"
 	"testing"
+	"time"
 )
 
 func Test_CacheLFU(t *testing.T) {
@@ -64,3 +66,115 @@ func Test_CacheLFU(t *testing.T) {
 	}
 	mut.Unlock()
 }
+
+// testClock is a clock that only moves forward when it's advanced.
+type testClock struct {
+	now *time.Time
+}
+
+func (c testClock) Now() time.Time {
+	return *c.now
+}
+
+func (c testClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c testClock) advance(d time.Duration) {
+	*c.now = c.now.Add(d)
+}
+
+func Test_CacheLFU_Decay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testClock{now: &now}
+	cache := eviction.NewCacheLFU(eviction.WithClock(clock), eviction.WithDecayTime(time.Minute))
+
+	// key1 is accessed often, then left idle.
+	for i := 0; i < 10; i++ {
+		cache.Update("key1")
+	}
+	clock.advance(8 * time.Minute)
+
+	// key2 and key3 are accessed less often, but more recently.
+	for i := 0; i < 3; i++ {
+		cache.Update("key2")
+	}
+	for i := 0; i < 4; i++ {
+		cache.Update("key3")
+	}
+
+	if count := cache.Count("key1"); count != 2 {
+		t.Errorf("expected the count of key1 to have decayed to 2, got %d", count)
+	}
+	if count := cache.Count("key2"); count != 3 {
+		t.Errorf("expected the count of key2 to be 3, got %d", count)
+	}
+
+	// key1 ends up being the least frequently used key.
+	expectedKeys := []string{"key1", "key2", "key3"}
+	for i := 0; i < len(expectedKeys); i++ {
+		key := heap.Pop(&cache).(string)
+		if key != expectedKeys[i] {
+			t.Errorf("expected popped key at index %d to be %s, got %s", i, expectedKeys[i], key)
+		}
+	}
+
+	// The count never decays below 0.
+	cache.Update("key4")
+	clock.advance(time.Hour)
+	if count := cache.Count("key4"); count != 0 {
+		t.Errorf("expected the count of key4 to have decayed to 0, got %d", count)
+	}
+	cache.Update("key4")
+	if count := cache.Count("key4"); count != 1 {
+		t.Errorf("expected the count of key4 to be 1, got %d", count)
+	}
+}
+
+func Test_CacheLFU_Delete(t *testing.T) {
+	cache := eviction.NewCacheLFU()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		cache.Update(key)
+	}
+	cache.Delete("key2")
+	cache.Delete("key4")
+	if cache.Len() != 2 {
+		t.Errorf("expected cache length to be 2, got %d", cache.Len())
+	}
+	for cache.Len() > 0 {
+		if key := heap.Pop(&cache).(string); key == "key2" {
+			t.Error("expected key2 to have been deleted")
+		}
+	}
+}
+
+func Benchmark_CacheLFU(b *testing.B) {
+	for _, size := range []int{1000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("Update/keys=%d", size), func(b *testing.B) {
+			cache := eviction.NewCacheLFU()
+			keys := make([]string, size)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key%d", i)
+				cache.Update(keys[i])
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Update(keys[i%size])
+			}
+		})
+		b.Run(fmt.Sprintf("Delete/keys=%d", size), func(b *testing.B) {
+			cache := eviction.NewCacheLFU()
+			keys := make([]string, size)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key%d", i)
+				cache.Update(keys[i])
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Add the key back so the cache keeps its size.
+				cache.Delete(keys[i%size])
+				cache.Update(keys[i%size])
+			}
+		})
+	}
+}
@@ -16,24 +16,23 @@ package eviction
 
 import (
 	"container/heap"
-	"slices"
-	"time"
 )
 
 type EntryLRU struct {
-	key      string // The key, matching the key in the store
-	unixTime int64  // Unix time in milliseconds when this key was accessed
-	index    int    // The index of the entry in the heap
+	key    string // The key, matching the key in the store
+	access uint64 // The sequence number of the latest access to this key
+	index  int    // The index of the entry in the heap
 }
 
 type CacheLRU struct {
-	keys    map[string]bool
-	entries []*EntryLRU
+	accesses uint64 // The number of accesses so far, used to order the entries by their latest access.
+	keys     map[string]*EntryLRU
+	entries  []*EntryLRU
 }
 
 func NewCacheLRU() CacheLRU {
 	cache := CacheLRU{
-		keys:    make(map[string]bool),
+		keys:    make(map[string]*EntryLRU),
 		entries: make([]*EntryLRU, 0),
 	}
 	heap.Init(&cache)
@@ -44,8 +43,9 @@ func (cache *CacheLRU) Len() int {
 	return len(cache.entries)
 }
 
+// Less orders the entries by their latest access, so that the least recently used key is popped first.
 func (cache *CacheLRU) Less(i, j int) bool {
-	return cache.entries[i].unixTime > cache.entries[j].unixTime
+	return cache.entries[i].access < cache.entries[j].access
 }
 
 func (cache *CacheLRU) Swap(i, j int) {
@@ -55,12 +55,14 @@ func (cache *CacheLRU) Swap(i, j int) {
 }
 
 func (cache *CacheLRU) Push(key any) {
-	n := len(cache.entries)
-	cache.entries = append(cache.entries, &EntryLRU{
-		key:      key.(string),
-		unixTime: time.Now().UnixMilli(),
-		index:    n,
-	})
+	cache.accesses++
+	entry := &EntryLRU{
+		key:    key.(string),
+		access: cache.accesses,
+		index:  len(cache.entries),
+	}
+	cache.entries = append(cache.entries, entry)
+	cache.keys[entry.key] = entry
 }
 
 func (cache *CacheLRU) Pop() any {
@@ -75,29 +77,19 @@ func (cache *CacheLRU) Pop() any {
 }
 
 func (cache *CacheLRU) Update(key string) {
+	entry, ok := cache.keys[key]
 	// If the key does not already exist in the cache, then push it
-	if !cache.contains(key) {
+	if !ok {
 		heap.Push(cache, key)
+		return
 	}
-	// Get the item with key
-	entryIdx := slices.IndexFunc(cache.entries, func(e *EntryLRU) bool {
-		return e.key == key
-	})
-	entry := cache.entries[entryIdx]
-	entry.unixTime = time.Now().UnixMilli()
-	heap.Fix(cache, entryIdx)
+	cache.accesses++
+	entry.access = cache.accesses
+	heap.Fix(cache, entry.index)
 }
 
 func (cache *CacheLRU) Delete(key string) {
-	entryIdx := slices.IndexFunc(cache.entries, func(entry *EntryLRU) bool {
-		return entry.key == key
-	})
-	if entryIdx > -1 {
-		heap.Remove(cache, cache.entries[entryIdx].index)
+	if entry, ok := cache.keys[key]; ok {
+		heap.Remove(cache, entry.index)
 	}
 }
-
-func (cache *CacheLRU) contains(key string) bool {
-	_, ok := cache.keys[key]
-	return ok
-}
@@ -16,6 +16,7 @@ package eviction_test
 
 import (
 	"container/heap"
+	"fmt"
 	"github.com/echovault/echovault/internal/eviction"
 	"testing"
 	"time"
@@ -39,10 +40,59 @@ func Test_CacheLRU(t *testing.T) {
 	}
 	ticker.Stop()
 
-	for i := len(access) - 1; i >= 0; i-- {
+	// The least recently accessed key is popped first.
+	for i := 0; i < len(access); i++ {
 		key := heap.Pop(&cache).(string)
 		if key != access[i] {
 			t.Errorf("expected key at index %d to be %s, got %s", i, access[i], key)
 		}
 	}
 }
+
+func Test_CacheLRU_Delete(t *testing.T) {
+	cache := eviction.NewCacheLRU()
+	for _, key := range []string{"key1", "key2", "key3", "key1"} {
+		cache.Update(key)
+	}
+	cache.Delete("key2")
+	cache.Delete("key4")
+	if cache.Len() != 2 {
+		t.Errorf("expected cache length to be 2, got %d", cache.Len())
+	}
+	for cache.Len() > 0 {
+		if key := heap.Pop(&cache).(string); key == "key2" {
+			t.Error("expected key2 to have been deleted")
+		}
+	}
+}
+
+func Benchmark_CacheLRU(b *testing.B) {
+	for _, size := range []int{1000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("Update/keys=%d", size), func(b *testing.B) {
+			cache := eviction.NewCacheLRU()
+			keys := make([]string, size)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key%d", i)
+				cache.Update(keys[i])
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Update(keys[i%size])
+			}
+		})
+		b.Run(fmt.Sprintf("Delete/keys=%d", size), func(b *testing.B) {
+			cache := eviction.NewCacheLRU()
+			keys := make([]string, size)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key%d", i)
+				cache.Update(keys[i])
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Add the key back so the cache keeps its size.
+				cache.Delete(keys[i%size])
+				cache.Update(keys[i%size])
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package forward implements the RPC channel that followers use to forward write commands, transactions and scripts
+// to the raft leader.
+// In a sharded cluster, the leaders of the shards also use it to move the keys of a migrating slot.
+// Nodes also use it to leave the raft group of the leader, to collect the raft stats of each other and to fetch the
+// slot state of the other shards.
+//
+// Each message is a frame made of a 4 byte big endian length followed by the JSON encoded Request or Response.
+// A connection carries one request at a time: the follower writes a request frame and waits for the response frame
+// before it sends the next request on the same connection.
+//
+// Both sides of a connection authenticate each other with the cluster secret before any request is sent.
+// The server sends a random nonce, the client answers with the HMAC of the nonce and a nonce of its own, and the
+// server answers with the HMAC of the client's nonce. The secret itself is never sent.
+package forward
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxFrameSize is the largest request or response frame that is accepted, to protect against corrupted length
+// prefixes. It only applies once the connection is authenticated.
+const maxFrameSize = 512 * 1024 * 1024
+
+// maxHandshakeFrameSize is the largest handshake frame that is accepted, so that a peer that is not authenticated
+// can't make the node allocate large buffers.
+const maxHandshakeFrameSize = 4 * 1024
+
+// handshakeTimeout is the maximum time the server waits for a new connection to authenticate.
+const handshakeTimeout = 5 * time.Second
+
+// nonceSize is the size of the random nonces exchanged in the handshake.
+const nonceSize = 32
+
+// The labels the handshake MACs are computed with, so that the MAC sent by one side can't be replayed as the other's.
+const (
+	clientLabel = "echovault-forward-client"
+	serverLabel = "echovault-forward-server"
+)
+
+// ErrUnauthenticated is returned by Client.Forward when the server does not know the cluster secret,
+// or rejected the client because the client does not know it.
+var ErrUnauthenticated = errors.New("forward connection not authenticated")
+
+// ErrNotDelivered is returned by Client.Forward when the request did not reach the leader,
+// so it can be retried without the risk of applying the command twice.
+var ErrNotDelivered = errors.New("request not delivered")
+
+// ErrNotLeader can be returned by the Handle function of the server when the command was not applied because the node
+// is not the leader. The follower then retries the request on the new leader.
+var ErrNotLeader = errors.New("not cluster leader")
+
+// The types of forwarded requests.
+const (
+	TypeCommand     = "command"     // A write command forwarded by a follower. This is the default.
+	TypeTransaction = "transaction" // A transaction executed with EXEC on a follower.
+	TypeScript      = "script"      // A script run with EVAL or EVALSHA on a follower.
+	TypeRestoreKey  = "restore-key" // A key migrated from another shard of the cluster.
+	TypeLeave       = "leave"       // A node asking the leader to remove it from the raft group.
+	TypeStats       = "stats"       // A request for the raft stats of the node. It's served by every node.
+	TypeSlots       = "slots"       // A request for the slot state of the node's shard. It's served by every node.
+)
+
+// Request is a command forwarded by a follower to the leader, or a key migrated to the leader of another shard.
+type Request struct {
+	Type         string            `json:"Type"`         // The request type. An empty type is a command.
+	ServerID     string            `json:"ServerID"`     // The ID of the follower that forwarded the command.
+	ConnectionID string            `json:"ConnectionID"` // The ID of the client connection on the follower.
+	CMD          []string          `json:"CMD"`          // The command to apply. The EVAL command of a script request.
+	Transaction  [][]string        `json:"Transaction"`  // The queued commands of a transaction request.
+	Watched      map[string]uint64 `json:"Watched"`      // The versions of the keys watched by a transaction request.
+	Protocol     int               `json:"Protocol"`     // The RESP protocol version of the client connection.
+	Key          string            `json:"Key"`          // The key of a restore-key request.
+	Value        []byte            `json:"Value"`        // The value of a restore-key request, encoded with codec.EncodeValue.
+	ExpireAt     int64             `json:"ExpireAt"`     // The expiry of a restore-key request in unix nanoseconds. 0 if none.
+	User         string            `json:"User"`         // The ACL user of the client connection. Empty for the embedded API.
+	RemoteAddr   string            `json:"-"`            // The address the request was received from. Set by the server.
+}
+
+// Response is the leader's result of a forwarded command.
+type Response struct {
+	Response  []byte `json:"Response"`  // The RESP encoded response of the command.
+	Error     string `json:"Error"`     // The error returned by the command. Empty if the command succeeded.
+	NotLeader bool   `json:"NotLeader"` // True if the node is not the leader. The request can be retried on the new leader.
+}
+
+func writeFrame(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	copy(frame[4:], b)
+	_, err = w.Write(frame)
+	return err
+}
+
+// handshake is the frame exchanged when a connection is opened.
+type handshake struct {
+	Nonce []byte `json:"Nonce"` // The nonce the other side must return the MAC of.
+	MAC   []byte `json:"MAC"`   // The MAC of the other side's nonce. Empty if the other side was rejected.
+}
+
+func handshakeMAC(secret string, label string, nonce []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(label))
+	h.Write(nonce)
+	return h.Sum(nil)
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	_, err := rand.Read(nonce)
+	return nonce, err
+}
+
+// serverHandshake authenticates the client of the connection and proves to it that the server knows the secret.
+func serverHandshake(conn net.Conn, secret string) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	if err = writeFrame(conn, handshake{Nonce: nonce}); err != nil {
+		return err
+	}
+	var client handshake
+	if err = readFrame(conn, &client, maxHandshakeFrameSize); err != nil {
+		return err
+	}
+	if !hmac.Equal(client.MAC, handshakeMAC(secret, clientLabel, nonce)) {
+		_ = writeFrame(conn, handshake{})
+		return ErrUnauthenticated
+	}
+	return writeFrame(conn, handshake{MAC: handshakeMAC(secret, serverLabel, client.Nonce)})
+}
+
+// clientHandshake authenticates the server of the connection and proves to it that the client knows the secret.
+func clientHandshake(conn net.Conn, secret string) error {
+	var server handshake
+	if err := readFrame(conn, &server, maxHandshakeFrameSize); err != nil {
+		return err
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	if err = writeFrame(conn, handshake{Nonce: nonce, MAC: handshakeMAC(secret, clientLabel, server.Nonce)}); err != nil {
+		return err
+	}
+	if err = readFrame(conn, &server, maxHandshakeFrameSize); err != nil {
+		return err
+	}
+	if !hmac.Equal(server.MAC, handshakeMAC(secret, serverLabel, nonce)) {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// readFrame reads a frame into v. Frames larger than maxSize are rejected before they're read.
+func readFrame(r io.Reader, v interface{}, maxSize uint32) error {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxSize {
+		return fmt.Errorf("frame of %d bytes is larger than the maximum of %d bytes", size, maxSize)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ServerOpts holds the callbacks used by the leader to serve forwarded commands.
+type ServerOpts struct {
+	// IsLeader returns true if the node is the raft leader. Requests received by other nodes are rejected,
+	// so that the follower retries them on the new leader. Stats and slots requests are served by every node.
+	IsLeader func() bool
+	// Handle applies the forwarded command through raft and returns its response.
+	Handle func(ctx context.Context, request Request) ([]byte, error)
+	// Secret is the cluster secret that clients must prove they know before sending requests.
+	Secret string
+}
+
+// Server accepts the connections of followers and serves the commands they forward.
+type Server struct {
+	options  ServerOpts
+	listener net.Listener
+	mutex    sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+func NewServer(opts ServerOpts) *Server {
+	return &Server{
+		options: opts,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen starts accepting follower connections on the address.
+func (s *Server) Listen(ctx context.Context, addr string) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("forward listener: %v\n", err)
+				}
+				return
+			}
+			go s.serve(ctx, conn)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops accepting connections and closes the open follower connections.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mutex.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		_ = conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		_ = conn.Close()
+	}()
+
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return
+	}
+	if err := serverHandshake(conn, s.options.Secret); err != nil {
+		log.Printf("forward handshake with %s: %v\n", conn.RemoteAddr(), err)
+		return
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return
+	}
+
+	for {
+		var request Request
+		if err := readFrame(conn, &request, maxFrameSize); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("forward read: %v\n", err)
+			}
+			return
+		}
+		request.RemoteAddr = conn.RemoteAddr().String()
+
+		var response Response
+		if request.Type != TypeStats && request.Type != TypeSlots && !s.options.IsLeader() {
+			response.NotLeader = true
+		} else if res, err := s.options.Handle(ctx, request); errors.Is(err, ErrNotLeader) {
+			response.NotLeader = true
+		} else if err != nil {
+			response.Error = err.Error()
+		} else {
+			response.Response = res
+		}
+
+		if err := writeFrame(conn, response); err != nil {
+			log.Printf("forward write: %v\n", err)
+			return
+		}
+	}
+}
+
+// Client forwards commands to the leader. It keeps the connections to the leader open between requests.
+type Client struct {
+	timeout time.Duration // The maximum time to wait for the response of a request.
+	secret  string        // The cluster secret that the client and the server authenticate each other with.
+	mutex   sync.Mutex
+	idle    map[string][]*idleConn // The idle connections to each leader address.
+}
+
+// idleConn is a connection kept open between requests. The server never writes to an idle connection,
+// so a goroutine waits for it to become readable, which means that the server closed it.
+type idleConn struct {
+	conn net.Conn
+	read chan error // Receives the error of the read once the connection is readable or taken back.
+}
+
+func NewClient(timeout time.Duration, secret string) *Client {
+	return &Client{
+		timeout: timeout,
+		secret:  secret,
+		idle:    make(map[string][]*idleConn),
+	}
+}
+
+// Forward sends the request to the leader at the address and waits for its response.
+// The returned error wraps ErrNotDelivered if the request could not be sent, in which case it's safe to retry.
+// Once the request is sent, a failure can't tell whether the leader applied it, so the error does not wrap
+// ErrNotDelivered.
+func (c *Client) Forward(ctx context.Context, addr string, request Request) (Response, error) {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	conn, err := c.getConn(addr, deadline)
+	if errors.Is(err, ErrUnauthenticated) {
+		return Response{}, err
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("%w: %v", ErrNotDelivered, err)
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return Response{}, fmt.Errorf("%w: %v", ErrNotDelivered, err)
+	}
+
+	// A failed write leaves an incomplete frame that the server discards, so the request was not delivered.
+	if err = writeFrame(conn, request); err != nil {
+		_ = conn.Close()
+		return Response{}, fmt.Errorf("%w: %v", ErrNotDelivered, err)
+	}
+
+	var response Response
+	if err = readFrame(conn, &response, maxFrameSize); err != nil {
+		_ = conn.Close()
+		return Response{}, err
+	}
+
+	c.putConn(addr, conn)
+	return response, nil
+}
+
+// Close closes all the idle connections.
+func (c *Client) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for addr, conns := range c.idle {
+		for _, idle := range conns {
+			_ = idle.conn.Close()
+		}
+		delete(c.idle, addr)
+	}
+}
+
+// getConn returns an idle connection to the address that is still open, or dials and authenticates a new one.
+// Checking the idle connection before the request is written means that a connection closed by the server
+// while it was idle never fails a request that may have been applied.
+func (c *Client) getConn(addr string, deadline time.Time) (net.Conn, error) {
+	for {
+		c.mutex.Lock()
+		conns := c.idle[addr]
+		if len(conns) == 0 {
+			c.mutex.Unlock()
+			break
+		}
+		idle := conns[len(conns)-1]
+		c.idle[addr] = conns[:len(conns)-1]
+		c.mutex.Unlock()
+
+		// Stop the pending read. It times out if the connection is still open.
+		if err := idle.conn.SetReadDeadline(time.Unix(1, 0)); err != nil {
+			_ = idle.conn.Close()
+			continue
+		}
+		var netErr net.Error
+		if err := <-idle.read; errors.As(err, &netErr) && netErr.Timeout() {
+			return idle.conn, nil
+		}
+		_ = idle.conn.Close()
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, time.Until(deadline))
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err = clientHandshake(conn, c.secret); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) putConn(addr string, conn net.Conn) {
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+		return
+	}
+	idle := &idleConn{conn: conn, read: make(chan error, 1)}
+	go func() {
+		var b [1]byte
+		_, err := conn.Read(b[:])
+		if err == nil {
+			// The server does not write to idle connections, so the connection is out of sync.
+			err = errors.New("unexpected data on idle connection")
+		}
+		idle.read <- err
+	}()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.idle[addr] = append(c.idle[addr], idle)
+}
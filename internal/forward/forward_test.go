@@ -0,0 +1,98 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forward
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, secret string) *Server {
+	server := NewServer(ServerOpts{
+		IsLeader: func() bool { return true },
+		Handle: func(ctx context.Context, request Request) ([]byte, error) {
+			return []byte(strings.Join(request.CMD, " ")), nil
+		},
+		Secret: secret,
+	})
+	if err := server.Listen(context.Background(), "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return server
+}
+
+func Test_ForwardIdleConnectionClosed(t *testing.T) {
+	server := newTestServer(t, "secret")
+	client := NewClient(time.Second, "secret")
+	defer client.Close()
+
+	response, err := client.Forward(context.Background(), server.Addr(), Request{CMD: []string{"SET", "key", "value"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Response) != "SET key value" {
+		t.Errorf("expected response \"SET key value\", got %q", response.Response)
+	}
+
+	// Close the connection the client keeps between requests, as the server does when it shuts down.
+	server.mutex.Lock()
+	for conn := range server.conns {
+		_ = conn.Close()
+	}
+	server.mutex.Unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	// The closed connection is detected before the request is written, so the request is sent on a new one.
+	response, err = client.Forward(context.Background(), server.Addr(), Request{CMD: []string{"DEL", "key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Response) != "DEL key" {
+		t.Errorf("expected response \"DEL key\", got %q", response.Response)
+	}
+}
+
+func Test_ForwardHandshakeFrameSize(t *testing.T) {
+	server := newTestServer(t, "secret")
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	var challenge handshake
+	if err = readFrame(conn, &challenge, maxHandshakeFrameSize); err != nil {
+		t.Fatal(err)
+	}
+	// Announce a frame larger than the handshake limit. The server must close the connection without reading it.
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], maxHandshakeFrameSize+1)
+	if _, err = conn.Write(length[:]); err != nil {
+		t.Fatal(err)
+	}
+	var b [1]byte
+	if _, err = conn.Read(b[:]); err == nil {
+		t.Error("expected the server to close the connection")
+	} else if netErr := (net.Error)(nil); errors.As(err, &netErr) && netErr.Timeout() {
+		t.Error("expected the server to close the connection, but it is waiting for the frame")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+)
+
+// KeyspaceEvents is the set of keyspace notifications enabled by the notify-keyspace-events config.
+// Each flag of the config enables one of the values below.
+type KeyspaceEvents uint16
+
+const (
+	KeyspaceEventsKeyspace  KeyspaceEvents = 1 << iota // K: Publish events on the __keyspace@0__:<key> channels.
+	KeyspaceEventsKeyevent                             // E: Publish events on the __keyevent@0__:<event> channels.
+	KeyspaceEventsGeneric                              // g: Commands that are not type specific, like DEL and EXPIRE.
+	KeyspaceEventsString                               // $: String commands.
+	KeyspaceEventsList                                 // l: List commands.
+	KeyspaceEventsSet                                  // s: Set commands.
+	KeyspaceEventsHash                                 // h: Hash commands.
+	KeyspaceEventsSortedSet                            // z: Sorted set commands.
+	KeyspaceEventsStream                               // t: Stream commands.
+	KeyspaceEventsExpired                              // x: Keys deleted because their expiry time has passed.
+	KeyspaceEventsEvicted                              // e: Keys evicted to stay under max-memory.
+	KeyspaceEventsNew                                  // n: Keys added to the keyspace.
+
+	// KeyspaceEventsAll is the A flag, an alias for "g$lshztxe".
+	KeyspaceEventsAll = KeyspaceEventsGeneric | KeyspaceEventsString | KeyspaceEventsList | KeyspaceEventsSet |
+		KeyspaceEventsHash | KeyspaceEventsSortedSet | KeyspaceEventsStream | KeyspaceEventsExpired | KeyspaceEventsEvicted
+)
+
+var keyspaceEventFlags = map[rune]KeyspaceEvents{
+	'K': KeyspaceEventsKeyspace,
+	'E': KeyspaceEventsKeyevent,
+	'g': KeyspaceEventsGeneric,
+	'$': KeyspaceEventsString,
+	'l': KeyspaceEventsList,
+	's': KeyspaceEventsSet,
+	'h': KeyspaceEventsHash,
+	'z': KeyspaceEventsSortedSet,
+	't': KeyspaceEventsStream,
+	'x': KeyspaceEventsExpired,
+	'e': KeyspaceEventsEvicted,
+	'n': KeyspaceEventsNew,
+	'A': KeyspaceEventsAll,
+}
+
+// ParseKeyspaceEvents parses the flags of the notify-keyspace-events config (e.g. "KEA" or "Kgx").
+// No events are published unless at least one of K or E is set along with the classes of events.
+// An empty string disables keyspace notifications.
+func ParseKeyspaceEvents(flags string) (KeyspaceEvents, error) {
+	var events KeyspaceEvents
+	for _, flag := range flags {
+		event, ok := keyspaceEventFlags[flag]
+		if !ok {
+			return 0, fmt.Errorf("invalid keyspace event flag '%c'", flag)
+		}
+		events |= event
+	}
+	return events, nil
+}
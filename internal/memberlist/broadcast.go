@@ -40,9 +40,6 @@ func (broadcastMessage *BroadcastMessage) Invalidates(other memberlist.Broadcast
 	case "RaftJoin":
 		return broadcastMessage.Action == otherBroadcast.Action &&
 			broadcastMessage.ServerID == otherBroadcast.ServerID
-	case "MutateData":
-		return broadcastMessage.Action == otherBroadcast.Action &&
-			broadcastMessage.ContentHash == otherBroadcast.ContentHash
 	default:
 		return false
 	}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/slots"
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/raft"
 	"log"
@@ -34,9 +35,10 @@ type DelegateOpts struct {
 	config         config.Config
 	broadcastQueue *memberlist.TransmitLimitedQueue
 	addVoter       func(id raft.ServerID, address raft.ServerAddress, prevIndex uint64, timeout time.Duration) error
+	addNonvoter    func(id raft.ServerID, address raft.ServerAddress, prevIndex uint64, timeout time.Duration) error
 	isRaftLeader   func() bool
-	applyMutate    func(ctx context.Context, cmd []string) ([]byte, error)
 	applyDeleteKey func(ctx context.Context, key string) error
+	getSlotState   func() slots.State
 }
 
 func NewDelegate(opts DelegateOpts) *Delegate {
@@ -45,13 +47,26 @@ func NewDelegate(opts DelegateOpts) *Delegate {
 	}
 }
 
-// NodeMeta implements Delegate interface
+// NodeMeta implements Delegate interface. The slots of the shard are not advertised because they don't fit in the
+// metadata size limit. The other shards fetch them from the node when it advertises a new slot epoch or digest.
 func (delegate *Delegate) NodeMeta(limit int) []byte {
 	meta := NodeMeta{
 		ServerID: raft.ServerID(delegate.options.config.ServerID),
 		RaftAddr: raft.ServerAddress(
 			fmt.Sprintf("%s:%d", delegate.options.config.RaftBindAddr, delegate.options.config.RaftBindPort)),
 		MemberlistAddr: fmt.Sprintf("%s:%d", delegate.options.config.BindAddr, delegate.options.config.DiscoveryPort),
+		ShardID:        delegate.options.config.ShardID,
+		ClientAddr:     fmt.Sprintf("%s:%d", delegate.options.config.BindAddr, delegate.options.config.Port),
+		Leader:         delegate.options.isRaftLeader(),
+	}
+	// The forward listener only runs when the cluster secret is set.
+	if delegate.options.config.ClusterSecret != "" {
+		meta.ForwardAddr = fmt.Sprintf("%s:%d", delegate.options.config.RaftBindAddr, delegate.options.config.ForwardBindPort)
+	}
+	if delegate.options.getSlotState != nil {
+		state := delegate.options.getSlotState()
+		meta.SlotEpoch = state.Epoch
+		meta.SlotDigest = state.Digest()
 	}
 
 	b, err := json.Marshal(&meta)
@@ -60,6 +75,12 @@ func (delegate *Delegate) NodeMeta(limit int) []byte {
 		return []byte("")
 	}
 
+	// Memberlist rejects metadata larger than the limit.
+	if len(b) > limit {
+		log.Printf("node metadata of %d bytes exceeds the limit of %d bytes\n", len(b), limit)
+		return []byte("")
+	}
+
 	return b
 }
 
@@ -73,19 +94,23 @@ func (delegate *Delegate) NotifyMsg(msgBytes []byte) {
 
 	switch msg.Action {
 	case "RaftJoin":
-		// If the current node is not the cluster leader, re-broadcast the message.
-		if !delegate.options.isRaftLeader() {
+		// If the current node is not the leader of the node's shard, re-broadcast the message.
+		if !delegate.options.isRaftLeader() || msg.NodeMeta.ShardID != delegate.options.config.ShardID {
 			delegate.options.broadcastQueue.QueueBroadcast(&msg)
 			return
 		}
-		err := delegate.options.addVoter(msg.NodeMeta.ServerID, msg.NodeMeta.RaftAddr, 0, 0)
+		addServer := delegate.options.addVoter
+		if msg.NodeMeta.Learner {
+			addServer = delegate.options.addNonvoter
+		}
+		err := addServer(msg.NodeMeta.ServerID, msg.NodeMeta.RaftAddr, 0, 0)
 		if err != nil {
 			log.Println(err)
 		}
 
 	case "DeleteKey":
-		// If the current node is not a cluster leader, re-broadcast the message.
-		if !delegate.options.isRaftLeader() {
+		// If the current node is not the leader of the node's shard, re-broadcast the message.
+		if !delegate.options.isRaftLeader() || msg.NodeMeta.ShardID != delegate.options.config.ShardID {
 			delegate.options.broadcastQueue.QueueBroadcast(&msg)
 			return
 		}
@@ -99,27 +124,6 @@ func (delegate *Delegate) NotifyMsg(msgBytes []byte) {
 		if err := delegate.options.applyDeleteKey(ctx, key); err != nil {
 			log.Println(err)
 		}
-
-	case "MutateData":
-		// If the current node is not a cluster leader, re-broadcast the message.
-		if !delegate.options.isRaftLeader() {
-			delegate.options.broadcastQueue.QueueBroadcast(&msg)
-			return
-		}
-		// Current node is the cluster leader, handle the mutation
-		ctx := context.WithValue(
-			context.WithValue(context.Background(), internal.ContextServerID("ServerID"), string(msg.ServerID)),
-			internal.ContextConnID("ConnectionID"), msg.ConnId)
-
-		cmd, err := internal.Decode(msg.Content)
-		if err != nil {
-			log.Println(err)
-			return
-		}
-
-		if _, err := delegate.options.applyMutate(ctx, cmd); err != nil {
-			log.Println(err)
-		}
 	}
 }
 
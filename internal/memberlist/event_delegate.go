@@ -25,8 +25,8 @@ type EventDelegate struct {
 }
 
 type EventDelegateOpts struct {
-	incrementNodes   func()
-	decrementNodes   func()
+	setMember        func(name string, meta NodeMeta)
+	removeMember     func(name string)
 	removeRaftServer func(meta NodeMeta) error
 }
 
@@ -38,12 +38,12 @@ func NewEventDelegate(opts EventDelegateOpts) *EventDelegate {
 
 // NotifyJoin implements EventDelegate interface
 func (eventDelegate *EventDelegate) NotifyJoin(node *memberlist.Node) {
-	eventDelegate.options.incrementNodes()
+	eventDelegate.setMember(node)
 }
 
 // NotifyLeave implements EventDelegate interface
 func (eventDelegate *EventDelegate) NotifyLeave(node *memberlist.Node) {
-	eventDelegate.options.decrementNodes()
+	eventDelegate.options.removeMember(node.Name)
 
 	var meta NodeMeta
 
@@ -63,5 +63,16 @@ func (eventDelegate *EventDelegate) NotifyLeave(node *memberlist.Node) {
 
 // NotifyUpdate implements EventDelegate interface
 func (eventDelegate *EventDelegate) NotifyUpdate(node *memberlist.Node) {
-	// No-Op
+	eventDelegate.setMember(node)
+}
+
+// setMember caches the metadata of the node. The node's metadata can only be read safely
+// while memberlist notifies the delegate, so it's copied here.
+func (eventDelegate *EventDelegate) setMember(node *memberlist.Node) {
+	var meta NodeMeta
+	if err := json.Unmarshal(node.Meta, &meta); err != nil {
+		log.Printf("could not get metadata of node %s: %v\n", node.Name, err)
+		return
+	}
+	eventDelegate.options.setMember(node.Name, meta)
 }
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/slots"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/memberlist"
@@ -32,30 +34,40 @@ type NodeMeta struct {
 	ServerID       raft.ServerID      `json:"ServerID"`
 	MemberlistAddr string             `json:"MemberlistAddr"`
 	RaftAddr       raft.ServerAddress `json:"RaftAddr"`
+	ForwardAddr    string             `json:"ForwardAddr"`       // The address that followers forward write commands to.
+	ShardID        string             `json:"ShardID"`           // The shard (raft group) the node belongs to.
+	ClientAddr     string             `json:"ClientAddr"`        // The address clients connect to, sent in redirections.
+	Leader         bool               `json:"Leader"`            // True if the node is the leader of its shard.
+	SlotEpoch      uint64             `json:"SlotEpoch"`         // The epoch of the shard's slot state known to the node.
+	SlotDigest     uint64             `json:"SlotDigest"`        // The digest of the slots owned by the shard, see slots.State.Digest.
+	Learner        bool               `json:"Learner,omitempty"` // True if the node joins the raft group as a non-voter.
 }
 
 type Opts struct {
 	Config           config.Config
 	HasJoinedCluster func() bool
 	AddVoter         func(id raft.ServerID, address raft.ServerAddress, prevIndex uint64, timeout time.Duration) error
+	AddNonvoter      func(id raft.ServerID, address raft.ServerAddress, prevIndex uint64, timeout time.Duration) error
 	RemoveRaftServer func(meta NodeMeta) error
 	IsRaftLeader     func() bool
-	ApplyMutate      func(ctx context.Context, cmd []string) ([]byte, error)
 	ApplyDeleteKey   func(ctx context.Context, key string) error
+	GetSlotState     func() slots.State
 }
 
 type MemberList struct {
 	options        Opts
 	broadcastQueue *memberlist.TransmitLimitedQueue
-	numOfNodes     int
 	memberList     *memberlist.Memberlist
+
+	membersMutex sync.RWMutex
+	members      map[string]NodeMeta // The metadata of the cluster members, including this node, by node name.
 }
 
 func NewMemberList(opts Opts) *MemberList {
 	return &MemberList{
 		options:        opts,
 		broadcastQueue: new(memberlist.TransmitLimitedQueue),
-		numOfNodes:     0,
+		members:        make(map[string]NodeMeta),
 	}
 }
 
@@ -69,19 +81,30 @@ func (m *MemberList) MemberListInit(ctx context.Context) {
 		config:         m.options.Config,
 		broadcastQueue: m.broadcastQueue,
 		addVoter:       m.options.AddVoter,
+		addNonvoter:    m.options.AddNonvoter,
 		isRaftLeader:   m.options.IsRaftLeader,
-		applyMutate:    m.options.ApplyMutate,
 		applyDeleteKey: m.options.ApplyDeleteKey,
+		getSlotState:   m.options.GetSlotState,
 	})
 	cfg.Events = NewEventDelegate(EventDelegateOpts{
-		incrementNodes:   func() { m.numOfNodes += 1 },
-		decrementNodes:   func() { m.numOfNodes -= 1 },
+		setMember: func(name string, meta NodeMeta) {
+			m.membersMutex.Lock()
+			defer m.membersMutex.Unlock()
+			m.members[name] = meta
+		},
+		removeMember: func(name string) {
+			m.membersMutex.Lock()
+			defer m.membersMutex.Unlock()
+			delete(m.members, name)
+		},
 		removeRaftServer: m.options.RemoveRaftServer,
 	})
 
 	m.broadcastQueue.RetransmitMult = 1
 	m.broadcastQueue.NumNodes = func() int {
-		return m.numOfNodes
+		m.membersMutex.RLock()
+		defer m.membersMutex.RUnlock()
+		return len(m.members)
 	}
 
 	list, err := memberlist.Create(cfg)
@@ -106,7 +129,11 @@ func (m *MemberList) MemberListInit(ctx context.Context) {
 			log.Fatal(err)
 		}
 
-		m.broadcastRaftAddress()
+		// A node that bootstraps its shard joins the memberlist cluster of the other shards,
+		// but not the raft group of another shard.
+		if !m.options.Config.BootstrapCluster {
+			m.broadcastRaftAddress()
+		}
 	}
 }
 
@@ -117,6 +144,8 @@ func (m *MemberList) broadcastRaftAddress() {
 			ServerID: raft.ServerID(m.options.Config.ServerID),
 			RaftAddr: raft.ServerAddress(fmt.Sprintf("%s:%d",
 				m.options.Config.RaftBindAddr, m.options.Config.RaftBindPort)),
+			ShardID: m.options.Config.ShardID,
+			Learner: m.options.Config.RaftLearner,
 		},
 	}
 	m.broadcastQueue.QueueBroadcast(&msg)
@@ -135,25 +164,48 @@ func (m *MemberList) ForwardDeleteKey(ctx context.Context, key string) {
 			ServerID: raft.ServerID(m.options.Config.ServerID),
 			RaftAddr: raft.ServerAddress(fmt.Sprintf("%s:%d",
 				m.options.Config.BindAddr, m.options.Config.RaftBindPort)),
+			ShardID: m.options.Config.ShardID,
 		},
 	})
 }
 
-// The ForwardDataMutation function is only called by non-leaders.
-// It uses the broadcast queue to forward a data mutation within the cluster.
-func (m *MemberList) ForwardDataMutation(ctx context.Context, cmd []byte) {
-	connId, _ := ctx.Value(internal.ContextConnID("ConnectionID")).(string)
-	m.broadcastQueue.QueueBroadcast(&BroadcastMessage{
-		Action:      "MutateData",
-		Content:     cmd,
-		ContentHash: md5.Sum(cmd),
-		ConnId:      connId,
-		NodeMeta: NodeMeta{
-			ServerID: raft.ServerID(m.options.Config.ServerID),
-			RaftAddr: raft.ServerAddress(fmt.Sprintf("%s:%d",
-				m.options.Config.BindAddr, m.options.Config.RaftBindPort)),
-		},
-	})
+// GetNodeMeta returns the metadata of the cluster member with the server ID.
+func (m *MemberList) GetNodeMeta(serverID raft.ServerID) (NodeMeta, error) {
+	m.membersMutex.RLock()
+	defer m.membersMutex.RUnlock()
+	for _, meta := range m.members {
+		if meta.ServerID == serverID {
+			return meta, nil
+		}
+	}
+	return NodeMeta{}, fmt.Errorf("could not find cluster member %s", serverID)
+}
+
+// Members returns the metadata of the cluster members, including the members of the other shards.
+func (m *MemberList) Members() []NodeMeta {
+	m.membersMutex.RLock()
+	defer m.membersMutex.RUnlock()
+	metas := make([]NodeMeta, 0, len(m.members))
+	for _, meta := range m.members {
+		metas = append(metas, meta)
+	}
+	return metas
+}
+
+// UpdateMeta advertises the node's current metadata to the cluster.
+// It's called when the node's leadership or the slot state of its shard changes.
+func (m *MemberList) UpdateMeta() {
+	if m.memberList == nil {
+		return
+	}
+	if err := m.memberList.UpdateNode(5 * time.Second); err != nil {
+		log.Printf("memberlist update: %v\n", err)
+	}
+}
+
+// Leave gracefully leaves the memberlist cluster without shutting the memberlist down.
+func (m *MemberList) Leave() error {
+	return m.memberList.Leave(5 * time.Second)
 }
 
 func (m *MemberList) MemberListShutdown() {
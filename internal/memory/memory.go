@@ -0,0 +1,143 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory estimates the memory used by the keys and values in the store.
+//
+// The estimates are based on the size of the Go representation of each value type on a 64-bit platform.
+// They do not account for allocator overhead or memory held by the runtime, but they are cheap to compute,
+// and they grow and shrink predictably with the dataset, which is what eviction decisions need.
+package memory
+
+import (
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
+	"github.com/echovault/echovault/internal/modules/set"
+	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
+)
+
+// DefaultSamples is the number of elements sampled to estimate the size of a collection when it's tracked
+// on writes. It's the same as the default SAMPLES of MEMORY USAGE in Redis.
+const DefaultSamples = 5
+
+const (
+	stringHeader    = 16 // The size of a string header.
+	sliceHeader     = 24 // The size of a slice header.
+	interfaceHeader = 16 // The size of an interface value.
+	pointer         = 8
+	word            = 8
+	mapHeader       = 48 // The size of a map header.
+	mapEntry        = 8  // The per-entry overhead of a map (tophash and load factor slack).
+	keyData         = 40 // The size of a KeyData (an interface value and a time.Time).
+	memberObject    = 32 // The size of a sorted_set.MemberObject.
+	streamEntry     = 40 // The size of a stream.Entry (an ID and a slice header).
+)
+
+// KeyUsage estimates the bytes used to hold the key and its value in the store.
+func KeyUsage(key string, value interface{}, samples int) int64 {
+	return stringHeader + int64(len(key)) + keyData + mapEntry + Usage(value, samples)
+}
+
+// Usage estimates the bytes used by the value, not including the interface value that holds it.
+// Collections with more than samples elements are estimated from the average size of samples of their
+// elements. When samples is 0, every element is measured.
+func Usage(value interface{}, samples int) int64 {
+	switch v := value.(type) {
+	default:
+		return word
+	case string:
+		return stringHeader + int64(len(v))
+	case int, int64, float64:
+		return word
+	case []interface{}:
+		return sliceHeader + int64(len(v))*interfaceHeader + listUsage(v, samples)
+	case map[string]interface{}:
+		usage := sample(len(v), samples, func(yield func(int64) bool) {
+			for field, value := range v {
+				if !yield(stringHeader + int64(len(field)) + Usage(value, samples)) {
+					return
+				}
+			}
+		})
+		return mapHeader + int64(len(v))*(interfaceHeader+mapEntry) + usage
+	case *set.Set:
+		usage := sample(v.Cardinality(), samples, func(yield func(int64) bool) {
+			v.Each(func(member string) bool {
+				return yield(stringHeader + int64(len(member)))
+			})
+		})
+		return pointer + mapHeader + int64(v.Cardinality())*(interfaceHeader+mapEntry) + usage
+	case *sorted_set.SortedSet:
+		usage := sample(v.Cardinality(), samples, func(yield func(int64) bool) {
+			v.Each(func(member sorted_set.MemberParam) bool {
+				// The member is held both as the key of the map and in the MemberObject.
+				return yield(2 * (stringHeader + int64(len(member.Value))))
+			})
+		})
+		return pointer + mapHeader + int64(v.Cardinality())*(memberObject-stringHeader+mapEntry) + usage
+	case *stream.Stream:
+		// The first entries of the stream are sampled. A count of 0 returns every entry.
+		entries := v.Range(stream.MinID, stream.MaxID, samples, false)
+		usage := sample(v.Len(), 0, func(yield func(int64) bool) {
+			for _, entry := range entries {
+				if !yield(streamEntry + int64(len(entry.Fields))*stringHeader + fieldsUsage(entry.Fields)) {
+					return
+				}
+			}
+		})
+		return pointer + usage
+	case *hyperloglog.HyperLogLog:
+		return pointer + 2*sliceHeader + word + int64(v.Size())
+	}
+}
+
+func listUsage(list []interface{}, samples int) int64 {
+	if samples == 0 || len(list) <= samples {
+		var usage int64
+		for _, element := range list {
+			usage += Usage(element, 0)
+		}
+		return usage
+	}
+	// Sample elements spread evenly over the list.
+	var usage int64
+	step := len(list) / samples
+	for i := 0; i < samples; i++ {
+		usage += Usage(list[i*step], samples)
+	}
+	return usage * int64(len(list)) / int64(samples)
+}
+
+func fieldsUsage(fields []string) int64 {
+	var usage int64
+	for _, field := range fields {
+		usage += int64(len(field))
+	}
+	return usage
+}
+
+// sample returns the total size of n elements, estimated from the sizes of the first samples elements yielded
+// by each. When samples is 0, or there are no more than samples elements, every element is measured.
+func sample(n int, samples int, each func(yield func(size int64) bool)) int64 {
+	var usage int64
+	var measured int64
+	each(func(size int64) bool {
+		usage += size
+		measured++
+		return samples == 0 || measured < int64(samples)
+	})
+	if measured == 0 || measured >= int64(n) {
+		return usage
+	}
+	return usage * int64(n) / measured
+}
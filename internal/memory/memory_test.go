@@ -0,0 +1,71 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory_test
+
+import (
+	"fmt"
+	"github.com/echovault/echovault/internal/memory"
+	"github.com/echovault/echovault/internal/modules/set"
+	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
+	"testing"
+)
+
+func Test_Usage(t *testing.T) {
+	list := make([]interface{}, 1000)
+	hash := make(map[string]interface{}, 1000)
+	members := make([]string, 1000)
+	zmembers := make([]sorted_set.MemberParam, 1000)
+	s := stream.NewStream()
+	for i := 0; i < 1000; i++ {
+		value := fmt.Sprintf("value%04d", i)
+		list[i] = value
+		hash[fmt.Sprintf("field%04d", i)] = value
+		members[i] = value
+		zmembers[i] = sorted_set.MemberParam{Value: sorted_set.Value(value), Score: sorted_set.Score(i)}
+		if err := s.Add(stream.ID{Ms: uint64(i + 1)}, []string{"field", value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "list", value: list},
+		{name: "hash", value: hash},
+		{name: "set", value: set.NewSet(members)},
+		{name: "sorted set", value: sorted_set.NewSortedSet(zmembers)},
+		{name: "stream", value: s},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The elements all have the same size, so the estimate from a sample should match the exact usage.
+			exact := memory.Usage(test.value, 0)
+			sampled := memory.Usage(test.value, memory.DefaultSamples)
+			if exact != sampled {
+				t.Errorf("expected sampled usage to be %d, got %d", exact, sampled)
+			}
+			if exact < 1000*int64(len("value0000")) {
+				t.Errorf("expected usage to include the elements, got %d", exact)
+			}
+		})
+	}
+
+	if a, b := memory.KeyUsage("key", "value", 0), memory.KeyUsage("key", "value-value", 0); b-a != 6 {
+		t.Errorf("expected usage to grow with the length of the value, got %d and %d", a, b)
+	}
+}
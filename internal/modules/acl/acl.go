@@ -16,12 +16,12 @@ package acl
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/clock"
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/constants"
 	"github.com/gobwas/glob"
@@ -48,6 +48,8 @@ type ACL struct {
 	Connections  map[*net.Conn]Connection // Connections to the echovault that are currently registered with the ACL module
 	Config       config.Config            // EchoVault configuration that contains the relevant ACL config options
 	GlobPatterns map[string]glob.Glob
+	Log          *Log                       // The latest denied commands and failed authentications, listed by ACL LOG.
+	CertUsers    []internal.CertUserMapping // Maps verified mTLS client certificates to users, in order of precedence.
 }
 
 func loadUsersFromConfigFile(users []*User, filePath string) {
@@ -102,6 +104,9 @@ func NewACL(config config.Config) *ACL {
 				PasswordValue: config.Password,
 			},
 		}
+		if err := defaultUser.HashPlaintextPasswords(config.AclPasswordHash); err != nil {
+			log.Printf("hash default user password: %v\n", err)
+		}
 	}
 
 	// 2. Read and parse the ACL config file
@@ -119,9 +124,23 @@ func NewACL(config config.Config) *ACL {
 		users = append([]*User{defaultUser}, users...)
 	}
 
-	// 4. Normalise all users
+	// 4. Normalise all users and hash their plaintext passwords
 	for _, user := range users {
 		user.Normalise()
+		if err := user.HashPlaintextPasswords(config.AclPasswordHash); err != nil {
+			log.Printf("hash password of user %s: %v\n", user.Username, err)
+		}
+	}
+
+	// 5. Parse the mTLS client certificate mappings
+	var certUsers []internal.CertUserMapping
+	for _, mapping := range config.MTLSUsers {
+		m, err := internal.ParseCertUserMapping(mapping)
+		if err != nil {
+			log.Printf("mtls user mapping: %v\n", err)
+			continue
+		}
+		certUsers = append(certUsers, m)
 	}
 
 	acl := ACL{
@@ -130,6 +149,8 @@ func NewACL(config config.Config) *ACL {
 		Connections:  make(map[*net.Conn]Connection),
 		Config:       config,
 		GlobPatterns: make(map[string]glob.Glob),
+		Log:          NewLog(clock.NewClock()),
+		CertUsers:    certUsers,
 	}
 
 	acl.CompileGlobs()
@@ -150,9 +171,53 @@ func (acl *ACL) RegisterConnection(conn *net.Conn) {
 		Authenticated: defaultUser.NoPassword,
 		User:          defaultUser,
 	}
+
+	// Connections with a verified client certificate that is mapped to a user are authenticated as that user.
+	if user := acl.certUser(conn); user != nil {
+		acl.Connections[conn] = Connection{
+			Authenticated: true,
+			User:          user,
+		}
+	}
+}
+
+// certUser returns the enabled user that the connection's verified client certificate is mapped to.
+// Returns nil if the connection is not an mTLS connection, or if the certificate is not mapped to an enabled user.
+// The TLS handshake must be complete before the connection is registered.
+func (acl *ACL) certUser(conn *net.Conn) *User {
+	tlsConn, ok := (*conn).(*tls.Conn)
+	if !ok || len(acl.CertUsers) == 0 {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	if !state.HandshakeComplete || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+	// The first certificate of a verified chain is the client's certificate.
+	cert := state.VerifiedChains[0][0]
+	for _, mapping := range acl.CertUsers {
+		if !mapping.Matches(cert) {
+			continue
+		}
+		idx := slices.IndexFunc(acl.Users, func(user *User) bool {
+			return user.Username == mapping.Username
+		})
+		if idx == -1 || !acl.Users[idx].Enabled {
+			acl.Log.Add(conn, LogReasonAuth, "", "mtls", mapping.Username)
+			return nil
+		}
+		return acl.Users[idx]
+	}
+	return nil
 }
 
 func (acl *ACL) SetUser(cmd []string) error {
+	// Plaintext passwords are hashed before the users lock is taken, because hashing is slow by design.
+	cmd, err := HashPasswordRules(acl.Config.AclPasswordHash, cmd)
+	if err != nil {
+		return err
+	}
+
 	acl.LockUsers()
 	defer acl.UnlockUsers()
 
@@ -218,83 +283,114 @@ func (acl *ACL) DeleteUser(_ context.Context, usernames []string) error {
 	return nil
 }
 
+// AuthenticateConnection authenticates the connection as the user with the password in the AUTH command.
+// The password is verified without holding the users lock, because bcrypt and argon2id are slow by design.
 func (acl *ACL) AuthenticateConnection(_ context.Context, conn *net.Conn, cmd []string) error {
-	var passwords []Password
-	var user *User
-
-	if len(cmd) == 2 {
-		// Process AUTH <password>
-		h := sha256.New()
-		h.Write([]byte(cmd[1]))
-		passwords = []Password{
-			{PasswordType: PasswordPlainText, PasswordValue: cmd[1]},
-			{PasswordType: PasswordSHA256, PasswordValue: hex.EncodeToString(h.Sum(nil))},
-		}
-		// Authenticate with default user
-		idx := slices.IndexFunc(acl.Users, func(user *User) bool {
-			return user.Username == "default"
-		})
-		user = acl.Users[idx]
-	}
-
+	password := cmd[len(cmd)-1]
+	username := "default" // AUTH <password> authenticates the default user.
 	if len(cmd) == 3 {
 		// Process AUTH <username> <password>
-		h := sha256.New()
-		h.Write([]byte(cmd[2]))
-		passwords = []Password{
-			{PasswordType: PasswordPlainText, PasswordValue: cmd[2]},
-			{PasswordType: PasswordSHA256, PasswordValue: hex.EncodeToString(h.Sum(nil))},
-		}
-		// Find user with the specified username
-		userFound := false
-		for _, u := range acl.Users {
-			if u.Username == cmd[1] {
-				user = u
-				userFound = true
-				break
-			}
-		}
-		if !userFound {
-			return fmt.Errorf("no user with username %s", cmd[1])
-		}
+		username = cmd[1]
 	}
 
-	// If user is not enabled, return error
-	if !user.Enabled {
-		return fmt.Errorf("user %s is disabled", user.Username)
+	// Copy the user's details, so that they can be checked after the lock is released.
+	acl.RLockUsers()
+	idx := slices.IndexFunc(acl.Users, func(user *User) bool {
+		return user.Username == username
+	})
+	if idx == -1 {
+		acl.RUnlockUsers()
+		acl.Log.Add(conn, LogReasonAuth, "", strings.ToLower(cmd[0]), username)
+		return fmt.Errorf("no user with username %s", username)
 	}
+	user := acl.Users[idx]
+	enabled, noPassword, passwords := user.Enabled, user.NoPassword, slices.Clone(user.Passwords)
+	acl.RUnlockUsers()
 
-	// If user is set to NoPassword, then immediately authenticate connection without considering the password
-	if user.NoPassword {
-		acl.Connections[conn] = Connection{
-			Authenticated: true,
-			User:          user,
-		}
-		return nil
+	// If user is not enabled, return error
+	if !enabled {
+		acl.Log.Add(conn, LogReasonAuth, "", strings.ToLower(cmd[0]), username)
+		return fmt.Errorf("user %s is disabled", username)
 	}
 
-	for _, userPassword := range user.Passwords {
-		for _, password := range passwords {
-			if userPassword.PasswordType == password.PasswordType &&
-				userPassword.PasswordValue == password.PasswordValue &&
-				user.Enabled {
-				// Set the current connection to the selected user and set them as authenticated.
-				acl.Connections[conn] = Connection{
-					Authenticated: true,
-					User:          user,
-				}
-				return nil
+	// If user is set to NoPassword, then authenticate connection without considering the password
+	if !noPassword {
+		i := slices.IndexFunc(passwords, func(userPassword Password) bool {
+			return userPassword.Verify(password)
+		})
+		if i == -1 {
+			acl.Log.Add(conn, LogReasonAuth, "", strings.ToLower(cmd[0]), username)
+			return errors.New("could not authenticate user")
+		}
+		// The plaintext is known now, so a SHA256 password is upgraded to a strong hash.
+		if !passwords[i].IsStrongHash() {
+			if upgraded, err := HashPassword(acl.Config.AclPasswordHash, password); err != nil {
+				log.Printf("upgrade password hash: %v\n", err)
+			} else {
+				acl.upgradePassword(user, passwords[i], upgraded)
 			}
 		}
 	}
 
-	return errors.New("could not authenticate user")
+	// Set the current connection to the selected user and set them as authenticated.
+	acl.LockUsers()
+	defer acl.UnlockUsers()
+	acl.Connections[conn] = Connection{
+		Authenticated: true,
+		User:          user,
+	}
+	return nil
+}
+
+// upgradePassword replaces the user's password with the upgraded one,
+// unless the password was changed or removed while it was being verified.
+func (acl *ACL) upgradePassword(user *User, password Password, upgraded Password) {
+	acl.LockUsers()
+	defer acl.UnlockUsers()
+	if i := slices.Index(user.Passwords, password); i != -1 {
+		user.Passwords[i] = upgraded
+	}
 }
 
 func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command internal.Command, subCommand internal.SubCommand) error {
 	acl.RLockUsers()
 	defer acl.RUnlockUsers()
+	return acl.authorize(conn, acl.Connections[conn], cmd, command, subCommand)
+}
+
+// ConnectionUser returns the name of the user the connection is associated with.
+func (acl *ACL) ConnectionUser(conn *net.Conn) string {
+	acl.RLockUsers()
+	defer acl.RUnlockUsers()
+	if connection, ok := acl.Connections[conn]; ok && connection.User != nil {
+		return connection.User.Username
+	}
+	return ""
+}
+
+// AuthorizeUser authorizes a command that was run by the user on a connection of another node of the cluster,
+// which already authenticated the connection.
+func (acl *ACL) AuthorizeUser(username string, cmd []string, command internal.Command, subCommand internal.SubCommand) error {
+	acl.RLockUsers()
+	defer acl.RUnlockUsers()
+
+	if !acl.Config.RequirePass {
+		return nil
+	}
+
+	idx := slices.IndexFunc(acl.Users, func(user *User) bool {
+		return user.Username == username
+	})
+	if idx == -1 || !acl.Users[idx].Enabled {
+		return fmt.Errorf("user %s does not exist or is disabled", username)
+	}
 
+	return acl.authorize(nil, Connection{Authenticated: true, User: acl.Users[idx]}, cmd, command, subCommand)
+}
+
+// authorize checks the permissions of the connection's user to run the command.
+// The caller must hold the users read lock.
+func (acl *ACL) authorize(conn *net.Conn, connection Connection, cmd []string, command internal.Command, subCommand internal.SubCommand) error {
 	// Extract command, categories, and keys
 	comm := command.Command
 	categories := command.Categories
@@ -332,8 +428,11 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 		return nil
 	}
 
-	// Get current connection ACL details
-	connection := acl.Connections[conn]
+	// Allow 'hello' as it can be used to authenticate the connection with HELLO AUTH.
+	// The HELLO handler rejects unauthenticated connections that do not provide credentials.
+	if strings.EqualFold(comm, "hello") {
+		return nil
+	}
 
 	// If password is not required, allow the connection
 	if !acl.Config.RequirePass {
@@ -346,6 +445,7 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 	}
 
 	var notAllowed []string
+	var deniedKeys []string // The keys in notAllowed, the first one is recorded in the ACL log.
 
 	// 2. Check if all categories are in IncludedCategories
 	count := make(map[string]int, len(categories))
@@ -360,6 +460,7 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 		}
 		notAllowed = getUnauthorized(count, "@")
 		if len(notAllowed) > 0 {
+			acl.Log.Add(conn, LogReasonCommand, comm, comm, connection.User.Username)
 			return fmt.Errorf("unauthorized access to the following categories: %+v", notAllowed)
 		}
 	}
@@ -374,6 +475,7 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 			return false
 		})
 	}) {
+		acl.Log.Add(conn, LogReasonCommand, comm, comm, connection.User.Username)
 		return fmt.Errorf("unauthorized access to the following categories: %+v", notAllowed)
 	}
 
@@ -381,6 +483,7 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 	if !slices.ContainsFunc(connection.User.IncludedCommands, func(includedCommand string) bool {
 		return includedCommand == "*" || includedCommand == comm
 	}) {
+		acl.Log.Add(conn, LogReasonCommand, comm, comm, connection.User.Username)
 		return fmt.Errorf("not authorised to run %s command", strings.ToUpper(comm))
 	}
 
@@ -388,6 +491,7 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 	if slices.ContainsFunc(connection.User.ExcludedCommands, func(excludedCommand string) bool {
 		return excludedCommand == "*" || excludedCommand == comm
 	}) {
+		acl.Log.Add(conn, LogReasonCommand, comm, comm, connection.User.Username)
 		return fmt.Errorf("not authorised to run %s command", strings.ToUpper(comm))
 	}
 
@@ -399,12 +503,14 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 			if !slices.ContainsFunc(connection.User.IncludedPubSubChannels, func(includedChannelGlob string) bool {
 				return acl.GlobPatterns[includedChannelGlob].Match(channel)
 			}) {
+				acl.Log.Add(conn, LogReasonChannel, channel, comm, connection.User.Username)
 				return fmt.Errorf("not authorised to access channel &%s", channel)
 			}
 			// 2.2) Check if the channel is in ExcludedPubSubChannels
 			if slices.ContainsFunc(connection.User.ExcludedPubSubChannels, func(excludedChannelGlob string) bool {
 				return acl.GlobPatterns[excludedChannelGlob].Match(channel)
 			}) {
+				acl.Log.Add(conn, LogReasonChannel, channel, comm, connection.User.Username)
 				return fmt.Errorf("not authorised to access channel &%s", channel)
 			}
 		}
@@ -414,6 +520,7 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 	if len(append(readKeys, writeKeys...)) > 0 {
 		// 7. Check if nokeys is true
 		if connection.User.NoKeys {
+			acl.Log.Add(conn, LogReasonKey, append(readKeys, writeKeys...)[0], comm, connection.User.Username)
 			return errors.New("not authorised to access any keys")
 		}
 
@@ -425,11 +532,13 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 				}
 				if !slices.Contains(notAllowed, fmt.Sprintf("%s~%s", "%R", key)) {
 					notAllowed = append(notAllowed, fmt.Sprintf("%s~%s", "%R", key))
+					deniedKeys = append(deniedKeys, key)
 				}
 				return false
 			})
 		}) {
 			if len(notAllowed) > 0 {
+				acl.Log.Add(conn, LogReasonKey, deniedKeys[0], comm, connection.User.Username)
 				return fmt.Errorf("not authorised to access the following keys: %+v", notAllowed)
 			}
 		}
@@ -442,10 +551,16 @@ func (acl *ACL) AuthorizeConnection(conn *net.Conn, cmd []string, command intern
 				}
 				if !slices.Contains(notAllowed, fmt.Sprintf("%s~%s", "%W", key)) {
 					notAllowed = append(notAllowed, fmt.Sprintf("%s~%s", "%W", key))
+					deniedKeys = append(deniedKeys, key)
 				}
 				return false
 			})
 		}) {
+			var key string
+			if len(deniedKeys) > 0 {
+				key = deniedKeys[0]
+			}
+			acl.Log.Add(conn, LogReasonKey, key, comm, connection.User.Username)
 			return fmt.Errorf("not authorised to access the following keys: %+v", notAllowed)
 		}
 	}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"path"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -36,9 +37,6 @@ func handleAuth(params internal.HandlerFuncParams) ([]byte, error) {
 	if !ok {
 		return nil, errors.New("could not load ACL")
 	}
-	acl.LockUsers()
-	defer acl.UnlockUsers()
-
 	if err := acl.AuthenticateConnection(params.Context, params.Connection, params.Command); err != nil {
 		return nil, err
 	}
@@ -305,13 +303,9 @@ func handleList(params internal.HandlerFuncParams) ([]byte, error) {
 			s += " nokeys"
 		}
 		// Passwords
+		// Passwords are hashed when the user is created or loaded, so only their hashes are listed.
 		for _, password := range user.Passwords {
-			if strings.EqualFold(password.PasswordType, "plaintext") {
-				s += fmt.Sprintf(" >%s", password.PasswordValue)
-			}
-			if strings.EqualFold(password.PasswordType, "SHA256") {
-				s += fmt.Sprintf(" #%s", password.PasswordValue)
-			}
+			s += fmt.Sprintf(" #%s", password.PasswordValue)
 		}
 		// Included categories
 		for _, category := range user.IncludedCategories {
@@ -413,9 +407,12 @@ func handleLoad(params internal.HandlerFuncParams) ([]byte, error) {
 		}
 	}
 
-	// Normalise each user
+	// Normalise each user and hash their plaintext passwords
 	for _, user := range users {
 		user.Normalise()
+		if err := user.HashPlaintextPasswords(acl.Config.AclPasswordHash); err != nil {
+			return nil, err
+		}
 		// Traverse the list of users.
 		userFound := false
 		for _, u := range acl.Users {
@@ -449,8 +446,15 @@ func handleSave(params internal.HandlerFuncParams) ([]byte, error) {
 	if !ok {
 		return nil, errors.New("could not load ACL")
 	}
-	acl.RLockUsers()
-	defer acl.RUnlockUsers()
+	acl.LockUsers()
+	defer acl.UnlockUsers()
+
+	// Plaintext passwords are never written to the config file. They're hashed before the users are saved.
+	for _, user := range acl.Users {
+		if err := user.HashPlaintextPasswords(acl.Config.AclPasswordHash); err != nil {
+			return nil, err
+		}
+	}
 
 	f, err := os.OpenFile(acl.Config.AclConfig, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
@@ -494,6 +498,62 @@ func handleSave(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(constants.OkResponse), nil
 }
 
+func handleLog(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) > 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	acl, ok := params.GetACL().(*ACL)
+	if !ok {
+		return nil, errors.New("could not load ACL")
+	}
+
+	count := 10
+	if len(params.Command) == 3 {
+		if strings.EqualFold(params.Command[2], "reset") {
+			acl.Log.Reset()
+			return []byte(constants.OkResponse), nil
+		}
+		c, err := strconv.Atoi(params.Command[2])
+		if err != nil || c < 0 {
+			return nil, errors.New("count must be a positive integer or RESET")
+		}
+		count = c
+	}
+
+	now := params.GetClock().Now()
+	entries := acl.Log.Entries(count)
+	res := fmt.Sprintf("*%d\r\n", len(entries))
+	for _, entry := range entries {
+		age := strconv.FormatFloat(now.Sub(entry.CreatedAt).Seconds(), 'f', 3, 64)
+		fields := []struct {
+			name  string
+			value string
+		}{
+			{name: "count", value: fmt.Sprintf(":%d\r\n", entry.Count)},
+			{name: "reason", value: fmt.Sprintf("$%d\r\n%s\r\n", len(entry.Reason), entry.Reason)},
+			{name: "object", value: fmt.Sprintf("$%d\r\n%s\r\n", len(entry.Object), entry.Object)},
+			{name: "command", value: fmt.Sprintf("$%d\r\n%s\r\n", len(entry.Command), entry.Command)},
+			{name: "username", value: fmt.Sprintf("$%d\r\n%s\r\n", len(entry.Username), entry.Username)},
+			{name: "age-seconds", value: fmt.Sprintf("$%d\r\n%s\r\n", len(age), age)},
+			{name: "client-addr", value: fmt.Sprintf("$%d\r\n%s\r\n", len(entry.ClientAddr), entry.ClientAddr)},
+			{name: "entry-id", value: fmt.Sprintf(":%d\r\n", entry.ID)},
+			{name: "timestamp-created", value: fmt.Sprintf(":%d\r\n", entry.CreatedAt.UnixMilli())},
+			{name: "timestamp-last-updated", value: fmt.Sprintf(":%d\r\n", entry.UpdatedAt.UnixMilli())},
+		}
+		// RESP3 connections receive a map for each entry, RESP2 connections receive a flat array of name-value pairs.
+		if params.Protocol == constants.RESP3Protocol {
+			res += fmt.Sprintf("%%%d\r\n", len(fields))
+		} else {
+			res += fmt.Sprintf("*%d\r\n", len(fields)*2)
+		}
+		for _, field := range fields {
+			res += fmt.Sprintf("$%d\r\n%s\r\n%s", len(field.name), field.name, field.value)
+		}
+	}
+
+	return []byte(res), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -667,6 +727,22 @@ When 'REPLACE' is passed, users from config file who share a username with users
 					},
 					HandlerFunc: handleSave,
 				},
+				{
+					Command:    "log",
+					Module:     constants.ACLModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(ACL LOG [count | RESET]) Lists the latest denied commands and failed authentications,
+starting with the most recent. Up to 10 entries are listed unless count is provided. RESET clears the log.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels:  make([]string, 0),
+							ReadKeys:  make([]string, 0),
+							WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleLog,
+				},
 			},
 		},
 	}
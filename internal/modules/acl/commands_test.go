@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/echovault"
 	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/clock"
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/modules/acl"
 	"github.com/tidwall/resp"
 	"os"
 	"path"
@@ -156,6 +158,23 @@ func generateSHA256Password(plain string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// maskPasswordHashes replaces the salted password hashes in the ACL LIST rules of a user with the hash type,
+// as the hashes are different each time a password is hashed.
+func maskPasswordHashes(rules []string) []string {
+	masked := make([]string, len(rules))
+	for i, rule := range rules {
+		switch {
+		case strings.HasPrefix(rule, "#$2"):
+			masked[i] = "#<bcrypt>"
+		case strings.HasPrefix(rule, "#$argon2id$"):
+			masked[i] = "#<argon2id>"
+		default:
+			masked[i] = rule
+		}
+	}
+	return masked
+}
+
 func Test_ACL(t *testing.T) {
 	port, err := internal.GetFreePort()
 	if err != nil {
@@ -305,6 +324,76 @@ func Test_ACL(t *testing.T) {
 		}
 	})
 
+	t.Run("Test_HandleConcurrentAuth", func(t *testing.T) {
+		t.Parallel()
+
+		// The passwords are verified without holding the users lock, so concurrent AUTH commands must each
+		// see a consistent user, including while the plaintext password is upgraded to a strong hash.
+		if _, err := mockServer.ACLSetUser(echovault.User{
+			Username:          "concurrent_auth_user",
+			Enabled:           true,
+			IncludeCategories: []string{"*"},
+			IncludeCommands:   []string{"*"},
+			AddPlainPasswords: []string{"password6"},
+			AddHashPasswords:  []string{generateSHA256Password("password7")},
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		passwords := []string{"password6", "password7", "wrong_password"}
+		var wg sync.WaitGroup
+		for i := 0; i < 12; i++ {
+			wg.Add(1)
+			go func(password string) {
+				defer wg.Done()
+				conn, err := internal.GetConnection("localhost", port)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer func() {
+					_ = conn.Close()
+				}()
+				r := resp.NewConn(conn)
+				if err = r.WriteArray([]resp.Value{
+					resp.StringValue("AUTH"),
+					resp.StringValue("concurrent_auth_user"),
+					resp.StringValue(password),
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+				rv, _, err := r.ReadValue()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if password == "wrong_password" {
+					if rv.Error() == nil || rv.Error().Error() != "Error could not authenticate user" {
+						t.Errorf("expected AUTH with the wrong password to fail, got \"%s\"", rv.String())
+					}
+					return
+				}
+				if rv.String() != "OK" {
+					t.Errorf("expected AUTH with %s to return \"OK\", got \"%s\"", password, rv.String())
+					return
+				}
+				// The connection is authenticated as the user.
+				if err = r.WriteArray([]resp.Value{resp.StringValue("ACL"), resp.StringValue("WHOAMI")}); err != nil {
+					t.Error(err)
+					return
+				}
+				if rv, _, err = r.ReadValue(); err != nil {
+					t.Error(err)
+				} else if rv.String() != "concurrent_auth_user" {
+					t.Errorf("expected ACL WHOAMI to return \"concurrent_auth_user\", got \"%s\"", rv.String())
+				}
+			}(passwords[i%len(passwords)])
+		}
+		wg.Wait()
+	})
+
 	t.Run("Test_Permissions", func(t *testing.T) {
 		port, err := internal.GetFreePort()
 		if err != nil {
@@ -1769,14 +1858,14 @@ func Test_ACL(t *testing.T) {
 				cmd: []resp.Value{resp.StringValue("ACL"), resp.StringValue("LIST")},
 				wantRes: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
-					fmt.Sprintf(`list_user_1 on >list_user_password_1 #%s +@write +@read +@pubsub -@admin -@connection -@dangerous +acl|setuser +acl|getuser +acl|deluser -rewriteaof -save -acl|load -acl|save %s +&channel1 +&channel2 -&channel3 -&channel4`,
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
+					fmt.Sprintf(`list_user_1 on #<bcrypt> #%s +@write +@read +@pubsub -@admin -@connection -@dangerous +acl|setuser +acl|getuser +acl|deluser -rewriteaof -save -acl|load -acl|save %s +&channel1 +&channel2 -&channel3 -&channel4`,
 						generateSHA256Password("list_user_password_2"), "%RW~key1 %RW~key2 %R~key3 %R~key4 %W~key5 %W~key6"),
 					fmt.Sprintf(`list_user_2 on nopass nokeys +@write +@read +@pubsub -@admin -@connection -@dangerous +acl|setuser +acl|getuser +acl|deluser -rewriteaof -save -acl|load -acl|save +&channel1 +&channel2 -&channel3 -&channel4`),
-					fmt.Sprintf(`list_user_3 on >list_user_password_3 #%s +@write +@read +@pubsub -@admin -@connection -@dangerous +acl|setuser +acl|getuser +acl|deluser -rewriteaof -save -acl|load -acl|save %s +&channel1 +&channel2 -&channel3 -&channel4`,
+					fmt.Sprintf(`list_user_3 on #<bcrypt> #%s +@write +@read +@pubsub -@admin -@connection -@dangerous +acl|setuser +acl|getuser +acl|deluser -rewriteaof -save -acl|load -acl|save %s +&channel1 +&channel2 -&channel3 -&channel4`,
 						generateSHA256Password("list_user_password_4"), "%RW~key1 %RW~key2 %R~key3 %R~key4 %W~key5 %W~key6"),
 				},
 				wantErr: "",
@@ -1823,7 +1912,7 @@ func Test_ACL(t *testing.T) {
 
 				var resStr []string
 				for i := 0; i < len(resArr); i++ {
-					resStr = strings.Split(resArr[i].String(), " ")
+					resStr = maskPasswordHashes(strings.Split(resArr[i].String(), " "))
 					if !slices.ContainsFunc(test.wantRes, func(s string) bool {
 						expectedUserSlice := strings.Split(s, " ")
 						return compareSlices(resStr, expectedUserSlice) == nil
@@ -1851,10 +1940,10 @@ func Test_ACL(t *testing.T) {
 				path: path.Join(baseDir, "json_test.json"),
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 				},
 			},
 			{
@@ -1862,10 +1951,10 @@ func Test_ACL(t *testing.T) {
 				path: path.Join(baseDir, "yaml_test.yaml"),
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 				},
 			},
 			{
@@ -1873,10 +1962,10 @@ func Test_ACL(t *testing.T) {
 				path: path.Join(baseDir, "yml_test.yml"),
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 				},
 			},
 		}
@@ -1938,6 +2027,18 @@ func Test_ACL(t *testing.T) {
 					return
 				}
 
+				// The saved config must not contain any plaintext passwords.
+				b, err := os.ReadFile(test.path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for _, plaintext := range []string{acl.PasswordPlainText, "password2", "password5"} {
+					if strings.Contains(string(b), plaintext) {
+						t.Errorf("expected saved ACL config not to contain \"%s\"", plaintext)
+					}
+				}
+
 				// Close client connection
 				if err = conn.Close(); err != nil {
 					t.Error(err)
@@ -1989,7 +2090,7 @@ func Test_ACL(t *testing.T) {
 
 				var resStr []string
 				for i := 0; i < len(resArr); i++ {
-					resStr = strings.Split(resArr[i].String(), " ")
+					resStr = maskPasswordHashes(strings.Split(resArr[i].String(), " "))
 					if !slices.ContainsFunc(test.want, func(s string) bool {
 						expectedUserSlice := strings.Split(s, " ")
 						return compareSlices(resStr, expectedUserSlice) == nil
@@ -2023,10 +2124,10 @@ func Test_ACL(t *testing.T) {
 				cmd: []resp.Value{resp.StringValue("ACL"), resp.StringValue("LOAD"), resp.StringValue("REPLACE")},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 on +@all +all %RW~* +&*",
 				},
 			},
@@ -2039,10 +2140,10 @@ func Test_ACL(t *testing.T) {
 				cmd: []resp.Value{resp.StringValue("ACL"), resp.StringValue("LOAD"), resp.StringValue("REPLACE")},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 on +@all +all %RW~* +&*",
 				},
 			},
@@ -2055,10 +2156,10 @@ func Test_ACL(t *testing.T) {
 				cmd: []resp.Value{resp.StringValue("ACL"), resp.StringValue("LOAD"), resp.StringValue("REPLACE")},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 on +@all +all %RW~* +&*",
 				},
 			},
@@ -2083,10 +2184,10 @@ func Test_ACL(t *testing.T) {
 				cmd: []resp.Value{resp.StringValue("ACL"), resp.StringValue("LOAD"), resp.StringValue("MERGE")},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf(`with_password_user on >password2 >password3 >password4 #%s +@all +all %s~key1 %s~key2 %s~key5 %s~key6 %s~key3 %s~key4 +&channel[12] -&channel[34]`,
+					fmt.Sprintf(`with_password_user on #<bcrypt> #<bcrypt> #<bcrypt> #%s +@all +all %s~key1 %s~key2 %s~key5 %s~key6 %s~key3 %s~key4 +&channel[12] -&channel[34]`,
 						generateSHA256Password("password3"), "%RW", "%RW", "%R", "%R", "%W", "%W"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 off +@all +all %RW~* +&*",
 				},
 			},
@@ -2111,10 +2212,10 @@ func Test_ACL(t *testing.T) {
 				cmd: []resp.Value{resp.StringValue("ACL"), resp.StringValue("LOAD"), resp.StringValue("REPLACE")},
 				want: []string{
 					"default on +@all +all %RW~* +&*",
-					fmt.Sprintf("with_password_user on >password2 #%s +@all +all %s~* +&*",
+					fmt.Sprintf("with_password_user on #<bcrypt> #%s +@all +all %s~* +&*",
 						generateSHA256Password("password3"), "%RW"),
 					"no_password_user on nopass +@all +all %RW~* +&*",
-					"disabled_user off >password5 +@all +all %RW~* +&*",
+					"disabled_user off #<bcrypt> +@all +all %RW~* +&*",
 					"user1 off +@all +all %RW~* +&*",
 				},
 			},
@@ -2213,7 +2314,8 @@ func Test_ACL(t *testing.T) {
 
 				var resStr []string
 				for i := 0; i < len(resArr); i++ {
-					resStr = strings.Split(resArr[i].String(), " ")
+					// The plaintext passwords are hashed when the users are saved before they're loaded.
+					resStr = maskPasswordHashes(strings.Split(resArr[i].String(), " "))
 					if !slices.ContainsFunc(test.want, func(s string) bool {
 						expectedUserSlice := strings.Split(s, " ")
 						return compareSlices(resStr, expectedUserSlice) == nil
@@ -2225,4 +2327,276 @@ func Test_ACL(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandlePasswordHashes", func(t *testing.T) {
+		t.Parallel()
+
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mockServer, err := setUpServer(port, false, "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		go func() {
+			mockServer.Start()
+		}()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Cleanup(func() {
+			_ = conn.Close()
+			mockServer.ShutDown()
+		})
+		client := resp.NewConn(conn)
+
+		send := func(cmd ...string) resp.Value {
+			values := make([]resp.Value, len(cmd))
+			for i, c := range cmd {
+				values[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(values); err != nil {
+				t.Fatal(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res
+		}
+		// passwords returns the password rules of the user from ACL LIST.
+		passwords := func(username string) []string {
+			var rules []string
+			for _, user := range send("ACL", "LIST").Array() {
+				fields := strings.Split(user.String(), " ")
+				if fields[0] != username {
+					continue
+				}
+				for _, field := range fields {
+					if strings.HasPrefix(field, ">") || strings.HasPrefix(field, "#") {
+						rules = append(rules, field)
+					}
+				}
+			}
+			return rules
+		}
+
+		bcryptHash, err := acl.HashPassword(acl.PasswordBcrypt, "bcrypt_password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		argon2idHash, err := acl.HashPassword(acl.PasswordArgon2id, "argon2id_password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Pre-hashed passwords are stored as they are.
+		if res := send("ACL", "SETUSER", "hash_user", "on", "+@all", "allKeys",
+			"#"+bcryptHash.PasswordValue, "#"+argon2idHash.PasswordValue); res.Error() != nil {
+			t.Fatal(res.Error())
+		}
+		want := []string{"#" + bcryptHash.PasswordValue, "#" + argon2idHash.PasswordValue}
+		if err = compareSlices(passwords("hash_user"), want); err != nil {
+			t.Error(err)
+		}
+		for _, password := range []string{"bcrypt_password", "argon2id_password"} {
+			if res := send("AUTH", "hash_user", password); res.String() != "OK" {
+				t.Errorf("expected AUTH with %s to succeed, got %v", password, res)
+			}
+		}
+		if res := send("AUTH", "hash_user", "wrong_password"); res.Error() == nil {
+			t.Error("expected AUTH with the wrong password to fail")
+		}
+		if err = compareSlices(passwords("hash_user"), want); err != nil {
+			t.Errorf("expected the hashed passwords not to change after AUTH: %v", err)
+		}
+
+		// Invalid hashes are rejected.
+		if res := send("ACL", "SETUSER", "hash_user", "#$argon2id$v=19$m=0,t=3,p=4$salt$hash"); res.Error() == nil {
+			t.Error("expected SETUSER with an invalid argon2id hash to fail")
+		}
+		if res := send("ACL", "SETUSER", "hash_user", "#$2a$1$invalid"); res.Error() == nil {
+			t.Error("expected SETUSER with an invalid bcrypt hash to fail")
+		}
+
+		// Plaintext passwords are hashed when they're set. SHA256 passwords are upgraded to bcrypt on successful AUTH.
+		if res := send("ACL", "SETUSER", "upgrade_user", "on", "+@all", "allKeys",
+			">plain_password", "#"+generateSHA256Password("sha256_password")); res.Error() != nil {
+			t.Fatal(res.Error())
+		}
+		if res := send("AUTH", "upgrade_user", "wrong_password"); res.Error() == nil {
+			t.Error("expected AUTH with the wrong password to fail")
+		}
+		if err = compareSlices(maskPasswordHashes(passwords("upgrade_user")),
+			[]string{"#<bcrypt>", "#" + generateSHA256Password("sha256_password")}); err != nil {
+			t.Errorf("expected the passwords not to change after a failed AUTH: %v", err)
+		}
+		for _, password := range []string{"plain_password", "sha256_password", "plain_password", "sha256_password"} {
+			if res := send("AUTH", "upgrade_user", password); res.String() != "OK" {
+				t.Errorf("expected AUTH with %s to succeed, got %v", password, res)
+			}
+		}
+		if err = compareSlices(maskPasswordHashes(passwords("upgrade_user")),
+			[]string{"#<bcrypt>", "#<bcrypt>"}); err != nil {
+			t.Errorf("expected the passwords to be upgraded to bcrypt: %v", err)
+		}
+
+		// Removing a plaintext password removes its upgraded hash.
+		if res := send("ACL", "SETUSER", "upgrade_user", "<plain_password"); res.Error() != nil {
+			t.Fatal(res.Error())
+		}
+		if res := send("AUTH", "upgrade_user", "plain_password"); res.Error() == nil {
+			t.Error("expected AUTH with the removed password to fail")
+		}
+		if res := send("AUTH", "upgrade_user", "sha256_password"); res.String() != "OK" {
+			t.Errorf("expected AUTH with sha256_password to succeed, got %v", res)
+		}
+	})
+
+	t.Run("Test_HandleLog", func(t *testing.T) {
+		t.Parallel()
+
+		port, err := internal.GetFreePort()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mockServer, err := setUpServer(port, true, "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		go func() {
+			mockServer.Start()
+		}()
+		if _, err = mockServer.ACLSetUser(echovault.User{
+			Username:             "log_user",
+			Enabled:              true,
+			AddPlainPasswords:    []string{"log_user_password"},
+			IncludeCategories:    []string{"*"},
+			IncludeCommands:      []string{"set", "get"},
+			IncludeReadWriteKeys: []string{"key1"},
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// The security events are triggered from one connection and listed from another.
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		adminConn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Cleanup(func() {
+			_ = conn.Close()
+			_ = adminConn.Close()
+			mockServer.ShutDown()
+		})
+		client, admin := resp.NewConn(conn), resp.NewConn(adminConn)
+
+		send := func(client *resp.Conn, cmd ...string) resp.Value {
+			values := make([]resp.Value, len(cmd))
+			for i, c := range cmd {
+				values[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(values); err != nil {
+				t.Fatal(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res
+		}
+
+		if res := send(client, "AUTH", "log_user", "wrong_password"); res.Error() == nil {
+			t.Error("expected AUTH with the wrong password to fail")
+		}
+		send(client, "AUTH", "log_user", "log_user_password")
+		for i := 0; i < 2; i++ {
+			if res := send(client, "HSET", "key1", "field1", "value1"); res.Error() == nil {
+				t.Error("expected HSET to be denied")
+			}
+		}
+		if res := send(client, "SET", "key2", "value1"); res.Error() == nil {
+			t.Error("expected SET on key2 to be denied")
+		}
+		send(admin, "AUTH", "password1")
+
+		entries := send(admin, "ACL", "LOG").Array()
+		want := []map[string]string{
+			{"reason": "key", "object": "key2", "command": "set", "username": "log_user", "count": "1"},
+			{"reason": "command", "object": "hset", "command": "hset", "username": "log_user", "count": "2"},
+			{"reason": "auth", "object": "", "command": "auth", "username": "log_user", "count": "1"},
+		}
+		if len(entries) != len(want) {
+			t.Errorf("expected %d entries, got %d", len(want), len(entries))
+			return
+		}
+		for i, entry := range entries {
+			fields := make(map[string]string)
+			for j := 0; j+1 < len(entry.Array()); j += 2 {
+				fields[entry.Array()[j].String()] = entry.Array()[j+1].String()
+			}
+			for name, value := range want[i] {
+				if fields[name] != value {
+					t.Errorf("expected %s of entry %d to be \"%s\", got \"%s\"", name, i, value, fields[name])
+				}
+			}
+			if fields["client-addr"] != conn.LocalAddr().String() {
+				t.Errorf("expected client-addr of entry %d to be %s, got %s", i, conn.LocalAddr(), fields["client-addr"])
+			}
+		}
+
+		if entries = send(admin, "ACL", "LOG", "1").Array(); len(entries) != 1 {
+			t.Errorf("expected 1 entry, got %d", len(entries))
+		}
+		if res := send(admin, "ACL", "LOG", "count"); res.Error() == nil ||
+			!strings.Contains(res.Error().Error(), "count must be a positive integer or RESET") {
+			t.Errorf("expected a count error, got %v", res)
+		}
+		if res := send(admin, "ACL", "LOG", "RESET"); res.String() != "OK" {
+			t.Errorf("expected OK, got %s", res.String())
+		}
+		if entries = send(admin, "ACL", "LOG").Array(); len(entries) != 0 {
+			t.Errorf("expected no entries after reset, got %d", len(entries))
+		}
+	})
+}
+
+func Test_Log(t *testing.T) {
+	log := acl.NewLog(clock.NewClock())
+
+	// Once the log is full, the oldest entries are dropped.
+	for i := 0; i < 130; i++ {
+		log.Add(nil, acl.LogReasonKey, fmt.Sprintf("key%d", i), "get", "default")
+	}
+	log.Add(nil, acl.LogReasonKey, "key129", "get", "default")
+
+	entries := log.Entries(-1)
+	if len(entries) != 128 {
+		t.Errorf("expected 128 entries, got %d", len(entries))
+		return
+	}
+	if entries[0].Object != "key129" || entries[0].Count != 2 || entries[0].ID != 129 {
+		t.Errorf("expected the latest entry to be key129 with ID 129 and count 2, got %+v", entries[0])
+	}
+	if entries[127].Object != "key2" {
+		t.Errorf("expected the oldest entry to be key2, got %s", entries[127].Object)
+	}
+
+	log.Reset()
+	if entries = log.Entries(-1); len(entries) != 0 {
+		t.Errorf("expected no entries after reset, got %d", len(entries))
+	}
 }
@@ -0,0 +1,139 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"cmp"
+	"github.com/echovault/echovault/internal/clock"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The reasons an event is recorded in the ACL log.
+const (
+	LogReasonAuth    = "auth"    // The connection failed to authenticate.
+	LogReasonCommand = "command" // The user is not allowed to run the command.
+	LogReasonKey     = "key"     // The user is not allowed to access a key of the command.
+	LogReasonChannel = "channel" // The user is not allowed to access a channel of the command.
+)
+
+// logMaxLength is the number of entries kept in the ACL log. The oldest entry is dropped to make room for a new one.
+const logMaxLength = 128
+
+// LogEntry is a security event recorded in the ACL log.
+// Repeated events from the same client are recorded in a single entry, and counted.
+type LogEntry struct {
+	ID         uint64    // The ID of the entry. Newer entries have higher IDs.
+	Count      int       // The number of times the event was repeated.
+	Reason     string    // Why the event was recorded, either auth, command, key or channel.
+	Object     string    // The command, key or channel that was denied. Empty for failed authentications.
+	Command    string    // The command that was denied, or that failed to authenticate the connection.
+	Username   string    // The user of the connection, or the user that the connection failed to authenticate as.
+	ClientAddr string    // The remote address of the connection.
+	CreatedAt  time.Time // The time of the first event.
+	UpdatedAt  time.Time // The time of the latest event.
+}
+
+// Log is a ring buffer of the latest ACL security events.
+type Log struct {
+	mutex   sync.Mutex
+	clock   clock.Clock
+	entries []*LogEntry // The entries in the order they were created, starting from next once the buffer is full.
+	next    int         // The index that the next entry is written to once the buffer is full.
+	nextID  uint64      // The ID of the next entry.
+}
+
+func NewLog(clock clock.Clock) *Log {
+	return &Log{
+		clock:   clock,
+		entries: make([]*LogEntry, 0, logMaxLength),
+	}
+}
+
+// Add records the event. If the same client was already denied the same object for the same reason,
+// the existing entry is updated instead.
+func (l *Log) Add(conn *net.Conn, reason string, object string, command string, username string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var clientAddr string
+	if conn != nil {
+		clientAddr = (*conn).RemoteAddr().String()
+	}
+	now := l.clock.Now()
+
+	for _, entry := range l.entries {
+		if entry.Reason == reason && entry.Object == object && entry.Command == command &&
+			entry.Username == username && entry.ClientAddr == clientAddr {
+			entry.Count += 1
+			entry.UpdatedAt = now
+			return
+		}
+	}
+
+	entry := &LogEntry{
+		ID:         l.nextID,
+		Count:      1,
+		Reason:     reason,
+		Object:     object,
+		Command:    command,
+		Username:   username,
+		ClientAddr: clientAddr,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	l.nextID += 1
+
+	if len(l.entries) < logMaxLength {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % logMaxLength
+}
+
+// Entries returns up to count of the latest entries, starting with the most recently updated one.
+// When count is negative, all the entries are returned.
+func (l *Log) Entries(count int) []LogEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entries := make([]LogEntry, len(l.entries))
+	for i, entry := range l.entries {
+		entries[i] = *entry
+	}
+	slices.SortFunc(entries, func(a, b LogEntry) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	if count >= 0 && count < len(entries) {
+		entries = entries[:count]
+	}
+	return entries
+}
+
+// Reset removes all the entries from the log.
+func (l *Log) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	clear(l.entries)
+	l.entries = l.entries[:0]
+	l.next = 0
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// The argon2id parameters used to hash new passwords. They follow the second recommended option of RFC 9106.
+// The parameters are encoded in each hash, so changing them does not affect the passwords that are already hashed.
+const (
+	argon2idTime    = 3
+	argon2idMemory  = 64 * 1024 // In KiB.
+	argon2idThreads = 4
+	argon2idKeyLen  = 32
+	argon2idSaltLen = 16
+)
+
+// argon2idParams holds the parameters and salt of an argon2id hash, decoded from the hash's PHC string
+// (e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>).
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	hash    []byte
+}
+
+// IsStrongHash returns true if the password is stored with a salted, slow hash.
+// Plaintext and SHA256 passwords are upgraded to a strong hash once they're used to authenticate.
+func (password Password) IsStrongHash() bool {
+	return password.PasswordType == PasswordBcrypt || password.PasswordType == PasswordArgon2id
+}
+
+// Verify returns true if the plaintext matches the password.
+func (password Password) Verify(plaintext string) bool {
+	switch password.PasswordType {
+	case PasswordPlainText:
+		return subtle.ConstantTimeCompare([]byte(password.PasswordValue), []byte(plaintext)) == 1
+	case PasswordSHA256:
+		h := sha256.Sum256([]byte(plaintext))
+		return subtle.ConstantTimeCompare(
+			[]byte(strings.ToLower(password.PasswordValue)), []byte(hex.EncodeToString(h[:]))) == 1
+	case PasswordBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(password.PasswordValue), []byte(plaintext)) == nil
+	case PasswordArgon2id:
+		params, err := decodeArgon2id(password.PasswordValue)
+		if err != nil {
+			return false
+		}
+		hash := argon2.IDKey([]byte(plaintext), params.salt, params.time, params.memory, params.threads,
+			uint32(len(params.hash)))
+		return subtle.ConstantTimeCompare(hash, params.hash) == 1
+	}
+	return false
+}
+
+// HashPassword hashes the plaintext with a random salt using the hash type, which is either bcrypt or argon2id.
+// bcrypt is used when the hash type is empty.
+func HashPassword(hashType string, plaintext string) (Password, error) {
+	switch hashType {
+	case "", PasswordBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.DefaultCost)
+		if err != nil {
+			return Password{}, err
+		}
+		return Password{PasswordType: PasswordBcrypt, PasswordValue: string(hash)}, nil
+	case PasswordArgon2id:
+		salt := make([]byte, argon2idSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return Password{}, err
+		}
+		hash := argon2.IDKey([]byte(plaintext), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+		return Password{
+			PasswordType: PasswordArgon2id,
+			PasswordValue: fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+				argon2.Version, argon2idMemory, argon2idTime, argon2idThreads,
+				base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)),
+		}, nil
+	}
+	return Password{}, fmt.Errorf("unsupported password hash %s", hashType)
+}
+
+// ValidatePasswordHash returns an error if a pre-hashed password can not be used for authentication.
+func ValidatePasswordHash(password Password) error {
+	switch password.PasswordType {
+	case PasswordBcrypt:
+		if _, err := bcrypt.Cost([]byte(password.PasswordValue)); err != nil {
+			return fmt.Errorf("invalid bcrypt password hash: %v", err)
+		}
+	case PasswordArgon2id:
+		if _, err := decodeArgon2id(password.PasswordValue); err != nil {
+			return fmt.Errorf("invalid argon2id password hash: %v", err)
+		}
+	}
+	return nil
+}
+
+func decodeArgon2id(encoded string) (argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordArgon2id {
+		return argon2idParams{}, errors.New("malformed hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return argon2idParams{}, fmt.Errorf("malformed version: %v", err)
+	}
+	if version != argon2.Version {
+		return argon2idParams{}, fmt.Errorf("unsupported version %d", version)
+	}
+
+	params := argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return argon2idParams{}, fmt.Errorf("malformed parameters: %v", err)
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return argon2idParams{}, errors.New("parameters must be positive")
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idParams{}, fmt.Errorf("malformed salt: %v", err)
+	}
+	if params.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return argon2idParams{}, fmt.Errorf("malformed hash: %v", err)
+	}
+	if len(params.hash) == 0 {
+		return argon2idParams{}, errors.New("empty hash")
+	}
+
+	return params, nil
+}
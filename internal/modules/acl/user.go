@@ -22,10 +22,13 @@ import (
 const (
 	PasswordPlainText = "plaintext"
 	PasswordSHA256    = "SHA256"
+	PasswordBcrypt    = "bcrypt"
+	PasswordArgon2id  = "argon2id"
 )
 
 type Password struct {
-	PasswordType  string `json:"PasswordType" yaml:"PasswordType"` // plaintext, SHA256
+	PasswordType string `json:"PasswordType" yaml:"PasswordType"` // plaintext, SHA256, bcrypt, argon2id
+	// The bcrypt and argon2id values are encoded hashes that hold the salt and the cost parameters of the password.
 	PasswordValue string `json:"PasswordValue" yaml:"PasswordValue"`
 }
 
@@ -92,6 +95,8 @@ func (user *User) Normalise() {
 		types := map[string]int{
 			PasswordPlainText: 0,
 			PasswordSHA256:    1,
+			PasswordBcrypt:    2,
+			PasswordArgon2id:  3,
 		}
 		return types[a.PasswordType] - types[b.PasswordType]
 	})
@@ -129,22 +134,27 @@ func (user *User) UpdateUser(cmd []string) error {
 		}
 		// Parse passwords
 		if str[0] == '>' || str[0] == '#' {
-			user.Passwords = append(user.Passwords, Password{
+			password := Password{
 				PasswordType:  GetPasswordType(str),
 				PasswordValue: str[1:],
-			})
+			}
+			if err := ValidatePasswordHash(password); err != nil {
+				return err
+			}
+			user.Passwords = append(user.Passwords, password)
 			user.NoPassword = false
 			continue
 		}
 		if str[0] == '<' {
+			// Plaintext passwords may have been upgraded to a hash, so every password that matches is removed.
 			user.Passwords = slices.DeleteFunc(user.Passwords, func(password Password) bool {
-				return strings.EqualFold(password.PasswordType, PasswordPlainText) && password.PasswordValue == str[1:]
+				return password.Verify(str[1:])
 			})
 			continue
 		}
 		if str[0] == '!' {
 			user.Passwords = slices.DeleteFunc(user.Passwords, func(password Password) bool {
-				return strings.EqualFold(password.PasswordType, PasswordSHA256) && password.PasswordValue == str[1:]
+				return password.PasswordType == GetPasswordType("#"+str[1:]) && password.PasswordValue == str[1:]
 			})
 			continue
 		}
@@ -319,9 +329,52 @@ func CreateUser(username string) *User {
 	}
 }
 
+// GetPasswordType returns the type of the password from its SETUSER rule.
+// Passwords prefixed with '>' are plaintext. Passwords prefixed with '#' are hashes: either a bcrypt hash
+// (e.g. #$2a$10$...), an argon2id hash in the PHC string format (e.g. #$argon2id$v=19$m=65536,t=3,p=4$...),
+// or a hex encoded SHA256 digest.
 func GetPasswordType(password string) string {
-	if password[0] == '#' {
+	if password[0] != '#' {
+		return PasswordPlainText
+	}
+	switch {
+	case strings.HasPrefix(password[1:], "$argon2id$"):
+		return PasswordArgon2id
+	case strings.HasPrefix(password[1:], "$2"):
+		return PasswordBcrypt
+	default:
 		return PasswordSHA256
 	}
-	return PasswordPlainText
+}
+
+// HashPasswordRules returns the SETUSER rules with the plaintext password rules ('>') replaced by hashed password
+// rules ('#') of the given hash type, so that plaintext passwords are never stored.
+func HashPasswordRules(hashType string, rules []string) ([]string, error) {
+	hashed := slices.Clone(rules)
+	for i, rule := range hashed {
+		if !strings.HasPrefix(rule, ">") {
+			continue
+		}
+		password, err := HashPassword(hashType, rule[1:])
+		if err != nil {
+			return nil, err
+		}
+		hashed[i] = "#" + password.PasswordValue
+	}
+	return hashed, nil
+}
+
+// HashPlaintextPasswords replaces the user's plaintext passwords with hashes of the given hash type.
+func (user *User) HashPlaintextPasswords(hashType string) error {
+	for i, password := range user.Passwords {
+		if password.PasswordType != PasswordPlainText {
+			continue
+		}
+		hashed, err := HashPassword(hashType, password.PasswordValue)
+		if err != nil {
+			return err
+		}
+		user.Passwords[i] = hashed
+	}
+	return nil
 }
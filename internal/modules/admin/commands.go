@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/memory"
 	"github.com/gobwas/glob"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -189,6 +191,135 @@ func handleCommandDocs(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte("*0\r\n"), nil
 }
 
+func handleMemoryUsage(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 3 && len(params.Command) != 5 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	key := params.Command[2]
+
+	samples := memory.DefaultSamples
+	if len(params.Command) == 5 {
+		if !strings.EqualFold(params.Command[3], "samples") {
+			return nil, errors.New("syntax error")
+		}
+		var err error
+		if samples, err = strconv.Atoi(params.Command[4]); err != nil || samples < 0 {
+			return nil, errors.New("value is not an integer or out of range")
+		}
+	}
+
+	value := params.GetValues(params.Context, []string{key})[key]
+	if value == nil {
+		return []byte("$-1\r\n"), nil
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", memory.KeyUsage(key, value, samples))), nil
+}
+
+func handleMemoryStats(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+
+	stats := params.GetMemoryStats()
+	var bytesPerKey int64
+	if stats.KeysCount > 0 {
+		bytesPerKey = stats.DatasetBytes / int64(stats.KeysCount)
+	}
+	var datasetPercentage float64
+	if stats.TotalAllocated > 0 {
+		datasetPercentage = float64(stats.DatasetBytes) / float64(stats.TotalAllocated) * 100
+	}
+	percentage := strconv.FormatFloat(datasetPercentage, 'f', 2, 64)
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{name: "total.allocated", value: fmt.Sprintf(":%d\r\n", stats.TotalAllocated)},
+		{name: "dataset.bytes", value: fmt.Sprintf(":%d\r\n", stats.DatasetBytes)},
+		{name: "dataset.peak.bytes", value: fmt.Sprintf(":%d\r\n", stats.PeakDatasetBytes)},
+		{name: "dataset.percentage", value: fmt.Sprintf("$%d\r\n%s\r\n", len(percentage), percentage)},
+		{name: "keys.count", value: fmt.Sprintf(":%d\r\n", stats.KeysCount)},
+		{name: "keys.bytes-per-key", value: fmt.Sprintf(":%d\r\n", bytesPerKey)},
+		{name: "keys.volatile", value: fmt.Sprintf(":%d\r\n", stats.VolatileKeysCount)},
+		{name: "evicted.keys", value: fmt.Sprintf(":%d\r\n", stats.EvictedKeys)},
+		{name: "expired.keys", value: fmt.Sprintf(":%d\r\n", stats.ExpiredKeys)},
+		{name: "expired.keys.active", value: fmt.Sprintf(":%d\r\n", stats.ActiveExpiredKeys)},
+		{name: "expire.cycles", value: fmt.Sprintf(":%d\r\n", stats.ExpireCycles)},
+		{name: "expire.cycles.time-limited", value: fmt.Sprintf(":%d\r\n", stats.ExpireCyclesTimeLimited)},
+		{name: "notifications.dropped", value: fmt.Sprintf(":%d\r\n", stats.DroppedNotifications)},
+		{name: "maxmemory", value: fmt.Sprintf(":%d\r\n", stats.MaxMemory)},
+		{name: "maxmemory-policy", value: fmt.Sprintf("$%d\r\n%s\r\n", len(stats.EvictionPolicy), stats.EvictionPolicy)},
+	}
+
+	// RESP3 connections receive a map, RESP2 connections receive a flat array of name-value pairs.
+	res := fmt.Sprintf("*%d\r\n", len(fields)*2)
+	if params.Protocol == constants.RESP3Protocol {
+		res = fmt.Sprintf("%%%d\r\n", len(fields))
+	}
+	for _, field := range fields {
+		res += fmt.Sprintf("$%d\r\n%s\r\n%s", len(field.name), field.name, field.value)
+	}
+
+	return []byte(res), nil
+}
+
+func handleMemoryDoctor(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+
+	stats := params.GetMemoryStats()
+	var report []string
+
+	if stats.KeysCount == 0 {
+		report = append(report, "The dataset is empty, there's nothing to report.")
+	}
+
+	if stats.MaxMemory > 0 {
+		usage := float64(stats.DatasetBytes) / float64(stats.MaxMemory) * 100
+		switch {
+		case usage >= 100 && strings.EqualFold(stats.EvictionPolicy, constants.NoEviction):
+			report = append(report, fmt.Sprintf(
+				"The dataset uses %.2f%% of max-memory and the eviction policy is %s, so writes are rejected. "+
+					"Consider increasing max-memory or setting an eviction policy.",
+				usage, stats.EvictionPolicy))
+		case usage >= 90:
+			report = append(report, fmt.Sprintf("The dataset uses %.2f%% of max-memory.", usage))
+		}
+	}
+
+	if stats.EvictedKeys > 0 {
+		report = append(report, fmt.Sprintf(
+			"%d keys have been evicted to stay under max-memory. Consider increasing max-memory if they were needed.",
+			stats.EvictedKeys))
+	}
+
+	if stats.PeakDatasetBytes > 0 && stats.DatasetBytes < stats.PeakDatasetBytes/2 {
+		report = append(report, fmt.Sprintf(
+			"The dataset is less than half of its peak size (%d of %d bytes). "+
+				"Memory freed since the peak is returned to the operating system gradually.",
+			stats.DatasetBytes, stats.PeakDatasetBytes))
+	}
+
+	if len(report) == 0 {
+		report = append(report, "No memory problems detected.")
+	}
+
+	res := strings.Join(report, "\n")
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(res), res)), nil
+}
+
+func memoryUsageKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) != 3 && len(cmd) != 5 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return internal.KeyExtractionFuncResult{
+		Channels: make([]string, 0), ReadKeys: cmd[2:3], WriteKeys: make([]string, 0),
+	}, nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -393,5 +524,54 @@ Unloads a module based on the its name as displayed by the MODULE LIST command.`
 				},
 			},
 		},
+		{
+			Command:     "memory",
+			Module:      constants.AdminModule,
+			Categories:  []string{},
+			Description: "Memory commands",
+			KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+				return internal.KeyExtractionFuncResult{
+					Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+				}, nil
+			},
+			SubCommands: []internal.SubCommand{
+				{
+					Command:    "usage",
+					Module:     constants.AdminModule,
+					Categories: []string{constants.ReadCategory, constants.SlowCategory},
+					Description: `(MEMORY USAGE key [SAMPLES count]) Estimate the number of bytes used to store the key and its value.
+Collections are estimated from a sample of count elements, 5 by default. A count of 0 measures every element.`,
+					Sync:              false,
+					KeyExtractionFunc: memoryUsageKeyFunc,
+					HandlerFunc:       handleMemoryUsage,
+				},
+				{
+					Command:     "stats",
+					Module:      constants.AdminModule,
+					Categories:  []string{constants.AdminCategory, constants.SlowCategory},
+					Description: `(MEMORY STATS) Get the estimated memory usage of the dataset and the memory allocated by the server.`,
+					Sync:        false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleMemoryStats,
+				},
+				{
+					Command:     "doctor",
+					Module:      constants.AdminModule,
+					Categories:  []string{constants.AdminCategory, constants.SlowCategory},
+					Description: `(MEMORY DOCTOR) Get a report of the memory problems detected by the server.`,
+					Sync:        false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleMemoryDoctor,
+				},
+			},
+		},
 	}
 }
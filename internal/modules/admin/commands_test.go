@@ -23,14 +23,19 @@ import (
 	"github.com/echovault/echovault/internal/constants"
 	"github.com/echovault/echovault/internal/modules/acl"
 	"github.com/echovault/echovault/internal/modules/admin"
+	"github.com/echovault/echovault/internal/modules/cluster"
 	"github.com/echovault/echovault/internal/modules/connection"
 	"github.com/echovault/echovault/internal/modules/generic"
 	"github.com/echovault/echovault/internal/modules/hash"
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
 	"github.com/echovault/echovault/internal/modules/list"
 	"github.com/echovault/echovault/internal/modules/pubsub"
+	"github.com/echovault/echovault/internal/modules/scripting"
 	"github.com/echovault/echovault/internal/modules/set"
 	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
 	str "github.com/echovault/echovault/internal/modules/string"
+	"github.com/echovault/echovault/internal/modules/transaction"
 	"github.com/tidwall/resp"
 	"os"
 	"path"
@@ -96,14 +101,19 @@ func Test_AdminCommands(t *testing.T) {
 		var commands []internal.Command
 		commands = append(commands, acl.Commands()...)
 		commands = append(commands, admin.Commands()...)
+		commands = append(commands, cluster.Commands()...)
 		commands = append(commands, generic.Commands()...)
 		commands = append(commands, hash.Commands()...)
+		commands = append(commands, hyperloglog.Commands()...)
 		commands = append(commands, list.Commands()...)
 		commands = append(commands, connection.Commands()...)
 		commands = append(commands, pubsub.Commands()...)
+		commands = append(commands, scripting.Commands()...)
 		commands = append(commands, set.Commands()...)
 		commands = append(commands, sorted_set.Commands()...)
+		commands = append(commands, stream.Commands()...)
 		commands = append(commands, str.Commands()...)
+		commands = append(commands, transaction.Commands()...)
 
 		// Flatten the commands and subcommands.
 		var allCommands []string
@@ -148,14 +158,19 @@ func Test_AdminCommands(t *testing.T) {
 		var commands []internal.Command
 		commands = append(commands, acl.Commands()...)
 		commands = append(commands, admin.Commands()...)
+		commands = append(commands, cluster.Commands()...)
 		commands = append(commands, generic.Commands()...)
 		commands = append(commands, hash.Commands()...)
+		commands = append(commands, hyperloglog.Commands()...)
 		commands = append(commands, list.Commands()...)
 		commands = append(commands, connection.Commands()...)
 		commands = append(commands, pubsub.Commands()...)
+		commands = append(commands, scripting.Commands()...)
 		commands = append(commands, set.Commands()...)
 		commands = append(commands, sorted_set.Commands()...)
+		commands = append(commands, stream.Commands()...)
 		commands = append(commands, str.Commands()...)
+		commands = append(commands, transaction.Commands()...)
 
 		// Flatten the commands and subcommands.
 		var allCommands []string
@@ -189,14 +204,19 @@ func Test_AdminCommands(t *testing.T) {
 		var allCommands []internal.Command
 		allCommands = append(allCommands, acl.Commands()...)
 		allCommands = append(allCommands, admin.Commands()...)
+		allCommands = append(allCommands, cluster.Commands()...)
 		allCommands = append(allCommands, generic.Commands()...)
 		allCommands = append(allCommands, hash.Commands()...)
+		allCommands = append(allCommands, hyperloglog.Commands()...)
 		allCommands = append(allCommands, list.Commands()...)
 		allCommands = append(allCommands, connection.Commands()...)
 		allCommands = append(allCommands, pubsub.Commands()...)
+		allCommands = append(allCommands, scripting.Commands()...)
 		allCommands = append(allCommands, set.Commands()...)
 		allCommands = append(allCommands, sorted_set.Commands()...)
+		allCommands = append(allCommands, stream.Commands()...)
 		allCommands = append(allCommands, str.Commands()...)
+		allCommands = append(allCommands, transaction.Commands()...)
 
 		tests := []struct {
 			name string
@@ -246,7 +266,9 @@ func Test_AdminCommands(t *testing.T) {
 				want: func() []string {
 					var commands []string
 					for _, command := range sorted_set.Commands() {
-						commands = append(commands, command.Command)
+						if strings.HasPrefix(command.Command, "z") {
+							commands = append(commands, command.Command)
+						}
 					}
 					return commands
 				}(),
@@ -995,4 +1017,68 @@ func Test_AdminCommands(t *testing.T) {
 		_ = conn.Close()
 		mockServer.ShutDown()
 	})
+
+	t.Run("Test MEMORY command", func(t *testing.T) {
+		t.Parallel()
+
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand := func(command ...string) resp.Value {
+			cmd := make([]resp.Value, len(command))
+			for i, c := range command {
+				cmd[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(cmd); err != nil {
+				t.Fatal(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res
+		}
+
+		writeCommand("SET", "MemoryKey1", "value1")
+
+		if res := writeCommand("MEMORY", "USAGE", "MemoryKey1"); res.Integer() <= len("MemoryKey1value1") {
+			t.Errorf("expected usage to be more than the length of the key and value, got %d", res.Integer())
+		}
+		if res := writeCommand("MEMORY", "USAGE", "MemoryKey1", "SAMPLES", "0"); res.Integer() <= 0 {
+			t.Errorf("expected usage to be more than 0, got %d", res.Integer())
+		}
+		if res := writeCommand("MEMORY", "USAGE", "MemoryKey2"); !res.IsNull() {
+			t.Errorf("expected usage of a missing key to be null, got %v", res)
+		}
+		if res := writeCommand("MEMORY", "USAGE", "MemoryKey1", "SAMPLES", "-1"); !strings.Contains(res.Error().Error(),
+			"value is not an integer or out of range") {
+			t.Errorf("expected out of range error, got %v", res)
+		}
+		if res := writeCommand("MEMORY", "USAGE", "MemoryKey1", "COUNT", "1"); !strings.Contains(res.Error().Error(),
+			"syntax error") {
+			t.Errorf("expected syntax error, got %v", res)
+		}
+
+		stats := writeCommand("MEMORY", "STATS").Array()
+		names := make([]string, 0, len(stats)/2)
+		for i := 0; i < len(stats); i += 2 {
+			names = append(names, stats[i].String())
+		}
+		for _, name := range []string{"total.allocated", "dataset.bytes", "keys.count", "maxmemory-policy"} {
+			if !slices.Contains(names, name) {
+				t.Errorf("expected MEMORY STATS to contain %s, got %v", name, names)
+			}
+		}
+
+		if res := writeCommand("MEMORY", "DOCTOR"); res.String() == "" {
+			t.Error("expected a MEMORY DOCTOR report")
+		}
+	})
 }
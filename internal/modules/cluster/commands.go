@@ -0,0 +1,559 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/slots"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// scanBatchSize is the number of keys scanned at a time when looking for the keys of a slot.
+const scanBatchSize = 1000
+
+func handleKeySlot(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	return []byte(fmt.Sprintf(":%d\r\n", slots.KeySlot(params.Command[2]))), nil
+}
+
+func handleSlots(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	shards, err := params.GetClusterShards()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each range of slots is listed with the nodes of the shard that owns it, the leader first.
+	var count int
+	var res string
+	for _, shard := range shards {
+		for _, r := range shard.Slots {
+			res += fmt.Sprintf("*%d\r\n:%d\r\n:%d\r\n", len(shard.Nodes)+2, r.Start, r.End)
+			for _, node := range shard.Nodes {
+				res += fmt.Sprintf("*3\r\n$%d\r\n%s\r\n:%d\r\n$%d\r\n%s\r\n",
+					len(node.Host), node.Host, node.Port, len(node.ID), node.ID)
+			}
+			count += 1
+		}
+	}
+
+	return []byte(fmt.Sprintf("*%d\r\n%s", count, res)), nil
+}
+
+func handleShards(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	shards, err := params.GetClusterShards()
+	if err != nil {
+		return nil, err
+	}
+
+	res := fmt.Sprintf("*%d\r\n", len(shards))
+	for _, shard := range shards {
+		res += fmt.Sprintf("*8\r\n$2\r\nid\r\n$%d\r\n%s\r\n$5\r\nepoch\r\n:%d\r\n",
+			len(shard.ID), shard.ID, shard.Epoch)
+		res += fmt.Sprintf("$5\r\nslots\r\n*%d\r\n", len(shard.Slots)*2)
+		for _, r := range shard.Slots {
+			res += fmt.Sprintf(":%d\r\n:%d\r\n", r.Start, r.End)
+		}
+		res += fmt.Sprintf("$5\r\nnodes\r\n*%d\r\n", len(shard.Nodes))
+		for _, node := range shard.Nodes {
+			role := "replica"
+			if node.Leader {
+				role = "master"
+			}
+			res += fmt.Sprintf("*8\r\n$2\r\nid\r\n$%d\r\n%s\r\n$8\r\nendpoint\r\n$%d\r\n%s\r\n",
+				len(node.ID), node.ID, len(node.Host), node.Host)
+			res += fmt.Sprintf("$4\r\nport\r\n:%d\r\n$4\r\nrole\r\n$%d\r\n%s\r\n", node.Port, len(role), role)
+		}
+	}
+
+	return []byte(res), nil
+}
+
+func handleSetSlot(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) < 4 || len(params.Command) > 5 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	slot, err := slots.ParseSlot(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+
+	action := strings.ToLower(params.Command[3])
+	var shardID string
+	switch action {
+	default:
+		return nil, fmt.Errorf("slot action %s must be IMPORTING, MIGRATING, STABLE or NODE", params.Command[3])
+	case "importing", "migrating", "node":
+		if len(params.Command) != 5 {
+			return nil, errors.New(constants.WrongArgsResponse)
+		}
+		shardID = params.Command[4]
+	case "stable":
+		if len(params.Command) != 4 {
+			return nil, errors.New(constants.WrongArgsResponse)
+		}
+	}
+
+	if err = params.SetSlot(params.Context, slot, action, shardID); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+// keysInSlot scans the keyspace for up to count keys of the slot. All the keys are returned when count is negative.
+func keysInSlot(params internal.HandlerFuncParams, slot int, count int) []string {
+	var keys []string
+	var cursor uint64
+	for {
+		var batch []string
+		batch, cursor = params.ScanKeys(cursor, scanBatchSize)
+		for _, key := range batch {
+			if slots.KeySlot(key) == slot {
+				keys = append(keys, key)
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	slices.Sort(keys)
+	if count >= 0 && len(keys) > count {
+		keys = keys[:count]
+	}
+	return keys
+}
+
+func handleGetKeysInSlot(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 4 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	slot, err := slots.ParseSlot(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(params.Command[3])
+	if err != nil || count < 0 {
+		return nil, errors.New("count must be a non-negative integer")
+	}
+
+	keys := keysInSlot(params, slot, count)
+	res := fmt.Sprintf("*%d\r\n", len(keys))
+	for _, key := range keys {
+		res += fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+	}
+	return []byte(res), nil
+}
+
+func handleCountKeysInSlot(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	slot, err := slots.ParseSlot(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(":%d\r\n", len(keysInSlot(params, slot, -1)))), nil
+}
+
+func handleMigrateSlot(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 4 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	slot, err := slots.ParseSlot(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(params.Command[3])
+	if err != nil || count <= 0 {
+		return nil, errors.New("count must be a positive integer")
+	}
+
+	moved, err := params.MigrateSlot(params.Context, slot, count)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(":%d\r\n", moved)), nil
+}
+
+func handleAsking(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 1 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.Asking(params.Connection); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleInfo(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	info, err := params.GetClusterInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(info))
+	for field := range info {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	var res strings.Builder
+	for _, field := range fields {
+		res.WriteString(fmt.Sprintf("%s:%s\r\n", field, info[field]))
+	}
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", res.Len(), res.String())), nil
+}
+
+func handleNodes(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	nodes, err := params.GetClusterNodes(params.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each node is described on its own line. Unknown addresses and numbers are written as "-".
+	unknown := func(n int64) string {
+		if n < 0 {
+			return "-"
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	var res strings.Builder
+	for _, node := range nodes {
+		memberlistAddr := node.MemberlistAddr
+		if memberlistAddr == "" {
+			memberlistAddr = "-"
+		}
+		flags := node.Role
+		if node.Myself {
+			flags = "myself," + flags
+		}
+		res.WriteString(fmt.Sprintf("%s %s %s %s %s %s %s\n",
+			node.ID, node.RaftAddr, memberlistAddr, flags,
+			unknown(node.LastContact), unknown(node.CommitIndex), unknown(node.AppliedIndex)))
+	}
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", res.Len(), res.String())), nil
+}
+
+func handleLeave(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.LeaveCluster(params.Context); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleRemove(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.RemoveClusterNode(params.Command[2]); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handlePromote(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.PromoteClusterNode(params.Command[2]); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleTransferLeader(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) < 2 || len(params.Command) > 3 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	var id string
+	if len(params.Command) == 3 {
+		id = params.Command[2]
+	}
+	if err := params.TransferLeadership(id); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleSnapshot(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 2 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.TakeRaftSnapshot(); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func Commands() []internal.Command {
+	return []internal.Command{
+		{
+			Command:     "cluster",
+			Module:      constants.ClusterModule,
+			Categories:  []string{},
+			Description: "Commands pertaining to the raft cluster and the hash slots of a sharded cluster",
+			Sync:        false,
+			KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+				return internal.KeyExtractionFuncResult{
+					Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+				}, nil
+			},
+			SubCommands: []internal.SubCommand{
+				{
+					Command:    "keyslot",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER KEYSLOT key)
+Returns the hash slot of the key. Only the hashtag between the first { and the following } is hashed if the key has one.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						// The key is not accessed, so the command is never redirected.
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleKeySlot,
+				},
+				{
+					Command:    "slots",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER SLOTS)
+Returns the ranges of slots of each shard, along with the host, port and ID of the shard's nodes. The leader is listed first.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleSlots,
+				},
+				{
+					Command:    "shards",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER SHARDS)
+Returns the ID, epoch, slot ranges and nodes of each shard of the cluster.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleShards,
+				},
+				{
+					Command:    "setslot",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER SETSLOT slot <IMPORTING shard-id | MIGRATING shard-id | STABLE | NODE shard-id>)
+Changes the state of the slot in the shard of the node, which must be the shard's leader. To move a slot, set it to
+IMPORTING on the target shard and MIGRATING on the source shard, move its keys with CLUSTER MIGRATESLOT, then assign
+it with NODE on the target shard followed by the source shard.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleSetSlot,
+				},
+				{
+					Command:    "getkeysinslot",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER GETKEYSINSLOT slot count)
+Returns up to count keys of the slot that are stored on the node.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleGetKeysInSlot,
+				},
+				{
+					Command:    "countkeysinslot",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER COUNTKEYSINSLOT slot)
+Returns the number of keys of the slot that are stored on the node.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleCountKeysInSlot,
+				},
+				{
+					Command:    "migrateslot",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER MIGRATESLOT slot count)
+Moves up to count keys of a MIGRATING slot to the leader of the target shard, and returns the number of keys moved.
+Must be called on the leader of the source shard.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleMigrateSlot,
+				},
+				{
+					Command:    "info",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER INFO)
+Returns the state of the node's raft group and the raft stats of the node, one field:value pair per line.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleInfo,
+				},
+				{
+					Command:    "nodes",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.SlowCategory},
+					Description: `(CLUSTER NODES)
+Returns the members of the node's raft group, one per line, with the format:
+<id> <raft-address> <memberlist-address> <flags> <last-contact-ms> <commit-index> <applied-index>
+The flags hold the node's role (leader, follower or learner), prefixed with "myself," for the node that replied.
+Values that are unknown, for instance because the node could not be reached, are written as "-".`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleNodes,
+				},
+				{
+					Command:    "leave",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER LEAVE)
+Removes the node from its raft group and leaves the memberlist cluster. A leader steps down once it's removed.
+The node keeps running, but can no longer serve the cluster's data and should be shut down.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleLeave,
+				},
+				{
+					Command:    "remove",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER REMOVE node-id)
+Removes the node with the server ID from the raft group. Must be called on the leader.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleRemove,
+				},
+				{
+					Command:    "promote",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER PROMOTE node-id)
+Makes the learner with the server ID a voter of the raft group. Learners are the nodes started with raft-learner.
+Must be called on the leader.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handlePromote,
+				},
+				{
+					Command:    "transfer-leader",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER TRANSFER-LEADER [node-id])
+Transfers the leadership of the raft group to the node with the server ID, or to the most up-to-date voter when no
+ID is given. Must be called on the leader.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleTransferLeader,
+				},
+				{
+					Command:    "snapshot",
+					Module:     constants.ClusterModule,
+					Categories: []string{constants.AdminCategory, constants.SlowCategory, constants.DangerousCategory},
+					Description: `(CLUSTER SNAPSHOT)
+Takes a raft snapshot of the node and waits for it to complete.`,
+					Sync: false,
+					KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+						return internal.KeyExtractionFuncResult{
+							Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+						}, nil
+					},
+					HandlerFunc: handleSnapshot,
+				},
+			},
+		},
+		{
+			Command:    "asking",
+			Module:     constants.ClusterModule,
+			Categories: []string{constants.ConnectionCategory, constants.FastCategory},
+			Description: `(ASKING)
+Allows the connection's next command to access a slot that is being imported by the node's shard.
+Clients send it before retrying a command that was redirected with an ASK error.`,
+			Sync: false,
+			KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+				return internal.KeyExtractionFuncResult{
+					Channels: make([]string, 0), ReadKeys: make([]string, 0), WriteKeys: make([]string, 0),
+				}, nil
+			},
+			HandlerFunc: handleAsking,
+		},
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/echovault/echovault/echovault"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/tidwall/resp"
+)
+
+func Test_Cluster(t *testing.T) {
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mockServer, err := echovault.NewEchoVault(
+		echovault.WithConfig(config.Config{
+			DataDir:        "",
+			EvictionPolicy: constants.NoEviction,
+			BindAddr:       "localhost",
+			Port:           uint16(port),
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		mockServer.Start()
+	}()
+
+	t.Cleanup(func() {
+		mockServer.ShutDown()
+	})
+
+	conn, err := internal.GetConnection("localhost", port)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	client := resp.NewConn(conn)
+
+	for _, key := range []string{"foo", "{user1}.name", "{user1}.email"} {
+		if err = client.WriteArray([]resp.Value{
+			resp.StringValue("SET"), resp.StringValue(key), resp.StringValue("value"),
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, _, err = client.ReadValue(); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	tests := []struct {
+		name        string
+		command     []string
+		expected    string
+		expectedErr error
+	}{
+		{name: "1. Key slot", command: []string{"CLUSTER", "KEYSLOT", "foo"}, expected: "12182"},
+		{name: "2. Key slot of hashtag", command: []string{"CLUSTER", "KEYSLOT", "{foo}bar"}, expected: "12182"},
+		{name: "3. Key slot of empty hashtag", command: []string{"CLUSTER", "KEYSLOT", "{}foo"}, expected: "9500"},
+		{
+			name:        "4. Key slot wrong args",
+			command:     []string{"CLUSTER", "KEYSLOT"},
+			expectedErr: errors.New(constants.WrongArgsResponse),
+		},
+		{name: "5. Count keys in slot", command: []string{"CLUSTER", "COUNTKEYSINSLOT", "8106"}, expected: "2"},
+		{
+			name:     "6. Get keys in slot",
+			command:  []string{"CLUSTER", "GETKEYSINSLOT", "8106", "1"},
+			expected: "{user1}.email",
+		},
+		{
+			name:        "7. Invalid slot",
+			command:     []string{"CLUSTER", "COUNTKEYSINSLOT", "16384"},
+			expectedErr: errors.New("invalid slot 16384, slots must be between 0 and 16383"),
+		},
+		{
+			name:        "8. Slots in standalone mode",
+			command:     []string{"CLUSTER", "SLOTS"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "9. Shards in standalone mode",
+			command:     []string{"CLUSTER", "SHARDS"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "10. Set slot in standalone mode",
+			command:     []string{"CLUSTER", "SETSLOT", "100", "STABLE"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "11. Set slot with unknown action",
+			command:     []string{"CLUSTER", "SETSLOT", "100", "MOVE", "shard-2"},
+			expectedErr: errors.New("slot action MOVE must be IMPORTING, MIGRATING, STABLE or NODE"),
+		},
+		{
+			name:        "12. Migrate slot in standalone mode",
+			command:     []string{"CLUSTER", "MIGRATESLOT", "100", "10"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{name: "13. Asking", command: []string{"ASKING"}, expected: "OK"},
+		{
+			name:        "14. Info in standalone mode",
+			command:     []string{"CLUSTER", "INFO"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "15. Nodes in standalone mode",
+			command:     []string{"CLUSTER", "NODES"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "16. Leave in standalone mode",
+			command:     []string{"CLUSTER", "LEAVE"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "17. Remove wrong args",
+			command:     []string{"CLUSTER", "REMOVE"},
+			expectedErr: errors.New(constants.WrongArgsResponse),
+		},
+		{
+			name:        "18. Remove in standalone mode",
+			command:     []string{"CLUSTER", "REMOVE", "SERVER-1"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "19. Transfer leader wrong args",
+			command:     []string{"CLUSTER", "TRANSFER-LEADER", "SERVER-1", "SERVER-2"},
+			expectedErr: errors.New(constants.WrongArgsResponse),
+		},
+		{
+			name:        "20. Transfer leader in standalone mode",
+			command:     []string{"CLUSTER", "TRANSFER-LEADER"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "21. Snapshot in standalone mode",
+			command:     []string{"CLUSTER", "SNAPSHOT"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+		{
+			name:        "22. Promote wrong args",
+			command:     []string{"CLUSTER", "PROMOTE"},
+			expectedErr: errors.New(constants.WrongArgsResponse),
+		},
+		{
+			name:        "23. Promote in standalone mode",
+			command:     []string{"CLUSTER", "PROMOTE", "SERVER-1"},
+			expectedErr: errors.New("cluster support disabled"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command := make([]resp.Value, len(test.command))
+			for i, arg := range test.command {
+				command[i] = resp.StringValue(arg)
+			}
+			if err = client.WriteArray(command); err != nil {
+				t.Error(err)
+				return
+			}
+
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if test.expectedErr != nil {
+				if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedErr.Error()) {
+					t.Errorf("expected error \"%s\", got \"%s\"", test.expectedErr.Error(), res.String())
+				}
+				return
+			}
+
+			got := res.String()
+			if res.Type() == resp.Array && len(res.Array()) == 1 {
+				got = res.Array()[0].String()
+			}
+			if got != test.expected {
+				t.Errorf("expected response \"%s\", got \"%s\"", test.expected, got)
+			}
+		})
+	}
+}
@@ -17,9 +17,13 @@ package connection
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/modules/acl"
 )
 
 func handlePing(params internal.HandlerFuncParams) ([]byte, error) {
@@ -40,6 +44,122 @@ func handleEcho(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(params.Command[1]), params.Command[1])), nil
 }
 
+func handleHello(params internal.HandlerFuncParams) ([]byte, error) {
+	info := params.GetConnectionInfo(params.Connection)
+	protocol, clientname := info.Protocol, info.Name
+
+	if len(params.Command) >= 2 {
+		p, err := strconv.Atoi(params.Command[1])
+		if err != nil {
+			return nil, errors.New("protocol version is not an integer or out of range")
+		}
+		if p != constants.RESP2Protocol && p != constants.RESP3Protocol {
+			return nil, errors.New("NOPROTO unsupported protocol version")
+		}
+		protocol = p
+	}
+
+	a, ok := params.GetACL().(*acl.ACL)
+	if !ok {
+		return nil, errors.New("could not load ACL")
+	}
+
+	authenticated := false
+	for i := 2; i < len(params.Command); i++ {
+		switch strings.ToLower(params.Command[i]) {
+		default:
+			return nil, fmt.Errorf("syntax error in HELLO option '%s'", params.Command[i])
+		case "auth":
+			if i+2 >= len(params.Command) {
+				return nil, errors.New(constants.WrongArgsResponse)
+			}
+			if err := a.AuthenticateConnection(params.Context, params.Connection,
+				[]string{"AUTH", params.Command[i+1], params.Command[i+2]}); err != nil {
+				return nil, err
+			}
+			authenticated = true
+			i += 2
+		case "setname":
+			if i+1 >= len(params.Command) {
+				return nil, errors.New(constants.WrongArgsResponse)
+			}
+			clientname = params.Command[i+1]
+			i += 1
+		}
+	}
+
+	// HELLO is allowed through the ACL layer so connections can authenticate with HELLO AUTH.
+	// Connections that have not been authenticated must provide the AUTH option.
+	if !authenticated && params.Connection != nil {
+		a.RLockUsers()
+		connection, registered := a.Connections[params.Connection]
+		a.RUnlockUsers()
+		if a.Config.RequirePass && (!registered || !connection.Authenticated) {
+			return nil, errors.New("NOAUTH HELLO must be called with the client already authenticated, " +
+				"otherwise the HELLO AUTH <user> <pass> option can be used to authenticate the client")
+		}
+	}
+
+	params.SetConnectionInfo(params.Connection, clientname, protocol)
+	info = params.GetConnectionInfo(params.Connection)
+	server := params.GetServerInfo()
+
+	// RESP3 connections receive a map, RESP2 connections receive a flat array of field-value pairs.
+	res := fmt.Sprintf("*%d\r\n", 14)
+	if info.Protocol == constants.RESP3Protocol {
+		res = fmt.Sprintf("%%%d\r\n", 7)
+	}
+	res += fmt.Sprintf("$6\r\nserver\r\n$%d\r\n%s\r\n", len(server.Server), server.Server)
+	res += fmt.Sprintf("$7\r\nversion\r\n$%d\r\n%s\r\n", len(server.Version), server.Version)
+	res += fmt.Sprintf("$5\r\nproto\r\n:%d\r\n", info.Protocol)
+	res += fmt.Sprintf("$2\r\nid\r\n:%d\r\n", info.Id)
+	res += fmt.Sprintf("$4\r\nmode\r\n$%d\r\n%s\r\n", len(server.Mode), server.Mode)
+	res += fmt.Sprintf("$4\r\nrole\r\n$%d\r\n%s\r\n", len(server.Role), server.Role)
+	res += fmt.Sprintf("$7\r\nmodules\r\n*%d\r\n", len(server.Modules))
+	for _, module := range server.Modules {
+		res += fmt.Sprintf("$%d\r\n%s\r\n", len(module), module)
+	}
+
+	return []byte(res), nil
+}
+
+func handleReadConsistency(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) == 1 {
+		// Return the connection's current read consistency.
+		consistency := params.GetConnectionInfo(params.Connection).ReadConsistency
+		return []byte(fmt.Sprintf("*2\r\n$%d\r\n%s\r\n:%d\r\n",
+			len(consistency.Mode), consistency.Mode, consistency.MaxLag.Milliseconds())), nil
+	}
+
+	consistency := internal.ReadConsistency{Mode: strings.ToLower(params.Command[1])}
+	switch consistency.Mode {
+	default:
+		return nil, fmt.Errorf("read consistency %s must be leader, lease or stale", params.Command[1])
+	case constants.ReadConsistencyLeader, constants.ReadConsistencyLease:
+		if len(params.Command) != 2 {
+			return nil, errors.New(constants.WrongArgsResponse)
+		}
+	case constants.ReadConsistencyStale:
+		switch len(params.Command) {
+		default:
+			return nil, errors.New(constants.WrongArgsResponse)
+		case 2:
+		case 4:
+			if !strings.EqualFold(params.Command[2], "maxlag") {
+				return nil, fmt.Errorf("syntax error in READCONSISTENCY option '%s'", params.Command[2])
+			}
+			maxLag, err := strconv.Atoi(params.Command[3])
+			if err != nil || maxLag < 0 {
+				return nil, errors.New("max lag must be a non-negative integer")
+			}
+			consistency.MaxLag = time.Duration(maxLag) * time.Millisecond
+		}
+	}
+
+	params.SetReadConsistency(params.Connection, consistency)
+	return []byte(constants.OkResponse), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -75,5 +195,46 @@ Echo the message back to the client.`,
 			},
 			HandlerFunc: handleEcho,
 		},
+		{
+			Command:    "hello",
+			Module:     constants.ConnectionModule,
+			Categories: []string{constants.ConnectionCategory, constants.FastCategory},
+			Description: `(HELLO [protover [AUTH username password] [SETNAME clientname]])
+Switch the connection to the provided RESP protocol version (2 or 3), optionally authenticating
+and setting the connection name. Returns the server details.
+Connections that have negotiated RESP3 receive native maps, sets, doubles, nulls and push messages.`,
+			Sync: false,
+			KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+				return internal.KeyExtractionFuncResult{
+					Channels:  make([]string, 0),
+					ReadKeys:  make([]string, 0),
+					WriteKeys: make([]string, 0),
+				}, nil
+			},
+			HandlerFunc: handleHello,
+		},
+		{
+			Command:    "readconsistency",
+			Module:     constants.ConnectionModule,
+			Categories: []string{constants.ConnectionCategory, constants.FastCategory},
+			Description: `(READCONSISTENCY [LEADER | LEASE | STALE [MAXLAG milliseconds]])
+Set the consistency of the connection's read commands in cluster mode. With LEADER, the leader confirms it's still
+the leader with a quorum of the cluster before serving each read. With LEASE, the leader serves reads while its
+leadership lease is valid. With STALE, any node serves reads from its local store. MAXLAG rejects stale reads on
+followers that have not heard from the leader for longer than the given milliseconds. Reads that can't meet the
+consistency are rejected. Without arguments, returns the connection's mode and max lag.`,
+			Sync: false,
+			KeyExtractionFunc: func(cmd []string) (internal.KeyExtractionFuncResult, error) {
+				if len(cmd) > 4 {
+					return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+				}
+				return internal.KeyExtractionFuncResult{
+					Channels:  make([]string, 0),
+					ReadKeys:  make([]string, 0),
+					WriteKeys: make([]string, 0),
+				}, nil
+			},
+			HandlerFunc: handleReadConsistency,
+		},
 	}
 }
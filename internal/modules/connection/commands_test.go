@@ -177,4 +177,164 @@ func Test_Connection(t *testing.T) {
 			}
 		})
 
+
+	t.Run("Test_HandleHello", func(t *testing.T) {
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			name        string
+			command     []string
+			expected    []string // Substrings expected in the raw response.
+			expectedErr error
+		}{
+			{
+				name:     "1. HELLO without a version returns the server details with the current protocol",
+				command:  []string{"HELLO"},
+				expected: []string{"*14\r\n", "$6\r\nserver\r\n$9\r\nechovault\r\n", "$5\r\nproto\r\n:2\r\n"},
+			},
+			{
+				name:        "2. HELLO with an unsupported protocol version",
+				command:     []string{"HELLO", "4"},
+				expectedErr: errors.New("NOPROTO unsupported protocol version"),
+			},
+			{
+				name:        "3. HELLO with an unknown option",
+				command:     []string{"HELLO", "3", "UNKNOWN"},
+				expectedErr: errors.New("syntax error in HELLO option 'UNKNOWN'"),
+			},
+			{
+				name:     "4. HELLO 3 switches the connection to RESP3 and returns a map",
+				command:  []string{"HELLO", "3", "SETNAME", "client1"},
+				expected: []string{"%7\r\n", "$5\r\nproto\r\n:3\r\n", "$4\r\nmode\r\n$10\r\nstandalone\r\n"},
+			},
+			{
+				name:     "5. Null responses are sent as RESP3 nulls",
+				command:  []string{"GET", "HelloKey1"},
+				expected: []string{constants.NullResponse},
+			},
+			{
+				name:     "6. HELLO 2 switches the connection back to RESP2",
+				command:  []string{"HELLO", "2"},
+				expected: []string{"*14\r\n", "$5\r\nproto\r\n:2\r\n"},
+			},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res := string(buf[:n])
+
+			if test.expectedErr != nil {
+				if !strings.Contains(res, test.expectedErr.Error()) {
+					t.Errorf("%s: expected error \"%s\", got \"%s\"", test.name, test.expectedErr.Error(), res)
+				}
+				continue
+			}
+
+			for _, expected := range test.expected {
+				if !strings.Contains(res, expected) {
+					t.Errorf("%s: expected response to contain %q, got %q", test.name, expected, res)
+				}
+			}
+		}
+	})
+
+	t.Run("Test_HandleReadConsistency", func(t *testing.T) {
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			name        string
+			command     []string
+			expected    string
+			expectedErr error
+		}{
+			{
+				name:     "1. Set the leader read consistency",
+				command:  []string{"READCONSISTENCY", "LEADER"},
+				expected: "+OK\r\n",
+			},
+			{
+				name:     "2. Return the connection's read consistency",
+				command:  []string{"READCONSISTENCY"},
+				expected: "*2\r\n$6\r\nleader\r\n:0\r\n",
+			},
+			{
+				name:     "3. Set the stale read consistency with a max lag",
+				command:  []string{"READCONSISTENCY", "stale", "MAXLAG", "250"},
+				expected: "+OK\r\n",
+			},
+			{
+				name:     "4. Return the stale read consistency with its max lag",
+				command:  []string{"READCONSISTENCY"},
+				expected: "*2\r\n$5\r\nstale\r\n:250\r\n",
+			},
+			{
+				name:        "5. Reject an unknown read consistency",
+				command:     []string{"READCONSISTENCY", "eventual"},
+				expectedErr: errors.New("read consistency eventual must be leader, lease or stale"),
+			},
+			{
+				name:        "6. Reject a max lag with the lease read consistency",
+				command:     []string{"READCONSISTENCY", "LEASE", "MAXLAG", "250"},
+				expectedErr: errors.New(constants.WrongArgsResponse),
+			},
+			{
+				name:        "7. Reject a negative max lag",
+				command:     []string{"READCONSISTENCY", "STALE", "MAXLAG", "-1"},
+				expectedErr: errors.New("max lag must be a non-negative integer"),
+			},
+			{
+				name:     "8. Reads are served in standalone mode regardless of the read consistency",
+				command:  []string{"GET", "ReadConsistencyKey1"},
+				expected: "$-1\r\n",
+			},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res := string(buf[:n])
+
+			if test.expectedErr != nil {
+				if !strings.Contains(res, test.expectedErr.Error()) {
+					t.Errorf("%s: expected error \"%s\", got \"%s\"", test.name, test.expectedErr.Error(), res)
+				}
+				continue
+			}
+
+			if res != test.expected {
+				t.Errorf("%s: expected response %q, got %q", test.name, test.expected, res)
+			}
+		}
+	})
 }
@@ -18,10 +18,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gobwas/glob"
+
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/constants"
 )
@@ -477,6 +480,228 @@ func handleDecr(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(fmt.Sprintf(":%d\r\n", newValue)), nil
 }
 
+func handleScan(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := scanKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+
+	options, err := internal.ParseScanArgs(params.Command[1:], true)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, cursor := params.ScanKeys(options.Cursor, options.Count)
+
+	var values map[string]interface{}
+	if options.Type != "" {
+		values = params.GetValues(params.Context, keys)
+	}
+
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !options.Matches(key) {
+			continue
+		}
+		// The key could have been deleted since it was scanned.
+		if options.Type != "" && (values[key] == nil || getValueType(values[key]) != options.Type) {
+			continue
+		}
+		res = append(res, key)
+	}
+
+	return internal.EncodeScanResponse(cursor, res), nil
+}
+
+func handleKeys(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := keysKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+
+	g, err := glob.Compile(params.Command[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s", params.Command[1])
+	}
+
+	// Walk the keyspace in batches so that the store is not locked for the whole command.
+	var keys []string
+	cursor := uint64(0)
+	for {
+		var batch []string
+		batch, cursor = params.ScanKeys(cursor, 1000)
+		for _, key := range batch {
+			if g.Match(key) {
+				keys = append(keys, key)
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	var res strings.Builder
+	res.WriteString(fmt.Sprintf("*%d\r\n", len(keys)))
+	for _, key := range keys {
+		res.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(key), key))
+	}
+	return []byte(res.String()), nil
+}
+
+func handleType(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := typeKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, exists := getKey(params, keys.ReadKeys[0])
+	if !exists {
+		return []byte("+none\r\n"), nil
+	}
+	return []byte(fmt.Sprintf("+%s\r\n", getValueType(value))), nil
+}
+
+func handleExists(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := existsKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys that are repeated are counted each time.
+	count := 0
+	for _, key := range keys.ReadKeys {
+		if _, _, exists := getKey(params, key); exists {
+			count += 1
+		}
+	}
+	return []byte(fmt.Sprintf(":%d\r\n", count)), nil
+}
+
+func handleRename(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := renameKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	source, destination := keys.WriteKeys[0], keys.WriteKeys[1]
+	nx := strings.EqualFold(params.Command[0], "renamenx")
+
+	value, expireAt, exists := getKey(params, source)
+	if !exists {
+		return nil, errors.New("no such key")
+	}
+
+	if source == destination {
+		if nx {
+			return []byte(":0\r\n"), nil
+		}
+		return []byte(constants.OkResponse), nil
+	}
+
+	if _, _, exists = getKey(params, destination); exists {
+		if nx {
+			return []byte(":0\r\n"), nil
+		}
+		// Delete the destination first, so that its expiry time is not kept.
+		if err = params.DeleteKey(destination); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = params.SetValues(params.Context, map[string]interface{}{destination: value}); err != nil {
+		return nil, err
+	}
+	if expireAt != (time.Time{}) {
+		params.SetExpiry(params.Context, destination, expireAt, false)
+	}
+	if err = params.DeleteKey(source); err != nil {
+		return nil, err
+	}
+
+	if nx {
+		return []byte(":1\r\n"), nil
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleCopy(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := copyKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	source, destination := keys.ReadKeys[0], keys.WriteKeys[0]
+
+	replace := false
+	for _, arg := range params.Command[3:] {
+		if !strings.EqualFold(arg, "replace") {
+			return nil, errors.New("syntax error")
+		}
+		replace = true
+	}
+
+	value, expireAt, exists := getKey(params, source)
+	if !exists || source == destination {
+		return []byte(":0\r\n"), nil
+	}
+
+	if _, _, exists = getKey(params, destination); exists {
+		if !replace {
+			return []byte(":0\r\n"), nil
+		}
+		// Delete the destination first, so that its expiry time is not kept.
+		if err = params.DeleteKey(destination); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err = copyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if err = params.SetValues(params.Context, map[string]interface{}{destination: value}); err != nil {
+		return nil, err
+	}
+	if expireAt != (time.Time{}) {
+		params.SetExpiry(params.Context, destination, expireAt, false)
+	}
+
+	return []byte(":1\r\n"), nil
+}
+
+func handleRandomKey(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := randomKeyKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+
+	// Pick the first key after a random position in the scan order, wrapping around to the start.
+	keys, _ := params.ScanKeys(rand.Uint64(), 1)
+	if len(keys) == 0 {
+		keys, _ = params.ScanKeys(0, 1)
+	}
+	if len(keys) == 0 {
+		return []byte("$-1\r\n"), nil
+	}
+
+	// Keys with the same position in the scan order are returned together.
+	key := keys[rand.Intn(len(keys))]
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)), nil
+}
+
+func handleTouch(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := touchKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading the keys updates their access time and frequency in the eviction cache.
+	count := 0
+	for _, key := range keys.ReadKeys {
+		if _, _, exists := getKey(params, key); exists {
+			count += 1
+		}
+	}
+	return []byte(fmt.Sprintf(":%d\r\n", count)), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -670,5 +895,110 @@ This operation is limited to 64 bit signed integers.`,
 			KeyExtractionFunc: decrKeyFunc,
 			HandlerFunc:       handleDecr,
 		},
+		{
+			Command:    "scan",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(SCAN cursor [MATCH pattern] [COUNT count] [TYPE type])
+Incrementally iterates over the keys in the keyspace. Start the iteration with a cursor of 0,
+and call SCAN again with the returned cursor until it returns a cursor of 0.
+A key that exists throughout the iteration is returned exactly once, even when keys are added or removed.
+MATCH - Only return the keys that match the glob pattern.
+COUNT - The number of keys to scan in each call. Defaults to 10.
+TYPE - Only return the keys holding values of the given type (string, list, set, zset, hash or stream).`,
+			Sync:              false,
+			KeyExtractionFunc: scanKeyFunc,
+			HandlerFunc:       handleScan,
+		},
+		{
+			Command:           "keys",
+			Module:            constants.GenericModule,
+			Categories:        []string{constants.KeyspaceCategory, constants.ReadCategory, constants.SlowCategory},
+			Description:       "(KEYS pattern) Returns all the keys that match the glob pattern.",
+			Sync:              false,
+			KeyExtractionFunc: keysKeyFunc,
+			HandlerFunc:       handleKeys,
+		},
+		{
+			Command:    "type",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.ReadCategory, constants.FastCategory},
+			Description: `(TYPE key) Returns the type of the value stored at the key.
+The type is one of string, list, set, zset, hash or stream. Returns none if the key does not exist.`,
+			Sync:              false,
+			KeyExtractionFunc: typeKeyFunc,
+			HandlerFunc:       handleType,
+		},
+		{
+			Command:    "exists",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.ReadCategory, constants.FastCategory},
+			Description: `(EXISTS key [key ...]) Returns the number of the provided keys that exist.
+A key that is provided multiple times is counted each time.`,
+			Sync:              false,
+			KeyExtractionFunc: existsKeyFunc,
+			HandlerFunc:       handleExists,
+		},
+		{
+			Command:    "rename",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(RENAME key newkey) Renames the key to newkey, along with its expiry time.
+If newkey already exists, it is overwritten.`,
+			Sync:              true,
+			KeyExtractionFunc: renameKeyFunc,
+			HandlerFunc:       handleRename,
+		},
+		{
+			Command:    "renamenx",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(RENAMENX key newkey) Renames the key to newkey, along with its expiry time, only if newkey does not exist.
+Returns 1 if the key was renamed and 0 otherwise.`,
+			Sync:              true,
+			KeyExtractionFunc: renameKeyFunc,
+			HandlerFunc:       handleRename,
+		},
+		{
+			Command:    "copy",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(COPY source destination [REPLACE]) Copies the value and the expiry time of the source key to the destination key.
+REPLACE - Overwrite the destination key if it exists.
+Returns 1 if the key was copied and 0 otherwise.`,
+			Sync:              true,
+			KeyExtractionFunc: copyKeyFunc,
+			HandlerFunc:       handleCopy,
+		},
+		{
+			Command:           "randomkey",
+			Module:            constants.GenericModule,
+			Categories:        []string{constants.KeyspaceCategory, constants.ReadCategory, constants.SlowCategory},
+			Description:       "(RANDOMKEY) Returns a random key from the keyspace. Returns nil if the keyspace is empty.",
+			Sync:              false,
+			KeyExtractionFunc: randomKeyKeyFunc,
+			HandlerFunc:       handleRandomKey,
+		},
+		{
+			Command:    "touch",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.ReadCategory, constants.FastCategory},
+			Description: `(TOUCH key [key ...]) Updates the last access time of the keys.
+Returns the number of the keys that exist.`,
+			Sync:              false,
+			KeyExtractionFunc: touchKeyFunc,
+			HandlerFunc:       handleTouch,
+		},
+		{
+			Command:    "unlink",
+			Module:     constants.GenericModule,
+			Categories: []string{constants.KeyspaceCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(UNLINK key [key ...]) Alias of DEL. Deleting a key only removes it from the keyspace.
+The memory held by its value is reclaimed by the garbage collector, which runs concurrently, so neither command
+waits for large values to be released.`,
+			Sync:              true,
+			KeyExtractionFunc: delKeyFunc,
+			HandlerFunc:       handleDel,
+		},
 	}
 }
@@ -17,6 +17,7 @@ package generic_test
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -2137,4 +2138,478 @@ func Test_Generic(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandleSCAN", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		write := func(command ...string) resp.Value {
+			return writeCommand(t, client, command...)
+		}
+
+		// scan returns the keys from one SCAN call, and the next cursor.
+		scan := func(cursor string, args ...string) ([]string, string) {
+			res := write(append([]string{"SCAN", cursor}, args...)...)
+			if res.Error() != nil || len(res.Array()) != 2 {
+				t.Errorf("unexpected SCAN response %v", res)
+				return nil, "0"
+			}
+			var keys []string
+			for _, key := range res.Array()[1].Array() {
+				keys = append(keys, key.String())
+			}
+			return keys, res.Array()[0].String()
+		}
+
+		expected := map[string]bool{}
+		for i := 0; i < 25; i++ {
+			key := fmt.Sprintf("ScanKey%d", i)
+			write("SET", key, "value")
+			expected[key] = true
+		}
+
+		// Scan the keys while keys are added and removed.
+		// Every key that exists for the whole scan must be returned exactly once.
+		seen := map[string]int{}
+		keys, cursor := scan("0", "MATCH", "ScanKey*", "COUNT", "5")
+		for _, key := range keys {
+			seen[key] += 1
+			// Remove the keys that were already returned.
+			write("DEL", key)
+			delete(expected, key)
+		}
+		for i := 25; i < 50; i++ {
+			write("SET", fmt.Sprintf("ScanKey%d", i), "value")
+		}
+		for calls := 0; cursor != "0"; calls++ {
+			if calls > 100 {
+				t.Error("SCAN did not complete")
+				return
+			}
+			keys, cursor = scan(cursor, "MATCH", "ScanKey*", "COUNT", "5")
+			for _, key := range keys {
+				seen[key] += 1
+			}
+		}
+		for key := range expected {
+			if seen[key] != 1 {
+				t.Errorf("expected key %s to be returned once, got %d", key, seen[key])
+			}
+		}
+		for key, count := range seen {
+			if count != 1 {
+				t.Errorf("expected key %s to be returned once, got %d", key, count)
+			}
+		}
+
+		// Filter the keys by type.
+		write("RPUSH", "ScanTypeKey1", "value")
+		write("SET", "ScanTypeKey2", "value")
+		var lists []string
+		cursor = "0"
+		for {
+			keys, cursor = scan(cursor, "MATCH", "ScanTypeKey*", "TYPE", "list", "COUNT", "100")
+			lists = append(lists, keys...)
+			if cursor == "0" {
+				break
+			}
+		}
+		if len(lists) != 1 || lists[0] != "ScanTypeKey1" {
+			t.Errorf("expected keys [ScanTypeKey1], got %v", lists)
+		}
+
+		errorTests := []struct {
+			command       []string
+			expectedError error
+		}{
+			{command: []string{"SCAN"}, expectedError: errors.New(constants.WrongArgsResponse)},
+			{command: []string{"SCAN", "cursor"}, expectedError: errors.New("invalid cursor")},
+			{command: []string{"SCAN", "0", "COUNT", "0"}, expectedError: errors.New("syntax error")},
+			{command: []string{"SCAN", "0", "COUNT", "ten"}, expectedError: errors.New("value is not an integer or out of range")},
+			{command: []string{"SCAN", "0", "MATCH"}, expectedError: errors.New("syntax error")},
+			{command: []string{"SCAN", "0", "LIMIT", "10"}, expectedError: errors.New("syntax error")},
+		}
+		for _, test := range errorTests {
+			res := write(test.command...)
+			if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+				t.Errorf("expected error \"%s\" for %v, got %v", test.expectedError.Error(), test.command, res)
+			}
+		}
+	})
+
+	t.Run("Test_HandleKEYS", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		for _, key := range []string{"KeysKey1", "KeysKey2", "KeysKey3", "KeysOther1"} {
+			writeCommand(t, client, "SET", key, "value")
+		}
+		// Expired keys are not returned.
+		writeCommand(t, client, "SET", "KeysKey4", "value", "PXAT", strconv.FormatInt(mockClock.Now().Add(-time.Second).UnixMilli(), 10))
+
+		tests := []struct {
+			name     string
+			pattern  string
+			expected []string
+		}{
+			{name: "1. Return the keys matching the pattern", pattern: "KeysKey*", expected: []string{"KeysKey1", "KeysKey2", "KeysKey3"}},
+			{name: "2. Return the keys matching a character class", pattern: "Keys[KO]*1", expected: []string{"KeysKey1", "KeysOther1"}},
+			{name: "3. Return an empty array when no keys match", pattern: "KeysNone*", expected: []string{}},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, "KEYS", test.pattern)
+				keys := make([]string, 0, len(res.Array()))
+				for _, key := range res.Array() {
+					keys = append(keys, key.String())
+				}
+				slices.Sort(keys)
+				if !slices.Equal(keys, test.expected) {
+					t.Errorf("expected keys %v, got %v", test.expected, keys)
+				}
+			})
+		}
+
+		res := writeCommand(t, client, "KEYS")
+		if res.Error() == nil || !strings.Contains(res.Error().Error(), constants.WrongArgsResponse) {
+			t.Errorf("expected error \"%s\", got %v", constants.WrongArgsResponse, res)
+		}
+	})
+
+	t.Run("Test_HandleTYPE", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, "SET", "TypeKey1", "value")
+		writeCommand(t, client, "RPUSH", "TypeKey2", "value")
+		writeCommand(t, client, "SADD", "TypeKey3", "value")
+		writeCommand(t, client, "ZADD", "TypeKey4", "1", "value")
+		writeCommand(t, client, "HSET", "TypeKey5", "field", "value")
+		writeCommand(t, client, "XADD", "TypeKey6", "*", "field", "value")
+		writeCommand(t, client, "PFADD", "TypeKey7", "value")
+		writeCommand(t, client, "SET", "TypeKey8", "value", "PXAT", strconv.FormatInt(mockClock.Now().Add(-time.Second).UnixMilli(), 10))
+
+		tests := []struct {
+			key      string
+			expected string
+		}{
+			{key: "TypeKey1", expected: "string"},
+			{key: "TypeKey2", expected: "list"},
+			{key: "TypeKey3", expected: "set"},
+			{key: "TypeKey4", expected: "zset"},
+			{key: "TypeKey5", expected: "hash"},
+			{key: "TypeKey6", expected: "stream"},
+			{key: "TypeKey7", expected: "string"},
+			{key: "TypeKey8", expected: "none"},
+			{key: "TypeKey9", expected: "none"},
+		}
+		for _, test := range tests {
+			if res := writeCommand(t, client, "TYPE", test.key); res.String() != test.expected {
+				t.Errorf("expected type of %s to be %s, got %v", test.key, test.expected, res)
+			}
+		}
+	})
+
+	t.Run("Test_HandleEXISTS", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, "SET", "ExistsKey1", "value")
+		writeCommand(t, client, "RPUSH", "ExistsKey2", "value")
+		writeCommand(t, client, "SET", "ExistsKey3", "value", "PXAT", strconv.FormatInt(mockClock.Now().Add(-time.Second).UnixMilli(), 10))
+
+		tests := []struct {
+			name     string
+			command  []string
+			expected int
+		}{
+			{name: "1. Count the keys that exist", command: []string{"EXISTS", "ExistsKey1", "ExistsKey2", "ExistsKey4"}, expected: 2},
+			{name: "2. Count repeated keys each time", command: []string{"EXISTS", "ExistsKey1", "ExistsKey1"}, expected: 2},
+			{name: "3. Expired keys don't exist", command: []string{"EXISTS", "ExistsKey3"}, expected: 0},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				if res := writeCommand(t, client, test.command...); res.Integer() != test.expected {
+					t.Errorf("expected %d, got %v", test.expected, res)
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandleRENAME", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		tests := []struct {
+			name             string
+			preset           [][]string
+			command          []string
+			expectedResponse string
+			expectedValues   map[string]string // Expected value of each key after the command. Empty if the key should not exist.
+			expectedTTL      map[string]int
+			expectedError    error
+		}{
+			{
+				name:             "1. Rename the key to a new key",
+				preset:           [][]string{{"SET", "RenameKey1", "value1"}},
+				command:          []string{"RENAME", "RenameKey1", "RenameKey2"},
+				expectedResponse: "OK",
+				expectedValues:   map[string]string{"RenameKey1": "", "RenameKey2": "value1"},
+			},
+			{
+				name:             "2. Overwrite the destination and keep the expiry time of the source",
+				preset:           [][]string{{"SET", "RenameKey3", "value3", "EX", "100"}, {"SET", "RenameKey4", "value4"}},
+				command:          []string{"RENAME", "RenameKey3", "RenameKey4"},
+				expectedResponse: "OK",
+				expectedValues:   map[string]string{"RenameKey3": "", "RenameKey4": "value3"},
+				expectedTTL:      map[string]int{"RenameKey4": 100},
+			},
+			{
+				name:             "3. Don't keep the expiry time of the destination",
+				preset:           [][]string{{"SET", "RenameKey5", "value5"}, {"SET", "RenameKey6", "value6", "EX", "100"}},
+				command:          []string{"RENAME", "RenameKey5", "RenameKey6"},
+				expectedResponse: "OK",
+				expectedValues:   map[string]string{"RenameKey6": "value5"},
+				expectedTTL:      map[string]int{"RenameKey6": -1},
+			},
+			{
+				name:             "4. RENAMENX does not overwrite the destination",
+				preset:           [][]string{{"SET", "RenameKey7", "value7"}, {"SET", "RenameKey8", "value8"}},
+				command:          []string{"RENAMENX", "RenameKey7", "RenameKey8"},
+				expectedResponse: "0",
+				expectedValues:   map[string]string{"RenameKey7": "value7", "RenameKey8": "value8"},
+			},
+			{
+				name:             "5. RENAMENX renames the key when the destination does not exist",
+				preset:           [][]string{{"SET", "RenameKey9", "value9"}},
+				command:          []string{"RENAMENX", "RenameKey9", "RenameKey10"},
+				expectedResponse: "1",
+				expectedValues:   map[string]string{"RenameKey9": "", "RenameKey10": "value9"},
+			},
+			{
+				name:          "6. Return error when the source does not exist",
+				command:       []string{"RENAME", "RenameKey11", "RenameKey12"},
+				expectedError: errors.New("no such key"),
+			},
+			{
+				name:          "7. Command too short",
+				command:       []string{"RENAME", "RenameKey11"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				for _, command := range test.preset {
+					writeCommand(t, client, command...)
+				}
+				res := writeCommand(t, client, test.command...)
+				if test.expectedError != nil {
+					if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got %v", test.expectedError.Error(), res)
+					}
+					return
+				}
+				if res.String() != test.expectedResponse {
+					t.Errorf("expected response %s, got %v", test.expectedResponse, res)
+				}
+				for key, value := range test.expectedValues {
+					got := writeCommand(t, client, "GET", key)
+					if (value == "" && !got.IsNull()) || (value != "" && got.String() != value) {
+						t.Errorf("expected value of %s to be \"%s\", got %v", key, value, got)
+					}
+				}
+				for key, ttl := range test.expectedTTL {
+					if got := writeCommand(t, client, "TTL", key); got.Integer() != ttl {
+						t.Errorf("expected TTL of %s to be %d, got %v", key, ttl, got)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandleCOPY", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		// Modifying a copied collection does not modify the source.
+		writeCommand(t, client, "RPUSH", "CopyList1", "a", "b")
+		writeCommand(t, client, "SADD", "CopySet1", "a", "b")
+		writeCommand(t, client, "HSET", "CopyHash1", "a", "1", "b", "2")
+		writeCommand(t, client, "ZADD", "CopyZset1", "1", "a", "2", "b")
+		collections := []struct {
+			source      string
+			destination string
+			modify      []string
+			length      []string
+		}{
+			{source: "CopyList1", destination: "CopyList2", modify: []string{"RPUSH", "CopyList2", "c"}, length: []string{"LLEN"}},
+			{source: "CopySet1", destination: "CopySet2", modify: []string{"SADD", "CopySet2", "c"}, length: []string{"SCARD"}},
+			{source: "CopyHash1", destination: "CopyHash2", modify: []string{"HSET", "CopyHash2", "c", "3"}, length: []string{"HLEN"}},
+			{source: "CopyZset1", destination: "CopyZset2", modify: []string{"ZADD", "CopyZset2", "3", "c"}, length: []string{"ZCARD"}},
+		}
+		for _, c := range collections {
+			if res := writeCommand(t, client, "COPY", c.source, c.destination); res.Integer() != 1 {
+				t.Errorf("expected %s to be copied, got %v", c.source, res)
+				continue
+			}
+			writeCommand(t, client, c.modify...)
+			if res := writeCommand(t, client, append(c.length, c.source)...); res.Integer() != 2 {
+				t.Errorf("expected %s to have 2 elements after modifying its copy, got %v", c.source, res)
+			}
+			if res := writeCommand(t, client, append(c.length, c.destination)...); res.Integer() != 3 {
+				t.Errorf("expected %s to have 3 elements, got %v", c.destination, res)
+			}
+		}
+
+		// The destination is only overwritten with REPLACE, and the expiry time is copied.
+		writeCommand(t, client, "SET", "CopyKey1", "value1", "EX", "100")
+		writeCommand(t, client, "SET", "CopyKey2", "value2")
+		if res := writeCommand(t, client, "COPY", "CopyKey1", "CopyKey2"); res.Integer() != 0 {
+			t.Errorf("expected 0 when the destination exists, got %v", res)
+		}
+		if res := writeCommand(t, client, "COPY", "CopyKey1", "CopyKey2", "REPLACE"); res.Integer() != 1 {
+			t.Errorf("expected 1 with REPLACE, got %v", res)
+		}
+		if res := writeCommand(t, client, "GET", "CopyKey2"); res.String() != "value1" {
+			t.Errorf("expected value1, got %v", res)
+		}
+		if res := writeCommand(t, client, "TTL", "CopyKey2"); res.Integer() != 100 {
+			t.Errorf("expected TTL 100, got %v", res)
+		}
+		if res := writeCommand(t, client, "COPY", "CopyKey3", "CopyKey4"); res.Integer() != 0 {
+			t.Errorf("expected 0 when the source does not exist, got %v", res)
+		}
+		if res := writeCommand(t, client, "COPY", "CopyKey1", "CopyKey2", "DB"); res.Error() == nil {
+			t.Errorf("expected syntax error, got %v", res)
+		}
+	})
+
+	t.Run("Test_HandleRANDOMKEY", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, "SET", "RandomKey1", "value")
+		for i := 0; i < 10; i++ {
+			res := writeCommand(t, client, "RANDOMKEY")
+			if res.IsNull() || res.String() == "" {
+				t.Errorf("expected a key, got %v", res)
+				return
+			}
+			if exists := writeCommand(t, client, "EXISTS", res.String()); exists.Integer() != 1 {
+				t.Errorf("expected random key %s to exist", res.String())
+			}
+		}
+	})
+
+	t.Run("Test_HandleTOUCH", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, "SET", "TouchKey1", "value")
+		writeCommand(t, client, "SET", "TouchKey2", "value")
+		if res := writeCommand(t, client, "TOUCH", "TouchKey1", "TouchKey2", "TouchKey3"); res.Integer() != 2 {
+			t.Errorf("expected 2, got %v", res)
+		}
+	})
+
+	t.Run("Test_HandleUNLINK", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, "SET", "UnlinkKey1", "value")
+		writeCommand(t, client, "RPUSH", "UnlinkKey2", "value")
+		if res := writeCommand(t, client, "UNLINK", "UnlinkKey1", "UnlinkKey2", "UnlinkKey3"); res.Integer() != 2 {
+			t.Errorf("expected 2, got %v", res)
+		}
+		if res := writeCommand(t, client, "EXISTS", "UnlinkKey1", "UnlinkKey2"); res.Integer() != 0 {
+			t.Errorf("expected the keys to be removed, got %v", res)
+		}
+	})
+}
+
+func writeCommand(t *testing.T, client *resp.Conn, command ...string) resp.Value {
+	values := make([]resp.Value, len(command))
+	for i, c := range command {
+		values[i] = resp.StringValue(c)
+	}
+	if err := client.WriteArray(values); err != nil {
+		t.Error(err)
+	}
+	res, _, err := client.ReadValue()
+	if err != nil {
+		t.Error(err)
+	}
+	return res
 }
@@ -17,7 +17,12 @@ package generic
 import (
 	"errors"
 	"fmt"
+	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/clock"
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
+	"github.com/echovault/echovault/internal/modules/set"
+	"github.com/echovault/echovault/internal/modules/sorted_set"
+	"github.com/echovault/echovault/internal/modules/stream"
 	"strconv"
 	"strings"
 	"time"
@@ -116,3 +121,61 @@ func getSetCommandOptions(clock clock.Clock, cmd []string, options SetOptions) (
 		return SetOptions{}, fmt.Errorf("unknown option %s for set command", strings.ToUpper(cmd[0]))
 	}
 }
+
+// getValueType returns the name of the data type of the value, as reported by TYPE and SCAN.
+// Values that are not one of the collection types are strings.
+func getValueType(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "hash"
+	case *set.Set:
+		return "set"
+	case *sorted_set.SortedSet:
+		return "zset"
+	case *stream.Stream:
+		return "stream"
+	default:
+		return "string"
+	}
+}
+
+// copyValue returns a deep copy of the value, so that modifying the copy does not modify the original.
+func copyValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return append(make([]interface{}, 0, len(v)), v...), nil
+	case map[string]interface{}:
+		hash := make(map[string]interface{}, len(v))
+		for field, fieldValue := range v {
+			hash[field] = fieldValue
+		}
+		return hash, nil
+	case *set.Set:
+		return set.NewSet(v.GetAll()), nil
+	case *sorted_set.SortedSet:
+		return sorted_set.NewSortedSet(v.GetAll()), nil
+	case *stream.Stream:
+		return v.Clone()
+	case *hyperloglog.HyperLogLog:
+		return v.Clone(), nil
+	default:
+		// Strings and numbers are immutable.
+		return v, nil
+	}
+}
+
+// getKey returns the value and the expiry time of the key, and whether the key exists.
+// A key that has expired at the command's current time does not exist. Checking the expiry against the
+// command's clock means every node in a raft cluster treats the key the same way, as they all use the leader's time.
+func getKey(params internal.HandlerFuncParams, key string) (interface{}, time.Time, bool) {
+	expireAt := params.GetExpiry(key)
+	if expireAt != (time.Time{}) && !expireAt.After(params.GetClock().Now()) {
+		// Reading the value removes the expired key.
+		params.GetValues(params.Context, []string{key})
+		return nil, time.Time{}, false
+	}
+	value := params.GetValues(params.Context, []string{key})[key]
+	return value, expireAt, value != nil
+}
@@ -461,6 +461,9 @@ func handleHGETALL(params internal.HandlerFuncParams) ([]byte, error) {
 	keyExists := params.KeysExist(keys.ReadKeys)[key]
 
 	if !keyExists {
+		if params.Protocol == constants.RESP3Protocol {
+			return []byte("%0\r\n"), nil
+		}
 		return []byte("*0\r\n"), nil
 	}
 
@@ -469,7 +472,11 @@ func handleHGETALL(params internal.HandlerFuncParams) ([]byte, error) {
 		return nil, fmt.Errorf("value at %s is not a hash", key)
 	}
 
+	// RESP3 connections receive a map, RESP2 connections receive a flat array of field-value pairs.
 	res := fmt.Sprintf("*%d\r\n", len(hash)*2)
+	if params.Protocol == constants.RESP3Protocol {
+		res = fmt.Sprintf("%%%d\r\n", len(hash))
+	}
 	for field, value := range hash {
 		res += fmt.Sprintf("$%d\r\n%s\r\n", len(field), field)
 		if s, ok := value.(string); ok {
@@ -548,6 +555,48 @@ func handleHDEL(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(fmt.Sprintf(":%d\r\n", count)), nil
 }
 
+func handleHSCAN(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := hscanKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := internal.ParseScanArgs(params.Command[2:], false)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.ReadKeys[0]
+	if !params.KeysExist(keys.ReadKeys)[key] {
+		return internal.EncodeScanResponse(0, []string{}), nil
+	}
+
+	hash, ok := params.GetValues(params.Context, []string{key})[key].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value at %s is not a hash", key)
+	}
+
+	index := params.GetScanIndex(key, func() *internal.ScanIndex {
+		index := internal.NewScanIndex()
+		for field := range hash {
+			index.Add(field)
+		}
+		return index
+	})
+	fields, cursor := index.Scan(options.Cursor, options.Count)
+
+	res := make([]string, 0, len(fields)*2)
+	for _, field := range fields {
+		// The hash may have been modified since it was read, so the index can hold fields that are not in it.
+		value, ok := hash[field]
+		if ok && options.Matches(field) {
+			res = append(res, field, fmt.Sprintf("%v", value))
+		}
+	}
+
+	return internal.EncodeScanResponse(cursor, res), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -671,5 +720,16 @@ Return the string length of the values stored at the specified fields. 0 if the
 			KeyExtractionFunc: hdelKeyFunc,
 			HandlerFunc:       handleHDEL,
 		},
+		{
+			Command:    "hscan",
+			Module:     constants.HashModule,
+			Categories: []string{constants.HashCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(HSCAN key cursor [MATCH pattern] [COUNT count])
+Incrementally iterates over the fields of the hash, returning the field-value pairs.
+Start the iteration with a cursor of 0, and call HSCAN again with the returned cursor until it returns a cursor of 0.`,
+			Sync:              false,
+			KeyExtractionFunc: hscanKeyFunc,
+			HandlerFunc:       handleHSCAN,
+		},
 	}
 }
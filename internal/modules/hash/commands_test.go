@@ -1771,4 +1771,152 @@ func Test_Hash(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandleRESP3", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			command  []string
+			expected string
+		}{
+			{command: []string{"HELLO", "3"}, expected: ""},
+			{command: []string{"HSET", "Resp3HashKey1", "field1", "value1"}, expected: ":1\r\n"},
+			{command: []string{"HGETALL", "Resp3HashKey1"}, expected: "%1\r\n$6\r\nfield1\r\n$6\r\nvalue1\r\n"},
+			{command: []string{"HGETALL", "Resp3HashKey2"}, expected: "%0\r\n"},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if test.expected == "" {
+				continue
+			}
+			if string(buf[:n]) != test.expected {
+				t.Errorf("%v: expected response %q, got %q", test.command, test.expected, string(buf[:n]))
+			}
+		}
+	})
+
+	t.Run("Test_HandleHSCAN", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		write := func(command ...string) resp.Value {
+			values := make([]resp.Value, len(command))
+			for i, c := range command {
+				values[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(values); err != nil {
+				t.Error(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+			}
+			return res
+		}
+
+		// scanAll runs HSCAN until the cursor returned is 0, and returns all the elements returned.
+		scanAll := func(key string, args ...string) []string {
+			var elements []string
+			cursor := "0"
+			for calls := 0; calls < 100; calls++ {
+				res := write(append([]string{"HSCAN", key, cursor}, args...)...)
+				if res.Error() != nil || len(res.Array()) != 2 {
+					t.Errorf("unexpected HSCAN response %v", res)
+					return elements
+				}
+				for _, element := range res.Array()[1].Array() {
+					elements = append(elements, element.String())
+				}
+				if cursor = res.Array()[0].String(); cursor == "0" {
+					return elements
+				}
+			}
+			t.Errorf("HSCAN did not complete")
+			return elements
+		}
+
+		var expected []string
+		preset := []string{"HSET", "HscanKey1"}
+		for i := 0; i < 20; i++ {
+			member := "member" + strconv.Itoa(i)
+			preset = append(preset, member, "value"+strconv.Itoa(i))
+			expected = append(expected, member, "value"+strconv.Itoa(i))
+		}
+		write(preset...)
+
+		elements := scanAll("HscanKey1", "COUNT", "3")
+		// The pairs are returned in the scan order, so sort them before comparing.
+		sortPairs := func(elements []string) []string {
+			pairs := make([][2]string, 0, len(elements)/2)
+			for i := 0; i+1 < len(elements); i += 2 {
+				pairs = append(pairs, [2]string{elements[i], elements[i+1]})
+			}
+			slices.SortFunc(pairs, func(a, b [2]string) int {
+				return strings.Compare(a[0], b[0])
+			})
+			sorted := make([]string, 0, len(elements))
+			for _, pair := range pairs {
+				sorted = append(sorted, pair[0], pair[1])
+			}
+			return sorted
+		}
+		elements, expected = sortPairs(elements), sortPairs(expected)
+		if !slices.Equal(elements, expected) {
+			t.Errorf("expected elements %v, got %v", expected, elements)
+		}
+
+		// Only return the elements matching the pattern.
+		if elements = scanAll("HscanKey1", "MATCH", "member1?"); len(elements) != 20 {
+			t.Errorf("expected 20 elements matching the pattern, got %v", elements)
+		}
+
+		// Return an empty list if the key does not exist.
+		if elements = scanAll("HscanKey2"); len(elements) != 0 {
+			t.Errorf("expected no elements, got %v", elements)
+		}
+
+		write("SET", "HscanKey3", "value")
+		errorTests := []struct {
+			command       []string
+			expectedError error
+		}{
+			{command: []string{"HSCAN", "HscanKey1"}, expectedError: errors.New(constants.WrongArgsResponse)},
+			{command: []string{"HSCAN", "HscanKey1", "cursor"}, expectedError: errors.New("invalid cursor")},
+			{command: []string{"HSCAN", "HscanKey1", "0", "COUNT", "0"}, expectedError: errors.New("syntax error")},
+			{command: []string{"HSCAN", "HscanKey1", "0", "TYPE", "string"}, expectedError: errors.New("syntax error")},
+			{command: []string{"HSCAN", "HscanKey3", "0"}, expectedError: errors.New("value at HscanKey3 is not a hash")},
+		}
+		for _, test := range errorTests {
+			res := write(test.command...)
+			if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+				t.Errorf("expected error \"%s\" for %v, got %v", test.expectedError.Error(), test.command, res)
+			}
+		}
+	})
 }
@@ -0,0 +1,162 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hyperloglog
+
+import (
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+)
+
+// getHyperLogLogs returns the HyperLogLogs at the given keys. Keys that do not exist are omitted from the result.
+func getHyperLogLogs(params internal.HandlerFuncParams, keys []string) (map[string]*HyperLogLog, error) {
+	result := make(map[string]*HyperLogLog, len(keys))
+	exists := params.KeysExist(keys)
+	values := params.GetValues(params.Context, keys)
+	for _, key := range keys {
+		if !exists[key] {
+			continue
+		}
+		hll, ok := values[key].(*HyperLogLog)
+		if !ok {
+			return nil, fmt.Errorf("value at key %s is not a hyperloglog", key)
+		}
+		result[key] = hll
+	}
+	return result, nil
+}
+
+func handlePFADD(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := pfaddKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	hlls, err := getHyperLogLogs(params, keys.WriteKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	hll, ok := hlls[key]
+	if !ok {
+		hll = NewHyperLogLog()
+		hll.Add(params.Command[2:]...)
+		if err = params.SetValues(params.Context, map[string]interface{}{key: hll}); err != nil {
+			return nil, err
+		}
+		return []byte(":1\r\n"), nil
+	}
+
+	if hll.Add(params.Command[2:]...) {
+		return []byte(":1\r\n"), nil
+	}
+	return []byte(":0\r\n"), nil
+}
+
+func handlePFCOUNT(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := pfcountKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	hlls, err := getHyperLogLogs(params, keys.ReadKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys.ReadKeys) == 1 {
+		hll, ok := hlls[keys.ReadKeys[0]]
+		if !ok {
+			return []byte(":0\r\n"), nil
+		}
+		return []byte(fmt.Sprintf(":%d\r\n", hll.Count())), nil
+	}
+
+	// With multiple keys, count the union of the HyperLogLogs without modifying them.
+	sources := make([]*HyperLogLog, 0, len(hlls))
+	for _, hll := range hlls {
+		sources = append(sources, hll)
+	}
+	return []byte(fmt.Sprintf(":%d\r\n", Union(sources...).Count())), nil
+}
+
+func handlePFMERGE(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := pfmergeKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	destination := keys.WriteKeys[0]
+	hlls, err := getHyperLogLogs(params, append([]string{destination}, keys.ReadKeys...))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]*HyperLogLog, 0, len(hlls))
+	for _, key := range keys.ReadKeys {
+		if hll, ok := hlls[key]; ok {
+			sources = append(sources, hll)
+		}
+	}
+
+	if hll, ok := hlls[destination]; ok {
+		hll.Merge(sources...)
+		return []byte(constants.OkResponse), nil
+	}
+
+	if err = params.SetValues(params.Context, map[string]interface{}{destination: Union(sources...)}); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func Commands() []internal.Command {
+	return []internal.Command{
+		{
+			Command:    "pfadd",
+			Module:     constants.HyperLogLogModule,
+			Categories: []string{constants.HyperLogLogCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(PFADD key [element [element ...]])
+Adds the elements to the HyperLogLog at the key. If the key does not exist, an empty HyperLogLog is created.
+Returns 1 if the HyperLogLog was created or its estimated cardinality changed, otherwise 0.`,
+			Sync:              true,
+			KeyExtractionFunc: pfaddKeyFunc,
+			HandlerFunc:       handlePFADD,
+		},
+		{
+			Command:    "pfcount",
+			Module:     constants.HyperLogLogModule,
+			Categories: []string{constants.HyperLogLogCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(PFCOUNT key [key ...])
+Returns the estimated cardinality of the HyperLogLog at the key. When multiple keys are given,
+returns the estimated cardinality of the union of the HyperLogLogs. Non-existent keys are treated as empty.`,
+			Sync:              false,
+			KeyExtractionFunc: pfcountKeyFunc,
+			HandlerFunc:       handlePFCOUNT,
+		},
+		{
+			Command:    "pfmerge",
+			Module:     constants.HyperLogLogModule,
+			Categories: []string{constants.HyperLogLogCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(PFMERGE destkey [sourcekey [sourcekey ...]])
+Merges the source HyperLogLogs into the destination so that its cardinality approximates the union of the sources.
+If the destination exists, it's included in the union.`,
+			Sync:              true,
+			KeyExtractionFunc: pfmergeKeyFunc,
+			HandlerFunc:       handlePFMERGE,
+		},
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hyperloglog_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/echovault"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/modules/hyperloglog"
+	"github.com/tidwall/resp"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_HyperLogLog(t *testing.T) {
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mockServer, err := echovault.NewEchoVault(
+		echovault.WithConfig(config.Config{
+			BindAddr:       "localhost",
+			Port:           uint16(port),
+			DataDir:        "",
+			EvictionPolicy: constants.NoEviction,
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		mockServer.Start()
+	}()
+
+	t.Cleanup(func() {
+		mockServer.ShutDown()
+	})
+
+	t.Run("Test_Estimate", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name         string
+			cardinality  int
+			wantEncoding string
+		}{
+			{name: "1. Small cardinality uses the sparse encoding", cardinality: 100, wantEncoding: hyperloglog.EncodingSparse},
+			{name: "2. Medium cardinality uses the dense encoding", cardinality: 10000, wantEncoding: hyperloglog.EncodingDense},
+			{name: "3. Large cardinality", cardinality: 500000, wantEncoding: hyperloglog.EncodingDense},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				hll := hyperloglog.NewHyperLogLog()
+				for i := 0; i < test.cardinality; i++ {
+					hll.Add(fmt.Sprintf("element-%d", i))
+				}
+				if hll.Encoding() != test.wantEncoding {
+					t.Errorf("expected encoding %s, got %s", test.wantEncoding, hll.Encoding())
+				}
+				// Allow 3 standard errors of the 0.81% standard error.
+				relativeError := math.Abs(float64(hll.Count())-float64(test.cardinality)) / float64(test.cardinality)
+				if relativeError > 0.0243 {
+					t.Errorf("expected count close to %d, got %d", test.cardinality, hll.Count())
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandlePFADD", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		tests := []struct {
+			name             string
+			presetValue      interface{}
+			key              string
+			command          []string
+			expectedResponse int
+			expectedCount    int
+			expectedError    error
+		}{
+			{
+				name:             "1. Create a new HyperLogLog on a non-existent key",
+				key:              "PfaddKey1",
+				command:          []string{"PFADD", "PfaddKey1", "a", "b", "c"},
+				expectedResponse: 1,
+				expectedCount:    3,
+				expectedError:    nil,
+			},
+			{
+				name:             "2. Create an empty HyperLogLog when no elements are provided",
+				key:              "PfaddKey2",
+				command:          []string{"PFADD", "PfaddKey2"},
+				expectedResponse: 1,
+				expectedCount:    0,
+				expectedError:    nil,
+			},
+			{
+				name:             "3. Return 0 when the elements are already present",
+				presetValue:      []string{"a", "b", "c"},
+				key:              "PfaddKey3",
+				command:          []string{"PFADD", "PfaddKey3", "a", "b"},
+				expectedResponse: 0,
+				expectedCount:    3,
+				expectedError:    nil,
+			},
+			{
+				name:          "4. Return error when the key does not hold a HyperLogLog",
+				presetValue:   "Default value",
+				key:           "PfaddKey4",
+				command:       []string{"PFADD", "PfaddKey4", "a"},
+				expectedError: errors.New("value at key PfaddKey4 is not a hyperloglog"),
+			},
+			{
+				name:          "5. Command too short",
+				key:           "PfaddKey5",
+				command:       []string{"PFADD"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				if test.presetValue != nil {
+					presetHyperLogLog(t, client, test.key, test.presetValue)
+				}
+
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%s\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if res.Integer() != test.expectedResponse {
+					t.Errorf("expected response %d, got %d", test.expectedResponse, res.Integer())
+				}
+
+				res = writeCommand(t, client, []string{"PFCOUNT", test.key})
+				if res.Integer() != test.expectedCount {
+					t.Errorf("expected count %d, got %d", test.expectedCount, res.Integer())
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandlePFCOUNT", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		presetHyperLogLog(t, client, "PfcountKey1", []string{"a", "b", "c"})
+		presetHyperLogLog(t, client, "PfcountKey2", []string{"c", "d", "e", "f"})
+		presetHyperLogLog(t, client, "PfcountKey3", "Default value")
+
+		tests := []struct {
+			name             string
+			command          []string
+			expectedResponse int
+			expectedError    error
+		}{
+			{
+				name:             "1. Return the estimated cardinality of a single HyperLogLog",
+				command:          []string{"PFCOUNT", "PfcountKey1"},
+				expectedResponse: 3,
+				expectedError:    nil,
+			},
+			{
+				name:             "2. Return 0 for a non-existent key",
+				command:          []string{"PFCOUNT", "PfcountNonExistent"},
+				expectedResponse: 0,
+				expectedError:    nil,
+			},
+			{
+				name:             "3. Return the estimated cardinality of the union of multiple HyperLogLogs",
+				command:          []string{"PFCOUNT", "PfcountKey1", "PfcountKey2", "PfcountNonExistent"},
+				expectedResponse: 6,
+				expectedError:    nil,
+			},
+			{
+				name:          "4. Return error when one of the keys does not hold a HyperLogLog",
+				command:       []string{"PFCOUNT", "PfcountKey1", "PfcountKey3"},
+				expectedError: errors.New("value at key PfcountKey3 is not a hyperloglog"),
+			},
+			{
+				name:          "5. Command too short",
+				command:       []string{"PFCOUNT"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%s\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if res.Integer() != test.expectedResponse {
+					t.Errorf("expected response %d, got %d", test.expectedResponse, res.Integer())
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandlePFMERGE", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		tests := []struct {
+			name          string
+			presetValues  map[string]interface{}
+			destination   string
+			command       []string
+			expectedCount int
+			expectedError error
+		}{
+			{
+				name: "1. Merge the sources into a new destination",
+				presetValues: map[string]interface{}{
+					"PfmergeKey1": []string{"a", "b", "c"},
+					"PfmergeKey2": []string{"c", "d"},
+				},
+				destination:   "PfmergeDestination1",
+				command:       []string{"PFMERGE", "PfmergeDestination1", "PfmergeKey1", "PfmergeKey2", "PfmergeNonExistent"},
+				expectedCount: 4,
+				expectedError: nil,
+			},
+			{
+				name: "2. Include the existing destination in the merge",
+				presetValues: map[string]interface{}{
+					"PfmergeDestination2": []string{"x", "y"},
+					"PfmergeKey3":         []string{"a", "x"},
+				},
+				destination:   "PfmergeDestination2",
+				command:       []string{"PFMERGE", "PfmergeDestination2", "PfmergeKey3"},
+				expectedCount: 3,
+				expectedError: nil,
+			},
+			{
+				name:          "3. Create an empty destination when there are no sources",
+				destination:   "PfmergeDestination3",
+				command:       []string{"PFMERGE", "PfmergeDestination3"},
+				expectedCount: 0,
+				expectedError: nil,
+			},
+			{
+				name: "4. Return error when a source does not hold a HyperLogLog",
+				presetValues: map[string]interface{}{
+					"PfmergeKey4": "Default value",
+				},
+				destination:   "PfmergeDestination4",
+				command:       []string{"PFMERGE", "PfmergeDestination4", "PfmergeKey4"},
+				expectedError: errors.New("value at key PfmergeKey4 is not a hyperloglog"),
+			},
+			{
+				name:          "5. Command too short",
+				command:       []string{"PFMERGE"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				for key, value := range test.presetValues {
+					presetHyperLogLog(t, client, key, value)
+				}
+
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%s\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if !strings.EqualFold(res.String(), "ok") {
+					t.Errorf("expected response OK, got %s", res.String())
+				}
+
+				res = writeCommand(t, client, []string{"PFCOUNT", test.destination})
+				if res.Integer() != test.expectedCount {
+					t.Errorf("expected count %d, got %d", test.expectedCount, res.Integer())
+				}
+			})
+		}
+	})
+}
+
+func writeCommand(t *testing.T, client *resp.Conn, command []string) resp.Value {
+	values := make([]resp.Value, len(command))
+	for i, c := range command {
+		values[i] = resp.StringValue(c)
+	}
+	if err := client.WriteArray(values); err != nil {
+		t.Error(err)
+	}
+	res, _, err := client.ReadValue()
+	if err != nil {
+		t.Error(err)
+	}
+	return res
+}
+
+// presetHyperLogLog stores a string with SET or adds a slice of elements with PFADD.
+func presetHyperLogLog(t *testing.T, client *resp.Conn, key string, value interface{}) {
+	var res resp.Value
+	switch v := value.(type) {
+	case string:
+		res = writeCommand(t, client, []string{"SET", key, v})
+	case []string:
+		res = writeCommand(t, client, append([]string{"PFADD", key}, v...))
+	}
+	if res.Error() != nil {
+		t.Error(res.Error())
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hyperloglog
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+const (
+	// precision is the number of hash bits used to select a register. It gives a standard error of 0.81%.
+	precision     = 14
+	registerCount = 1 << precision
+	registerBits  = 6
+	registerMax   = 1<<registerBits - 1
+	// q is the number of hash bits left to count the run of zeros.
+	q = 64 - precision
+	// denseSize is the size of the packed registers. An extra byte is allocated so that
+	// the last register can be read and written with the same two byte access as the others.
+	denseSize = registerCount*registerBits/8 + 1
+	// sparseMaxBytes is the size above which a sparse HyperLogLog is converted to the dense encoding.
+	sparseMaxBytes = 3000
+	// sparseMaxValue is the largest register value that can be stored in the sparse encoding.
+	sparseMaxValue = 32
+	alphaInf       = 0.721347520444481703680
+	hashSeed       = 0xadc83b19
+)
+
+const (
+	EncodingSparse = "sparse"
+	EncodingDense  = "dense"
+)
+
+// HyperLogLog is a probabilistic cardinality estimator with 2^14 registers.
+// It starts in a sparse encoding which only holds the registers that are set, and is converted
+// to a dense encoding of packed 6 bit registers once the sparse encoding grows past sparseMaxBytes.
+type HyperLogLog struct {
+	mutex sync.RWMutex
+	// sparse holds the non-zero registers ordered by index. Each entry is the register index
+	// shifted left by 8 bits OR'ed with the register value. It is nil when the encoding is dense.
+	sparse []uint32
+	// dense holds the packed registers. It is nil when the encoding is sparse.
+	dense []byte
+	// cardinality is the cached result of the last count. It's -1 when the registers have changed since.
+	cardinality int64
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{
+		sparse:      make([]uint32, 0),
+		cardinality: -1,
+	}
+}
+
+// Encoding returns either EncodingSparse or EncodingDense.
+func (hll *HyperLogLog) Encoding() string {
+	hll.mutex.RLock()
+	defer hll.mutex.RUnlock()
+	if hll.dense != nil {
+		return EncodingDense
+	}
+	return EncodingSparse
+}
+
+// Add hashes the elements into the registers.
+// Returns true if at least one register was altered.
+func (hll *HyperLogLog) Add(elements ...string) bool {
+	hll.mutex.Lock()
+	defer hll.mutex.Unlock()
+
+	updated := false
+	for _, element := range elements {
+		index, count := hashElement(element)
+		if hll.set(index, count) {
+			updated = true
+		}
+	}
+	if updated {
+		hll.cardinality = -1
+	}
+	return updated
+}
+
+// Count returns the estimated cardinality of the elements added to the HyperLogLog.
+func (hll *HyperLogLog) Count() int64 {
+	hll.mutex.Lock()
+	defer hll.mutex.Unlock()
+	if hll.cardinality < 0 {
+		hll.cardinality = estimate(hll.registers())
+	}
+	return hll.cardinality
+}
+
+// Merge sets every register to the maximum of its value and the values of the same register in others.
+func (hll *HyperLogLog) Merge(others ...*HyperLogLog) {
+	registers := Union(others...).registers()
+
+	hll.mutex.Lock()
+	defer hll.mutex.Unlock()
+	for index, value := range registers {
+		if value > 0 {
+			hll.set(index, value)
+		}
+	}
+	hll.cardinality = -1
+}
+
+// Union returns a new HyperLogLog that holds the maximum value of each register across all the given HyperLogLogs.
+// The count of the result is the estimated cardinality of the union of the sets they were built from.
+func Union(hlls ...*HyperLogLog) *HyperLogLog {
+	registers := make([]uint8, registerCount)
+	for _, hll := range hlls {
+		hll.mutex.RLock()
+		hll.forEachRegister(func(index int, value uint8) {
+			registers[index] = max(registers[index], value)
+		})
+		hll.mutex.RUnlock()
+	}
+
+	union := NewHyperLogLog()
+	for index, value := range registers {
+		if value > 0 {
+			union.set(index, value)
+		}
+	}
+	return union
+}
+
+// set raises the register at index to value if its current value is lower.
+// Returns true if the register was altered. The caller must hold the write lock.
+func (hll *HyperLogLog) set(index int, value uint8) bool {
+	if hll.dense != nil {
+		if getRegister(hll.dense, index) >= value {
+			return false
+		}
+		setRegister(hll.dense, index, value)
+		return true
+	}
+
+	i, found := slices.BinarySearchFunc(hll.sparse, index, func(entry uint32, index int) int {
+		return int(entry>>8) - index
+	})
+	if found {
+		if uint8(hll.sparse[i]) >= value {
+			return false
+		}
+		hll.sparse[i] = uint32(index)<<8 | uint32(value)
+	} else {
+		hll.sparse = slices.Insert(hll.sparse, i, uint32(index)<<8|uint32(value))
+	}
+
+	if value > sparseMaxValue || len(hll.sparse)*4 > sparseMaxBytes {
+		hll.promote()
+	}
+	return true
+}
+
+// promote converts the sparse encoding to the dense encoding.
+func (hll *HyperLogLog) promote() {
+	hll.dense = make([]byte, denseSize)
+	for _, entry := range hll.sparse {
+		setRegister(hll.dense, int(entry>>8), uint8(entry))
+	}
+	hll.sparse = nil
+}
+
+func (hll *HyperLogLog) forEachRegister(f func(index int, value uint8)) {
+	if hll.dense != nil {
+		for i := 0; i < registerCount; i++ {
+			if value := getRegister(hll.dense, i); value > 0 {
+				f(i, value)
+			}
+		}
+		return
+	}
+	for _, entry := range hll.sparse {
+		f(int(entry>>8), uint8(entry))
+	}
+}
+
+// registers returns the unpacked value of every register.
+func (hll *HyperLogLog) registers() []uint8 {
+	registers := make([]uint8, registerCount)
+	hll.forEachRegister(func(index int, value uint8) {
+		registers[index] = value
+	})
+	return registers
+}
+
+func getRegister(dense []byte, index int) uint8 {
+	b := index * registerBits / 8
+	fb := uint(index*registerBits) & 7
+	return uint8((uint(dense[b])>>fb | uint(dense[b+1])<<(8-fb)) & registerMax)
+}
+
+func setRegister(dense []byte, index int, value uint8) {
+	b := index * registerBits / 8
+	fb := uint(index*registerBits) & 7
+	v := uint(value)
+	dense[b] &^= byte(registerMax << fb)
+	dense[b] |= byte(v << fb)
+	dense[b+1] &^= byte(registerMax >> (8 - fb))
+	dense[b+1] |= byte(v >> (8 - fb))
+}
+
+// hashElement returns the register index of the element and the length of the run of zeros in the rest of its hash plus one.
+func hashElement(element string) (int, uint8) {
+	hash := murmurHash64A([]byte(element), hashSeed)
+	index := int(hash & (registerCount - 1))
+	// Set the bit after the last counted bit so that the count is at most q+1.
+	hash = hash>>precision | 1<<q
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+// estimate returns the cardinality using the improved estimator from Otmar Ertl's
+// "New cardinality estimation algorithms for HyperLogLog sketches", which is accurate across the whole range.
+func estimate(registers []uint8) int64 {
+	var histogram [q + 2]int
+	for _, value := range registers {
+		histogram[value]++
+	}
+
+	m := float64(registerCount)
+	z := m * tau((m-float64(histogram[q+1]))/m)
+	for j := q; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return int64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A is the 64-bit MurmurHash2 by Austin Appleby.
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(data))*m
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * i)
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// Size returns the number of bytes used by the registers in the current encoding.
+func (hll *HyperLogLog) Size() int {
+	hll.mutex.RLock()
+	defer hll.mutex.RUnlock()
+	return 4*len(hll.sparse) + len(hll.dense)
+}
+
+// Clone returns a deep copy of the HyperLogLog.
+func (hll *HyperLogLog) Clone() *HyperLogLog {
+	hll.mutex.RLock()
+	defer hll.mutex.RUnlock()
+	clone := &HyperLogLog{cardinality: hll.cardinality}
+	if hll.sparse != nil {
+		clone.sparse = append(make([]uint32, 0, len(hll.sparse)), hll.sparse...)
+	}
+	if hll.dense != nil {
+		clone.dense = append(make([]byte, 0, len(hll.dense)), hll.dense...)
+	}
+	return clone
+}
+
+type hyperLogLogJSON struct {
+	Sparse []uint32 `json:"sparse,omitempty"`
+	Dense  []byte   `json:"dense,omitempty"`
+}
+
+// MarshalJSON encodes the registers in the current encoding of the HyperLogLog.
+func (hll *HyperLogLog) MarshalJSON() ([]byte, error) {
+	hll.mutex.RLock()
+	defer hll.mutex.RUnlock()
+	return json.Marshal(hyperLogLogJSON{Sparse: hll.sparse, Dense: hll.dense})
+}
+
+// UnmarshalJSON restores a HyperLogLog encoded with MarshalJSON.
+func (hll *HyperLogLog) UnmarshalJSON(b []byte) error {
+	var h hyperLogLogJSON
+	if err := json.Unmarshal(b, &h); err != nil {
+		return err
+	}
+	if h.Dense != nil && len(h.Dense) != denseSize {
+		return errors.New("invalid dense hyperloglog size")
+	}
+
+	hll.mutex.Lock()
+	defer hll.mutex.Unlock()
+	hll.sparse, hll.dense, hll.cardinality = h.Sparse, h.Dense, -1
+	if hll.dense == nil && hll.sparse == nil {
+		hll.sparse = make([]uint32, 0)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseBlockTimeout parses the timeout of a blocking list command, which is given in seconds.
+func parseBlockTimeout(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, errors.New("timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, errors.New("timeout is negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// parseDirection parses LEFT or RIGHT. Returns true for LEFT.
+func parseDirection(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "left":
+		return true, nil
+	case "right":
+		return false, nil
+	default:
+		return false, errors.New("wherefrom and whereto arguments must be either LEFT or RIGHT")
+	}
+}
+
+// parseLMPopArgs parses the arguments of LMPOP that follow the command name, which are
+// also the arguments of BLMPOP that follow the timeout: numkeys key [key ...] <LEFT | RIGHT> [COUNT count].
+func parseLMPopArgs(args []string) ([]string, bool, int, error) {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, errors.New("numkeys should be greater than 0")
+	}
+	if len(args) < numKeys+2 {
+		return nil, false, 0, errors.New(constants.WrongArgsResponse)
+	}
+	keys := args[1 : numKeys+1]
+	left, err := parseDirection(args[numKeys+1])
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	count := 1
+	switch rest := args[numKeys+2:]; len(rest) {
+	case 0:
+	case 2:
+		if !strings.EqualFold(rest[0], "count") {
+			return nil, false, 0, errors.New("syntax error")
+		}
+		if count, err = strconv.Atoi(rest[1]); err != nil || count <= 0 {
+			return nil, false, 0, errors.New("count should be greater than 0")
+		}
+	default:
+		return nil, false, 0, errors.New("syntax error")
+	}
+
+	return keys, left, count, nil
+}
+
+// getList returns the list at the key. Returns nil if the key does not exist.
+func getList(params internal.HandlerFuncParams, key string) ([]interface{}, error) {
+	if !params.KeysExist([]string{key})[key] {
+		return nil, nil
+	}
+	list, ok := params.GetValues(params.Context, []string{key})[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value at key %s is not a list", key)
+	}
+	return list, nil
+}
+
+// setList stores the list at the key, or deletes the key if the list is empty.
+func setList(params internal.HandlerFuncParams, key string, list []interface{}) error {
+	if len(list) == 0 {
+		return params.DeleteKey(key)
+	}
+	return params.SetValues(params.Context, map[string]interface{}{key: list})
+}
+
+// listsHaveElements returns true if any of the lists has elements.
+func listsHaveElements(params internal.HandlerFuncParams, keys []string) (bool, error) {
+	for _, key := range keys {
+		list, err := getList(params, key)
+		if err != nil {
+			return false, err
+		}
+		if len(list) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// popFromLists pops up to count elements from the first non-empty list of the keys.
+// Returns the key that the elements were popped from, or an empty key if all the lists are empty.
+func popFromLists(params internal.HandlerFuncParams, keys []string, left bool, count int) (string, []interface{}, error) {
+	for _, key := range keys {
+		list, err := getList(params, key)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(list) == 0 {
+			continue
+		}
+
+		count = min(count, len(list))
+		popped := make([]interface{}, count)
+		if left {
+			copy(popped, list[:count])
+			list = append([]interface{}{}, list[count:]...)
+		} else {
+			// Elements popped from the right are returned in the order they were popped.
+			for i := range popped {
+				popped[i] = list[len(list)-1-i]
+			}
+			list = append([]interface{}{}, list[:len(list)-count]...)
+		}
+
+		if err = setList(params, key, list); err != nil {
+			return "", nil, err
+		}
+		return key, popped, nil
+	}
+	return "", nil, nil
+}
+
+// moveElement pops an element from the source list and pushes it to the destination list.
+// The destination list is created if it does not exist. Returns false if the source list is empty.
+func moveElement(params internal.HandlerFuncParams, source, destination string, fromLeft, toLeft bool) (interface{}, bool, error) {
+	sourceList, err := getList(params, source)
+	if err != nil {
+		return nil, false, err
+	}
+	destinationList, err := getList(params, destination)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(sourceList) == 0 {
+		return nil, false, nil
+	}
+
+	var element interface{}
+	if fromLeft {
+		element = sourceList[0]
+		sourceList = append([]interface{}{}, sourceList[1:]...)
+	} else {
+		element = sourceList[len(sourceList)-1]
+		sourceList = append([]interface{}{}, sourceList[:len(sourceList)-1]...)
+	}
+
+	if source == destination {
+		// Rotate the list.
+		destinationList = sourceList
+	}
+	if toLeft {
+		destinationList = append([]interface{}{element}, destinationList...)
+	} else {
+		destinationList = append(append([]interface{}{}, destinationList...), element)
+	}
+
+	if source != destination {
+		if err = setList(params, source, sourceList); err != nil {
+			return nil, false, err
+		}
+	}
+	if err = params.SetValues(params.Context, map[string]interface{}{destination: destinationList}); err != nil {
+		return nil, false, err
+	}
+	return element, true, nil
+}
+
+// waitForList calls pop until it returns a non-nil reply, the timeout is reached or the connection is closed.
+// The command is queued on the keys so that clients blocked on the same list are served in the order they blocked.
+// If the command is not allowed to block, pop is only called once. A timeout of 0 blocks indefinitely.
+func waitForList(params internal.HandlerFuncParams, keys []string, timeout time.Duration, pop func() ([]byte, error)) ([]byte, error) {
+	var notify <-chan struct{}
+	if params.QueueOnKeys != nil {
+		var cancel func()
+		notify, cancel = params.QueueOnKeys(keys)
+		defer cancel()
+	}
+
+	if params.ApplyCommand != nil {
+		// In cluster mode, the lists are only read on this node. The command is applied through raft, where it does
+		// not block, once one of the lists has elements.
+		pop = func() ([]byte, error) {
+			if ok, err := listsHaveElements(params, keys); err != nil || !ok {
+				return nil, err
+			}
+			res, err := params.ApplyCommand(params.Context, params.Command)
+			if err != nil || internal.IsNullResponse(res) {
+				return nil, err
+			}
+			return res, nil
+		}
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = params.GetClock().After(timeout)
+	}
+
+	for {
+		res, err := pop()
+		if err != nil || res != nil || notify == nil {
+			return res, err
+		}
+
+		if !params.Wait(notify, deadline) {
+			return nil, nil
+		}
+	}
+}
+
+func encodeElement(element interface{}) string {
+	s := fmt.Sprintf("%v", element)
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// encodePopResponse encodes the key and elements popped by LMPOP and BLMPOP.
+func encodePopResponse(key string, elements []interface{}) []byte {
+	res := fmt.Sprintf("*2\r\n$%d\r\n%s\r\n*%d\r\n", len(key), key, len(elements))
+	for _, element := range elements {
+		res += encodeElement(element)
+	}
+	return []byte(res)
+}
+
+func handleBPop(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := blpopKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseBlockTimeout(params.Command[len(params.Command)-1])
+	if err != nil {
+		return nil, err
+	}
+	left := strings.EqualFold(params.Command[0], "blpop")
+
+	res, err := waitForList(params, keys.WriteKeys, timeout, func() ([]byte, error) {
+		key, popped, err := popFromLists(params, keys.WriteKeys, left, 1)
+		if err != nil || key == "" {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("*2\r\n$%d\r\n%s\r\n%s", len(key), key, encodeElement(popped[0]))), nil
+	})
+	if err != nil || res != nil {
+		return res, err
+	}
+	return []byte("*-1\r\n"), nil
+}
+
+func handleBLMove(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := blmoveKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	fromLeft, err := parseDirection(params.Command[3])
+	if err != nil {
+		return nil, err
+	}
+	toLeft, err := parseDirection(params.Command[4])
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseBlockTimeout(params.Command[5])
+	if err != nil {
+		return nil, err
+	}
+	source, destination := keys.WriteKeys[0], keys.WriteKeys[1]
+
+	res, err := waitForList(params, []string{source}, timeout, func() ([]byte, error) {
+		element, moved, err := moveElement(params, source, destination, fromLeft, toLeft)
+		if err != nil || !moved {
+			return nil, err
+		}
+		return []byte(encodeElement(element)), nil
+	})
+	if err != nil || res != nil {
+		return res, err
+	}
+	return []byte("$-1\r\n"), nil
+}
+
+func handleLMPop(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := lmpopKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+	keys, left, count, err := parseLMPopArgs(params.Command[1:])
+	if err != nil {
+		return nil, err
+	}
+	key, popped, err := popFromLists(params, keys, left, count)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return []byte("*-1\r\n"), nil
+	}
+	return encodePopResponse(key, popped), nil
+}
+
+func handleBLMPop(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := blmpopKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+	timeout, err := parseBlockTimeout(params.Command[1])
+	if err != nil {
+		return nil, err
+	}
+	keys, left, count, err := parseLMPopArgs(params.Command[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := waitForList(params, keys, timeout, func() ([]byte, error) {
+		key, popped, err := popFromLists(params, keys, left, count)
+		if err != nil || key == "" {
+			return nil, err
+		}
+		return encodePopResponse(key, popped), nil
+	})
+	if err != nil || res != nil {
+		return res, err
+	}
+	return []byte("*-1\r\n"), nil
+}
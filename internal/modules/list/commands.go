@@ -493,6 +493,30 @@ Prepends a value to the beginning of a list only if the list exists.`,
 			KeyExtractionFunc: popKeyFunc,
 			HandlerFunc:       handlePop,
 		},
+		{
+			Command:    "blpop",
+			Module:     constants.ListModule,
+			Categories: []string{constants.ListCategory, constants.WriteCategory, constants.SlowCategory, constants.BlockingCategory},
+			Description: `(BLPOP key [key ...] timeout)
+Removes and returns the first element of the first non-empty list of the keys, along with the key.
+If all the lists are empty, blocks until an element is pushed to one of them or the timeout in seconds is reached.
+A timeout of 0 blocks indefinitely. Clients blocked on the same list are served in the order they blocked.`,
+			Sync:              true,
+			KeyExtractionFunc: blpopKeyFunc,
+			HandlerFunc:       handleBPop,
+		},
+		{
+			Command:    "brpop",
+			Module:     constants.ListModule,
+			Categories: []string{constants.ListCategory, constants.WriteCategory, constants.SlowCategory, constants.BlockingCategory},
+			Description: `(BRPOP key [key ...] timeout)
+Removes and returns the last element of the first non-empty list of the keys, along with the key.
+If all the lists are empty, blocks until an element is pushed to one of them or the timeout in seconds is reached.
+A timeout of 0 blocks indefinitely. Clients blocked on the same list are served in the order they blocked.`,
+			Sync:              true,
+			KeyExtractionFunc: blpopKeyFunc,
+			HandlerFunc:       handleBPop,
+		},
 		{
 			Command:           "llen",
 			Module:            constants.ListModule,
@@ -557,6 +581,39 @@ Move element from one list to the other specifying left/right for both lists.`,
 			KeyExtractionFunc: lmoveKeyFunc,
 			HandlerFunc:       handleLMove,
 		},
+		{
+			Command:    "blmove",
+			Module:     constants.ListModule,
+			Categories: []string{constants.ListCategory, constants.WriteCategory, constants.SlowCategory, constants.BlockingCategory},
+			Description: `(BLMOVE source destination <LEFT | RIGHT> <LEFT | RIGHT> timeout)
+Moves an element from the source list to the destination list, creating the destination list if it does not exist.
+If the source list is empty, blocks until an element is pushed to it or the timeout in seconds is reached.
+A timeout of 0 blocks indefinitely.`,
+			Sync:              true,
+			KeyExtractionFunc: blmoveKeyFunc,
+			HandlerFunc:       handleBLMove,
+		},
+		{
+			Command:    "lmpop",
+			Module:     constants.ListModule,
+			Categories: []string{constants.ListCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(LMPOP numkeys key [key ...] <LEFT | RIGHT> [COUNT count])
+Pops up to count elements from the first non-empty list of the keys. Returns the key and the popped elements.`,
+			Sync:              true,
+			KeyExtractionFunc: lmpopKeyFunc,
+			HandlerFunc:       handleLMPop,
+		},
+		{
+			Command:    "blmpop",
+			Module:     constants.ListModule,
+			Categories: []string{constants.ListCategory, constants.WriteCategory, constants.SlowCategory, constants.BlockingCategory},
+			Description: `(BLMPOP timeout numkeys key [key ...] <LEFT | RIGHT> [COUNT count])
+The blocking variant of LMPOP. If all the lists are empty, blocks until an element is pushed to one of them
+or the timeout in seconds is reached. A timeout of 0 blocks indefinitely.`,
+			Sync:              true,
+			KeyExtractionFunc: blmpopKeyFunc,
+			HandlerFunc:       handleBLMPop,
+		},
 		{
 			Command:           "rpop",
 			Module:            constants.ListModule,
@@ -21,10 +21,12 @@ import (
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/constants"
 	"github.com/tidwall/resp"
+	"net"
 	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func Test_List(t *testing.T) {
@@ -1790,4 +1792,391 @@ func Test_List(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandleLMPOP", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, []string{"RPUSH", "LmpopKey2", "a", "b", "c", "d"})
+		writeCommand(t, client, []string{"SET", "LmpopKey3", "value"})
+
+		tests := []struct {
+			name             string
+			command          []string
+			expectedKey      string
+			expectedElements []string
+			expectedError    error
+		}{
+			{
+				name:             "1. Pop from the left of the first non-empty list",
+				command:          []string{"LMPOP", "2", "LmpopKey1", "LmpopKey2", "LEFT"},
+				expectedKey:      "LmpopKey2",
+				expectedElements: []string{"a"},
+			},
+			{
+				name:             "2. Pop count elements from the right in the order they're popped",
+				command:          []string{"LMPOP", "1", "LmpopKey2", "RIGHT", "COUNT", "2"},
+				expectedKey:      "LmpopKey2",
+				expectedElements: []string{"d", "c"},
+			},
+			{
+				name:             "3. Pop the remaining elements when count is greater than the list length",
+				command:          []string{"LMPOP", "1", "LmpopKey2", "LEFT", "COUNT", "10"},
+				expectedKey:      "LmpopKey2",
+				expectedElements: []string{"b"},
+			},
+			{
+				name:        "4. Return null when all the lists are empty",
+				command:     []string{"LMPOP", "2", "LmpopKey1", "LmpopKey2", "LEFT"},
+				expectedKey: "",
+			},
+			{
+				name:          "5. Return error when the key is not a list",
+				command:       []string{"LMPOP", "1", "LmpopKey3", "LEFT"},
+				expectedError: errors.New("value at key LmpopKey3 is not a list"),
+			},
+			{
+				name:          "6. Return error when numkeys is not greater than 0",
+				command:       []string{"LMPOP", "0", "LmpopKey1", "LEFT"},
+				expectedError: errors.New("numkeys should be greater than 0"),
+			},
+			{
+				name:          "7. Return error when the direction is invalid",
+				command:       []string{"LMPOP", "1", "LmpopKey1", "UP"},
+				expectedError: errors.New("wherefrom and whereto arguments must be either LEFT or RIGHT"),
+			},
+			{
+				name:          "8. Return error when count is not greater than 0",
+				command:       []string{"LMPOP", "1", "LmpopKey1", "LEFT", "COUNT", "0"},
+				expectedError: errors.New("count should be greater than 0"),
+			},
+			{
+				name:          "9. Command too short",
+				command:       []string{"LMPOP", "1", "LmpopKey1"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%v\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if test.expectedKey == "" {
+					if !res.IsNull() {
+						t.Errorf("expected null response, got %v", res)
+					}
+					return
+				}
+				if len(res.Array()) != 2 || res.Array()[0].String() != test.expectedKey {
+					t.Errorf("expected key \"%s\", got %v", test.expectedKey, res)
+					return
+				}
+				elements := make([]string, len(res.Array()[1].Array()))
+				for i, element := range res.Array()[1].Array() {
+					elements[i] = element.String()
+				}
+				if !slices.Equal(elements, test.expectedElements) {
+					t.Errorf("expected elements %v, got %v", test.expectedElements, elements)
+				}
+			})
+		}
+
+		// The key is deleted once its last element is popped.
+		if res := writeCommand(t, client, []string{"LLEN", "LmpopKey2"}); res.Integer() != 0 {
+			t.Errorf("expected empty list to be deleted, got length %d", res.Integer())
+		}
+	})
+
+	t.Run("Test_HandleBLPOP", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		writeCommand(t, client, []string{"RPUSH", "BlpopKey2", "a", "b", "c"})
+		writeCommand(t, client, []string{"SET", "BlpopKey3", "value"})
+
+		tests := []struct {
+			name          string
+			command       []string
+			expected      []string
+			expectedError error
+		}{
+			{
+				name:     "1. BLPOP pops from the first non-empty list without blocking",
+				command:  []string{"BLPOP", "BlpopKey1", "BlpopKey2", "1"},
+				expected: []string{"BlpopKey2", "a"},
+			},
+			{
+				name:     "2. BRPOP pops from the right of the list",
+				command:  []string{"BRPOP", "BlpopKey1", "BlpopKey2", "1"},
+				expected: []string{"BlpopKey2", "c"},
+			},
+			{
+				name:     "3. Return null when the timeout is reached",
+				command:  []string{"BLPOP", "BlpopKey1", "0.05"},
+				expected: nil,
+			},
+			{
+				name:          "4. Return error when the key is not a list",
+				command:       []string{"BLPOP", "BlpopKey3", "1"},
+				expectedError: errors.New("value at key BlpopKey3 is not a list"),
+			},
+			{
+				name:          "5. Return error when the timeout is negative",
+				command:       []string{"BLPOP", "BlpopKey1", "-1"},
+				expectedError: errors.New("timeout is negative"),
+			},
+			{
+				name:          "6. Return error when the timeout is not a number",
+				command:       []string{"BLPOP", "BlpopKey1", "soon"},
+				expectedError: errors.New("timeout is not a float or out of range"),
+			},
+			{
+				name:          "7. Command too short",
+				command:       []string{"BLPOP", "BlpopKey1"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%v\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if test.expected == nil {
+					if !res.IsNull() {
+						t.Errorf("expected null response, got %v", res)
+					}
+					return
+				}
+				got := []string{}
+				for _, v := range res.Array() {
+					got = append(got, v.String())
+				}
+				if !slices.Equal(got, test.expected) {
+					t.Errorf("expected response %v, got %v", test.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandleBLPOP_Blocking", func(t *testing.T) {
+		t.Parallel()
+		var conns []net.Conn
+		var clients []*resp.Conn
+		for i := 0; i < 4; i++ {
+			conn, err := internal.GetConnection("localhost", port)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() {
+				_ = conn.Close()
+			}()
+			conns = append(conns, conn)
+			clients = append(clients, resp.NewConn(conn))
+		}
+		pusher := clients[0]
+
+		// Block two clients on the same list, one after the other.
+		results := make([]chan resp.Value, 2)
+		for i, client := range clients[1:3] {
+			results[i] = make(chan resp.Value, 1)
+			if err := client.WriteArray([]resp.Value{
+				resp.StringValue("BLPOP"), resp.StringValue("BlpopBlockingKey1"), resp.StringValue("0"),
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+			go func(client *resp.Conn, result chan resp.Value) {
+				res, _, _ := client.ReadValue()
+				result <- res
+			}(client, results[i])
+			// Give the command time to block.
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		// The clients are served in the order they blocked.
+		for i, element := range []string{"first", "second"} {
+			writeCommand(t, pusher, []string{"RPUSH", "BlpopBlockingKey1", element})
+			select {
+			case res := <-results[i]:
+				if len(res.Array()) != 2 || res.Array()[1].String() != element {
+					t.Errorf("expected client %d to pop \"%s\", got %v", i+1, element, res)
+				}
+			case <-time.After(2 * time.Second):
+				t.Errorf("client %d was not woken", i+1)
+				return
+			}
+		}
+
+		// A client that disconnects while blocked does not consume the next element.
+		if err := clients[3].WriteArray([]resp.Value{
+			resp.StringValue("BLPOP"), resp.StringValue("BlpopBlockingKey2"), resp.StringValue("0"),
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		// Give the command time to block.
+		time.Sleep(100 * time.Millisecond)
+		_ = conns[3].Close()
+		time.Sleep(100 * time.Millisecond)
+		writeCommand(t, pusher, []string{"RPUSH", "BlpopBlockingKey2", "element"})
+		time.Sleep(100 * time.Millisecond)
+		if res := writeCommand(t, pusher, []string{"LLEN", "BlpopBlockingKey2"}); res.Integer() != 1 {
+			t.Errorf("expected the element to remain in the list, got length %d", res.Integer())
+		}
+	})
+
+	t.Run("Test_HandleBLMOVE", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		pusherConn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = pusherConn.Close()
+		}()
+		pusher := resp.NewConn(pusherConn)
+
+		// Move an element to a destination that does not exist.
+		writeCommand(t, client, []string{"RPUSH", "BlmoveSource1", "a", "b"})
+		res := writeCommand(t, client, []string{"BLMOVE", "BlmoveSource1", "BlmoveDestination1", "RIGHT", "LEFT", "1"})
+		if res.String() != "b" {
+			t.Errorf("expected response \"b\", got %v", res)
+		}
+		res = writeCommand(t, client, []string{"LRANGE", "BlmoveDestination1", "0", "-1"})
+		if len(res.Array()) != 1 || res.Array()[0].String() != "b" {
+			t.Errorf("expected destination list [b], got %v", res.Array())
+		}
+
+		// Return null when the timeout is reached.
+		if res = writeCommand(t, client, []string{"BLMOVE", "BlmoveSource2", "BlmoveDestination2", "LEFT", "LEFT", "0.05"}); !res.IsNull() {
+			t.Errorf("expected null response, got %v", res)
+		}
+
+		// Block until an element is pushed to the source list.
+		if err = client.WriteArray([]resp.Value{
+			resp.StringValue("BLMOVE"), resp.StringValue("BlmoveSource2"), resp.StringValue("BlmoveDestination2"),
+			resp.StringValue("LEFT"), resp.StringValue("RIGHT"), resp.StringValue("0"),
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		// Give the command time to block.
+		time.Sleep(100 * time.Millisecond)
+		writeCommand(t, pusher, []string{"LPUSH", "BlmoveSource2", "c"})
+		if res, _, err = client.ReadValue(); err != nil || res.String() != "c" {
+			t.Errorf("expected response \"c\", got %v (%v)", res, err)
+		}
+
+		// Return error when the direction is invalid.
+		res = writeCommand(t, client, []string{"BLMOVE", "BlmoveSource1", "BlmoveDestination1", "UP", "LEFT", "1"})
+		if res.Error() == nil || !strings.Contains(res.Error().Error(), "wherefrom and whereto arguments must be either LEFT or RIGHT") {
+			t.Errorf("expected direction error, got %v", res)
+		}
+	})
+
+	t.Run("Test_HandleBLMPOP", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		pusherConn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = pusherConn.Close()
+		}()
+		pusher := resp.NewConn(pusherConn)
+
+		if res := writeCommand(t, client, []string{"BLMPOP", "0.05", "1", "BlmpopKey1", "LEFT"}); !res.IsNull() {
+			t.Errorf("expected null response, got %v", res)
+		}
+
+		if err = client.WriteArray([]resp.Value{
+			resp.StringValue("BLMPOP"), resp.StringValue("0"), resp.StringValue("2"),
+			resp.StringValue("BlmpopKey1"), resp.StringValue("BlmpopKey2"),
+			resp.StringValue("LEFT"), resp.StringValue("COUNT"), resp.StringValue("2"),
+		}); err != nil {
+			t.Error(err)
+			return
+		}
+		// Give the command time to block.
+		time.Sleep(100 * time.Millisecond)
+		writeCommand(t, pusher, []string{"RPUSH", "BlmpopKey2", "a", "b", "c"})
+		res, _, err := client.ReadValue()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(res.Array()) != 2 || res.Array()[0].String() != "BlmpopKey2" || len(res.Array()[1].Array()) != 2 ||
+			res.Array()[1].Array()[0].String() != "a" || res.Array()[1].Array()[1].String() != "b" {
+			t.Errorf("expected response [BlmpopKey2 [a b]], got %v", res)
+		}
+
+		res = writeCommand(t, client, []string{"BLMPOP", "-1", "1", "BlmpopKey1", "LEFT"})
+		if res.Error() == nil || !strings.Contains(res.Error().Error(), "timeout is negative") {
+			t.Errorf("expected timeout error, got %v", res)
+		}
+	})
+}
+
+func writeCommand(t *testing.T, client *resp.Conn, command []string) resp.Value {
+	values := make([]resp.Value, len(command))
+	for i, c := range command {
+		values[i] = resp.StringValue(c)
+	}
+	if err := client.WriteArray(values); err != nil {
+		t.Error(err)
+	}
+	res, _, err := client.ReadValue()
+	if err != nil {
+		t.Error(err)
+	}
+	return res
 }
@@ -15,19 +15,27 @@
 package pubsub
 
 import (
+	"fmt"
+	"github.com/echovault/echovault/internal/constants"
 	"github.com/gobwas/glob"
-	"github.com/tidwall/resp"
 	"log"
 	"net"
 	"sync"
 )
 
 type Channel struct {
-	name             string                   // Channel name. This can be a glob pattern string.
-	pattern          glob.Glob                // Compiled glob pattern. This is nil if the channel is not a pattern channel.
-	subscribersRWMut sync.RWMutex             // RWMutex to concurrency control when accessing channel subscribers.
-	subscribers      map[*net.Conn]*resp.Conn // Map containing the channel subscribers.
-	messageChan      *chan string             // Messages published to this channel will be sent to this channel.
+	name             string            // Channel name. This can be a glob pattern string.
+	pattern          glob.Glob         // Compiled glob pattern. This is nil if the channel is not a pattern channel.
+	subscribersRWMut sync.RWMutex      // RWMutex to concurrency control when accessing channel subscribers.
+	subscribers      map[*net.Conn]int // Map containing the channel subscribers and the RESP protocol version of each one.
+	messageChan      *chan publication // Messages published to this channel will be sent to this channel.
+}
+
+// publication is a message published to a channel. The channel is the name the message was published to,
+// which differs from the channel's name when the channel is a pattern channel.
+type publication struct {
+	channel string
+	message string
 }
 
 // WithName option sets the channels name.
@@ -46,13 +54,13 @@ func WithPattern(pattern string) func(channel *Channel) {
 }
 
 func NewChannel(options ...func(channel *Channel)) *Channel {
-	messageChan := make(chan string, 4096)
+	messageChan := make(chan publication, 4096)
 
 	channel := &Channel{
 		name:             "",
 		pattern:          nil,
 		subscribersRWMut: sync.RWMutex{},
-		subscribers:      make(map[*net.Conn]*resp.Conn),
+		subscribers:      make(map[*net.Conn]int),
 		messageChan:      &messageChan,
 	}
 
@@ -66,20 +74,21 @@ func NewChannel(options ...func(channel *Channel)) *Channel {
 func (ch *Channel) Start() {
 	go func() {
 		for {
-			message := <-*ch.messageChan
+			p := <-*ch.messageChan
 
 			ch.subscribersRWMut.RLock()
 
-			for _, conn := range ch.subscribers {
-				go func(conn *resp.Conn) {
-					if err := conn.WriteArray([]resp.Value{
-						resp.StringValue("message"),
-						resp.StringValue(ch.name),
-						resp.StringValue(message),
-					}); err != nil {
+			for conn, protocol := range ch.subscribers {
+				go func(conn *net.Conn, protocol int) {
+					// Pattern subscribers also receive the name of the channel that matched the pattern.
+					message := encodeMessage(protocol, "message", ch.name, p.message)
+					if ch.pattern != nil {
+						message = encodeMessage(protocol, "pmessage", ch.name, p.channel, p.message)
+					}
+					if _, err := (*conn).Write(message); err != nil {
 						log.Println(err)
 					}
-				}(conn)
+				}(conn, protocol)
 			}
 
 			ch.subscribersRWMut.RUnlock()
@@ -95,11 +104,11 @@ func (ch *Channel) Pattern() glob.Glob {
 	return ch.pattern
 }
 
-func (ch *Channel) Subscribe(conn *net.Conn) bool {
+func (ch *Channel) Subscribe(conn *net.Conn, protocol int) bool {
 	ch.subscribersRWMut.Lock()
 	defer ch.subscribersRWMut.Unlock()
 	if _, ok := ch.subscribers[conn]; !ok {
-		ch.subscribers[conn] = resp.NewConn(*conn)
+		ch.subscribers[conn] = protocol
 	}
 	_, ok := ch.subscribers[conn]
 	return ok
@@ -115,8 +124,20 @@ func (ch *Channel) Unsubscribe(conn *net.Conn) bool {
 	return true
 }
 
-func (ch *Channel) Publish(message string) {
-	*ch.messageChan <- message
+// Publish sends the message that was published to the channel name to the subscribers of the channel.
+func (ch *Channel) Publish(channel string, message string) {
+	*ch.messageChan <- publication{channel: channel, message: message}
+}
+
+// TryPublish is the same as Publish, but it drops the message instead of waiting when the channel's buffer is full.
+// Returns false if the message was dropped.
+func (ch *Channel) TryPublish(channel string, message string) bool {
+	select {
+	case *ch.messageChan <- publication{channel: channel, message: message}:
+		return true
+	default:
+		return false
+	}
 }
 
 func (ch *Channel) IsActive() bool {
@@ -137,14 +158,28 @@ func (ch *Channel) NumSubs() int {
 	return n
 }
 
-func (ch *Channel) Subscribers() map[*net.Conn]*resp.Conn {
+func (ch *Channel) Subscribers() map[*net.Conn]int {
 	ch.subscribersRWMut.RLock()
 	defer ch.subscribersRWMut.RUnlock()
 
-	subscribers := make(map[*net.Conn]*resp.Conn, len(ch.subscribers))
+	subscribers := make(map[*net.Conn]int, len(ch.subscribers))
 	for k, v := range ch.subscribers {
 		subscribers[k] = v
 	}
 
 	return subscribers
 }
+
+// encodeMessage encodes a pub/sub message with the given kind (e.g. "message", "pmessage") followed by the fields.
+// RESP3 subscribers receive push messages, RESP2 subscribers receive arrays.
+func encodeMessage(protocol int, kind string, fields ...string) []byte {
+	prefix := "*"
+	if protocol == constants.RESP3Protocol {
+		prefix = ">"
+	}
+	res := fmt.Sprintf("%s%d\r\n$%d\r\n%s\r\n", prefix, len(fields)+1, len(kind), kind)
+	for _, field := range fields {
+		res += fmt.Sprintf("$%d\r\n%s\r\n", len(field), field)
+	}
+	return []byte(res)
+}
@@ -35,7 +35,7 @@ func handleSubscribe(params internal.HandlerFuncParams) ([]byte, error) {
 	}
 
 	withPattern := strings.EqualFold(params.Command[0], "psubscribe")
-	pubsub.Subscribe(params.Context, params.Connection, channels, withPattern)
+	pubsub.Subscribe(params.Context, params.Connection, channels, withPattern, params.Protocol)
 
 	return nil, nil
 }
@@ -50,7 +50,7 @@ func handleUnsubscribe(params internal.HandlerFuncParams) ([]byte, error) {
 
 	withPattern := strings.EqualFold(params.Command[0], "punsubscribe")
 
-	return pubsub.Unsubscribe(params.Context, params.Connection, channels, withPattern), nil
+	return pubsub.Unsubscribe(params.Context, params.Connection, channels, withPattern, params.Protocol), nil
 }
 
 func handlePublish(params internal.HandlerFuncParams) ([]byte, error) {
@@ -19,6 +19,7 @@ import (
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/constants"
+	"github.com/echovault/echovault/internal/modules/pubsub"
 	"github.com/tidwall/resp"
 	"net"
 	"slices"
@@ -374,6 +375,10 @@ func Test_PubSub(t *testing.T) {
 				t.Error(err)
 			}
 			v := rv.Array()
+			if len(v) != len(expected) {
+				t.Errorf("expected %d items, got %d", len(expected), len(v))
+				return
+			}
 			for i := 0; i < len(v); i++ {
 				if v[i].String() != expected[i] {
 					t.Errorf("expected item at index %d to be \"%s\", got \"%s\"", i, expected[i], v[i].String())
@@ -541,6 +546,11 @@ func Test_PubSub(t *testing.T) {
 			}
 
 			for _, sub := range test.subscribers {
+				if sub.channel != test.channel {
+					// The subscriber received the message through a pattern subscription.
+					verifyEvent(sub.client, []string{"pmessage", sub.channel, test.channel, test.message})
+					continue
+				}
 				verifyEvent(sub.client, []string{"message", sub.channel, test.message})
 			}
 		}
@@ -1010,4 +1020,83 @@ func Test_PubSub(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_RESP3PushMessages", func(t *testing.T) {
+		t.Parallel()
+
+		subscriber, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		publisher, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = subscriber.Close()
+			_ = publisher.Close()
+		}()
+
+		buf := make([]byte, 1024)
+		read := func(conn net.Conn) string {
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+			}
+			return string(buf[:n])
+		}
+
+		// Switch the subscriber to RESP3.
+		if _, err = subscriber.Write(internal.EncodeCommand([]string{"HELLO", "3"})); err != nil {
+			t.Error(err)
+			return
+		}
+		_ = read(subscriber)
+
+		// The subscription confirmation should be a push message.
+		if _, err = subscriber.Write(internal.EncodeCommand([]string{"SUBSCRIBE", "resp3_channel1"})); err != nil {
+			t.Error(err)
+			return
+		}
+		expected := ">3\r\n$9\r\nsubscribe\r\n$14\r\nresp3_channel1\r\n:1\r\n"
+		if res := read(subscriber); res != expected {
+			t.Errorf("expected subscribe response %q, got %q", expected, res)
+		}
+
+		// Published messages should be push messages.
+		if _, err = publisher.Write(internal.EncodeCommand([]string{"PUBLISH", "resp3_channel1", "hello"})); err != nil {
+			t.Error(err)
+			return
+		}
+		_ = read(publisher)
+		expected = ">3\r\n$7\r\nmessage\r\n$14\r\nresp3_channel1\r\n$5\r\nhello\r\n"
+		if res := read(subscriber); res != expected {
+			t.Errorf("expected message %q, got %q", expected, res)
+		}
+
+		// The unsubscribe confirmation should be a push message.
+		if _, err = subscriber.Write(internal.EncodeCommand([]string{"UNSUBSCRIBE", "resp3_channel1"})); err != nil {
+			t.Error(err)
+			return
+		}
+		expected = ">3\r\n$11\r\nunsubscribe\r\n$14\r\nresp3_channel1\r\n:1\r\n"
+		if res := read(subscriber); res != expected {
+			t.Errorf("expected unsubscribe response %q, got %q", expected, res)
+		}
+	})
+}
+
+func Test_ChannelTryPublish(t *testing.T) {
+	// The channel is not started, so nothing reads its buffer.
+	channel := pubsub.NewChannel(pubsub.WithName("channel"))
+	for i := 0; i < 4096; i++ {
+		if !channel.TryPublish("channel", "message") {
+			t.Fatalf("expected message %d to be buffered", i)
+		}
+	}
+	if channel.TryPublish("channel", "message") {
+		t.Error("expected the message to be dropped when the buffer is full")
+	}
 }
@@ -17,17 +17,19 @@ package pubsub
 import (
 	"context"
 	"fmt"
+	"github.com/echovault/echovault/internal/constants"
 	"github.com/gobwas/glob"
-	"github.com/tidwall/resp"
 	"log"
 	"net"
 	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 type PubSub struct {
 	channels      []*Channel
 	channelsRWMut sync.RWMutex
+	dropped       atomic.Uint64 // The number of notifications dropped because a channel's buffer was full.
 }
 
 func NewPubSub() *PubSub {
@@ -37,12 +39,21 @@ func NewPubSub() *PubSub {
 	}
 }
 
-func (ps *PubSub) Subscribe(_ context.Context, conn *net.Conn, channels []string, withPattern bool) {
+func (ps *PubSub) Subscribe(_ context.Context, conn *net.Conn, channels []string, withPattern bool, protocol int) {
+	// The confirmations are written once the channels are unlocked, so that a slow subscriber does not hold up
+	// the publishers.
+	var confirmations [][]byte
+	defer func() {
+		for _, confirmation := range confirmations {
+			if _, err := (*conn).Write(confirmation); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+
 	ps.channelsRWMut.Lock()
 	defer ps.channelsRWMut.Unlock()
 
-	r := resp.NewConn(*conn)
-
 	action := "subscribe"
 	if withPattern {
 		action = "psubscribe"
@@ -65,32 +76,20 @@ func (ps *PubSub) Subscribe(_ context.Context, conn *net.Conn, channels []string
 				newChan = NewChannel(WithName(channels[i]))
 			}
 			newChan.Start()
-			if newChan.Subscribe(conn) {
-				if err := r.WriteArray([]resp.Value{
-					resp.StringValue(action),
-					resp.StringValue(newChan.name),
-					resp.IntegerValue(i + 1),
-				}); err != nil {
-					log.Println(err)
-				}
+			if newChan.Subscribe(conn, protocol) {
+				confirmations = append(confirmations, encodeSubscription(protocol, action, newChan.name, i+1))
 				ps.channels = append(ps.channels, newChan)
 			}
 		} else {
 			// Subscribe to existing channel
-			if ps.channels[channelIdx].Subscribe(conn) {
-				if err := r.WriteArray([]resp.Value{
-					resp.StringValue(action),
-					resp.StringValue(ps.channels[channelIdx].name),
-					resp.IntegerValue(i + 1),
-				}); err != nil {
-					log.Println(err)
-				}
+			if ps.channels[channelIdx].Subscribe(conn, protocol) {
+				confirmations = append(confirmations, encodeSubscription(protocol, action, ps.channels[channelIdx].name, i+1))
 			}
 		}
 	}
 }
 
-func (ps *PubSub) Unsubscribe(_ context.Context, conn *net.Conn, channels []string, withPattern bool) []byte {
+func (ps *PubSub) Unsubscribe(_ context.Context, conn *net.Conn, channels []string, withPattern bool, protocol int) []byte {
 	ps.channelsRWMut.RLock()
 	defer ps.channelsRWMut.RUnlock()
 
@@ -167,6 +166,18 @@ func (ps *PubSub) Unsubscribe(_ context.Context, conn *net.Conn, channels []stri
 		}
 	}
 
+	// RESP3 connections receive a push message for each unsubscribed channel.
+	if protocol == constants.RESP3Protocol {
+		if len(unsubscribed) == 0 {
+			return []byte(fmt.Sprintf(">3\r\n$%d\r\n%s\r\n_\r\n:0\r\n", len(action), action))
+		}
+		var res []byte
+		for key, value := range unsubscribed {
+			res = append(res, encodeSubscription(protocol, action, value, key)...)
+		}
+		return res
+	}
+
 	res := fmt.Sprintf("*%d\r\n", len(unsubscribed))
 	for key, value := range unsubscribed {
 		res += fmt.Sprintf("*3\r\n+%s\r\n$%d\r\n%s\r\n:%d\r\n", action, len(value), value, key)
@@ -176,6 +187,29 @@ func (ps *PubSub) Unsubscribe(_ context.Context, conn *net.Conn, channels []stri
 }
 
 func (ps *PubSub) Publish(_ context.Context, message string, channelName string) {
+	ps.eachMatchingChannel(channelName, func(channel *Channel) {
+		channel.Publish(channelName, message)
+	})
+}
+
+// Notify publishes the message like Publish, but it never waits for a channel whose buffer is full.
+// The message is dropped for the subscribers of such a channel, so that Notify can be called while the keyspace
+// is locked. The dropped messages are counted by DroppedNotifications.
+func (ps *PubSub) Notify(_ context.Context, message string, channelName string) {
+	ps.eachMatchingChannel(channelName, func(channel *Channel) {
+		if !channel.TryPublish(channelName, message) {
+			ps.dropped.Add(1)
+		}
+	})
+}
+
+// DroppedNotifications returns the number of messages dropped by Notify.
+func (ps *PubSub) DroppedNotifications() uint64 {
+	return ps.dropped.Load()
+}
+
+// eachMatchingChannel calls f for each channel with the name, and for each pattern channel that matches the name.
+func (ps *PubSub) eachMatchingChannel(channelName string, f func(channel *Channel)) {
 	ps.channelsRWMut.RLock()
 	defer ps.channelsRWMut.RUnlock()
 
@@ -183,13 +217,13 @@ func (ps *PubSub) Publish(_ context.Context, message string, channelName string)
 		// If it's a regular channel, check if the channel name matches the name given
 		if channel.pattern == nil {
 			if channel.name == channelName {
-				channel.Publish(message)
+				f(channel)
 			}
 			continue
 		}
 		// If it's a glob pattern channel, check if the name matches the pattern
 		if channel.pattern.Match(channelName) {
-			channel.Publish(message)
+			f(channel)
 		}
 	}
 }
@@ -274,3 +308,14 @@ func (ps *PubSub) GetAllChannels() []*Channel {
 
 	return channels
 }
+
+// encodeSubscription encodes the confirmation of a subscription change, e.g. "subscribe" or "unsubscribe".
+// RESP3 subscribers receive push messages, RESP2 subscribers receive arrays.
+func encodeSubscription(protocol int, action string, channel string, count int) []byte {
+	prefix := "*"
+	if protocol == constants.RESP3Protocol {
+		prefix = ">"
+	}
+	return []byte(fmt.Sprintf("%s3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n:%d\r\n",
+		prefix, len(action), action, len(channel), channel, count))
+}
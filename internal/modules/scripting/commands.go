@@ -0,0 +1,170 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"strings"
+)
+
+func handleEval(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, args, err := evalArgs(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	return params.Eval(params.Context, params.Connection, params.Command[1], keys, args)
+}
+
+func handleEvalSHA(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, args, err := evalArgs(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	return params.EvalSHA(params.Context, params.Connection, strings.ToLower(params.Command[1]), keys, args)
+}
+
+func handleScriptLoad(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := scriptLoadKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+	sha, err := params.ScriptLoad(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(sha), sha)), nil
+}
+
+func handleScriptExists(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := scriptExistsKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+	shas := make([]string, len(params.Command[2:]))
+	for i, sha := range params.Command[2:] {
+		shas[i] = strings.ToLower(sha)
+	}
+	res := fmt.Sprintf("*%d\r\n", len(shas))
+	for _, exists := range params.ScriptExists(shas) {
+		if exists {
+			res += ":1\r\n"
+			continue
+		}
+		res += ":0\r\n"
+	}
+	return []byte(res), nil
+}
+
+func handleScriptFlush(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := scriptFlushKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+	// The cache is always flushed synchronously. ASYNC and SYNC are accepted for compatibility.
+	if len(params.Command) == 3 && !strings.EqualFold(params.Command[2], "async") &&
+		!strings.EqualFold(params.Command[2], "sync") {
+		return nil, fmt.Errorf("unknown SCRIPT FLUSH option %s", params.Command[2])
+	}
+	params.ScriptFlush()
+	return []byte(constants.OkResponse), nil
+}
+
+func handleScriptKill(params internal.HandlerFuncParams) ([]byte, error) {
+	if _, err := scriptKillKeyFunc(params.Command); err != nil {
+		return nil, err
+	}
+	if err := params.ScriptKill(); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func Commands() []internal.Command {
+	return []internal.Command{
+		{
+			Command:    "eval",
+			Module:     constants.ScriptingModule,
+			Categories: []string{constants.ScriptingCategory, constants.SlowCategory},
+			Description: `(EVAL script numkeys [key [key ...]] [arg [arg ...]])
+Runs the Lua script atomically. The keys are available to the script in the KEYS table and the arguments in the ARGV table.
+The script can call commands with redis.call and redis.pcall.`,
+			Sync:              false,
+			KeyExtractionFunc: evalKeyFunc,
+			HandlerFunc:       handleEval,
+		},
+		{
+			Command:    "evalsha",
+			Module:     constants.ScriptingModule,
+			Categories: []string{constants.ScriptingCategory, constants.SlowCategory},
+			Description: `(EVALSHA sha1 numkeys [key [key ...]] [arg [arg ...]])
+Runs the script with the given SHA1 digest from the script cache. Works the same as EVAL otherwise.`,
+			Sync:              false,
+			KeyExtractionFunc: evalKeyFunc,
+			HandlerFunc:       handleEvalSHA,
+		},
+		{
+			Command:           "script",
+			Module:            constants.ScriptingModule,
+			Categories:        []string{},
+			Description:       "",
+			Sync:              false,
+			KeyExtractionFunc: scriptKeyFunc,
+			HandlerFunc: func(_ internal.HandlerFuncParams) ([]byte, error) {
+				return nil, errors.New("provide LOAD, EXISTS, FLUSH or KILL subcommand")
+			},
+			SubCommands: []internal.SubCommand{
+				{
+					Command:    "load",
+					Module:     constants.ScriptingModule,
+					Categories: []string{constants.ScriptingCategory, constants.SlowCategory},
+					Description: `(SCRIPT LOAD script) Compiles the script and adds it to the script cache without running it.
+Returns the SHA1 digest of the script.`,
+					Sync:              true,
+					KeyExtractionFunc: scriptLoadKeyFunc,
+					HandlerFunc:       handleScriptLoad,
+				},
+				{
+					Command:    "exists",
+					Module:     constants.ScriptingModule,
+					Categories: []string{constants.ScriptingCategory, constants.SlowCategory},
+					Description: `(SCRIPT EXISTS sha1 [sha1 ...])
+Returns an array with 1 for each SHA1 digest that is in the script cache and 0 for each one that is not.`,
+					Sync:              false,
+					KeyExtractionFunc: scriptExistsKeyFunc,
+					HandlerFunc:       handleScriptExists,
+				},
+				{
+					Command:           "flush",
+					Module:            constants.ScriptingModule,
+					Categories:        []string{constants.ScriptingCategory, constants.SlowCategory},
+					Description:       "(SCRIPT FLUSH [ASYNC | SYNC]) Removes all the scripts from the script cache.",
+					Sync:              true,
+					KeyExtractionFunc: scriptFlushKeyFunc,
+					HandlerFunc:       handleScriptFlush,
+				},
+				{
+					Command:    "kill",
+					Module:     constants.ScriptingModule,
+					Categories: []string{constants.ScriptingCategory, constants.SlowCategory},
+					Description: `(SCRIPT KILL) Stops the script that's currently running. Scripts that have already written
+to the store can't be stopped, and neither can scripts in cluster mode, as every node runs them.`,
+					Sync:              false,
+					KeyExtractionFunc: scriptKillKeyFunc,
+					HandlerFunc:       handleScriptKill,
+				},
+			},
+		},
+	}
+}
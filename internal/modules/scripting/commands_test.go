@@ -0,0 +1,414 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting_test
+
+import (
+	"errors"
+	"github.com/echovault/echovault/echovault"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/tidwall/resp"
+	"strings"
+	"testing"
+)
+
+func Test_Scripting(t *testing.T) {
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mockServer, err := echovault.NewEchoVault(
+		echovault.WithConfig(config.Config{
+			BindAddr:       "localhost",
+			Port:           uint16(port),
+			DataDir:        "",
+			EvictionPolicy: constants.NoEviction,
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		mockServer.Start()
+	}()
+
+	t.Cleanup(func() {
+		mockServer.ShutDown()
+	})
+
+	t.Run("Test_HandleEVAL", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		if res := writeCommand(t, client, []string{"SET", "EvalKey1", "value1"}); res.Error() != nil {
+			t.Error(res.Error())
+			return
+		}
+
+		tests := []struct {
+			name             string
+			command          []string
+			expectedResponse string // The expected raw RESP response.
+			expectedError    error
+		}{
+			{
+				name:             "1. Return a string",
+				command:          []string{"EVAL", "return 'hello'", "0"},
+				expectedResponse: "$5\r\nhello\r\n",
+			},
+			{
+				name:             "2. Truncate numbers to integers",
+				command:          []string{"EVAL", "return 3.99", "0"},
+				expectedResponse: ":3\r\n",
+			},
+			{
+				name:             "3. Return true as 1 and false as null",
+				command:          []string{"EVAL", "return {true, false}", "0"},
+				expectedResponse: "*2\r\n:1\r\n$-1\r\n",
+			},
+			{
+				name:             "4. Return the KEYS and ARGV tables",
+				command:          []string{"EVAL", "return {KEYS[1], KEYS[2], ARGV[1]}", "2", "key1", "key2", "arg1"},
+				expectedResponse: "*3\r\n$4\r\nkey1\r\n$4\r\nkey2\r\n$4\r\narg1\r\n",
+			},
+			{
+				name:             "5. Stop converting a table to an array at the first nil",
+				command:          []string{"EVAL", "return {1, 2, nil, 4}", "0"},
+				expectedResponse: "*2\r\n:1\r\n:2\r\n",
+			},
+			{
+				name:             "6. Return a status reply",
+				command:          []string{"EVAL", "return redis.status_reply('DONE')", "0"},
+				expectedResponse: "+DONE\r\n",
+			},
+			{
+				name:             "7. Read a key with redis.call",
+				command:          []string{"EVAL", "return redis.call('GET', KEYS[1])", "1", "EvalKey1"},
+				expectedResponse: "$6\r\nvalue1\r\n",
+			},
+			{
+				name:             "8. Convert a null reply to false",
+				command:          []string{"EVAL", "return redis.call('GET', KEYS[1]) == false", "1", "EvalNonExistent"},
+				expectedResponse: ":1\r\n",
+			},
+			{
+				name: "9. Write and read keys with redis.call",
+				command: []string{"EVAL", `redis.call('SET', KEYS[1], ARGV[1])
+return {redis.call('INCR', KEYS[1]), redis.call('SET', KEYS[2], 'x')}`, "2", "EvalKey2", "EvalKey3", "10"},
+				expectedResponse: "*2\r\n:11\r\n$2\r\nOK\r\n",
+			},
+			{
+				name:             "10. Return the error of redis.pcall as a value",
+				command:          []string{"EVAL", "local r = redis.pcall('INCR', KEYS[1]) return type(r.err)", "1", "EvalKey1"},
+				expectedResponse: "$6\r\nstring\r\n",
+			},
+			{
+				name:             "11. Produce the same random numbers on every run",
+				command:          []string{"EVAL", "return {math.random(100), math.random(100)}", "0"},
+				expectedResponse: "*2\r\n:75\r\n:15\r\n",
+			},
+			{
+				name:             "12. Return the SHA1 digest of a string",
+				command:          []string{"EVAL", "return redis.sha1hex('')", "0"},
+				expectedResponse: "$40\r\nda39a3ee5e6b4b0d3255bfef95601890afd80709\r\n",
+			},
+			{
+				name:          "13. Return an error reply as an error",
+				command:       []string{"EVAL", "return redis.error_reply('my error')", "0"},
+				expectedError: errors.New("my error"),
+			},
+			{
+				name:          "14. Raise the error of redis.call",
+				command:       []string{"EVAL", "redis.call('INCR', KEYS[1]) return 1", "1", "EvalKey1"},
+				expectedError: errors.New("value is not an integer or out of range"),
+			},
+			{
+				name:          "15. Return error when calling a command that is not allowed from scripts",
+				command:       []string{"EVAL", "return redis.call('MULTI')", "0"},
+				expectedError: errors.New("command MULTI is not allowed from scripts"),
+			},
+			{
+				name:          "16. Return error when calling an unknown command",
+				command:       []string{"EVAL", "return redis.call('NOTACOMMAND')", "0"},
+				expectedError: errors.New("command NOTACOMMAND not supported"),
+			},
+			{
+				name:          "17. Return error when the script does not compile",
+				command:       []string{"EVAL", "return (", "0"},
+				expectedError: errors.New("error compiling script"),
+			},
+			{
+				name:          "18. Return error when the script raises a runtime error",
+				command:       []string{"EVAL", "return nil + 1", "0"},
+				expectedError: errors.New("error running script"),
+			},
+			{
+				name:          "19. Return error when the number of keys is greater than the number of args",
+				command:       []string{"EVAL", "return 1", "2", "key1"},
+				expectedError: errors.New("number of keys can't be greater than number of args"),
+			},
+			{
+				name:          "20. Return error when the number of keys is negative",
+				command:       []string{"EVAL", "return 1", "-1"},
+				expectedError: errors.New("number of keys can't be negative"),
+			},
+			{
+				name:          "21. Command too short",
+				command:       []string{"EVAL", "return 1"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+			{
+				name:          "22. Return error when the script accesses a key that's not declared",
+				command:       []string{"EVAL", "return redis.call('MGET', KEYS[1], 'EvalKey1')", "1", "EvalKey2"},
+				expectedError: errors.New("script attempted to access key EvalKey1 that was not declared in KEYS"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%v\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if res.Error() != nil {
+					t.Error(res.Error())
+					return
+				}
+				b, _ := res.MarshalRESP()
+				if string(b) != test.expectedResponse {
+					t.Errorf("expected response %q, got %q", test.expectedResponse, string(b))
+				}
+			})
+		}
+
+		// The writes of the script are visible to the commands that follow it.
+		if res := writeCommand(t, client, []string{"GET", "EvalKey2"}); res.String() != "11" {
+			t.Errorf("expected value \"11\", got \"%s\"", res.String())
+		}
+	})
+
+	t.Run("Test_HandleEVALSHA", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		script := "return redis.call('INCR', KEYS[1])"
+		res := writeCommand(t, client, []string{"SCRIPT", "LOAD", script})
+		if res.Error() != nil {
+			t.Error(res.Error())
+			return
+		}
+		sha := res.String()
+
+		tests := []struct {
+			name             string
+			command          []string
+			expectedResponse int
+			expectedError    error
+		}{
+			{
+				name:             "1. Run a loaded script",
+				command:          []string{"EVALSHA", sha, "1", "EvalshaKey1"},
+				expectedResponse: 1,
+			},
+			{
+				name:             "2. The SHA1 digest is case insensitive",
+				command:          []string{"EVALSHA", strings.ToUpper(sha), "1", "EvalshaKey1"},
+				expectedResponse: 2,
+			},
+			{
+				name:          "3. Return error when the script is not in the cache",
+				command:       []string{"EVALSHA", "ffffffffffffffffffffffffffffffffffffffff", "0"},
+				expectedError: errors.New("NOSCRIPT No matching script. Please use EVAL."),
+			},
+			{
+				name:          "4. Command too short",
+				command:       []string{"EVALSHA", sha},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, test.command)
+				if test.expectedError != nil {
+					if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+						t.Errorf("expected error \"%s\", got \"%v\"", test.expectedError.Error(), res.Error())
+					}
+					return
+				}
+				if res.Integer() != test.expectedResponse {
+					t.Errorf("expected response %d, got %d", test.expectedResponse, res.Integer())
+				}
+			})
+		}
+	})
+
+	t.Run("Test_HandleSCRIPT", func(t *testing.T) {
+		// Not parallel, as SCRIPT FLUSH clears the scripts loaded by the other tests.
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		res := writeCommand(t, client, []string{"SCRIPT", "LOAD", "return 1"})
+		if res.String() != "e0e1f9fabfc9d4800c877a703b823ac0578ff8db" {
+			t.Errorf("expected SHA1 digest \"e0e1f9fabfc9d4800c877a703b823ac0578ff8db\", got \"%s\"", res.String())
+		}
+
+		// A script run with EVAL is added to the cache too.
+		if res = writeCommand(t, client, []string{"EVAL", "return 2", "0"}); res.Integer() != 2 {
+			t.Errorf("expected response 2, got %d", res.Integer())
+		}
+
+		res = writeCommand(t, client, []string{
+			"SCRIPT", "EXISTS",
+			"e0e1f9fabfc9d4800c877a703b823ac0578ff8db",
+			"7f923f79fe76194c868d7e1d0820de36700eb649",
+			"ffffffffffffffffffffffffffffffffffffffff",
+		})
+		expected := []int{1, 1, 0}
+		if len(res.Array()) != len(expected) {
+			t.Errorf("expected array of length %d, got %d", len(expected), len(res.Array()))
+			return
+		}
+		for i, v := range res.Array() {
+			if v.Integer() != expected[i] {
+				t.Errorf("expected %d at index %d, got %d", expected[i], i, v.Integer())
+			}
+		}
+
+		if res = writeCommand(t, client, []string{"SCRIPT", "FLUSH", "ASYNC"}); res.String() != "OK" {
+			t.Errorf("expected response OK, got \"%s\"", res.String())
+		}
+		res = writeCommand(t, client, []string{"SCRIPT", "EXISTS", "e0e1f9fabfc9d4800c877a703b823ac0578ff8db"})
+		if len(res.Array()) != 1 || res.Array()[0].Integer() != 0 {
+			t.Errorf("expected script to be flushed, got %v", res.Array())
+		}
+
+		errorTests := []struct {
+			name          string
+			command       []string
+			expectedError error
+		}{
+			{
+				name:          "1. Return error when the script does not compile",
+				command:       []string{"SCRIPT", "LOAD", "return ("},
+				expectedError: errors.New("error compiling script"),
+			},
+			{
+				name:          "2. Return error for an unknown FLUSH option",
+				command:       []string{"SCRIPT", "FLUSH", "NOW"},
+				expectedError: errors.New("unknown SCRIPT FLUSH option NOW"),
+			},
+			{
+				name:          "3. SCRIPT LOAD command too short",
+				command:       []string{"SCRIPT", "LOAD"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+			{
+				name:          "4. SCRIPT EXISTS command too short",
+				command:       []string{"SCRIPT", "EXISTS"},
+				expectedError: errors.New(constants.WrongArgsResponse),
+			},
+		}
+
+		for _, test := range errorTests {
+			t.Run(test.name, func(t *testing.T) {
+				res := writeCommand(t, client, test.command)
+				if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+					t.Errorf("expected error \"%s\", got \"%v\"", test.expectedError.Error(), res.Error())
+				}
+			})
+		}
+	})
+
+	t.Run("Test_EVALInTransaction", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		commands := [][]string{
+			{"MULTI"},
+			{"SET", "EvalTxKey1", "1"},
+			{"EVAL", "return redis.call('INCR', KEYS[1])", "1", "EvalTxKey1"},
+		}
+		for _, command := range commands {
+			if res := writeCommand(t, client, command); res.Error() != nil {
+				t.Error(res.Error())
+				return
+			}
+		}
+
+		res := writeCommand(t, client, []string{"EXEC"})
+		if len(res.Array()) != 2 {
+			t.Errorf("expected 2 responses, got %d", len(res.Array()))
+			return
+		}
+		if res.Array()[1].Integer() != 2 {
+			t.Errorf("expected EVAL response 2, got %v", res.Array()[1])
+		}
+	})
+}
+
+func writeCommand(t *testing.T, client *resp.Conn, command []string) resp.Value {
+	values := make([]resp.Value, len(command))
+	for i, c := range command {
+		values[i] = resp.StringValue(c)
+	}
+	if err := client.WriteArray(values); err != nil {
+		t.Error(err)
+	}
+	res, _, err := client.ReadValue()
+	if err != nil {
+		t.Error(err)
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"errors"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"strconv"
+)
+
+// evalArgs returns the keys and arguments that follow the script or SHA1 digest of EVAL and EVALSHA.
+func evalArgs(cmd []string) ([]string, []string, error) {
+	if len(cmd) < 3 {
+		return nil, nil, errors.New(constants.WrongArgsResponse)
+	}
+	numKeys, err := strconv.Atoi(cmd[2])
+	if err != nil {
+		return nil, nil, errors.New("numkeys must be an integer")
+	}
+	if numKeys < 0 {
+		return nil, nil, errors.New("number of keys can't be negative")
+	}
+	if numKeys > len(cmd)-3 {
+		return nil, nil, errors.New("number of keys can't be greater than number of args")
+	}
+	return cmd[3 : 3+numKeys], cmd[3+numKeys:], nil
+}
+
+func evalKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	keys, _, err := evalArgs(cmd)
+	if err != nil {
+		return internal.KeyExtractionFuncResult{}, err
+	}
+	// A script may read and write any of its declared keys, so the connection needs both permissions.
+	return internal.KeyExtractionFuncResult{
+		Channels:  make([]string, 0),
+		ReadKeys:  keys,
+		WriteKeys: keys,
+	}, nil
+}
+
+func scriptKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	return internal.KeyExtractionFuncResult{
+		Channels:  make([]string, 0),
+		ReadKeys:  make([]string, 0),
+		WriteKeys: make([]string, 0),
+	}, nil
+}
+
+func scriptLoadKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) != 3 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return scriptKeyFunc(cmd)
+}
+
+func scriptExistsKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) < 3 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return scriptKeyFunc(cmd)
+}
+
+func scriptFlushKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) > 3 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return scriptKeyFunc(cmd)
+}
+
+func scriptKillKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) != 2 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return scriptKeyFunc(cmd)
+}
@@ -105,15 +105,7 @@ func handleSDIFF(params internal.HandlerFuncParams) ([]byte, error) {
 	diff := baseSet.Subtract(sets)
 	elems := diff.GetAll()
 
-	res := fmt.Sprintf("*%d", len(elems))
-	for i, e := range elems {
-		res = fmt.Sprintf("%s\r\n$%d\r\n%s", res, len(e), e)
-		if i == len(elems)-1 {
-			res += "\r\n"
-		}
-	}
-
-	return []byte(res), nil
+	return encodeMembers(params.Protocol, elems), nil
 }
 
 func handleSDIFFSTORE(params internal.HandlerFuncParams) ([]byte, error) {
@@ -168,7 +160,7 @@ func handleSINTER(params internal.HandlerFuncParams) ([]byte, error) {
 
 	for key, exists := range keyExists {
 		if !exists {
-			return []byte("*0\r\n"), nil
+			return encodeMembers(params.Protocol, nil), nil
 		}
 		set, ok := params.GetValues(params.Context, []string{key})[key].(*Set)
 		if !ok {
@@ -185,15 +177,7 @@ func handleSINTER(params internal.HandlerFuncParams) ([]byte, error) {
 	intersect, _ := Intersection(0, sets...)
 	elems := intersect.GetAll()
 
-	res := fmt.Sprintf("*%d", len(elems))
-	for i, e := range elems {
-		res = fmt.Sprintf("%s\r\n$%d\r\n%s", res, len(e), e)
-		if i == len(elems)-1 {
-			res += "\r\n"
-		}
-	}
-
-	return []byte(res), nil
+	return encodeMembers(params.Protocol, elems), nil
 }
 
 func handleSINTERCARD(params internal.HandlerFuncParams) ([]byte, error) {
@@ -315,7 +299,7 @@ func handleSMEMBERS(params internal.HandlerFuncParams) ([]byte, error) {
 	keyExists := params.KeysExist(keys.ReadKeys)[key]
 
 	if !keyExists {
-		return []byte("*0\r\n"), nil
+		return encodeMembers(params.Protocol, nil), nil
 	}
 
 	set, ok := params.GetValues(params.Context, []string{key})[key].(*Set)
@@ -325,15 +309,7 @@ func handleSMEMBERS(params internal.HandlerFuncParams) ([]byte, error) {
 
 	elems := set.GetAll()
 
-	res := fmt.Sprintf("*%d", len(elems))
-	for i, e := range elems {
-		res = fmt.Sprintf("%s\r\n$%d\r\n%s", res, len(e), e)
-		if i == len(elems)-1 {
-			res += "\r\n"
-		}
-	}
-
-	return []byte(res), nil
+	return encodeMembers(params.Protocol, elems), nil
 }
 
 func handleSMISMEMBER(params internal.HandlerFuncParams) ([]byte, error) {
@@ -529,15 +505,7 @@ func handleSUNION(params internal.HandlerFuncParams) ([]byte, error) {
 
 	union := Union(sets...)
 
-	res := fmt.Sprintf("*%d", union.Cardinality())
-	for i, e := range union.GetAll() {
-		res = fmt.Sprintf("%s\r\n$%d\r\n%s", res, len(e), e)
-		if i == len(union.GetAll())-1 {
-			res += "\r\n"
-		}
-	}
-
-	return []byte(res), nil
+	return encodeMembers(params.Protocol, union.GetAll()), nil
 }
 
 func handleSUNIONSTORE(params internal.HandlerFuncParams) ([]byte, error) {
@@ -567,6 +535,39 @@ func handleSUNIONSTORE(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(fmt.Sprintf(":%d\r\n", union.Cardinality())), nil
 }
 
+func handleSSCAN(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := sscanKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := internal.ParseScanArgs(params.Command[2:], false)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.ReadKeys[0]
+	if !params.KeysExist(keys.ReadKeys)[key] {
+		return internal.EncodeScanResponse(0, []string{}), nil
+	}
+
+	set, ok := params.GetValues(params.Context, []string{key})[key].(*Set)
+	if !ok {
+		return nil, fmt.Errorf("value at key %s is not a set", key)
+	}
+
+	elems, cursor := set.Scan(options.Cursor, options.Count)
+
+	res := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		if options.Matches(elem) {
+			res = append(res, elem)
+		}
+	}
+
+	return internal.EncodeScanResponse(cursor, res), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -719,5 +720,29 @@ Returns the cardinality of the intersection between multiple sets.`,
 			KeyExtractionFunc: sunionstoreKeyFunc,
 			HandlerFunc:       handleSUNIONSTORE,
 		},
+		{
+			Command:    "sscan",
+			Module:     constants.SetModule,
+			Categories: []string{constants.SetCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(SSCAN key cursor [MATCH pattern] [COUNT count])
+Incrementally iterates over the members of the set.
+Start the iteration with a cursor of 0, and call SSCAN again with the returned cursor until it returns a cursor of 0.`,
+			Sync:              false,
+			KeyExtractionFunc: sscanKeyFunc,
+			HandlerFunc:       handleSSCAN,
+		},
+	}
+}
+
+// encodeMembers encodes the members of a set.
+// RESP3 connections receive a native set, RESP2 connections receive an array.
+func encodeMembers(protocol int, elems []string) []byte {
+	res := fmt.Sprintf("*%d\r\n", len(elems))
+	if protocol == constants.RESP3Protocol {
+		res = fmt.Sprintf("~%d\r\n", len(elems))
+	}
+	for _, e := range elems {
+		res += fmt.Sprintf("$%d\r\n%s\r\n", len(e), e)
 	}
+	return []byte(res)
 }
@@ -2472,4 +2472,139 @@ func Test_Set(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandleRESP3", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			command  []string
+			expected string
+		}{
+			{command: []string{"HELLO", "3"}, expected: ""},
+			{command: []string{"SADD", "Resp3SetKey1", "one"}, expected: ":1\r\n"},
+			{command: []string{"SMEMBERS", "Resp3SetKey1"}, expected: "~1\r\n$3\r\none\r\n"},
+			{command: []string{"SUNION", "Resp3SetKey1", "Resp3SetKey1"}, expected: "~1\r\n$3\r\none\r\n"},
+			{command: []string{"SMEMBERS", "Resp3SetKey2"}, expected: "~0\r\n"},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if test.expected == "" {
+				continue
+			}
+			if string(buf[:n]) != test.expected {
+				t.Errorf("%v: expected response %q, got %q", test.command, test.expected, string(buf[:n]))
+			}
+		}
+	})
+
+	t.Run("Test_HandleSSCAN", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		write := func(command ...string) resp.Value {
+			values := make([]resp.Value, len(command))
+			for i, c := range command {
+				values[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(values); err != nil {
+				t.Error(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+			}
+			return res
+		}
+
+		// scanAll runs SSCAN until the cursor returned is 0, and returns all the elements returned.
+		scanAll := func(key string, args ...string) []string {
+			var elements []string
+			cursor := "0"
+			for calls := 0; calls < 100; calls++ {
+				res := write(append([]string{"SSCAN", key, cursor}, args...)...)
+				if res.Error() != nil || len(res.Array()) != 2 {
+					t.Errorf("unexpected SSCAN response %v", res)
+					return elements
+				}
+				for _, element := range res.Array()[1].Array() {
+					elements = append(elements, element.String())
+				}
+				if cursor = res.Array()[0].String(); cursor == "0" {
+					return elements
+				}
+			}
+			t.Errorf("SSCAN did not complete")
+			return elements
+		}
+
+		var expected []string
+		preset := []string{"SADD", "SscanKey1"}
+		for i := 0; i < 20; i++ {
+			member := "member" + strconv.Itoa(i)
+			preset = append(preset, member)
+			expected = append(expected, member)
+		}
+		write(preset...)
+
+		elements := scanAll("SscanKey1", "COUNT", "3")
+		slices.Sort(elements)
+		slices.Sort(expected)
+		if !slices.Equal(elements, expected) {
+			t.Errorf("expected elements %v, got %v", expected, elements)
+		}
+
+		// Only return the elements matching the pattern.
+		if elements = scanAll("SscanKey1", "MATCH", "member1?"); len(elements) != 10 {
+			t.Errorf("expected 10 elements matching the pattern, got %v", elements)
+		}
+
+		// Return an empty list if the key does not exist.
+		if elements = scanAll("SscanKey2"); len(elements) != 0 {
+			t.Errorf("expected no elements, got %v", elements)
+		}
+
+		write("SET", "SscanKey3", "value")
+		errorTests := []struct {
+			command       []string
+			expectedError error
+		}{
+			{command: []string{"SSCAN", "SscanKey1"}, expectedError: errors.New(constants.WrongArgsResponse)},
+			{command: []string{"SSCAN", "SscanKey1", "cursor"}, expectedError: errors.New("invalid cursor")},
+			{command: []string{"SSCAN", "SscanKey1", "0", "COUNT", "0"}, expectedError: errors.New("syntax error")},
+			{command: []string{"SSCAN", "SscanKey1", "0", "TYPE", "string"}, expectedError: errors.New("syntax error")},
+			{command: []string{"SSCAN", "SscanKey3", "0"}, expectedError: errors.New("value at key SscanKey3 is not a set")},
+		}
+		for _, test := range errorTests {
+			res := write(test.command...)
+			if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+				t.Errorf("expected error \"%s\" for %v, got %v", test.expectedError.Error(), test.command, res)
+			}
+		}
+	})
 }
@@ -23,12 +23,14 @@ import (
 type Set struct {
 	members map[string]interface{}
 	length  int
+	index   *internal.ScanIndex // The members in their scan order, used by SSCAN.
 }
 
 func NewSet(elems []string) *Set {
 	set := &Set{
 		members: make(map[string]interface{}),
 		length:  0,
+		index:   internal.NewScanIndex(),
 	}
 	set.Add(elems)
 	return set
@@ -39,6 +41,7 @@ func (set *Set) Add(elems []string) int {
 	for _, e := range elems {
 		if !set.Contains(e) {
 			set.members[e] = struct{}{}
+			set.index.Add(e)
 			count += 1
 		}
 	}
@@ -58,6 +61,20 @@ func (set *Set) GetAll() []string {
 	return res
 }
 
+// Each calls f for each member of the set until f returns false.
+func (set *Set) Each(f func(elem string) bool) {
+	for e := range set.members {
+		if !f(e) {
+			return
+		}
+	}
+}
+
+// Scan returns the next batch of at least count members from the cursor, and the cursor for the next call.
+func (set *Set) Scan(cursor uint64, count int) ([]string, uint64) {
+	return set.index.Scan(cursor, count)
+}
+
 func (set *Set) Cardinality() int {
 	return set.length
 }
@@ -105,6 +122,7 @@ func (set *Set) Remove(elems []string) int {
 	for _, e := range elems {
 		if set.Get(e) != nil {
 			delete(set.members, e)
+			set.index.Remove(e)
 			count += 1
 		}
 	}
@@ -435,6 +435,9 @@ func handleZINCRBY(params internal.HandlerFuncParams) ([]byte, error) {
 		); err != nil {
 			return nil, err
 		}
+		if params.Protocol == constants.RESP3Protocol {
+			return []byte(internal.EncodeDouble(float64(increment))), nil
+		}
 		return []byte(fmt.Sprintf("+%s\r\n", strconv.FormatFloat(float64(increment), 'f', -1, 64))), nil
 	}
 
@@ -451,6 +454,9 @@ func handleZINCRBY(params internal.HandlerFuncParams) ([]byte, error) {
 		"incr"); err != nil {
 		return nil, err
 	}
+	if params.Protocol == constants.RESP3Protocol {
+		return []byte(internal.EncodeDouble(float64(set.Get(member).Score))), nil
+	}
 	return []byte(fmt.Sprintf("+%s\r\n",
 		strconv.FormatFloat(float64(set.Get(member).Score), 'f', -1, 64))), nil
 }
@@ -700,9 +706,14 @@ func handleZMSCORE(params internal.HandlerFuncParams) ([]byte, error) {
 
 	for i := 0; i < len(members); i++ {
 		member = set.Get(Value(members[i]))
-		if !member.Exists {
+		switch {
+		case !member.Exists && params.Protocol == constants.RESP3Protocol:
+			res = fmt.Sprintf("%s\r\n_", res)
+		case !member.Exists:
 			res = fmt.Sprintf("%s\r\n$-1", res)
-		} else {
+		case params.Protocol == constants.RESP3Protocol:
+			res = fmt.Sprintf("%s\r\n%s", res, strings.TrimSuffix(internal.EncodeDouble(float64(member.Score)), "\r\n"))
+		default:
 			res = fmt.Sprintf("%s\r\n+%s", res, strconv.FormatFloat(float64(member.Score), 'f', -1, 64))
 		}
 	}
@@ -862,6 +873,10 @@ func handleZSCORE(params internal.HandlerFuncParams) ([]byte, error) {
 		return []byte("$-1\r\n"), nil
 	}
 
+	if params.Protocol == constants.RESP3Protocol {
+		return []byte(internal.EncodeDouble(float64(member.Score))), nil
+	}
+
 	score := strconv.FormatFloat(float64(member.Score), 'f', -1, 64)
 
 	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(score), score)), nil
@@ -1366,6 +1381,342 @@ func handleZUNIONSTORE(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(fmt.Sprintf(":%d\r\n", union.Cardinality())), nil
 }
 
+// getGeoSet returns the sorted set at the key. Returns nil if the key does not exist.
+func getGeoSet(params internal.HandlerFuncParams, key string) (*SortedSet, error) {
+	if !params.KeysExist([]string{key})[key] {
+		return nil, nil
+	}
+	set, ok := params.GetValues(params.Context, []string{key})[key].(*SortedSet)
+	if !ok {
+		return nil, fmt.Errorf("value at %s is not a sorted set", key)
+	}
+	return set, nil
+}
+
+func encodeBulkString(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// encodeGeoCoordinate returns a coordinate as a bulk string, or as a double for RESP3 connections.
+func encodeGeoCoordinate(value float64, protocol int) string {
+	if protocol == constants.RESP3Protocol {
+		return internal.EncodeDouble(value)
+	}
+	return encodeBulkString(strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func handleZSCAN(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := zscanKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := internal.ParseScanArgs(params.Command[2:], false)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.ReadKeys[0]
+	if !params.KeysExist(keys.ReadKeys)[key] {
+		return internal.EncodeScanResponse(0, []string{}), nil
+	}
+
+	set, ok := params.GetValues(params.Context, []string{key})[key].(*SortedSet)
+	if !ok {
+		return nil, fmt.Errorf("value at %s is not a sorted set", key)
+	}
+
+	members, cursor := set.Scan(options.Cursor, options.Count)
+
+	res := make([]string, 0, len(members)*2)
+	for _, member := range members {
+		if options.Matches(string(member)) {
+			score := set.Get(member).Score
+			res = append(res, string(member), strconv.FormatFloat(float64(score), 'f', -1, 64))
+		}
+	}
+
+	return internal.EncodeScanResponse(cursor, res), nil
+}
+
+func handleGEOADD(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := geoaddKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+
+	var updatePolicy interface{} = nil
+	changed := false
+
+	i := 2
+	for ; i < len(params.Command); i++ {
+		option := strings.ToLower(params.Command[i])
+		if option == "nx" || option == "xx" {
+			if updatePolicy != nil && !strings.EqualFold(updatePolicy.(string), option) {
+				return nil, errors.New("XX and NX options at the same time are not compatible")
+			}
+			updatePolicy = option
+			continue
+		}
+		if option == "ch" {
+			changed = true
+			continue
+		}
+		break
+	}
+
+	args := params.Command[i:]
+	if len(args) == 0 || len(args)%3 != 0 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+
+	members := make([]MemberParam, 0, len(args)/3)
+	for j := 0; j < len(args); j += 3 {
+		lon, lat, err := parseGeoCoordinates(args[j], args[j+1])
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, MemberParam{
+			Value: Value(args[j+2]),
+			Score: Score(geohashEncode(lon, lat, geoLatMin, geoLatMax)),
+		})
+	}
+
+	set, err := getGeoSet(params, key)
+	if err != nil {
+		return nil, err
+	}
+	exists := set != nil
+	if !exists {
+		set = NewSortedSet([]MemberParam{})
+	}
+
+	// Count the members that will be added, and the ones that will be updated when CH is provided.
+	count := 0
+	for _, m := range members {
+		current := set.Get(m.Value)
+		switch {
+		case !current.Exists && updatePolicy != "xx":
+			count += 1
+		case current.Exists && updatePolicy != "nx" && changed && current.Score != m.Score:
+			count += 1
+		}
+	}
+
+	if _, err = set.AddOrUpdate(members, updatePolicy, nil, nil, nil); err != nil {
+		return nil, err
+	}
+
+	if !exists && set.Cardinality() > 0 {
+		if err = params.SetValues(params.Context, map[string]interface{}{key: set}); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", count)), nil
+}
+
+func handleGEOPOS(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := geoposKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := getGeoSet(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	null := "*-1\r\n"
+	if params.Protocol == constants.RESP3Protocol {
+		null = constants.NullResponse
+	}
+
+	members := params.Command[2:]
+	res := fmt.Sprintf("*%d\r\n", len(members))
+	for _, member := range members {
+		if set == nil || !set.Contains(Value(member)) {
+			res += null
+			continue
+		}
+		lon, lat := geohashDecode(uint64(set.Get(Value(member)).Score))
+		res += "*2\r\n" + encodeGeoCoordinate(lon, params.Protocol) + encodeGeoCoordinate(lat, params.Protocol)
+	}
+
+	return []byte(res), nil
+}
+
+func handleGEODIST(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := geodistKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := 1.0
+	if len(params.Command) == 5 {
+		if unit, err = parseGeoUnit(params.Command[4]); err != nil {
+			return nil, err
+		}
+	}
+
+	set, err := getGeoSet(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	null := "$-1\r\n"
+	if params.Protocol == constants.RESP3Protocol {
+		null = constants.NullResponse
+	}
+
+	if set == nil {
+		return []byte(null), nil
+	}
+	m1, m2 := set.Get(Value(params.Command[2])), set.Get(Value(params.Command[3]))
+	if !m1.Exists || !m2.Exists {
+		return []byte(null), nil
+	}
+
+	lon1, lat1 := geohashDecode(uint64(m1.Score))
+	lon2, lat2 := geohashDecode(uint64(m2.Score))
+	distance := geoDistance(lon1, lat1, lon2, lat2) / unit
+
+	return []byte(encodeBulkString(strconv.FormatFloat(distance, 'f', 4, 64))), nil
+}
+
+func handleGEOHASH(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := geohashKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := getGeoSet(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	null := "$-1\r\n"
+	if params.Protocol == constants.RESP3Protocol {
+		null = constants.NullResponse
+	}
+
+	members := params.Command[2:]
+	res := fmt.Sprintf("*%d\r\n", len(members))
+	for _, member := range members {
+		if set == nil || !set.Contains(Value(member)) {
+			res += null
+			continue
+		}
+		lon, lat := geohashDecode(uint64(set.Get(Value(member)).Score))
+		res += encodeBulkString(geohashString(lon, lat))
+	}
+
+	return []byte(res), nil
+}
+
+func handleGEOSEARCH(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := geosearchKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := parseGeoSearchOptions(params.Command[2:], false)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := getGeoSet(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return []byte("*0\r\n"), nil
+	}
+
+	results, err := geoSearch(set, options)
+	if err != nil {
+		return nil, err
+	}
+
+	res := fmt.Sprintf("*%d\r\n", len(results))
+	for _, result := range results {
+		if !options.withDist && !options.withHash && !options.withCoord {
+			res += encodeBulkString(string(result.member))
+			continue
+		}
+		fields := 1
+		entry := encodeBulkString(string(result.member))
+		if options.withDist {
+			fields += 1
+			entry += encodeBulkString(strconv.FormatFloat(result.distance/options.unit, 'f', 4, 64))
+		}
+		if options.withHash {
+			fields += 1
+			entry += fmt.Sprintf(":%d\r\n", uint64(result.score))
+		}
+		if options.withCoord {
+			fields += 1
+			entry += "*2\r\n" + encodeGeoCoordinate(result.lon, params.Protocol) + encodeGeoCoordinate(result.lat, params.Protocol)
+		}
+		res += fmt.Sprintf("*%d\r\n%s", fields, entry)
+	}
+
+	return []byte(res), nil
+}
+
+func handleGEOSEARCHSTORE(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := geosearchstoreKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	destination := keys.WriteKeys[0]
+
+	options, err := parseGeoSearchOptions(params.Command[3:], true)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := getGeoSet(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var results []geoSearchResult
+	if set != nil {
+		if results, err = geoSearch(set, options); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(results) == 0 {
+		if params.KeysExist(keys.WriteKeys)[destination] {
+			if err = params.DeleteKey(destination); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(":0\r\n"), nil
+	}
+
+	members := make([]MemberParam, len(results))
+	for i, result := range results {
+		members[i] = MemberParam{Value: result.member, Score: result.score}
+		if options.storeDist {
+			members[i].Score = Score(result.distance / options.unit)
+		}
+	}
+
+	if err = params.SetValues(params.Context, map[string]interface{}{
+		destination: NewSortedSet(members),
+	}); err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", len(members))), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -1637,5 +1988,80 @@ The resulting union is stored at the destination key.`,
 			KeyExtractionFunc: zunionstoreKeyFunc,
 			HandlerFunc:       handleZUNIONSTORE,
 		},
+		{
+			Command:    "zscan",
+			Module:     constants.SortedSetModule,
+			Categories: []string{constants.SortedSetCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(ZSCAN key cursor [MATCH pattern] [COUNT count])
+Incrementally iterates over the members of the sorted set, returning the member-score pairs.
+Start the iteration with a cursor of 0, and call ZSCAN again with the returned cursor until it returns a cursor of 0.`,
+			Sync:              false,
+			KeyExtractionFunc: zscanKeyFunc,
+			HandlerFunc:       handleZSCAN,
+		},
+		{
+			Command:    "geoadd",
+			Module:     constants.SortedSetModule,
+			Categories: []string{constants.GeoCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...])
+Adds the members at the given coordinates to the sorted set at the key. The score of each member is the geohash of its coordinates.
+"NX" only adds new members. "XX" only updates the coordinates of existing members.
+"CH" modifies the result to return the number of members changed + added, instead of only new members added.`,
+			Sync:              true,
+			KeyExtractionFunc: geoaddKeyFunc,
+			HandlerFunc:       handleGEOADD,
+		},
+		{
+			Command:           "geopos",
+			Module:            constants.SortedSetModule,
+			Categories:        []string{constants.GeoCategory, constants.ReadCategory, constants.SlowCategory},
+			Description:       "(GEOPOS key [member [member ...]]) Returns the longitude and latitude of each of the members.",
+			Sync:              false,
+			KeyExtractionFunc: geoposKeyFunc,
+			HandlerFunc:       handleGEOPOS,
+		},
+		{
+			Command:    "geodist",
+			Module:     constants.SortedSetModule,
+			Categories: []string{constants.GeoCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(GEODIST key member1 member2 [M | KM | FT | MI])
+Returns the distance between the two members in the given unit. The default unit is meters.`,
+			Sync:              false,
+			KeyExtractionFunc: geodistKeyFunc,
+			HandlerFunc:       handleGEODIST,
+		},
+		{
+			Command:           "geohash",
+			Module:            constants.SortedSetModule,
+			Categories:        []string{constants.GeoCategory, constants.ReadCategory, constants.SlowCategory},
+			Description:       "(GEOHASH key [member [member ...]]) Returns the standard 11 character geohash of each of the members.",
+			Sync:              false,
+			KeyExtractionFunc: geohashKeyFunc,
+			HandlerFunc:       handleGEOHASH,
+		},
+		{
+			Command:    "geosearch",
+			Module:     constants.SortedSetModule,
+			Categories: []string{constants.GeoCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(GEOSEARCH key <FROMMEMBER member | FROMLONLAT longitude latitude>
+<BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>> [ASC | DESC] [COUNT count [ANY]]
+[WITHCOORD] [WITHDIST] [WITHHASH]) Returns the members within the circle or box centred on the given member or coordinates.
+"ANY" returns as soon as enough matches are found, instead of returning the closest matches.`,
+			Sync:              false,
+			KeyExtractionFunc: geosearchKeyFunc,
+			HandlerFunc:       handleGEOSEARCH,
+		},
+		{
+			Command:    "geosearchstore",
+			Module:     constants.SortedSetModule,
+			Categories: []string{constants.GeoCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(GEOSEARCHSTORE destination source <FROMMEMBER member | FROMLONLAT longitude latitude>
+<BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>> [ASC | DESC] [COUNT count [ANY]]
+[STOREDIST]) Works like GEOSEARCH but stores the result as a sorted set at the destination and returns its cardinality.
+"STOREDIST" stores the distances in the given unit as the scores instead of the geohashes.`,
+			Sync:              true,
+			KeyExtractionFunc: geosearchstoreKeyFunc,
+			HandlerFunc:       handleGEOSEARCHSTORE,
+		},
 	}
 }
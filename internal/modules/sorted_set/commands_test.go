@@ -5744,4 +5744,281 @@ func Test_SortedSet(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandleRESP3", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			command  []string
+			expected string
+		}{
+			{command: []string{"HELLO", "3"}, expected: ""},
+			{command: []string{"ZADD", "Resp3ZSetKey1", "1.5", "one"}, expected: ":1\r\n"},
+			{command: []string{"ZSCORE", "Resp3ZSetKey1", "one"}, expected: ",1.5\r\n"},
+			{command: []string{"ZSCORE", "Resp3ZSetKey1", "two"}, expected: "_\r\n"},
+			{command: []string{"ZMSCORE", "Resp3ZSetKey1", "one", "two"}, expected: "*2\r\n,1.5\r\n_\r\n"},
+			{command: []string{"ZINCRBY", "Resp3ZSetKey1", "+inf", "one"}, expected: ",inf\r\n"},
+			{command: []string{"GEOADD", "Resp3ZSetKey2", "13.361389", "38.115556", "Palermo"}, expected: ":1\r\n"},
+			{
+				command:  []string{"GEOPOS", "Resp3ZSetKey2", "Palermo", "Catania"},
+				expected: "*2\r\n*2\r\n,13.361389338970184\r\n,38.1155563954963\r\n_\r\n",
+			},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if test.expected == "" {
+				continue
+			}
+			if string(buf[:n]) != test.expected {
+				t.Errorf("%v: expected response %q, got %q", test.command, test.expected, string(buf[:n]))
+			}
+		}
+	})
+
+	t.Run("Test_HandleGeo", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			command  []string
+			expected string
+		}{
+			{
+				command:  []string{"GEOADD", "GeoKey1", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"},
+				expected: ":2\r\n",
+			},
+			{
+				command:  []string{"GEOADD", "GeoKey1", "NX", "CH", "13.361389", "38.115556", "Palermo", "13.583333", "37.316667", "Agrigento"},
+				expected: ":1\r\n",
+			},
+			{
+				command:  []string{"GEOADD", "GeoKey1", "XX", "CH", "13.5834", "37.316667", "Agrigento", "12.758489", "38.788135", "edge1"},
+				expected: ":1\r\n",
+			},
+			{
+				command:  []string{"GEOADD", "GeoKey1", "200", "100", "Invalid"},
+				expected: "-Error invalid longitude,latitude pair 200.000000,100.000000\r\n",
+			},
+			{
+				command:  []string{"GEOADD", "GeoKey1", "NX", "XX", "13.361389", "38.115556", "Palermo"},
+				expected: "-Error XX and NX options at the same time are not compatible\r\n",
+			},
+			{
+				command:  []string{"GEODIST", "GeoKey1", "Palermo", "Catania"},
+				expected: "$11\r\n166274.1516\r\n",
+			},
+			{
+				command:  []string{"GEODIST", "GeoKey1", "Palermo", "Catania", "km"},
+				expected: "$8\r\n166.2742\r\n",
+			},
+			{
+				command:  []string{"GEODIST", "GeoKey1", "Palermo", "NonExistent"},
+				expected: "$-1\r\n",
+			},
+			{
+				command:  []string{"GEODIST", "GeoKey1", "Palermo", "Catania", "parsec"},
+				expected: "-Error unsupported unit provided. please use M, KM, FT, MI\r\n",
+			},
+			{
+				command:  []string{"GEOHASH", "GeoKey1", "Palermo", "Catania", "NonExistent"},
+				expected: "*3\r\n$11\r\nsqc8b49rny0\r\n$11\r\nsqdtr74hyu0\r\n$-1\r\n",
+			},
+			{
+				command:  []string{"GEOPOS", "GeoKey1", "Palermo", "NonExistent"},
+				expected: "*2\r\n*2\r\n$18\r\n13.361389338970184\r\n$16\r\n38.1155563954963\r\n*-1\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCH", "GeoKey1", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"},
+				expected: "*3\r\n$7\r\nCatania\r\n$9\r\nAgrigento\r\n$7\r\nPalermo\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCH", "GeoKey1", "FROMMEMBER", "Palermo", "BYBOX", "200", "200", "km", "DESC", "COUNT", "2", "WITHDIST"},
+				expected: "*2\r\n*2\r\n$9\r\nAgrigento\r\n$7\r\n90.9791\r\n*2\r\n$7\r\nPalermo\r\n$6\r\n0.0000\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCH", "GeoKey1", "FROMLONLAT", "15", "37", "BYRADIUS", "100", "km", "WITHHASH"},
+				expected: "*1\r\n*2\r\n$7\r\nCatania\r\n:3479447370796909\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCH", "GeoKey1", "FROMMEMBER", "NonExistent", "BYRADIUS", "100", "km"},
+				expected: "-Error could not decode requested zset member\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCH", "GeoKey1", "FROMLONLAT", "15", "37", "BYRADIUS", "100", "km", "ANY"},
+				expected: "-Error the ANY argument requires COUNT argument\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCHSTORE", "GeoKey2", "GeoKey1", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "COUNT", "1", "STOREDIST"},
+				expected: ":1\r\n",
+			},
+			{
+				command:  []string{"ZSCORE", "GeoKey2", "Catania"},
+				expected: "$16\r\n56.4412578701582\r\n",
+			},
+			{
+				command:  []string{"GEOSEARCHSTORE", "GeoKey2", "GeoKey1", "FROMLONLAT", "0", "0", "BYRADIUS", "1", "km"},
+				expected: ":0\r\n",
+			},
+			{
+				command:  []string{"ZCARD", "GeoKey2"},
+				expected: ":0\r\n",
+			},
+			{
+				command:  []string{"SET", "GeoKey3", "value"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"GEOPOS", "GeoKey3", "Palermo"},
+				expected: "-Error value at GeoKey3 is not a sorted set\r\n",
+			},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(buf[:n]) != test.expected {
+				t.Errorf("%v: expected response %q, got %q", test.command, test.expected, string(buf[:n]))
+			}
+		}
+	})
+
+	t.Run("Test_HandleZSCAN", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		write := func(command ...string) resp.Value {
+			values := make([]resp.Value, len(command))
+			for i, c := range command {
+				values[i] = resp.StringValue(c)
+			}
+			if err := client.WriteArray(values); err != nil {
+				t.Error(err)
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+			}
+			return res
+		}
+
+		// scanAll runs ZSCAN until the cursor returned is 0, and returns all the elements returned.
+		scanAll := func(key string, args ...string) []string {
+			var elements []string
+			cursor := "0"
+			for calls := 0; calls < 100; calls++ {
+				res := write(append([]string{"ZSCAN", key, cursor}, args...)...)
+				if res.Error() != nil || len(res.Array()) != 2 {
+					t.Errorf("unexpected ZSCAN response %v", res)
+					return elements
+				}
+				for _, element := range res.Array()[1].Array() {
+					elements = append(elements, element.String())
+				}
+				if cursor = res.Array()[0].String(); cursor == "0" {
+					return elements
+				}
+			}
+			t.Errorf("ZSCAN did not complete")
+			return elements
+		}
+
+		var expected []string
+		preset := []string{"ZADD", "ZscanKey1"}
+		for i := 0; i < 20; i++ {
+			member := "member" + strconv.Itoa(i)
+			preset = append(preset, strconv.Itoa(i), member)
+			expected = append(expected, member, strconv.Itoa(i))
+		}
+		write(preset...)
+
+		elements := scanAll("ZscanKey1", "COUNT", "3")
+		// The pairs are returned in the scan order, so sort them before comparing.
+		sortPairs := func(elements []string) []string {
+			pairs := make([][2]string, 0, len(elements)/2)
+			for i := 0; i+1 < len(elements); i += 2 {
+				pairs = append(pairs, [2]string{elements[i], elements[i+1]})
+			}
+			slices.SortFunc(pairs, func(a, b [2]string) int {
+				return strings.Compare(a[0], b[0])
+			})
+			sorted := make([]string, 0, len(elements))
+			for _, pair := range pairs {
+				sorted = append(sorted, pair[0], pair[1])
+			}
+			return sorted
+		}
+		elements, expected = sortPairs(elements), sortPairs(expected)
+		if !slices.Equal(elements, expected) {
+			t.Errorf("expected elements %v, got %v", expected, elements)
+		}
+
+		// Only return the elements matching the pattern.
+		if elements = scanAll("ZscanKey1", "MATCH", "member1?"); len(elements) != 20 {
+			t.Errorf("expected 20 elements matching the pattern, got %v", elements)
+		}
+
+		// Return an empty list if the key does not exist.
+		if elements = scanAll("ZscanKey2"); len(elements) != 0 {
+			t.Errorf("expected no elements, got %v", elements)
+		}
+
+		write("SET", "ZscanKey3", "value")
+		errorTests := []struct {
+			command       []string
+			expectedError error
+		}{
+			{command: []string{"ZSCAN", "ZscanKey1"}, expectedError: errors.New(constants.WrongArgsResponse)},
+			{command: []string{"ZSCAN", "ZscanKey1", "cursor"}, expectedError: errors.New("invalid cursor")},
+			{command: []string{"ZSCAN", "ZscanKey1", "0", "COUNT", "0"}, expectedError: errors.New("syntax error")},
+			{command: []string{"ZSCAN", "ZscanKey1", "0", "TYPE", "string"}, expectedError: errors.New("syntax error")},
+			{command: []string{"ZSCAN", "ZscanKey3", "0"}, expectedError: errors.New("value at ZscanKey3 is not a sorted set")},
+		}
+		for _, test := range errorTests {
+			res := write(test.command...)
+			if res.Error() == nil || !strings.Contains(res.Error().Error(), test.expectedError.Error()) {
+				t.Errorf("expected error \"%s\" for %v, got %v", test.expectedError.Error(), test.command, res)
+			}
+		}
+	})
 }
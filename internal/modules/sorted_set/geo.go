@@ -0,0 +1,320 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sorted_set
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// geoStep is the number of bits used for each of the longitude and latitude in a geohash score.
+	// The resulting 52 bit integer can be stored in a float64 score without loss of precision.
+	geoStep   = 26
+	geoLonMin = -180
+	geoLonMax = 180
+	// The latitude limits are those of the EPSG:900913 / EPSG:3785 / OSGEO:41001 projection.
+	geoLatMin = -85.05112878
+	geoLatMax = 85.05112878
+	// earthRadius is the earth's quadratic mean radius for WGS-84 in meters.
+	earthRadius  = 6372797.560856
+	geoAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geoHashChars = 11
+)
+
+// geoUnits maps the units accepted by the geo commands to their size in meters.
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+func parseGeoUnit(unit string) (float64, error) {
+	meters, ok := geoUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, errors.New("unsupported unit provided. please use M, KM, FT, MI")
+	}
+	return meters, nil
+}
+
+func parseGeoCoordinates(lonArg, latArg string) (float64, float64, error) {
+	lon, err := strconv.ParseFloat(lonArg, 64)
+	if err != nil {
+		return 0, 0, errors.New("value is not a valid float")
+	}
+	lat, err := strconv.ParseFloat(latArg, 64)
+	if err != nil {
+		return 0, 0, errors.New("value is not a valid float")
+	}
+	if lon < geoLonMin || lon > geoLonMax || lat < geoLatMin || lat > geoLatMax {
+		return 0, 0, fmt.Errorf("invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return lon, lat, nil
+}
+
+// geohashEncode interleaves the longitude and latitude offsets within the given ranges into a 52 bit integer.
+// The longitude occupies the odd bits and the latitude the even bits, so the most significant bit is a longitude bit.
+func geohashEncode(lon, lat, latMin, latMax float64) uint64 {
+	lonOffset := uint64((lon - geoLonMin) / (geoLonMax - geoLonMin) * (1 << geoStep))
+	latOffset := uint64((lat - latMin) / (latMax - latMin) * (1 << geoStep))
+	// The maximum value of the range must fall into the last cell.
+	lonOffset = min(lonOffset, 1<<geoStep-1)
+	latOffset = min(latOffset, 1<<geoStep-1)
+
+	var hash uint64
+	for i := 0; i < geoStep; i++ {
+		hash |= (latOffset >> i & 1) << (2 * i)
+		hash |= (lonOffset >> i & 1) << (2*i + 1)
+	}
+	return hash
+}
+
+// geohashDecode returns the longitude and latitude at the centre of the cell represented by the geohash score.
+func geohashDecode(hash uint64) (float64, float64) {
+	var lonOffset, latOffset uint64
+	for i := 0; i < geoStep; i++ {
+		latOffset |= (hash >> (2 * i) & 1) << i
+		lonOffset |= (hash >> (2*i + 1) & 1) << i
+	}
+
+	cell := func(offset uint64, lower, upper float64) float64 {
+		cellMin := lower + float64(offset)/(1<<geoStep)*(upper-lower)
+		cellMax := lower + float64(offset+1)/(1<<geoStep)*(upper-lower)
+		return max(lower, min(upper, (cellMin+cellMax)/2))
+	}
+	return cell(lonOffset, geoLonMin, geoLonMax), cell(latOffset, geoLatMin, geoLatMax)
+}
+
+// geohashString returns the standard 11 character geohash of the coordinates.
+// Unlike the score, the standard geohash uses the full latitude range of -90 to 90.
+func geohashString(lon, lat float64) string {
+	hash := geohashEncode(lon, lat, -90, 90)
+	var b strings.Builder
+	for i := 0; i < geoHashChars; i++ {
+		// The hash only has 52 bits, so the last character is always the first in the alphabet.
+		idx := 0
+		if i < geoHashChars-1 {
+			idx = int(hash>>(geoStep*2-(i+1)*5)) & 0x1f
+		}
+		b.WriteByte(geoAlphabet[idx])
+	}
+	return b.String()
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs(degreesToRadians(lat2)-degreesToRadians(lat1))
+}
+
+// geoDistance returns the haversine distance in meters between two points.
+func geoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := degreesToRadians(lat1), degreesToRadians(lat2)
+	v := math.Sin((degreesToRadians(lon2) - degreesToRadians(lon1)) / 2)
+	if v == 0 {
+		return geoLatDistance(lat1, lat2)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// geoSearchOptions holds the parsed arguments of GEOSEARCH and GEOSEARCHSTORE.
+type geoSearchOptions struct {
+	fromMember string
+	lon, lat   float64
+	byBox      bool
+	radius     float64 // In meters.
+	width      float64 // In meters.
+	height     float64 // In meters.
+	unit       float64 // The size of the unit used for the reply in meters.
+	sort       string  // "asc", "desc" or "" for unsorted.
+	count      int
+	any        bool
+	withCoord  bool
+	withDist   bool
+	withHash   bool
+	storeDist  bool
+}
+
+// parseGeoSearchOptions parses the arguments that follow the source key of GEOSEARCH and GEOSEARCHSTORE.
+func parseGeoSearchOptions(args []string, store bool) (geoSearchOptions, error) {
+	options := geoSearchOptions{}
+	from, by := 0, 0
+
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		switch strings.ToLower(args[i]) {
+		case "frommember":
+			if remaining < 1 {
+				return options, errors.New("FROMMEMBER requires a member")
+			}
+			options.fromMember = args[i+1]
+			from += 1
+			i += 1
+		case "fromlonlat":
+			if remaining < 2 {
+				return options, errors.New("FROMLONLAT requires longitude and latitude")
+			}
+			lon, lat, err := parseGeoCoordinates(args[i+1], args[i+2])
+			if err != nil {
+				return options, err
+			}
+			options.lon, options.lat = lon, lat
+			from += 1
+			i += 2
+		case "byradius":
+			if remaining < 2 {
+				return options, errors.New("BYRADIUS requires radius and unit")
+			}
+			radius, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || radius < 0 {
+				return options, errors.New("radius must be a non-negative float")
+			}
+			if options.unit, err = parseGeoUnit(args[i+2]); err != nil {
+				return options, err
+			}
+			options.radius = radius * options.unit
+			by += 1
+			i += 2
+		case "bybox":
+			if remaining < 3 {
+				return options, errors.New("BYBOX requires width, height and unit")
+			}
+			width, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || width < 0 {
+				return options, errors.New("width must be a non-negative float")
+			}
+			height, err := strconv.ParseFloat(args[i+2], 64)
+			if err != nil || height < 0 {
+				return options, errors.New("height must be a non-negative float")
+			}
+			if options.unit, err = parseGeoUnit(args[i+3]); err != nil {
+				return options, err
+			}
+			options.byBox = true
+			options.width, options.height = width*options.unit, height*options.unit
+			by += 1
+			i += 3
+		case "asc", "desc":
+			options.sort = strings.ToLower(args[i])
+		case "count":
+			if remaining < 1 {
+				return options, errors.New("COUNT requires a value")
+			}
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil || count <= 0 {
+				return options, errors.New("COUNT must be > 0")
+			}
+			options.count = count
+			i += 1
+		case "any":
+			options.any = true
+		case "withcoord":
+			options.withCoord = true
+		case "withdist":
+			options.withDist = true
+		case "withhash":
+			options.withHash = true
+		case "storedist":
+			if !store {
+				return options, fmt.Errorf("unknown option %s", args[i])
+			}
+			options.storeDist = true
+		default:
+			return options, fmt.Errorf("unknown option %s", args[i])
+		}
+	}
+
+	if from != 1 {
+		return options, errors.New("exactly one of FROMMEMBER or FROMLONLAT must be provided")
+	}
+	if by != 1 {
+		return options, errors.New("exactly one of BYRADIUS or BYBOX must be provided")
+	}
+	if options.any && options.count == 0 {
+		return options, errors.New("the ANY argument requires COUNT argument")
+	}
+	if store && (options.withCoord || options.withDist || options.withHash) {
+		return options, errors.New("WITHCOORD, WITHDIST and WITHHASH are not supported by GEOSEARCHSTORE")
+	}
+	// Without ANY, COUNT returns the closest matches.
+	if options.count > 0 && !options.any && options.sort == "" {
+		options.sort = "asc"
+	}
+	return options, nil
+}
+
+// geoSearchResult is a member of the sorted set found by a geo search.
+type geoSearchResult struct {
+	member   Value
+	score    Score
+	distance float64 // In meters.
+	lon, lat float64
+}
+
+// geoSearch returns the members of the sorted set within the area described by the options.
+func geoSearch(set *SortedSet, options geoSearchOptions) ([]geoSearchResult, error) {
+	if options.fromMember != "" {
+		member := set.Get(Value(options.fromMember))
+		if !member.Exists {
+			return nil, errors.New("could not decode requested zset member")
+		}
+		options.lon, options.lat = geohashDecode(uint64(member.Score))
+	}
+
+	var results []geoSearchResult
+	for _, m := range set.GetAll() {
+		lon, lat := geohashDecode(uint64(m.Score))
+		distance := geoDistance(options.lon, options.lat, lon, lat)
+		if options.byBox {
+			// The point is within the box if both its latitude and longitude distances from the centre are within it.
+			if geoLatDistance(options.lat, lat) > options.height/2 ||
+				geoDistance(lon, lat, options.lon, lat) > options.width/2 {
+				continue
+			}
+		} else if distance > options.radius {
+			continue
+		}
+		results = append(results, geoSearchResult{member: m.Value, score: m.Score, distance: distance, lon: lon, lat: lat})
+		if options.any && len(results) == options.count {
+			break
+		}
+	}
+
+	switch options.sort {
+	case "asc":
+		slices.SortFunc(results, func(a, b geoSearchResult) int {
+			return cmp.Compare(a.distance, b.distance)
+		})
+	case "desc":
+		slices.SortFunc(results, func(a, b geoSearchResult) int {
+			return cmp.Compare(b.distance, a.distance)
+		})
+	}
+
+	if options.count > 0 && len(results) > options.count {
+		results = results[:options.count]
+	}
+	return results, nil
+}
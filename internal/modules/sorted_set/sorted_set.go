@@ -43,11 +43,13 @@ type MemberParam struct {
 
 type SortedSet struct {
 	members map[Value]MemberObject
+	index   *internal.ScanIndex // The members in their scan order, used by ZSCAN.
 }
 
 func NewSortedSet(members []MemberParam) *SortedSet {
 	s := &SortedSet{
 		members: make(map[Value]MemberObject),
+		index:   internal.NewScanIndex(),
 	}
 	for _, m := range members {
 		s.members[m.Value] = MemberObject{
@@ -55,6 +57,7 @@ func NewSortedSet(members []MemberParam) *SortedSet {
 			Score:  m.Score,
 			Exists: true,
 		}
+		s.index.Add(string(m.Value))
 	}
 	return s
 }
@@ -114,6 +117,25 @@ func (set *SortedSet) GetAll() []MemberParam {
 	return res
 }
 
+// Each calls f for each member of the sorted set until f returns false.
+func (set *SortedSet) Each(f func(m MemberParam) bool) {
+	for k, v := range set.members {
+		if !f(MemberParam{Value: k, Score: v.Score}) {
+			return
+		}
+	}
+}
+
+// Scan returns the next batch of at least count members from the cursor, and the cursor for the next call.
+func (set *SortedSet) Scan(cursor uint64, count int) ([]Value, uint64) {
+	members, next := set.index.Scan(cursor, count)
+	values := make([]Value, len(members))
+	for i, member := range members {
+		values[i] = Value(member)
+	}
+	return values, next
+}
+
 func (set *SortedSet) Cardinality() int {
 	return len(set.GetAll())
 }
@@ -155,6 +177,7 @@ func (set *SortedSet) AddOrUpdate(
 					Score:  m.Score,
 					Exists: true,
 				}
+				set.index.Add(string(m.Value))
 				// Always add count because this is the addition of a new element
 				count += 1
 				return count, err
@@ -197,6 +220,7 @@ func (set *SortedSet) AddOrUpdate(
 					Score:  m.Score,
 					Exists: true,
 				}
+				set.index.Add(string(m.Value))
 				count += 1
 			}
 			continue
@@ -210,6 +234,7 @@ func (set *SortedSet) AddOrUpdate(
 			Score:  compareScores(set.members[m.Value].Score, m.Score, comp),
 			Exists: true,
 		}
+		set.index.Add(string(m.Value))
 	}
 	return count, nil
 }
@@ -217,6 +242,7 @@ func (set *SortedSet) AddOrUpdate(
 func (set *SortedSet) Remove(v Value) bool {
 	if set.Contains(v) {
 		delete(set.members, v)
+		set.index.Remove(string(v))
 		return true
 	}
 	return false
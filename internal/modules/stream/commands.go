@@ -0,0 +1,1360 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func getStream(params internal.HandlerFuncParams, key string) (*Stream, bool, error) {
+	if !params.KeysExist([]string{key})[key] {
+		return nil, false, nil
+	}
+	stream, ok := params.GetValues(params.Context, []string{key})[key].(*Stream)
+	if !ok {
+		return nil, true, fmt.Errorf("value at %s is not a stream", key)
+	}
+	return stream, true, nil
+}
+
+func encodeBulkString(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func encodeNull(protocol int) string {
+	if protocol == constants.RESP3Protocol {
+		return constants.NullResponse
+	}
+	return "*-1\r\n"
+}
+
+// encodeEntry encodes the entry as an array made up of the ID and the field-value pairs.
+// The field-value pairs of deleted entries are encoded as null.
+func encodeEntry(protocol int, entry Entry) string {
+	res := "*2\r\n" + encodeBulkString(entry.ID.String())
+	if entry.Fields == nil {
+		return res + encodeNull(protocol)
+	}
+	res += fmt.Sprintf("*%d\r\n", len(entry.Fields))
+	for _, field := range entry.Fields {
+		res += encodeBulkString(field)
+	}
+	return res
+}
+
+func encodeEntries(protocol int, entries []Entry) string {
+	res := fmt.Sprintf("*%d\r\n", len(entries))
+	for _, entry := range entries {
+		res += encodeEntry(protocol, entry)
+	}
+	return res
+}
+
+func encodeIDs(ids []ID) string {
+	res := fmt.Sprintf("*%d\r\n", len(ids))
+	for _, id := range ids {
+		res += encodeBulkString(id.String())
+	}
+	return res
+}
+
+// encodeStreamsReply encodes the reply of XREAD and XREADGROUP. In RESP3, the reply is a map of keys to entries.
+func encodeStreamsReply(protocol int, keys []string, entries map[string][]Entry) string {
+	if len(entries) == 0 {
+		return "*-1\r\n"
+	}
+	res := fmt.Sprintf("*%d\r\n", len(entries))
+	if protocol == constants.RESP3Protocol {
+		res = fmt.Sprintf("%%%d\r\n", len(entries))
+	}
+	for _, key := range keys {
+		e, ok := entries[key]
+		if !ok {
+			continue
+		}
+		if protocol != constants.RESP3Protocol {
+			res += "*2\r\n"
+		}
+		res += encodeBulkString(key) + encodeEntries(protocol, e)
+	}
+	return res
+}
+
+// parseRangeID parses an ID used as the start or end of a range.
+// "-" and "+" are the smallest and greatest possible IDs. A "(" prefix makes the ID exclusive.
+// An incomplete ID (without the sequence number) covers all the sequence numbers of the millisecond.
+func parseRangeID(s string, start bool) (ID, error) {
+	switch s {
+	case "-":
+		return MinID, nil
+	case "+":
+		return MaxID, nil
+	}
+
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+
+	defaultSeq := uint64(0)
+	if !start {
+		defaultSeq = math.MaxUint64
+	}
+	id, err := ParseID(s, defaultSeq)
+	if err != nil {
+		return ID{}, err
+	}
+	if !exclusive {
+		return id, nil
+	}
+
+	if start {
+		if id.Compare(MaxID) == 0 {
+			return ID{}, errors.New("invalid start ID for the interval")
+		}
+		return id.Next(), nil
+	}
+	if id.Compare(MinID) == 0 {
+		return ID{}, errors.New("invalid end ID for the interval")
+	}
+	return id.Prev(), nil
+}
+
+// trimOptions holds the trimming strategy of XADD and XTRIM.
+type trimOptions struct {
+	strategy string // "maxlen" or "minid". Empty if no trimming is required.
+	approx   bool
+	maxLen   int
+	minID    ID
+	limit    int
+}
+
+// parseTrimOptions parses the trimming strategy at the start of args:
+// MAXLEN | MINID [= | ~] threshold [LIMIT count].
+// Returns the options and the number of arguments consumed.
+func parseTrimOptions(args []string) (trimOptions, int, error) {
+	options := trimOptions{strategy: strings.ToLower(args[0])}
+	i := 1
+
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		options.approx = args[i] == "~"
+		i += 1
+	}
+	if i >= len(args) {
+		return trimOptions{}, 0, errors.New(constants.WrongArgsResponse)
+	}
+
+	switch options.strategy {
+	case "maxlen":
+		maxLen, err := strconv.Atoi(args[i])
+		if err != nil || maxLen < 0 {
+			return trimOptions{}, 0, errors.New("MAXLEN must be a non-negative integer")
+		}
+		options.maxLen = maxLen
+	case "minid":
+		minID, err := ParseID(args[i], 0)
+		if err != nil {
+			return trimOptions{}, 0, err
+		}
+		options.minID = minID
+	}
+	i += 1
+
+	if i < len(args) && strings.EqualFold(args[i], "limit") {
+		if i+1 >= len(args) {
+			return trimOptions{}, 0, errors.New(constants.WrongArgsResponse)
+		}
+		if !options.approx {
+			return trimOptions{}, 0, errors.New("syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		limit, err := strconv.Atoi(args[i+1])
+		if err != nil || limit < 0 {
+			return trimOptions{}, 0, errors.New("LIMIT must be a non-negative integer")
+		}
+		options.limit = limit
+		i += 2
+	}
+
+	return options, i, nil
+}
+
+func (options trimOptions) apply(stream *Stream) int {
+	switch options.strategy {
+	case "maxlen":
+		return stream.TrimMaxLen(options.maxLen, options.approx, options.limit)
+	case "minid":
+		return stream.TrimMinID(options.minID, options.approx, options.limit)
+	}
+	return 0
+}
+
+// waitForEntries calls read until it returns a non-empty reply.
+// If block is false, or the command is not allowed to block, read is only called once.
+// Otherwise, read is called again each time one of the keys is modified, until the timeout is reached.
+// A timeout of 0 blocks indefinitely.
+func waitForEntries(params internal.HandlerFuncParams, keys []string, block bool, timeout time.Duration,
+	read func() (map[string][]Entry, error)) (map[string][]Entry, error) {
+	var deadline <-chan time.Time
+	if block && timeout > 0 {
+		deadline = params.GetClock().After(timeout)
+	}
+
+	for {
+		var notify <-chan struct{}
+		cancel := func() {}
+		if block && params.NotifyOnKeys != nil {
+			notify, cancel = params.NotifyOnKeys(keys)
+		}
+
+		entries, err := read()
+		if err != nil || len(entries) > 0 || notify == nil {
+			cancel()
+			return entries, err
+		}
+
+		notified := params.Wait(notify, deadline)
+		cancel()
+		if !notified {
+			return entries, nil
+		}
+	}
+}
+
+// applyWhenDelivered is used by XREADGROUP in cluster mode, where the command is applied through raft.
+// The streams are only read on this node: if block is true, it waits until one of the streams has entries that were
+// never delivered to the group before it applies the command. If another consumer read the entries first,
+// it waits again.
+func applyWhenDelivered(params internal.HandlerFuncParams, keys []string, group string, block bool,
+	timeout time.Duration) ([]byte, error) {
+	var res []byte
+	_, err := waitForEntries(params, keys, block, timeout, func() (map[string][]Entry, error) {
+		available := !block
+		for _, key := range keys {
+			stream, exists, err := getStream(params, key)
+			if err != nil {
+				return nil, err
+			}
+			available = available || (exists && stream.HasUndelivered(group))
+		}
+		if !available {
+			return nil, nil
+		}
+		r, err := params.ApplyCommand(params.Context, params.Command)
+		if err != nil || internal.IsNullResponse(r) {
+			return nil, err
+		}
+		res = r
+		// The entries are only used to tell waitForEntries that the command is complete.
+		return map[string][]Entry{keys[0]: nil}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return []byte(encodeStreamsReply(params.Protocol, keys, nil)), nil
+	}
+	return res, nil
+}
+
+func parseBlockTimeout(s string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return 0, errors.New("timeout is negative")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func handleXADD(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xaddKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	noMkStream := false
+	trim := trimOptions{}
+
+	i := 2
+options:
+	for i < len(params.Command) {
+		switch strings.ToLower(params.Command[i]) {
+		case "nomkstream":
+			noMkStream = true
+			i += 1
+		case "maxlen", "minid":
+			var n int
+			trim, n, err = parseTrimOptions(params.Command[i:])
+			if err != nil {
+				return nil, err
+			}
+			i += n
+		default:
+			break options
+		}
+	}
+
+	fields := params.Command[min(i+1, len(params.Command)):]
+	if i >= len(params.Command) || len(fields) == 0 || len(fields)%2 != 0 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+
+	stream, exists, err := getStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if noMkStream {
+			return []byte("$-1\r\n"), nil
+		}
+		stream = NewStream()
+	}
+
+	var id ID
+	switch arg := params.Command[i]; {
+	case arg == "*":
+		id = stream.NextID(params.GetClock().Now())
+	case strings.HasSuffix(arg, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(arg, "-*"), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid stream ID specified as stream command argument")
+		}
+		if id, err = stream.NextSeq(ms); err != nil {
+			return nil, err
+		}
+	default:
+		if id, err = ParseID(arg, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = stream.Add(id, fields); err != nil {
+		return nil, err
+	}
+	// Replace the ID with the generated ID so the command is deterministic when it's replayed.
+	params.Command[i] = id.String()
+
+	if !exists {
+		if err = params.SetValues(params.Context, map[string]interface{}{key: stream}); err != nil {
+			return nil, err
+		}
+	}
+
+	trim.apply(stream)
+
+	return []byte(encodeBulkString(id.String())), nil
+}
+
+func handleXLEN(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xlenKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, exists, err := getStream(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []byte(":0\r\n"), nil
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", stream.Len())), nil
+}
+
+func handleXRANGE(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xrangeKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	rev := strings.EqualFold(params.Command[0], "xrevrange")
+	startArg, endArg := params.Command[2], params.Command[3]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+
+	start, err := parseRangeID(startArg, true)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseRangeID(endArg, false)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	if len(params.Command) == 6 {
+		if !strings.EqualFold(params.Command[4], "count") {
+			return nil, errors.New("syntax error")
+		}
+		count, err = strconv.Atoi(params.Command[5])
+		if err != nil {
+			return nil, errors.New("count must be an integer")
+		}
+		if count <= 0 {
+			return []byte("*0\r\n"), nil
+		}
+	}
+
+	stream, exists, err := getStream(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []byte("*0\r\n"), nil
+	}
+
+	return []byte(encodeEntries(params.Protocol, stream.Range(start, end, count, rev))), nil
+}
+
+func handleXDEL(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xdelKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]ID, len(params.Command[2:]))
+	for i, arg := range params.Command[2:] {
+		if ids[i], err = ParseID(arg, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, exists, err := getStream(params, keys.WriteKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []byte(":0\r\n"), nil
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", stream.Delete(ids))), nil
+}
+
+func handleXTRIM(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xtrimKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(params.Command[2], "maxlen") && !strings.EqualFold(params.Command[2], "minid") {
+		return nil, errors.New("syntax error")
+	}
+	trim, n, err := parseTrimOptions(params.Command[2:])
+	if err != nil {
+		return nil, err
+	}
+	if n != len(params.Command[2:]) {
+		return nil, errors.New("syntax error")
+	}
+
+	stream, exists, err := getStream(params, keys.WriteKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []byte(":0\r\n"), nil
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", trim.apply(stream))), nil
+}
+
+func handleXREAD(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xreadKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	block := false
+	var timeout time.Duration
+
+	for i := 1; i < len(params.Command) && !strings.EqualFold(params.Command[i], "streams"); i++ {
+		if i+1 >= len(params.Command) {
+			return nil, errors.New("syntax error")
+		}
+		switch strings.ToLower(params.Command[i]) {
+		default:
+			return nil, errors.New("syntax error")
+		case "count":
+			if count, err = strconv.Atoi(params.Command[i+1]); err != nil {
+				return nil, errors.New("count must be an integer")
+			}
+		case "block":
+			if timeout, err = parseBlockTimeout(params.Command[i+1]); err != nil {
+				return nil, err
+			}
+			block = true
+		}
+		i += 1
+	}
+
+	_, args, err := streamsArgs(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the IDs once, so "$" only returns the entries added after the command was called.
+	ids := make([]ID, len(args))
+	for i, arg := range args {
+		if arg != "$" {
+			if ids[i], err = ParseID(arg, 0); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		stream, exists, err := getStream(params, keys.ReadKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			ids[i] = stream.LastID()
+		}
+	}
+
+	entries, err := waitForEntries(params, keys.ReadKeys, block, timeout, func() (map[string][]Entry, error) {
+		res := make(map[string][]Entry)
+		for i, key := range keys.ReadKeys {
+			stream, exists, err := getStream(params, key)
+			if err != nil {
+				return nil, err
+			}
+			if !exists || ids[i].Compare(MaxID) == 0 {
+				continue
+			}
+			if e := stream.Range(ids[i].Next(), MaxID, count, false); len(e) > 0 {
+				res[key] = e
+			}
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(encodeStreamsReply(params.Protocol, keys.ReadKeys, entries)), nil
+}
+
+func handleXGROUPCREATE(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xgroupCreateKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	group := params.Command[3]
+	mkStream := false
+	entriesRead := int64(0)
+
+	for i := 5; i < len(params.Command); i++ {
+		switch strings.ToLower(params.Command[i]) {
+		default:
+			return nil, errors.New("syntax error")
+		case "mkstream":
+			mkStream = true
+		case "entriesread":
+			if i+1 >= len(params.Command) {
+				return nil, errors.New("syntax error")
+			}
+			if entriesRead, err = strconv.ParseInt(params.Command[i+1], 10, 64); err != nil || entriesRead < 0 {
+				return nil, errors.New("value for ENTRIESREAD must be positive or -1")
+			}
+			i += 1
+		}
+	}
+
+	stream, exists, err := getStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if !mkStream {
+			return nil, errors.New("The XGROUP subcommand requires the key to exist. " +
+				"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+		stream = NewStream()
+	}
+
+	id := stream.LastID()
+	if params.Command[4] != "$" {
+		if id, err = ParseID(params.Command[4], 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = stream.CreateGroup(group, id, entriesRead); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		if err = params.SetValues(params.Context, map[string]interface{}{key: stream}); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(constants.OkResponse), nil
+}
+
+// getGroupStream returns the stream at key for the XGROUP subcommands, which require the key to exist.
+func getGroupStream(params internal.HandlerFuncParams, key string) (*Stream, error) {
+	stream, exists, err := getStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("The XGROUP subcommand requires the key to exist. " +
+			"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	}
+	return stream, nil
+}
+
+func handleXGROUPSETID(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xgroupSetIDKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	group := params.Command[3]
+
+	entriesRead := int64(0)
+	if len(params.Command) == 7 {
+		if !strings.EqualFold(params.Command[5], "entriesread") {
+			return nil, errors.New("syntax error")
+		}
+		if entriesRead, err = strconv.ParseInt(params.Command[6], 10, 64); err != nil || entriesRead < 0 {
+			return nil, errors.New("value for ENTRIESREAD must be positive or -1")
+		}
+	}
+
+	stream, err := getGroupStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+
+	id := stream.LastID()
+	if params.Command[4] != "$" {
+		if id, err = ParseID(params.Command[4], 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if !stream.SetGroupID(group, id, entriesRead) {
+		return nil, fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+	}
+
+	return []byte(constants.OkResponse), nil
+}
+
+func handleXGROUPDESTROY(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xgroupDestroyKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := getGroupStream(params, keys.WriteKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if stream.DestroyGroup(params.Command[3]) {
+		return []byte(":1\r\n"), nil
+	}
+	return []byte(":0\r\n"), nil
+}
+
+func handleXGROUPCREATECONSUMER(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xgroupConsumerKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	group := params.Command[3]
+
+	stream, err := getGroupStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := stream.CreateConsumer(group, params.Command[4], params.GetClock().Now())
+	if err != nil {
+		return nil, fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+	}
+
+	if created {
+		return []byte(":1\r\n"), nil
+	}
+	return []byte(":0\r\n"), nil
+}
+
+func handleXGROUPDELCONSUMER(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xgroupConsumerKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	group := params.Command[3]
+
+	stream, err := getGroupStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := stream.DeleteConsumer(group, params.Command[4])
+	if err != nil {
+		return nil, fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", pending)), nil
+}
+
+func handleXREADGROUP(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xreadgroupKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(params.Command[1], "group") {
+		return nil, errors.New("syntax error")
+	}
+	group, consumer := params.Command[2], params.Command[3]
+
+	count := 0
+	block := false
+	noAck := false
+	var timeout time.Duration
+
+	for i := 4; i < len(params.Command) && !strings.EqualFold(params.Command[i], "streams"); i++ {
+		switch strings.ToLower(params.Command[i]) {
+		default:
+			return nil, errors.New("syntax error")
+		case "noack":
+			noAck = true
+		case "count":
+			if i+1 >= len(params.Command) {
+				return nil, errors.New("syntax error")
+			}
+			if count, err = strconv.Atoi(params.Command[i+1]); err != nil {
+				return nil, errors.New("count must be an integer")
+			}
+			i += 1
+		case "block":
+			if i+1 >= len(params.Command) {
+				return nil, errors.New("syntax error")
+			}
+			if timeout, err = parseBlockTimeout(params.Command[i+1]); err != nil {
+				return nil, err
+			}
+			block = true
+			i += 1
+		}
+	}
+
+	_, args, err := streamsArgs(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	// ">" reads entries that have never been delivered to the group.
+	// Any other ID reads the history of the consumer's pending entries, which never blocks.
+	ids := make([]*ID, len(args))
+	for i, arg := range args {
+		if arg == ">" {
+			continue
+		}
+		id, err := ParseID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = &id
+		block = false
+	}
+
+	for _, key := range keys.WriteKeys {
+		stream, exists, err := getStream(params, key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists || !stream.HasGroup(group) {
+			return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+		}
+	}
+
+	if params.ApplyCommand != nil {
+		return applyWhenDelivered(params, keys.WriteKeys, group, block, timeout)
+	}
+
+	entries, err := waitForEntries(params, keys.WriteKeys, block, timeout, func() (map[string][]Entry, error) {
+		res := make(map[string][]Entry)
+		now := params.GetClock().Now()
+		for i, key := range keys.WriteKeys {
+			stream, exists, err := getStream(params, key)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+			}
+
+			if ids[i] != nil {
+				// The history is always included in the reply, even when it's empty.
+				e, err := stream.ReadGroupHistory(group, consumer, *ids[i], count, now)
+				if err != nil {
+					return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+				}
+				res[key] = e
+				continue
+			}
+
+			e, err := stream.ReadGroupNew(group, consumer, count, noAck, now)
+			if err != nil {
+				return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+			}
+			if len(e) > 0 {
+				res[key] = e
+			}
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(encodeStreamsReply(params.Protocol, keys.WriteKeys, entries)), nil
+}
+
+func handleXACK(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xackKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]ID, len(params.Command[3:]))
+	for i, arg := range params.Command[3:] {
+		if ids[i], err = ParseID(arg, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, exists, err := getStream(params, keys.WriteKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []byte(":0\r\n"), nil
+	}
+
+	count, err := stream.Ack(params.Command[2], ids)
+	if err != nil {
+		return []byte(":0\r\n"), nil
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", count)), nil
+}
+
+func handleXPENDING(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xpendingKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.ReadKeys[0]
+	group := params.Command[2]
+
+	stream, exists, err := getStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || !stream.HasGroup(group) {
+		return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+
+	// Summary form.
+	if len(params.Command) == 3 {
+		entries, consumers, err := stream.PendingSummary(group)
+		if err != nil {
+			return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+		}
+		if len(entries) == 0 {
+			if params.Protocol == constants.RESP3Protocol {
+				return []byte("*4\r\n:0\r\n_\r\n_\r\n_\r\n"), nil
+			}
+			return []byte("*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n"), nil
+		}
+		res := fmt.Sprintf("*4\r\n:%d\r\n%s%s*%d\r\n",
+			len(entries),
+			encodeBulkString(entries[0].ID.String()),
+			encodeBulkString(entries[len(entries)-1].ID.String()),
+			len(consumers))
+		names := make([]string, 0, len(consumers))
+		for name := range consumers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			res += "*2\r\n" + encodeBulkString(name) + encodeBulkString(strconv.Itoa(consumers[name]))
+		}
+		return []byte(res), nil
+	}
+
+	// Extended form: [IDLE min-idle-time] start end count [consumer].
+	args := params.Command[3:]
+	var minIdle time.Duration
+	if strings.EqualFold(args[0], "idle") {
+		idle, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, errors.New("min-idle-time must be an integer")
+		}
+		minIdle = time.Duration(idle) * time.Millisecond
+		args = args[2:]
+	}
+	if len(args) < 3 || len(args) > 4 {
+		return nil, errors.New("syntax error")
+	}
+
+	start, err := parseRangeID(args[0], true)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseRangeID(args[1], false)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, errors.New("count must be an integer")
+	}
+	if count <= 0 {
+		return []byte("*0\r\n"), nil
+	}
+	consumer := ""
+	if len(args) == 4 {
+		consumer = args[3]
+	}
+
+	now := params.GetClock().Now()
+	entries, err := stream.PendingRange(group, start, end, count, consumer, minIdle, now)
+	if err != nil {
+		return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+
+	res := fmt.Sprintf("*%d\r\n", len(entries))
+	for _, entry := range entries {
+		res += fmt.Sprintf("*4\r\n%s%s:%d\r\n:%d\r\n",
+			encodeBulkString(entry.ID.String()),
+			encodeBulkString(entry.Consumer),
+			max(now.Sub(entry.DeliveryTime).Milliseconds(), 0),
+			entry.DeliveryCount)
+	}
+	return []byte(res), nil
+}
+
+func handleXCLAIM(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xclaimKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	group, consumer := params.Command[2], params.Command[3]
+
+	minIdle, err := strconv.ParseInt(params.Command[4], 10, 64)
+	if err != nil {
+		return nil, errors.New("min-idle-time must be an integer")
+	}
+
+	now := params.GetClock().Now()
+	options := ClaimOptions{
+		DeliveryTime: now,
+		RetryCount:   -1,
+		MinIdle:      time.Duration(max(minIdle, 0)) * time.Millisecond,
+		Now:          now,
+	}
+
+	// The IDs are followed by the options.
+	i := 5
+	ids := make([]ID, 0)
+	for ; i < len(params.Command); i++ {
+		id, err := ParseID(params.Command[i], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("invalid stream ID specified as stream command argument")
+	}
+
+	for ; i < len(params.Command); i++ {
+		option := strings.ToLower(params.Command[i])
+		switch option {
+		default:
+			return nil, fmt.Errorf("unrecognized XCLAIM option '%s'", params.Command[i])
+		case "force":
+			options.Force = true
+			continue
+		case "justid":
+			options.JustID = true
+			continue
+		case "idle", "time", "retrycount", "lastid":
+		}
+
+		if i+1 >= len(params.Command) {
+			return nil, errors.New("syntax error")
+		}
+		arg := params.Command[i+1]
+		i += 1
+
+		switch option {
+		case "idle":
+			idle, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, errors.New("invalid IDLE option argument for XCLAIM")
+			}
+			options.DeliveryTime = now.Add(-time.Duration(idle) * time.Millisecond)
+		case "time":
+			ms, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, errors.New("invalid TIME option argument for XCLAIM")
+			}
+			options.DeliveryTime = time.UnixMilli(ms)
+		case "retrycount":
+			retryCount, err := strconv.Atoi(arg)
+			if err != nil || retryCount < 0 {
+				return nil, errors.New("invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			options.RetryCount = retryCount
+		case "lastid":
+			if options.LastID, err = ParseID(arg, 0); err != nil {
+				return nil, err
+			}
+			options.SetLastID = true
+		}
+	}
+
+	stream, exists, err := getStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+
+	entries, err := stream.Claim(group, consumer, ids, options)
+	if err != nil {
+		return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+
+	if options.JustID {
+		claimed := make([]ID, len(entries))
+		for i, entry := range entries {
+			claimed[i] = entry.ID
+		}
+		return []byte(encodeIDs(claimed)), nil
+	}
+	return []byte(encodeEntries(params.Protocol, entries)), nil
+}
+
+func handleXAUTOCLAIM(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := xautoclaimKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+	group, consumer := params.Command[2], params.Command[3]
+
+	minIdle, err := strconv.ParseInt(params.Command[4], 10, 64)
+	if err != nil {
+		return nil, errors.New("min-idle-time must be an integer")
+	}
+	start, err := parseRangeID(params.Command[5], true)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 100
+	justID := false
+	for i := 6; i < len(params.Command); i++ {
+		switch strings.ToLower(params.Command[i]) {
+		default:
+			return nil, errors.New("syntax error")
+		case "justid":
+			justID = true
+		case "count":
+			if i+1 >= len(params.Command) {
+				return nil, errors.New("syntax error")
+			}
+			if count, err = strconv.Atoi(params.Command[i+1]); err != nil || count < 1 {
+				return nil, errors.New("COUNT must be > 0")
+			}
+			i += 1
+		}
+	}
+
+	stream, exists, err := getStream(params, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+
+	next, entries, deleted, err := stream.AutoClaim(group, consumer,
+		time.Duration(max(minIdle, 0))*time.Millisecond, start, count, justID, params.GetClock().Now())
+	if err != nil {
+		return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+
+	res := "*3\r\n" + encodeBulkString(next.String())
+	if justID {
+		claimed := make([]ID, len(entries))
+		for i, entry := range entries {
+			claimed[i] = entry.ID
+		}
+		res += encodeIDs(claimed)
+	} else {
+		res += encodeEntries(params.Protocol, entries)
+	}
+	res += encodeIDs(deleted)
+
+	return []byte(res), nil
+}
+
+func Commands() []internal.Command {
+	return []internal.Command{
+		{
+			Command:    "xadd",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(XADD key [NOMKSTREAM] [<MAXLEN | MINID> [= | ~] threshold [LIMIT count]] <* | id> field value [field value ...])
+Appends an entry with the field-value pairs to the stream at key and returns the ID of the entry.
+If the ID is "*", the ID is generated from the current time. If the ID is "<ms>-*", the sequence number is generated.
+"NOMKSTREAM" does not create the stream if it does not exist, a nil value is returned instead.
+"MAXLEN" and "MINID" trim the stream after the entry is added, see XTRIM.`,
+			Sync:              true,
+			KeyExtractionFunc: xaddKeyFunc,
+			HandlerFunc:       handleXADD,
+		},
+		{
+			Command:    "xlen",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.ReadCategory, constants.FastCategory},
+			Description: `(XLEN key) Returns the number of entries in the stream at key.
+If the key does not exist, 0 is returned.`,
+			Sync:              false,
+			KeyExtractionFunc: xlenKeyFunc,
+			HandlerFunc:       handleXLEN,
+		},
+		{
+			Command:    "xrange",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(XRANGE key start end [COUNT count])
+Returns the entries of the stream at key with IDs between start and end inclusive.
+"-" and "+" are the smallest and greatest possible IDs. Prefix an ID with "(" to make it exclusive.
+"COUNT" limits the number of entries returned.`,
+			Sync:              false,
+			KeyExtractionFunc: xrangeKeyFunc,
+			HandlerFunc:       handleXRANGE,
+		},
+		{
+			Command:    "xrevrange",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(XREVRANGE key end start [COUNT count])
+Returns the entries of the stream at key with IDs between end and start inclusive, in reverse order.
+"-" and "+" are the smallest and greatest possible IDs. Prefix an ID with "(" to make it exclusive.
+"COUNT" limits the number of entries returned.`,
+			Sync:              false,
+			KeyExtractionFunc: xrangeKeyFunc,
+			HandlerFunc:       handleXRANGE,
+		},
+		{
+			Command:    "xdel",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(XDEL key id [id ...])
+Deletes the entries with the given IDs from the stream at key. Returns the number of entries deleted.`,
+			Sync:              true,
+			KeyExtractionFunc: xdelKeyFunc,
+			HandlerFunc:       handleXDEL,
+		},
+		{
+			Command:    "xtrim",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(XTRIM key <MAXLEN | MINID> [= | ~] threshold [LIMIT count])
+Trims the stream at key and returns the number of entries removed.
+"MAXLEN" removes the oldest entries until the stream has at most threshold entries.
+"MINID" removes the entries with IDs lower than threshold.
+"~" trims approximately, which only removes whole nodes of the stream and may leave a few extra entries.
+"LIMIT" caps the number of entries removed by an approximate trim.`,
+			Sync:              true,
+			KeyExtractionFunc: xtrimKeyFunc,
+			HandlerFunc:       handleXTRIM,
+		},
+		{
+			Command: "xread",
+			Module:  constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.ReadCategory,
+				constants.SlowCategory, constants.BlockingCategory},
+			Description: `(XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...])
+Returns the entries with IDs greater than the given ID from each of the streams.
+"$" as the ID only returns the entries added after the command was called.
+"BLOCK" waits for entries to be added when none are available, 0 waits indefinitely.
+Returns nil if no entries are available.`,
+			Sync:              false,
+			KeyExtractionFunc: xreadKeyFunc,
+			HandlerFunc:       handleXREAD,
+		},
+		{
+			Command:           "xgroup",
+			Module:            constants.StreamModule,
+			Categories:        []string{},
+			Description:       "Stream consumer group commands",
+			Sync:              false,
+			KeyExtractionFunc: xgroupKeyFunc,
+			SubCommands: []internal.SubCommand{
+				{
+					Command:    "create",
+					Module:     constants.StreamModule,
+					Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.SlowCategory},
+					Description: `(XGROUP CREATE key group <id | $> [MKSTREAM] [ENTRIESREAD entries-read])
+Creates a consumer group on the stream at key that delivers the entries with IDs greater than id.
+"$" delivers only the entries added after the group is created.
+"MKSTREAM" creates an empty stream if the key does not exist.`,
+					Sync:              true,
+					KeyExtractionFunc: xgroupCreateKeyFunc,
+					HandlerFunc:       handleXGROUPCREATE,
+				},
+				{
+					Command:    "setid",
+					Module:     constants.StreamModule,
+					Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.SlowCategory},
+					Description: `(XGROUP SETID key group <id | $> [ENTRIESREAD entries-read])
+Sets the last delivered ID of the consumer group.`,
+					Sync:              true,
+					KeyExtractionFunc: xgroupSetIDKeyFunc,
+					HandlerFunc:       handleXGROUPSETID,
+				},
+				{
+					Command:    "destroy",
+					Module:     constants.StreamModule,
+					Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.SlowCategory},
+					Description: `(XGROUP DESTROY key group)
+Destroys the consumer group along with its consumers and pending entries.
+Returns 1 if the group was destroyed, otherwise 0.`,
+					Sync:              true,
+					KeyExtractionFunc: xgroupDestroyKeyFunc,
+					HandlerFunc:       handleXGROUPDESTROY,
+				},
+				{
+					Command:    "createconsumer",
+					Module:     constants.StreamModule,
+					Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.SlowCategory},
+					Description: `(XGROUP CREATECONSUMER key group consumer)
+Creates a consumer in the consumer group. Returns 1 if the consumer was created, otherwise 0.`,
+					Sync:              true,
+					KeyExtractionFunc: xgroupConsumerKeyFunc,
+					HandlerFunc:       handleXGROUPCREATECONSUMER,
+				},
+				{
+					Command:    "delconsumer",
+					Module:     constants.StreamModule,
+					Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.SlowCategory},
+					Description: `(XGROUP DELCONSUMER key group consumer)
+Deletes the consumer from the consumer group. Returns the number of pending entries the consumer had.`,
+					Sync:              true,
+					KeyExtractionFunc: xgroupConsumerKeyFunc,
+					HandlerFunc:       handleXGROUPDELCONSUMER,
+				},
+			},
+		},
+		{
+			Command: "xreadgroup",
+			Module:  constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory,
+				constants.SlowCategory, constants.BlockingCategory},
+			Description: `(XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...])
+Reads the entries of the streams on behalf of the consumer of the consumer group.
+">" as the ID delivers the entries that have never been delivered to any consumer of the group,
+and adds them to the consumer's pending entries list unless "NOACK" is provided.
+Any other ID returns the consumer's pending entries with IDs greater than the ID.
+"BLOCK" waits for new entries when none are available, 0 waits indefinitely.`,
+			Sync:              true,
+			KeyExtractionFunc: xreadgroupKeyFunc,
+			HandlerFunc:       handleXREADGROUP,
+		},
+		{
+			Command:    "xack",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(XACK key group id [id ...])
+Removes the IDs from the pending entries list of the consumer group. Returns the number of entries acknowledged.`,
+			Sync:              true,
+			KeyExtractionFunc: xackKeyFunc,
+			HandlerFunc:       handleXACK,
+		},
+		{
+			Command:    "xpending",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(XPENDING key group [[IDLE min-idle-time] start end count [consumer]])
+Returns a summary of the pending entries of the consumer group: the number of entries,
+the smallest and greatest IDs and the number of pending entries of each consumer.
+When the range is provided, returns the ID, consumer, idle time and delivery count of each pending entry in the range.`,
+			Sync:              false,
+			KeyExtractionFunc: xpendingKeyFunc,
+			HandlerFunc:       handleXPENDING,
+		},
+		{
+			Command:    "xclaim",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid])
+Transfers the ownership of the pending entries that have been idle for at least min-idle-time to the consumer.
+"IDLE" and "TIME" set the delivery time of the claimed entries. "RETRYCOUNT" sets their delivery count.
+"FORCE" adds the entries to the pending entries list even if they're not pending.
+"JUSTID" only returns the IDs and does not increment the delivery count.`,
+			Sync:              true,
+			KeyExtractionFunc: xclaimKeyFunc,
+			HandlerFunc:       handleXCLAIM,
+		},
+		{
+			Command:    "xautoclaim",
+			Module:     constants.StreamModule,
+			Categories: []string{constants.StreamCategory, constants.WriteCategory, constants.FastCategory},
+			Description: `(XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID])
+Transfers the ownership of up to count (default 100) pending entries that have been idle for at least min-idle-time,
+starting from start, to the consumer. Returns the ID to use as start in the next call, the claimed entries and
+the IDs of the pending entries that no longer exist in the stream.`,
+			Sync:              true,
+			KeyExtractionFunc: xautoclaimKeyFunc,
+			HandlerFunc:       handleXAUTOCLAIM,
+		},
+	}
+}
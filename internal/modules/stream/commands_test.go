@@ -0,0 +1,494 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream_test
+
+import (
+	"fmt"
+	"github.com/echovault/echovault/echovault"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/constants"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The mock clock used in tests always returns 2006-01-02T15:04:05+07:00.
+const mockMs = "1136189045000"
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func array(elems ...string) string {
+	return fmt.Sprintf("*%d\r\n%s", len(elems), strings.Join(elems, ""))
+}
+
+func entry(id string, fields ...string) string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = bulk(field)
+	}
+	return array(bulk(id), array(values...))
+}
+
+type streamTest struct {
+	command  []string
+	expected string
+}
+
+func runStreamTests(t *testing.T, conn net.Conn, tests []streamTest) {
+	buf := make([]byte, 4096)
+	for _, test := range tests {
+		if _, err := conn.Write(internal.EncodeCommand(test.command)); err != nil {
+			t.Error(err)
+			return
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(buf[:n]) != test.expected {
+			t.Errorf("%v: expected response %q, got %q", test.command, test.expected, string(buf[:n]))
+		}
+	}
+}
+
+func Test_Stream(t *testing.T) {
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mockServer, err := echovault.NewEchoVault(
+		echovault.WithConfig(config.Config{
+			BindAddr:       "localhost",
+			Port:           uint16(port),
+			DataDir:        "",
+			EvictionPolicy: constants.NoEviction,
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		mockServer.Start()
+	}()
+
+	t.Cleanup(func() {
+		mockServer.ShutDown()
+	})
+
+	t.Run("Test_HandleXADD", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		runStreamTests(t, conn, []streamTest{
+			{ // 1. Generate the ID from the current time.
+				command:  []string{"XADD", "XaddKey1", "*", "field1", "value1"},
+				expected: bulk(mockMs + "-0"),
+			},
+			{ // 2. The sequence number is incremented when the time has not moved forward.
+				command:  []string{"XADD", "XaddKey1", "*", "field2", "value2"},
+				expected: bulk(mockMs + "-1"),
+			},
+			{ // 3. Generate the sequence number of an explicit millisecond time.
+				command:  []string{"XADD", "XaddKey2", "5-*", "field1", "value1"},
+				expected: bulk("5-0"),
+			},
+			{
+				command:  []string{"XADD", "XaddKey2", "5-*", "field1", "value1"},
+				expected: bulk("5-1"),
+			},
+			{ // 4. Explicit ID.
+				command:  []string{"XADD", "XaddKey2", "7-3", "field1", "value1"},
+				expected: bulk("7-3"),
+			},
+			{ // 5. Return an error when the ID is not greater than the last ID.
+				command:  []string{"XADD", "XaddKey2", "7-3", "field1", "value1"},
+				expected: "-Error The ID specified in XADD is equal or smaller than the target stream top item\r\n",
+			},
+			{ // 6. Return an error when the ID is 0-0.
+				command:  []string{"XADD", "XaddKey3", "0-0", "field1", "value1"},
+				expected: "-Error The ID specified in XADD must be greater than 0-0\r\n",
+			},
+			{ // 7. NOMKSTREAM does not create the stream.
+				command:  []string{"XADD", "XaddKey4", "NOMKSTREAM", "*", "field1", "value1"},
+				expected: "$-1\r\n",
+			},
+			{
+				command:  []string{"XLEN", "XaddKey4"},
+				expected: ":0\r\n",
+			},
+			{ // 8. MAXLEN trims the stream after adding the entry.
+				command:  []string{"XADD", "XaddKey2", "MAXLEN", "2", "8-0", "field1", "value1"},
+				expected: bulk("8-0"),
+			},
+			{
+				command:  []string{"XRANGE", "XaddKey2", "-", "+"},
+				expected: array(entry("7-3", "field1", "value1"), entry("8-0", "field1", "value1")),
+			},
+			{ // 9. Return an error when the field-value pairs are unbalanced.
+				command:  []string{"XADD", "XaddKey5", "*", "field1", "value1", "field2"},
+				expected: "-Error " + constants.WrongArgsResponse + "\r\n",
+			},
+			{ // 10. Return an error when the key is not a stream.
+				command:  []string{"SET", "XaddKey6", "value"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"XADD", "XaddKey6", "*", "field1", "value1"},
+				expected: "-Error value at XaddKey6 is not a stream\r\n",
+			},
+		})
+	})
+
+	t.Run("Test_HandleXRANGE", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		runStreamTests(t, conn, []streamTest{
+			{command: []string{"XADD", "XrangeKey1", "1-1", "a", "1"}, expected: bulk("1-1")},
+			{command: []string{"XADD", "XrangeKey1", "1-2", "b", "2"}, expected: bulk("1-2")},
+			{command: []string{"XADD", "XrangeKey1", "2-1", "c", "3"}, expected: bulk("2-1")},
+			{command: []string{"XADD", "XrangeKey1", "3-1", "d", "4"}, expected: bulk("3-1")},
+			{ // 1. Full range.
+				command: []string{"XRANGE", "XrangeKey1", "-", "+"},
+				expected: array(entry("1-1", "a", "1"), entry("1-2", "b", "2"),
+					entry("2-1", "c", "3"), entry("3-1", "d", "4")),
+			},
+			{ // 2. Incomplete IDs cover the whole millisecond.
+				command:  []string{"XRANGE", "XrangeKey1", "1", "1"},
+				expected: array(entry("1-1", "a", "1"), entry("1-2", "b", "2")),
+			},
+			{ // 3. Exclusive ranges.
+				command:  []string{"XRANGE", "XrangeKey1", "(1-1", "(3-1"},
+				expected: array(entry("1-2", "b", "2"), entry("2-1", "c", "3")),
+			},
+			{ // 4. COUNT.
+				command:  []string{"XRANGE", "XrangeKey1", "-", "+", "COUNT", "1"},
+				expected: array(entry("1-1", "a", "1")),
+			},
+			{ // 5. XREVRANGE returns the entries in reverse order.
+				command:  []string{"XREVRANGE", "XrangeKey1", "+", "2", "COUNT", "5"},
+				expected: array(entry("3-1", "d", "4"), entry("2-1", "c", "3")),
+			},
+			{ // 6. Non-existent key.
+				command:  []string{"XRANGE", "XrangeKey2", "-", "+"},
+				expected: "*0\r\n",
+			},
+			{ // 7. Invalid ID.
+				command:  []string{"XRANGE", "XrangeKey1", "abc", "+"},
+				expected: "-Error invalid stream ID specified as stream command argument\r\n",
+			},
+		})
+	})
+
+	t.Run("Test_HandleXDEL_XTRIM", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := make([]streamTest, 0)
+		for i := 1; i <= 250; i++ {
+			tests = append(tests, streamTest{
+				command:  []string{"XADD", "XtrimKey1", fmt.Sprintf("%d-0", i), "field", "value"},
+				expected: bulk(fmt.Sprintf("%d-0", i)),
+			})
+		}
+		tests = append(tests, []streamTest{
+			{ // 1. Delete existing and non-existent entries.
+				command:  []string{"XDEL", "XtrimKey1", "1-0", "2-0", "1000-0"},
+				expected: ":2\r\n",
+			},
+			{command: []string{"XLEN", "XtrimKey1"}, expected: ":248\r\n"},
+			{ // 2. Approximate trimming only removes whole nodes.
+				command:  []string{"XTRIM", "XtrimKey1", "MAXLEN", "~", "140"},
+				expected: ":98\r\n",
+			},
+			{command: []string{"XLEN", "XtrimKey1"}, expected: ":150\r\n"},
+			{ // 3. Exact trimming.
+				command:  []string{"XTRIM", "XtrimKey1", "MAXLEN", "=", "140"},
+				expected: ":10\r\n",
+			},
+			{ // 4. MINID.
+				command:  []string{"XTRIM", "XtrimKey1", "MINID", "200"},
+				expected: ":89\r\n",
+			},
+			{command: []string{"XLEN", "XtrimKey1"}, expected: ":51\r\n"},
+			{ // 5. LIMIT requires approximate trimming.
+				command:  []string{"XTRIM", "XtrimKey1", "MAXLEN", "0", "LIMIT", "10"},
+				expected: "-Error syntax error, LIMIT cannot be used without the special ~ option\r\n",
+			},
+			{ // 6. Non-existent key.
+				command:  []string{"XTRIM", "XtrimKey2", "MAXLEN", "0"},
+				expected: ":0\r\n",
+			},
+		}...)
+
+		runStreamTests(t, conn, tests)
+	})
+
+	t.Run("Test_HandleXREAD", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		runStreamTests(t, conn, []streamTest{
+			{command: []string{"XADD", "XreadKey1", "1-1", "a", "1"}, expected: bulk("1-1")},
+			{command: []string{"XADD", "XreadKey1", "1-2", "b", "2"}, expected: bulk("1-2")},
+			{command: []string{"XADD", "XreadKey2", "2-1", "c", "3"}, expected: bulk("2-1")},
+			{ // 1. Read entries after the given IDs from multiple streams.
+				command: []string{"XREAD", "STREAMS", "XreadKey1", "XreadKey2", "1-1", "0"},
+				expected: array(
+					array(bulk("XreadKey1"), array(entry("1-2", "b", "2"))),
+					array(bulk("XreadKey2"), array(entry("2-1", "c", "3"))),
+				),
+			},
+			{ // 2. COUNT.
+				command:  []string{"XREAD", "COUNT", "1", "STREAMS", "XreadKey1", "0"},
+				expected: array(array(bulk("XreadKey1"), array(entry("1-1", "a", "1")))),
+			},
+			{ // 3. No entries returns nil.
+				command:  []string{"XREAD", "STREAMS", "XreadKey1", "$"},
+				expected: "*-1\r\n",
+			},
+			{ // 4. Blocking times out.
+				command:  []string{"XREAD", "BLOCK", "50", "STREAMS", "XreadKey1", "$"},
+				expected: "*-1\r\n",
+			},
+			{ // 5. Unbalanced streams.
+				command:  []string{"XREAD", "STREAMS", "XreadKey1", "XreadKey2", "0"},
+				expected: "-Error unbalanced list of streams: for each stream key an ID must be specified\r\n",
+			},
+		})
+
+		// 6. A blocked XREAD is woken up by XADD from another connection.
+		writer, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = writer.Close()
+		}()
+
+		if _, err = conn.Write(internal.EncodeCommand([]string{"XREAD", "BLOCK", "0", "STREAMS", "XreadKey3", "$"})); err != nil {
+			t.Error(err)
+			return
+		}
+		<-time.After(100 * time.Millisecond)
+		runStreamTests(t, writer, []streamTest{
+			{command: []string{"XADD", "XreadKey3", "5-1", "e", "5"}, expected: bulk("5-1")},
+		})
+
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := array(array(bulk("XreadKey3"), array(entry("5-1", "e", "5"))))
+		if string(buf[:n]) != expected {
+			t.Errorf("expected blocked XREAD response %q, got %q", expected, string(buf[:n]))
+		}
+	})
+
+	t.Run("Test_HandleXREADGROUP", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		runStreamTests(t, conn, []streamTest{
+			{ // 1. XGROUP CREATE requires the key to exist without MKSTREAM.
+				command: []string{"XGROUP", "CREATE", "XgroupKey1", "group1", "$"},
+				expected: "-Error The XGROUP subcommand requires the key to exist. " +
+					"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n",
+			},
+			{command: []string{"XGROUP", "CREATE", "XgroupKey1", "group1", "$", "MKSTREAM"}, expected: "+OK\r\n"},
+			{ // 2. Creating an existing group.
+				command:  []string{"XGROUP", "CREATE", "XgroupKey1", "group1", "0"},
+				expected: "-Error BUSYGROUP Consumer Group name already exists\r\n",
+			},
+			{command: []string{"XADD", "XgroupKey1", "1-1", "a", "1"}, expected: bulk("1-1")},
+			{command: []string{"XADD", "XgroupKey1", "1-2", "b", "2"}, expected: bulk("1-2")},
+			{command: []string{"XADD", "XgroupKey1", "1-3", "c", "3"}, expected: bulk("1-3")},
+			{ // 3. Read new entries.
+				command: []string{"XREADGROUP", "GROUP", "group1", "consumer1", "COUNT", "2", "STREAMS", "XgroupKey1", ">"},
+				expected: array(array(bulk("XgroupKey1"),
+					array(entry("1-1", "a", "1"), entry("1-2", "b", "2")))),
+			},
+			{
+				command:  []string{"XREADGROUP", "GROUP", "group1", "consumer2", "STREAMS", "XgroupKey1", ">"},
+				expected: array(array(bulk("XgroupKey1"), array(entry("1-3", "c", "3")))),
+			},
+			{ // 4. No more new entries.
+				command:  []string{"XREADGROUP", "GROUP", "group1", "consumer2", "STREAMS", "XgroupKey1", ">"},
+				expected: "*-1\r\n",
+			},
+			{ // 5. Read the history of the consumer.
+				command: []string{"XREADGROUP", "GROUP", "group1", "consumer1", "STREAMS", "XgroupKey1", "0"},
+				expected: array(array(bulk("XgroupKey1"),
+					array(entry("1-1", "a", "1"), entry("1-2", "b", "2")))),
+			},
+			{ // 6. Deleted entries are returned with nil fields in the history.
+				command:  []string{"XDEL", "XgroupKey1", "1-1"},
+				expected: ":1\r\n",
+			},
+			{
+				command: []string{"XREADGROUP", "GROUP", "group1", "consumer1", "STREAMS", "XgroupKey1", "0"},
+				expected: array(array(bulk("XgroupKey1"),
+					array(array(bulk("1-1"), "*-1\r\n"), entry("1-2", "b", "2")))),
+			},
+			{ // 7. XPENDING summary.
+				command: []string{"XPENDING", "XgroupKey1", "group1"},
+				expected: array(":3\r\n", bulk("1-1"), bulk("1-3"),
+					array(array(bulk("consumer1"), bulk("2")), array(bulk("consumer2"), bulk("1")))),
+			},
+			{ // 8. XPENDING extended form.
+				command: []string{"XPENDING", "XgroupKey1", "group1", "-", "+", "10", "consumer1"},
+				expected: array(
+					array(bulk("1-1"), bulk("consumer1"), ":0\r\n", ":1\r\n"),
+					array(bulk("1-2"), bulk("consumer1"), ":0\r\n", ":1\r\n"),
+				),
+			},
+			{ // 9. XACK.
+				command:  []string{"XACK", "XgroupKey1", "group1", "1-2", "1-5"},
+				expected: ":1\r\n",
+			},
+			{ // 10. XCLAIM transfers the entry and increments the delivery count.
+				command:  []string{"XCLAIM", "XgroupKey1", "group1", "consumer1", "0", "1-3"},
+				expected: array(entry("1-3", "c", "3")),
+			},
+			{
+				command:  []string{"XPENDING", "XgroupKey1", "group1", "(1-1", "+", "10"},
+				expected: array(array(bulk("1-3"), bulk("consumer1"), ":0\r\n", ":2\r\n")),
+			},
+			{ // 11. XAUTOCLAIM removes deleted entries from the PEL.
+				command: []string{"XAUTOCLAIM", "XgroupKey1", "group1", "consumer2", "0", "0-0"},
+				expected: array(bulk("0-0"), array(entry("1-3", "c", "3")),
+					array(bulk("1-1"))),
+			},
+			{
+				command:  []string{"XPENDING", "XgroupKey1", "group1"},
+				expected: array(":1\r\n", bulk("1-3"), bulk("1-3"), array(array(bulk("consumer2"), bulk("1")))),
+			},
+			{ // 12. XGROUP CREATECONSUMER and DELCONSUMER.
+				command:  []string{"XGROUP", "CREATECONSUMER", "XgroupKey1", "group1", "consumer3"},
+				expected: ":1\r\n",
+			},
+			{
+				command:  []string{"XGROUP", "CREATECONSUMER", "XgroupKey1", "group1", "consumer3"},
+				expected: ":0\r\n",
+			},
+			{
+				command:  []string{"XGROUP", "DELCONSUMER", "XgroupKey1", "group1", "consumer2"},
+				expected: ":1\r\n",
+			},
+			{ // 13. XGROUP SETID re-delivers entries.
+				command:  []string{"XGROUP", "SETID", "XgroupKey1", "group1", "1-2"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"XREADGROUP", "GROUP", "group1", "consumer3", "NOACK", "STREAMS", "XgroupKey1", ">"},
+				expected: array(array(bulk("XgroupKey1"), array(entry("1-3", "c", "3")))),
+			},
+			{
+				command:  []string{"XPENDING", "XgroupKey1", "group1"},
+				expected: array(":0\r\n", "$-1\r\n", "$-1\r\n", "*-1\r\n"),
+			},
+			{ // 14. XGROUP DESTROY.
+				command:  []string{"XGROUP", "DESTROY", "XgroupKey1", "group1"},
+				expected: ":1\r\n",
+			},
+			{
+				command: []string{"XREADGROUP", "GROUP", "group1", "consumer1", "STREAMS", "XgroupKey1", ">"},
+				expected: "-Error NOGROUP No such key 'XgroupKey1' or consumer group 'group1' " +
+					"in XREADGROUP with GROUP option\r\n",
+			},
+		})
+	})
+
+	t.Run("Test_HandleRESP3", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		buf := make([]byte, 1024)
+		if _, err = conn.Write(internal.EncodeCommand([]string{"HELLO", "3"})); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err = conn.Read(buf); err != nil {
+			t.Error(err)
+			return
+		}
+
+		runStreamTests(t, conn, []streamTest{
+			{command: []string{"XADD", "Resp3StreamKey1", "1-1", "a", "1"}, expected: bulk("1-1")},
+			{
+				command:  []string{"XREAD", "STREAMS", "Resp3StreamKey1", "0"},
+				expected: "%1\r\n" + bulk("Resp3StreamKey1") + array(entry("1-1", "a", "1")),
+			},
+			{
+				command:  []string{"XREAD", "STREAMS", "Resp3StreamKey1", "$"},
+				expected: "_\r\n",
+			},
+		})
+	})
+}
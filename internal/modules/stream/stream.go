@@ -0,0 +1,893 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNodeEntries is the maximum number of entries held by a single node of the stream.
+// Approximate trimming (~) only ever removes whole nodes.
+const maxNodeEntries = 100
+
+// ID is the ID of a stream entry. It's made up of a millisecond timestamp and a sequence number.
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	MinID = ID{Ms: 0, Seq: 0}
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+func (id ID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+func (id ID) Compare(other ID) int {
+	if c := cmp.Compare(id.Ms, other.Ms); c != 0 {
+		return c
+	}
+	return cmp.Compare(id.Seq, other.Seq)
+}
+
+// Next returns the smallest ID that is greater than id.
+func (id ID) Next() ID {
+	if id.Seq == math.MaxUint64 {
+		return ID{Ms: id.Ms + 1, Seq: 0}
+	}
+	return ID{Ms: id.Ms, Seq: id.Seq + 1}
+}
+
+// Prev returns the greatest ID that is smaller than id.
+func (id ID) Prev() ID {
+	if id.Seq == 0 {
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}
+	}
+	return ID{Ms: id.Ms, Seq: id.Seq - 1}
+}
+
+// ParseID parses an ID in the form "<ms>-<seq>". If the sequence number is omitted,
+// it defaults to defaultSeq.
+func ParseID(s string, defaultSeq uint64) (ID, error) {
+	ms, seq, found := strings.Cut(s, "-")
+	m, err := strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return ID{}, errors.New("invalid stream ID specified as stream command argument")
+	}
+	if !found {
+		return ID{Ms: m, Seq: defaultSeq}, nil
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return ID{}, errors.New("invalid stream ID specified as stream command argument")
+	}
+	return ID{Ms: m, Seq: n}, nil
+}
+
+// Entry is a single stream entry. Fields holds the flattened field-value pairs in insertion order.
+// Fields is nil for entries that are referenced by a consumer group but have been deleted from the stream.
+type Entry struct {
+	ID     ID
+	Fields []string
+}
+
+// PendingEntry is an entry that has been delivered to a consumer but not acknowledged yet.
+type PendingEntry struct {
+	ID            ID
+	Consumer      string
+	DeliveryTime  time.Time
+	DeliveryCount int
+}
+
+// Consumer is a consumer within a consumer group.
+type Consumer struct {
+	Name     string
+	SeenTime time.Time
+	pending  map[ID]*PendingEntry
+}
+
+// Group is a consumer group.
+type Group struct {
+	Name            string
+	LastDeliveredID ID
+	EntriesRead     int64
+	pending         map[ID]*PendingEntry // The pending entries list (PEL) of the group.
+	consumers       map[string]*Consumer
+}
+
+// node holds a contiguous run of entries in ID order, similar to a listpack in a radix tree leaf.
+type node struct {
+	entries []Entry
+}
+
+// Stream is an append-only log of entries ordered by ID.
+// The entries are stored in fixed size nodes so that appends and trims from the head do not
+// need to move the whole stream in memory.
+type Stream struct {
+	mutex        sync.RWMutex
+	nodes        []*node
+	length       int
+	lastID       ID
+	entriesAdded uint64
+	groups       map[string]*Group
+}
+
+func NewStream() *Stream {
+	return &Stream{
+		mutex:        sync.RWMutex{},
+		nodes:        make([]*node, 0),
+		length:       0,
+		lastID:       MinID,
+		entriesAdded: 0,
+		groups:       make(map[string]*Group),
+	}
+}
+
+func (stream *Stream) Len() int {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	return stream.length
+}
+
+func (stream *Stream) LastID() ID {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	return stream.lastID
+}
+
+// NextID generates the ID of the next entry for the "*" ID.
+// The ID uses the current time unless the last ID is in the future, in which case the
+// sequence number of the last ID is incremented.
+func (stream *Stream) NextID(now time.Time) ID {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	ms := uint64(now.UnixMilli())
+	if ms > stream.lastID.Ms {
+		return ID{Ms: ms, Seq: 0}
+	}
+	return stream.lastID.Next()
+}
+
+// NextSeq generates the ID of the next entry for the "<ms>-*" ID.
+func (stream *Stream) NextSeq(ms uint64) (ID, error) {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	switch {
+	case ms < stream.lastID.Ms:
+		return ID{}, errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+	case ms == stream.lastID.Ms:
+		if stream.lastID.Seq == math.MaxUint64 {
+			return ID{}, errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+		return stream.lastID.Next(), nil
+	case ms == 0:
+		return ID{Ms: 0, Seq: 1}, nil
+	default:
+		return ID{Ms: ms, Seq: 0}, nil
+	}
+}
+
+// Add appends a new entry to the stream. The ID must be greater than the last ID of the stream.
+func (stream *Stream) Add(id ID, fields []string) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	if id.Compare(MinID) == 0 {
+		return errors.New("The ID specified in XADD must be greater than 0-0")
+	}
+	if id.Compare(stream.lastID) <= 0 {
+		return errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+	}
+
+	if len(stream.nodes) == 0 || len(stream.nodes[len(stream.nodes)-1].entries) >= maxNodeEntries {
+		stream.nodes = append(stream.nodes, &node{entries: make([]Entry, 0, maxNodeEntries)})
+	}
+	last := stream.nodes[len(stream.nodes)-1]
+	last.entries = append(last.entries, Entry{ID: id, Fields: slices.Clone(fields)})
+
+	stream.length += 1
+	stream.lastID = id
+	stream.entriesAdded += 1
+	return nil
+}
+
+// SetLastID sets the last ID of the stream. Used to restore the last ID of an empty stream.
+func (stream *Stream) SetLastID(id ID) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if id.Compare(stream.lastID) > 0 {
+		stream.lastID = id
+	}
+}
+
+// Range returns at most count entries with IDs between start and end inclusive.
+// If count is 0, all the entries in the range are returned. If rev is true, the entries
+// are returned from the greatest ID to the smallest.
+func (stream *Stream) Range(start, end ID, count int, rev bool) []Entry {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	return stream.rangeUnlocked(start, end, count, rev)
+}
+
+func (stream *Stream) rangeUnlocked(start, end ID, count int, rev bool) []Entry {
+	res := make([]Entry, 0)
+	if start.Compare(end) > 0 {
+		return res
+	}
+
+	if !rev {
+		for _, n := range stream.nodes {
+			if n.entries[len(n.entries)-1].ID.Compare(start) < 0 {
+				continue
+			}
+			for _, entry := range n.entries {
+				if entry.ID.Compare(start) < 0 {
+					continue
+				}
+				if entry.ID.Compare(end) > 0 || (count > 0 && len(res) >= count) {
+					return res
+				}
+				res = append(res, entry)
+			}
+		}
+		return res
+	}
+
+	for i := len(stream.nodes) - 1; i >= 0; i-- {
+		n := stream.nodes[i]
+		if n.entries[0].ID.Compare(end) > 0 {
+			continue
+		}
+		for j := len(n.entries) - 1; j >= 0; j-- {
+			entry := n.entries[j]
+			if entry.ID.Compare(end) > 0 {
+				continue
+			}
+			if entry.ID.Compare(start) < 0 || (count > 0 && len(res) >= count) {
+				return res
+			}
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+func (stream *Stream) getUnlocked(id ID) (Entry, bool) {
+	idx, found := slices.BinarySearchFunc(stream.nodes, id, func(n *node, id ID) int {
+		return n.entries[0].ID.Compare(id)
+	})
+	if !found {
+		idx -= 1
+	}
+	if idx < 0 {
+		return Entry{}, false
+	}
+	n := stream.nodes[idx]
+	i, found := slices.BinarySearchFunc(n.entries, id, func(e Entry, id ID) int {
+		return e.ID.Compare(id)
+	})
+	if !found {
+		return Entry{}, false
+	}
+	return n.entries[i], true
+}
+
+// Delete removes the entries with the given IDs from the stream and returns the number of entries removed.
+func (stream *Stream) Delete(ids []ID) int {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		for i, n := range stream.nodes {
+			if n.entries[0].ID.Compare(id) > 0 || n.entries[len(n.entries)-1].ID.Compare(id) < 0 {
+				continue
+			}
+			j, found := slices.BinarySearchFunc(n.entries, id, func(e Entry, id ID) int {
+				return e.ID.Compare(id)
+			})
+			if !found {
+				break
+			}
+			n.entries = slices.Delete(n.entries, j, j+1)
+			if len(n.entries) == 0 {
+				stream.nodes = slices.Delete(stream.nodes, i, i+1)
+			}
+			stream.length -= 1
+			count += 1
+			break
+		}
+	}
+	return count
+}
+
+// TrimMaxLen removes the oldest entries until the stream has at most maxLen entries.
+// When approx is true, only whole nodes are removed, so the stream may keep a few more entries than maxLen.
+// limit caps the number of entries removed when approx is true. A limit of 0 means no limit.
+// Returns the number of entries removed.
+func (stream *Stream) TrimMaxLen(maxLen int, approx bool, limit int) int {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.trimUnlocked(func(entry Entry, remaining int) bool {
+		return remaining > maxLen
+	}, approx, limit)
+}
+
+// TrimMinID removes the entries with IDs lower than minID.
+// approx and limit behave the same as in TrimMaxLen.
+// Returns the number of entries removed.
+func (stream *Stream) TrimMinID(minID ID, approx bool, limit int) int {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.trimUnlocked(func(entry Entry, remaining int) bool {
+		return entry.ID.Compare(minID) < 0
+	}, approx, limit)
+}
+
+// trimUnlocked removes entries from the head of the stream while shouldTrim returns true.
+func (stream *Stream) trimUnlocked(shouldTrim func(entry Entry, remaining int) bool, approx bool, limit int) int {
+	removed := 0
+	for len(stream.nodes) > 0 {
+		n := stream.nodes[0]
+		if approx {
+			// Only remove the node if every entry in it should be trimmed.
+			last := n.entries[len(n.entries)-1]
+			if !shouldTrim(last, stream.length-len(n.entries)+1) {
+				break
+			}
+			if limit > 0 && removed+len(n.entries) > limit {
+				break
+			}
+			stream.nodes = stream.nodes[1:]
+			stream.length -= len(n.entries)
+			removed += len(n.entries)
+			continue
+		}
+		for len(n.entries) > 0 && shouldTrim(n.entries[0], stream.length) {
+			n.entries = n.entries[1:]
+			stream.length -= 1
+			removed += 1
+		}
+		if len(n.entries) > 0 {
+			break
+		}
+		stream.nodes = stream.nodes[1:]
+	}
+	return removed
+}
+
+// CreateGroup creates a new consumer group that will deliver entries with IDs greater than id.
+func (stream *Stream) CreateGroup(name string, id ID, entriesRead int64) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if _, ok := stream.groups[name]; ok {
+		return errors.New("BUSYGROUP Consumer Group name already exists")
+	}
+	stream.groups[name] = &Group{
+		Name:            name,
+		LastDeliveredID: id,
+		EntriesRead:     entriesRead,
+		pending:         make(map[ID]*PendingEntry),
+		consumers:       make(map[string]*Consumer),
+	}
+	return nil
+}
+
+// SetGroupID sets the last delivered ID of the group. Returns false if the group does not exist.
+func (stream *Stream) SetGroupID(name string, id ID, entriesRead int64) bool {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	group, ok := stream.groups[name]
+	if !ok {
+		return false
+	}
+	group.LastDeliveredID = id
+	group.EntriesRead = entriesRead
+	return true
+}
+
+// DestroyGroup removes the consumer group. Returns false if the group does not exist.
+func (stream *Stream) DestroyGroup(name string) bool {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if _, ok := stream.groups[name]; !ok {
+		return false
+	}
+	delete(stream.groups, name)
+	return true
+}
+
+func (stream *Stream) HasGroup(name string) bool {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	_, ok := stream.groups[name]
+	return ok
+}
+
+// CreateConsumer creates a consumer in the group. Returns false if the consumer already exists.
+func (stream *Stream) CreateConsumer(group string, consumer string, now time.Time) (bool, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return false, errNoGroup
+	}
+	if _, ok = g.consumers[consumer]; ok {
+		return false, nil
+	}
+	g.getConsumer(consumer, now)
+	return true, nil
+}
+
+// DeleteConsumer removes the consumer from the group along with its pending entries.
+// Returns the number of pending entries the consumer had.
+func (stream *Stream) DeleteConsumer(group string, consumer string) (int, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return 0, errNoGroup
+	}
+	c, ok := g.consumers[consumer]
+	if !ok {
+		return 0, nil
+	}
+	for id := range c.pending {
+		delete(g.pending, id)
+	}
+	delete(g.consumers, consumer)
+	return len(c.pending), nil
+}
+
+var errNoGroup = errors.New("no such consumer group")
+
+func (group *Group) getConsumer(name string, now time.Time) *Consumer {
+	c, ok := group.consumers[name]
+	if !ok {
+		c = &Consumer{Name: name, SeenTime: now, pending: make(map[ID]*PendingEntry)}
+		group.consumers[name] = c
+	}
+	c.SeenTime = now
+	return c
+}
+
+// assign adds the entry to the PEL of the group and the consumer, moving it from its previous consumer if needed.
+func (group *Group) assign(id ID, consumer *Consumer, deliveryTime time.Time, deliveryCount int) *PendingEntry {
+	pending, ok := group.pending[id]
+	if ok {
+		if previous, ok := group.consumers[pending.Consumer]; ok {
+			delete(previous.pending, id)
+		}
+	} else {
+		pending = &PendingEntry{ID: id}
+		group.pending[id] = pending
+	}
+	pending.Consumer = consumer.Name
+	pending.DeliveryTime = deliveryTime
+	pending.DeliveryCount = deliveryCount
+	consumer.pending[id] = pending
+	return pending
+}
+
+func (group *Group) unassign(id ID) {
+	pending, ok := group.pending[id]
+	if !ok {
+		return
+	}
+	if c, ok := group.consumers[pending.Consumer]; ok {
+		delete(c.pending, id)
+	}
+	delete(group.pending, id)
+}
+
+// sortedPending returns the pending entries sorted by ID.
+func sortedPending(pending map[ID]*PendingEntry) []*PendingEntry {
+	res := make([]*PendingEntry, 0, len(pending))
+	for _, p := range pending {
+		res = append(res, p)
+	}
+	slices.SortFunc(res, func(a, b *PendingEntry) int {
+		return a.ID.Compare(b.ID)
+	})
+	return res
+}
+
+// ReadGroupNew delivers at most count entries that have not been delivered to any consumer of the group yet.
+// Unless noAck is true, the entries are added to the pending entries list of the consumer.
+func (stream *Stream) ReadGroupNew(group string, consumer string, count int, noAck bool, now time.Time) ([]Entry, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, errNoGroup
+	}
+	c := g.getConsumer(consumer, now)
+	entries := stream.rangeUnlocked(g.LastDeliveredID.Next(), MaxID, count, false)
+	for _, entry := range entries {
+		g.LastDeliveredID = entry.ID
+		g.EntriesRead += 1
+		if !noAck {
+			g.assign(entry.ID, c, now, 1)
+		}
+	}
+	return entries, nil
+}
+
+// HasUndelivered returns true if the stream has entries that have not been delivered to any consumer of the group.
+func (stream *Stream) HasUndelivered(group string) bool {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return false
+	}
+	return len(stream.rangeUnlocked(g.LastDeliveredID.Next(), MaxID, 1, false)) > 0
+}
+
+// ReadGroupHistory returns at most count entries from the pending entries list of the consumer
+// with IDs greater than after. Entries that have been deleted from the stream are returned with nil fields.
+func (stream *Stream) ReadGroupHistory(group string, consumer string, after ID, count int, now time.Time) ([]Entry, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, errNoGroup
+	}
+	c := g.getConsumer(consumer, now)
+	res := make([]Entry, 0)
+	for _, pending := range sortedPending(c.pending) {
+		if pending.ID.Compare(after) <= 0 {
+			continue
+		}
+		if count > 0 && len(res) >= count {
+			break
+		}
+		entry, ok := stream.getUnlocked(pending.ID)
+		if !ok {
+			entry = Entry{ID: pending.ID, Fields: nil}
+		}
+		res = append(res, entry)
+	}
+	return res, nil
+}
+
+// Ack removes the IDs from the pending entries list of the group. Returns the number of IDs acknowledged.
+func (stream *Stream) Ack(group string, ids []ID) (int, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return 0, errNoGroup
+	}
+	count := 0
+	for _, id := range ids {
+		if _, ok = g.pending[id]; ok {
+			g.unassign(id)
+			count += 1
+		}
+	}
+	return count, nil
+}
+
+// PendingSummary returns the pending entries of the group sorted by ID and the number of
+// pending entries of each consumer.
+func (stream *Stream) PendingSummary(group string) ([]PendingEntry, map[string]int, error) {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, nil, errNoGroup
+	}
+	entries := make([]PendingEntry, 0, len(g.pending))
+	for _, p := range sortedPending(g.pending) {
+		entries = append(entries, *p)
+	}
+	consumers := make(map[string]int)
+	for name, c := range g.consumers {
+		if len(c.pending) > 0 {
+			consumers[name] = len(c.pending)
+		}
+	}
+	return entries, consumers, nil
+}
+
+// PendingRange returns at most count pending entries of the group with IDs between start and end inclusive.
+// If consumer is not empty, only the entries of that consumer are returned.
+// Only the entries that have been idle for at least minIdle are returned.
+func (stream *Stream) PendingRange(group string, start, end ID, count int, consumer string, minIdle time.Duration, now time.Time) ([]PendingEntry, error) {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, errNoGroup
+	}
+	pending := g.pending
+	if consumer != "" {
+		c, ok := g.consumers[consumer]
+		if !ok {
+			return []PendingEntry{}, nil
+		}
+		pending = c.pending
+	}
+	res := make([]PendingEntry, 0)
+	for _, p := range sortedPending(pending) {
+		if p.ID.Compare(start) < 0 || p.ID.Compare(end) > 0 {
+			continue
+		}
+		if now.Sub(p.DeliveryTime) < minIdle {
+			continue
+		}
+		if len(res) >= count {
+			break
+		}
+		res = append(res, *p)
+	}
+	return res, nil
+}
+
+// ClaimOptions modifies the behaviour of Claim.
+type ClaimOptions struct {
+	DeliveryTime time.Time // The delivery time to set on the claimed entries.
+	RetryCount   int       // The delivery count to set on the claimed entries. Ignored if negative.
+	Force        bool      // Create the pending entry if it's not in the PEL, as long as the entry exists in the stream.
+	JustID       bool      // Do not increment the delivery count.
+	LastID       ID        // Update the last delivered ID of the group if it's greater.
+	SetLastID    bool
+	MinIdle      time.Duration // Only claim the entries that have been idle for at least MinIdle.
+	Now          time.Time     // The current time, used to compute the idle time of the entries.
+}
+
+// Claim changes the ownership of the pending entries to the consumer if they have been idle for at least
+// options.MinIdle. Entries that no longer exist in the stream are removed from the PEL and skipped.
+// Returns the claimed entries.
+func (stream *Stream) Claim(group string, consumer string, ids []ID, options ClaimOptions) ([]Entry, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return nil, errNoGroup
+	}
+
+	if options.SetLastID && options.LastID.Compare(g.LastDeliveredID) > 0 {
+		g.LastDeliveredID = options.LastID
+	}
+
+	c := g.getConsumer(consumer, options.Now)
+	res := make([]Entry, 0)
+	for _, id := range ids {
+		entry, exists := stream.getUnlocked(id)
+		pending, ok := g.pending[id]
+		if !ok {
+			if !options.Force || !exists {
+				continue
+			}
+			pending = g.assign(id, c, options.DeliveryTime, 0)
+		} else if options.Now.Sub(pending.DeliveryTime) < options.MinIdle {
+			continue
+		}
+		if !exists {
+			// The entry was deleted from the stream, so it can't be claimed.
+			g.unassign(id)
+			continue
+		}
+		deliveryCount := pending.DeliveryCount
+		if !options.JustID {
+			deliveryCount += 1
+		}
+		if options.RetryCount >= 0 {
+			deliveryCount = options.RetryCount
+		}
+		g.assign(id, c, options.DeliveryTime, deliveryCount)
+		res = append(res, entry)
+	}
+	return res, nil
+}
+
+// AutoClaim scans the PEL of the group from start and claims at most count entries that have been idle for
+// at least minIdle. Returns the ID to use as start in the next call (0-0 when the whole PEL has been scanned),
+// the claimed entries and the IDs of the scanned entries that no longer exist in the stream.
+func (stream *Stream) AutoClaim(group string, consumer string, minIdle time.Duration, start ID, count int, justID bool, now time.Time) (ID, []Entry, []ID, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	g, ok := stream.groups[group]
+	if !ok {
+		return ID{}, nil, nil, errNoGroup
+	}
+	c := g.getConsumer(consumer, now)
+
+	claimed := make([]Entry, 0)
+	deleted := make([]ID, 0)
+	next := MinID
+
+	// Limit the number of PEL entries scanned so a single call can't scan the whole PEL.
+	attempts := count * 10
+	for _, pending := range sortedPending(g.pending) {
+		if pending.ID.Compare(start) < 0 {
+			continue
+		}
+		if attempts == 0 || len(claimed) >= count {
+			next = pending.ID
+			break
+		}
+		attempts -= 1
+		entry, exists := stream.getUnlocked(pending.ID)
+		if !exists {
+			deleted = append(deleted, pending.ID)
+			g.unassign(pending.ID)
+			continue
+		}
+		if now.Sub(pending.DeliveryTime) < minIdle {
+			continue
+		}
+		deliveryCount := pending.DeliveryCount
+		if !justID {
+			deliveryCount += 1
+		}
+		g.assign(pending.ID, c, now, deliveryCount)
+		claimed = append(claimed, entry)
+	}
+
+	return next, claimed, deleted, nil
+}
+
+// streamJSON is the representation of a stream used when it's marshalled to JSON.
+type streamJSON struct {
+	Entries      []entryJSON `json:"Entries"`
+	LastID       string      `json:"LastID"`
+	EntriesAdded uint64      `json:"EntriesAdded"`
+	Groups       []groupJSON `json:"Groups"`
+}
+
+type entryJSON struct {
+	ID     string   `json:"ID"`
+	Fields []string `json:"Fields"`
+}
+
+type groupJSON struct {
+	Name            string        `json:"Name"`
+	LastDeliveredID string        `json:"LastDeliveredID"`
+	EntriesRead     int64         `json:"EntriesRead"`
+	Consumers       []string      `json:"Consumers"`
+	Pending         []pendingJSON `json:"Pending"`
+}
+
+type pendingJSON struct {
+	ID            string `json:"ID"`
+	Consumer      string `json:"Consumer"`
+	DeliveryTime  int64  `json:"DeliveryTime"`
+	DeliveryCount int    `json:"DeliveryCount"`
+}
+
+// Clone returns a deep copy of the stream, including its consumer groups and their pending entries.
+func (stream *Stream) Clone() (*Stream, error) {
+	b, err := stream.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	clone := NewStream()
+	if err = clone.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// MarshalJSON encodes the entries, consumer groups and pending entries of the stream.
+func (stream *Stream) MarshalJSON() ([]byte, error) {
+	stream.mutex.RLock()
+	defer stream.mutex.RUnlock()
+
+	s := streamJSON{
+		Entries:      make([]entryJSON, 0, stream.length),
+		LastID:       stream.lastID.String(),
+		EntriesAdded: stream.entriesAdded,
+		Groups:       make([]groupJSON, 0, len(stream.groups)),
+	}
+	for _, entry := range stream.rangeUnlocked(MinID, MaxID, 0, false) {
+		s.Entries = append(s.Entries, entryJSON{ID: entry.ID.String(), Fields: entry.Fields})
+	}
+	for _, g := range stream.groups {
+		group := groupJSON{
+			Name:            g.Name,
+			LastDeliveredID: g.LastDeliveredID.String(),
+			EntriesRead:     g.EntriesRead,
+			Consumers:       make([]string, 0, len(g.consumers)),
+			Pending:         make([]pendingJSON, 0, len(g.pending)),
+		}
+		for name := range g.consumers {
+			group.Consumers = append(group.Consumers, name)
+		}
+		slices.Sort(group.Consumers)
+		for _, p := range sortedPending(g.pending) {
+			group.Pending = append(group.Pending, pendingJSON{
+				ID:            p.ID.String(),
+				Consumer:      p.Consumer,
+				DeliveryTime:  p.DeliveryTime.UnixMilli(),
+				DeliveryCount: p.DeliveryCount,
+			})
+		}
+		s.Groups = append(s.Groups, group)
+	}
+	// Sort the groups so that the same stream is always encoded the same way.
+	slices.SortFunc(s.Groups, func(a, b groupJSON) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON restores a stream encoded with MarshalJSON.
+func (stream *Stream) UnmarshalJSON(b []byte) error {
+	var s streamJSON
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	restored := NewStream()
+	for _, e := range s.Entries {
+		id, err := ParseID(e.ID, 0)
+		if err != nil {
+			return err
+		}
+		if err = restored.Add(id, e.Fields); err != nil {
+			return err
+		}
+	}
+	lastID, err := ParseID(s.LastID, 0)
+	if err != nil {
+		return err
+	}
+	restored.SetLastID(lastID)
+	restored.entriesAdded = s.EntriesAdded
+
+	for _, g := range s.Groups {
+		id, err := ParseID(g.LastDeliveredID, 0)
+		if err != nil {
+			return err
+		}
+		if err = restored.CreateGroup(g.Name, id, g.EntriesRead); err != nil {
+			return err
+		}
+		group := restored.groups[g.Name]
+		for _, name := range g.Consumers {
+			group.getConsumer(name, time.Time{})
+		}
+		for _, p := range g.Pending {
+			pid, err := ParseID(p.ID, 0)
+			if err != nil {
+				return err
+			}
+			group.assign(pid, group.getConsumer(p.Consumer, time.Time{}), time.UnixMilli(p.DeliveryTime), p.DeliveryCount)
+		}
+	}
+
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	stream.nodes = restored.nodes
+	stream.length = restored.length
+	stream.lastID = restored.lastID
+	stream.entriesAdded = restored.entriesAdded
+	stream.groups = restored.groups
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package str
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// maxBitOffset is the greatest bit offset that can be set, which limits bitmaps to 512MB.
+const maxBitOffset = math.MaxUint32
+
+// getBitmap returns the string at the key as a byte slice. Bits are addressed from the most significant
+// bit of the first byte. Integer and float values are converted to their string representation.
+func getBitmap(params internal.HandlerFuncParams, key string) ([]byte, bool, error) {
+	if !params.KeysExist([]string{key})[key] {
+		return []byte{}, false, nil
+	}
+	switch value := params.GetValues(params.Context, []string{key})[key].(type) {
+	case string:
+		return []byte(value), true, nil
+	case int:
+		return []byte(strconv.Itoa(value)), true, nil
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'f', -1, 64)), true, nil
+	default:
+		return nil, true, fmt.Errorf("value at key %s is not a string", key)
+	}
+}
+
+func parseBitOffset(s string) (uint64, error) {
+	offset, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || offset > maxBitOffset {
+		return 0, errors.New("bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// growBitmap extends the bitmap with zero bytes so that it holds at least the given number of bits.
+func growBitmap(bitmap []byte, nbits uint64) []byte {
+	size := (nbits + 7) / 8
+	if uint64(len(bitmap)) >= size {
+		return bitmap
+	}
+	return append(bitmap, make([]byte, size-uint64(len(bitmap)))...)
+}
+
+func getBit(bitmap []byte, offset uint64) int {
+	if offset/8 >= uint64(len(bitmap)) {
+		return 0
+	}
+	return int(bitmap[offset/8]>>(7-offset%8)) & 1
+}
+
+func setBit(bitmap []byte, offset uint64, bit int) {
+	if bit == 1 {
+		bitmap[offset/8] |= 1 << (7 - offset%8)
+		return
+	}
+	bitmap[offset/8] &^= 1 << (7 - offset%8)
+}
+
+// normalizeRange converts a range with negative indices relative to length into an absolute range.
+// Returns false if the range is empty.
+func normalizeRange(start, end, length int64) (int64, int64, bool) {
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if end < 0 {
+		end = length + end
+	}
+	end = min(end, length-1)
+	if length == 0 || start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// bitCount counts the set bits between the start and end bit offsets inclusive.
+func bitCount(bitmap []byte, start, end int64) int {
+	count := 0
+	for start <= end && start%8 != 0 {
+		count += getBit(bitmap, uint64(start))
+		start += 1
+	}
+	for ; start+7 <= end; start += 8 {
+		count += bits.OnesCount8(bitmap[start/8])
+	}
+	for ; start <= end; start++ {
+		count += getBit(bitmap, uint64(start))
+	}
+	return count
+}
+
+// bitPos returns the offset of the first bit equal to bit between the start and end bit offsets inclusive.
+// Returns -1 if no bit is found.
+func bitPos(bitmap []byte, bit int, start, end int64) int64 {
+	skip := byte(0xff)
+	if bit == 1 {
+		skip = 0
+	}
+	for start <= end {
+		// Skip over whole bytes that can't contain the bit.
+		if start%8 == 0 && start+7 <= end && bitmap[start/8] == skip {
+			start += 8
+			continue
+		}
+		if getBit(bitmap, uint64(start)) == bit {
+			return start
+		}
+		start += 1
+	}
+	return -1
+}
+
+// bitfieldType is the encoding of an integer in a BITFIELD command, e.g. i8 or u16.
+type bitfieldType struct {
+	signed bool
+	bits   uint
+}
+
+func parseBitfieldType(s string) (bitfieldType, error) {
+	err := errors.New("invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is")
+	if len(s) < 2 {
+		return bitfieldType{}, err
+	}
+	t := bitfieldType{signed: s[0] == 'i' || s[0] == 'I'}
+	if !t.signed && s[0] != 'u' && s[0] != 'U' {
+		return bitfieldType{}, err
+	}
+	n, e := strconv.ParseUint(s[1:], 10, 8)
+	if e != nil || n < 1 || (t.signed && n > 64) || (!t.signed && n > 63) {
+		return bitfieldType{}, err
+	}
+	t.bits = uint(n)
+	return t, nil
+}
+
+// parseBitfieldOffset parses the offset of a BITFIELD operation.
+// An offset prefixed with "#" is multiplied by the width of the type.
+func parseBitfieldOffset(s string, t bitfieldType) (uint64, error) {
+	multiply := strings.HasPrefix(s, "#")
+	offset, err := parseBitOffset(strings.TrimPrefix(s, "#"))
+	if err != nil {
+		return 0, err
+	}
+	if multiply {
+		offset *= uint64(t.bits)
+	}
+	if offset+uint64(t.bits)-1 > maxBitOffset {
+		return 0, errors.New("bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+func (t bitfieldType) get(bitmap []byte, offset uint64) int64 {
+	var value uint64
+	for i := uint64(0); i < uint64(t.bits); i++ {
+		value = value<<1 | uint64(getBit(bitmap, offset+i))
+	}
+	if t.signed && t.bits < 64 && value&(1<<(t.bits-1)) != 0 {
+		// Sign extend the value.
+		value |= math.MaxUint64 << t.bits
+	}
+	return int64(value)
+}
+
+// set writes the value at the offset. The bitmap must be large enough to hold the value.
+func (t bitfieldType) set(bitmap []byte, offset uint64, value int64) {
+	for i := uint64(0); i < uint64(t.bits); i++ {
+		setBit(bitmap, offset+i, int(uint64(value)>>(uint64(t.bits)-1-i))&1)
+	}
+}
+
+// add returns value + increment according to the overflow behaviour, which is one of "wrap", "sat" or "fail".
+// Returns false if the result overflows and the overflow behaviour is "fail".
+func (t bitfieldType) add(value, increment int64, overflow string) (int64, bool) {
+	var lower, upper big.Int
+	if t.signed {
+		upper.Lsh(big.NewInt(1), t.bits-1)
+		lower.Neg(&upper)
+		upper.Sub(&upper, big.NewInt(1))
+	} else {
+		upper.Lsh(big.NewInt(1), t.bits)
+		upper.Sub(&upper, big.NewInt(1))
+	}
+
+	sum := new(big.Int).Add(big.NewInt(value), big.NewInt(increment))
+	if sum.Cmp(&lower) >= 0 && sum.Cmp(&upper) <= 0 {
+		return sum.Int64(), true
+	}
+
+	switch overflow {
+	case "fail":
+		return 0, false
+	case "sat":
+		if sum.Sign() < 0 {
+			return lower.Int64(), true
+		}
+		return upper.Int64(), true
+	default:
+		// Wrap around by keeping the lower bits of the result.
+		mask := new(big.Int).Lsh(big.NewInt(1), t.bits)
+		mask.Sub(mask, big.NewInt(1))
+		sum.And(sum, mask)
+		if t.signed && sum.Bit(int(t.bits-1)) == 1 {
+			sum.Sub(sum, new(big.Int).Lsh(big.NewInt(1), t.bits))
+		}
+		if sum.IsInt64() {
+			return sum.Int64(), true
+		}
+		return int64(sum.Uint64()), true
+	}
+}
@@ -19,6 +19,9 @@ import (
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/constants"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 func handleSetRange(params internal.HandlerFuncParams) ([]byte, error) {
@@ -166,6 +169,335 @@ func handleSubStr(params internal.HandlerFuncParams) ([]byte, error) {
 	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(str), str)), nil
 }
 
+func handleSetBit(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := setBitKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+
+	offset, err := parseBitOffset(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+
+	bit, err := strconv.Atoi(params.Command[3])
+	if err != nil || (bit != 0 && bit != 1) {
+		return nil, errors.New("bit is not an integer or out of range")
+	}
+
+	bitmap, _, err := getBitmap(params, key)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap = growBitmap(bitmap, offset+1)
+	original := getBit(bitmap, offset)
+	setBit(bitmap, offset, bit)
+
+	if err = params.SetValues(params.Context, map[string]interface{}{key: string(bitmap)}); err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", original)), nil
+}
+
+func handleGetBit(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := getBitKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := parseBitOffset(params.Command[2])
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap, _, err := getBitmap(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", getBit(bitmap, offset))), nil
+}
+
+// parseBitRange parses the optional start, end and BYTE | BIT arguments of BITCOUNT and BITPOS.
+// Returns the range as absolute bit offsets, and false if the range is empty.
+func parseBitRange(args []string, bitmap []byte) (int64, int64, bool, error) {
+	start, end := int64(0), int64(-1)
+	var err error
+
+	if len(args) > 0 {
+		if start, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+			return 0, 0, false, errors.New("value is not an integer or out of range")
+		}
+	}
+	if len(args) > 1 {
+		if end, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return 0, 0, false, errors.New("value is not an integer or out of range")
+		}
+	}
+
+	unitBits := false
+	if len(args) > 2 {
+		switch strings.ToLower(args[2]) {
+		default:
+			return 0, 0, false, errors.New("syntax error")
+		case "byte":
+		case "bit":
+			unitBits = true
+		}
+	}
+
+	if unitBits {
+		start, end, ok := normalizeRange(start, end, int64(len(bitmap))*8)
+		return start, end, ok, nil
+	}
+	start, end, ok := normalizeRange(start, end, int64(len(bitmap)))
+	return start * 8, end*8 + 7, ok, nil
+}
+
+func handleBitCount(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := bitCountKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap, _, err := getBitmap(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, ok, err := parseBitRange(params.Command[2:], bitmap)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []byte(":0\r\n"), nil
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", bitCount(bitmap, start, end))), nil
+}
+
+func handleBitPos(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := bitPosKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	bit, err := strconv.Atoi(params.Command[2])
+	if err != nil || (bit != 0 && bit != 1) {
+		return nil, errors.New("the bit argument must be 1 or 0")
+	}
+
+	bitmap, exists, err := getBitmap(params, keys.ReadKeys[0])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// A non-existent key is treated as an empty string, which only contains clear bits.
+		if bit == 1 {
+			return []byte(":-1\r\n"), nil
+		}
+		return []byte(":0\r\n"), nil
+	}
+
+	start, end, ok, err := parseBitRange(params.Command[3:], bitmap)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []byte(":-1\r\n"), nil
+	}
+
+	pos := bitPos(bitmap, bit, start, end)
+	if pos == -1 && bit == 0 && len(params.Command) < 5 {
+		// When looking for a clear bit without an explicit end, the string is considered to be padded with zeros.
+		pos = int64(len(bitmap)) * 8
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", pos)), nil
+}
+
+func handleBitOp(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := bitOpKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	operation := strings.ToLower(params.Command[1])
+	if !slices.Contains([]string{"and", "or", "xor", "not"}, operation) {
+		return nil, errors.New("syntax error")
+	}
+	if operation == "not" && len(keys.ReadKeys) != 1 {
+		return nil, errors.New("BITOP NOT must be called with a single source key")
+	}
+
+	bitmaps := make([][]byte, len(keys.ReadKeys))
+	length := 0
+	for i, key := range keys.ReadKeys {
+		if bitmaps[i], _, err = getBitmap(params, key); err != nil {
+			return nil, err
+		}
+		length = max(length, len(bitmaps[i]))
+	}
+
+	destination := keys.WriteKeys[0]
+
+	if length == 0 {
+		// All the source keys are empty, so the result is an empty string, which deletes the destination.
+		if params.KeysExist([]string{destination})[destination] {
+			if err = params.DeleteKey(destination); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(":0\r\n"), nil
+	}
+
+	result := make([]byte, length)
+	for i := 0; i < length; i++ {
+		// Shorter strings are treated as if they are padded with zero bytes.
+		for j, bitmap := range bitmaps {
+			b := byte(0)
+			if i < len(bitmap) {
+				b = bitmap[i]
+			}
+			switch {
+			case operation == "not":
+				result[i] = ^b
+			case j == 0:
+				result[i] = b
+			case operation == "and":
+				result[i] &= b
+			case operation == "or":
+				result[i] |= b
+			case operation == "xor":
+				result[i] ^= b
+			}
+		}
+	}
+
+	if err = params.SetValues(params.Context, map[string]interface{}{destination: string(result)}); err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf(":%d\r\n", length)), nil
+}
+
+// bitfieldOperation is a single GET, SET or INCRBY subcommand of BITFIELD.
+type bitfieldOperation struct {
+	operation string
+	encoding  bitfieldType
+	offset    uint64
+	value     int64
+	overflow  string // The overflow behaviour in effect for this operation.
+}
+
+func handleBitField(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := bitFieldKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.WriteKeys[0]
+
+	operations := make([]bitfieldOperation, 0)
+	overflow := "wrap"
+	for i := 2; i < len(params.Command); {
+		operation := strings.ToLower(params.Command[i])
+
+		if operation == "overflow" {
+			if i+1 >= len(params.Command) {
+				return nil, errors.New("syntax error")
+			}
+			overflow = strings.ToLower(params.Command[i+1])
+			if !slices.Contains([]string{"wrap", "sat", "fail"}, overflow) {
+				return nil, errors.New("invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+
+		argCount := 3
+		if operation == "get" {
+			argCount = 2
+		} else if operation != "set" && operation != "incrby" {
+			return nil, errors.New("syntax error")
+		}
+		if i+argCount >= len(params.Command) {
+			return nil, errors.New("syntax error")
+		}
+
+		encoding, err := parseBitfieldType(params.Command[i+1])
+		if err != nil {
+			return nil, err
+		}
+		offset, err := parseBitfieldOffset(params.Command[i+2], encoding)
+		if err != nil {
+			return nil, err
+		}
+		op := bitfieldOperation{operation: operation, encoding: encoding, offset: offset, overflow: overflow}
+		if argCount == 3 {
+			if op.value, err = strconv.ParseInt(params.Command[i+3], 10, 64); err != nil {
+				return nil, errors.New("value is not an integer or out of range")
+			}
+		}
+		operations = append(operations, op)
+		i += argCount + 1
+	}
+
+	bitmap, _, err := getBitmap(params, key)
+	if err != nil {
+		return nil, err
+	}
+
+	null := "$-1\r\n"
+	if params.Protocol == constants.RESP3Protocol {
+		null = constants.NullResponse
+	}
+
+	modified := false
+	res := fmt.Sprintf("*%d\r\n", len(operations))
+	for _, op := range operations {
+		current := op.encoding.get(bitmap, op.offset)
+
+		switch op.operation {
+		case "get":
+			res += fmt.Sprintf(":%d\r\n", current)
+			continue
+		case "set":
+			// The overflow behaviour applies to the value being set.
+			value, ok := op.encoding.add(0, op.value, op.overflow)
+			if !ok {
+				res += null
+				continue
+			}
+			bitmap = growBitmap(bitmap, op.offset+uint64(op.encoding.bits))
+			op.encoding.set(bitmap, op.offset, value)
+			res += fmt.Sprintf(":%d\r\n", current)
+		case "incrby":
+			value, ok := op.encoding.add(current, op.value, op.overflow)
+			if !ok {
+				res += null
+				continue
+			}
+			bitmap = growBitmap(bitmap, op.offset+uint64(op.encoding.bits))
+			op.encoding.set(bitmap, op.offset, value)
+			res += fmt.Sprintf(":%d\r\n", value)
+		}
+		modified = true
+	}
+
+	if modified {
+		if err = params.SetValues(params.Context, map[string]interface{}{key: string(bitmap)}); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(res), nil
+}
+
 func Commands() []internal.Command {
 	return []internal.Command{
 		{
@@ -205,5 +537,75 @@ Overwrites part of a string value with another by offset. Creates the key if it
 			KeyExtractionFunc: subStrKeyFunc,
 			HandlerFunc:       handleSubStr,
 		},
+		{
+			Command:    "setbit",
+			Module:     constants.StringModule,
+			Categories: []string{constants.BitmapCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(SETBIT key offset value)
+Sets or clears the bit at offset in the string value at key, and returns the original bit.
+The string is grown with zero bytes if the offset is past its end. Creates the key if it doesn't exist.`,
+			Sync:              true,
+			KeyExtractionFunc: setBitKeyFunc,
+			HandlerFunc:       handleSetBit,
+		},
+		{
+			Command:    "getbit",
+			Module:     constants.StringModule,
+			Categories: []string{constants.BitmapCategory, constants.ReadCategory, constants.FastCategory},
+			Description: `(GETBIT key offset) Returns the bit at offset in the string value at key.
+Offsets past the end of the string, and non-existent keys, return 0.`,
+			Sync:              false,
+			KeyExtractionFunc: getBitKeyFunc,
+			HandlerFunc:       handleGetBit,
+		},
+		{
+			Command:    "bitcount",
+			Module:     constants.StringModule,
+			Categories: []string{constants.BitmapCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(BITCOUNT key [start end [BYTE | BIT]])
+Returns the number of set bits in the string value at key.
+The range is inclusive and is specified in bytes by default, or in bits with "BIT". Negative indices count from the end.`,
+			Sync:              false,
+			KeyExtractionFunc: bitCountKeyFunc,
+			HandlerFunc:       handleBitCount,
+		},
+		{
+			Command:    "bitpos",
+			Module:     constants.StringModule,
+			Categories: []string{constants.BitmapCategory, constants.ReadCategory, constants.SlowCategory},
+			Description: `(BITPOS key bit [start [end [BYTE | BIT]]])
+Returns the position of the first bit set to 1 or 0 in the string value at key, or -1 if there's none.
+The range is inclusive and is specified in bytes by default, or in bits with "BIT". Negative indices count from the end.`,
+			Sync:              false,
+			KeyExtractionFunc: bitPosKeyFunc,
+			HandlerFunc:       handleBitPos,
+		},
+		{
+			Command:    "bitop",
+			Module:     constants.StringModule,
+			Categories: []string{constants.BitmapCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(BITOP <AND | OR | XOR | NOT> destkey key [key ...])
+Performs a bitwise operation between the strings at the keys and stores the result at destkey.
+NOT only accepts a single key. Shorter strings are padded with zero bytes.
+Returns the length of the string stored at destkey.`,
+			Sync:              true,
+			KeyExtractionFunc: bitOpKeyFunc,
+			HandlerFunc:       handleBitOp,
+		},
+		{
+			Command:    "bitfield",
+			Module:     constants.StringModule,
+			Categories: []string{constants.BitmapCategory, constants.WriteCategory, constants.SlowCategory},
+			Description: `(BITFIELD key [GET encoding offset | [OVERFLOW <WRAP | SAT | FAIL>] <SET encoding offset value | INCRBY encoding offset increment> ...])
+Treats the string value at key as an array of integers of arbitrary width and performs the operations in order.
+The encoding is "i" or "u" for signed and unsigned integers followed by the width in bits, e.g. i8 or u16.
+An offset prefixed with "#" is multiplied by the width of the encoding.
+"OVERFLOW" sets the behaviour of the subsequent SET and INCRBY operations when the result overflows:
+"WRAP" wraps around, "SAT" saturates at the minimum or maximum value, and "FAIL" skips the operation and returns nil.
+Returns an array with the result of each operation.`,
+			Sync:              true,
+			KeyExtractionFunc: bitFieldKeyFunc,
+			HandlerFunc:       handleBitField,
+		},
 	}
 }
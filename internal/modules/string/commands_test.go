@@ -450,4 +450,211 @@ func Test_String(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test_HandleBitmap", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		tests := []struct {
+			name     string
+			command  []string
+			expected string
+		}{
+			{
+				name:     "1. SETBIT creates the key and returns the original bit",
+				command:  []string{"SETBIT", "BitmapKey1", "7", "1"},
+				expected: ":0\r\n",
+			},
+			{
+				name:     "2. SETBIT returns the original bit when it's overwritten",
+				command:  []string{"SETBIT", "BitmapKey1", "7", "0"},
+				expected: ":1\r\n",
+			},
+			{
+				name:     "3. SETBIT grows the string",
+				command:  []string{"SETBIT", "BitmapKey1", "17", "1"},
+				expected: ":0\r\n",
+			},
+			{
+				name:     "4. STRLEN of the grown string",
+				command:  []string{"STRLEN", "BitmapKey1"},
+				expected: ":3\r\n",
+			},
+			{
+				name:     "5. GETBIT returns the bit",
+				command:  []string{"GETBIT", "BitmapKey1", "17"},
+				expected: ":1\r\n",
+			},
+			{
+				name:     "6. GETBIT past the end of the string returns 0",
+				command:  []string{"GETBIT", "BitmapKey1", "1000"},
+				expected: ":0\r\n",
+			},
+			{
+				name:     "7. SETBIT returns an error when the bit is not 0 or 1",
+				command:  []string{"SETBIT", "BitmapKey1", "7", "2"},
+				expected: "-Error bit is not an integer or out of range\r\n",
+			},
+			{
+				name:     "8. SETBIT returns an error when the offset is negative",
+				command:  []string{"SETBIT", "BitmapKey1", "-1", "1"},
+				expected: "-Error bit offset is not an integer or out of range\r\n",
+			},
+			{
+				name:     "9. BITCOUNT the whole string",
+				command:  []string{"SET", "BitmapKey2", "foobar"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"BITCOUNT", "BitmapKey2"},
+				expected: ":26\r\n",
+			},
+			{
+				name:     "10. BITCOUNT a byte range",
+				command:  []string{"BITCOUNT", "BitmapKey2", "1", "1"},
+				expected: ":6\r\n",
+			},
+			{
+				name:     "11. BITCOUNT a bit range",
+				command:  []string{"BITCOUNT", "BitmapKey2", "5", "30", "BIT"},
+				expected: ":17\r\n",
+			},
+			{
+				name:     "12. BITCOUNT with negative indices",
+				command:  []string{"BITCOUNT", "BitmapKey2", "-2", "-1"},
+				expected: ":7\r\n",
+			},
+			{
+				name:     "13. BITCOUNT a non-existent key",
+				command:  []string{"BITCOUNT", "BitmapKey3"},
+				expected: ":0\r\n",
+			},
+			{
+				name:     "14. BITPOS of the first clear bit",
+				command:  []string{"SET", "BitmapKey4", "\xff\xf0\x00"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"BITPOS", "BitmapKey4", "0"},
+				expected: ":12\r\n",
+			},
+			{
+				name:     "15. BITPOS of the first set bit in a range",
+				command:  []string{"BITPOS", "BitmapKey4", "1", "1"},
+				expected: ":8\r\n",
+			},
+			{
+				name:     "16. BITPOS of the first set bit in a bit range",
+				command:  []string{"BITPOS", "BitmapKey4", "1", "7", "15", "BIT"},
+				expected: ":7\r\n",
+			},
+			{
+				name:     "17. BITPOS returns -1 when the bit is not in the range",
+				command:  []string{"BITPOS", "BitmapKey4", "1", "2", "-1"},
+				expected: ":-1\r\n",
+			},
+			{
+				name:     "18. BITPOS of a clear bit in a string of set bits without an end",
+				command:  []string{"SET", "BitmapKey5", "\xff\xff"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"BITPOS", "BitmapKey5", "0"},
+				expected: ":16\r\n",
+			},
+			{
+				name:     "19. BITOP AND",
+				command:  []string{"SET", "BitmapKey6", "abcdef"},
+				expected: "+OK\r\n",
+			},
+			{
+				command:  []string{"BITOP", "AND", "BitmapKey7", "BitmapKey2", "BitmapKey6"},
+				expected: ":6\r\n",
+			},
+			{
+				command:  []string{"GET", "BitmapKey7"},
+				expected: "+`bc`ab\r\n",
+			},
+			{
+				name:     "20. BITOP OR pads shorter strings with zero bytes",
+				command:  []string{"BITOP", "OR", "BitmapKey8", "BitmapKey5", "BitmapKey2"},
+				expected: ":6\r\n",
+			},
+			{
+				command:  []string{"GETRANGE", "BitmapKey8", "2", "5"},
+				expected: "$4\r\nobar\r\n",
+			},
+			{
+				name:     "21. BITOP NOT",
+				command:  []string{"BITOP", "NOT", "BitmapKey9", "BitmapKey5"},
+				expected: ":2\r\n",
+			},
+			{
+				command:  []string{"BITCOUNT", "BitmapKey9"},
+				expected: ":0\r\n",
+			},
+			{
+				name:     "22. BITOP NOT with multiple keys returns an error",
+				command:  []string{"BITOP", "NOT", "BitmapKey9", "BitmapKey5", "BitmapKey6"},
+				expected: "-Error BITOP NOT must be called with a single source key\r\n",
+			},
+			{
+				name:     "23. BITFIELD INCRBY and GET",
+				command:  []string{"BITFIELD", "BitmapKey10", "INCRBY", "i5", "100", "1", "GET", "u4", "0"},
+				expected: "*2\r\n:1\r\n:0\r\n",
+			},
+			{
+				name:     "24. BITFIELD SET returns the old value and wraps by default",
+				command:  []string{"BITFIELD", "BitmapKey10", "SET", "i8", "#1", "127", "INCRBY", "i8", "#1", "1"},
+				expected: "*2\r\n:0\r\n:-128\r\n",
+			},
+			{
+				name:     "25. BITFIELD OVERFLOW SAT",
+				command:  []string{"BITFIELD", "BitmapKey10", "OVERFLOW", "SAT", "INCRBY", "u2", "200", "5", "INCRBY", "i8", "#1", "-1"},
+				expected: "*2\r\n:3\r\n:-128\r\n",
+			},
+			{
+				name:     "26. BITFIELD OVERFLOW FAIL",
+				command:  []string{"BITFIELD", "BitmapKey10", "OVERFLOW", "FAIL", "INCRBY", "u2", "200", "1", "GET", "u2", "200"},
+				expected: "*2\r\n$-1\r\n:3\r\n",
+			},
+			{
+				name:     "27. BITFIELD returns an error for invalid types",
+				command:  []string{"BITFIELD", "BitmapKey10", "GET", "u64", "0"},
+				expected: "-Error invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is\r\n",
+			},
+			{
+				name:     "28. Bitmap commands return an error when the value is not a string",
+				command:  []string{"LPUSH", "BitmapKey11", "value"},
+				expected: ":1\r\n",
+			},
+			{
+				command:  []string{"GETBIT", "BitmapKey11", "0"},
+				expected: "-Error value at key BitmapKey11 is not a string\r\n",
+			},
+		}
+
+		buf := make([]byte, 1024)
+		for _, test := range tests {
+			if _, err = conn.Write(internal.EncodeCommand(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(buf[:n]) != test.expected {
+				t.Errorf("%s %v: expected response %q, got %q", test.name, test.command, test.expected, string(buf[:n]))
+			}
+		}
+	})
 }
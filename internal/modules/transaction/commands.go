@@ -0,0 +1,143 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"errors"
+
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/constants"
+)
+
+func handleMulti(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 1 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.Multi(params.Connection); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleExec(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 1 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	return params.Exec(params.Context, params.Connection)
+}
+
+func handleDiscard(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 1 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	if err := params.Discard(params.Connection); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleWatch(params internal.HandlerFuncParams) ([]byte, error) {
+	keys, err := watchKeyFunc(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	if err = params.Watch(params.Connection, keys.ReadKeys); err != nil {
+		return nil, err
+	}
+	return []byte(constants.OkResponse), nil
+}
+
+func handleUnwatch(params internal.HandlerFuncParams) ([]byte, error) {
+	if len(params.Command) != 1 {
+		return nil, errors.New(constants.WrongArgsResponse)
+	}
+	params.Unwatch(params.Connection)
+	return []byte(constants.OkResponse), nil
+}
+
+func noKeysKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) != 1 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return internal.KeyExtractionFuncResult{
+		Channels:  make([]string, 0),
+		ReadKeys:  make([]string, 0),
+		WriteKeys: make([]string, 0),
+	}, nil
+}
+
+func watchKeyFunc(cmd []string) (internal.KeyExtractionFuncResult, error) {
+	if len(cmd) < 2 {
+		return internal.KeyExtractionFuncResult{}, errors.New(constants.WrongArgsResponse)
+	}
+	return internal.KeyExtractionFuncResult{
+		Channels:  make([]string, 0),
+		ReadKeys:  cmd[1:],
+		WriteKeys: make([]string, 0),
+	}, nil
+}
+
+func Commands() []internal.Command {
+	return []internal.Command{
+		{
+			Command:    "multi",
+			Module:     constants.TransactionModule,
+			Categories: []string{constants.TransactionCategory, constants.FastCategory},
+			Description: `(MULTI) Marks the start of a transaction block.
+All subsequent commands on the connection are queued and executed atomically when EXEC is called.`,
+			Sync:              false,
+			KeyExtractionFunc: noKeysKeyFunc,
+			HandlerFunc:       handleMulti,
+		},
+		{
+			Command:    "exec",
+			Module:     constants.TransactionModule,
+			Categories: []string{constants.TransactionCategory, constants.SlowCategory},
+			Description: `(EXEC) Executes all the commands queued since MULTI as a single atomic unit.
+Returns nil without executing the queued commands if any of the watched keys were modified.`,
+			Sync:              false,
+			KeyExtractionFunc: noKeysKeyFunc,
+			HandlerFunc:       handleExec,
+		},
+		{
+			Command:           "discard",
+			Module:            constants.TransactionModule,
+			Categories:        []string{constants.TransactionCategory, constants.FastCategory},
+			Description:       "(DISCARD) Flushes all the commands queued since MULTI and exits the transaction block.",
+			Sync:              false,
+			KeyExtractionFunc: noKeysKeyFunc,
+			HandlerFunc:       handleDiscard,
+		},
+		{
+			Command:    "watch",
+			Module:     constants.TransactionModule,
+			Categories: []string{constants.TransactionCategory, constants.FastCategory},
+			Description: `(WATCH key [key ...]) Watches the given keys for conditional execution of the next transaction.
+If any of the watched keys are modified before EXEC is called, the transaction is aborted.`,
+			Sync:              false,
+			KeyExtractionFunc: watchKeyFunc,
+			HandlerFunc:       handleWatch,
+		},
+		{
+			Command:           "unwatch",
+			Module:            constants.TransactionModule,
+			Categories:        []string{constants.TransactionCategory, constants.FastCategory},
+			Description:       "(UNWATCH) Flushes all the keys previously watched by the connection.",
+			Sync:              false,
+			KeyExtractionFunc: noKeysKeyFunc,
+			HandlerFunc:       handleUnwatch,
+		},
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction_test
+
+import (
+	"github.com/echovault/echovault/echovault"
+	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/tidwall/resp"
+	"strings"
+	"testing"
+)
+
+func Test_Transaction(t *testing.T) {
+	port, err := internal.GetFreePort()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mockServer, err := echovault.NewEchoVault(
+		echovault.WithConfig(config.Config{
+			BindAddr:       "localhost",
+			Port:           uint16(port),
+			DataDir:        "",
+			EvictionPolicy: constants.NoEviction,
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		mockServer.Start()
+	}()
+
+	t.Cleanup(func() {
+		mockServer.ShutDown()
+	})
+
+	t.Run("Test_HandleMultiExec", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		tests := []struct {
+			name          string
+			commands      [][]string
+			responses     []string
+			execResponse  []string
+			execError     string
+			execNull      bool
+			otherCommands [][]string // Commands executed on a second connection before EXEC.
+		}{
+			{
+				name: "1. Queue commands and execute them in order",
+				commands: [][]string{
+					{"MULTI"},
+					{"SET", "MultiKey1", "value1"},
+					{"INCR", "MultiKey2"},
+					{"GET", "MultiKey1"},
+				},
+				responses:    []string{"OK", "QUEUED", "QUEUED", "QUEUED"},
+				execResponse: []string{"OK", "1", "value1"},
+			},
+			{
+				name: "2. Runtime error in one command does not abort the other commands",
+				commands: [][]string{
+					{"MULTI"},
+					{"SET", "MultiKey3", "value3"},
+					{"INCR", "MultiKey3"},
+					{"GET", "MultiKey3"},
+				},
+				responses:    []string{"OK", "QUEUED", "QUEUED", "QUEUED"},
+				execResponse: []string{"OK", "", "value3"},
+			},
+			{
+				name: "3. Error while queueing a command discards the transaction on EXEC",
+				commands: [][]string{
+					{"MULTI"},
+					{"SET", "MultiKey4", "value4"},
+					{"GET"},
+				},
+				responses: []string{"OK", "QUEUED", "Error wrong number of arguments"},
+				execError: "EXECABORT transaction discarded because of previous errors",
+			},
+			{
+				name: "4. Modifying a watched key aborts the transaction",
+				commands: [][]string{
+					{"WATCH", "MultiKey5"},
+					{"MULTI"},
+					{"SET", "MultiKey5", "value5"},
+				},
+				responses:     []string{"OK", "OK", "QUEUED"},
+				otherCommands: [][]string{{"SET", "MultiKey5", "other"}},
+				execNull:      true,
+			},
+			{
+				name: "5. Unmodified watched key does not abort the transaction",
+				commands: [][]string{
+					{"WATCH", "MultiKey6"},
+					{"MULTI"},
+					{"SET", "MultiKey6", "value6"},
+				},
+				responses:     []string{"OK", "OK", "QUEUED"},
+				otherCommands: [][]string{{"SET", "MultiKey7", "other"}},
+				execResponse:  []string{"OK"},
+			},
+			{
+				name: "6. UNWATCH flushes the watched keys",
+				commands: [][]string{
+					{"WATCH", "MultiKey8"},
+					{"UNWATCH"},
+					{"MULTI"},
+					{"SET", "MultiKey8", "value8"},
+				},
+				responses:     []string{"OK", "OK", "OK", "QUEUED"},
+				otherCommands: [][]string{{"SET", "MultiKey8", "other"}},
+				execResponse:  []string{"OK"},
+			},
+		}
+
+		for _, test := range tests {
+			for i, command := range test.commands {
+				if err = client.WriteArray(toRespArray(command)); err != nil {
+					t.Error(err)
+					return
+				}
+				res, _, err := client.ReadValue()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if res.String() != test.responses[i] {
+					t.Errorf("%s: expected response \"%s\", got \"%s\"", test.name, test.responses[i], res.String())
+				}
+			}
+
+			if len(test.otherCommands) > 0 {
+				other, err := internal.GetConnection("localhost", port)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				otherClient := resp.NewConn(other)
+				for _, command := range test.otherCommands {
+					if err = otherClient.WriteArray(toRespArray(command)); err != nil {
+						t.Error(err)
+						return
+					}
+					if _, _, err = otherClient.ReadValue(); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				_ = other.Close()
+			}
+
+			if err = client.WriteArray([]resp.Value{resp.StringValue("EXEC")}); err != nil {
+				t.Error(err)
+				return
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if test.execError != "" {
+				if !strings.Contains(res.Error().Error(), test.execError) {
+					t.Errorf("%s: expected error \"%s\", got \"%s\"", test.name, test.execError, res.Error().Error())
+				}
+				continue
+			}
+
+			if test.execNull {
+				if !res.IsNull() {
+					t.Errorf("%s: expected nil response, got %+v", test.name, res)
+				}
+				continue
+			}
+
+			if len(res.Array()) != len(test.execResponse) {
+				t.Errorf("%s: expected response of length %d, got %d", test.name, len(test.execResponse), len(res.Array()))
+				continue
+			}
+			for i, r := range res.Array() {
+				if test.execResponse[i] == "" {
+					if r.Type() != resp.Error {
+						t.Errorf("%s: expected error at index %d, got \"%s\"", test.name, i, r.String())
+					}
+					continue
+				}
+				if r.String() != test.execResponse[i] {
+					t.Errorf("%s: expected \"%s\" at index %d, got \"%s\"", test.name, test.execResponse[i], i, r.String())
+				}
+			}
+		}
+	})
+
+	t.Run("Test_HandleDiscard", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		commands := [][]string{
+			{"MULTI"},
+			{"SET", "DiscardKey1", "value1"},
+			{"DISCARD"},
+			{"GET", "DiscardKey1"},
+		}
+		expected := []string{"OK", "QUEUED", "OK", ""}
+
+		for i, command := range commands {
+			if err = client.WriteArray(toRespArray(command)); err != nil {
+				t.Error(err)
+				return
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res.String() != expected[i] {
+				t.Errorf("expected response \"%s\", got \"%s\"", expected[i], res.String())
+			}
+		}
+	})
+
+	t.Run("Test_HandleTransactionErrors", func(t *testing.T) {
+		t.Parallel()
+		conn, err := internal.GetConnection("localhost", port)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		client := resp.NewConn(conn)
+
+		tests := []struct {
+			name    string
+			command []string
+			wantErr string
+		}{
+			{name: "1. EXEC without MULTI", command: []string{"EXEC"}, wantErr: "EXEC without MULTI"},
+			{name: "2. DISCARD without MULTI", command: []string{"DISCARD"}, wantErr: "DISCARD without MULTI"},
+			{name: "3. WATCH without keys", command: []string{"WATCH"}, wantErr: constants.WrongArgsResponse},
+			{name: "4. Start transaction", command: []string{"MULTI"}},
+			{name: "5. Nested MULTI", command: []string{"MULTI"}, wantErr: "MULTI calls can not be nested"},
+			{name: "6. WATCH inside MULTI", command: []string{"WATCH", "key"}, wantErr: "WATCH inside MULTI is not allowed"},
+		}
+
+		for _, test := range tests {
+			if err = client.WriteArray(toRespArray(test.command)); err != nil {
+				t.Error(err)
+				return
+			}
+			res, _, err := client.ReadValue()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if test.wantErr == "" {
+				if res.String() != "OK" {
+					t.Errorf("%s: expected response \"OK\", got \"%s\"", test.name, res.String())
+				}
+				continue
+			}
+			if !strings.Contains(res.Error().Error(), test.wantErr) {
+				t.Errorf("%s: expected error \"%s\", got \"%s\"", test.name, test.wantErr, res.Error().Error())
+			}
+		}
+	})
+}
+
+func toRespArray(command []string) []resp.Value {
+	arr := make([]resp.Value, len(command))
+	for i, token := range command {
+		arr[i] = resp.StringValue(token)
+	}
+	return arr
+}
@@ -15,11 +15,15 @@
 package raft
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/codec"
 	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/slots"
 	"github.com/hashicorp/raft"
 	"io"
 	"log"
@@ -30,15 +34,23 @@ import (
 
 type FSMOpts struct {
 	Config                config.Config
-	GetState              func() map[string]internal.KeyData
+	GetState              func() internal.StateView
 	GetCommand            func(command string) (internal.Command, error)
 	SetValues             func(ctx context.Context, entries map[string]interface{}) error
 	SetExpiry             func(ctx context.Context, key string, expire time.Time, touch bool)
-	DeleteKey             func(key string) error
+	DeleteKey             func(key string, event string) error
+	DeleteKeyIf           func(key string, version uint64) (bool, error)
 	StartSnapshot         func()
 	FinishSnapshot        func()
 	SetLatestSnapshotTime func(msec int64)
 	GetHandlerFuncParams  func(ctx context.Context, cmd []string, conn *net.Conn) internal.HandlerFuncParams
+	ExecTransaction       func(ctx context.Context, commands [][]string, watched map[string]uint64) ([]byte, error)
+	ExecScript            func(ctx context.Context, cmd []string) ([]byte, error)
+	KeysModified          func(keys []string)
+	GetSlotState          func() slots.State
+	SetSlotState          func(state slots.State)
+	RestoreKey            func(key string, value []byte, expireAt int64) error
+	SetAppliedIndex       func(index uint64)
 }
 
 type FSM struct {
@@ -57,6 +69,9 @@ func (fsm *FSM) Apply(log *raft.Log) interface{} {
 	default:
 		// No-Op
 	case raft.LogCommand:
+		// The keys modified by the entry are stamped with its index, which is their version.
+		fsm.options.SetAppliedIndex(log.Index)
+
 		var request internal.ApplyRequest
 
 		if err := json.Unmarshal(log.Data, &request); err != nil {
@@ -68,6 +83,9 @@ func (fsm *FSM) Apply(log *raft.Log) interface{} {
 
 		ctx := context.WithValue(context.Background(), internal.ContextServerID("ServerID"), request.ServerID)
 		ctx = context.WithValue(ctx, internal.ContextConnID("ConnectionID"), request.ConnectionID)
+		if request.Timestamp != 0 {
+			ctx = context.WithValue(ctx, internal.ContextTimestamp("Timestamp"), time.Unix(0, request.Timestamp))
+		}
 
 		switch strings.ToLower(request.Type) {
 		default:
@@ -77,17 +95,97 @@ func (fsm *FSM) Apply(log *raft.Log) interface{} {
 			}
 
 		case "delete-key":
-			if err := fsm.options.DeleteKey(request.Key); err != nil {
+			if err := fsm.options.DeleteKey(request.Key, request.Event); err != nil {
+				return internal.ApplyResponse{
+					Error:    err,
+					Response: nil,
+				}
+			}
+			return internal.ApplyResponse{
+				Error:    nil,
+				Response: []byte("OK"),
+			}
+
+		case "delete-key-if":
+			// Delete the key only if it has not been modified since its version was read.
+			// The response is nil if the key was modified.
+			deleted, err := fsm.options.DeleteKeyIf(request.Key, request.Version)
+			if err != nil {
 				return internal.ApplyResponse{
 					Error:    err,
 					Response: nil,
 				}
 			}
+			if !deleted {
+				return internal.ApplyResponse{
+					Error:    nil,
+					Response: nil,
+				}
+			}
 			return internal.ApplyResponse{
 				Error:    nil,
 				Response: []byte("OK"),
 			}
 
+		case "slots":
+			// Replace the slot state of the shard.
+			if request.Slots == nil {
+				return internal.ApplyResponse{
+					Error:    errors.New("slots request without a slot state"),
+					Response: nil,
+				}
+			}
+			fsm.options.SetSlotState(*request.Slots)
+			return internal.ApplyResponse{
+				Error:    nil,
+				Response: []byte("OK"),
+			}
+
+		case "restore-key":
+			// Store a key migrated from another shard.
+			if err := fsm.options.RestoreKey(request.Key, request.Value, request.ExpireAt); err != nil {
+				return internal.ApplyResponse{
+					Error:    err,
+					Response: nil,
+				}
+			}
+			return internal.ApplyResponse{
+				Error:    nil,
+				Response: []byte("OK"),
+			}
+
+		case "transaction":
+			// Execute all the commands of the transaction as a single unit, unless a watched key was modified.
+			// The response is then nil.
+			res, err := fsm.options.ExecTransaction(ctx, request.Transaction, request.Watched)
+			if err != nil {
+				return internal.ApplyResponse{
+					Error:    err,
+					Response: nil,
+				}
+			}
+			return internal.ApplyResponse{
+				Error:    nil,
+				Response: res,
+			}
+
+		case "script":
+			// Run the script with the protocol version of the client that called it.
+			if request.Protocol != 0 {
+				ctx = context.WithValue(ctx, internal.ContextProtocol("Protocol"), request.Protocol)
+			}
+			res, err := fsm.options.ExecScript(ctx, request.CMD)
+			if err != nil {
+				return internal.ApplyResponse{
+					Error:    err,
+					Response: nil,
+				}
+			}
+			return internal.ApplyResponse{
+				Error:    nil,
+				Response: res,
+			}
+
 		case "command":
 			// Handle command
 			command, err := fsm.options.GetCommand(request.CMD[0])
@@ -99,6 +197,7 @@ func (fsm *FSM) Apply(log *raft.Log) interface{} {
 			}
 
 			handler := command.HandlerFunc
+			keyExtractionFunc := command.KeyExtractionFunc
 
 			sc, err := internal.GetSubCommand(command, request.CMD)
 			if err != nil {
@@ -110,19 +209,35 @@ func (fsm *FSM) Apply(log *raft.Log) interface{} {
 			subCommand, ok := sc.(internal.SubCommand)
 			if ok {
 				handler = subCommand.HandlerFunc
+				keyExtractionFunc = subCommand.KeyExtractionFunc
 			}
 
-			if res, err := handler(fsm.options.GetHandlerFuncParams(ctx, request.CMD, nil)); err != nil {
+			params := fsm.options.GetHandlerFuncParams(ctx, request.CMD, nil)
+			if request.Protocol != 0 {
+				params.Protocol = request.Protocol
+			}
+			// Blocking would stall the log, so commands applied through raft are not allowed to block.
+			params.NotifyOnKeys = nil
+			params.QueueOnKeys = nil
+
+			res, err := handler(params)
+			if err != nil {
 				return internal.ApplyResponse{
 					Error:    err,
 					Response: nil,
 				}
-			} else {
-				return internal.ApplyResponse{
-					Error:    nil,
-					Response: res,
+			}
+
+			if internal.IsWriteCommand(command, subCommand) {
+				if keys, err := keyExtractionFunc(request.CMD); err == nil {
+					fsm.options.KeysModified(keys.WriteKeys)
 				}
 			}
+
+			return internal.ApplyResponse{
+				Error:    nil,
+				Response: res,
+			}
 		}
 	}
 
@@ -131,30 +246,33 @@ func (fsm *FSM) Apply(log *raft.Log) interface{} {
 
 // Snapshot implements raft.FSM interface
 func (fsm *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	slotState := fsm.options.GetSlotState()
 	return NewFSMSnapshot(SnapshotOpts{
 		config:                fsm.options.Config,
 		startSnapshot:         fsm.options.StartSnapshot,
 		finishSnapshot:        fsm.options.FinishSnapshot,
 		setLatestSnapshotTime: fsm.options.SetLatestSnapshotTime,
-		data:                  fsm.options.GetState(),
+		view:                  fsm.options.GetState(),
+		slots:                 &slotState,
 	}), nil
 }
 
 // Restore implements raft.FSM interface
 func (fsm *FSM) Restore(snapshot io.ReadCloser) error {
-	b, err := io.ReadAll(snapshot)
-
-	if err != nil {
-		log.Fatal(err)
-		return err
-	}
-
 	data := internal.SnapshotObject{
 		State:                      make(map[string]internal.KeyData),
 		LatestSnapshotMilliseconds: 0,
 	}
 
-	if err = json.Unmarshal(b, &data); err != nil {
+	var err error
+	r := bufio.NewReader(snapshot)
+	if codec.IsBinary(r) {
+		data, err = codec.Decode(r)
+	} else {
+		// Snapshots taken before the binary format was introduced are JSON encoded.
+		err = json.NewDecoder(r).Decode(&data)
+	}
+	if err != nil {
 		log.Fatal(err)
 		return err
 	}
@@ -162,11 +280,17 @@ func (fsm *FSM) Restore(snapshot io.ReadCloser) error {
 	// Set state
 	ctx := context.Background()
 	for k, v := range internal.FilterExpiredKeys(time.Now(), data.State) {
+		// Restore the key with the version it had when the snapshot was taken.
+		fsm.options.SetAppliedIndex(v.Version)
 		if err = fsm.options.SetValues(ctx, map[string]interface{}{k: v.Value}); err != nil {
 			log.Fatal(err)
 		}
 		fsm.options.SetExpiry(ctx, k, v.ExpireAt, false)
 	}
+	// Set the slot state of the shard. Snapshots taken before the keyspace was sharded don't have one.
+	if data.Slots != nil {
+		fsm.options.SetSlotState(*data.Slots)
+	}
 	// Set latest snapshot milliseconds
 	fsm.options.SetLatestSnapshotTime(data.LatestSnapshotMilliseconds)
 
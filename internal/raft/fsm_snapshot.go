@@ -15,9 +15,10 @@
 package raft
 
 import (
-	"encoding/json"
 	"github.com/echovault/echovault/internal"
+	"github.com/echovault/echovault/internal/codec"
 	"github.com/echovault/echovault/internal/config"
+	"github.com/echovault/echovault/internal/slots"
 	"github.com/hashicorp/raft"
 	"strconv"
 	"strings"
@@ -26,7 +27,8 @@ import (
 
 type SnapshotOpts struct {
 	config                config.Config
-	data                  map[string]internal.KeyData
+	view                  internal.StateView
+	slots                 *slots.State
 	startSnapshot         func()
 	finishSnapshot        func()
 	setLatestSnapshotTime func(msec int64)
@@ -53,18 +55,12 @@ func (s *Snapshot) Persist(sink raft.SnapshotSink) error {
 	}
 
 	snapshotObject := internal.SnapshotObject{
-		State:                      internal.FilterExpiredKeys(time.Now(), s.options.data),
+		State:                      internal.FilterExpiredKeys(time.Now(), s.options.view.State),
 		LatestSnapshotMilliseconds: int64(msec),
+		Slots:                      s.options.slots,
 	}
 
-	o, err := json.Marshal(snapshotObject)
-
-	if err != nil {
-		_ = sink.Cancel()
-		return err
-	}
-
-	if _, err = sink.Write(o); err != nil {
+	if err = codec.Encode(sink, snapshotObject); err != nil {
 		_ = sink.Cancel()
 		return err
 	}
@@ -76,5 +72,6 @@ func (s *Snapshot) Persist(sink raft.SnapshotSink) error {
 
 // Release implements FSMSnapshot interface
 func (s *Snapshot) Release() {
+	s.options.view.Release()
 	s.options.finishSnapshot()
 }
@@ -21,32 +21,51 @@ import (
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/config"
 	"github.com/echovault/echovault/internal/memberlist"
+	"github.com/echovault/echovault/internal/slots"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
 )
 
+// ErrNotLeader is the error of Apply when the node is not the leader. The command is not applied.
+var ErrNotLeader = raft.ErrNotLeader
+
+// Voter is the suffrage of the servers that vote in elections and can be elected leader.
+const Voter = raft.Voter
+
 type Opts struct {
 	Config                config.Config
 	SetValues             func(ctx context.Context, entries map[string]interface{}) error
 	SetExpiry             func(ctx context.Context, key string, expire time.Time, touch bool)
-	GetState              func() map[string]internal.KeyData
+	GetState              func() internal.StateView
 	GetCommand            func(command string) (internal.Command, error)
-	DeleteKey             func(key string) error
+	DeleteKey             func(key string, event string) error
+	DeleteKeyIf           func(key string, version uint64) (bool, error)
 	StartSnapshot         func()
 	FinishSnapshot        func()
 	SetLatestSnapshotTime func(msec int64)
 	GetHandlerFuncParams  func(ctx context.Context, cmd []string, conn *net.Conn) internal.HandlerFuncParams
+	ExecTransaction       func(ctx context.Context, commands [][]string, watched map[string]uint64) ([]byte, error)
+	ExecScript            func(ctx context.Context, cmd []string) ([]byte, error)
+	KeysModified          func(keys []string)
+	GetSlotState          func() slots.State
+	SetSlotState          func(state slots.State)
+	RestoreKey            func(key string, value []byte, expireAt int64) error
+	SetAppliedIndex       func(index uint64)
 }
 
 type Raft struct {
 	options Opts
 	raft    *raft.Raft
+
+	leaseTimeout time.Duration // How long the leadership lease lasts after the leadership is confirmed.
+	leaseExpiry  atomic.Int64  // The time the leadership lease expires, in unix nanoseconds.
 }
 
 func NewRaft(opts Opts) *Raft {
@@ -62,6 +81,7 @@ func (r *Raft) RaftInit(ctx context.Context) {
 	raftConfig.LocalID = raft.ServerID(conf.ServerID)
 	raftConfig.SnapshotThreshold = conf.SnapShotThreshold
 	raftConfig.SnapshotInterval = conf.SnapshotInterval
+	r.leaseTimeout = raftConfig.LeaderLeaseTimeout
 
 	var logStore raft.LogStore
 	var stableStore raft.StableStore
@@ -119,10 +139,18 @@ func (r *Raft) RaftInit(ctx context.Context) {
 			SetValues:             r.options.SetValues,
 			SetExpiry:             r.options.SetExpiry,
 			DeleteKey:             r.options.DeleteKey,
+			DeleteKeyIf:           r.options.DeleteKeyIf,
 			StartSnapshot:         r.options.StartSnapshot,
 			FinishSnapshot:        r.options.FinishSnapshot,
 			SetLatestSnapshotTime: r.options.SetLatestSnapshotTime,
 			GetHandlerFuncParams:  r.options.GetHandlerFuncParams,
+			ExecTransaction:       r.options.ExecTransaction,
+			ExecScript:            r.options.ExecScript,
+			KeysModified:          r.options.KeysModified,
+			GetSlotState:          r.options.GetSlotState,
+			SetSlotState:          r.options.SetSlotState,
+			RestoreKey:            r.options.RestoreKey,
+			SetAppliedIndex:       r.options.SetAppliedIndex,
 		}),
 		logStore,
 		stableStore,
@@ -141,7 +169,7 @@ func (r *Raft) RaftInit(ctx context.Context) {
 				{
 					Suffrage: raft.Voter,
 					ID:       raft.ServerID(conf.ServerID),
-					Address:  raft.ServerAddress(conf.RaftBindAddr),
+					Address:  raft.ServerAddress(bindAddr),
 				},
 			},
 		}).Error()
@@ -158,6 +186,89 @@ func (r *Raft) IsRaftLeader() bool {
 	return r.raft.State() == raft.Leader
 }
 
+// VerifyLeader confirms with a quorum of the cluster that the node is still the leader, then waits until the
+// entries in its log when the call started are applied. Reads served after it returns observe every write
+// acknowledged before the call. A successful call also renews the leadership lease.
+// It returns the context's error if the context is done first.
+func (r *Raft) VerifyLeader(ctx context.Context) error {
+	if !r.IsRaftLeader() {
+		return ErrNotLeader
+	}
+
+	start := time.Now()
+	readIndex := r.raft.LastIndex()
+	if err := waitFuture(ctx, r.raft.VerifyLeader()); err != nil {
+		return err
+	}
+	// The lease starts when the leadership was confirmed at the latest, which is before the quorum responded.
+	r.leaseExpiry.Store(start.Add(r.leaseTimeout).UnixNano())
+
+	if r.raft.AppliedIndex() >= readIndex {
+		return nil
+	}
+	// The barrier is applied after every entry that precedes it in the log, including the entry at the read index.
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if err := waitFuture(ctx, r.raft.Barrier(timeout)); err != nil {
+		return fmt.Errorf("waiting for the log to be applied up to index %d: %v", readIndex, err)
+	}
+	return nil
+}
+
+// VerifyLease returns nil if the node is the leader and its leadership lease is valid. When the lease has expired,
+// the leadership is confirmed with VerifyLeader, which renews the lease.
+// The lease is shorter than the election timeout, so no other node can be elected while it's valid.
+func (r *Raft) VerifyLease(ctx context.Context) error {
+	if !r.IsRaftLeader() {
+		return ErrNotLeader
+	}
+	if time.Now().UnixNano() < r.leaseExpiry.Load() {
+		return nil
+	}
+	return r.VerifyLeader(ctx)
+}
+
+// waitFuture waits for the future to complete and returns its error, or returns the context's error if the
+// context is done first. Raft futures can't be cancelled, so the future completes in the background in that case.
+func waitFuture(ctx context.Context, future raft.Future) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- future.Error()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReplicationLag returns the time since the node last heard from the leader. It's 0 on the leader.
+// ok is false if a follower has never heard from a leader.
+func (r *Raft) ReplicationLag() (lag time.Duration, ok bool) {
+	if r.IsRaftLeader() {
+		return 0, true
+	}
+	lastContact := r.raft.LastContact()
+	if lastContact.IsZero() {
+		return 0, false
+	}
+	return time.Since(lastContact), true
+}
+
+// Leader returns the address and the ID of the current cluster leader. They're empty if there's no known leader.
+func (r *Raft) Leader() (raft.ServerAddress, raft.ServerID) {
+	return r.raft.LeaderWithID()
+}
+
+// LeaderCh returns a channel that receives true when the node becomes the leader and false when it loses the
+// leadership. Notifications are dropped if the previous one was not received.
+func (r *Raft) LeaderCh() <-chan bool {
+	return r.raft.LeaderCh()
+}
+
 func (r *Raft) isRaftFollower() bool {
 	return r.raft.State() == raft.Follower
 }
@@ -199,7 +310,63 @@ func (r *Raft) AddVoter(
 	return nil
 }
 
+// AddNonvoter adds a learner to the raft group. Learners receive the log entries, but don't vote in elections
+// and can't be elected, so they don't count towards the quorum.
+func (r *Raft) AddNonvoter(
+	id raft.ServerID,
+	address raft.ServerAddress,
+	prevIndex uint64,
+	timeout time.Duration,
+) error {
+	if r.IsRaftLeader() {
+		raftConfig := r.raft.GetConfiguration()
+		if err := raftConfig.Error(); err != nil {
+			return errors.New("could not retrieve raft config")
+		}
+
+		for _, s := range raftConfig.Configuration().Servers {
+			// Check if a node already exists with the current attributes.
+			if s.ID == id && s.Address == address {
+				return fmt.Errorf("node with id %s and address %s already exists", id, address)
+			}
+		}
+
+		err := r.raft.AddNonvoter(id, address, prevIndex, timeout).Error()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Promote makes the learner with the ID a voter. Must be called on the leader.
+func (r *Raft) Promote(id string) error {
+	if !r.IsRaftLeader() {
+		return ErrNotLeader
+	}
+
+	servers, err := r.Servers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		if s.ID != raft.ServerID(id) {
+			continue
+		}
+		if s.Suffrage == raft.Voter {
+			return fmt.Errorf("node %s is already a voter", id)
+		}
+		return r.raft.AddVoter(s.ID, s.Address, 0, 0).Error()
+	}
+	return fmt.Errorf("node %s is not a member of the cluster", id)
+}
+
 func (r *Raft) RemoveServer(meta memberlist.NodeMeta) error {
+	if meta.ShardID != r.options.Config.ShardID {
+		// The node belongs to the raft group of another shard.
+		return nil
+	}
 	if !r.IsRaftLeader() {
 		return errors.New("not leader, could not remove node")
 	}
@@ -215,6 +382,47 @@ func (r *Raft) TakeSnapshot() error {
 	return r.raft.Snapshot().Error()
 }
 
+// Stats returns the raft stats of the node, such as its state, term, last contact with the leader
+// and its commit and applied indexes.
+func (r *Raft) Stats() map[string]string {
+	return r.raft.Stats()
+}
+
+// Servers returns the servers of the latest raft configuration.
+func (r *Raft) Servers() ([]raft.Server, error) {
+	future := r.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, errors.New("could not retrieve raft config")
+	}
+	return future.Configuration().Servers, nil
+}
+
+// TransferLeadership transfers the leadership to the voter with the ID. When the ID is empty,
+// the most up-to-date voter is picked.
+func (r *Raft) TransferLeadership(id string) error {
+	if !r.IsRaftLeader() {
+		return ErrNotLeader
+	}
+	if id == "" {
+		return r.raft.LeadershipTransfer().Error()
+	}
+
+	servers, err := r.Servers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		if s.ID != raft.ServerID(id) {
+			continue
+		}
+		if s.Suffrage != raft.Voter {
+			return fmt.Errorf("node %s is not a voter", id)
+		}
+		return r.raft.LeadershipTransferToServer(s.ID, s.Address).Error()
+	}
+	return fmt.Errorf("node %s is not a member of the cluster", id)
+}
+
 func (r *Raft) RaftShutdown() {
 	// Leadership transfer if current node is the leader.
 	if r.IsRaftLeader() {
@@ -0,0 +1,244 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/echovault/echovault/internal/constants"
+	"github.com/gobwas/glob"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ScanOptions holds the parsed arguments of SCAN, HSCAN, SSCAN and ZSCAN.
+type ScanOptions struct {
+	Cursor uint64
+	Match  glob.Glob // Nil if no MATCH pattern was provided.
+	Count  int
+	Type   string // The type of the keys returned by SCAN. Empty if no TYPE was provided.
+}
+
+// ParseScanArgs parses the cursor and the options of a scan command.
+// The TYPE option is only accepted when withType is true.
+func ParseScanArgs(args []string, withType bool) (ScanOptions, error) {
+	if len(args) == 0 {
+		return ScanOptions{}, errors.New(constants.WrongArgsResponse)
+	}
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return ScanOptions{}, errors.New("invalid cursor")
+	}
+	options := ScanOptions{Cursor: cursor, Count: 10}
+
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return ScanOptions{}, errors.New("syntax error")
+		}
+		switch strings.ToLower(args[i]) {
+		default:
+			return ScanOptions{}, errors.New("syntax error")
+		case "match":
+			if options.Match, err = glob.Compile(args[i+1]); err != nil {
+				return ScanOptions{}, fmt.Errorf("invalid pattern %s", args[i+1])
+			}
+		case "count":
+			if options.Count, err = strconv.Atoi(args[i+1]); err != nil {
+				return ScanOptions{}, errors.New("value is not an integer or out of range")
+			}
+			if options.Count < 1 {
+				return ScanOptions{}, errors.New("syntax error")
+			}
+		case "type":
+			if !withType {
+				return ScanOptions{}, errors.New("syntax error")
+			}
+			options.Type = strings.ToLower(args[i+1])
+		}
+	}
+
+	return options, nil
+}
+
+// Matches returns whether the element matches the MATCH pattern of the options.
+func (options ScanOptions) Matches(element string) bool {
+	return options.Match == nil || options.Match.Match(element)
+}
+
+// ScanHash returns the position of the element in the scan order.
+func ScanHash(element string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(element))
+	return h.Sum64()
+}
+
+// ScanIndex keeps the elements of a collection in their scan order, so that each call to Scan only visits the
+// elements of the batch it returns.
+//
+// The elements are ordered by their ScanHash, and the cursor is the hash to resume from.
+// As the order does not depend on the elements around them, an element that exists for the whole iteration is
+// returned exactly once, no matter how many elements are added or removed in between.
+//
+// The elements are kept in a list of sorted chunks, so adding and removing an element only moves the elements of
+// its chunk. ScanIndex is not safe for concurrent use.
+type ScanIndex struct {
+	chunks [][]scanEntry
+	length int
+}
+
+type scanEntry struct {
+	hash    uint64
+	element string
+}
+
+// scanChunkSize is the number of entries at which a chunk is split in two.
+const scanChunkSize = 128
+
+func compareScanEntries(a, b scanEntry) int {
+	if c := cmp.Compare(a.hash, b.hash); c != 0 {
+		return c
+	}
+	return strings.Compare(a.element, b.element)
+}
+
+func NewScanIndex() *ScanIndex {
+	return &ScanIndex{}
+}
+
+// Len returns the number of elements in the index.
+func (index *ScanIndex) Len() int {
+	return index.length
+}
+
+// chunkFor returns the index of the first chunk whose last entry is not lower than the entry.
+func (index *ScanIndex) chunkFor(entry scanEntry) int {
+	i, _ := slices.BinarySearchFunc(index.chunks, entry, func(chunk []scanEntry, entry scanEntry) int {
+		return compareScanEntries(chunk[len(chunk)-1], entry)
+	})
+	return i
+}
+
+// Add adds the element to the index. It's a no-op if the element is already in the index.
+func (index *ScanIndex) Add(element string) {
+	entry := scanEntry{hash: ScanHash(element), element: element}
+	i := index.chunkFor(entry)
+	switch {
+	case len(index.chunks) == 0:
+		index.chunks = append(index.chunks, make([]scanEntry, 0, scanChunkSize))
+	case i == len(index.chunks):
+		// The entry is higher than every entry in the index, so it's appended to the last chunk.
+		i--
+	}
+	chunk := index.chunks[i]
+	j, found := slices.BinarySearchFunc(chunk, entry, compareScanEntries)
+	if found {
+		return
+	}
+	chunk = slices.Insert(chunk, j, entry)
+	index.length++
+
+	if len(chunk) < scanChunkSize {
+		index.chunks[i] = chunk
+		return
+	}
+	// Split the full chunk in two, copying the upper half so that the chunks don't share their backing arrays.
+	upper := append(make([]scanEntry, 0, scanChunkSize), chunk[len(chunk)/2:]...)
+	index.chunks[i] = slices.Clip(chunk[:len(chunk)/2])
+	index.chunks = slices.Insert(index.chunks, i+1, upper)
+}
+
+// Remove removes the element from the index. It's a no-op if the element is not in the index.
+func (index *ScanIndex) Remove(element string) {
+	entry := scanEntry{hash: ScanHash(element), element: element}
+	i := index.chunkFor(entry)
+	if i == len(index.chunks) {
+		return
+	}
+	j, found := slices.BinarySearchFunc(index.chunks[i], entry, compareScanEntries)
+	if !found {
+		return
+	}
+	index.chunks[i] = slices.Delete(index.chunks[i], j, j+1)
+	index.length--
+	if len(index.chunks[i]) == 0 {
+		index.chunks = slices.Delete(index.chunks, i, i+1)
+	}
+}
+
+// Scan returns the next batch of at least count elements (when that many are left), starting from the cursor,
+// along with the cursor for the next call. The returned cursor is 0 when the iteration is complete.
+// Elements with the same hash are always returned in the same batch.
+func (index *ScanIndex) Scan(cursor uint64, count int) ([]string, uint64) {
+	elements := make([]string, 0, count)
+	last := uint64(0)
+	for i := index.chunkFor(scanEntry{hash: cursor}); i < len(index.chunks); i++ {
+		chunk := index.chunks[i]
+		j, _ := slices.BinarySearchFunc(chunk, scanEntry{hash: cursor}, compareScanEntries)
+		for ; j < len(chunk); j++ {
+			if len(elements) >= count && chunk[j].hash != last {
+				return elements, chunk[j].hash
+			}
+			elements = append(elements, chunk[j].element)
+			last = chunk[j].hash
+		}
+	}
+	return elements, 0
+}
+
+// MergeScans merges the batches returned by scanning several indexes from the same cursor into the batch that
+// a single index holding all their elements would have returned.
+func MergeScans(count int, batches [][]string, cursors []uint64) ([]string, uint64) {
+	var entries []scanEntry
+	for _, batch := range batches {
+		for _, element := range batch {
+			entries = append(entries, scanEntry{hash: ScanHash(element), element: element})
+		}
+	}
+	slices.SortFunc(entries, compareScanEntries)
+
+	// An index that was cut short returned at least count elements, so the batch can not go past its cursor.
+	next := uint64(0)
+	for _, cursor := range cursors {
+		if cursor != 0 && (next == 0 || cursor < next) {
+			next = cursor
+		}
+	}
+
+	elements := make([]string, 0, min(count, len(entries)))
+	for i, entry := range entries {
+		if len(elements) >= count && entry.hash != entries[i-1].hash {
+			if next == 0 || entry.hash < next {
+				next = entry.hash
+			}
+			break
+		}
+		elements = append(elements, entry.element)
+	}
+	return elements, next
+}
+
+// EncodeScanResponse encodes the cursor and the elements returned by a scan command.
+func EncodeScanResponse(cursor uint64, elements []string) []byte {
+	c := strconv.FormatUint(cursor, 10)
+	var res strings.Builder
+	_, _ = fmt.Fprintf(&res, "*2\r\n$%d\r\n%s\r\n*%d\r\n", len(c), c, len(elements))
+	for _, element := range elements {
+		_, _ = fmt.Fprintf(&res, "$%d\r\n%s\r\n", len(element), element)
+	}
+	return []byte(res.String())
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func Test_ScanIndex(t *testing.T) {
+	index := NewScanIndex()
+	for i := 0; i < 1000; i++ {
+		index.Add(fmt.Sprintf("element%d", i))
+	}
+	index.Add("element0")
+	if index.Len() != 1000 {
+		t.Fatalf("expected length 1000, got %d", index.Len())
+	}
+
+	// Elements 0-499 exist for the whole iteration, so they must be returned exactly once.
+	// Elements 500-999 are removed and elements 1000-1499 are added while scanning.
+	returned := make(map[string]int)
+	cursor := uint64(0)
+	for i := 0; ; i++ {
+		var batch []string
+		batch, cursor = index.Scan(cursor, 10)
+		if len(batch) < 10 && cursor != 0 {
+			t.Fatalf("expected at least 10 elements before the end of the iteration, got %d", len(batch))
+		}
+		for _, element := range batch {
+			returned[element]++
+		}
+		if cursor == 0 {
+			break
+		}
+		if i < 50 {
+			index.Remove(fmt.Sprintf("element%d", 500+i*10))
+			index.Add(fmt.Sprintf("element%d", 1000+i*10))
+		}
+	}
+	for i := 0; i < 500; i++ {
+		if count := returned[fmt.Sprintf("element%d", i)]; count != 1 {
+			t.Errorf("expected element%d to be returned once, got %d", i, count)
+		}
+	}
+	for element, count := range returned {
+		if count != 1 {
+			t.Errorf("expected %s to be returned at most once, got %d", element, count)
+		}
+	}
+
+	for i := 0; i < 1500; i++ {
+		index.Remove(fmt.Sprintf("element%d", i))
+	}
+	if batch, cursor := index.Scan(0, 10); index.Len() != 0 || len(batch) != 0 || cursor != 0 {
+		t.Errorf("expected an empty index, got length %d, batch %v, cursor %d", index.Len(), batch, cursor)
+	}
+}
+
+func Test_MergeScans(t *testing.T) {
+	all := NewScanIndex()
+	indexes := []*ScanIndex{NewScanIndex(), NewScanIndex(), NewScanIndex()}
+	for i := 0; i < 300; i++ {
+		element := fmt.Sprintf("element%d", i)
+		all.Add(element)
+		// Spread the elements unevenly over the indexes.
+		indexes[i%5%3].Add(element)
+	}
+
+	for _, count := range []int{1, 7, 50, 1000} {
+		cursor, merged := uint64(0), uint64(0)
+		for {
+			expected, next := all.Scan(cursor, count)
+			batches := make([][]string, len(indexes))
+			cursors := make([]uint64, len(indexes))
+			for i, index := range indexes {
+				batches[i], cursors[i] = index.Scan(cursor, count)
+			}
+			var batch []string
+			batch, merged = MergeScans(count, batches, cursors)
+			if !slices.Equal(batch, expected) || merged != next {
+				t.Fatalf("count %d: expected batch %v and cursor %d, got %v and %d", count, expected, next, batch, merged)
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package slots implements the hash slots that the keyspace is sharded by in cluster mode.
+//
+// Every key belongs to one of 16384 slots, computed the same way as Redis Cluster: the CRC16 of the key modulo 16384.
+// If the key contains a hashtag (a non-empty substring between the first "{" and the next "}"), only the hashtag is
+// hashed, so that related keys can be placed in the same slot. Each raft group (shard) owns a set of slots and
+// replicates only the keys in those slots.
+package slots
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Count is the number of hash slots.
+const Count = 16384
+
+// KeySlot returns the hash slot of the key.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start != -1 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % Count)
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Range is an inclusive range of slots.
+type Range struct {
+	Start int `json:"Start"`
+	End   int `json:"End"`
+}
+
+// ParseRanges parses a comma separated list of slots and slot ranges (e.g. "0-5460,6000,7000-7100").
+func ParseRanges(s string) ([]Range, error) {
+	var slots []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		start, end, isRange := strings.Cut(part, "-")
+		first, err := ParseSlot(start)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = ParseSlot(end); err != nil {
+				return nil, err
+			}
+		}
+		if last < first {
+			return nil, fmt.Errorf("slot range %s is not in ascending order", part)
+		}
+		for slot := first; slot <= last; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return NewRanges(slots), nil
+}
+
+// ParseSlot parses a slot number.
+func ParseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || slot < 0 || slot >= Count {
+		return 0, fmt.Errorf("invalid slot %s, slots must be between 0 and %d", s, Count-1)
+	}
+	return slot, nil
+}
+
+// NewRanges returns the sorted, non-overlapping ranges that cover the slots.
+func NewRanges(slots []int) []Range {
+	var owned [Count]bool
+	for _, slot := range slots {
+		owned[slot] = true
+	}
+	return rangesOf(&owned)
+}
+
+// AllRanges returns the range that covers all the slots.
+func AllRanges() []Range {
+	return []Range{{Start: 0, End: Count - 1}}
+}
+
+// FormatRanges formats the ranges the way ParseRanges parses them.
+func FormatRanges(ranges []Range) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		if r.Start == r.End {
+			parts[i] = strconv.Itoa(r.Start)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", r.Start, r.End)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Contains returns true if the slot is in one of the ranges.
+func Contains(ranges []Range, slot int) bool {
+	for _, r := range ranges {
+		if slot >= r.Start && slot <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+func rangesOf(owned *[Count]bool) []Range {
+	ranges := make([]Range, 0)
+	for slot := 0; slot < Count; slot++ {
+		if !owned[slot] {
+			continue
+		}
+		r := Range{Start: slot, End: slot}
+		for r.End+1 < Count && owned[r.End+1] {
+			r.End++
+		}
+		ranges = append(ranges, r)
+		slot = r.End
+	}
+	return ranges
+}
+
+// State is the slot configuration of a shard. It's replicated through the shard's raft log.
+type State struct {
+	// Epoch orders the claims of the shards on the slots. When two shards claim a slot, the one with the higher
+	// epoch owns it. A shard's epoch is raised above every other shard's whenever it takes over a slot.
+	Epoch     uint64         `json:"Epoch"`
+	Slots     []Range        `json:"Slots"`     // The slots owned by the shard.
+	Migrating map[int]string `json:"Migrating"` // The owned slots being migrated, mapped to the ID of the target shard.
+	Importing map[int]string `json:"Importing"` // The slots being imported, mapped to the ID of the source shard.
+}
+
+// NewState returns the state of a shard that owns the ranges.
+func NewState(ranges []Range) State {
+	return State{
+		Slots:     ranges,
+		Migrating: make(map[int]string),
+		Importing: make(map[int]string),
+	}
+}
+
+// Clone returns a copy of the state that can be modified without affecting the original.
+func (state State) Clone() State {
+	clone := state
+	clone.Slots = append([]Range{}, state.Slots...)
+	clone.Migrating = maps.Clone(state.Migrating)
+	clone.Importing = maps.Clone(state.Importing)
+	if clone.Migrating == nil {
+		clone.Migrating = make(map[int]string)
+	}
+	if clone.Importing == nil {
+		clone.Importing = make(map[int]string)
+	}
+	return clone
+}
+
+// Digest returns a hash of the slots owned by the shard, so that a copy of the state can be checked against the
+// state advertised by the shard without transferring the slots.
+func (state State) Digest() uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(FormatRanges(state.Slots)))
+	return hash.Sum64()
+}
+
+// Owns returns true if the shard owns the slot.
+func (state State) Owns(slot int) bool {
+	return Contains(state.Slots, slot)
+}
+
+// SetOwned adds the slot to, or removes it from, the slots owned by the shard.
+func (state *State) SetOwned(slot int, owned bool) {
+	var bitmap [Count]bool
+	for _, r := range state.Slots {
+		for s := r.Start; s <= r.End; s++ {
+			bitmap[s] = true
+		}
+	}
+	bitmap[slot] = owned
+	state.Slots = rangesOf(&bitmap)
+}
+
+// Error is a cluster error that is sent to clients with its code as the error prefix
+// (e.g. "MOVED 3999 127.0.0.1:6381"), so that cluster aware clients can follow redirections.
+type Error struct {
+	Code    string // MOVED, ASK, CROSSSLOT, TRYAGAIN or CLUSTERDOWN.
+	Message string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s %s", err.Code, err.Message)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Kelvin Clement Mwinuka
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slots_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/echovault/echovault/internal/slots"
+)
+
+func Test_KeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{key: "foo", want: 12182},
+		{key: "bar", want: 5061},
+		{key: "hello", want: 866},
+		{key: "123456789", want: 0x31C3},
+		// Only the hashtag is hashed.
+		{key: "{foo}.bar", want: 12182},
+		{key: "prefix{foo}", want: 12182},
+		// Only the first hashtag is used.
+		{key: "{foo}{bar}", want: 12182},
+		// An empty hashtag is ignored, so the whole key is hashed.
+		{key: "{}foo", want: slots.KeySlot("{}foo")},
+	}
+	for _, test := range tests {
+		if got := slots.KeySlot(test.key); got != test.want {
+			t.Errorf("KeySlot(%q) = %d, want %d", test.key, got, test.want)
+		}
+	}
+	if slots.KeySlot("{}foo") == slots.KeySlot("foo") {
+		t.Errorf("expected the empty hashtag to be ignored")
+	}
+	if slots.KeySlot("{user1000}.following") != slots.KeySlot("{user1000}.followers") {
+		t.Errorf("expected keys with the same hashtag to be in the same slot")
+	}
+}
+
+func Test_Ranges(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []slots.Range
+		format  string
+		wantErr bool
+	}{
+		{
+			name:   "1. Ranges and single slots are merged and sorted",
+			input:  "100-200,0-10,11,300",
+			want:   []slots.Range{{Start: 0, End: 11}, {Start: 100, End: 200}, {Start: 300, End: 300}},
+			format: "0-11,100-200,300",
+		},
+		{
+			name:   "2. All the slots",
+			input:  "0-16383",
+			want:   slots.AllRanges(),
+			format: "0-16383",
+		},
+		{
+			name:    "3. Slot out of range",
+			input:   "0-16384",
+			wantErr: true,
+		},
+		{
+			name:    "4. Descending range",
+			input:   "10-5",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := slots.ParseRanges(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected error parsing %s", test.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseRanges() = %v, want %v", got, test.want)
+			}
+			if format := slots.FormatRanges(got); format != test.format {
+				t.Errorf("FormatRanges() = %s, want %s", format, test.format)
+			}
+		})
+	}
+}
+
+func Test_State(t *testing.T) {
+	state := slots.NewState([]slots.Range{{Start: 0, End: 99}})
+	state.SetOwned(100, true)
+	state.SetOwned(50, false)
+
+	clone := state.Clone()
+	clone.SetOwned(0, false)
+	clone.Migrating[1] = "shard-2"
+
+	if !reflect.DeepEqual(state.Slots, []slots.Range{{Start: 0, End: 49}, {Start: 51, End: 100}}) {
+		t.Errorf("unexpected slots %v", state.Slots)
+	}
+	if !state.Owns(0) || state.Owns(50) || !state.Owns(100) || state.Owns(101) {
+		t.Errorf("unexpected ownership of slots %v", state.Slots)
+	}
+	if len(state.Migrating) != 0 {
+		t.Errorf("expected the clone to be independent of the state")
+	}
+}
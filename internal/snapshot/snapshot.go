@@ -15,12 +15,14 @@
 package snapshot
 
 import (
+	"bufio"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/clock"
+	"github.com/echovault/echovault/internal/codec"
 	"io"
 	"io/fs"
 	"log"
@@ -46,7 +48,7 @@ type Engine struct {
 	snapshotThreshold         uint64
 	startSnapshotFunc         func()
 	finishSnapshotFunc        func()
-	getStateFunc              func() map[string]internal.KeyData
+	getStateFunc              func() internal.StateView
 	setLatestSnapshotTimeFunc func(msec int64)
 	getLatestSnapshotTimeFunc func() int64
 	setKeyDataFunc            func(key string, data internal.KeyData)
@@ -88,7 +90,7 @@ func WithFinishSnapshotFunc(f func()) func(engine *Engine) {
 	}
 }
 
-func WithGetStateFunc(f func() map[string]internal.KeyData) func(engine *Engine) {
+func WithGetStateFunc(f func() internal.StateView) func(engine *Engine) {
 	return func(engine *Engine) {
 		engine.getStateFunc = f
 	}
@@ -121,8 +123,8 @@ func NewSnapshotEngine(options ...func(engine *Engine)) *Engine {
 		snapshotThreshold:  1000,
 		startSnapshotFunc:  func() {},
 		finishSnapshotFunc: func() {},
-		getStateFunc: func() map[string]internal.KeyData {
-			return map[string]internal.KeyData{}
+		getStateFunc: func() internal.StateView {
+			return internal.StateView{State: map[string]internal.KeyData{}, Release: func() {}}
 		},
 		setKeyDataFunc:            func(key string, data internal.KeyData) {},
 		setLatestSnapshotTimeFunc: func(msec int64) {},
@@ -216,31 +218,56 @@ func (engine *Engine) TakeSnapshot() error {
 		}
 	}
 
-	// Get current state
+	// Get a view of the current state. Writes can carry on while it's being persisted.
+	view := engine.getStateFunc()
+	defer view.Release()
 	snapshotObject := internal.SnapshotObject{
-		State:                      internal.FilterExpiredKeys(engine.clock.Now(), engine.getStateFunc()),
-		LatestSnapshotMilliseconds: engine.getLatestSnapshotTimeFunc(),
+		State: internal.FilterExpiredKeys(engine.clock.Now(), view.State),
 	}
-	out, err := json.Marshal(snapshotObject)
-	if err != nil {
+
+	// The hash only covers the state, so it's computed before the snapshot time is set.
+	// The state is encoded in key order, so the hash only changes when the state does.
+	hash := md5.New()
+	if err = codec.Encode(hash, snapshotObject); err != nil {
 		log.Println(err)
 		return err
 	}
-
-	snapshotHash := md5.Sum(out)
+	var snapshotHash [16]byte
+	copy(snapshotHash[:], hash.Sum(nil))
 	if snapshotHash == manifest.LatestSnapshotHash {
 		return errors.New("nothing new to snapshot")
 	}
 
 	// Update the snapshotObject
 	snapshotObject.LatestSnapshotMilliseconds = msec
-	// Marshal the updated snapshotObject
-	out, err = json.Marshal(snapshotObject)
+
+	// Create snapshot directory
+	snapshotDirname := path.Join(engine.directory, "snapshots", fmt.Sprintf("%d", msec))
+	if err := os.MkdirAll(snapshotDirname, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Create snapshot file
+	f, err := os.OpenFile(path.Join(snapshotDirname, "state.bin"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	// Stream the state to the file
+	if err = codec.Encode(f, snapshotObject); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		log.Println(err)
+	}
 
+	// Only point the manifest to the snapshot once it's been written.
 	// os.Create will replace the old manifest file
 	mf, err = os.Create(path.Join(dirname, "manifest.bin"))
 	if err != nil {
@@ -250,7 +277,7 @@ func (engine *Engine) TakeSnapshot() error {
 
 	// Write the latest manifest data
 	manifest = &Manifest{
-		LatestSnapshotHash:         md5.Sum(out),
+		LatestSnapshotHash:         snapshotHash,
 		LatestSnapshotMilliseconds: msec,
 	}
 	mo, err := json.Marshal(manifest)
@@ -270,32 +297,6 @@ func (engine *Engine) TakeSnapshot() error {
 		return err
 	}
 
-	// Create snapshot directory
-	dirname = path.Join(engine.directory, "snapshots", fmt.Sprintf("%d", msec))
-	if err := os.MkdirAll(dirname, os.ModePerm); err != nil {
-		return err
-	}
-
-	// Create snapshot file
-	f, err := os.OpenFile(path.Join(dirname, "state.bin"), os.O_WRONLY|os.O_CREATE, os.ModePerm)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
-
-	// Write state to file
-	if _, err = f.Write(out); err != nil {
-		return err
-	}
-	if err = f.Sync(); err != nil {
-		log.Println(err)
-	}
-
 	// Set the latest snapshot in unix milliseconds
 	engine.setLatestSnapshotTimeFunc(msec)
 
@@ -341,15 +342,23 @@ func (engine *Engine) Restore() error {
 		return err
 	}
 
-	sd, err := io.ReadAll(sf)
-	if err != nil {
-		return nil
-	}
+	defer func() {
+		_ = sf.Close()
+	}()
 
 	snapshotObject := new(internal.SnapshotObject)
 
-	if err = json.Unmarshal(sd, snapshotObject); err != nil {
-		return err
+	r := bufio.NewReader(sf)
+	if codec.IsBinary(r) {
+		if *snapshotObject, err = codec.Decode(r); err != nil {
+			return err
+		}
+	} else {
+		// Snapshots taken before the binary format was introduced are JSON encoded.
+		// They're migrated to the binary format when the next snapshot is taken.
+		if err = json.NewDecoder(r).Decode(snapshotObject); err != nil {
+			return err
+		}
 	}
 
 	engine.setLatestSnapshotTimeFunc(snapshotObject.LatestSnapshotMilliseconds)
@@ -15,11 +15,15 @@
 package snapshot_test
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"github.com/echovault/echovault/internal"
 	"github.com/echovault/echovault/internal/clock"
+	"github.com/echovault/echovault/internal/codec"
 	"github.com/echovault/echovault/internal/snapshot"
 	"os"
+	"path"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -51,8 +55,8 @@ func Test_SnapshotEngine(t *testing.T) {
 		"key4": {Value: "value4", ExpireAt: clock.NewClock().Now().Add(23 * time.Second)},
 		"key5": {Value: "value5", ExpireAt: clock.NewClock().Now().Add(121 * time.Millisecond)},
 	}
-	getStateFunc := func() map[string]internal.KeyData {
-		return state
+	getStateFunc := func() internal.StateView {
+		return internal.StateView{State: state, Release: func() {}}
 	}
 
 	restoredState := map[string]internal.KeyData{}
@@ -117,3 +121,78 @@ func Test_SnapshotEngine(t *testing.T) {
 
 	_ = os.RemoveAll(directory)
 }
+
+func Test_SnapshotEngine_RestoreJSON(t *testing.T) {
+	directory := "./testdata/json"
+	defer func() {
+		_ = os.RemoveAll(directory)
+	}()
+
+	// Write a snapshot in the JSON format used before the binary format was introduced.
+	var msec int64 = 1704067200000
+	state := map[string]internal.KeyData{
+		"key1": {Value: "value1", ExpireAt: clock.NewClock().Now().Add(10 * time.Second)},
+		"key2": {Value: "value2"},
+	}
+	if err := os.MkdirAll(path.Join(directory, "snapshots", fmt.Sprintf("%d", msec)), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := json.Marshal(snapshot.Manifest{LatestSnapshotMilliseconds: msec})
+	if err := os.WriteFile(path.Join(directory, "snapshots", "manifest.bin"), manifest, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	object, _ := json.Marshal(internal.SnapshotObject{State: state, LatestSnapshotMilliseconds: msec})
+	if err := os.WriteFile(path.Join(directory, "snapshots", fmt.Sprintf("%d", msec), "state.bin"), object, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredState := map[string]internal.KeyData{}
+	var latestSnapshotTime int64
+	snapshotEngine := snapshot.NewSnapshotEngine(
+		snapshot.WithClock(clock.NewClock()),
+		snapshot.WithDirectory(directory),
+		snapshot.WithInterval(0),
+		snapshot.WithGetStateFunc(func() internal.StateView {
+			return internal.StateView{State: restoredState, Release: func() {}}
+		}),
+		snapshot.WithSetKeyDataFunc(func(key string, data internal.KeyData) {
+			restoredState[key] = data
+		}),
+		snapshot.WithSetLatestSnapshotTimeFunc(func(msec int64) {
+			latestSnapshotTime = msec
+		}),
+		snapshot.WithGetLatestSnapshotTimeFunc(func() int64 {
+			return latestSnapshotTime
+		}),
+	)
+
+	if err := snapshotEngine.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if latestSnapshotTime != msec {
+		t.Errorf("expected latest snapshot time %d, got %d", msec, latestSnapshotTime)
+	}
+	for key, data := range state {
+		if restoredState[key].Value != data.Value {
+			t.Errorf("expected value %v for key %s, got %v", data.Value, key, restoredState[key].Value)
+		}
+		if !restoredState[key].ExpireAt.Equal(data.ExpireAt) {
+			t.Errorf("expected expiry time %v for key %s, got %v", data.ExpireAt, key, restoredState[key].ExpireAt)
+		}
+	}
+
+	// The next snapshot migrates the state to the binary format.
+	if err := snapshotEngine.TakeSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path.Join(directory, "snapshots", fmt.Sprintf("%d", latestSnapshotTime), "state.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if !codec.IsBinary(bufio.NewReader(f)) {
+		t.Error("expected the snapshot to be migrated to the binary format")
+	}
+}
@@ -17,6 +17,7 @@ package internal
 import (
 	"context"
 	"github.com/echovault/echovault/internal/clock"
+	"github.com/echovault/echovault/internal/slots"
 	"net"
 	"time"
 )
@@ -24,17 +25,41 @@ import (
 type KeyData struct {
 	Value    interface{}
 	ExpireAt time.Time
+	// Version is the index of the raft log entry that last modified the key, in cluster mode.
+	// Every node applies the same log, so the nodes agree on the version of each key.
+	Version uint64
 }
 
 type ContextServerID string
 type ContextConnID string
+type ContextProtocol string
+type ContextTimestamp string
+type ContextReplay string
+type ContextCommandEvent string
+type ContextReadConsistency string
+type ContextUser string
+
+// ReadConsistency is the consistency required for read commands in cluster mode.
+type ReadConsistency struct {
+	Mode   string        // The read consistency mode: leader, lease or stale.
+	MaxLag time.Duration // The max time since a follower last heard from the leader for stale reads. 0 means no limit.
+}
 
 type ApplyRequest struct {
-	Type         string   `json:"Type"` // command | delete-key
-	ServerID     string   `json:"ServerID"`
-	ConnectionID string   `json:"ConnectionID"`
-	CMD          []string `json:"CMD"`
-	Key          string   `json:"Key"`
+	Type         string            `json:"Type"` // command | delete-key | delete-key-if | transaction | script | slots | restore-key
+	ServerID     string            `json:"ServerID"`
+	ConnectionID string            `json:"ConnectionID"`
+	CMD          []string          `json:"CMD"`
+	Key          string            `json:"Key"`
+	Event        string            `json:"Event"`       // The keyspace event of a delete-key request: del, expired or evicted.
+	Transaction  [][]string        `json:"Transaction"` // The queued commands of a transaction, in execution order.
+	Watched      map[string]uint64 `json:"Watched"`     // The versions of the keys watched by a transaction.
+	Protocol     int               `json:"Protocol"`    // The RESP protocol version of the connection that sent the command.
+	Timestamp    int64             `json:"Timestamp"`   // The leader's time in unix nanoseconds. Every node uses it as the command's current time.
+	Slots        *slots.State      `json:"Slots"`       // The new slot state of the shard for a slots request.
+	Value        []byte            `json:"Value"`       // The encoded value of a restore-key request.
+	ExpireAt     int64             `json:"ExpireAt"`    // The expiry time of a restore-key request in unix nanoseconds. 0 if the key has no expiry.
+	Version      uint64            `json:"Version"`     // The version the key must still have for a delete-key-if request.
 }
 
 type ApplyResponse struct {
@@ -42,9 +67,87 @@ type ApplyResponse struct {
 	Response []byte
 }
 
+// StateView is a consistent point-in-time view of the keyspace, used to persist the state without blocking writes.
+// The values in State are shared with the store, so they must not be modified.
+// Release must be called once the view is no longer needed.
+type StateView struct {
+	State   map[string]KeyData
+	Release func()
+}
+
 type SnapshotObject struct {
 	State                      map[string]KeyData
 	LatestSnapshotMilliseconds int64
+	Slots                      *slots.State // The slot state of the raft group. It's nil in standalone mode.
+}
+
+// ConnectionInfo holds the details of a client connection.
+type ConnectionInfo struct {
+	Id       uint64 // The ID assigned to the connection when it was accepted.
+	Name     string // The name set by the client using HELLO SETNAME.
+	Protocol int    // The RESP protocol version negotiated by the client. This is either 2 or 3.
+
+	ReadConsistency ReadConsistency // The consistency of the connection's reads in cluster mode.
+	Asking          bool            // Set by ASKING. Allows the next command to access a slot that is being imported.
+}
+
+// ClusterNode is a node of a shard, as reported by the CLUSTER SLOTS and CLUSTER SHARDS commands.
+type ClusterNode struct {
+	ID     string // The server ID.
+	Host   string // The host that clients connect to.
+	Port   int    // The port that clients connect to.
+	Leader bool   // True if the node is the leader of the shard's raft group.
+}
+
+// ClusterShard is a shard of the cluster and the slots it owns.
+type ClusterShard struct {
+	ID    string        // The shard ID from the config.
+	Epoch uint64        // The epoch of the shard's slot claims.
+	Slots []slots.Range // The slots owned by the shard.
+	Nodes []ClusterNode // The nodes of the shard. The leader is first.
+}
+
+// RaftNode is a member of the node's raft group, as reported by the CLUSTER NODES command.
+type RaftNode struct {
+	ID             string // The server ID.
+	RaftAddr       string // The address of the node's raft transport.
+	MemberlistAddr string // The address of the node's memberlist. Empty if the node is not a known member.
+	Role           string // "leader", "follower" or "learner".
+	Myself         bool   // True if the node is the one that reported the list.
+	// The milliseconds since the node last heard from the leader. It's 0 on the leader,
+	// and -1 if the node never heard from a leader or could not be reached.
+	LastContact  int64
+	CommitIndex  int64 // The latest index committed in the raft log. -1 if the node could not be reached.
+	AppliedIndex int64 // The latest index applied to the store. -1 if the node could not be reached.
+}
+
+// ServerInfo holds the details of the EchoVault instance that are reported to clients.
+type ServerInfo struct {
+	Server  string   // The server name, this is always "echovault".
+	Version string   // The EchoVault version.
+	Id      string   // The server ID from the config.
+	Mode    string   // "standalone" or "cluster".
+	Role    string   // "master" if the instance is standalone or the cluster leader, "replica" otherwise.
+	Modules []string // The modules loaded in the instance.
+}
+
+// MemoryStats holds the memory usage of the EchoVault instance.
+type MemoryStats struct {
+	TotalAllocated   uint64 // The bytes allocated on the heap, as reported by the Go runtime.
+	DatasetBytes     int64  // The estimated bytes used by the keys and values in the store.
+	PeakDatasetBytes int64  // The highest DatasetBytes since the instance started.
+	KeysCount        int    // The number of keys in the store.
+	EvictedKeys      uint64 // The number of keys evicted to stay under max-memory.
+	MaxMemory        uint64 // The max-memory config. 0 means there's no limit.
+	EvictionPolicy   string // The eviction policy config.
+
+	VolatileKeysCount       int    // The number of keys with an expiry time.
+	ExpiredKeys             uint64 // The number of keys deleted because their expiry time had passed.
+	ActiveExpiredKeys       uint64 // The number of ExpiredKeys deleted by the active expiry cycle, before being accessed.
+	ExpireCycles            uint64 // The number of active expiry cycles that have run.
+	ExpireCyclesTimeLimited uint64 // The number of active expiry cycles that stopped because they ran out of time.
+
+	DroppedNotifications uint64 // The number of keyspace notifications dropped because a subscriber fell behind.
 }
 
 // KeyExtractionFuncResult is the return type of the KeyExtractionFunc for the command/subcommand.
@@ -67,12 +170,24 @@ type HandlerFuncParams struct {
 	// Context is the context passed from the EchoVault instance.
 	Context context.Context
 	// Command is the string slice contains the command (e.g []string{"SET", "key", "value"})
+	// Handlers that resolve non-deterministic arguments (e.g. the "*" ID in XADD) may replace the argument
+	// in place, so that the resolved command is the one written to the AOF.
 	Command []string
 	// Connection is the connection that triggered this command.
 	// Do not write the response directly to the connection, return it from the function.
 	Connection *net.Conn
+	// Protocol is the RESP protocol version negotiated by the connection, either 2 or 3.
+	// When it's 3, the handler should return native RESP3 types (maps, sets, doubles, nulls) where applicable.
+	// Embedded calls always use RESP2.
+	Protocol int
 	// KeysExist returns a map that specifies which keys exist in the keyspace.
 	KeysExist func(keys []string) map[string]bool
+	// ScanKeys returns the next batch of about count keys from the cursor, and the cursor for the next call.
+	// The returned cursor is 0 when the whole keyspace has been scanned. Expired keys are skipped.
+	ScanKeys func(cursor uint64, count int) ([]string, uint64)
+	// GetScanIndex returns the scan index of a value that doesn't keep its own, e.g. a hash.
+	// The index is built with build on the first call after the key is modified, and reused until then.
+	GetScanIndex func(key string, build func() *ScanIndex) *ScanIndex
 	// GetExpiry returns the expiry time of a key.
 	GetExpiry func(key string) time.Time
 	// DeleteKey deletes the specified key. Returns an error if the deletion was unsuccessful.
@@ -112,10 +227,94 @@ type HandlerFuncParams struct {
 	UnloadModule func(module string)
 	// ListModules returns the list of modules loaded in the EchoVault instance.
 	ListModules func() []string
+	// Multi marks the start of a transaction block on the connection.
+	// All subsequent commands from the connection are queued until Exec or Discard is called.
+	Multi func(conn *net.Conn) error
+	// Exec atomically executes all the commands queued on the connection since Multi was called.
+	// Returns a RESP array containing the response of each queued command,
+	// or a nil array if any of the watched keys were modified.
+	Exec func(ctx context.Context, conn *net.Conn) ([]byte, error)
+	// Discard flushes all the commands queued on the connection and exits the transaction block.
+	Discard func(conn *net.Conn) error
+	// Watch marks the keys to be watched for conditional execution of the connection's next transaction.
+	Watch func(conn *net.Conn, keys []string) error
+	// Unwatch flushes all the keys previously watched by the connection.
+	Unwatch func(conn *net.Conn)
+	// GetConnectionInfo returns the details of the connection.
+	GetConnectionInfo func(conn *net.Conn) ConnectionInfo
+	// SetConnectionInfo sets the name and the RESP protocol version of the connection.
+	SetConnectionInfo func(conn *net.Conn, clientname string, protocol int)
+	// SetReadConsistency sets the consistency of the connection's reads in cluster mode.
+	// When the connection is nil, it sets the consistency of embedded calls.
+	SetReadConsistency func(conn *net.Conn, consistency ReadConsistency)
+	// GetClusterShards returns the shards of the cluster. Returns an error in standalone mode.
+	GetClusterShards func() ([]ClusterShard, error)
+	// SetSlot changes the state of a slot in the shard of this node, which must be the shard's leader.
+	// The action is importing, migrating, stable or node, following CLUSTER SETSLOT.
+	SetSlot func(ctx context.Context, slot int, action string, shardID string) error
+	// MigrateSlot moves up to count keys of a migrating slot to the target shard. Returns the number of keys moved.
+	MigrateSlot func(ctx context.Context, slot int, count int) (int, error)
+	// Asking allows the connection's next command to access a slot that is being imported.
+	Asking func(conn *net.Conn) error
+	// GetClusterInfo returns the state of the node's raft group along with the raft stats of the node.
+	// Returns an error in standalone mode.
+	GetClusterInfo func() (map[string]string, error)
+	// GetClusterNodes returns the members of the node's raft group. Returns an error in standalone mode.
+	GetClusterNodes func(ctx context.Context) ([]RaftNode, error)
+	// LeaveCluster removes the node from its raft group and from the memberlist cluster.
+	LeaveCluster func(ctx context.Context) error
+	// RemoveClusterNode removes the node with the server ID from the raft group. Must be called on the leader.
+	RemoveClusterNode func(id string) error
+	// PromoteClusterNode makes the learner with the server ID a voter. Must be called on the leader.
+	PromoteClusterNode func(id string) error
+	// TransferLeadership transfers the leadership of the raft group to the node with the server ID,
+	// or to the most up-to-date voter when the ID is empty. Must be called on the leader.
+	TransferLeadership func(id string) error
+	// TakeRaftSnapshot takes a raft snapshot and waits for it to complete.
+	TakeRaftSnapshot func() error
+	// GetServerInfo returns the details of the EchoVault instance.
+	GetServerInfo func() ServerInfo
+	// GetMemoryStats returns the memory usage of the EchoVault instance.
+	GetMemoryStats func() MemoryStats
+	// NotifyOnKeys returns a channel that receives a value when any of the keys is modified, and a function that
+	// deregisters the channel once it's no longer needed. Blocking commands use this to wait for data.
+	// Call it before checking the keys, so that modifications made in between are not missed.
+	// NotifyOnKeys is nil when the command is not allowed to block (e.g. inside a transaction or when the
+	// command is applied through raft). Blocking commands should then behave as if the timeout was reached.
+	NotifyOnKeys func(keys []string) (<-chan struct{}, func())
+	// QueueOnKeys works like NotifyOnKeys, but the commands queued on a key are woken one at a time
+	// in the order they were queued. Commands that consume the data they wait for (e.g. BLPOP) use this
+	// so that blocked clients are served fairly. The deregister function wakes the next command in the queue,
+	// so it must be called as soon as the command is done with the keys. QueueOnKeys is nil whenever NotifyOnKeys is.
+	QueueOnKeys func(keys []string) (<-chan struct{}, func())
+	// Wait blocks until the channel returned by NotifyOnKeys or QueueOnKeys receives a value, the deadline channel
+	// receives a value or the command's context is done. Returns true if the keys were modified.
+	// Blocking commands must wait for their keys with Wait, as write commands leave the write section while they wait.
+	Wait func(notify <-chan struct{}, deadline <-chan time.Time) bool
+	// ApplyCommand applies a single attempt of the command through the raft log and returns its response.
+	// It's set in cluster mode for blocking write commands, which wait for their keys on the node that received them
+	// and only read the keys there. Once data is available, the command is applied with ApplyCommand, where it does
+	// not block. If another client took the data first, the response is null and the command waits again.
+	ApplyCommand func(ctx context.Context, cmd []string) ([]byte, error)
+	// Eval runs the Lua script atomically with the KEYS and ARGV tables set to keys and args.
+	// The script is added to the script cache. Returns the RESP encoded result of the script.
+	Eval func(ctx context.Context, conn *net.Conn, script string, keys []string, args []string) ([]byte, error)
+	// EvalSHA works like Eval but runs the script with the given SHA1 digest from the script cache.
+	EvalSHA func(ctx context.Context, conn *net.Conn, sha string, keys []string, args []string) ([]byte, error)
+	// ScriptLoad compiles the script and adds it to the script cache without running it.
+	// Returns the SHA1 digest of the script.
+	ScriptLoad func(script string) (string, error)
+	// ScriptExists returns whether each of the SHA1 digests is in the script cache.
+	ScriptExists func(shas []string) []bool
+	// ScriptFlush removes all the scripts from the script cache.
+	ScriptFlush func()
+	// ScriptKill stops the script that's currently running, if it has not written to the store yet.
+	ScriptKill func() error
 }
 
 // HandlerFunc is a functions described by a command where the bulk of the command handling is done.
-// This function returns a byte slice which contains a RESP2 response. The response from this function
+// This function returns a byte slice which contains a RESP2 response, or a RESP3 response
+// if the connection has negotiated protocol version 3 (see HandlerFuncParams.Protocol). The response from this function
 // is forwarded directly to the client connection that triggered the command.
 // In embedded mode, the response is parsed and a native Go type is returned to the caller.
 type HandlerFunc func(params HandlerFuncParams) ([]byte, error)
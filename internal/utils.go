@@ -24,10 +24,10 @@ import (
 	"github.com/echovault/echovault/internal/constants"
 	"io"
 	"log"
+	"math"
 	"math/big"
 	"net"
 	"reflect"
-	"runtime"
 	"slices"
 	"strconv"
 	"strings"
@@ -151,6 +151,23 @@ func IsWriteCommand(command Command, subCommand SubCommand) bool {
 	return slices.Contains(append(command.Categories, subCommand.Categories...), constants.WriteCategory)
 }
 
+func IsReadCommand(command Command, subCommand SubCommand) bool {
+	return slices.Contains(append(command.Categories, subCommand.Categories...), constants.ReadCategory)
+}
+
+func IsBlockingCommand(command Command, subCommand SubCommand) bool {
+	return slices.Contains(append(command.Categories, subCommand.Categories...), constants.BlockingCategory)
+}
+
+// IsNullResponse returns true if the response is a RESP2 null bulk string or null array, or the RESP3 null type.
+func IsNullResponse(res []byte) bool {
+	switch string(res) {
+	case "$-1\r\n", "*-1\r\n", constants.NullResponse:
+		return true
+	}
+	return false
+}
+
 func AbsInt(n int) int {
 	if n < 0 {
 		return -n
@@ -186,30 +203,6 @@ func ParseMemory(memory string) (uint64, error) {
 	return uint64(bytesInt), nil
 }
 
-// IsMaxMemoryExceeded checks whether we have exceeded the current maximum memory limit.
-func IsMaxMemoryExceeded(maxMemory uint64) bool {
-	if maxMemory == 0 {
-		return false
-	}
-
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-
-	// If we're currently using less than the configured max memory, return false.
-	if memStats.HeapInuse < maxMemory {
-		return false
-	}
-
-	// If we're currently using more than max memory, force a garbage collection before we start deleting keys.
-	// This measure is to prevent deleting keys that may be important when some memory can be reclaimed
-	// by just collecting garbage.
-	runtime.GC()
-	runtime.ReadMemStats(&memStats)
-
-	// Return true when whe are above or equal to max memory.
-	return memStats.HeapInuse >= maxMemory
-}
-
 // FilterExpiredKeys filters out keys that are already expired, so they are not persisted.
 func FilterExpiredKeys(now time.Time, state map[string]KeyData) map[string]KeyData {
 	var keysToDelete []string
@@ -266,6 +259,20 @@ func EncodeCommand(cmd []string) []byte {
 	return []byte(res)
 }
 
+// EncodeDouble returns the RESP3 double representation of f.
+// Infinite values are encoded as "inf" and "-inf".
+func EncodeDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ",inf\r\n"
+	case math.IsInf(f, -1):
+		return ",-inf\r\n"
+	case math.IsNaN(f):
+		return ",nan\r\n"
+	}
+	return fmt.Sprintf(",%s\r\n", strconv.FormatFloat(f, 'f', -1, 64))
+}
+
 func ParseNilResponse(b []byte) (bool, error) {
 	r := resp.NewReader(bytes.NewReader(b))
 	v, _, err := r.ReadValue()